@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// analyzeSearchTime is how long /analyze searches unless it's told otherwise, the same as a move.
+	analyzeSearchTime = 500 * time.Millisecond
+	// analyzeMaxSearchTime is the longest /analyze will search for.
+	analyzeMaxSearchTime = 10 * time.Second
+)
+
+// AnalysedMove is one of the moves the search looked at from the board.
+type AnalysedMove struct {
+	Move   string  `json:"move"`
+	Visits int64   `json:"visits"`
+	Value  float64 `json:"value"` // average score for the snake moving
+}
+
+// Analysis is everything /analyze found out about a board.
+type Analysis struct {
+	ID          string             `json:"id"`
+	Move        string             `json:"move"`
+	Visits      int64              `json:"visits"`
+	SearchMs    int64              `json:"search_ms"`
+	Moves       []AnalysedMove     `json:"moves"` // most visited first
+	Eval        map[string]float64 `json:"eval"`  // each module's unweighted score for the snake moving
+	Voronoi     [][]int            `json:"voronoi"`
+	VoronoiText string             `json:"voronoi_text"`
+	BoardText   string             `json:"board_text"`
+	TreeURL     string             `json:"tree_url"`
+}
+
+// analyzeBoard searches the board for the first snake, for as long as it's given, and puts together
+// what the search and the evaluation make of it. The tree is kept in memory for the analysis' tree link.
+// It has every cpu to itself only while no game is searching, since a move has a deadline and this doesn't.
+func analyzeBoard(ctx context.Context, board Board, search time.Duration, config SearchConfig) Analysis {
+	searchCtx, cancel := context.WithTimeout(ctx, search)
+	start := time.Now()
+	ticket := searchScheduler.Yield(searchCtx, runtime.NumCPU())
+	root := MCTS(searchCtx, "analyze", copyBoard(board), math.MaxInt, runtime.NumCPU(), nil, WithSearchConfig(config), WithSearchTicket(ticket))
+	cancel()
+
+	voronoi := GenerateVoronoi(board)
+	analysis := Analysis{
+		ID:          uuid.New().String(),
+		Move:        determineBestMove(root),
+		Visits:      root.Visits,
+		SearchMs:    time.Since(start).Milliseconds(),
+		Eval:        evaluationBreakdown(board, 0, config.fullModules()),
+		Voronoi:     voronoi,
+		VoronoiText: VisualizeVoronoi(voronoi, board.Snakes),
+		BoardText:   visualizeBoard(board),
+	}
+	for _, child := range root.Children() {
+		visits := atomic.LoadInt64(&child.Visits)
+		move := AnalysedMove{Move: moveForChild(root, child), Visits: visits}
+		if visits > 0 {
+			move.Value = child.Score / float64(visits)
+		}
+		analysis.Moves = append(analysis.Moves, move)
+	}
+	sort.SliceStable(analysis.Moves, func(i, j int) bool { return analysis.Moves[i].Visits > analysis.Moves[j].Visits })
+	analysis.TreeURL = exportTree(analysis.ID, root, config.fullModules(), false, slog.Default())
+	return analysis
+}
+
+// handleAnalyze takes a board, the same json the test cases are written in, and answers with what we'd
+// do on it and why. ?ms= sets how long it searches and ?snake= whose move it is, the first snake's if
+// it's left out. ?format=text answers in plain text for a terminal instead of json.
+func handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	var board Board
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGameBytes)).Decode(&board); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode board: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(board.Snakes) == 0 {
+		http.Error(w, "board has no snakes", http.StatusBadRequest)
+		return
+	}
+	snakeID := r.URL.Query().Get("snake")
+	if snakeID == "" {
+		snakeID = board.Snakes[0].ID
+	}
+	// the board gets the same checks a move would
+	game := BattleSnakeGame{Game: Game{ID: "analyze"}, Turn: board.Turn, Board: board, You: Snake{ID: snakeID}}
+	if err := game.ValidateMove(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	search := analyzeSearchTime
+	if ms := r.URL.Query().Get("ms"); ms != "" {
+		parsed, err := strconv.Atoi(ms)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "ms has to be a positive number of milliseconds", http.StatusBadRequest)
+			return
+		}
+		search = time.Duration(parsed) * time.Millisecond
+	}
+	if search > analyzeMaxSearchTime {
+		search = analyzeMaxSearchTime
+	}
+
+	board = reorderSnakes(board, snakeID)
+	analysis := analyzeBoard(r.Context(), board, search, defaultSearchConfig)
+	if r.URL.Query().Get("format") == "text" {
+		writeAnalysisText(w, analysis, board)
+		return
+	}
+	writeJSON(w, analysis)
+}
+
+// writeAnalysisText writes the analysis for reading in a terminal, with the board in colour so a four
+// snake board can be told apart. Boards bigger than the standard one are drawn compact to fit.
+func writeAnalysisText(w http.ResponseWriter, analysis Analysis, board Board) {
+	options := []func(*boardOptions){WithANSI(), WithMove(directionFromString(analysis.Move), 0)}
+	if board.Width > 11 {
+		options = append(options, WithCompact())
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, visualizeBoard(board, options...))
+	fmt.Fprintf(w, "\n%s after %d visits in %dms\n", analysis.Move, analysis.Visits, analysis.SearchMs)
+	for _, move := range analysis.Moves {
+		fmt.Fprintf(w, "  %-6s %8d visits %7.3f\n", move.Move, move.Visits, move.Value)
+	}
+	modules := make([]string, 0, len(analysis.Eval))
+	for module := range analysis.Eval {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	fmt.Fprintln(w, "\neval")
+	for _, module := range modules {
+		fmt.Fprintf(w, "  %-10s %7.3f\n", module, analysis.Eval[module])
+	}
+	fmt.Fprintf(w, "\ntree %s\n", analysis.TreeURL)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func analyzeRequest(t *testing.T, router http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAnalyze(t *testing.T) {
+	router := newRouter("secret")
+	board := blunderTestBoard()
+
+	rec := analyzeRequest(t, router, http.MethodPost, "/analyze?ms=200", board)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var analysis Analysis
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &analysis))
+
+	assert.NotEqual(t, "left", analysis.Move, "left runs into our own body")
+	assert.Positive(t, analysis.Visits)
+	require.NotEmpty(t, analysis.Moves)
+	assert.Equal(t, analysis.Move, analysis.Moves[0].Move, "the most visited move comes first")
+	for i := 1; i < len(analysis.Moves); i++ {
+		assert.GreaterOrEqual(t, analysis.Moves[i-1].Visits, analysis.Moves[i].Visits)
+	}
+	assert.Contains(t, analysis.Eval, "voronoi")
+	require.Len(t, analysis.Voronoi, board.Height)
+	assert.Len(t, analysis.Voronoi[0], board.Width)
+	assert.NotEmpty(t, analysis.VoronoiText)
+	assert.NotEmpty(t, analysis.BoardText)
+
+	rec = analyzeRequest(t, router, http.MethodGet, analysis.TreeURL, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tree TreeNode
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tree))
+	assert.Equal(t, analysis.Visits, tree.Visits)
+	assert.NotEmpty(t, tree.Children)
+}
+
+func TestAnalyzeOtherSnake(t *testing.T) {
+	router := newRouter("secret")
+	rec := analyzeRequest(t, router, http.MethodPost, "/analyze?ms=50&snake=them", blunderTestBoard())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var analysis Analysis
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &analysis))
+	assert.NotEqual(t, "up", analysis.Move, "up runs back into them")
+}
+
+func TestAnalyzeRejects(t *testing.T) {
+	router := newRouter("secret")
+	outOfBounds := blunderTestBoard()
+	outOfBounds.Food = []Point{{X: 20, Y: 20}}
+
+	for name, path := range map[string]string{
+		"bad duration":  "/analyze?ms=soon",
+		"unknown snake": "/analyze?snake=nobody",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, analyzeRequest(t, router, http.MethodPost, path, blunderTestBoard()).Code)
+		})
+	}
+	assert.Equal(t, http.StatusBadRequest, analyzeRequest(t, router, http.MethodPost, "/analyze", outOfBounds).Code)
+	assert.Equal(t, http.StatusBadRequest, analyzeRequest(t, router, http.MethodPost, "/analyze", Board{Width: 7, Height: 7}).Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/analyze", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAnalyzeText(t *testing.T) {
+	router := newRouter("secret")
+	rec := analyzeRequest(t, router, http.MethodPost, "/analyze?ms=50&format=text", blunderTestBoard())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, ansiSnakeColours[0], "the board's in colour")
+	assert.Contains(t, body, "visits")
+	assert.Contains(t, body, "voronoi")
+	assert.Contains(t, body, "tree /trees/")
+}
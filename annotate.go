@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// annotatedSize is the width and height of the annotated gif. square and big enough to read on a phone
+	// without the file getting silly.
+	annotatedSize = 540
+	// annotatedHeader is the strip along the top with the turn and eval written in it
+	annotatedHeader = 24
+	// annotatedBarWidth is the eval bar down the left hand side
+	annotatedBarWidth = 24
+	// annotatedGap separates the bar from the board and the board from the edges
+	annotatedGap = 6
+)
+
+// DecisionLog keeps every decision we made in a game, so it can be archived once the game's over.
+type DecisionLog struct {
+	mu      sync.Mutex
+	records []DecisionRecord
+}
+
+// Add records the turn's decision.
+func (l *DecisionLog) Add(decision DecisionRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, decision)
+}
+
+// Records returns a copy of the decisions so far, in the order they were made.
+func (l *DecisionLog) Records() []DecisionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]DecisionRecord(nil), l.records...)
+}
+
+// DecisionArchive is a game's decisions as kept in the bucket.
+type DecisionArchive struct {
+	GameID    string           `json:"game_id"`
+	YouID     string           `json:"you_id"`
+	Engine    string           `json:"engine"`
+	Decisions []DecisionRecord `json:"decisions"`
+}
+
+func decisionArchiveObject(gameID string) string {
+	return fmt.Sprintf("decisions/%s.json", gameID)
+}
+
+// decisionsStage keeps the game's decisions in the bucket so the game can be exported with annotations later.
+func decisionsStage(ctx context.Context, job *EndOfGameJob) error {
+	session := job.Session
+	archive := DecisionArchive{
+		GameID:    session.ID,
+		YouID:     session.YouID,
+		Engine:    engineBuild.String(),
+		Decisions: session.decisions.Records(),
+	}
+	if len(archive.Decisions) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return fmt.Errorf("failed to encode decisions: %w", err)
+	}
+	return uploadToBucket(ctx, decisionArchiveObject(session.ID), "application/json", data)
+}
+
+// loadDecisionArchive reads a game's decisions back from the bucket.
+func loadDecisionArchive(ctx context.Context, gameID string) (DecisionArchive, error) {
+	var archive DecisionArchive
+	data, err := downloadFromBucket(ctx, decisionArchiveObject(gameID))
+	if err != nil {
+		return archive, err
+	}
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return archive, fmt.Errorf("failed to decode decisions: %w", err)
+	}
+	return archive, nil
+}
+
+// evalShare turns our value into how much of the eval bar is ours, 0 to 1.
+func evalShare(value float64) float64 {
+	if value > 1 {
+		value = 1
+	}
+	if value < -1 {
+		value = -1
+	}
+	return (value + 1) / 2
+}
+
+// annotatedPalette is the game's palette plus the darker shades the voronoi territory and the background use.
+func annotatedPalette(snakePalette *SnakePalette, first *Board) color.Palette {
+	colors := snakePalette.Colors()
+	colors = append(colors, color.RGBA{20, 20, 20, 255}, color.RGBA{50, 50, 50, 255})
+	for _, snake := range first.Snakes {
+		colors = append(colors, shade(snakePalette.Body(snake.ID), 0.3))
+	}
+	return colors
+}
+
+// renderAnnotatedBoard draws one turn with each snake's voronoi territory shaded in, our move as an arrow
+// off our head and the eval bar down the side.
+func renderAnnotatedBoard(board *Board, snakePalette *SnakePalette, youID string, decision *DecisionRecord, value float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, annotatedSize, annotatedSize))
+	background := color.RGBA{20, 20, 20, 255}
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	left := annotatedBarWidth + annotatedGap
+	top := annotatedHeader
+	size := annotatedSize - left - annotatedGap
+	if height := annotatedSize - top - annotatedGap; height < size {
+		size = height
+	}
+	cellSize := size / max(board.Width, board.Height)
+	cell := func(p Point, inset int) image.Rectangle {
+		x := left + p.X*cellSize
+		y := top + (board.Height-1-p.Y)*cellSize // Flip along Y axis
+		return image.Rect(x+inset, y+inset, x+cellSize-inset, y+cellSize-inset)
+	}
+	centre := func(p Point) image.Point {
+		r := cell(p, 0)
+		return image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+	}
+
+	// territory first so everything else sits on top of it
+	grid := color.RGBA{50, 50, 50, 255}
+	owners := GenerateVoronoi(*board)
+	for y := 0; y < board.Height; y++ {
+		for x := 0; x < board.Width; x++ {
+			c := grid
+			if owner := owners[y][x]; owner >= 0 && owner < len(board.Snakes) {
+				c = shade(snakePalette.Body(board.Snakes[owner].ID), 0.3)
+			}
+			draw.Draw(img, cell(Point{X: x, Y: y}, 1), &image.Uniform{c}, image.Point{}, draw.Src)
+		}
+	}
+
+	green := color.RGBA{0, 255, 0, 255}
+	for _, food := range board.Food {
+		draw.Draw(img, cell(food, cellSize/4), &image.Uniform{green}, image.Point{}, draw.Src)
+	}
+
+	var you *Snake
+	for i, snake := range board.Snakes {
+		if snake.ID == youID {
+			you = &board.Snakes[i]
+		}
+		body := snakePalette.Body(snake.ID)
+		for i := len(snake.Body) - 1; i >= 0; i-- {
+			c := shade(body, 1-0.5*float64(i)/float64(len(snake.Body)))
+			if i == 0 {
+				c = snakePalette.Head(snake.ID)
+			}
+			draw.Draw(img, cell(snake.Body[i], 2), &image.Uniform{c}, image.Point{}, draw.Src)
+		}
+	}
+
+	white := color.RGBA{255, 255, 255, 255}
+	if decision != nil && you != nil && len(you.Body) > 0 {
+		if move := directionFromName(decision.Move); move != Unset {
+			from, to := centre(you.Head), centre(moveHead(you.Head, move))
+			drawArrow(img, from, to, max(2, cellSize/8), white)
+		}
+	}
+
+	// the eval bar fills up from the bottom in our colour
+	bar := image.Rect(annotatedGap, top, annotatedBarWidth, top+cellSize*board.Height)
+	draw.Draw(img, bar, &image.Uniform{grid}, image.Point{}, draw.Src)
+	ours := color.RGBA{255, 255, 255, 255}
+	if you != nil {
+		ours = snakePalette.Body(you.ID)
+	}
+	filled := int(evalShare(value) * float64(bar.Dy()))
+	draw.Draw(img, image.Rect(bar.Min.X, bar.Max.Y-filled, bar.Max.X, bar.Max.Y), &image.Uniform{ours}, image.Point{}, draw.Src)
+	middle := (bar.Min.Y + bar.Max.Y) / 2
+	draw.Draw(img, image.Rect(bar.Min.X, middle, bar.Max.X, middle+1), &image.Uniform{white}, image.Point{}, draw.Src)
+
+	label := "turn ?"
+	if decision != nil {
+		label = fmt.Sprintf("turn %d  eval %+.2f", decision.Turn, value)
+		if decision.Forced {
+			label += "  forced"
+		}
+		if decision.SaferMove {
+			label += "  safer"
+		}
+	}
+	addScaledLabel(img, left, annotatedHeader-8, label, white)
+	return img
+}
+
+// drawArrow draws a thick line from one point towards another, stopping short so the tip sits in the cell
+// we're moving into, with a square on the end for the head.
+func drawArrow(img *image.RGBA, from, to image.Point, thickness int, c color.RGBA) {
+	tip := image.Pt(from.X+(to.X-from.X)*3/4, from.Y+(to.Y-from.Y)*3/4)
+	shaft := image.Rect(min(from.X, tip.X)-thickness/2, min(from.Y, tip.Y)-thickness/2, max(from.X, tip.X)+thickness/2+1, max(from.Y, tip.Y)+thickness/2+1)
+	draw.Draw(img, shaft, &image.Uniform{c}, image.Point{}, draw.Src)
+	head := image.Rect(tip.X-thickness, tip.Y-thickness, tip.X+thickness+1, tip.Y+thickness+1)
+	draw.Draw(img, head, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// directionFromName is the direction for a move as we send it to the engine.
+func directionFromName(name string) Direction {
+	switch name {
+	case "up":
+		return Up
+	case "down":
+		return Down
+	case "left":
+		return Left
+	case "right":
+		return Right
+	}
+	return Unset
+}
+
+// encodeAnnotatedGIF renders the game with the decisions we made drawn over it. Turns we didn't search,
+// or don't have a decision for, keep the eval from the last turn we did.
+func encodeAnnotatedGIF(frames []*Board, archive DecisionArchive, outcome GameOutcome) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, errNothingToDisplay
+	}
+	byTurn := make(map[int]*DecisionRecord, len(archive.Decisions))
+	for i := range archive.Decisions {
+		byTurn[archive.Decisions[i].Turn] = &archive.Decisions[i]
+	}
+
+	snakePalette := newSnakePalette(frames[0])
+	palette := annotatedPalette(snakePalette, frames[0])
+	images := make([]*image.Paletted, 0, len(frames)+1)
+	value := 0.0
+	for turn, board := range frames {
+		decision := byTurn[turn]
+		if decision != nil && decision.Visits > 0 {
+			value = decision.Value
+		}
+		images = append(images, palettize(renderAnnotatedBoard(board, snakePalette, archive.YouID, decision, value), palette, true))
+	}
+	return encodeFramesWithOutcome(images, gameDelays(len(frames)), outcome)
+}
+
+// handleExport renders an archived game with our annotations, for sharing instead of the engine's plain gif.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	gameID := r.URL.Query().Get("game_id")
+	if gameID == "" {
+		http.Error(w, "game_id is required", http.StatusBadRequest)
+		return
+	}
+	archive, err := loadDecisionArchive(r.Context(), gameID)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		http.Error(w, "no decisions archived for that game", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	frames, outcome, err := collectGameFrames(fmt.Sprintf("wss://engine.battlesnake.com/games/%s/events", gameID), archive.YouID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to collect game frames: %v", err), http.StatusBadGateway)
+		return
+	}
+	data, err := encodeAnnotatedGIF(frames, archive, outcome)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/gif")
+	w.Write(data)
+}
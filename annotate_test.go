@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func annotateTestArchive() DecisionArchive {
+	return DecisionArchive{
+		GameID: "game",
+		YouID:  "gs_us",
+		Decisions: []DecisionRecord{
+			{Turn: 0, Move: "up", Visits: 1000, Value: 0.25},
+			{Turn: 1, Move: "up", Forced: true},
+		},
+	}
+}
+
+func TestAnnotatedGIFGolden(t *testing.T) {
+	data, err := encodeAnnotatedGIF(rendererTestFrames(), annotateTestArchive(), Win)
+	require.NoError(t, err)
+	assertGolden(t, "annotated_1v1.gif", data)
+}
+
+func TestAnnotatedGIFFrames(t *testing.T) {
+	frames := rendererTestFrames()
+	data, err := encodeAnnotatedGIF(frames, annotateTestArchive(), Win)
+	require.NoError(t, err)
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(decoded.Image), len(frames))
+	assert.Equal(t, annotatedSize, decoded.Config.Width)
+	assert.Equal(t, annotatedSize, decoded.Config.Height)
+
+	_, err = encodeAnnotatedGIF(nil, annotateTestArchive(), Win)
+	assert.ErrorIs(t, err, errNothingToDisplay)
+}
+
+func TestAnnotatedBoard(t *testing.T) {
+	frames := rendererTestFrames()
+	snakePalette := newSnakePalette(frames[0])
+	board := frames[0]
+	cellSize := (annotatedSize - annotatedBarWidth - 2*annotatedGap) / board.Width
+	at := func(img *image.RGBA, p Point) color.RGBA {
+		x := annotatedBarWidth + annotatedGap + p.X*cellSize
+		y := annotatedHeader + (board.Height-1-p.Y)*cellSize
+		return img.RGBAAt(x+cellSize/2, y+cellSize/2)
+	}
+	barFill := func(img *image.RGBA) int {
+		filled := 0
+		for y := annotatedHeader; y < annotatedHeader+cellSize*board.Height; y++ {
+			if img.RGBAAt(annotatedGap+1, y) == snakePalette.Body("gs_us") {
+				filled++
+			}
+		}
+		return filled
+	}
+
+	decision := &DecisionRecord{Turn: 0, Move: "up", Visits: 1}
+	img := renderAnnotatedBoard(board, snakePalette, "gs_us", decision, 0)
+
+	// territory is shaded in the colour of the snake that gets there first
+	assert.Equal(t, shade(snakePalette.Body("gs_us"), 0.3), at(img, Point{X: 1, Y: 4}))
+	assert.Equal(t, shade(snakePalette.Body("gs_them"), 0.3), at(img, Point{X: 9, Y: 6}))
+
+	// the arrow leaves our head towards the move we made
+	white := color.RGBA{255, 255, 255, 255}
+	head := at(img, Point{X: 1, Y: 1})
+	above := image.Pt(annotatedBarWidth+annotatedGap+cellSize+cellSize/2, annotatedHeader+(board.Height-2)*cellSize+cellSize/4)
+	assert.Equal(t, white, img.RGBAAt(above.X, above.Y))
+	assert.Equal(t, white, head)
+
+	// an even game fills half the bar, winning fills it and losing empties it
+	testCases := []struct {
+		Value float64
+		Want  float64
+	}{
+		{0, 0.5},
+		{1, 1},
+		{-2, 0},
+		{0.5, 0.75},
+	}
+	for _, tc := range testCases {
+		assert.InDelta(t, tc.Want, evalShare(tc.Value), 1e-9)
+		img := renderAnnotatedBoard(board, snakePalette, "gs_us", decision, tc.Value)
+		assert.InDelta(t, tc.Want*float64(cellSize*board.Height), float64(barFill(img)), 2, "value %v", tc.Value)
+	}
+}
+
+func TestDecisionLog(t *testing.T) {
+	log := &DecisionLog{}
+	log.Add(DecisionRecord{Turn: 0, Move: "up"})
+	log.Add(DecisionRecord{Turn: 1, Move: "left"})
+
+	records := log.Records()
+	require.Len(t, records, 2)
+	assert.Equal(t, "left", records[1].Move)
+
+	// the copy handed out doesn't change the log
+	records[0].Move = "down"
+	assert.Equal(t, "up", log.Records()[0].Move)
+}
+
+func TestDirectionFromName(t *testing.T) {
+	for _, direction := range AllDirections {
+		assert.Equal(t, direction, directionFromName(determineMoveDirection(Point{X: 5, Y: 5}, moveInDirection(Point{X: 5, Y: 5}, direction))))
+	}
+	assert.Equal(t, Unset, directionFromName("sideways"))
+}
@@ -1,5 +1,7 @@
 package main
 
+import "strings"
+
 type Game struct {
 	ID      string  `json:"id"`
 	Ruleset Ruleset `json:"ruleset"`
@@ -15,9 +17,14 @@ type Ruleset struct {
 }
 
 type Settings struct {
-	FoodSpawnChance     int `json:"foodSpawnChance"`
-	MinimumFood         int `json:"minimumFood"`
-	HazardDamagePerTurn int `json:"hazardDamagePerTurn"`
+	FoodSpawnChance     int            `json:"foodSpawnChance"`
+	MinimumFood         int            `json:"minimumFood"`
+	HazardDamagePerTurn int            `json:"hazardDamagePerTurn"`
+	Royale              RoyaleSettings `json:"royale"`
+}
+
+type RoyaleSettings struct {
+	ShrinkEveryNTurns int `json:"shrinkEveryNTurns"`
 }
 
 type Board struct {
@@ -26,6 +33,22 @@ type Board struct {
 	Food    []Point `json:"food"`
 	Hazards []Point `json:"hazards"`
 	Snakes  []Snake `json:"snakes"`
+
+	// HazardDamage is the ruleset's hazardDamagePerTurn, carried on the board so the simulation can apply it
+	HazardDamage int `json:"-"`
+	// Wrapped is set in wrapped games, where leaving one edge of the board comes back on the opposite one
+	Wrapped bool `json:"-"`
+	// FoodSpawnChance and MinimumFood are the ruleset's, carried so the simulation can put food down
+	FoodSpawnChance int `json:"-"`
+	MinimumFood     int `json:"-"`
+	// ShrinkEvery is how often the royale hazards close in, zero outside royale
+	ShrinkEvery int `json:"-"`
+	// Turn is the game's turn, counted on through the simulation so the royale shrinks land when they should
+	Turn int `json:"-"`
+	// Map is the game's map, for the mechanics it adds to the ruleset
+	Map string `json:"-"`
+	// Deaths are the last heads of the snakes that have died, kept on replayed frames so the renders can mark them
+	Deaths []Point `json:"-"`
 }
 
 type Point struct {
@@ -57,3 +80,32 @@ type BattleSnakeGame struct {
 	Board Board `json:"board"`
 	You   Snake `json:"you"`
 }
+
+// withRules carries the parts of the game's rules the simulation needs onto the board.
+func (b Board) withRules(game Game) Board {
+	b.HazardDamage = game.Ruleset.Settings.HazardDamagePerTurn
+	b.Wrapped = isWrappedGame(game)
+	b.FoodSpawnChance = game.Ruleset.Settings.FoodSpawnChance
+	b.MinimumFood = game.Ruleset.Settings.MinimumFood
+	// the royale settings come with every game, but only royale shrinks
+	b.ShrinkEvery = 0
+	if game.Ruleset.Name == "royale" {
+		b.ShrinkEvery = game.Ruleset.Settings.Royale.ShrinkEveryNTurns
+	}
+	b.Map = game.Map
+	if rules := rulesForMap(b.Map); rules.Setup != nil {
+		rules.Setup(&b)
+	}
+	return b
+}
+
+// atTurn puts the game's turn on the board.
+func (b Board) atTurn(turn int) Board {
+	b.Turn = turn
+	return b
+}
+
+// isWrappedGame is whether the board wraps around, going by the ruleset or the map.
+func isWrappedGame(game Game) bool {
+	return strings.Contains(game.Ruleset.Name, "wrapped") || strings.Contains(game.Map, "wrapped")
+}
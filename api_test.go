@@ -123,3 +123,36 @@ func TestUnmarshalBattleSnakeGame(t *testing.T) {
 		t.Errorf("Expected snake ID 'snake-508e96ac-94ad-11ea-bb37', got '%s'", game.You.ID)
 	}
 }
+
+func TestBoardWithRules(t *testing.T) {
+	testCases := []struct {
+		Game    Game
+		Wrapped bool
+	}{
+		{Game{Ruleset: Ruleset{Name: "standard"}, Map: "standard"}, false},
+		{Game{Ruleset: Ruleset{Name: "wrapped"}, Map: "standard"}, true},
+		{Game{Ruleset: Ruleset{Name: "wrapped_constrictor"}}, true},
+		{Game{Ruleset: Ruleset{Name: "standard"}, Map: "wrapped_islands"}, true},
+	}
+	for _, tc := range testCases {
+		tc.Game.Ruleset.Settings = Settings{HazardDamagePerTurn: 14, FoodSpawnChance: 15, MinimumFood: 1}
+		board := Board{Width: 11, Height: 11}.withRules(tc.Game)
+		if board.Wrapped != tc.Wrapped {
+			t.Errorf("%+v: expected wrapped %v, got %v", tc.Game, tc.Wrapped, board.Wrapped)
+		}
+		if board.HazardDamage != 14 {
+			t.Errorf("%+v: expected hazard damage 14, got %d", tc.Game, board.HazardDamage)
+		}
+		if board.FoodSpawnChance != 15 || board.MinimumFood != 1 {
+			t.Errorf("%+v: expected food settings 15 and 1, got %d and %d", tc.Game, board.FoodSpawnChance, board.MinimumFood)
+		}
+		if board.ShrinkEvery != 0 {
+			t.Errorf("%+v: expected no shrinking outside royale, got %d", tc.Game, board.ShrinkEvery)
+		}
+	}
+
+	royale := Game{Ruleset: Ruleset{Name: "royale", Settings: Settings{Royale: RoyaleSettings{ShrinkEveryNTurns: 25}}}}
+	if board := (Board{}).withRules(royale); board.ShrinkEvery != 25 {
+		t.Errorf("expected royale to shrink every 25 turns, got %d", board.ShrinkEvery)
+	}
+}
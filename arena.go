@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// arenaSnake is a snake the arena can invite, either ours or a public community bot.
+type arenaSnake struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// arenaConfig holds the settings for an arena run.
+type arenaConfig struct {
+	snakesFile string
+	eloFile    string
+	cli        string
+	rounds     int
+	size       int
+	timeout    int
+	gameLimit  time.Duration
+}
+
+// arenaResult is the final line the battlesnake cli writes to its output file.
+type arenaResult struct {
+	WinnerID   string `json:"winnerId"`
+	WinnerName string `json:"winnerName"`
+	IsDraw     bool   `json:"isDraw"`
+}
+
+// runArena plays round robins between the configured snakes with the official cli and merges the results into the elo store.
+// Usage: main arena -snakes snakes.json [-elo elo.json] [-rounds 1] [-cli battlesnake]
+func runArena(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("arena", flag.ContinueOnError)
+	flags.SetOutput(out)
+	cfg := arenaConfig{}
+	flags.StringVar(&cfg.snakesFile, "snakes", "snakes.json", `json list of {"name", "url"} to play, ours included`)
+	flags.StringVar(&cfg.eloFile, "elo", "elo.json", "elo store to merge results into")
+	flags.StringVar(&cfg.cli, "cli", "battlesnake", "path to the official battlesnake cli")
+	flags.IntVar(&cfg.rounds, "rounds", 1, "times each pair plays")
+	flags.IntVar(&cfg.size, "size", 11, "board width and height")
+	flags.IntVar(&cfg.timeout, "timeout", 500, "move timeout in ms")
+	flags.DurationVar(&cfg.gameLimit, "game-limit", 10*time.Minute, "give up on a game that runs longer than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(cfg.snakesFile)
+	if err != nil {
+		return err
+	}
+	var snakes []arenaSnake
+	if err := json.Unmarshal(data, &snakes); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", cfg.snakesFile, err)
+	}
+	if len(snakes) < 2 {
+		return errors.New("need at least two snakes for an arena")
+	}
+
+	store, err := loadEloStore(cfg.eloFile)
+	if err != nil {
+		return err
+	}
+
+	for i, pair := range roundRobin(snakes, cfg.rounds) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.gameLimit)
+		result, err := playArenaGame(ctx, cfg, pair)
+		cancel()
+		if err != nil {
+			// community bots go down, don't lose the rest of the schedule over it
+			fmt.Fprintf(out, "game %d %s vs %s failed: %v\n", i, pair[0].Name, pair[1].Name, err)
+			continue
+		}
+
+		scoreA := 0.5
+		switch {
+		case result.IsDraw:
+		case result.WinnerName == pair[0].Name:
+			scoreA = 1
+		case result.WinnerName == pair[1].Name:
+			scoreA = 0
+		default:
+			fmt.Fprintf(out, "game %d %s vs %s has unknown winner %q\n", i, pair[0].Name, pair[1].Name, result.WinnerName)
+			continue
+		}
+		store.Record(pair[0].Name, pair[1].Name, scoreA)
+		fmt.Fprintf(out, "game %d %s vs %s: %s\n", i, pair[0].Name, pair[1].Name, describeArenaResult(result))
+
+		// save as we go so a long schedule that dies part way still counts
+		if err := store.Save(cfg.eloFile); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range store.Leaderboard() {
+		fmt.Fprintf(out, "%-30s %7.1f %4d games\n", entry.Name, entry.Rating, entry.Games)
+	}
+	return nil
+}
+
+// roundRobin pairs every snake with every other snake, alternating who is listed first each round.
+func roundRobin(snakes []arenaSnake, rounds int) [][2]arenaSnake {
+	var pairs [][2]arenaSnake
+	for round := 0; round < rounds; round++ {
+		for i := 0; i < len(snakes); i++ {
+			for j := i + 1; j < len(snakes); j++ {
+				if round%2 == 0 {
+					pairs = append(pairs, [2]arenaSnake{snakes[i], snakes[j]})
+				} else {
+					pairs = append(pairs, [2]arenaSnake{snakes[j], snakes[i]})
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// arenaCommand builds the arguments for the battlesnake cli.
+func arenaCommand(cfg arenaConfig, pair [2]arenaSnake, output string) []string {
+	args := []string{
+		"play",
+		"--width", strconv.Itoa(cfg.size),
+		"--height", strconv.Itoa(cfg.size),
+		"--timeout", strconv.Itoa(cfg.timeout),
+		"--output", output,
+	}
+	for _, snake := range pair {
+		args = append(args, "--name", snake.Name, "--url", snake.URL)
+	}
+	return args
+}
+
+func playArenaGame(ctx context.Context, cfg arenaConfig, pair [2]arenaSnake) (arenaResult, error) {
+	dir, err := os.MkdirTemp("", "arena")
+	if err != nil {
+		return arenaResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "game.jsonl")
+
+	cmd := exec.CommandContext(ctx, cfg.cli, arenaCommand(cfg, pair, output)...)
+	if combined, err := cmd.CombinedOutput(); err != nil {
+		return arenaResult{}, fmt.Errorf("%w: %s", err, combined)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		return arenaResult{}, err
+	}
+	defer f.Close()
+	return parseArenaResult(f)
+}
+
+// parseArenaResult reads the cli's output file, where every turn is a line and the result is the last one.
+func parseArenaResult(r io.Reader) (arenaResult, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var last []byte
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return arenaResult{}, err
+	}
+	if last == nil {
+		return arenaResult{}, errors.New("empty game output")
+	}
+
+	var result arenaResult
+	if err := json.Unmarshal(last, &result); err != nil {
+		return arenaResult{}, fmt.Errorf("failed to parse result: %w", err)
+	}
+	if !result.IsDraw && result.WinnerName == "" {
+		return arenaResult{}, errors.New("game output has no result")
+	}
+	return result, nil
+}
+
+func describeArenaResult(result arenaResult) string {
+	if result.IsDraw {
+		return "draw"
+	}
+	return result.WinnerName + " won"
+}
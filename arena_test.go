@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundRobin(t *testing.T) {
+	snakes := []arenaSnake{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	pairs := roundRobin(snakes, 2)
+
+	names := make([]string, len(pairs))
+	for i, pair := range pairs {
+		names[i] = pair[0].Name + pair[1].Name
+	}
+	assert.Equal(t, []string{"ab", "ac", "bc", "ba", "ca", "cb"}, names)
+}
+
+func TestArenaCommand(t *testing.T) {
+	cfg := arenaConfig{size: 11, timeout: 500}
+	pair := [2]arenaSnake{{Name: "gregory", URL: "http://localhost:8080"}, {Name: "other", URL: "https://example.com"}}
+	assert.Equal(t,
+		"play --width 11 --height 11 --timeout 500 --output out.jsonl --name gregory --url http://localhost:8080 --name other --url https://example.com",
+		strings.Join(arenaCommand(cfg, pair, "out.jsonl"), " "))
+}
+
+func TestParseArenaResult(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Output      string
+		Expected    arenaResult
+		Err         bool
+	}{
+		{
+			Description: "winner on the last line",
+			Output:      `{"turn":0}` + "\n" + `{"turn":1}` + "\n" + `{"winnerId":"x","winnerName":"gregory","isDraw":false}` + "\n",
+			Expected:    arenaResult{WinnerID: "x", WinnerName: "gregory"},
+		},
+		{
+			Description: "draw",
+			Output:      `{"turn":0}` + "\n" + `{"winnerId":"","winnerName":"","isDraw":true}`,
+			Expected:    arenaResult{IsDraw: true},
+		},
+		{
+			Description: "game cut short",
+			Output:      `{"turn":0}` + "\n" + `{"turn":1}` + "\n",
+			Err:         true,
+		},
+		{
+			Description: "nothing written",
+			Output:      "",
+			Err:         true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			result, err := parseArenaResult(strings.NewReader(tc.Output))
+			if tc.Err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, result)
+		})
+	}
+}
+
+func TestEloStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "elo.json")
+	store, err := loadEloStore(path)
+	require.NoError(t, err)
+
+	store.Record("gregory", "other", 1)
+	assert.InDelta(t, 1516, store.Rating("gregory"), 0.001)
+	assert.InDelta(t, 1484, store.Rating("other"), 0.001)
+
+	// beating a weaker snake is worth less
+	store.Record("gregory", "other", 1)
+	assert.Less(t, store.Rating("gregory")-1516, 16.0)
+
+	store.Record("gregory", "third", 0.5)
+	require.NoError(t, store.Save(path))
+
+	loaded, err := loadEloStore(path)
+	require.NoError(t, err)
+	assert.Equal(t, store.Ratings, loaded.Ratings)
+	leaderboard := loaded.Leaderboard()
+	assert.Equal(t, "gregory", leaderboard[0].Name)
+	assert.Equal(t, 3, leaderboard[0].Games)
+	assert.Equal(t, "other", leaderboard[len(leaderboard)-1].Name)
+}
@@ -0,0 +1,97 @@
+package main
+
+import "math/bits"
+
+// Bitboard is a set of cells, a bit each, numbered row by row from the bottom left. Two words cover boards
+// of up to 128 cells, which is every standard size up to 11x11. Bigger boards go on scanning the slices.
+type Bitboard [2]uint64
+
+// bitboardCells is the most cells a Bitboard holds.
+const bitboardCells = 128
+
+// bitboardFits says whether the board's cells all fit in a Bitboard.
+func bitboardFits(board *Board) bool {
+	return board.Width*board.Height <= bitboardCells
+}
+
+// cellIndex is the point's bit on the board. The point has to be on the board.
+func cellIndex(board *Board, p Point) int {
+	return p.Y*board.Width + p.X
+}
+
+// Set adds the cell.
+func (b *Bitboard) Set(cell int) {
+	b[cell>>6] |= 1 << (cell & 63)
+}
+
+// Has says whether the cell is in the set.
+func (b Bitboard) Has(cell int) bool {
+	return b[cell>>6]&(1<<(cell&63)) != 0
+}
+
+// Or is every cell in either set.
+func (b Bitboard) Or(other Bitboard) Bitboard {
+	return Bitboard{b[0] | other[0], b[1] | other[1]}
+}
+
+// AndNot is the cells in b that aren't in other.
+func (b Bitboard) AndNot(other Bitboard) Bitboard {
+	return Bitboard{b[0] &^ other[0], b[1] &^ other[1]}
+}
+
+// Count is how many cells are in the set.
+func (b Bitboard) Count() int {
+	return bits.OnesCount64(b[0]) + bits.OnesCount64(b[1])
+}
+
+// pointsBitboard is the set of the points that are on the board.
+func pointsBitboard(board *Board, points []Point) Bitboard {
+	var b Bitboard
+	for _, p := range points {
+		if isPointInsideBoard(board, p) {
+			b.Set(cellIndex(board, p))
+		}
+	}
+	return b
+}
+
+// BoardBits is the board's cells as bitboards: what the living snakes cover, where their heads are, the
+// food and the hazards. Stacked hazards count once.
+type BoardBits struct {
+	Bodies  Bitboard
+	Heads   Bitboard
+	Food    Bitboard
+	Hazards Bitboard
+}
+
+// newBoardBits works out the board's bitboards. The board has to fit.
+func newBoardBits(board *Board) BoardBits {
+	bb := BoardBits{
+		Food:    pointsBitboard(board, board.Food),
+		Hazards: pointsBitboard(board, board.Hazards),
+	}
+	for _, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		bb.Bodies = bb.Bodies.Or(pointsBitboard(board, snake.Body))
+		if isPointInsideBoard(board, snake.Head) {
+			bb.Heads.Set(cellIndex(board, snake.Head))
+		}
+	}
+	return bb
+}
+
+// blockedBitboard is every cell a body is in the way of the viewer's next move on, the same cells hitsBody
+// says it'd run into.
+func blockedBitboard(board *Board, viewerIndex int) Bitboard {
+	var blocked Bitboard
+	for i := range board.Snakes {
+		body := effectiveBody(board, viewerIndex, i)
+		if i < viewerIndex && len(body) > 0 {
+			body = body[1:]
+		}
+		blocked = blocked.Or(pointsBitboard(board, body))
+	}
+	return blocked
+}
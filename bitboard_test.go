@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitboardSets(t *testing.T) {
+	var b Bitboard
+	for _, cell := range []int{0, 63, 64, 120} {
+		b.Set(cell)
+	}
+	assert.Equal(t, 4, b.Count())
+	assert.True(t, b.Has(63))
+	assert.True(t, b.Has(64))
+	assert.False(t, b.Has(65))
+
+	var other Bitboard
+	other.Set(64)
+	other.Set(7)
+	assert.Equal(t, 5, b.Or(other).Count())
+	assert.Equal(t, 3, b.AndNot(other).Count())
+	assert.False(t, b.AndNot(other).Has(64))
+}
+
+func TestBoardBits(t *testing.T) {
+	board := evalTestBoard()
+	board.Hazards = []Point{{X: 0, Y: 10}, {X: 0, Y: 10}, {X: 20, Y: 20}}
+	board.Snakes[2].Health = 0
+	assert.True(t, bitboardFits(&board))
+
+	bb := newBoardBits(&board)
+	assert.Equal(t, 7, bb.Bodies.Count(), "dead snakes cover nothing")
+	assert.Equal(t, 2, bb.Heads.Count())
+	assert.True(t, bb.Heads.Has(cellIndex(&board, Point{X: 8, Y: 8})))
+	assert.Equal(t, 2, bb.Food.Count())
+	assert.Equal(t, 1, bb.Hazards.Count(), "stacks count once and off the board doesn't count")
+
+	big := Board{Width: 19, Height: 19}
+	assert.False(t, bitboardFits(&big))
+}
+
+func TestBlockedBitboardMatchesHitsBody(t *testing.T) {
+	ate := evalTestBoard()
+	ate.Snakes[1].Body = append(ate.Snakes[1].Body, Point{X: 9, Y: 9})
+	wrapped := evalTestBoard()
+	wrapped.Wrapped = true
+	for name, board := range map[string]Board{"open": evalTestBoard(), "just ate": ate, "wrapped": wrapped, "corner": cornerEndgame()} {
+		for viewer := range board.Snakes {
+			blocked := blockedBitboard(&board, viewer)
+			for y := 0; y < board.Height; y++ {
+				for x := 0; x < board.Width; x++ {
+					p := Point{X: x, Y: y}
+					assert.Equal(t, hitsBody(&board, viewer, p), blocked.Has(cellIndex(&board, p)), "%s viewer %d at %v", name, viewer, p)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkGenerateSafeMoves(b *testing.B) {
+	board := evalTestBoard()
+	for i := 0; i < b.N; i++ {
+		for index := range board.Snakes {
+			generateSafeMoves(board, index)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// blunderSearchVisits and blunderSearchTime are how much the search gets on each turn going back over a
+	// game, whichever runs out first. Well past what a move gets, but a whole game still has to fit in the
+	// stage's timeout.
+	blunderSearchVisits = 100000
+	blunderSearchTime   = 500 * time.Millisecond
+	// blunderMargin is how far our move's value has to fall short of the best one's for it to be a blunder.
+	// Values run from -2 for a loss to 2 for a win.
+	blunderMargin = 0.5
+	// blunderMinVisits is the fewest visits the deeper search has to give the move it likes best before
+	// we believe its value. The move we made can have far fewer: the worse it is the less it gets looked at.
+	blunderMinVisits = 100
+	// blundersReported is the most turns that make it into a report, worst first.
+	blundersReported = 5
+)
+
+// blunderReportsEnabled turns on going back over every game with a deeper search. It's a lot of cpu, so
+// it's off unless BLUNDER_REPORTS is true.
+var blunderReportsEnabled = os.Getenv("BLUNDER_REPORTS") == "true"
+
+// Blunder is a turn where the deeper search found a move much better than the one we made.
+type Blunder struct {
+	Turn      int
+	Move      string
+	Value     float64 // our move's value to the deeper search
+	Best      string
+	BestValue float64
+	Board     Board
+}
+
+// Cost is how much the blunder gave away.
+func (b Blunder) Cost() float64 {
+	return b.BestValue - b.Value
+}
+
+// findBlunders searches every turn we searched in the game again, deeper, and returns the turns where our
+// move fell well short of the best one found. It also returns how many turns it got through before the
+// context ended.
+func findBlunders(ctx context.Context, entries []GameLogEntry, config SearchConfig, visits int, perTurn time.Duration) ([]Blunder, int) {
+	var blunders []Blunder
+	analysed := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		analysed++
+		// with one safe move or a won race there was nothing to choose
+		if entry.Decision.Forced || entry.Decision.TailChase || len(entry.Board.Snakes) == 0 {
+			continue
+		}
+		turnCtx, cancel := context.WithTimeout(ctx, perTurn)
+		root := MCTS(turnCtx, "blunder", entry.SearchBoard(), visits, 1, nil, WithSearchConfig(config))
+		cancel()
+
+		blunder := Blunder{Turn: entry.Turn, Move: entry.Move, Board: entry.Board}
+		found := false
+		for _, child := range root.Children() {
+			childVisits := atomic.LoadInt64(&child.Visits)
+			if childVisits == 0 {
+				continue
+			}
+			move, value := moveForChild(root, child), child.Score/float64(childVisits)
+			if move == entry.Move {
+				blunder.Value, found = value, true
+			}
+			if childVisits >= blunderMinVisits && (blunder.Best == "" || value > blunder.BestValue) {
+				blunder.Best, blunder.BestValue = move, value
+			}
+		}
+		// a move that wasn't even tried has no value to compare
+		if !found || blunder.Best == "" || blunder.Best == entry.Move {
+			continue
+		}
+		if blunder.Cost() >= blunderMargin {
+			blunders = append(blunders, blunder)
+		}
+	}
+	return blunders, analysed
+}
+
+// blunderReport is the discord message and an embed per blunder, with the board it was made on. Only the
+// worst few make it in.
+func blunderReport(gameID string, blunders []Blunder, analysed, turns int) (string, []Embed) {
+	sort.SliceStable(blunders, func(i, j int) bool { return blunders[i].Cost() > blunders[j].Cost() })
+	message := fmt.Sprintf("🔎 %d turns worth another look in [game](<https://play.battlesnake.com/game/%s>)", len(blunders), gameID)
+	if analysed < turns {
+		message += fmt.Sprintf(" (only got through %d of %d turns)", analysed, turns)
+	}
+	if len(blunders) > blundersReported {
+		blunders = blunders[:blundersReported]
+	}
+	embeds := make([]Embed, 0, len(blunders))
+	for _, blunder := range blunders {
+		board := visualizeBoard(blunder.Board, WithMove(directionFromString(blunder.Move), 0))
+		embeds = append(embeds, Embed{
+			Title:       fmt.Sprintf("turn %d: %s (%.2f), %s was %.2f", blunder.Turn, blunder.Move, blunder.Value, blunder.Best, blunder.BestValue),
+			Description: "```\n" + strings.TrimRight(board, "\n") + "\n```",
+		})
+	}
+	return message, embeds
+}
+
+// blunderStage goes back over the game with a deeper search and posts any blunders to discord.
+func blunderStage(ctx context.Context, job *EndOfGameJob) error {
+	if !blunderReportsEnabled {
+		return nil
+	}
+	session := job.Session
+	log, ok := gameLogs.Get(session.ID)
+	if !ok {
+		return nil
+	}
+	var entries []GameLogEntry
+	for _, entry := range log.Entries {
+		if entry.YouID == session.YouID {
+			entries = append(entries, entry)
+		}
+	}
+	blunders, analysed := findBlunders(ctx, entries, session.Config, blunderSearchVisits, blunderSearchTime)
+	session.Logger.Info("blunder analysis", "blunders", len(blunders), "analysed", analysed, "turns", len(entries))
+	if len(blunders) == 0 {
+		return nil
+	}
+	message, embeds := blunderReport(session.ID, blunders, analysed, len(entries))
+	discordQueue.Send(webhookURL.Get(), message, embeds)
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blunderTestBoard has us in the bottom left with left walking into the corner, which our own body has
+// already closed off.
+func blunderTestBoard() Board {
+	return Board{
+		Width:  7,
+		Height: 7,
+		Snakes: []Snake{
+			{ID: "us", Health: 90, Head: Point{1, 0}, Body: []Point{{1, 0}, {1, 1}, {0, 1}, {0, 2}, {0, 3}}},
+			{ID: "them", Health: 90, Head: Point{5, 5}, Body: []Point{{5, 5}, {5, 6}, {6, 6}}},
+		},
+	}
+}
+
+func TestFindBlunders(t *testing.T) {
+	board := blunderTestBoard()
+	entries := []GameLogEntry{
+		{YouID: "us", Turn: 1, Board: board, Move: "right"},
+		{YouID: "us", Turn: 2, Board: board, Move: "left"},
+		// forced moves had nothing to choose between, whatever they were
+		{YouID: "us", Turn: 3, Board: board, Move: "left", Decision: DecisionRecord{Forced: true}},
+	}
+	blunders, analysed := findBlunders(context.Background(), entries, defaultSearchConfig, 5000, 2*time.Second)
+	assert.Equal(t, 3, analysed)
+	require.Len(t, blunders, 1)
+	assert.Equal(t, 2, blunders[0].Turn)
+	assert.Equal(t, "left", blunders[0].Move)
+	assert.NotEqual(t, "left", blunders[0].Best)
+	assert.GreaterOrEqual(t, blunders[0].Cost(), blunderMargin)
+
+	message, embeds := blunderReport("g", blunders, 2, 3)
+	assert.Contains(t, message, "only got through 2 of 3 turns")
+	require.Len(t, embeds, 1)
+	assert.Contains(t, embeds[0].Title, "turn 2: left")
+	assert.Contains(t, embeds[0].Description, "```")
+}
+
+func TestFindBlundersPlaysByTheGamesRules(t *testing.T) {
+	// the corner isn't a dead end when the board wraps, and the log read back from the bucket has to know that
+	data, err := json.Marshal(GameLog{GameID: "g", Entries: []GameLogEntry{
+		{YouID: "us", Turn: 2, Board: blunderTestBoard(), Ruleset: Ruleset{Name: "wrapped"}, Move: "left"},
+	}})
+	require.NoError(t, err)
+	var log GameLog
+	require.NoError(t, json.Unmarshal(data, &log))
+
+	blunders, analysed := findBlunders(context.Background(), log.Entries, defaultSearchConfig, 5000, 2*time.Second)
+	assert.Equal(t, 1, analysed)
+	assert.Empty(t, blunders)
+}
+
+func TestFindBlundersStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	entries := []GameLogEntry{{YouID: "us", Turn: 1, Board: blunderTestBoard(), Move: "left"}}
+	blunders, analysed := findBlunders(ctx, entries, defaultSearchConfig, 5000, time.Second)
+	assert.Empty(t, blunders)
+	assert.Zero(t, analysed)
+}
+
+func TestBlunderReportKeepsTheWorst(t *testing.T) {
+	var blunders []Blunder
+	for turn := 0; turn < blundersReported+2; turn++ {
+		blunders = append(blunders, Blunder{Turn: turn, Move: "up", Best: "down", Value: -float64(turn) / 10, BestValue: 1, Board: blunderTestBoard()})
+	}
+	message, embeds := blunderReport("g", blunders, 10, 10)
+	assert.NotContains(t, message, "only got through")
+	require.Len(t, embeds, blundersReported)
+	assert.Contains(t, embeds[0].Title, "turn 6:", "the costliest first")
+}
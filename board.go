@@ -20,7 +20,7 @@ func applyMove(board *Board, snakeIndex int, direction Direction) {
 	initialHead := board.Snakes[snakeIndex].Head
 
 	// Calculate the new head position
-	newHead := moveHead(initialHead, direction)
+	newHead := moveOnBoard(board, initialHead, direction)
 
 	// Move the snake's head and body
 	snake := &board.Snakes[snakeIndex]
@@ -46,8 +46,25 @@ func applyMove(board *Board, snakeIndex int, direction Direction) {
 		}
 	}
 
+	// standing in sauce costs the ruleset's damage for every hazard stacked on the cell, unless we ate there
+	if !ateFood && board.HazardDamage > 0 {
+		for _, hazard := range board.Hazards {
+			if hazard == snake.Head {
+				snake.Health -= board.HazardDamage
+			}
+		}
+		if snake.Health <= 0 {
+			markDeadSnake(board, snakeIndex)
+			return
+		}
+	}
+
 	// remove the last segment for the move
+	tail := snake.Body[len(snake.Body)-1]
 	snake.Body = snake.Body[:len(snake.Body)-1]
+	if len(snake.Body) > 0 && snake.Body[len(snake.Body)-1] != tail {
+		board.vacate(tail, len(snake.Body))
+	}
 	// If the snake ate food, reset health and add an additional segment on the tail
 	if ateFood {
 		snake.Health = 100
@@ -93,20 +110,15 @@ func resolveCollisions(board *Board, snakeIndex int, newHead Point) {
 
 	// After head collisions are resolved, check if the new head overlaps any snake's body
 	for i := range board.Snakes {
-		if board.Snakes[i].Health > 0 { // Skip dead snakes
-			// Adjust body length if the other snake has not yet moved
-			body := board.Snakes[i].Body
-			if i > snakeIndex {
-				if len(body) > 0 {
-					body = body[:len(body)-1] // Remove last segment (tail)
-				}
-			}
-			for _, segment := range body[1:] { // Exclude the head
-				if newHead == segment {
-					// If the new head overlaps any body part, kill the snake
-					deadSnakes[snakeIndex] = true
-					break
-				}
+		body := effectiveBodyAfterMove(board, snakeIndex, i)
+		if len(body) < 2 {
+			continue
+		}
+		for _, segment := range body[1:] { // Exclude the head
+			if newHead == segment {
+				// If the new head overlaps any body part, kill the snake
+				deadSnakes[snakeIndex] = true
+				break
 			}
 		}
 	}
@@ -191,13 +203,11 @@ func moveHead(head Point, direction Direction) Point {
 }
 
 // Helper function to get all possible moves a snake can make
-func getPossibleMoves(snake Snake) []Point {
+func getPossibleMoves(board *Board, snake Snake) []Point {
 	head := snake.Body[0]
-	moves := []Point{
-		{X: head.X, Y: head.Y + 1}, // Move up
-		{X: head.X, Y: head.Y - 1}, // Move down
-		{X: head.X + 1, Y: head.Y}, // Move right
-		{X: head.X - 1, Y: head.Y}, // Move left
+	moves := make([]Point, 0, len(AllDirections))
+	for _, direction := range AllDirections {
+		moves = append(moves, moveOnBoard(board, head, direction))
 	}
 	return moves
 }
@@ -218,7 +228,7 @@ func markDangerZones(board *Board, snakeIndex int) [][]int {
 		if isSnakeDead(snake) {
 			continue
 		}
-		possibleMoves := getPossibleMoves(snake)
+		possibleMoves := getPossibleMoves(board, snake)
 		for _, move := range possibleMoves {
 			if isPointInsideBoard(board, move) && !isOccupied(board, move, snakeIndex) {
 				// Mark the danger zone with the length of the threatening snake
@@ -247,8 +257,15 @@ func generateSafeMoves(board Board, snakeIndex int) []Direction {
 	possibleDirections := []Direction{Up, Down, Left, Right}
 	safeMoves := []Direction{}
 
+	// on boards that fit, the bodies are gathered into a bitboard once rather than scanned for every move
+	fits := bitboardFits(&board)
+	var blocked Bitboard
+	if fits {
+		blocked = blockedBitboard(&board, snakeIndex)
+	}
+
 	for _, direction := range possibleDirections {
-		nextMove := moveInDirection(head, direction)
+		nextMove := moveOnBoard(&board, head, direction)
 
 		// Check if the move is within the board boundaries
 		if !isPointInsideBoard(&board, nextMove) {
@@ -260,6 +277,11 @@ func generateSafeMoves(board Board, snakeIndex int) []Direction {
 			continue // Move is into the snake's own neck
 		}
 
+		// Check if the move runs into a body that will still be there
+		if fits && blocked.Has(cellIndex(&board, nextMove)) || !fits && hitsBody(&board, snakeIndex, nextMove) {
+			continue
+		}
+
 		// Otherwise, it's a safe move
 		safeMoves = append(safeMoves, direction)
 	}
@@ -267,42 +289,31 @@ func generateSafeMoves(board Board, snakeIndex int) []Direction {
 	return safeMoves
 }
 
-// Check if the point is within the board boundaries
+// moveOnBoard is moveHead on the board's topology: on a wrapped board a head leaving one edge comes back
+// on the opposite one, anywhere else it's left off the board for isPointInsideBoard to catch.
+func moveOnBoard(board *Board, head Point, direction Direction) Point {
+	return wrapPoint(board, moveHead(head, direction))
+}
+
+// wrapPoint brings a point back onto a wrapped board. Boards that don't wrap leave it alone.
+func wrapPoint(board *Board, point Point) Point {
+	if !board.Wrapped || board.Width <= 0 || board.Height <= 0 {
+		return point
+	}
+	point.X = (point.X%board.Width + board.Width) % board.Width
+	point.Y = (point.Y%board.Height + board.Height) % board.Height
+	return point
+}
+
+// Check if the point is within the board boundaries. Points that went through moveOnBoard are always
+// inside a wrapped board.
 func isPointInsideBoard(board *Board, point Point) bool {
 	return point.X >= 0 && point.X < board.Width && point.Y >= 0 && point.Y < board.Height
 }
 
 // Check if a point is safe for a given snake to move its head to
 func isOccupied(board *Board, point Point, snakeIndex int) bool {
-	for i, snake := range board.Snakes {
-		snakeLength := len(snake.Body)
-		if snakeLength == 0 {
-			continue
-		}
-		body := snake.Body
-		if i == snakeIndex {
-			// Don't count our own tail since it will disappear if we are the ones moving
-			if snakeLength > 1 {
-				body = body[:snakeLength-1]
-			} else {
-				body = []Point{}
-			}
-		} else if i > snakeIndex {
-			// The snake hasn't moved yet; we need to remove its last segment (tail)
-			if snakeLength > 1 {
-				body = body[:snakeLength-1]
-			} else {
-				body = []Point{}
-			}
-		}
-		// Now check if point is in the adjusted body
-		for _, bodyPart := range body {
-			if bodyPart.X == point.X && bodyPart.Y == point.Y {
-				return true
-			}
-		}
-	}
-	return false
+	return blocksPoint(board, snakeIndex, point)
 }
 
 // Helper function to map a direction to a point
@@ -324,11 +335,19 @@ func moveInDirection(head Point, direction Direction) Point {
 // copyBoard creates and returns a deep copy of the provided board.
 func copyBoard(board Board) Board {
 	newBoard := Board{
-		Height:  board.Height,
-		Width:   board.Width,
-		Food:    append([]Point(nil), board.Food...),
-		Hazards: append([]Point(nil), board.Hazards...),
-		Snakes:  make([]Snake, len(board.Snakes)),
+		Height:       board.Height,
+		Width:        board.Width,
+		Food:         append([]Point(nil), board.Food...),
+		Hazards:      append([]Point(nil), board.Hazards...),
+		Snakes:       make([]Snake, len(board.Snakes)),
+		HazardDamage: board.HazardDamage,
+		Wrapped:      board.Wrapped,
+
+		FoodSpawnChance: board.FoodSpawnChance,
+		MinimumFood:     board.MinimumFood,
+		ShrinkEvery:     board.ShrinkEvery,
+		Turn:            board.Turn,
+		Map:             board.Map,
 	}
 
 	// Deep copy each snake
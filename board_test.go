@@ -43,6 +43,37 @@ func TestGenerateSafeMoves(t *testing.T) {
 				Right, // Can move right
 			},
 		},
+		{
+			Description: "One snake in the bottom-left corner of a wrapped board can leave by any edge",
+			Board: Board{
+				Height:  5,
+				Width:   5,
+				Wrapped: true,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}}},
+				},
+			},
+			SnakeIndex: 0,
+			ExpectedMoves: []Direction{
+				Up, Down, Left, Right,
+			},
+		},
+		{
+			Description: "A body across the edge of a wrapped board still blocks",
+			Board: Board{
+				Height:  5,
+				Width:   5,
+				Wrapped: true,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 0, Y: 2}, Body: []Point{{X: 0, Y: 2}, {X: 0, Y: 1}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 4, Y: 3}, Body: []Point{{X: 4, Y: 3}, {X: 4, Y: 2}, {X: 4, Y: 1}}},
+				},
+			},
+			SnakeIndex: 0,
+			ExpectedMoves: []Direction{
+				Up, Right,
+			},
+		},
 		{
 			Description: "One multi-length snake in the middle of the board",
 			Board: Board{
@@ -193,11 +224,11 @@ func TestGenerateSafeMoves(t *testing.T) {
 
 			t.Log("generated")
 			for _, move := range moves {
-				fmt.Println(visualizeBoard(tc.Board, WithMove(move, tc.SnakeIndex), WithNewlineCharacter("\n")))
+				fmt.Println(visualizeBoard(tc.Board, append(terminalBoardOptions(), WithMove(move, tc.SnakeIndex), WithNewlineCharacter("\n"))...))
 			}
 			t.Log("expected")
 			for _, move := range tc.ExpectedMoves {
-				fmt.Println(visualizeBoard(tc.Board, WithMove(move, tc.SnakeIndex), WithNewlineCharacter("\n")))
+				fmt.Println(visualizeBoard(tc.Board, append(terminalBoardOptions(), WithMove(move, tc.SnakeIndex), WithNewlineCharacter("\n"))...))
 			}
 		})
 	}
@@ -314,6 +345,120 @@ func TestApplyMove(t *testing.T) {
 				},
 			},
 		},
+		{
+			Description: "Snake leaving the edge of a wrapped board comes back on the other side",
+			InitialBoard: Board{
+				Height: 5, Width: 5, Wrapped: true,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 4, Y: 2}, Body: []Point{{X: 4, Y: 2}, {X: 3, Y: 2}}},
+				},
+			},
+			Move:       Right,
+			SnakeIndex: 0,
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Wrapped: true,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 0, Y: 2}, Body: []Point{{X: 0, Y: 2}, {X: 4, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Snake moving into hazard sauce takes the ruleset's damage",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Move:       Up,
+			SnakeIndex: 0,
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 35, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Snake dies inside hazard sauce when the damage takes the last of its health",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 2}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 15, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Move:       Up,
+			SnakeIndex: 0,
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 2}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 2, Y: 3}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "Stacked hazards do their damage once per layer",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Move:       Up,
+			SnakeIndex: 0,
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 21, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Eating food inside hazard sauce saves the snake from the damage",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Food:    []Point{{X: 2, Y: 3}},
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 5, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Move:       Up,
+			SnakeIndex: 0,
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Food:    []Point{},
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Hazards do nothing without hazard damage in the ruleset",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Move:       Up,
+			SnakeIndex: 0,
+			ExpectedBoard: Board{
+				Height: 5, Width: 5,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -323,11 +468,11 @@ func TestApplyMove(t *testing.T) {
 			assert.Equal(t, tc.ExpectedBoard, newBoard, "The resulting board state does not match the expected board state")
 
 			fmt.Println("original")
-			fmt.Println(visualizeBoard(tc.InitialBoard))
+			fmt.Println(visualizeBoard(tc.InitialBoard, terminalBoardOptions()...))
 			fmt.Println("expected")
-			fmt.Println(visualizeBoard(tc.ExpectedBoard))
+			fmt.Println(visualizeBoard(tc.ExpectedBoard, terminalBoardOptions()...))
 			fmt.Println("actual")
-			fmt.Println(visualizeBoard(newBoard))
+			fmt.Println(visualizeBoard(newBoard, terminalBoardOptions()...))
 		})
 	}
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"math"
+)
+
+// Best-reply search (Schadd and Winands) cuts the branching of a game with several opponents down to about
+// what a duel has. After our move only one opponent gets to answer, whichever answer hurts us most, while
+// the rest play the move they'd most likely play anyway. Snakes can't pass the way BRS's opponents usually
+// do, so the heuristic policy picks the move each of the others makes. The whole turn is then played at
+// once by the official rules, and our score is searched with alpha-beta.
+
+// BestReplySearch deepens a best-reply search a turn at a time until the context ends or maxnMaxDepth,
+// returning the last pass that finished. Like the MaxN searches it leaves chance out.
+func BestReplySearch(ctx context.Context, board Board, config SearchConfig) MaxNResult {
+	return bestReplySearchTo(ctx, board, config, maxnMaxDepth)
+}
+
+// bestReplySearchTo is BestReplySearch going no deeper than maxDepth turns.
+func bestReplySearchTo(ctx context.Context, board Board, config SearchConfig, maxDepth int) MaxNResult {
+	s := &maxnSearcher{ctx: ctx, config: config, modules: config.fullModules()}
+	result := MaxNResult{Move: Unset}
+	if len(board.Snakes) == 0 || isSnakeDead(board.Snakes[0]) {
+		return result
+	}
+	board = copyBoard(board)
+	board.FoodSpawnChance, board.MinimumFood, board.ShrinkEvery = 0, 0, 0
+
+	moves := maxnMoves(board, 0)
+	result.Move = moves[0]
+	for depth := 1; depth <= maxDepth && ctx.Err() == nil; depth++ {
+		moves = append([]Direction{result.Move}, removeDirection(moves, result.Move)...)
+		best, bestValue := Unset, math.Inf(-1)
+		for _, move := range moves {
+			value := s.bestReply(board, move, depth, bestValue, math.Inf(1))
+			if s.aborted {
+				break
+			}
+			if best == Unset || value > bestValue {
+				best, bestValue = move, value
+			}
+		}
+		if s.aborted {
+			break
+		}
+		result.Move, result.Scores, result.Depth = best, []float64{bestValue}, depth
+		if isTerminal(board) {
+			break
+		}
+	}
+	result.Nodes = s.nodes
+	return result
+}
+
+// bestReplyMax is our score with the turns left, us picking the move best for us.
+func (s *maxnSearcher) bestReplyMax(board Board, turns int, alpha, beta float64) float64 {
+	if scores, ok := s.leaf(board, 0, turns); ok {
+		return scores[0]
+	}
+	if isSnakeDead(board.Snakes[0]) {
+		return 0
+	}
+	value := math.Inf(-1)
+	for _, move := range maxnMoves(board, 0) {
+		value = math.Max(value, s.bestReply(board, move, turns, alpha, beta))
+		if s.aborted {
+			return value
+		}
+		alpha = math.Max(alpha, value)
+		if alpha >= beta {
+			break
+		}
+	}
+	return value
+}
+
+// bestReply is our move's score against the single worst answer any one opponent has to it, the others
+// playing their likeliest move.
+func (s *maxnSearcher) bestReply(board Board, move Direction, turns int, alpha, beta float64) float64 {
+	likely := likelyMoves(board)
+	likely[0] = move
+
+	value := math.Inf(1)
+	for i := 1; i < len(board.Snakes); i++ {
+		if isSnakeDead(board.Snakes[i]) {
+			continue
+		}
+		for _, reply := range maxnMoves(board, i) {
+			joint := append([]Direction(nil), likely...)
+			joint[i] = reply
+			next := copyBoard(board)
+			applyMoves(&next, joint)
+			value = math.Min(value, s.bestReplyMax(next, turns-1, alpha, beta))
+			if s.aborted {
+				return value
+			}
+			beta = math.Min(beta, value)
+			if alpha >= beta {
+				return value
+			}
+		}
+	}
+	if math.IsInf(value, 1) {
+		// nobody left to answer
+		next := copyBoard(board)
+		applyMoves(&next, likely)
+		value = s.bestReplyMax(next, turns-1, alpha, beta)
+	}
+	return value
+}
+
+// likelyMoves is the move the heuristic policy likes best for every living snake, by snake index.
+func likelyMoves(board Board) []Direction {
+	moves := make([]Direction, len(board.Snakes))
+	for i, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		options := maxnMoves(board, i)
+		priors := heuristicPolicy{}.Priors(board, i, options)
+		best := 0
+		for j := range options {
+			if priors[j] > priors[best] {
+				best = j
+			}
+		}
+		moves[i] = options[best]
+	}
+	return moves
+}
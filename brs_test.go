@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBestReplySearchTakesTheWin(t *testing.T) {
+	result := bestReplySearchTo(context.Background(), cornerEndgame(), SearchConfig{}, 3)
+	assert.Contains(t, []Direction{Down, Right}, result.Move)
+	require.Len(t, result.Scores, 1)
+	assert.Equal(t, 2.0*maxnScoreShift, result.Scores[0], "sees them die")
+}
+
+func TestBestReplySearchBranchesLessThanParanoid(t *testing.T) {
+	board := evalTestBoard()
+	paranoid := maxnSearchTo(context.Background(), board, SearchConfig{}, MaxNParanoid, 2)
+	brs := bestReplySearchTo(context.Background(), board, SearchConfig{}, 2)
+	assert.Equal(t, 2, brs.Depth)
+	assert.Less(t, brs.Nodes, paranoid.Nodes)
+	assert.Contains(t, generateSafeMoves(board, 0), brs.Move)
+}
+
+func TestBestReplySearchStopsWithTheContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := BestReplySearch(ctx, evalTestBoard(), SearchConfig{})
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Contains(t, generateSafeMoves(evalTestBoard(), 0), result.Move)
+
+	cancel()
+	result = BestReplySearch(ctx, evalTestBoard(), SearchConfig{})
+	assert.Zero(t, result.Depth)
+	assert.Contains(t, generateSafeMoves(evalTestBoard(), 0), result.Move, "still a safe move with no time at all")
+}
+
+func TestLikelyMovesAreSafe(t *testing.T) {
+	board := evalTestBoard()
+	moves := likelyMoves(board)
+	require.Len(t, moves, len(board.Snakes))
+	for i, move := range moves {
+		assert.Contains(t, generateSafeMoves(board, i), move, "snake %d", i)
+	}
+}
+
+func TestDepthFirstSearchPicksTheEngine(t *testing.T) {
+	board := evalTestBoard()
+	for _, engine := range []SearchEngine{EngineMaxN, EngineParanoid, EngineBRS} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		result, ok := depthFirstSearch(ctx, board, SearchConfig{Engine: engine})
+		cancel()
+		assert.True(t, ok, engine.String())
+		assert.Contains(t, generateSafeMoves(board, 0), result.Move, engine.String())
+	}
+	_, ok := depthFirstSearch(context.Background(), board, SearchConfig{Engine: EngineMCTS})
+	assert.False(t, ok, "the tree search isn't one")
+}
@@ -10,13 +10,20 @@ import (
 	"cloud.google.com/go/storage"
 )
 
+const (
+	bucketName = "gregorywebp"
+)
+
 // downloadAndUploadFile streams the file from the URL and uploads it directly to the Google Cloud Storage bucket.
 func downloadAndUploadFile(ctx context.Context, gameID string) error {
 
 	url := fmt.Sprintf("https://exporter.battlesnake.com/games/%s/gif", gameID)
-	bucketName := "gregorywebp"
 	// Make a GET request to the URL
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := exporterClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
@@ -27,31 +34,100 @@ func downloadAndUploadFile(ctx context.Context, gameID string) error {
 		return fmt.Errorf("bad status: %s", resp.Status)
 	}
 
-	// Create a Google Cloud Storage client
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to create storage client: %w", err)
-	}
-	defer client.Close()
+	err = guardBucket(ctx, func(ctx context.Context) error {
+		// Create a Google Cloud Storage client
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create storage client: %w", err)
+		}
+		defer client.Close()
 
-	// Get a reference to the bucket and object (file)
-	bucket := client.Bucket(bucketName)
-	object := bucket.Object(fmt.Sprintf("%s.gif", gameID))
+		// Get a reference to the bucket and object (file)
+		object := bucketObject(client, fmt.Sprintf("%s.gif", gameID))
 
-	// Create a new writer for the object in the bucket
-	writer := object.NewWriter(ctx)
+		// Create a new writer for the object in the bucket
+		writer := object.NewWriter(ctx)
+		writer.Metadata = engineBuild.Metadata()
 
-	// Stream the file from the URL directly to the bucket
-	_, err = io.Copy(writer, resp.Body)
+		// Stream the file from the URL directly to the bucket
+		_, err = io.Copy(writer, resp.Body)
+		if err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to copy data to bucket: %w", err)
+		}
+
+		// Close the writer to complete the upload
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to close writer: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to copy data to bucket: %w", err)
+		return err
 	}
 
-	// Close the writer to complete the upload
-	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to close writer: %w", err)
+	slog.Debug("file uploaded", "game_id", gameID)
+	return nil
+}
+
+// bucketObject is the object in our bucket. Everything we write overwrites the whole object, so it's safe
+// for the storage client to retry writes as well as reads.
+func bucketObject(client *storage.Client, objectName string) *storage.ObjectHandle {
+	return client.Bucket(bucketName).Object(objectName).Retryer(storage.WithPolicy(storage.RetryAlways))
+}
+
+// uploadToBucket writes the data to the named object in the bucket.
+func uploadToBucket(ctx context.Context, objectName, contentType string, data []byte) error {
+	err := guardBucket(ctx, func(ctx context.Context) error {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create storage client: %w", err)
+		}
+		defer client.Close()
+
+		writer := bucketObject(client, objectName).NewWriter(ctx)
+		writer.ContentType = contentType
+		writer.Metadata = engineBuild.Metadata()
+
+		if _, err := writer.Write(data); err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to write object: %w", err)
+		}
+
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to close writer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	slog.Debug("file uploaded", "game_id", gameID)
+	slog.Debug("object uploaded", "object", objectName)
 	return nil
 }
+
+// downloadFromBucket reads the named object from the bucket. Missing objects return storage.ErrObjectNotExist.
+func downloadFromBucket(ctx context.Context, objectName string) ([]byte, error) {
+	var data []byte
+	err := guardBucket(ctx, func(ctx context.Context) error {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create storage client: %w", err)
+		}
+		defer client.Close()
+
+		reader, err := bucketObject(client, objectName).NewReader(ctx)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		data, err = io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read object: %w", err)
+		}
+		return nil
+	})
+	return data, err
+}
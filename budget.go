@@ -0,0 +1,211 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMoveMargin is kept back from the game timeout for the network and writing the response
+	defaultMoveMargin = 170 * time.Millisecond
+	// latencyHeadroom is added on top of the worst network overhead we've seen
+	latencyHeadroom = 50 * time.Millisecond
+	// minSearchTime is the least we'll search for, no matter how bad the network looks
+	minSearchTime = 50 * time.Millisecond
+	// minMoveMargin is the least kept back from the timeout however much time is banked
+	minMoveMargin = 80 * time.Millisecond
+	// bankCap is the most time that can be banked, so a long run of forced moves doesn't build up a huge overdraft
+	bankCap = time.Second
+	// bankSpendShare is the share of the bank a single complex turn draws on
+	bankSpendShare = 0.5
+	// complexDistance is how close an opponent's head has to be for a turn with a choice to count as complex
+	complexDistance = 4
+	// openingTurns are the first turns of a game, when the snakes are short and far apart and the move
+	// hardly matters, so a quiet one doesn't get the whole budget
+	openingTurns = 5
+	// openingShare is the share of the budget a quiet opening turn searches for. the rest goes in the bank.
+	openingShare = 0.5
+)
+
+// LatencyTracker works out how much of each turn the network eats. The engine reports the round trip
+// it measured for our previous move, and anything beyond the time we spent in the handler was spent getting there and back.
+type LatencyTracker struct {
+	mu           sync.Mutex
+	lastTurn     int
+	lastDuration time.Duration
+	overhead     time.Duration
+	reported     time.Duration // every latency the engine's reported, for the average
+	reports      int
+}
+
+// Observe takes the latency the engine reported on this turn, which is for the previous turn's response.
+func (t *LatencyTracker) Observe(turn int, reported string) {
+	latencyMs, err := strconv.Atoi(reported)
+	if err != nil || latencyMs <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reported += time.Duration(latencyMs) * time.Millisecond
+	t.reports++
+	if t.lastDuration == 0 || t.lastTurn != turn-1 {
+		return
+	}
+	overhead := time.Duration(latencyMs)*time.Millisecond - t.lastDuration
+	if overhead > t.overhead {
+		t.overhead = overhead
+	}
+}
+
+// Record stores how long we took to answer the turn.
+func (t *LatencyTracker) Record(turn int, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastTurn = turn
+	t.lastDuration = duration
+}
+
+// Average is the mean latency the engine's reported over the game, zero before it's reported any.
+func (t *LatencyTracker) Average() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reports == 0 {
+		return 0
+	}
+	return t.reported / time.Duration(t.reports)
+}
+
+// Margin is how much of the timeout to keep back for the network.
+func (t *LatencyTracker) Margin() time.Duration {
+	if t == nil {
+		return defaultMoveMargin
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if margin := t.overhead + latencyHeadroom; margin > defaultMoveMargin {
+		return margin
+	}
+	return defaultMoveMargin
+}
+
+// MinMargin is the least of the timeout that can be kept back without risking the network making us late.
+func (t *LatencyTracker) MinMargin() time.Duration {
+	if t == nil {
+		return defaultMoveMargin
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if margin := t.overhead + latencyHeadroom; margin > minMoveMargin {
+		return margin
+	}
+	return minMoveMargin
+}
+
+// moveBudget is how long to search for given the game timeout and the margin kept back.
+func moveBudget(timeoutMs int, margin time.Duration) time.Duration {
+	budget := time.Duration(timeoutMs)*time.Millisecond - margin
+	if budget < minSearchTime {
+		return minSearchTime
+	}
+	return budget
+}
+
+// TimeManager decides how long each turn gets. Forced turns are answered straight away, settled searches
+// are cut off early, and the time they didn't use goes into a bank, which complex turns later draw on by keeping less of the timeout back.
+// It never lets the margin drop below what the network needs, so banked time can't make us late.
+type TimeManager struct {
+	latency *LatencyTracker
+
+	mu   sync.Mutex
+	bank time.Duration
+}
+
+func newTimeManager(latency *LatencyTracker) *TimeManager {
+	return &TimeManager{latency: latency}
+}
+
+// Budget is how long to search this turn, and how much of that was drawn from the bank. Quiet opening
+// turns only get a share of the usual budget, and complex turns can borrow on top of it.
+func (m *TimeManager) Budget(timeoutMs int, turn int, complex bool) (time.Duration, time.Duration) {
+	margin := m.latency.Margin()
+	budget := moveBudget(timeoutMs, margin)
+	if !complex {
+		return phaseBudget(budget, turn), 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	borrowed := time.Duration(float64(m.bank) * bankSpendShare)
+	if spare := margin - m.latency.MinMargin(); borrowed > spare {
+		borrowed = spare
+	}
+	if borrowed <= 0 {
+		return budget, 0
+	}
+	m.bank -= borrowed
+	return moveBudget(timeoutMs, margin-borrowed), borrowed
+}
+
+// phaseBudget cuts the budget down for the opening turns.
+func phaseBudget(budget time.Duration, turn int) time.Duration {
+	if turn >= openingTurns {
+		return budget
+	}
+	if opening := time.Duration(float64(budget) * openingShare); opening > minSearchTime {
+		return opening
+	}
+	return minSearchTime
+}
+
+// Deposit banks the part of the turn's budget we didn't use.
+func (m *TimeManager) Deposit(timeoutMs int, used time.Duration) {
+	saved := moveBudget(timeoutMs, m.latency.Margin()) - used
+	if saved <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bank += saved
+	if m.bank > bankCap {
+		m.bank = bankCap
+	}
+}
+
+// Banked is how much time is in the bank.
+func (m *TimeManager) Banked() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bank
+}
+
+// forcedMove returns our only safe move when we don't have a choice.
+func forcedMove(board Board) (Direction, bool) {
+	if len(board.Snakes) == 0 {
+		return Unset, false
+	}
+	moves := generateSafeMoves(board, 0)
+	if len(moves) != 1 {
+		return Unset, false
+	}
+	return moves[0], true
+}
+
+// complexTurn says whether the turn is worth spending banked time on: we have a choice to make
+// and an opponent is close enough for it to matter.
+func complexTurn(board Board) bool {
+	if len(board.Snakes) == 0 || len(generateSafeMoves(board, 0)) < 2 {
+		return false
+	}
+	head := board.Snakes[0].Head
+	for _, snake := range board.Snakes[1:] {
+		if !isSnakeDead(snake) && manhattanDistance(head, snake.Head) <= complexDistance {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyTrackerMargin(t *testing.T) {
+	var missing *LatencyTracker
+	assert.Equal(t, defaultMoveMargin, missing.Margin())
+
+	tracker := &LatencyTracker{}
+	tracker.Observe(1, "400")
+	assert.Equal(t, defaultMoveMargin, tracker.Margin(), "nothing to compare the first latency to")
+
+	// we took 300ms and the engine saw 350ms, well within the default margin
+	tracker.Record(1, 300*time.Millisecond)
+	tracker.Observe(2, "350")
+	assert.Equal(t, defaultMoveMargin, tracker.Margin())
+
+	// a slow network spends 200ms getting there and back
+	tracker.Record(2, 300*time.Millisecond)
+	tracker.Observe(3, "500")
+	assert.Equal(t, 200*time.Millisecond+latencyHeadroom, tracker.Margin())
+
+	// the worst overhead sticks around after a good turn
+	tracker.Record(3, 250*time.Millisecond)
+	tracker.Observe(4, "260")
+	assert.Equal(t, 200*time.Millisecond+latencyHeadroom, tracker.Margin())
+
+	// garbage and skipped turns are ignored
+	tracker.Record(4, 100*time.Millisecond)
+	tracker.Observe(5, "")
+	tracker.Observe(7, "900")
+	assert.Equal(t, 200*time.Millisecond+latencyHeadroom, tracker.Margin())
+}
+
+func TestLatencyTrackerAverage(t *testing.T) {
+	var missing *LatencyTracker
+	assert.Zero(t, missing.Average())
+
+	tracker := &LatencyTracker{}
+	assert.Zero(t, tracker.Average())
+	tracker.Observe(1, "400")
+	tracker.Observe(2, "")
+	tracker.Observe(3, "200")
+	tracker.Observe(5, "300")
+	assert.Equal(t, 300*time.Millisecond, tracker.Average(), "every latency counts, garbage doesn't")
+}
+
+func TestMoveBudget(t *testing.T) {
+	assert.Equal(t, 330*time.Millisecond, moveBudget(500, defaultMoveMargin))
+	assert.Equal(t, minSearchTime, moveBudget(100, defaultMoveMargin))
+}
+
+func TestTimeManagerBanksForcedTurns(t *testing.T) {
+	latency := &LatencyTracker{}
+	manager := newTimeManager(latency)
+	normal := moveBudget(500, defaultMoveMargin)
+
+	budget, borrowed := manager.Budget(500, openingTurns, true)
+	assert.Equal(t, normal, budget, "nothing banked yet")
+	assert.Zero(t, borrowed)
+
+	// a forced turn answered in 10ms banks the rest of its budget
+	manager.Deposit(500, 10*time.Millisecond)
+	assert.Equal(t, normal-10*time.Millisecond, manager.Banked())
+
+	budget, borrowed = manager.Budget(500, openingTurns, false)
+	assert.Equal(t, normal, budget, "simple turns leave the bank alone")
+	assert.Zero(t, borrowed)
+
+	// a complex turn can only eat into the margin down to the minimum, however much is banked
+	budget, borrowed = manager.Budget(500, openingTurns, true)
+	assert.Equal(t, defaultMoveMargin-minMoveMargin, borrowed)
+	assert.Equal(t, moveBudget(500, minMoveMargin), budget)
+	assert.Equal(t, normal-10*time.Millisecond-borrowed, manager.Banked())
+
+	// and never more than its share of what's left
+	manager.bank = 40 * time.Millisecond
+	budget, borrowed = manager.Budget(500, openingTurns, true)
+	assert.Equal(t, 20*time.Millisecond, borrowed)
+	assert.Equal(t, normal+20*time.Millisecond, budget)
+	assert.Equal(t, 20*time.Millisecond, manager.Banked())
+}
+
+func TestTimeManagerOpening(t *testing.T) {
+	manager := newTimeManager(&LatencyTracker{})
+	normal := moveBudget(500, defaultMoveMargin)
+
+	budget, _ := manager.Budget(500, 0, false)
+	assert.Equal(t, time.Duration(float64(normal)*openingShare), budget, "a quiet opening turn gets a share")
+	budget, _ = manager.Budget(500, openingTurns, false)
+	assert.Equal(t, normal, budget)
+	budget, _ = manager.Budget(500, 0, true)
+	assert.Equal(t, normal, budget, "an opponent close by gets the whole budget, opening or not")
+
+	// the share never drops below the least we'll search for
+	assert.Equal(t, minSearchTime, phaseBudget(60*time.Millisecond, 0))
+}
+
+func TestTimeManagerRespectsTheNetwork(t *testing.T) {
+	latency := &LatencyTracker{}
+	manager := newTimeManager(latency)
+
+	// a slow network needs all of the margin, so there's nothing to borrow
+	latency.Record(1, 300*time.Millisecond)
+	latency.Observe(2, "500")
+	manager.Deposit(500, 0)
+	budget, borrowed := manager.Budget(500, openingTurns, true)
+	assert.Zero(t, borrowed)
+	assert.Equal(t, moveBudget(500, latency.Margin()), budget)
+}
+
+func TestTimeManagerBankIsCapped(t *testing.T) {
+	manager := newTimeManager(&LatencyTracker{})
+	for i := 0; i < 20; i++ {
+		manager.Deposit(500, 0)
+	}
+	assert.Equal(t, bankCap, manager.Banked())
+
+	// running over the budget doesn't take anything out
+	manager.Deposit(500, time.Second)
+	assert.Equal(t, bankCap, manager.Banked())
+}
+
+func TestForcedMoveAndComplexTurn(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Us          Snake
+		Them        Snake
+		Forced      Direction
+		Complex     bool
+	}{
+		{
+			Description: "cornered",
+			Us:          Snake{Health: 100, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}}},
+			Them:        Snake{Health: 100, Head: Point{X: 2, Y: 1}, Body: []Point{{X: 2, Y: 1}, {X: 3, Y: 1}, {X: 4, Y: 1}}},
+			Forced:      Right,
+		},
+		{
+			Description: "choice with an opponent close by",
+			Us:          Snake{Health: 100, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+			Them:        Snake{Health: 100, Head: Point{X: 7, Y: 6}, Body: []Point{{X: 7, Y: 6}, {X: 8, Y: 6}, {X: 9, Y: 6}}},
+			Complex:     true,
+		},
+		{
+			Description: "choice with nobody about",
+			Us:          Snake{Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+			Them:        Snake{Health: 100, Head: Point{X: 9, Y: 9}, Body: []Point{{X: 9, Y: 9}, {X: 9, Y: 10}, {X: 10, Y: 10}}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			board := Board{Height: 11, Width: 11, Snakes: []Snake{tc.Us, tc.Them}}
+			move, forced := forcedMove(board)
+			assert.Equal(t, tc.Forced != Unset, forced)
+			assert.Equal(t, tc.Forced, move)
+			assert.Equal(t, tc.Complex, complexTurn(board))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheTurn records what the warm start cache did on a single turn.
+type CacheTurn struct {
+	Turn         int   `json:"turn"`
+	Hit          bool  `json:"hit"`
+	NearMiss     bool  `json:"near_miss"` // missed, but a saved snapshot matched everything except food
+	ReusedVisits int64 `json:"reused_visits"`
+	Saved        int   `json:"saved"`
+	Nodes        int   `json:"nodes"`  // nodes kept beneath the saved snapshots
+	Pruned       int   `json:"pruned"` // nodes cut to keep under the reuse budget
+	SaveMs       int64 `json:"save_ms"`
+}
+
+// CacheStats tracks how often the depth 2 snapshots from one turn match the board on the next.
+type CacheStats struct {
+	mu    sync.Mutex
+	turns []CacheTurn
+	// boards of the saved snapshots without food, to spot misses caused by food spawning
+	shapes map[string]bool
+}
+
+// CacheReport summarises the cache for a game.
+type CacheReport struct {
+	GameID          string  `json:"game_id"`
+	Turns           int     `json:"turns"`
+	Hits            int     `json:"hits"`
+	NearMisses      int     `json:"near_misses"`
+	HitRate         float64 `json:"hit_rate"`
+	HitTurns        []int   `json:"hit_turns"`
+	AvgReusedVisits float64 `json:"avg_reused_visits"`
+	AvgSaved        float64 `json:"avg_saved"`
+	AvgNodes        float64 `json:"avg_nodes"`
+	PrunedTurns     int     `json:"pruned_turns"`
+	AvgSaveMs       float64 `json:"avg_save_ms"`
+}
+
+// boardShape is the board's hash ignoring food, which the search can't predict.
+func boardShape(board Board) string {
+	board.Food = nil
+	return boardHash(board)
+}
+
+// Lookup records whether the board is in the cache. It has to be called before the search adds visits.
+func (s *CacheStats) Lookup(turn int, gameState map[string]*Node, board Board) CacheTurn {
+	record := CacheTurn{Turn: turn}
+	if node, ok := gameState[boardHash(board)]; ok {
+		record.Hit = true
+		record.ReusedVisits = atomic.LoadInt64(&node.Visits)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !record.Hit && s.shapes[boardShape(board)] {
+		record.NearMiss = true
+	}
+	s.turns = append(s.turns, record)
+	return record
+}
+
+// Saved records the snapshots kept after the turn's search, how much of the tree came with them and how
+// long it took to keep them.
+func (s *CacheStats) Saved(turn int, gameState map[string]*Node, reuse TreeReuse, duration time.Duration) {
+	shapes := make(map[string]bool, len(gameState))
+	for _, node := range gameState {
+		shapes[boardShape(node.Board)] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shapes = shapes
+	if len(s.turns) > 0 && s.turns[len(s.turns)-1].Turn == turn {
+		s.turns[len(s.turns)-1].Saved = len(gameState)
+		s.turns[len(s.turns)-1].Nodes = reuse.Nodes
+		s.turns[len(s.turns)-1].Pruned = reuse.Pruned
+		s.turns[len(s.turns)-1].SaveMs = duration.Milliseconds()
+	}
+}
+
+// Report summarises the game so far. The first turn never has anything cached so it's left out.
+func (s *CacheStats) Report(gameID string) CacheReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := CacheReport{GameID: gameID}
+	var reused, saved, nodes, saveMs int64
+	for _, turn := range s.turns {
+		saved += int64(turn.Saved)
+		nodes += int64(turn.Nodes)
+		saveMs += turn.SaveMs
+		if turn.Pruned > 0 {
+			report.PrunedTurns++
+		}
+		if turn.Turn == 0 {
+			continue
+		}
+		report.Turns++
+		if turn.Hit {
+			report.Hits++
+			report.HitTurns = append(report.HitTurns, turn.Turn)
+			reused += turn.ReusedVisits
+		}
+		if turn.NearMiss {
+			report.NearMisses++
+		}
+	}
+
+	if report.Turns > 0 {
+		report.HitRate = float64(report.Hits) / float64(report.Turns)
+	}
+	if report.Hits > 0 {
+		report.AvgReusedVisits = float64(reused) / float64(report.Hits)
+	}
+	if len(s.turns) > 0 {
+		report.AvgSaved = float64(saved) / float64(len(s.turns))
+		report.AvgNodes = float64(nodes) / float64(len(s.turns))
+		report.AvgSaveMs = float64(saveMs) / float64(len(s.turns))
+	}
+	return report
+}
+
+func (r CacheReport) String() string {
+	return fmt.Sprintf("cache hit %d/%d turns (%.0f%%), %d more missed only on food, %.0f visits reused per hit, %.0f snapshots holding %.0f nodes saved in %.1fms per turn, pruned to budget on %d turns",
+		r.Hits, r.Turns, r.HitRate*100, r.NearMisses, r.AvgReusedVisits, r.AvgSaved, r.AvgNodes, r.AvgSaveMs, r.PrunedTurns)
+}
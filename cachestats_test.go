@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheStatsReport(t *testing.T) {
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Food:   []Point{{X: 3, Y: 3}},
+		Snakes: []Snake{
+			{ID: "a", Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "b", Health: 100, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+		},
+	}
+	predicted := copyBoard(board)
+	applyMove(&predicted, 0, Up)
+	applyMove(&predicted, 1, Down)
+
+	stats := &CacheStats{}
+
+	// first turn has nothing cached
+	stats.Lookup(0, map[string]*Node{}, board)
+	snapshot := map[string]*Node{boardHash(predicted): {Board: predicted, Visits: 120}}
+	stats.Saved(0, snapshot, TreeReuse{Replies: 1, Nodes: 400}, 2*time.Millisecond)
+
+	// turn 1 plays out as predicted
+	stats.Lookup(1, snapshot, predicted)
+	stats.Saved(1, snapshot, TreeReuse{Replies: 1, Nodes: 1000, Pruned: 300}, 4*time.Millisecond)
+
+	// turn 2 the same snakes but food spawned
+	spawned := copyBoard(predicted)
+	spawned.Food = append(spawned.Food, Point{X: 0, Y: 6})
+	stats.Lookup(2, snapshot, spawned)
+
+	// turn 3 something we didn't save at all
+	stats.Lookup(3, snapshot, board)
+
+	report := stats.Report("game")
+	assert.Equal(t, 3, report.Turns)
+	assert.Equal(t, 1, report.Hits)
+	assert.Equal(t, 1, report.NearMisses)
+	assert.Equal(t, []int{1}, report.HitTurns)
+	assert.InDelta(t, 1.0/3, report.HitRate, 0.001)
+	assert.Equal(t, 120.0, report.AvgReusedVisits)
+	assert.Equal(t, 0.5, report.AvgSaved)
+	assert.Equal(t, 1.5, report.AvgSaveMs)
+	assert.Equal(t, 350.0, report.AvgNodes)
+	assert.Equal(t, 1, report.PrunedTurns)
+	assert.Contains(t, report.String(), "cache hit 1/3 turns")
+}
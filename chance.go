@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+)
+
+// A turn ends with the board doing things nobody chose: food turning up somewhere, and in royale the
+// hazards closing in from one side. Rather than fix one of those futures for good when the turn is
+// expanded, the move that finishes the turn leads to a chance node holding the board before the dice are
+// rolled. Each time the search passes through it rolls them again and carries on down the outcome it
+// got, so outcomes are visited about as often as they'd happen and the chance node's average is the
+// expectation over all of them.
+
+// newChanceNode is the node for a board that's waiting on the end of its turn. It has no moves of its
+// own, its children are the outcomes rolled so far, each with the same snake to move next.
+func newChanceNode(board Board, snakeIndex int, parent *Node) *Node {
+	node := NewNode(board, snakeIndex, parent)
+	node.moves = nil
+	node.chance = true
+	return node
+}
+
+// hasChance says whether the end of the turn can go more than one way on the board.
+func hasChance(board Board) bool {
+	return len(board.Food) < board.MinimumFood || board.FoodSpawnChance > 0 || turnsUntilShrink(board) == 1
+}
+
+// rollChance plays the end of a turn's chance events on the board, moving it on to the next turn.
+func rollChance(board *Board) {
+	board.Turn++
+	spawnFood(board)
+	shrinkHazards(board)
+	if rules := rulesForMap(board.Map); rules.EndTurn != nil {
+		rules.EndTurn(board)
+	}
+}
+
+// outcome rolls the chance node's dice and returns the child they landed on, adding it if nobody has
+// rolled it before. The second result says whether it's new.
+func (n *Node) outcome() (*Node, bool) {
+	board := copyBoard(n.Board)
+	rollChance(&board)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, child := range n.Children() {
+		if slices.Equal(child.Board.Food, board.Food) && slices.Equal(child.Board.Hazards, board.Hazards) {
+			return child, false
+		}
+	}
+	child := NewNode(board, n.SnakeIndex, n)
+	n.addChild(child)
+	return child, true
+}
+
+// shrinkHazards closes the royale hazards in by a row or column on the turns the ruleset shrinks, from a
+// side picked at random the way the engine does.
+func shrinkHazards(board *Board) {
+	if board.ShrinkEvery <= 0 || board.Turn%board.ShrinkEvery != 0 {
+		return
+	}
+	side := AllDirections[rand.Intn(len(AllDirections))]
+	for _, point := range nextHazardLine(board, side) {
+		if !containsPoint(board.Hazards, point) {
+			board.Hazards = append(board.Hazards, point)
+		}
+	}
+}
+
+// nextHazardLine is the row or column the ring closes over next from the side: the one furthest out that
+// isn't all hazard yet. It's empty once the side has closed in all the way.
+func nextHazardLine(board *Board, side Direction) []Point {
+	for depth := 0; ; depth++ {
+		line := edgeLine(board, side, depth)
+		if len(line) == 0 {
+			return nil
+		}
+		for _, point := range line {
+			if !containsPoint(board.Hazards, point) {
+				return line
+			}
+		}
+	}
+}
+
+// edgeLine is the row or column the given depth in from the side of the board, or nothing once that's
+// past the far side.
+func edgeLine(board *Board, side Direction, depth int) []Point {
+	var line []Point
+	switch side {
+	case Left, Right:
+		if depth >= board.Width {
+			return nil
+		}
+		x := depth
+		if side == Right {
+			x = board.Width - 1 - depth
+		}
+		for y := 0; y < board.Height; y++ {
+			line = append(line, Point{X: x, Y: y})
+		}
+	case Down, Up:
+		if depth >= board.Height {
+			return nil
+		}
+		y := depth
+		if side == Up {
+			y = board.Height - 1 - depth
+		}
+		for x := 0; x < board.Width; x++ {
+			line = append(line, Point{X: x, Y: y})
+		}
+	}
+	return line
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasChance(t *testing.T) {
+	board := evalTestBoard()
+	board.Food = []Point{{X: 5, Y: 5}}
+	assert.False(t, hasChance(board), "no settings, nothing random")
+
+	board.MinimumFood = 1
+	assert.False(t, hasChance(board), "the minimum is already on the board")
+	board.MinimumFood = 2
+	assert.True(t, hasChance(board), "where the missing food goes is random")
+
+	board.MinimumFood = 0
+	board.FoodSpawnChance = 15
+	assert.True(t, hasChance(board))
+
+	board.FoodSpawnChance = 0
+	board.ShrinkEvery = 25
+	board.Turn = 23
+	assert.False(t, hasChance(board), "the ring isn't due to close in")
+	board.Turn = 24
+	assert.True(t, hasChance(board), "it closes in from a random side next turn")
+}
+
+func TestShrinkHazards(t *testing.T) {
+	board := Board{Width: 3, Height: 2}
+	shrinkHazards(&board)
+	assert.Empty(t, board.Hazards, "only in royale")
+	board.ShrinkEvery, board.Turn = 5, 3
+	shrinkHazards(&board)
+	assert.Empty(t, board.Hazards, "only on the turns it's due")
+
+	// every turn shrinks, each time a line further in from whichever side comes up until it's all sauce
+	board.ShrinkEvery = 1
+	for turn := 1; len(board.Hazards) < 6; turn++ {
+		before := len(board.Hazards)
+		shrinkHazards(&board)
+		require.Greater(t, len(board.Hazards), before, "turn %d", turn)
+		require.LessOrEqual(t, len(board.Hazards)-before, 3, "turn %d adds a row or a column", turn)
+	}
+	for y := 0; y < board.Height; y++ {
+		for x := 0; x < board.Width; x++ {
+			assert.Contains(t, board.Hazards, Point{X: x, Y: y})
+		}
+	}
+	shrinkHazards(&board)
+	assert.Len(t, board.Hazards, 6, "nowhere left to close in")
+}
+
+func TestEdgeLine(t *testing.T) {
+	board := &Board{Width: 3, Height: 2}
+	assert.Equal(t, []Point{{X: 0, Y: 0}, {X: 0, Y: 1}}, edgeLine(board, Left, 0))
+	assert.Equal(t, []Point{{X: 1, Y: 0}, {X: 1, Y: 1}}, edgeLine(board, Right, 1))
+	assert.Equal(t, []Point{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}}, edgeLine(board, Up, 0))
+	assert.Equal(t, []Point{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}}, edgeLine(board, Down, 1))
+	assert.Empty(t, edgeLine(board, Down, 2))
+	assert.Empty(t, edgeLine(board, Left, 3))
+}
+
+func TestChanceNodesAverageOverOutcomes(t *testing.T) {
+	board := evalTestBoard()
+	board.FoodSpawnChance = 50
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	root := MCTS(ctx, "chance", board, 3000, 2, make(map[string]*Node))
+
+	var chanceNodes int
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		children := node.Children()
+		if node.chance {
+			chanceNodes++
+			assert.Equal(t, len(board.Snakes)-1, node.SnakeIndex, "only the last snake to move finishes a turn")
+			assert.Empty(t, node.UnexpandedMoves(), "a chance node has outcomes, not moves")
+			visits := node.leafVisits
+			for i, child := range children {
+				assert.Equal(t, node.SnakeIndex, child.SnakeIndex)
+				assert.Equal(t, node.Board.Snakes, child.Board.Snakes, "rolling doesn't move anyone")
+				visits += child.Visits
+				for _, other := range children[i+1:] {
+					assert.NotEqual(t, child.Board.Food, other.Board.Food, "each outcome is rolled once")
+				}
+			}
+			assert.Equal(t, node.Visits, visits, "the chance node's visits are its outcomes'")
+		}
+		for _, child := range children {
+			walk(child)
+		}
+	}
+	walk(root)
+	assert.Positive(t, chanceNodes)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// checkpointRestoreTime is the most a move waits on the store for a game's checkpoint. It's only ever
+	// the first move after a restart, but it comes out of that move's search.
+	checkpointRestoreTime = 150 * time.Millisecond
+	// checkpointSaveTime is the most shutdown spends writing checkpoints, inside the 10s cloud run gives
+	// between SIGTERM and killing us.
+	checkpointSaveTime = 5 * time.Second
+)
+
+// GameCheckpoint is enough of a session to carry a game on after a restart: who we are in it, what we've
+// decided so far for the end of game report, and the trees saved for next turn's search, each under the
+// board it starts from.
+type GameCheckpoint struct {
+	GameID      string
+	YouID       string
+	Source      string
+	Timeout     int
+	OtherSnakes []string
+	Start       time.Time
+	Decisions   []DecisionRecord
+	Trees       []TreeSnapshot
+	Saved       time.Time
+}
+
+// SessionStore keeps game checkpoints somewhere that outlives the instance.
+type SessionStore interface {
+	// Get returns the checkpoint saved under the key and whether there is one. Keys are the game, with the
+	// snake in front for any but the production one.
+	Get(ctx context.Context, key string) (GameCheckpoint, bool, error)
+	Put(ctx context.Context, key string, checkpoint GameCheckpoint) error
+	// Delete removes the checkpoint. There not being one isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// sessionStore is where sessions are saved on shutdown and looked for when a game turns up that we've
+// never heard of. Left nil, nothing is saved. CHECKPOINTS=off turns it off.
+var sessionStore SessionStore
+
+func sessionStoreFromEnv() SessionStore {
+	if os.Getenv("CHECKPOINTS") == "off" {
+		return nil
+	}
+	return &bucketSessionStore{}
+}
+
+// checkpoint saves the session. Whatever it's searching in the background should be stopped first so
+// the trees hold still.
+func (s *GameSession) checkpoint() (GameCheckpoint, error) {
+	checkpoint := GameCheckpoint{
+		GameID:      s.ID,
+		YouID:       s.YouID,
+		Source:      s.Source,
+		Timeout:     s.Timeout,
+		OtherSnakes: s.otherSnakes,
+		Start:       s.start,
+		Decisions:   s.decisions.Records(),
+		Saved:       time.Now(),
+	}
+	// the saved trees were already cut down to treeReuseNodes between them at the end of the move
+	for _, root := range s.States() {
+		snapshot, err := snapshotTree(root)
+		if err != nil {
+			return GameCheckpoint{}, fmt.Errorf("failed to snapshot tree: %w", err)
+		}
+		checkpoint.Trees = append(checkpoint.Trees, *snapshot)
+	}
+	return checkpoint, nil
+}
+
+// restoreSession rebuilds the game's session from its checkpoint. A tree that won't rebuild is left out
+// rather than losing the rest.
+func (s *HostedSnake) restoreSession(game BattleSnakeGame, checkpoint GameCheckpoint) *GameSession {
+	session := s.newSession(game, checkpoint.OtherSnakes)
+	session.YouID = checkpoint.YouID
+	session.Source = checkpoint.Source
+	session.Timeout = checkpoint.Timeout
+	session.start = checkpoint.Start
+	session.restored = true
+	for _, decision := range checkpoint.Decisions {
+		session.decisions.Add(decision)
+	}
+	states := make(map[string]*Node, len(checkpoint.Trees))
+	for i := range checkpoint.Trees {
+		root, err := checkpoint.Trees[i].Tree()
+		if err != nil {
+			session.Logger.Warn("failed to rebuild checkpointed tree", "error", err.Error())
+			continue
+		}
+		states[boardHash(root.Board)] = root
+	}
+	session.SetStates(states)
+	return session
+}
+
+// loadCheckpoint looks for the game's checkpoint, giving up quickly since there's a move waiting on it.
+func (s *HostedSnake) loadCheckpoint(store SessionStore, game BattleSnakeGame) (*GameSession, bool) {
+	if store == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointRestoreTime)
+	defer cancel()
+	checkpoint, ok, err := store.Get(ctx, s.checkpointKey(game.Game.ID))
+	if err != nil {
+		slog.Warn("failed to load checkpoint", "game_id", game.Game.ID, "error", err.Error())
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	session := s.restoreSession(game, checkpoint)
+	session.Logger.Info("restored session from checkpoint", "saved", checkpoint.Saved, "decisions", len(checkpoint.Decisions), "trees", len(session.States()))
+	return session, true
+}
+
+// checkpointSessions saves every game in progress of every snake, for another instance or the next one to
+// carry on with. It returns how many were saved.
+func checkpointSessions(ctx context.Context, store SessionStore) int {
+	if store == nil {
+		return 0
+	}
+	saved := 0
+	for _, snake := range allSnakes() {
+		for _, session := range snake.sessions.All() {
+			session.prefetch.Stop()
+			checkpoint, err := session.checkpoint()
+			if err == nil {
+				err = store.Put(ctx, snake.checkpointKey(session.ID), checkpoint)
+			}
+			if err != nil {
+				session.Logger.Error("failed to checkpoint session", "error", err.Error())
+				continue
+			}
+			saved++
+		}
+	}
+	return saved
+}
+
+// forgetCheckpoint removes a finished game's checkpoint in the background.
+func forgetCheckpoint(store SessionStore, key string) {
+	if store == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), checkpointSaveTime)
+		defer cancel()
+		if err := store.Delete(ctx, key); err != nil {
+			slog.Warn("failed to delete checkpoint", "key", key, "error", err.Error())
+		}
+	}()
+}
+
+// bucketSessionStore keeps game checkpoints as gobs in our bucket.
+type bucketSessionStore struct {
+	once   sync.Once
+	client *storage.Client
+	err    error
+}
+
+func (s *bucketSessionStore) object(key string) (*storage.ObjectHandle, error) {
+	s.once.Do(func() {
+		s.client, s.err = storage.NewClient(context.Background())
+	})
+	if s.err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", s.err)
+	}
+	return s.client.Bucket(bucketName).Object(fmt.Sprintf("checkpoints/%s.gob", key)), nil
+}
+
+func (s *bucketSessionStore) Get(ctx context.Context, key string) (GameCheckpoint, bool, error) {
+	object, err := s.object(key)
+	if err != nil {
+		return GameCheckpoint{}, false, err
+	}
+	reader, err := object.NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return GameCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return GameCheckpoint{}, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	defer reader.Close()
+
+	var checkpoint GameCheckpoint
+	if err := gob.NewDecoder(reader).Decode(&checkpoint); err != nil {
+		return GameCheckpoint{}, false, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return checkpoint, true, nil
+}
+
+func (s *bucketSessionStore) Put(ctx context.Context, key string, checkpoint GameCheckpoint) error {
+	object, err := s.object(key)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(checkpoint); err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	writer := object.NewWriter(ctx)
+	writer.ContentType = "application/octet-stream"
+	writer.Metadata = engineBuild.Metadata()
+	if _, err := writer.Write(buf.Bytes()); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint writer: %w", err)
+	}
+	return nil
+}
+
+func (s *bucketSessionStore) Delete(ctx context.Context, key string) error {
+	object, err := s.object(key)
+	if err != nil {
+		return err
+	}
+	if err := object.Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySessionStore keeps checkpoints gob encoded, the same as the bucket, so anything that wouldn't
+// survive the trip doesn't survive here either.
+type memorySessionStore struct {
+	mu          sync.Mutex
+	checkpoints map[string][]byte
+	deleted     []string
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{checkpoints: make(map[string][]byte)}
+}
+
+func (s *memorySessionStore) Get(ctx context.Context, key string) (GameCheckpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.checkpoints[key]
+	if !ok {
+		return GameCheckpoint{}, false, nil
+	}
+	var checkpoint GameCheckpoint
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&checkpoint)
+	return checkpoint, err == nil, err
+}
+
+func (s *memorySessionStore) Put(ctx context.Context, key string, checkpoint GameCheckpoint) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(checkpoint); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[key] = buf.Bytes()
+	return nil
+}
+
+func (s *memorySessionStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.checkpoints, key)
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func (s *memorySessionStore) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// forgetSessions drops every session without ending it, the way a restart would.
+func forgetSessions() {
+	for _, session := range sessions.All() {
+		session.Close()
+		sessions.Remove(session.ID)
+	}
+}
+
+func TestSessionSurvivesRestart(t *testing.T) {
+	store := newMemorySessionStore()
+	saved := sessionStore
+	sessionStore = store
+	t.Cleanup(func() { sessionStore = saved })
+	forgetSessions()
+
+	router := newRouter("")
+	game := sessionTestGame("checkpoint-restart")
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	for _, path := range []string{"/start", "/move"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	before := productionSnake.lookupSession(game)
+	require.NotEmpty(t, before.States())
+	visits := make(map[string]int64)
+	for key, root := range before.States() {
+		visits[key] = root.Visits
+	}
+
+	assert.Equal(t, 1, checkpointSessions(context.Background(), store))
+	forgetSessions()
+
+	after := productionSnake.lookupSession(game)
+	assert.NotSame(t, before, after)
+	assert.True(t, after.restored)
+	assert.Equal(t, []string{"b"}, after.otherSnakes, "not a server reset as far as the report's concerned")
+	assert.Equal(t, before.decisions.Records(), after.decisions.Records())
+	require.Len(t, after.States(), len(visits))
+	for key, root := range after.States() {
+		assert.Equal(t, visits[key], root.Visits, "tree under %s", key)
+	}
+
+	productionSnake.endSession(game)
+	assert.Eventually(t, func() bool { return len(store.Deleted()) == 1 }, time.Second, 10*time.Millisecond, "a finished game's checkpoint is cleaned up")
+}
+
+func TestUnknownGameWithoutCheckpoint(t *testing.T) {
+	saved := sessionStore
+	sessionStore = newMemorySessionStore()
+	t.Cleanup(func() { sessionStore = saved })
+
+	game := sessionTestGame("checkpoint-missing")
+	session := productionSnake.lookupSession(game)
+	assert.False(t, session.restored)
+	assert.Equal(t, []string{"server reset during game"}, session.otherSnakes)
+	productionSnake.endSession(game)
+}
+
+func TestShutdownCheckpointsGames(t *testing.T) {
+	store := newMemorySessionStore()
+	forgetSessions()
+	game := sessionTestGame("checkpoint-shutdown")
+	productionSnake.startSession(newGameSession(game, []string{"b"}))
+	t.Cleanup(forgetSessions)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: newRouter("")}
+	go server.Serve(listener)
+
+	shutdown(server, store)
+	_, err = http.Get("http://" + listener.Addr().String() + "/")
+	assert.Error(t, err, "no more requests once it's shut down")
+	_, ok, err := store.Get(context.Background(), game.Game.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
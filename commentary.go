@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// share of the board an opponent has to lose in one turn before we say we boxed them in
+	commentaryBoxedShare = 0.05
+	// change in a module's score between turns that's worth talking about
+	commentaryEvalDelta = 0.15
+	// total eval drop between turns that counts as getting into trouble
+	commentaryTroubleDelta = 0.3
+)
+
+// commentarySnapshot is what the commentator remembers about the previous turn.
+type commentarySnapshot struct {
+	turn      int
+	move      string
+	evals     map[string]float64
+	total     float64
+	territory map[string]float64 // share of the board each snake controls, by id
+}
+
+// Commentator turns each turn's decision into a line of commentary when something interesting happened.
+type Commentator struct {
+	mu   sync.Mutex
+	prev *commentarySnapshot
+}
+
+// evaluationBreakdown returns each module's unweighted score for the board.
+func evaluationBreakdown(board Board, rootSnakeIndex int, modules []EvaluationModule) map[string]float64 {
+	breakdown := make(map[string]float64, len(modules))
+	if rootSnakeIndex < 0 || rootSnakeIndex >= len(board.Snakes) {
+		return breakdown
+	}
+	ec := newEvaluationContext(board, rootSnakeIndex)
+	modules = activeModules(ec, modules)
+	for i, score := range runModules(ec, modules) {
+		breakdown[modules[i].Name] = score
+	}
+	return breakdown
+}
+
+// territoryShares returns the share of the board each living snake controls.
+func territoryShares(board Board) map[string]float64 {
+	shares := make(map[string]float64, len(board.Snakes))
+	total := float64(board.Width * board.Height)
+	for _, row := range GenerateVoronoi(board) {
+		for _, owner := range row {
+			if owner >= 0 && owner < len(board.Snakes) {
+				shares[board.Snakes[owner].ID] += 1 / total
+			}
+		}
+	}
+	return shares
+}
+
+func nearWall(board Board, p Point) bool {
+	return p.X <= 1 || p.Y <= 1 || p.X >= board.Width-2 || p.Y >= board.Height-2
+}
+
+// Comment describes how last turn's move played out from our snake's point of view (index 0), now that
+// the opponents have replied. It returns an empty string when nothing changed enough to be worth saying.
+func (c *Commentator) Comment(record DecisionRecord, board Board) string {
+	snapshot := &commentarySnapshot{
+		turn:      record.Turn,
+		move:      record.Move,
+		evals:     evaluationBreakdown(board, 0, modules),
+		total:     evaluateBoard(board, 0, modules),
+		territory: territoryShares(board),
+	}
+
+	c.mu.Lock()
+	prev := c.prev
+	c.prev = snapshot
+	c.mu.Unlock()
+
+	if prev == nil || prev.turn != record.Turn-1 {
+		return ""
+	}
+
+	var phrases []string
+
+	// who lost the most ground since last turn
+	var boxed *Snake
+	worstLoss := 0.0
+	for i := 1; i < len(board.Snakes); i++ {
+		snake := board.Snakes[i]
+		if isSnakeDead(snake) {
+			continue
+		}
+		loss := prev.territory[snake.ID] - snapshot.territory[snake.ID]
+		if loss >= commentaryBoxedShare && loss > worstLoss {
+			boxed, worstLoss = &board.Snakes[i], loss
+		}
+	}
+	if boxed != nil {
+		if nearWall(board, boxed.Head) {
+			phrases = append(phrases, fmt.Sprintf("boxed %s toward the wall", boxed.Name))
+		} else {
+			phrases = append(phrases, fmt.Sprintf("squeezed %s", boxed.Name))
+		}
+	}
+
+	territory := snapshot.evals["voronoi"] - prev.evals["voronoi"]
+	length := snapshot.evals["length"] - prev.evals["length"]
+	switch {
+	case territory >= commentaryEvalDelta && length <= -commentaryEvalDelta:
+		phrases = append(phrases, "trading food for territory")
+	case length >= commentaryEvalDelta && territory <= -commentaryEvalDelta:
+		phrases = append(phrases, "trading territory for food")
+	case territory >= commentaryEvalDelta:
+		phrases = append(phrases, "taking space")
+	case length >= commentaryEvalDelta:
+		phrases = append(phrases, "growing")
+	}
+
+	if prev.total-snapshot.total >= commentaryTroubleDelta {
+		phrases = append(phrases, "and it's looking grim")
+	}
+
+	if len(phrases) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("turn %d: went %s, %s", prev.turn, prev.move, strings.Join(phrases, ", "))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommentatorBoxedToWall(t *testing.T) {
+	before := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "us", Name: "Gregory", Health: 90, Head: Point{X: 3, Y: 5}, Body: []Point{{X: 3, Y: 5}, {X: 3, Y: 4}, {X: 3, Y: 3}, {X: 3, Y: 2}}},
+			{ID: "soba", Name: "soba", Health: 90, Head: Point{X: 1, Y: 6}, Body: []Point{{X: 1, Y: 6}, {X: 1, Y: 5}, {X: 1, Y: 4}, {X: 1, Y: 3}}},
+		},
+	}
+	// we cut across above them, they carry on up the wall
+	after := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "us", Name: "Gregory", Health: 89, Head: Point{X: 2, Y: 6}, Body: []Point{{X: 2, Y: 6}, {X: 3, Y: 6}, {X: 3, Y: 5}, {X: 3, Y: 4}}},
+			{ID: "soba", Name: "soba", Health: 89, Head: Point{X: 1, Y: 7}, Body: []Point{{X: 1, Y: 7}, {X: 1, Y: 6}, {X: 1, Y: 5}, {X: 1, Y: 4}}},
+		},
+	}
+
+	commentator := &Commentator{}
+	assert.Empty(t, commentator.Comment(DecisionRecord{Turn: 10, Move: "up"}, before), "nothing to compare to yet")
+	line := commentator.Comment(DecisionRecord{Turn: 11, Move: "left"}, after)
+	assert.Contains(t, line, "turn 10: went up")
+	assert.Contains(t, line, "boxed soba toward the wall")
+}
+
+func TestCommentatorQuietTurn(t *testing.T) {
+	board := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "us", Name: "Gregory", Health: 90, Head: Point{X: 3, Y: 5}, Body: []Point{{X: 3, Y: 5}, {X: 3, Y: 4}, {X: 3, Y: 3}}},
+			{ID: "soba", Name: "soba", Health: 90, Head: Point{X: 8, Y: 5}, Body: []Point{{X: 8, Y: 5}, {X: 8, Y: 4}, {X: 8, Y: 3}}},
+		},
+	}
+	moved := copyBoard(board)
+	applyMove(&moved, 0, Up)
+	applyMove(&moved, 1, Up)
+
+	commentator := &Commentator{}
+	commentator.Comment(DecisionRecord{Turn: 1, Move: "up"}, board)
+	assert.Empty(t, commentator.Comment(DecisionRecord{Turn: 2, Move: "up"}, moved))
+
+	// a skipped turn isn't compared against
+	assert.Empty(t, commentator.Comment(DecisionRecord{Turn: 9, Move: "up"}, board))
+}
+
+func TestEvaluationBreakdown(t *testing.T) {
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "us", Health: 90, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+			{ID: "them", Health: 90, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+		},
+	}
+	breakdown := evaluationBreakdown(board, 0, modules)
+	assert.Len(t, breakdown, len(activeModules(newEvaluationContext(board, 0), modules)))
+	assert.NotContains(t, breakdown, "forecast", "only in royale")
+	assert.Equal(t, lengthEvaluation(newEvaluationContext(board, 0)), breakdown["length"])
+	assert.Equal(t, voronoiEvaluation(newEvaluationContext(board, 0)), breakdown["voronoi"])
+	assert.Empty(t, evaluationBreakdown(board, 5, modules))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// coordinates are easy to get backwards: the engine has y going up from the bottom, images have it going
+// down from the top, and the ascii board has a border round it. A board that isn't square catches x and y
+// being swapped too.
+const (
+	coordWidth  = 9
+	coordHeight = 6
+)
+
+// coordPoints are the corners plus a couple of cells that aren't symmetric about anything.
+var coordPoints = []Point{
+	{X: 0, Y: 0},
+	{X: coordWidth - 1, Y: 0},
+	{X: 0, Y: coordHeight - 1},
+	{X: coordWidth - 1, Y: coordHeight - 1},
+	{X: 2, Y: 4},
+	{X: 6, Y: 1},
+}
+
+// parseVisualizedBoard reads visualizeBoard's output back into engine coordinates.
+func parseVisualizedBoard(t *testing.T, visual string, width, height int) map[Point]rune {
+	t.Helper()
+	var rows [][]rune
+	for _, line := range strings.Split(strings.TrimRight(visual, "\n"), "\n") {
+		var row []rune
+		for _, cell := range strings.Split(strings.TrimRight(line, " "), "  ") {
+			row = append(row, []rune(cell)[0])
+		}
+		rows = append(rows, row)
+	}
+	require.Len(t, rows, height+2, "a row of border above and below")
+
+	cells := make(map[Point]rune)
+	for r, row := range rows {
+		require.Len(t, row, width+2, "a column of border either side")
+		for c, cell := range row {
+			if r == 0 || r == height+1 || c == 0 || c == width+1 {
+				require.Equal(t, 'x', cell, "border at row %d column %d", r, c)
+				continue
+			}
+			if cell != '.' {
+				cells[Point{X: c - 1, Y: height - r}] = cell
+			}
+		}
+	}
+	return cells
+}
+
+func TestVisualizeBoardCoordinates(t *testing.T) {
+	for _, p := range coordPoints {
+		board := Board{Width: coordWidth, Height: coordHeight, Food: []Point{p}}
+		cells := parseVisualizedBoard(t, visualizeBoard(board), coordWidth, coordHeight)
+		assert.Equal(t, map[Point]rune{p: '♥'}, cells, "food at %v", p)
+	}
+
+	board := Board{
+		Width:  coordWidth,
+		Height: coordHeight,
+		Snakes: []Snake{{ID: "a", Head: Point{X: 2, Y: 1}, Body: []Point{{X: 2, Y: 1}, {X: 2, Y: 0}, {X: 3, Y: 0}}}},
+	}
+	cells := parseVisualizedBoard(t, visualizeBoard(board), coordWidth, coordHeight)
+	assert.Equal(t, map[Point]rune{{X: 2, Y: 1}: 'A', {X: 2, Y: 0}: 'a', {X: 3, Y: 0}: 'a'}, cells)
+}
+
+func TestVisualizeBoardMovesAgreeWithEngine(t *testing.T) {
+	head := Point{X: 4, Y: 2}
+	board := Board{
+		Width:  coordWidth,
+		Height: coordHeight,
+		Snakes: []Snake{{ID: "a", Head: head, Body: []Point{head}}},
+	}
+	arrows := map[Direction]rune{Up: '↑', Down: '↓', Left: '←', Right: '→'}
+	for _, direction := range AllDirections {
+		visual := visualizeBoard(board, WithMove(direction, 0))
+		// the first line names the move, the board follows
+		lines := strings.SplitN(visual, "\n", 2)
+		cells := parseVisualizedBoard(t, lines[1], coordWidth, coordHeight)
+
+		next := moveHead(head, direction)
+		assert.Equal(t, arrows[direction], cells[next], "%v drawn where the engine moves", direction)
+		assert.Equal(t, next, moveInDirection(head, direction))
+		assert.Equal(t, []string{"up", "down", "left", "right"}[direction-Up], determineMoveDirection(head, next))
+	}
+}
+
+func TestEngineEdgesMatchVisualOrientation(t *testing.T) {
+	board := Board{Width: coordWidth, Height: coordHeight}
+	testCases := []struct {
+		From      Point
+		Direction Direction
+	}{
+		{From: Point{X: 3, Y: coordHeight - 1}, Direction: Up},
+		{From: Point{X: 3, Y: 0}, Direction: Down},
+		{From: Point{X: 0, Y: 3}, Direction: Left},
+		{From: Point{X: coordWidth - 1, Y: 3}, Direction: Right},
+	}
+	for _, tc := range testCases {
+		next := moveHead(tc.From, tc.Direction)
+		assert.False(t, isPointInsideBoard(&board, next), "%v from %v leaves the board", tc.Direction, tc.From)
+	}
+}
+
+func TestWrappedEdgesComeBackOpposite(t *testing.T) {
+	board := Board{Width: coordWidth, Height: coordHeight, Wrapped: true}
+	testCases := []struct {
+		From      Point
+		Direction Direction
+		To        Point
+	}{
+		{From: Point{X: 3, Y: coordHeight - 1}, Direction: Up, To: Point{X: 3, Y: 0}},
+		{From: Point{X: 3, Y: 0}, Direction: Down, To: Point{X: 3, Y: coordHeight - 1}},
+		{From: Point{X: 0, Y: 3}, Direction: Left, To: Point{X: coordWidth - 1, Y: 3}},
+		{From: Point{X: coordWidth - 1, Y: 3}, Direction: Right, To: Point{X: 0, Y: 3}},
+		{From: Point{X: 3, Y: 3}, Direction: Right, To: Point{X: 4, Y: 3}},
+	}
+	for _, tc := range testCases {
+		next := moveOnBoard(&board, tc.From, tc.Direction)
+		assert.Equal(t, tc.To, next, "%v from %v", tc.Direction, tc.From)
+		assert.True(t, isPointInsideBoard(&board, next))
+		assert.Equal(t, tc.Direction, directionTo(&board, tc.From, next))
+		assert.Equal(t, []string{"up", "down", "left", "right"}[tc.Direction-Up], determineMoveDirection(tc.From, next))
+	}
+
+	// the same step off a board that doesn't wrap still leaves it
+	board.Wrapped = false
+	assert.False(t, isPointInsideBoard(&board, moveOnBoard(&board, Point{X: 0, Y: 3}, Left)))
+}
+
+func TestVisualizeWrappedEdges(t *testing.T) {
+	head := Point{X: coordWidth - 1, Y: 2}
+	board := Board{
+		Width:   coordWidth,
+		Height:  coordHeight,
+		Wrapped: true,
+		Snakes:  []Snake{{ID: "a", Head: head, Body: []Point{head}}},
+	}
+	visual := visualizeBoard(board, WithMove(Right, 0))
+	lines := strings.SplitN(visual, "\n", 2)
+	assert.NotContains(t, lines[1], "x", "a wrapped board has no walls")
+	cells := parseVisualizedBoard(t, strings.ReplaceAll(lines[1], "~", "x"), coordWidth, coordHeight)
+	assert.Equal(t, '→', cells[Point{X: 0, Y: 2}], "the arrow comes back on the far side")
+}
+
+// tidbytPixel is the middle pixel of the cell on the tidbyt render.
+func tidbytPixel(board Board, p Point) (int, int) {
+	offsetX := canvasWidth - board.Width*3
+	return offsetX + p.X*3 + 1, (board.Height-1-p.Y)*3 + 1
+}
+
+// hiResPixel is the middle pixel of the cell on the discord render.
+func hiResPixel(board Board, p Point) (int, int) {
+	return p.X*hiResCellSize + hiResCellSize/2, (board.Height-1-p.Y)*hiResCellSize + hiResCellSize/2
+}
+
+func TestRenderCoordinates(t *testing.T) {
+	green := color.RGBA{0, 255, 0, 255}
+	for _, p := range coordPoints {
+		board := Board{Width: coordWidth, Height: coordHeight, Food: []Point{p}}
+		palette := newSnakePalette(&board)
+
+		img, _ := renderBoardToImage(&board, palette)
+		x, y := tidbytPixel(board, p)
+		assert.Equal(t, green, img.RGBAAt(x, y), "tidbyt food at %v", p)
+		// and nowhere else on the board
+		for _, other := range coordPoints {
+			if other != p {
+				ox, oy := tidbytPixel(board, other)
+				assert.NotEqual(t, green, img.RGBAAt(ox, oy), "tidbyt food at %v drawn at %v", p, other)
+			}
+		}
+
+		hires := renderBoardHighRes(&board, palette)
+		x, y = hiResPixel(board, p)
+		assert.Equal(t, green, hires.RGBAAt(x, y), "high res food at %v", p)
+	}
+}
+
+func TestRenderSnakeHeadMatchesEngineHead(t *testing.T) {
+	board := Board{
+		Width:  coordWidth,
+		Height: coordHeight,
+		Snakes: []Snake{{ID: "a", Head: Point{X: 6, Y: 4}, Body: []Point{{X: 6, Y: 4}, {X: 6, Y: 3}, {X: 5, Y: 3}}}},
+	}
+	palette := newSnakePalette(&board)
+	head := palette.Head("a")
+
+	img, _ := renderBoardToImage(&board, palette)
+	hires := renderBoardHighRes(&board, palette)
+	for _, p := range board.Snakes[0].Body {
+		x, y := tidbytPixel(board, p)
+		hx, hy := hiResPixel(board, p)
+		if p == board.Snakes[0].Head {
+			assert.Equal(t, head, img.RGBAAt(x, y), "tidbyt head")
+			assert.Equal(t, head, hires.RGBAAt(hx, hy), "high res head")
+			continue
+		}
+		assert.NotEqual(t, head, img.RGBAAt(x, y), "tidbyt body at %v", p)
+		assert.NotEqual(t, head, hires.RGBAAt(hx, hy), "high res body at %v", p)
+	}
+
+	// moving up on the engine's board moves the head up the image
+	next := copyBoard(board)
+	applyMove(&next, 0, Up)
+	img, _ = renderBoardToImage(&next, palette)
+	x, y := tidbytPixel(next, next.Snakes[0].Head)
+	_, before := tidbytPixel(board, board.Snakes[0].Head)
+	assert.Less(t, y, before)
+	assert.Equal(t, head, img.RGBAAt(x, y))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// tells cloud error reporting to pick up the log entry even though it isn't a crash of the process
+	errorReportingType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+	serviceName        = "battlesnake-server"
+)
+
+// CrashReport is the reproducer stored when a search worker panics.
+type CrashReport struct {
+	GameID     string    `json:"game_id"`
+	Panic      string    `json:"panic"`
+	Stack      string    `json:"stack"`
+	SnakeIndex int       `json:"snake_index"`
+	Board      *Board    `json:"board,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// newCrashReport captures the panic value and the node being worked on when it happened.
+func newCrashReport(gameID string, node *Node, recovered interface{}, stack []byte) CrashReport {
+	report := CrashReport{
+		GameID:     gameID,
+		Panic:      fmt.Sprint(recovered),
+		Stack:      string(stack),
+		SnakeIndex: -1,
+		Time:       time.Now(),
+	}
+	if node != nil {
+		board := copyBoard(node.Board)
+		report.Board = &board
+		report.SnakeIndex = node.SnakeIndex
+	}
+	return report
+}
+
+// ObjectName is where the reproducer is stored in the archive bucket.
+func (c CrashReport) ObjectName() string {
+	return fmt.Sprintf("crashes/%s/%s.json", c.GameID, c.Time.Format("20060102_150405.000000"))
+}
+
+// reportPanic sends the panic to error reporting and archives the offending board.
+// It must be called from a deferred function with the value returned by recover.
+func reportPanic(gameID string, node *Node, recovered interface{}) {
+	report := newCrashReport(gameID, node, recovered, debug.Stack())
+
+	// error reporting parses the go stack out of the message, so it has to start with the panic
+	slog.Error(fmt.Sprintf("panic: %s\n\n%s", report.Panic, report.Stack),
+		"@type", errorReportingType,
+		"serviceContext", map[string]string{"service": serviceName},
+		"game_id", gameID,
+		"snake_index", report.SnakeIndex,
+		"board", report.Board,
+	)
+
+	go func() {
+		data, err := json.Marshal(report)
+		if err != nil {
+			slog.Error("failed to marshal crash report", "error", err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = uploadToBucket(ctx, report.ObjectName(), "application/json", data)
+		if err != nil {
+			slog.Error("failed to archive crash report", "error", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCrashReport(t *testing.T) {
+	board := Board{
+		Height: 5,
+		Width:  5,
+		Snakes: []Snake{
+			{ID: "snake1", Head: Point{X: 1, Y: 1}, Health: 100, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}}},
+		},
+	}
+	node := &Node{Board: board, SnakeIndex: 0}
+
+	report := newCrashReport("game1", node, "index out of range", []byte("goroutine 1 [running]:"))
+
+	assert.Equal(t, "game1", report.GameID)
+	assert.Equal(t, "index out of range", report.Panic)
+	assert.Equal(t, 0, report.SnakeIndex)
+	if assert.NotNil(t, report.Board) {
+		assert.Equal(t, board, *report.Board)
+	}
+	assert.True(t, strings.HasPrefix(report.ObjectName(), "crashes/game1/"))
+
+	// the reproducer must not share memory with the live tree
+	node.Board.Snakes[0].Body[0] = Point{X: 4, Y: 4}
+	assert.Equal(t, Point{X: 1, Y: 1}, report.Board.Snakes[0].Body[0])
+}
+
+func TestNewCrashReportWithoutNode(t *testing.T) {
+	report := newCrashReport("game1", nil, "boom", nil)
+	assert.Nil(t, report.Board)
+	assert.Equal(t, -1, report.SnakeIndex)
+}
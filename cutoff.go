@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// cutoffSampleInterval is how often the search checks whether its best move can still be caught
+	cutoffSampleInterval = 10 * time.Millisecond
+	// cutoffMinSearch is how long the search runs before it can be cut off, so the visit rate is worth trusting
+	cutoffMinSearch = 50 * time.Millisecond
+)
+
+// EarlyCutoff ends a search as soon as the most visited move at the root can't be overtaken in the time
+// left. Past that point the rest of the budget can't change the answer, so it's better off in the bank.
+type EarlyCutoff struct {
+	stopped int32
+	saved   int64 // nanoseconds left before the deadline when the search was stopped
+}
+
+// Stopped says whether the search was cut off before its deadline.
+func (c *EarlyCutoff) Stopped() bool {
+	return c != nil && atomic.LoadInt32(&c.stopped) == 1
+}
+
+// Saved is how much of the search's time was left when it was cut off.
+func (c *EarlyCutoff) Saved() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&c.saved))
+}
+
+// watch samples the root until the context is done, calling stop once the visits the search could still
+// make at its current rate wouldn't be enough for the runner up to catch the best move.
+func (c *EarlyCutoff) watch(ctx context.Context, stop context.CancelFunc, root *Node) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(cutoffSampleInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	startVisits := atomic.LoadInt64(&root.Visits)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if elapsed < cutoffMinSearch {
+				continue
+			}
+			remaining := deadline.Sub(now)
+			if remaining <= 0 {
+				return
+			}
+			rate := float64(atomic.LoadInt64(&root.Visits)-startVisits) / elapsed.Seconds()
+			if !unassailable(root, int64(rate*remaining.Seconds())) {
+				continue
+			}
+			atomic.StoreInt64(&c.saved, int64(remaining))
+			atomic.StoreInt32(&c.stopped, 1)
+			slog.Debug("search cut off early", "remaining_ms", remaining.Milliseconds(), "visits", atomic.LoadInt64(&root.Visits))
+			stop()
+			return
+		}
+	}
+}
+
+// unassailable says whether the most visited child of the root would still be ahead if every one of the
+// remaining visits went to the runner up. A move nobody has expanded yet counts as a runner up with none.
+func unassailable(root *Node, remaining int64) bool {
+	var best, second int64
+	for _, child := range root.Children() {
+		visits := atomic.LoadInt64(&child.Visits)
+		if visits > best {
+			best, second = visits, best
+		} else if visits > second {
+			second = visits
+		}
+	}
+	return best > 0 && best-second > remaining
+}
+
+// WithEarlyCutoff has the search stop early once its best move is settled. The workers of a root parallel
+// search each grow their own tree, so the root can't tell which move is ahead until they're merged and
+// the cutoff is left out.
+func WithEarlyCutoff(cutoff *EarlyCutoff) func(*searchOptions) {
+	return func(o *searchOptions) {
+		o.cutoff = cutoff
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnassailable(t *testing.T) {
+	root := &Node{}
+	assert.False(t, unassailable(root, 0), "nothing searched, nothing settled")
+
+	root.setChildren([]*Node{{Visits: 100}, {Visits: 40}, {Visits: 10}})
+	assert.True(t, unassailable(root, 59))
+	assert.False(t, unassailable(root, 60), "the runner up could draw level")
+
+	root.setChildren([]*Node{{Visits: 5}, {Visits: 90}})
+	assert.True(t, unassailable(root, 84))
+	assert.False(t, unassailable(root, 85))
+}
+
+func TestEarlyCutoffStopsSettledSearch(t *testing.T) {
+	var none *EarlyCutoff
+	assert.False(t, none.Stopped())
+	assert.Zero(t, none.Saved())
+
+	// one move has all the visits and keeps getting more than the deadline leaves for the other
+	root := &Node{}
+	best := &Node{Visits: 1000}
+	root.setChildren([]*Node{best, {Visits: 1}})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	go func() {
+		for ctx.Err() == nil {
+			atomic.AddInt64(&root.Visits, 1)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	cutoff := &EarlyCutoff{}
+	cutoff.watch(ctx, stop, root)
+	assert.True(t, cutoff.Stopped())
+	assert.Greater(t, cutoff.Saved(), time.Second)
+	assert.Error(t, ctx.Err(), "the search was told to stop")
+}
+
+// feedVisits adds visits to the root at a steady rate until the duration is up.
+func feedVisits(root *Node, duration time.Duration) {
+	end := time.Now().Add(duration)
+	for time.Now().Before(end) {
+		atomic.AddInt64(&root.Visits, 10)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEarlyCutoffWaitsForClosePositions(t *testing.T) {
+	root := &Node{}
+	root.setChildren([]*Node{{Visits: 50}, {Visits: 49}})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	go feedVisits(root, 100*time.Millisecond)
+
+	cutoff := &EarlyCutoff{}
+	cutoff.watch(ctx, cancel, root)
+	assert.False(t, cutoff.Stopped())
+	assert.Zero(t, cutoff.Saved())
+}
+
+func TestMCTSEarlyCutoff(t *testing.T) {
+	// last turn's search already settled on a move, far beyond what this one could add to the others
+	board := evalTestBoard()
+	root := NewNode(copyBoard(board), -1, nil)
+	move, ok := root.claimMove()
+	require.True(t, ok)
+	next := copyBoard(board)
+	applyMove(&next, 0, move)
+	settled := NewNode(next, 0, root)
+	settled.Visits = 1 << 40
+	root.addChild(settled)
+	root.Visits = settled.Visits
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	cutoff := &EarlyCutoff{}
+	result := MCTS(ctx, "test", board, 1<<62, 2, map[string]*Node{boardHash(board): root}, WithEarlyCutoff(cutoff))
+	require.True(t, cutoff.Stopped())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Greater(t, result.Visits, settled.Visits-1)
+
+	// a root parallel search can't see its workers' trees until they're merged, so it runs to the deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	parallel := &EarlyCutoff{}
+	MCTS(ctx, "test", board, 1<<62, 2, map[string]*Node{boardHash(board): root}, WithEarlyCutoff(parallel), WithSearchConfig(SearchConfig{Parallel: ParallelRoot}))
+	assert.False(t, parallel.Stopped())
+}
@@ -0,0 +1,105 @@
+package main
+
+const (
+	// dangerValueMargin is how much average value we'll give up for a line with less danger along it
+	dangerValueMargin = 0.05
+	// dangerVisitShare is the share of the most visited move's visits an alternative needs before its value is trusted
+	dangerVisitShare = 0.5
+)
+
+// dangerousReplies counts the moves open to the mover that would leave us, snake 0, dead or down to a single
+// safe move, so that if we misplay the reply we die.
+func dangerousReplies(board Board, mover int) int {
+	if mover <= 0 || mover >= len(board.Snakes) || isSnakeDead(board.Snakes[0]) || isSnakeDead(board.Snakes[mover]) {
+		return 0
+	}
+
+	replies := generateSafeMoves(board, mover)
+	if len(replies) == 0 {
+		replies = AllDirections
+	}
+	dangerous := 0
+	for _, reply := range replies {
+		next := copyBoard(board)
+		applyMove(&next, mover, reply)
+		if isSnakeDead(next.Snakes[0]) || len(generateSafeMoves(next, 0)) < 2 {
+			dangerous++
+		}
+	}
+	return dangerous
+}
+
+// pvDanger counts the dangerous opponent replies at each node along the line. Nodes where we're the next to move count zero.
+func pvDanger(line []*Node) []int {
+	danger := make([]int, len(line))
+	for i, node := range line {
+		if len(node.Board.Snakes) == 0 {
+			continue
+		}
+		mover := (node.SnakeIndex + 1) % len(node.Board.Snakes)
+		danger[i] = dangerousReplies(node.Board, mover)
+	}
+	return danger
+}
+
+// dangerExposure is the number of dangerous replies along the line, all plies together.
+func dangerExposure(line []*Node) int {
+	total := 0
+	for _, danger := range pvDanger(line) {
+		total += danger
+	}
+	return total
+}
+
+// RootChoice is the move picked at the root and how exposed the line it expects is.
+type RootChoice struct {
+	Node        *Node
+	Danger      int  // dangerous replies along the picked move's principal variation
+	MostVisited int  // the same for the most visited move
+	Safer       bool // whether a safer move was picked over the most visited one
+}
+
+// chooseRootChild picks the most visited move, unless it threads needles and another move with nearly as many
+// visits and nearly the same value has fewer dangerous replies along its principal variation.
+func chooseRootChild(root *Node) RootChoice {
+	return chooseRootChildWithin(root, dangerValueMargin)
+}
+
+// chooseRootChildWithin is chooseRootChild giving up at most the margin of value for a safer line.
+func chooseRootChildWithin(root *Node, margin float64) RootChoice {
+	var best *Node
+	for _, child := range root.Children() {
+		if best == nil || child.Visits > best.Visits {
+			best = child
+		}
+	}
+	if best == nil || best.Visits == 0 {
+		return RootChoice{Node: best}
+	}
+
+	exposure := func(child *Node) int {
+		return dangerExposure(append([]*Node{child}, principalVariation(child)...))
+	}
+	choice := RootChoice{Node: best, Danger: exposure(best)}
+	choice.MostVisited = choice.Danger
+	if choice.Danger == 0 {
+		return choice
+	}
+
+	bestValue := best.Score / float64(best.Visits)
+	for _, child := range root.Children() {
+		if child == best || float64(child.Visits) < dangerVisitShare*float64(best.Visits) {
+			continue
+		}
+		if child.Score/float64(child.Visits) < bestValue-margin {
+			continue
+		}
+		danger := exposure(child)
+		if danger < choice.Danger || (danger == choice.Danger && choice.Safer && child.Visits > choice.Node.Visits) {
+			choice.Node = child
+			choice.Danger = danger
+			choice.Safer = true
+		}
+	}
+	return choice
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cornerBoard has us in the bottom left corner heading down, with a longer snake able to reach (1,0)
+// and cut off our only way out.
+func cornerBoard() Board {
+	return Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 0, Y: 0}, Health: 100, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}}},
+			{ID: "them", Head: Point{X: 2, Y: 0}, Health: 100, Body: []Point{{X: 2, Y: 0}, {X: 3, Y: 0}, {X: 4, Y: 0}, {X: 5, Y: 0}}},
+		},
+	}
+}
+
+func TestDangerousReplies(t *testing.T) {
+	open := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 5, Y: 5}, Health: 100, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+			{ID: "them", Head: Point{X: 1, Y: 9}, Health: 100, Body: []Point{{X: 1, Y: 9}, {X: 1, Y: 10}, {X: 2, Y: 10}}},
+		},
+	}
+
+	testCases := []struct {
+		Description string
+		Board       Board
+		Mover       int
+		Want        int
+	}{
+		{Description: "far apart", Board: open, Mover: 1, Want: 0},
+		{Description: "our own move", Board: cornerBoard(), Mover: 0, Want: 0},
+		// going up leaves us one way out and going left takes that away too
+		{Description: "cornered", Board: cornerBoard(), Mover: 1, Want: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			before := copyBoard(tc.Board)
+			assert.Equal(t, tc.Want, dangerousReplies(tc.Board, tc.Mover))
+			assert.Equal(t, before, tc.Board, "counting replies mustn't change the board")
+		})
+	}
+}
+
+func TestPVDangerOnlyCountsOpponentReplies(t *testing.T) {
+	board := cornerBoard()
+	ours := &Node{Board: board, SnakeIndex: 0}   // we just moved, they're next
+	theirs := &Node{Board: board, SnakeIndex: 1} // they just moved, we're next
+
+	assert.Equal(t, []int{2, 0}, pvDanger([]*Node{ours, theirs}))
+	assert.Equal(t, 2, dangerExposure([]*Node{ours, theirs}))
+	assert.Empty(t, pvDanger(nil))
+}
+
+func TestChooseRootChild(t *testing.T) {
+	safe := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 5, Y: 5}, Health: 100, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+			{ID: "them", Head: Point{X: 1, Y: 9}, Health: 100, Body: []Point{{X: 1, Y: 9}, {X: 1, Y: 10}, {X: 2, Y: 10}}},
+		},
+	}
+
+	testCases := []struct {
+		Description string
+		RiskyVisits int64
+		RiskyValue  float64
+		SafeVisits  int64
+		SafeValue   float64
+		WantSafe    bool
+	}{
+		{Description: "safer line about as good", RiskyVisits: 100, RiskyValue: 0.5, SafeVisits: 80, SafeValue: 0.48, WantSafe: true},
+		{Description: "safer line clearly worse", RiskyVisits: 100, RiskyValue: 0.5, SafeVisits: 80, SafeValue: 0.3},
+		{Description: "safer line barely searched", RiskyVisits: 100, RiskyValue: 0.5, SafeVisits: 20, SafeValue: 0.6},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			root := &Node{}
+			risky := &Node{Board: cornerBoard(), SnakeIndex: 0, Parent: root, Visits: tc.RiskyVisits, Score: tc.RiskyValue * float64(tc.RiskyVisits)}
+			calm := &Node{Board: safe, SnakeIndex: 0, Parent: root, Visits: tc.SafeVisits, Score: tc.SafeValue * float64(tc.SafeVisits)}
+			root.setChildren([]*Node{risky, calm})
+
+			choice := chooseRootChild(root)
+
+			assert.Equal(t, 2, choice.MostVisited)
+			assert.Equal(t, tc.WantSafe, choice.Safer)
+			if tc.WantSafe {
+				assert.Same(t, calm, choice.Node)
+				assert.Zero(t, choice.Danger)
+				return
+			}
+			assert.Same(t, risky, choice.Node)
+			assert.Equal(t, 2, choice.Danger)
+		})
+	}
+}
+
+func TestChooseRootChildWithoutSearch(t *testing.T) {
+	root := NewNode(cornerBoard(), -1, nil)
+	require.Nil(t, chooseRootChild(root).Node)
+	assert.Equal(t, "right", determineBestMove(root))
+}
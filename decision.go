@@ -0,0 +1,79 @@
+package main
+
+// DecisionRecord captures what the search saw when it picked a move.
+type DecisionRecord struct {
+	GameID     string          `json:"game_id"`
+	Turn       int             `json:"turn"`
+	Move       string          `json:"move"`
+	Visits     int64           `json:"visits"`
+	Entropy    float64         `json:"entropy"`
+	MaxDepth   int             `json:"max_depth"`
+	PVDepth    int             `json:"pv_depth"`
+	DurationMs int64           `json:"duration_ms"`
+	BudgetMs   int64           `json:"budget_ms"`   // time the search was given, zero when the move was answered without one
+	Plan       bool            `json:"plan"`        // whether the search was biased towards last turn's plan
+	WarmVisits int64           `json:"warm_visits"` // visits the root already had from last turn's search
+	Throttled  bool            `json:"throttled"`   // whether the search was degraded because the cpu was throttled
+	Danger     int             `json:"danger"`      // opponent replies along the expected line that would leave us dead or with one way out
+	SaferMove  bool            `json:"safer_move"`  // whether the most visited move was passed over for one with less danger
+	Forced     bool            `json:"forced"`      // whether we only had one safe move and answered without searching
+	TailChase  bool            `json:"tail_chase"`  // whether we'd won the starvation race and kept going round our loop without searching
+	BorrowedMs int64           `json:"borrowed_ms"` // time drawn from the bank built up on forced turns
+	Workers    int             `json:"workers"`     // fewest workers the scheduler gave the search while sharing with other games
+	Prefetched int64           `json:"prefetched"`  // visits the root got from searching ahead before the request came in
+	Engine     string          `json:"engine"`      // build that made the decision
+	Source     MoveSource      `json:"source"`      // whether the move was safe, a backup or a random guess
+	Opponents  []OpponentStyle `json:"opponents"`   // how each snake was searched going by its reply times, us first
+	Value      float64         `json:"value"`       // average score of the move we picked, ours to keep
+	Parallel   string          `json:"parallel"`    // whether the workers shared a tree or grew their own from the root
+	EarlyStop  bool            `json:"early_stop"`  // whether the search stopped before its deadline because the best move couldn't be caught
+	Solved     bool            `json:"solved"`      // whether the endgame solver proved a win and answered without searching
+	Algorithm  string          `json:"algorithm"`   // the engine that picked the move, see SearchEngine
+	Stats      *MoveStats      `json:"stats,omitempty"`
+	TreeURL    string          `json:"tree_url,omitempty"` // where the search tree behind the move is served, when it was exported
+}
+
+// principalVariation follows the most visited child from the node down to a leaf. The outcomes under a
+// chance node aren't moves, so the line goes through the most rolled one without listing it.
+func principalVariation(node *Node) []*Node {
+	var pv []*Node
+	for node != nil {
+		node.mutex.Lock()
+		var next *Node
+		for _, child := range node.Children() {
+			if next == nil || child.Visits > next.Visits {
+				next = child
+			}
+		}
+		node.mutex.Unlock()
+
+		if next == nil || next.Visits == 0 {
+			break
+		}
+		if !node.chance {
+			pv = append(pv, next)
+		}
+		node = next
+	}
+	return pv
+}
+
+// maxTreeDepth returns the depth of the deepest expanded node beneath the node.
+func maxTreeDepth(node *Node) int {
+	if node == nil {
+		return 0
+	}
+
+	node.mutex.Lock()
+	children := append([]*Node(nil), node.Children()...)
+	node.mutex.Unlock()
+
+	deepest := 0
+	for _, child := range children {
+		depth := 1 + maxTreeDepth(child)
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+	return deepest
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalVariationAndDepth(t *testing.T) {
+	// root -> a (10) -> c (6) -> e (1)
+	//      -> b (3)  -> d (2) -> f (1) -> g (1)
+	root := &Node{Visits: 14}
+	a := &Node{Visits: 10, Parent: root}
+	b := &Node{Visits: 3, Parent: root}
+	c := &Node{Visits: 6, Parent: a}
+	d := &Node{Visits: 2, Parent: b}
+	e := &Node{Visits: 1, Parent: c}
+	f := &Node{Visits: 1, Parent: d}
+	g := &Node{Visits: 1, Parent: f}
+	root.setChildren([]*Node{a, b})
+	a.setChildren([]*Node{c})
+	b.setChildren([]*Node{d})
+	c.setChildren([]*Node{e})
+	d.setChildren([]*Node{f})
+	f.setChildren([]*Node{g})
+
+	assert.Equal(t, []*Node{a, c, e}, principalVariation(root))
+	assert.Equal(t, 4, maxTreeDepth(root))
+	assert.Equal(t, 0, maxTreeDepth(g))
+}
+
+func TestPrincipalVariationStopsAtUnvisited(t *testing.T) {
+	root := &Node{Visits: 1}
+	root.setChildren([]*Node{{Parent: root}})
+
+	assert.Empty(t, principalVariation(root))
+	assert.Equal(t, 1, maxTreeDepth(root))
+}
@@ -0,0 +1,100 @@
+package main
+
+import "sync"
+
+// SnakeDelta describes what happened to a single snake between two boards.
+type SnakeDelta struct {
+	ID     string    `json:"id"`
+	Move   Direction `json:"move"` // Unset if the snake died or the move couldn't be inferred
+	Ate    bool      `json:"ate"`
+	Died   bool      `json:"died"`
+	Health int       `json:"health"`
+	Length int       `json:"length"`
+}
+
+// BoardDiff is the structured delta between two consecutive boards.
+type BoardDiff struct {
+	Snakes      []SnakeDelta `json:"snakes"`
+	FoodEaten   []Point      `json:"food_eaten"`
+	FoodSpawned []Point      `json:"food_spawned"`
+	Deaths      []string     `json:"deaths"`
+}
+
+// Snake returns the delta for the snake with the given id.
+func (d BoardDiff) Snake(id string) (SnakeDelta, bool) {
+	for _, delta := range d.Snakes {
+		if delta.ID == id {
+			return delta, true
+		}
+	}
+	return SnakeDelta{}, false
+}
+
+// DiffBoards infers what happened between two boards a turn apart. Snakes are matched by id,
+// so the order of snakes on the boards doesn't matter and the server dropping dead snakes is fine.
+func DiffBoards(prev, next Board) BoardDiff {
+	var diff BoardDiff
+
+	nextSnakes := make(map[string]Snake, len(next.Snakes))
+	for _, snake := range next.Snakes {
+		nextSnakes[snake.ID] = snake
+	}
+
+	for _, before := range prev.Snakes {
+		if isSnakeDead(before) {
+			continue
+		}
+
+		delta := SnakeDelta{ID: before.ID}
+		after, ok := nextSnakes[before.ID]
+		if !ok || isSnakeDead(after) {
+			delta.Died = true
+			diff.Deaths = append(diff.Deaths, before.ID)
+			diff.Snakes = append(diff.Snakes, delta)
+			continue
+		}
+
+		delta.Health = after.Health
+		delta.Length = len(after.Body)
+		// unset if the head jumped, and a step across the edge of a wrapped board is still one step
+		delta.Move = directionTo(&next, before.Head, after.Head)
+		delta.Ate = len(after.Body) > len(before.Body) || (after.Health == 100 && before.Health < 100)
+		diff.Snakes = append(diff.Snakes, delta)
+	}
+
+	for _, food := range prev.Food {
+		if !containsPoint(next.Food, food) {
+			diff.FoodEaten = append(diff.FoodEaten, food)
+		}
+	}
+	for _, food := range next.Food {
+		if !containsPoint(prev.Food, food) {
+			diff.FoodSpawned = append(diff.FoodSpawned, food)
+		}
+	}
+
+	return diff
+}
+
+// TurnHistory remembers the last board seen in a game so the next one can be diffed against it.
+type TurnHistory struct {
+	mu    sync.Mutex
+	turn  int
+	board *Board
+}
+
+// Advance stores the board for the turn and returns the diff from the previous turn, if it was the turn before.
+func (h *TurnHistory) Advance(turn int, board Board) (BoardDiff, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prev, prevTurn := h.board, h.turn
+	stored := copyBoard(board)
+	h.board = &stored
+	h.turn = turn
+
+	if prev == nil || prevTurn != turn-1 {
+		return BoardDiff{}, false
+	}
+	return DiffBoards(*prev, board), true
+}
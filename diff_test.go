@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffBoards(t *testing.T) {
+	prev := Board{
+		Height: 11,
+		Width:  11,
+		Food:   []Point{{X: 2, Y: 3}, {X: 8, Y: 8}},
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}, {X: 2, Y: 0}}},
+			{ID: "b", Health: 50, Head: Point{X: 6, Y: 6}, Body: []Point{{X: 6, Y: 6}, {X: 7, Y: 6}, {X: 8, Y: 6}}},
+			{ID: "c", Health: 1, Head: Point{X: 0, Y: 10}, Body: []Point{{X: 0, Y: 10}, {X: 1, Y: 10}}},
+		},
+	}
+
+	// a eats going up, b goes left, c starves and is dropped by the server, new food spawns
+	next := Board{
+		Height: 11,
+		Width:  11,
+		Food:   []Point{{X: 8, Y: 8}, {X: 5, Y: 0}},
+		Snakes: []Snake{
+			{ID: "b", Health: 49, Head: Point{X: 5, Y: 6}, Body: []Point{{X: 5, Y: 6}, {X: 6, Y: 6}, {X: 7, Y: 6}}},
+			{ID: "a", Health: 100, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 2, Y: 1}, {X: 2, Y: 1}}},
+		},
+	}
+
+	diff := DiffBoards(prev, next)
+
+	a, ok := diff.Snake("a")
+	if assert.True(t, ok) {
+		assert.Equal(t, Up, a.Move)
+		assert.True(t, a.Ate)
+		assert.Equal(t, 4, a.Length)
+	}
+	b, ok := diff.Snake("b")
+	if assert.True(t, ok) {
+		assert.Equal(t, Left, b.Move)
+		assert.False(t, b.Ate)
+	}
+	c, ok := diff.Snake("c")
+	if assert.True(t, ok) {
+		assert.True(t, c.Died)
+		assert.Equal(t, Unset, c.Move)
+	}
+	assert.Equal(t, []string{"c"}, diff.Deaths)
+	assert.Equal(t, []Point{{X: 2, Y: 3}}, diff.FoodEaten)
+	assert.Equal(t, []Point{{X: 5, Y: 0}}, diff.FoodSpawned)
+}
+
+func TestDiffBoardsAcrossTheWrap(t *testing.T) {
+	prev := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{{ID: "a", Health: 90, Head: Point{X: 0, Y: 5}, Body: []Point{{X: 0, Y: 5}, {X: 1, Y: 5}, {X: 2, Y: 5}}}},
+	}
+	next := copyBoard(prev)
+	next.Snakes[0].Health = 89
+	next.Snakes[0].Head = Point{X: 10, Y: 5}
+	next.Snakes[0].Body = []Point{{X: 10, Y: 5}, {X: 0, Y: 5}, {X: 1, Y: 5}}
+
+	a, _ := DiffBoards(prev, next).Snake("a")
+	assert.Equal(t, Unset, a.Move, "a jump on a board that doesn't wrap")
+
+	prev.Wrapped, next.Wrapped = true, true
+	a, _ = DiffBoards(prev, next).Snake("a")
+	assert.Equal(t, Left, a.Move, "off the left edge and back on the right")
+}
+
+func TestDiffBoardsMatchesSimulator(t *testing.T) {
+	prev := Board{
+		Height: 7,
+		Width:  7,
+		Food:   []Point{{X: 1, Y: 3}},
+		Snakes: []Snake{
+			{ID: "a", Health: 80, Head: Point{X: 1, Y: 2}, Body: []Point{{X: 1, Y: 2}, {X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "b", Health: 80, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+		},
+	}
+
+	next := copyBoard(prev)
+	applyMove(&next, 0, Up)
+	applyMove(&next, 1, Right)
+
+	diff := DiffBoards(prev, next)
+	a, _ := diff.Snake("a")
+	b, _ := diff.Snake("b")
+	assert.Equal(t, Up, a.Move)
+	assert.True(t, a.Ate)
+	assert.Equal(t, Right, b.Move)
+	assert.False(t, b.Ate)
+	assert.Empty(t, diff.Deaths)
+	assert.Equal(t, []Point{{X: 1, Y: 3}}, diff.FoodEaten)
+}
+
+func TestTurnHistory(t *testing.T) {
+	history := &TurnHistory{}
+	board := Board{Height: 5, Width: 5, Snakes: []Snake{{ID: "a", Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}}}}}
+
+	_, ok := history.Advance(1, board)
+	assert.False(t, ok, "nothing to diff on the first turn")
+
+	moved := copyBoard(board)
+	applyMove(&moved, 0, Right)
+	diff, ok := history.Advance(2, moved)
+	assert.True(t, ok)
+	a, _ := diff.Snake("a")
+	assert.Equal(t, Right, a.Move)
+
+	_, ok = history.Advance(5, moved)
+	assert.False(t, ok, "skipped turns can't be diffed")
+}
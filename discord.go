@@ -3,6 +3,8 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 )
@@ -72,6 +74,8 @@ type WebhookPayload struct {
 	Embeds  []Embed `json:"embeds,omitempty"`
 }
 
+// sendDiscordWebhook makes one attempt at posting the message. Most things should go through discordQueue instead,
+// which retries.
 func sendDiscordWebhook(webhookURL, message string, embeds []Embed) error {
 	// Create the payload with the embed
 	payload := WebhookPayload{
@@ -86,14 +90,32 @@ func sendDiscordWebhook(webhookURL, message string, embeds []Embed) error {
 	}
 
 	// Send the HTTP POST request to the webhook URL
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return err
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); closeErr != nil && readErr == nil {
+		readErr = closeErr
+	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &rateLimitedError{retryAfter: retryAfter(resp, body)}
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return &rejectedError{status: resp.Status, body: body}
+	}
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return err
+		return fmt.Errorf("discord returned %s: %s", resp.Status, body)
+	}
+	if readErr != nil {
+		// it's delivered, we just couldn't read what came back
+		slog.Warn("failed to read discord response", "error", readErr.Error())
 	}
 
 	slog.Debug("discord message sent")
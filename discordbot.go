@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// discordInteractionBytes is the most an interaction from discord can be, they're small
+	discordInteractionBytes = 64 << 10
+	// discordAnalyzeTime is how long /analyze gets. The interaction's token lasts 15 minutes, but the
+	// answer's no use to anyone by then.
+	discordAnalyzeTime = 2 * time.Minute
+	// discordStatsDays is how far back /stats looks
+	discordStatsDays = 7
+)
+
+// discord interaction and response types, from https://discord.com/developers/docs/interactions/receiving-and-responding
+const (
+	interactionPing              = 1
+	interactionApplicationCmd    = 2
+	responsePong                 = 1
+	responseChannelMessage       = 4
+	responseDeferredChannelReply = 5
+)
+
+// discordAPI is where follow ups to interactions go.
+var discordAPI = "https://discord.com/api/v10"
+
+// discordPublicKey checks interactions really came from discord. DISCORD_PUBLIC_KEY is the application's
+// public key from the developer portal, and without it the endpoint's off.
+var discordPublicKey = parseDiscordPublicKey(os.Getenv("DISCORD_PUBLIC_KEY"))
+
+func parseDiscordPublicKey(key string) ed25519.PublicKey {
+	if key == "" {
+		return nil
+	}
+	decoded, err := hex.DecodeString(key)
+	if err != nil || len(decoded) != ed25519.PublicKeySize {
+		slog.Error("DISCORD_PUBLIC_KEY isn't a hex ed25519 key, discord commands are off")
+		return nil
+	}
+	return decoded
+}
+
+// DiscordCommand is a slash command as it's registered with discord.
+type DiscordCommand struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Options     []DiscordCommandOption `json:"options,omitempty"`
+}
+
+type DiscordCommandOption struct {
+	Type        int    `json:"type"` // 3 is a string
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// discordCommands are the commands /discord/interactions answers. They're registered by PUTting them to
+// the application's commands, https://discord.com/developers/docs/interactions/application-commands.
+var discordCommands = []DiscordCommand{
+	{Name: "lastgame", Description: "How the last game went, with the gif"},
+	{Name: "stats", Description: fmt.Sprintf("Win rate over the last %d days", discordStatsDays)},
+	{Name: "analyze", Description: "Go back over a game looking for blunders", Options: []DiscordCommandOption{
+		{Type: 3, Name: "game", Description: "The game's url or id", Required: true},
+	}},
+}
+
+// Interaction is the part of a discord interaction we use.
+type Interaction struct {
+	Type          int    `json:"type"`
+	ApplicationID string `json:"application_id"`
+	Token         string `json:"token"`
+	Data          struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// Option is the string option's value, empty if it wasn't given.
+func (i Interaction) Option(name string) string {
+	for _, option := range i.Data.Options {
+		if option.Name != name {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(option.Value, &value); err == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+// DiscordReply is a message in answer to a command.
+type DiscordReply struct {
+	Content string  `json:"content"`
+	Embeds  []Embed `json:"embeds,omitempty"`
+}
+
+type interactionResponse struct {
+	Type int           `json:"type"`
+	Data *DiscordReply `json:"data,omitempty"`
+}
+
+// verifyDiscordSignature checks the request was signed by discord: the signature is over the timestamp
+// then the body.
+func verifyDiscordSignature(key ed25519.PublicKey, r *http.Request, body []byte) bool {
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return false
+	}
+	message := append([]byte(r.Header.Get("X-Signature-Timestamp")), body...)
+	return ed25519.Verify(key, message, signature)
+}
+
+// handleDiscordInteractions answers discord's slash commands. Discord wants an answer inside 3 seconds,
+// so /analyze says it's thinking and follows up once the analysis is done.
+func handleDiscordInteractions(w http.ResponseWriter, r *http.Request) {
+	if discordPublicKey == nil {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, discordInteractionBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// discord checks this endpoint turns away bad signatures before it'll use it
+	if !verifyDiscordSignature(discordPublicKey, r, body) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	var interaction Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch interaction.Type {
+	case interactionPing:
+		writeJSON(w, interactionResponse{Type: responsePong})
+	case interactionApplicationCmd:
+		slog.Info("discord command", "command", interaction.Data.Name)
+		writeJSON(w, answerDiscordCommand(r.Context(), interaction))
+	default:
+		http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+	}
+}
+
+func answerDiscordCommand(ctx context.Context, interaction Interaction) interactionResponse {
+	reply := func(reply DiscordReply) interactionResponse {
+		return interactionResponse{Type: responseChannelMessage, Data: &reply}
+	}
+	switch interaction.Data.Name {
+	case "lastgame", "stats":
+		stats, err := loadGameStats(ctx)
+		if err != nil {
+			slog.Error("failed to load game stats", "error", err.Error())
+			return reply(DiscordReply{Content: "couldn't load the games: " + err.Error()})
+		}
+		if interaction.Data.Name == "lastgame" {
+			return reply(lastGameReply(stats.Games))
+		}
+		return reply(statsReply(stats.Games, time.Now()))
+	case "analyze":
+		gameID := gameIDFromURL(interaction.Option("game"))
+		if gameID == "" {
+			return reply(DiscordReply{Content: "which game? give it the game's url or id"})
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), discordAnalyzeTime)
+			defer cancel()
+			if err := followUpInteraction(ctx, interaction, analyzeGameReply(ctx, gameID)); err != nil {
+				slog.Error("failed to follow up discord command", "command", "analyze", "game_id", gameID, "error", err.Error())
+			}
+		}()
+		return interactionResponse{Type: responseDeferredChannelReply}
+	}
+	return reply(DiscordReply{Content: fmt.Sprintf("don't know /%s", interaction.Data.Name)})
+}
+
+// followUpInteraction replaces the deferred "thinking" message with the reply.
+func followUpInteraction(ctx context.Context, interaction Interaction, reply DiscordReply) error {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/webhooks/%s/%s/messages/@original", discordAPI, interaction.ApplicationID, interaction.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := discordClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// gameIDFromURL takes the game id off the end of a play.battlesnake.com url, or returns it as is if it's
+// just the id.
+func gameIDFromURL(url string) string {
+	url = strings.Trim(strings.TrimSpace(url), "<>/")
+	if i := strings.LastIndex(url, "/"); i >= 0 {
+		url = url[i+1:]
+	}
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	return url
+}
+
+func gameURL(gameID string) string {
+	return "https://play.battlesnake.com/game/" + gameID
+}
+
+// lastGameReply is how the most recent game went, with the engine's gif the archive stage kept.
+func lastGameReply(games []GameRecord) DiscordReply {
+	if len(games) == 0 {
+		return DiscordReply{Content: "no games yet"}
+	}
+	game := games[len(games)-1]
+	description := fmt.Sprintf("%s in %d turns", strings.ToLower(game.Outcome), game.Turns)
+	if game.Cause != "" {
+		description += ", " + game.Cause
+	}
+	if len(game.Opponents) > 0 {
+		description += " against " + strings.Join(game.Opponents, ", ")
+	}
+	gif := bucketObjectURL(game.GameID + ".gif")
+	return DiscordReply{
+		Content: fmt.Sprintf("%s %s", outcomeEmoji(game.Outcome), description),
+		Embeds: []Embed{{
+			Title:     fmt.Sprintf("%s, %s", game.GameID, game.Time.Format(time.RFC822)),
+			URL:       gameURL(game.GameID),
+			Image:     &Image{URL: gif},
+			Timestamp: game.Time.Format(time.RFC3339),
+		}},
+	}
+}
+
+func outcomeEmoji(outcome string) string {
+	switch outcome {
+	case Win.String():
+		return "🏆"
+	case Loss.String():
+		return "💀"
+	default:
+		return "🤝"
+	}
+}
+
+// statsReply is our record over the last few days.
+func statsReply(games []GameRecord, now time.Time) DiscordReply {
+	since := now.AddDate(0, 0, -discordStatsDays)
+	var played, wins, losses, draws int
+	for _, game := range games {
+		if game.Time.Before(since) {
+			continue
+		}
+		played++
+		switch game.Outcome {
+		case Win.String():
+			wins++
+		case Loss.String():
+			losses++
+		default:
+			draws++
+		}
+	}
+	if played == 0 {
+		return DiscordReply{Content: fmt.Sprintf("no games in the last %d days", discordStatsDays)}
+	}
+	return DiscordReply{Content: fmt.Sprintf("📈 %.0f%% win rate over the last %d days: %d games, %d won, %d lost, %d drawn",
+		100*float64(wins)/float64(played), discordStatsDays, played, wins, losses, draws)}
+}
+
+// analyzeGameReply goes back over the game for blunders, the same as the blunder stage does, for the
+// first of our snakes in it.
+func analyzeGameReply(ctx context.Context, gameID string) DiscordReply {
+	log, err := loadGameLog(ctx, gameID)
+	if errors.Is(err, storage.ErrObjectNotExist) || (err == nil && len(log.Entries) == 0) {
+		return DiscordReply{Content: fmt.Sprintf("no log for [%s](<%s>), was it one of ours?", gameID, gameURL(gameID))}
+	}
+	if err != nil {
+		return DiscordReply{Content: "couldn't load the game: " + err.Error()}
+	}
+	youID := log.Entries[0].YouID
+	var entries []GameLogEntry
+	for _, entry := range log.Entries {
+		if entry.YouID == youID {
+			entries = append(entries, entry)
+		}
+	}
+	blunders, analysed := findBlunders(ctx, entries, defaultSearchConfig, blunderSearchVisits, blunderSearchTime)
+	if len(blunders) == 0 {
+		return DiscordReply{Content: fmt.Sprintf("✅ no blunders in %d of %d turns of [game](<%s>)", analysed, len(entries), gameURL(gameID))}
+	}
+	message, embeds := blunderReport(gameID, blunders, analysed, len(entries))
+	return DiscordReply{Content: message, Embeds: embeds}
+}
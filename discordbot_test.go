@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// discordTestKey swaps in a key for the test and returns the private half to sign with.
+func discordTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	saved := discordPublicKey
+	discordPublicKey = public
+	t.Cleanup(func() { discordPublicKey = saved })
+	return private
+}
+
+func discordRequest(private ed25519.PrivateKey, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(body))
+	timestamp := "1700000000"
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(private, []byte(timestamp+body))))
+	return req
+}
+
+func TestDiscordInteractionsSignature(t *testing.T) {
+	router := newRouter("secret")
+	private := discordTestKey(t)
+	_, someoneElse, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, discordRequest(private, `{"type":1}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"type":1}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, discordRequest(someoneElse, `{"type":1}`))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// signed, then changed on the way
+	req := discordRequest(private, `{"type":1}`)
+	req.Body = io.NopCloser(strings.NewReader(`{"type":2}`))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(`{"type":1}`))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "unsigned")
+
+	discordPublicKey = nil
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, discordRequest(private, `{"type":1}`))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "off without a key")
+}
+
+func TestDiscordUnknownCommand(t *testing.T) {
+	router := newRouter("secret")
+	private := discordTestKey(t)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, discordRequest(private, `{"type":2,"data":{"name":"dance"}}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response interactionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, responseChannelMessage, response.Type)
+	assert.Equal(t, "don't know /dance", response.Data.Content)
+}
+
+func TestStatsReply(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	game := func(daysAgo int, outcome GameOutcome) GameRecord {
+		return GameRecord{Time: now.AddDate(0, 0, -daysAgo), Outcome: outcome.String()}
+	}
+	games := []GameRecord{
+		game(8, Loss), // too long ago
+		game(6, Win),
+		game(5, Win),
+		game(3, Loss),
+		game(0, Draw),
+	}
+	assert.Equal(t, "📈 50% win rate over the last 7 days: 4 games, 2 won, 1 lost, 1 drawn", statsReply(games, now).Content)
+	assert.Equal(t, "no games in the last 7 days", statsReply(games[:1], now).Content)
+}
+
+func TestLastGameReply(t *testing.T) {
+	assert.Equal(t, "no games yet", lastGameReply(nil).Content)
+
+	games := []GameRecord{
+		{GameID: "old", Outcome: Win.String()},
+		{GameID: "g1", Time: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), Opponents: []string{"paul", "jeff"}, Outcome: Loss.String(), Cause: "head-collision", Turns: 87},
+	}
+	reply := lastGameReply(games)
+	assert.Equal(t, "💀 loss in 87 turns, head-collision against paul, jeff", reply.Content)
+	require.Len(t, reply.Embeds, 1)
+	assert.Equal(t, "https://play.battlesnake.com/game/g1", reply.Embeds[0].URL)
+	assert.Equal(t, bucketObjectURL("g1.gif"), reply.Embeds[0].Image.URL)
+}
+
+func TestGameIDFromURL(t *testing.T) {
+	for input, want := range map[string]string{
+		"https://play.battlesnake.com/game/abc-123":       "abc-123",
+		"https://play.battlesnake.com/game/abc-123/":      "abc-123",
+		"<https://play.battlesnake.com/game/abc-123?t=4>": "abc-123",
+		" abc-123 ": "abc-123",
+		"":          "",
+	} {
+		assert.Equal(t, want, gameIDFromURL(input), input)
+	}
+}
+
+func TestDiscordAnalyzeFollowsUp(t *testing.T) {
+	router := newRouter("secret")
+	private := discordTestKey(t)
+
+	followUp := make(chan DiscordReply, 1)
+	var path string
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		var reply DiscordReply
+		json.NewDecoder(r.Body).Decode(&reply)
+		followUp <- reply
+	}))
+	defer discord.Close()
+	saved := discordAPI
+	discordAPI = discord.URL
+	t.Cleanup(func() { discordAPI = saved })
+
+	board := blunderTestBoard()
+	gameLogs.Add("discord-analyze", GameLogEntry{YouID: "us", Turn: 1, Board: board, Move: "right"})
+	gameLogs.Add("discord-analyze", GameLogEntry{YouID: "us", Turn: 2, Board: board, Move: "left"})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, discordRequest(private, `{"type":2,"application_id":"app","token":"tok","data":{"name":"analyze","options":[{"name":"game","type":3,"value":"https://play.battlesnake.com/game/discord-analyze"}]}}`))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"type":5}`, rec.Body.String(), "deferred while it thinks")
+
+	select {
+	case reply := <-followUp:
+		assert.Equal(t, "PATCH /webhooks/app/tok/messages/@original", path)
+		assert.Contains(t, reply.Content, "1 turns worth another look")
+		require.Len(t, reply.Embeds, 1)
+		assert.Contains(t, reply.Embeds[0].Title, "turn 2: left")
+	case <-time.After(time.Minute):
+		t.Fatal("never followed up")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, discordRequest(private, `{"type":2,"data":{"name":"analyze"}}`))
+	var response interactionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, responseChannelMessage, response.Type, "no game, nothing to defer")
+}
+
+func TestFollowUpInteractionFails(t *testing.T) {
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown webhook", http.StatusNotFound)
+	}))
+	defer discord.Close()
+	saved := discordAPI
+	discordAPI = discord.URL
+	t.Cleanup(func() { discordAPI = saved })
+
+	err := followUpInteraction(context.Background(), Interaction{ApplicationID: "app", Token: "expired"}, DiscordReply{Content: "hi"})
+	assert.ErrorContains(t, err, "unknown webhook")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// discordRouteInterval is the least time between messages to the same webhook. discord allows
+	// a few more than this but alerts come in bursts at the end of a game and it's better not to find the edge
+	discordRouteInterval = 500 * time.Millisecond
+	// discordBackoff is the wait before the first retry, doubling after each failure
+	discordBackoff = time.Second
+	// discordMaxBackoff caps how long a retry waits
+	discordMaxBackoff = 30 * time.Second
+	// discordAttempts is how many times a message is tried before it's dead-lettered
+	discordAttempts = 5
+	// discordRouteBuffer is how many messages can wait per webhook before new ones are dropped
+	discordRouteBuffer = 100
+)
+
+// rateLimitedError is a 429 from discord, with how long it asked us to wait.
+type rateLimitedError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return "rate limited by discord, retry after " + e.retryAfter.String()
+}
+
+// rejectedError is any other 4xx from discord. The message itself is wrong, a bad embed or a deleted
+// webhook, so sending it again won't help.
+type rejectedError struct {
+	status string
+	body   []byte
+}
+
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("discord returned %s: %s", e.status, e.body)
+}
+
+// retryAfter reads how long discord wants us to wait, from the header or failing that the body.
+func retryAfter(resp *http.Response, body []byte) time.Duration {
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	var limited struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.Unmarshal(body, &limited); err == nil && limited.RetryAfter > 0 {
+		return time.Duration(limited.RetryAfter * float64(time.Second))
+	}
+	return discordBackoff
+}
+
+// discordMessage is one message waiting to go to a webhook.
+type discordMessage struct {
+	url     string
+	message string
+	embeds  []Embed
+}
+
+// DiscordQueue delivers webhook messages in the background. Each webhook gets its own queue so one that's
+// being rate limited or failing doesn't hold up the others. Failed messages are retried with backoff, unless
+// discord rejected them outright, and ones that never make it are logged so they aren't lost without a trace.
+type DiscordQueue struct {
+	send        func(url, message string, embeds []Embed) error
+	interval    time.Duration
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxAttempts int
+
+	mu      sync.Mutex
+	routes  map[string]chan discordMessage
+	pending sync.WaitGroup
+}
+
+func newDiscordQueue(send func(url, message string, embeds []Embed) error) *DiscordQueue {
+	return &DiscordQueue{
+		send:        send,
+		interval:    discordRouteInterval,
+		backoff:     discordBackoff,
+		maxBackoff:  discordMaxBackoff,
+		maxAttempts: discordAttempts,
+		routes:      make(map[string]chan discordMessage),
+	}
+}
+
+// discordQueue is shared by everything that posts to discord.
+var discordQueue = newDiscordQueue(sendDiscordWebhook)
+
+// Send queues the message for the webhook and returns straight away.
+// Messages for a webhook we don't have the url for are dropped.
+func (q *DiscordQueue) Send(url, message string, embeds []Embed) {
+	if url == "" {
+		slog.Debug("no discord webhook, dropping message", "message", message)
+		return
+	}
+
+	q.mu.Lock()
+	route, ok := q.routes[url]
+	if !ok {
+		route = make(chan discordMessage, discordRouteBuffer)
+		q.routes[url] = route
+		go q.deliver(route)
+	}
+	q.mu.Unlock()
+
+	q.pending.Add(1)
+	select {
+	case route <- discordMessage{url: url, message: message, embeds: embeds}:
+	default:
+		q.pending.Done()
+		discordFailures.Inc("queue full")
+		slog.Error("discord message dead-lettered", "reason", "queue full", "message", message)
+	}
+}
+
+// Drain waits for everything queued so far to be delivered or given up on.
+func (q *DiscordQueue) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver sends one webhook's messages in order, keeping to the rate limit.
+func (q *DiscordQueue) deliver(route chan discordMessage) {
+	var last time.Time
+	for msg := range route {
+		for attempt := 1; ; attempt++ {
+			if wait := q.interval - time.Since(last); wait > 0 {
+				time.Sleep(wait)
+			}
+			last = time.Now()
+
+			err := q.send(msg.url, msg.message, msg.embeds)
+			if err == nil {
+				break
+			}
+			var rejected *rejectedError
+			if errors.As(err, &rejected) {
+				discordFailures.Inc("rejected")
+				slog.Error("discord message dead-lettered", "reason", err.Error(), "attempts", attempt, "message", msg.message)
+				break
+			}
+			if attempt >= q.maxAttempts {
+				discordFailures.Inc("attempts")
+				slog.Error("discord message dead-lettered", "reason", err.Error(), "attempts", attempt, "message", msg.message)
+				break
+			}
+
+			wait := q.backoff << (attempt - 1)
+			var limited *rateLimitedError
+			if errors.As(err, &limited) {
+				wait = limited.retryAfter
+			}
+			if wait > q.maxBackoff {
+				wait = q.maxBackoff
+			}
+			slog.Warn("discord message failed, retrying", "error", err.Error(), "attempt", attempt, "wait_ms", wait.Milliseconds())
+			time.Sleep(wait)
+		}
+		q.pending.Done()
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendDiscordWebhookErrors(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Handler     http.HandlerFunc
+		Check       func(t *testing.T, err error)
+	}{
+		{
+			Description: "delivered",
+			Handler:     func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			Check:       func(t *testing.T, err error) { assert.NoError(t, err) },
+		},
+		{
+			Description: "server error",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", http.StatusInternalServerError)
+			},
+			Check: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "500")
+				var rejected *rejectedError
+				assert.False(t, errors.As(err, &rejected), "worth another go")
+			},
+		},
+		{
+			Description: "rejected",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad embed", http.StatusBadRequest)
+			},
+			Check: func(t *testing.T, err error) {
+				var rejected *rejectedError
+				require.True(t, errors.As(err, &rejected))
+				assert.ErrorContains(t, err, "bad embed")
+			},
+		},
+		{
+			Description: "rate limited by header",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "1.5")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			Check: func(t *testing.T, err error) {
+				var limited *rateLimitedError
+				require.True(t, errors.As(err, &limited))
+				assert.Equal(t, 1500*time.Millisecond, limited.retryAfter)
+			},
+		},
+		{
+			Description: "rate limited by body",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]float64{"retry_after": 0.25})
+			},
+			Check: func(t *testing.T, err error) {
+				var limited *rateLimitedError
+				require.True(t, errors.As(err, &limited))
+				assert.Equal(t, 250*time.Millisecond, limited.retryAfter)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			server := httptest.NewServer(tc.Handler)
+			defer server.Close()
+			tc.Check(t, sendDiscordWebhook(server.URL, "hello", nil))
+		})
+	}
+}
+
+// fakeDiscord records what it's sent and fails the first few attempts.
+type fakeDiscord struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	received []string
+	times    []time.Time
+}
+
+func (f *fakeDiscord) send(url, message string, embeds []Embed) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.times = append(f.times, time.Now())
+	if f.failures > 0 {
+		f.failures--
+		return f.err
+	}
+	f.received = append(f.received, message)
+	return nil
+}
+
+func testDiscordQueue(fake *fakeDiscord) *DiscordQueue {
+	queue := newDiscordQueue(fake.send)
+	queue.interval = 10 * time.Millisecond
+	queue.backoff = time.Millisecond
+	queue.maxBackoff = 20 * time.Millisecond
+	queue.maxAttempts = 3
+	return queue
+}
+
+func drainDiscord(t *testing.T, queue *DiscordQueue) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, queue.Drain(ctx))
+}
+
+func TestDiscordQueueRetries(t *testing.T) {
+	fake := &fakeDiscord{failures: 2, err: &rateLimitedError{retryAfter: 5 * time.Millisecond}}
+	queue := testDiscordQueue(fake)
+	queue.Send("hook", "first", nil)
+	queue.Send("hook", "second", nil)
+	drainDiscord(t, queue)
+
+	assert.Equal(t, []string{"first", "second"}, fake.received, "retries keep messages in order")
+	assert.Len(t, fake.times, 4)
+}
+
+func TestDiscordQueueDeadLetters(t *testing.T) {
+	fake := &fakeDiscord{failures: 100, err: errors.New("boom")}
+	queue := testDiscordQueue(fake)
+	queue.Send("hook", "doomed", nil)
+	drainDiscord(t, queue)
+
+	assert.Empty(t, fake.received)
+	assert.Len(t, fake.times, 3, "gives up after the last attempt")
+}
+
+func TestDiscordQueueDeadLettersRejectionsStraightAway(t *testing.T) {
+	fake := &fakeDiscord{failures: 100, err: &rejectedError{status: "404 Not Found"}}
+	queue := testDiscordQueue(fake)
+	queue.Send("hook", "doomed", nil)
+	queue.Send("hook", "also doomed", nil)
+	drainDiscord(t, queue)
+
+	assert.Empty(t, fake.received)
+	assert.Len(t, fake.times, 2, "one try each")
+}
+
+func TestDiscordQueueRateLimitsPerRoute(t *testing.T) {
+	fake := &fakeDiscord{}
+	queue := testDiscordQueue(fake)
+	queue.interval = 30 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		queue.Send("hook", "burst", nil)
+	}
+	drainDiscord(t, queue)
+
+	require.Len(t, fake.times, 3)
+	for i := 1; i < len(fake.times); i++ {
+		assert.GreaterOrEqual(t, fake.times[i].Sub(fake.times[i-1]), 30*time.Millisecond)
+	}
+}
+
+func TestDiscordQueueRoutesAreIndependent(t *testing.T) {
+	stuck := make(chan struct{})
+	var mu sync.Mutex
+	var delivered []string
+	queue := newDiscordQueue(func(url, message string, embeds []Embed) error {
+		if url == "slow" {
+			<-stuck
+		}
+		mu.Lock()
+		delivered = append(delivered, message)
+		mu.Unlock()
+		return nil
+	})
+	queue.Send("slow", "waiting", nil)
+	queue.Send("fast", "through", nil)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == 1 && delivered[0] == "through"
+	}, time.Second, 5*time.Millisecond)
+	close(stuck)
+	drainDiscord(t, queue)
+}
+
+func TestDiscordQueueDropsWithoutURL(t *testing.T) {
+	fake := &fakeDiscord{}
+	queue := testDiscordQueue(fake)
+	queue.Send("", "nowhere", nil)
+	drainDiscord(t, queue)
+	assert.Empty(t, fake.times)
+}
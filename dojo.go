@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// dojoConfig holds the settings for a dojo session.
+type dojoConfig struct {
+	size      int
+	thinkTime time.Duration
+	seed      int64
+	workers   int
+	color     bool
+}
+
+// runDojo plays a human at the terminal against the engine.
+// Usage: main dojo [-size 11] [-think 400ms] [-seed 1] [-color]
+func runDojo(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("dojo", flag.ContinueOnError)
+	flags.SetOutput(out)
+	cfg := dojoConfig{}
+	flags.IntVar(&cfg.size, "size", 11, "board width and height")
+	flags.DurationVar(&cfg.thinkTime, "think", 400*time.Millisecond, "how long the engine searches each turn")
+	flags.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "seed for starting positions and food")
+	flags.IntVar(&cfg.workers, "workers", runtime.NumCPU(), "search workers")
+	flags.BoolVar(&cfg.color, "color", len(terminalBoardOptions()) > 0, "draw the board in colour")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return playDojo(cfg, in, out)
+}
+
+// dojoGame is the standard ruleset, so food turns up in the dojo the way it does in a real game.
+var dojoGame = Game{Ruleset: Ruleset{Name: "standard", Settings: Settings{FoodSpawnChance: 15, MinimumFood: 1}}}
+
+// newDojoBoard sets up the engine at index 0 and the human at index 1 in opposite corners.
+func newDojoBoard(size int, rng *rand.Rand) Board {
+	start := func(id, name string, p Point) Snake {
+		return Snake{
+			ID:     id,
+			Name:   name,
+			Health: 100,
+			Head:   p,
+			Body:   []Point{p, p, p},
+		}
+	}
+
+	board := Board{
+		Height: size,
+		Width:  size,
+		Snakes: []Snake{
+			start("engine", "Gregory", Point{X: 1, Y: 1}),
+			start("human", "you", Point{X: size - 2, Y: size - 2}),
+		},
+	}.withRules(dojoGame)
+	board.Food = []Point{{X: size / 2, Y: size / 2}}
+	spawnFoodWith(&board, rng.Intn)
+	return board
+}
+
+// parseDojoMove turns what the human typed into a direction.
+func parseDojoMove(input string) (Direction, bool) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "w", "u", "up":
+		return Up, true
+	case "s", "down":
+		return Down, true
+	case "a", "l", "left":
+		return Left, true
+	case "d", "r", "right":
+		return Right, true
+	}
+	return Unset, false
+}
+
+// dojoPuzzle formats the board as a case for TestMCTSVisualizationJSON.
+func dojoPuzzle(board Board) (string, error) {
+	data, err := json.Marshal(board)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`{
+	Description:  "dojo position",
+	InitialBoard: %s,
+	Iterations:   math.MaxInt,
+},`, "`"+string(data)+"`"), nil
+}
+
+func playDojo(cfg dojoConfig, in io.Reader, out io.Writer) error {
+	rng := rand.New(rand.NewSource(cfg.seed))
+	board := newDojoBoard(cfg.size, rng)
+	scanner := bufio.NewScanner(in)
+	gameStates := make(map[string]*Node)
+
+	var drawOptions []func(*boardOptions)
+	if cfg.color {
+		drawOptions = append(drawOptions, WithANSI())
+	}
+	if cfg.size > 11 {
+		drawOptions = append(drawOptions, WithCompact())
+	}
+
+	fmt.Fprintln(out, "you are snake b. moves: w/a/s/d or up/left/down/right, p prints a puzzle case, q quits")
+
+	for turn := 0; !isTerminal(board); turn++ {
+		fmt.Fprintf(out, "turn %d  health a:%d b:%d\n", turn, board.Snakes[0].Health, board.Snakes[1].Health)
+		fmt.Fprint(out, visualizeBoard(board, drawOptions...))
+
+		var humanMove Direction
+		for humanMove == Unset {
+			fmt.Fprint(out, "> ")
+			if !scanner.Scan() {
+				return scanner.Err()
+			}
+			input := scanner.Text()
+			switch strings.TrimSpace(input) {
+			case "q":
+				return nil
+			case "p":
+				puzzle, err := dojoPuzzle(board)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(out, puzzle)
+				continue
+			}
+			move, ok := parseDojoMove(input)
+			if !ok {
+				fmt.Fprintf(out, "didn't understand %q\n", input)
+				continue
+			}
+			humanMove = move
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.thinkTime)
+		root := MCTS(ctx, "dojo", copyBoard(board), math.MaxInt, cfg.workers, gameStates)
+		cancel()
+		engineMove := determineBestMove(root)
+		fmt.Fprintf(out, "engine plays %s after %d visits\n", engineMove, root.Visits)
+
+		gameStates, _ = reuseTree(chooseRootChild(root).Node, treeReuseNodes)
+
+		applyMove(&board, 0, directionFromString(engineMove))
+		applyMove(&board, 1, humanMove)
+		spawnFoodWith(&board, rng.Intn)
+	}
+
+	fmt.Fprint(out, visualizeBoard(board, drawOptions...))
+	switch {
+	case isSnakeDead(board.Snakes[0]) && isSnakeDead(board.Snakes[1]):
+		fmt.Fprintln(out, "draw")
+	case isSnakeDead(board.Snakes[0]):
+		fmt.Fprintln(out, "you win")
+	default:
+		fmt.Fprintln(out, "the engine wins")
+	}
+	return nil
+}
+
+// directionFromString is the inverse of determineMoveDirection.
+func directionFromString(move string) Direction {
+	switch move {
+	case "up":
+		return Up
+	case "down":
+		return Down
+	case "left":
+		return Left
+	case "right":
+		return Right
+	}
+	return Unset
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDojoMove(t *testing.T) {
+	testCases := map[string]Direction{
+		"w":      Up,
+		"a":      Left,
+		"s":      Down,
+		"d":      Right,
+		" Up ":   Up,
+		"left":   Left,
+		"RIGHT":  Right,
+		"down\n": Down,
+	}
+	for input, expected := range testCases {
+		move, ok := parseDojoMove(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, move, input)
+	}
+
+	_, ok := parseDojoMove("x")
+	assert.False(t, ok)
+}
+
+func TestPlayDojo(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader("nonsense\np\nleft\ndown\nq\n")
+
+	err := playDojo(dojoConfig{size: 7, thinkTime: 20 * time.Millisecond, seed: 1, workers: 1}, in, &out)
+
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "didn't understand")
+	assert.Contains(t, out.String(), "InitialBoard:")
+	assert.Equal(t, 2, strings.Count(out.String(), "engine plays"))
+}
+
+func TestDojoFoodFollowsTheStandardRuleset(t *testing.T) {
+	board := newDojoBoard(7, rand.New(rand.NewSource(1)))
+	assert.Equal(t, 15, board.FoodSpawnChance)
+	assert.Equal(t, 1, board.MinimumFood)
+
+	// eaten down to nothing, the minimum is put straight back, in the same place for the same seed
+	board.Food = nil
+	again := copyBoard(board)
+	spawnFoodWith(&board, rand.New(rand.NewSource(2)).Intn)
+	spawnFoodWith(&again, rand.New(rand.NewSource(2)).Intn)
+	assert.Len(t, board.Food, 1)
+	assert.Equal(t, board.Food, again.Food)
+}
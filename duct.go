@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// ductExploration is what each snake's moves are picked with, the same as the sequential search's root.
+const ductExploration = 1.41
+
+// The sequential search takes the snakes one at a time, so a snake deeper in the round sees where the
+// others went before it picks, which the real game never lets it do. Decoupled UCT searches the moves as
+// they're really made: at every node each snake keeps statistics for its own moves and picks from them
+// by itself, and the joint move the snakes land on between them is one edge to the next board.
+
+// ductStats is what one snake has seen from one of its moves at a node.
+type ductStats struct {
+	visits int64
+	score  float64
+}
+
+// DUCTNode is a board in the simultaneous move search, with every snake's statistics for its moves and a
+// child for each joint move tried.
+type DUCTNode struct {
+	Board  Board
+	Visits int64
+
+	mutex    sync.Mutex
+	moves    [][]Direction // each snake's moves, nil for dead snakes
+	stats    [][]ductStats // each snake's statistics, in the same order as its moves
+	children map[string]*DUCTNode
+}
+
+// newDUCTNode sets up a node for the board. A snake with no safe move still takes part with one of the
+// moves that kills it, so the joint moves don't leave it standing still.
+func newDUCTNode(board Board) *DUCTNode {
+	node := &DUCTNode{
+		Board:    board,
+		moves:    make([][]Direction, len(board.Snakes)),
+		stats:    make([][]ductStats, len(board.Snakes)),
+		children: make(map[string]*DUCTNode),
+	}
+	if isTerminal(board) {
+		return node
+	}
+	for i, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		moves := generateSafeMoves(board, i)
+		if len(moves) == 0 {
+			moves = AllDirections[:1]
+		}
+		node.moves[i] = moves
+		node.stats[i] = make([]ductStats, len(moves))
+	}
+	return node
+}
+
+// pick is the joint move for the next visit, each snake taking the move with the best UCB score from its
+// own statistics without knowing what the others picked. Moves a snake hasn't tried yet come first.
+func (n *DUCTNode) pick() []int {
+	joint := make([]int, len(n.moves))
+	for i, stats := range n.stats {
+		best := math.Inf(-1)
+		for m, s := range stats {
+			value := math.MaxFloat64
+			if s.visits > 0 {
+				value = s.score/float64(s.visits) + ductExploration*math.Sqrt(math.Log(float64(n.Visits))/float64(s.visits))
+			}
+			if value > best {
+				best, joint[i] = value, m
+			}
+		}
+	}
+	return joint
+}
+
+// play is the board after the joint move, with every snake moving at once.
+func (n *DUCTNode) play(joint []int) Board {
+	board := copyBoard(n.Board)
+	moves := make([]Direction, len(n.moves))
+	for i := range moves {
+		moves[i] = Unset
+		if n.moves[i] != nil {
+			moves[i] = n.moves[i][joint[i]]
+		}
+	}
+	applyMoves(&board, moves)
+	return board
+}
+
+// update adds a visit with the scores, each snake's going to the move it picked. A nil joint move is a
+// leaf, which only counts the visit.
+func (n *DUCTNode) update(joint []int, scores []float64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	atomic.AddInt64(&n.Visits, 1)
+	if joint == nil {
+		return
+	}
+	for i, stats := range n.stats {
+		if stats == nil || i >= len(scores) {
+			continue
+		}
+		stats[joint[i]].visits++
+		stats[joint[i]].score += scores[i]
+	}
+}
+
+// Strategy is the snake's mixed strategy at the node, the share of visits each of its moves got.
+func (n *DUCTNode) Strategy(snake int) map[Direction]float64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	strategy := make(map[Direction]float64)
+	if snake < 0 || snake >= len(n.stats) {
+		return strategy
+	}
+	var total int64
+	for _, s := range n.stats[snake] {
+		total += s.visits
+	}
+	if total == 0 {
+		return strategy
+	}
+	for m, s := range n.stats[snake] {
+		strategy[n.moves[snake][m]] = float64(s.visits) / float64(total)
+	}
+	return strategy
+}
+
+// BestMove is the snake's most visited move at the node, or Unset if it hasn't got one.
+func (n *DUCTNode) BestMove(snake int) Direction {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	best, bestVisits := Unset, int64(0)
+	if snake < 0 || snake >= len(n.stats) {
+		return best
+	}
+	for m, s := range n.stats[snake] {
+		if s.visits > bestVisits {
+			best, bestVisits = n.moves[snake][m], s.visits
+		}
+	}
+	return best
+}
+
+// ductKey identifies a joint move among a node's children.
+func ductKey(joint []int) string {
+	key := make([]byte, len(joint))
+	for i, m := range joint {
+		key[i] = byte(m)
+	}
+	return string(key)
+}
+
+// DUCT runs a decoupled UCT search from the board until the context ends or the root has been visited the
+// given number of times, and returns the root.
+func DUCT(ctx context.Context, rootBoard Board, iterations int, numWorkers int, config SearchConfig) *DUCTNode {
+	root := newDUCTNode(rootBoard)
+	if len(rootBoard.Snakes) > 0 {
+		config.standing = evaluateBoard(rootBoard, 0, config.fullModules())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && atomic.LoadInt64(&root.Visits) < int64(iterations) {
+				ductIterate(root, config)
+			}
+		}()
+	}
+	wg.Wait()
+	return root
+}
+
+// ductStep is a node on the way down and the joint move taken from it.
+type ductStep struct {
+	node  *DUCTNode
+	joint []int
+}
+
+// ductIterate walks down picking joint moves until it reaches a board it hasn't seen or the game ends,
+// evaluates it for every snake and takes the scores back up.
+func ductIterate(root *DUCTNode, config SearchConfig) {
+	var path []ductStep
+	node := root
+	for !isTerminal(node.Board) {
+		node.mutex.Lock()
+		joint := node.pick()
+		key := ductKey(joint)
+		child, ok := node.children[key]
+		if !ok {
+			child = newDUCTNode(node.play(joint))
+			node.children[key] = child
+		}
+		node.mutex.Unlock()
+
+		path = append(path, ductStep{node: node, joint: joint})
+		node = child
+		if !ok {
+			break
+		}
+	}
+
+	scores := config.scores(node.Board, config.fullModules())
+	node.update(nil, scores)
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].node.update(path[i].joint, scores)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ductHeadToHeadBoard has a short snake and a long one both a step from the same square.
+func ductHeadToHeadBoard() Board {
+	return Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "short", Health: 100, Head: Point{X: 3, Y: 5}, Body: []Point{{X: 3, Y: 5}, {X: 3, Y: 6}, {X: 2, Y: 6}}},
+			{ID: "long", Health: 100, Head: Point{X: 3, Y: 3}, Body: []Point{{X: 3, Y: 3}, {X: 3, Y: 2}, {X: 3, Y: 1}, {X: 3, Y: 0}, {X: 2, Y: 0}}},
+		},
+	}
+}
+
+func TestDUCTKeepsEachSnakesStatistics(t *testing.T) {
+	root := DUCT(context.Background(), ductHeadToHeadBoard(), 2000, 2, SearchConfig{})
+	require.GreaterOrEqual(t, root.Visits, int64(2000))
+
+	for snake := range root.Board.Snakes {
+		// every visit through the root counts once for each snake's own pick
+		var visits int64
+		for _, s := range root.stats[snake] {
+			visits += s.visits
+		}
+		assert.Equal(t, root.Visits, visits, "snake %d", snake)
+
+		total := 0.0
+		for _, share := range root.Strategy(snake) {
+			total += share
+		}
+		assert.InDelta(t, 1, total, 1e-9)
+	}
+	assert.NotEmpty(t, root.children)
+	assert.Empty(t, newDUCTNode(Board{}).Strategy(0))
+}
+
+func TestDUCTAvoidsLosingHeadToHead(t *testing.T) {
+	// the short snake can't see where the long one is going, whichever index it has, so it stays clear
+	board := ductHeadToHeadBoard()
+	root := DUCT(context.Background(), board, 3000, 2, SearchConfig{})
+	assert.NotEqual(t, Down, root.BestMove(0))
+	assert.Less(t, root.Strategy(0)[Down], 0.2)
+
+	board.Snakes[0], board.Snakes[1] = board.Snakes[1], board.Snakes[0]
+	swapped := DUCT(context.Background(), board, 3000, 2, SearchConfig{})
+	assert.NotEqual(t, Down, swapped.BestMove(1))
+}
+
+func TestDUCTFindsTheWayOut(t *testing.T) {
+	// against the left wall, with the only way out of the corner upwards
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "me", Health: 100, Head: Point{X: 0, Y: 1}, Body: []Point{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 0}}},
+			{ID: "them", Health: 100, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+		},
+	}
+	root := DUCT(context.Background(), board, 1000, 2, SearchConfig{})
+	assert.Equal(t, Up, root.BestMove(0))
+
+	// a finished game has nothing to search
+	over := copyBoard(board)
+	markDeadSnake(&over, 1)
+	ended := DUCT(context.Background(), over, 10, 1, SearchConfig{})
+	assert.Equal(t, Unset, ended.BestMove(0))
+	assert.Empty(t, ended.children)
+}
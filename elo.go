@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	eloInitialRating = 1500
+	eloK             = 32
+)
+
+// EloStore keeps ratings for every snake we've played, ours and everyone else's.
+type EloStore struct {
+	Ratings map[string]float64 `json:"ratings"`
+	Games   map[string]int     `json:"games"`
+}
+
+// EloEntry is one row of the leaderboard.
+type EloEntry struct {
+	Name   string  `json:"name"`
+	Rating float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+// loadEloStore reads the store from disk, starting an empty one if the file doesn't exist yet.
+func loadEloStore(path string) (*EloStore, error) {
+	store := &EloStore{
+		Ratings: make(map[string]float64),
+		Games:   make(map[string]int),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, err
+	}
+	if store.Ratings == nil {
+		store.Ratings = make(map[string]float64)
+	}
+	if store.Games == nil {
+		store.Games = make(map[string]int)
+	}
+	return store, nil
+}
+
+// Save writes the store to disk.
+func (s *EloStore) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Rating returns the snake's rating, or the starting rating if it hasn't played.
+func (s *EloStore) Rating(name string) float64 {
+	if rating, ok := s.Ratings[name]; ok {
+		return rating
+	}
+	return eloInitialRating
+}
+
+// Record updates both ratings after a game. scoreA is 1 if a won, 0 if b won and 0.5 for a draw.
+func (s *EloStore) Record(a, b string, scoreA float64) {
+	ratingA, ratingB := s.Rating(a), s.Rating(b)
+	expectedA := 1 / (1 + math.Pow(10, (ratingB-ratingA)/400))
+
+	s.Ratings[a] = ratingA + eloK*(scoreA-expectedA)
+	s.Ratings[b] = ratingB + eloK*((1-scoreA)-(1-expectedA))
+	s.Games[a]++
+	s.Games[b]++
+}
+
+// Leaderboard returns every snake sorted by rating, best first.
+func (s *EloStore) Leaderboard() []EloEntry {
+	entries := make([]EloEntry, 0, len(s.Ratings))
+	for name, rating := range s.Ratings {
+		entries = append(entries, EloEntry{Name: name, Rating: rating, Games: s.Games[name]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rating == entries[j].Rating {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Rating > entries[j].Rating
+	})
+	return entries
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// endgameRegionCells is the most free cells the two snakes can share for the solver to take over.
+	// Beyond that there are too many lines for it to finish in a move's time.
+	endgameRegionCells = 24
+	// endgameMaxDepth is the most turns the solver looks ahead
+	endgameMaxDepth = 40
+	// endgameMaxNodes caps the positions the solver visits in one move
+	endgameMaxNodes = 500000
+)
+
+// endgameSolverEnabled lets the solver answer small 1v1 endgames it can prove. ENDGAME_SOLVER=false
+// leaves them to the search.
+var endgameSolverEnabled = os.Getenv("ENDGAME_SOLVER") != "false"
+
+// endgameValue is what the solver has proven about a position for us. Draws aren't told apart from
+// positions it couldn't finish, since either way there's nothing proven worth playing on.
+type endgameValue int8
+
+const (
+	endgameLoss    endgameValue = -1
+	endgameUnknown endgameValue = 0
+	endgameWin     endgameValue = 1
+)
+
+func (v endgameValue) String() string {
+	switch v {
+	case endgameWin:
+		return "win"
+	case endgameLoss:
+		return "loss"
+	}
+	return "unknown"
+}
+
+// EndgameResult is the solver's answer: the move to play, what it proved, and how hard it looked.
+type EndgameResult struct {
+	Move  Direction
+	Value endgameValue
+	Depth int // turns ahead the last finished pass looked
+	Nodes int
+}
+
+// Proven says whether the solver found a forced win.
+func (r EndgameResult) Proven() bool {
+	return r.Value == endgameWin && r.Move != Unset
+}
+
+// isSmallEndgame says whether the board is a duel in a small enough space for the solver: just us and one
+// other snake left, the free cells either of us can get to at most endgameRegionCells, and nothing about
+// the board left to chance the solver can't see coming. Food spawns are left out of the solve altogether.
+func isSmallEndgame(board Board) bool {
+	if len(board.Snakes) == 0 || isSnakeDead(board.Snakes[0]) {
+		return false
+	}
+	alive := 0
+	for _, snake := range board.Snakes {
+		if !isSnakeDead(snake) {
+			alive++
+		}
+	}
+	if alive != 2 || board.ShrinkEvery > 0 || rulesForMap(board.Map).EndTurn != nil {
+		return false
+	}
+	return endgameRegion(board) <= endgameRegionCells
+}
+
+// endgameRegion counts the free cells reachable from any snake's head without passing through a body.
+// Bodies are treated as staying put, so the real room grows as tails move off, but it's a fair measure
+// of how boxed in the game is.
+func endgameRegion(board Board) int {
+	blocked := make(map[Point]bool)
+	var frontier []Point
+	for _, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		for _, part := range snake.Body {
+			blocked[part] = true
+		}
+		frontier = append(frontier, snake.Head)
+	}
+
+	seen := make(map[Point]bool)
+	for len(frontier) > 0 {
+		p := frontier[len(frontier)-1]
+		frontier = frontier[:len(frontier)-1]
+		for _, direction := range AllDirections {
+			next := moveOnBoard(&board, p, direction)
+			if !isPointInsideBoard(&board, next) || blocked[next] || seen[next] {
+				continue
+			}
+			seen[next] = true
+			frontier = append(frontier, next)
+		}
+	}
+	return len(seen)
+}
+
+// endgameSolver is an exact search of a duel. It assumes the opponent sees our move before picking its
+// own, so a win it proves is a win whatever the opponent does. A loss only means we can't force anything,
+// since a real opponent has to guess.
+type endgameSolver struct {
+	ctx      context.Context
+	maxNodes int
+	nodes    int
+	aborted  bool
+	// positions already proven won or lost. a proof holds at any depth so they're kept across passes.
+	proven map[string]endgameValue
+}
+
+// solveEndgame deepens an exact search of the duel a turn at a time until it proves a win or a loss, runs
+// out of depth or nodes, or the context ends. Turns are played with resolveMoves, so no food turns up and
+// the hazards stay where they are: a proof can't hang on how the dice happened to land.
+func solveEndgame(ctx context.Context, board Board, maxDepth, maxNodes int) EndgameResult {
+	board = copyBoard(board)
+	// the rest of the solve is between us and whichever snake is left
+	for i := 1; i < len(board.Snakes); i++ {
+		if !isSnakeDead(board.Snakes[i]) {
+			board.Snakes[1] = board.Snakes[i]
+			board.Snakes = board.Snakes[:2]
+			break
+		}
+	}
+
+	solver := &endgameSolver{ctx: ctx, maxNodes: maxNodes, proven: make(map[string]endgameValue)}
+	result := EndgameResult{}
+	for depth := 1; depth <= maxDepth; depth++ {
+		move, value := solver.root(board, depth)
+		if solver.aborted {
+			// a proof found before running out still stands, anything else is half a pass
+			if value != endgameUnknown {
+				result.Move, result.Value, result.Depth = move, value, depth
+			}
+			break
+		}
+		result.Move, result.Value, result.Depth = move, value, depth
+		if value != endgameUnknown {
+			break
+		}
+	}
+	result.Nodes = solver.nodes
+	return result
+}
+
+// root is one pass at the depth, returning our best move and what it's worth.
+func (s *endgameSolver) root(board Board, depth int) (Direction, endgameValue) {
+	best, bestValue := Unset, endgameLoss-1
+	for _, move := range endgameMoves(board, 0) {
+		value := s.reply(board, move, depth)
+		if value > bestValue {
+			best, bestValue = move, value
+		}
+		if bestValue == endgameWin {
+			break
+		}
+	}
+	return best, bestValue
+}
+
+// search is what the position is worth to us with the turns left to look.
+func (s *endgameSolver) search(board Board, depth int) endgameValue {
+	if depth == 0 || s.aborted {
+		return endgameUnknown
+	}
+	key := endgameKey(board)
+	if value, ok := s.proven[key]; ok {
+		return value
+	}
+	s.nodes++
+	if s.nodes > s.maxNodes || s.ctx.Err() != nil {
+		s.aborted = true
+		return endgameUnknown
+	}
+
+	best := endgameLoss
+	for _, move := range endgameMoves(board, 0) {
+		if value := s.reply(board, move, depth); value > best {
+			best = value
+		}
+		if best == endgameWin {
+			break
+		}
+	}
+	// an unfinished subtree comes back unknown, which can't make a proof, so proofs are good even after aborting
+	if best != endgameUnknown {
+		s.proven[key] = best
+	}
+	return best
+}
+
+// reply is our move's worth against the opponent's best answer to it.
+func (s *endgameSolver) reply(board Board, move Direction, depth int) endgameValue {
+	worst := endgameWin
+	for _, response := range endgameMoves(board, 1) {
+		next := copyBoard(board)
+		resolveMoves(&next, []Direction{move, response})
+		value, over := endgameOver(next)
+		if !over {
+			value = s.search(next, depth-1)
+		}
+		if value < worst {
+			worst = value
+		}
+		if worst == endgameLoss {
+			break
+		}
+	}
+	return worst
+}
+
+// endgameMoves are the snake's safe moves, or a single move to lose with if it has none.
+func endgameMoves(board Board, index int) []Direction {
+	if moves := generateSafeMoves(board, index); len(moves) > 0 {
+		return moves
+	}
+	return []Direction{Up}
+}
+
+// endgameOver says whether the duel's decided and how. Both going together is a draw.
+func endgameOver(board Board) (endgameValue, bool) {
+	usDead, themDead := isSnakeDead(board.Snakes[0]), isSnakeDead(board.Snakes[1])
+	switch {
+	case usDead && themDead:
+		return endgameUnknown, true
+	case usDead:
+		return endgameLoss, true
+	case themDead:
+		return endgameWin, true
+	}
+	return endgameUnknown, false
+}
+
+// endgameKey tells positions apart for the proven table. Health counts as well as the layout since a
+// snake a move from starving isn't in the same position.
+func endgameKey(board Board) string {
+	return fmt.Sprintf("%s|%d|%d", boardHash(board), board.Snakes[0].Health, board.Snakes[1].Health)
+}
+
+// endgameSolveTime is how much of the move the solver gets before the search takes over.
+func endgameSolveTime(timeoutMs int) time.Duration {
+	return time.Duration(timeoutMs) * time.Millisecond / 4
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cornerEndgame has them in the top left corner with our body across their only way out, so whatever they
+// do they're dead next turn as long as we don't run into them first.
+func cornerEndgame() Board {
+	return Board{
+		Width: 3, Height: 3,
+		Snakes: []Snake{
+			{ID: "us", Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 0, Y: 1}, {X: 0, Y: 0}}},
+			{ID: "them", Health: 100, Head: Point{X: 0, Y: 2}, Body: []Point{{X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}},
+		},
+	}
+}
+
+// starvingEndgame has us going round a loop at full health and them boxed in beside us with three moves
+// of health and no food anywhere.
+func starvingEndgame() Board {
+	return Board{
+		Width: 5, Height: 2,
+		Snakes: []Snake{
+			{ID: "us", Health: 100, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "them", Health: 3, Head: Point{X: 4, Y: 0}, Body: []Point{{X: 4, Y: 0}, {X: 4, Y: 1}}},
+		},
+	}
+}
+
+func TestIsSmallEndgame(t *testing.T) {
+	assert.True(t, isSmallEndgame(cornerEndgame()))
+	assert.True(t, isSmallEndgame(starvingEndgame()))
+
+	open := evalTestBoard()
+	open.Snakes = open.Snakes[:2]
+	assert.False(t, isSmallEndgame(open), "the whole board is free")
+
+	three := cornerEndgame()
+	three.Snakes = append(three.Snakes, Snake{ID: "other", Health: 100, Head: Point{X: 2, Y: 0}, Body: []Point{{X: 2, Y: 0}}})
+	assert.False(t, isSmallEndgame(three), "only duels")
+
+	dead := cornerEndgame()
+	dead.Snakes = append(dead.Snakes, Snake{ID: "gone"})
+	assert.True(t, isSmallEndgame(dead), "dead snakes don't count")
+
+	royale := cornerEndgame()
+	royale.ShrinkEvery = 25
+	assert.False(t, isSmallEndgame(royale), "the shrink is random")
+
+	standard := cornerEndgame()
+	standard.Map = "standard"
+	assert.True(t, isSmallEndgame(standard))
+	sinkholes := cornerEndgame()
+	sinkholes.Map = "sinkholes"
+	assert.False(t, isSmallEndgame(sinkholes), "maps that change the board every turn are left to the search")
+}
+
+func TestEndgameAnswersWithoutSearching(t *testing.T) {
+	router := newRouter("")
+	game := sessionTestGame("session-endgame")
+	game.Board = cornerEndgame()
+	game.You = game.Board.Snakes[0]
+	session := newGameSession(game, []string{"them"})
+	productionSnake.startSession(session)
+	defer productionSnake.endSession(game)
+
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Contains(t, []string{"down", "right"}, response["move"])
+	records := session.decisions.Records()
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Solved)
+}
+
+func TestEndgameRegion(t *testing.T) {
+	assert.Equal(t, 3, endgameRegion(cornerEndgame()))
+	assert.Equal(t, 4, endgameRegion(starvingEndgame()))
+}
+
+func TestSolveEndgameWinsInOne(t *testing.T) {
+	result := solveEndgame(context.Background(), cornerEndgame(), endgameMaxDepth, endgameMaxNodes)
+	require.True(t, result.Proven())
+	assert.Equal(t, 1, result.Depth)
+	assert.Contains(t, []Direction{Down, Right}, result.Move, "up runs into them before they're gone")
+}
+
+func TestSolveEndgameWinsByStarvation(t *testing.T) {
+	result := solveEndgame(context.Background(), starvingEndgame(), endgameMaxDepth, endgameMaxNodes)
+	require.True(t, result.Proven())
+	assert.Equal(t, 3, result.Depth, "they last until their health runs out")
+	assert.Equal(t, Right, result.Move, "round the loop after our tail")
+}
+
+func TestSolveEndgameDoesntRollChance(t *testing.T) {
+	// food could save them and a shrink could put the sauce under us, if the solve rolled for either
+	board := starvingEndgame()
+	board.FoodSpawnChance, board.MinimumFood = 100, 3
+	board.ShrinkEvery, board.HazardDamage = 1, 100
+	for i := 0; i < 20; i++ {
+		result := solveEndgame(context.Background(), board, endgameMaxDepth, endgameMaxNodes)
+		require.True(t, result.Proven(), "the same proof every time")
+		assert.Equal(t, solveEndgame(context.Background(), starvingEndgame(), endgameMaxDepth, endgameMaxNodes), result)
+	}
+}
+
+func TestSolveEndgameLoses(t *testing.T) {
+	// the corner from their side: we're the one with nowhere to go
+	board := cornerEndgame()
+	board.Snakes[0], board.Snakes[1] = board.Snakes[1], board.Snakes[0]
+	result := solveEndgame(context.Background(), board, endgameMaxDepth, endgameMaxNodes)
+	assert.Equal(t, endgameLoss, result.Value)
+	assert.False(t, result.Proven())
+}
+
+func TestSolveEndgameStopsWhenOutOfBudget(t *testing.T) {
+	// far too open to prove anything in fifty positions
+	board := evalTestBoard()
+	board.Snakes = board.Snakes[:2]
+
+	result := solveEndgame(context.Background(), board, endgameMaxDepth, 50)
+	assert.False(t, result.Proven())
+	assert.LessOrEqual(t, result.Nodes, 51)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = solveEndgame(ctx, starvingEndgame(), endgameMaxDepth, endgameMaxNodes)
+	assert.False(t, result.Proven(), "a win past the first turn needs time to prove")
+}
+
+func TestSolveEndgameIgnoresDeadSnakes(t *testing.T) {
+	board := cornerEndgame()
+	board.Snakes = []Snake{board.Snakes[0], {ID: "gone"}, board.Snakes[1]}
+	result := solveEndgame(context.Background(), board, endgameMaxDepth, endgameMaxNodes)
+	assert.True(t, result.Proven())
+	assert.Len(t, board.Snakes, 3, "the caller's board is left alone")
+}
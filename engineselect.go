@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// selectEngine picks what answers the turn. The endgame solver gets first go at a small duel it might prove,
+// whatever's configured, and otherwise it's the search selectSearchEngine picks.
+func selectEngine(board Board, ruleset string, configured SearchEngine) SearchEngine {
+	if endgameSolverEnabled && isSmallEndgame(board) {
+		return EngineEndgame
+	}
+	return selectSearchEngine(board, ruleset, configured)
+}
+
+// selectSearchEngine is the configured engine unless that's auto, which takes the sequential tree search for
+// duels and DUCT once three or more snakes are left, where seeing the others' moves before picking flatters
+// the sequential search most. Royale stays with the tree search however many are left, since only its
+// chance nodes see the hazards closing in.
+func selectSearchEngine(board Board, ruleset string, configured SearchEngine) SearchEngine {
+	if configured != EngineAuto {
+		return configured
+	}
+	if ruleset == "royale" {
+		return EngineMCTS
+	}
+	alive := 0
+	for _, snake := range board.Snakes {
+		if !isSnakeDead(snake) {
+			alive++
+		}
+	}
+	if alive >= 3 {
+		return EngineDUCT
+	}
+	return EngineMCTS
+}
+
+// ductSearch runs DUCT on every cpu until the context ends and returns our most visited move, or our first
+// safe move if the search didn't get a visit in, along with how many times the root was visited.
+func ductSearch(ctx context.Context, board Board, config SearchConfig) (Direction, int64) {
+	root := DUCT(ctx, board, math.MaxInt, runtime.NumCPU(), config)
+	move := root.BestMove(0)
+	if move == Unset {
+		move = maxnMoves(board, 0)[0]
+	}
+	return move, root.Visits
+}
+
+// VisitsPerMove is the average number of search iterations behind the moves we searched for, leaving out
+// the ones we answered without searching.
+func (l *DecisionLog) VisitsPerMove() int64 {
+	var visits int64
+	var searched int64
+	for _, record := range l.Records() {
+		if record.Forced || record.TailChase || record.Solved {
+			continue
+		}
+		visits += record.Visits
+		searched++
+	}
+	if searched == 0 {
+		return 0
+	}
+	return visits / searched
+}
+
+// EngineSummary counts the moves each engine picked over the game, for the end of game report. Moves that
+// were forced or otherwise made without an engine aren't counted.
+func (l *DecisionLog) EngineSummary() string {
+	counts := make(map[string]int)
+	for _, record := range l.Records() {
+		if record.Algorithm != "" {
+			counts[record.Algorithm]++
+		}
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+	engines := make([]string, 0, len(counts))
+	for engine := range counts {
+		engines = append(engines, engine)
+	}
+	sort.Slice(engines, func(i, j int) bool {
+		if counts[engines[i]] == counts[engines[j]] {
+			return engines[i] < engines[j]
+		}
+		return counts[engines[i]] > counts[engines[j]]
+	})
+	parts := make([]string, len(engines))
+	for i, engine := range engines {
+		parts[i] = fmt.Sprintf("%s %d", engine, counts[engine])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectEngine(t *testing.T) {
+	three := evalTestBoard()
+	duel := evalTestBoard()
+	duel.Snakes = duel.Snakes[:2]
+	down := evalTestBoard()
+	down.Snakes[2].Health = 0
+
+	assert.Equal(t, EngineMCTS, selectEngine(duel, "standard", EngineAuto))
+	assert.Equal(t, EngineDUCT, selectEngine(three, "standard", EngineAuto))
+	assert.Equal(t, EngineMCTS, selectEngine(down, "standard", EngineAuto), "dead snakes don't count")
+	assert.Equal(t, EngineMCTS, selectEngine(three, "royale", EngineAuto), "only the tree search plans for the shrink")
+	assert.Equal(t, EngineMaxN, selectEngine(three, "standard", EngineMaxN), "a pinned engine is used as it is")
+	assert.Equal(t, EngineMCTS, selectEngine(duel, "standard", EngineMCTS))
+
+	assert.Equal(t, EngineEndgame, selectEngine(cornerEndgame(), "standard", EngineAuto))
+	assert.Equal(t, EngineEndgame, selectEngine(cornerEndgame(), "standard", EngineBRS), "the solver goes first whatever's pinned")
+	assert.Equal(t, EngineMCTS, selectSearchEngine(cornerEndgame(), "standard", EngineAuto), "what the solver falls back to")
+}
+
+func TestEngineSummary(t *testing.T) {
+	log := &DecisionLog{}
+	assert.Empty(t, log.EngineSummary())
+
+	for _, algorithm := range []string{"mcts", "duct", "", "mcts", "endgame", "duct", "mcts"} {
+		log.Add(DecisionRecord{Algorithm: algorithm})
+	}
+	assert.Equal(t, "mcts 3, duct 2, endgame 1", log.EngineSummary(), "forced moves have no engine")
+}
+
+func TestVisitsPerMove(t *testing.T) {
+	log := &DecisionLog{}
+	assert.Zero(t, log.VisitsPerMove())
+
+	log.Add(DecisionRecord{Visits: 1000})
+	log.Add(DecisionRecord{Visits: 3000})
+	log.Add(DecisionRecord{Forced: true})
+	log.Add(DecisionRecord{TailChase: true})
+	log.Add(DecisionRecord{Solved: true, Visits: 1})
+	assert.EqualValues(t, 2000, log.VisitsPerMove(), "moves we didn't search for don't drag it down")
+}
+
+func TestAutoEngineAnswersWithDUCT(t *testing.T) {
+	router := newRouter("")
+	game := sessionTestGame("session-auto")
+	game.Board = evalTestBoard()
+	game.You = game.Board.Snakes[0]
+	session := newGameSession(game, []string{"b", "c"})
+	session.Config.Engine = EngineAuto
+	productionSnake.startSession(session)
+	defer productionSnake.endSession(game)
+
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Contains(t, []string{"up", "right"}, response["move"])
+	records := session.decisions.Records()
+	require.Len(t, records, 1)
+	assert.Equal(t, "duct", records[0].Algorithm)
+	assert.Positive(t, records[0].Visits)
+}
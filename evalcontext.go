@@ -0,0 +1,322 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"sync"
+)
+
+var (
+	// running modules side by side only pays off when the search isn't already using every core
+	parallelEvaluation = os.Getenv("PARALLEL_EVAL") == "true"
+
+	evalPoolOnce sync.Once
+	evalPool     *moduleRunner
+)
+
+// EvaluationContext is everything the modules need to score a board, worked out once and shared
+// between modules. Cheap facts are filled in up front, expensive ones on first use.
+// Modules must treat all of it, the board included, as read only.
+type EvaluationContext struct {
+	Board     Board
+	RootIndex int
+	Alive     []bool  // by snake index
+	Lengths   []int   // by snake index, 0 for dead snakes
+	Occupancy [][]int // [y][x] index of the living snake on the cell, -1 if empty
+
+	// the expensive pieces don't depend on whose point of view it is, so every snake's context shares them
+	lazy *lazyAnalysis
+}
+
+type lazyAnalysis struct {
+	voronoiOnce sync.Once
+	voronoi     [][]int
+
+	foodOnce      sync.Once
+	foodDistances []int
+
+	areaOnce sync.Once
+	areas    []int
+	tails    []bool
+}
+
+func newEvaluationContext(board Board, rootIndex int) *EvaluationContext {
+	ec := &EvaluationContext{
+		Board:     board,
+		RootIndex: rootIndex,
+		Alive:     make([]bool, len(board.Snakes)),
+		Lengths:   make([]int, len(board.Snakes)),
+		Occupancy: make([][]int, board.Height),
+		lazy:      &lazyAnalysis{},
+	}
+
+	for y := range ec.Occupancy {
+		ec.Occupancy[y] = make([]int, board.Width)
+		for x := range ec.Occupancy[y] {
+			ec.Occupancy[y][x] = -1
+		}
+	}
+
+	for i, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		ec.Alive[i] = true
+		ec.Lengths[i] = len(snake.Body)
+		for _, part := range snake.Body {
+			if isPointInsideBoard(&board, part) {
+				ec.Occupancy[part.Y][part.X] = i
+			}
+		}
+	}
+
+	return ec
+}
+
+// forSnake is the same board seen from another snake's point of view, sharing everything worked out so far.
+func (ec *EvaluationContext) forSnake(index int) *EvaluationContext {
+	view := *ec
+	view.RootIndex = index
+	return &view
+}
+
+// AliveOpponents counts the living snakes other than the root snake.
+func (ec *EvaluationContext) AliveOpponents() int {
+	alive := 0
+	for i, isAlive := range ec.Alive {
+		if isAlive && i != ec.RootIndex {
+			alive++
+		}
+	}
+	return alive
+}
+
+// FoodDistances returns how many moves each snake is from its nearest food going around bodies,
+// by snake index. Dead snakes and snakes that can't reach any food get -1.
+func (ec *EvaluationContext) FoodDistances() []int {
+	ec.lazy.foodOnce.Do(func() {
+		distances := make([]int, len(ec.Board.Snakes))
+		for i, snake := range ec.Board.Snakes {
+			distances[i] = -1
+			if ec.Alive[i] {
+				distances[i] = ec.nearestFood(snake.Head)
+			}
+		}
+		ec.lazy.foodDistances = distances
+	})
+	return ec.lazy.foodDistances
+}
+
+func (ec *EvaluationContext) nearestFood(start Point) int {
+	board := &ec.Board
+	if len(board.Food) == 0 || !isPointInsideBoard(board, start) {
+		return -1
+	}
+
+	food := make(map[Point]bool, len(board.Food))
+	for _, f := range board.Food {
+		food[f] = true
+	}
+
+	visited := make([][]bool, board.Height)
+	for y := range visited {
+		visited[y] = make([]bool, board.Width)
+	}
+	visited[start.Y][start.X] = true
+
+	frontier := []Point{start}
+	for distance := 0; len(frontier) > 0; distance++ {
+		var next []Point
+		for _, p := range frontier {
+			if food[p] {
+				return distance
+			}
+			for _, direction := range AllDirections {
+				n := moveOnBoard(board, p, direction)
+				if !isPointInsideBoard(board, n) || visited[n.Y][n.X] || ec.Occupancy[n.Y][n.X] >= 0 {
+					continue
+				}
+				visited[n.Y][n.X] = true
+				next = append(next, n)
+			}
+		}
+		frontier = next
+	}
+	return -1
+}
+
+// StarvationHorizons returns how far each snake can get before starving, by snake index. See starvationHorizon.
+func (ec *EvaluationContext) StarvationHorizons() []int {
+	foodDistances := ec.FoodDistances()
+	horizons := make([]int, len(ec.Board.Snakes))
+	for i, snake := range ec.Board.Snakes {
+		horizons[i] = starvationHorizon(snake.Health, foodDistances[i])
+	}
+	return horizons
+}
+
+// ReachableAreas returns how many cells each snake can reach from its head, by snake index, counting no
+// further than its length since that's enough room to keep going by following its own tail. A body is in
+// the way until its tail has moved off the cell, so a corridor that opens up in time counts. Dead snakes get 0.
+func (ec *EvaluationContext) ReachableAreas() []int {
+	ec.floodFill()
+	return ec.lazy.areas
+}
+
+// TailsReachable says whether each snake can get to its own tail once it's moved off, by snake index. One
+// that can has a loop to go round for as long as it likes, however little room there is otherwise.
+func (ec *EvaluationContext) TailsReachable() []bool {
+	ec.floodFill()
+	return ec.lazy.tails
+}
+
+func (ec *EvaluationContext) floodFill() {
+	ec.lazy.areaOnce.Do(func() {
+		areas := make([]int, len(ec.Board.Snakes))
+		tails := make([]bool, len(ec.Board.Snakes))
+		for i := range ec.Board.Snakes {
+			if ec.Alive[i] {
+				areas[i], tails[i] = ec.reachableArea(i, ec.clearingTimes(i))
+			}
+		}
+		ec.lazy.areas, ec.lazy.tails = areas, tails
+	})
+}
+
+// clearingTimes is how many moves until each cell is free of the bodies on it as the viewer sees it, by
+// [y][x]. Its own body clears on time, a stacked tail after eating staying put a move longer. Any of the
+// others could eat on the way, so their bodies are given a move longer so their tails can't lure it in.
+func (ec *EvaluationContext) clearingTimes(viewer int) [][]int {
+	board := &ec.Board
+	clears := make([][]int, board.Height)
+	for y := range clears {
+		clears[y] = make([]int, board.Width)
+	}
+	for i, snake := range board.Snakes {
+		if !ec.Alive[i] {
+			continue
+		}
+		slack := 1
+		if i == viewer {
+			slack = 0
+		}
+		for k, part := range snake.Body {
+			if clear := len(snake.Body) - k + slack; isPointInsideBoard(board, part) && clear > clears[part.Y][part.X] {
+				clears[part.Y][part.X] = clear
+			}
+		}
+	}
+	return clears
+}
+
+// reachableArea floods out from the snake's head, counting the cells it can get to up to its length and
+// whether its own tail is one of them.
+func (ec *EvaluationContext) reachableArea(index int, clears [][]int) (int, bool) {
+	board := &ec.Board
+	snake := board.Snakes[index]
+	limit, tail := ec.Lengths[index], snake.Body[len(snake.Body)-1]
+	if !isPointInsideBoard(board, snake.Head) {
+		return 0, false
+	}
+	visited := make([][]bool, board.Height)
+	for y := range visited {
+		visited[y] = make([]bool, board.Width)
+	}
+	visited[snake.Head.Y][snake.Head.X] = true
+
+	area, reachedTail := 0, false
+	frontier := []Point{snake.Head}
+	for distance := 1; len(frontier) > 0 && (area < limit || !reachedTail); distance++ {
+		var next []Point
+		for _, p := range frontier {
+			for _, direction := range AllDirections {
+				n := moveOnBoard(board, p, direction)
+				if !isPointInsideBoard(board, n) || visited[n.Y][n.X] || clears[n.Y][n.X] > distance {
+					continue
+				}
+				visited[n.Y][n.X] = true
+				next = append(next, n)
+				area++
+				if n == tail {
+					reachedTail = true
+				}
+			}
+		}
+		frontier = next
+	}
+	if area > limit {
+		return limit, reachedTail
+	}
+	return area, reachedTail
+}
+
+// Voronoi returns the board's voronoi diagram, generating it the first time it's asked for.
+func (ec *EvaluationContext) Voronoi() [][]int {
+	ec.lazy.voronoiOnce.Do(func() {
+		ec.lazy.voronoi = generateVoronoi(ec.Board, ec.StarvationHorizons())
+	})
+	return ec.lazy.voronoi
+}
+
+// moduleTask is one module to run against a context, with somewhere to put the score.
+type moduleTask struct {
+	module EvaluationModule
+	ec     *EvaluationContext
+	result *float64
+	done   *sync.WaitGroup
+}
+
+// moduleRunner is a fixed set of goroutines that run modules for any leaf that hands them over.
+type moduleRunner struct {
+	tasks chan moduleTask
+}
+
+func newModuleRunner(workers int) *moduleRunner {
+	runner := &moduleRunner{tasks: make(chan moduleTask, workers)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range runner.tasks {
+				*task.result = task.module.EvalFunc(task.ec)
+				task.done.Done()
+			}
+		}()
+	}
+	return runner
+}
+
+// run scores the context with every module. The first module always runs on the calling goroutine,
+// and any module the pool has no room for does too, so a busy pool slows things down rather than blocking.
+func (r *moduleRunner) run(ec *EvaluationContext, modules []EvaluationModule) []float64 {
+	scores := make([]float64, len(modules))
+	var done sync.WaitGroup
+	for i := 1; i < len(modules); i++ {
+		done.Add(1)
+		select {
+		case r.tasks <- moduleTask{module: modules[i], ec: ec, result: &scores[i], done: &done}:
+		default:
+			scores[i] = modules[i].EvalFunc(ec)
+			done.Done()
+		}
+	}
+	if len(modules) > 0 {
+		scores[0] = modules[0].EvalFunc(ec)
+	}
+	done.Wait()
+	return scores
+}
+
+// runModules scores the context with every module, in parallel if it's turned on.
+func runModules(ec *EvaluationContext, modules []EvaluationModule) []float64 {
+	if parallelEvaluation {
+		evalPoolOnce.Do(func() {
+			evalPool = newModuleRunner(runtime.NumCPU())
+		})
+		return evalPool.run(ec, modules)
+	}
+
+	scores := make([]float64, len(modules))
+	for i, module := range modules {
+		scores[i] = module.EvalFunc(ec)
+	}
+	return scores
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func evalTestBoard() Board {
+	return Board{
+		Height: 11,
+		Width:  11,
+		Food:   []Point{{X: 5, Y: 5}, {X: 2, Y: 8}},
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}, {X: 0, Y: 1}}},
+			{ID: "b", Health: 90, Head: Point{X: 8, Y: 8}, Body: []Point{{X: 8, Y: 8}, {X: 8, Y: 9}, {X: 9, Y: 9}}},
+			{ID: "c", Health: 90, Head: Point{X: 8, Y: 2}, Body: []Point{{X: 8, Y: 2}, {X: 9, Y: 2}, {X: 10, Y: 2}}},
+		},
+	}
+}
+
+func TestModuleRunnerMatchesSerial(t *testing.T) {
+	board := evalTestBoard()
+	runner := newModuleRunner(2)
+
+	for index := range board.Snakes {
+		ec := newEvaluationContext(board, index)
+		serial := make([]float64, len(modules))
+		for i, module := range modules {
+			serial[i] = module.EvalFunc(ec)
+		}
+		assert.Equal(t, serial, runner.run(newEvaluationContext(board, index), modules))
+	}
+}
+
+func TestModuleRunnerRunsInlineWhenFull(t *testing.T) {
+	// nothing is reading the tasks so everything has to run on the caller
+	runner := &moduleRunner{tasks: make(chan moduleTask)}
+	var calls int64
+	counting := EvaluationModule{Name: "count", Weight: 1, EvalFunc: func(ec *EvaluationContext) float64 {
+		return float64(atomic.AddInt64(&calls, 1))
+	}}
+
+	scores := runner.run(newEvaluationContext(evalTestBoard(), 0), []EvaluationModule{counting, counting, counting})
+	assert.Len(t, scores, 3)
+	assert.Equal(t, int64(3), calls)
+}
+
+func TestEvaluationContextSharesVoronoi(t *testing.T) {
+	ec := newEvaluationContext(evalTestBoard(), 0)
+	first := ec.Voronoi()
+	assert.Equal(t, GenerateVoronoi(evalTestBoard()), first)
+	// same slice handed back, not a new diagram
+	assert.Same(t, &first[0][0], &ec.Voronoi()[0][0])
+	assert.Same(t, &first[0][0], &ec.forSnake(1).Voronoi()[0][0], "other snakes' views share it too")
+}
+
+func TestEvaluationContextPrecomputes(t *testing.T) {
+	board := evalTestBoard()
+	board.Snakes = append(board.Snakes, Snake{ID: "dead", Health: 0, Head: Point{X: 5, Y: 0}, Body: []Point{{X: 5, Y: 0}, {X: 4, Y: 0}}})
+	ec := newEvaluationContext(board, 0)
+
+	assert.Equal(t, []bool{true, true, true, false}, ec.Alive)
+	assert.Equal(t, []int{4, 3, 3, 0}, ec.Lengths)
+	assert.Equal(t, 2, ec.AliveOpponents())
+
+	assert.Equal(t, 0, ec.Occupancy[0][0])
+	assert.Equal(t, 1, ec.Occupancy[9][9])
+	assert.Equal(t, 2, ec.Occupancy[2][10])
+	assert.Equal(t, -1, ec.Occupancy[0][5], "dead snakes don't take up space")
+	assert.Equal(t, -1, ec.Occupancy[5][5])
+
+	assert.Equal(t, []int{8, 6, 6, -1}, ec.FoodDistances())
+}
+
+func TestFoodDistanceGoesAroundBodies(t *testing.T) {
+	board := Board{
+		Height: 5,
+		Width:  5,
+		Food:   []Point{{X: 2, Y: 4}},
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 2, Y: 0}, Body: []Point{{X: 2, Y: 0}, {X: 1, Y: 0}}},
+			// a wall across the middle with a gap on the right
+			{ID: "b", Health: 90, Head: Point{X: 0, Y: 2}, Body: []Point{{X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}, {X: 3, Y: 2}}},
+		},
+	}
+	ec := newEvaluationContext(board, 0)
+	assert.Equal(t, []int{8, 4}, ec.FoodDistances())
+}
+
+// pocketBoard has a shut in the bottom left corner behind b's body, with the rest of the board open to b.
+func pocketBoard() Board {
+	return Board{
+		Height: 5,
+		Width:  5,
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}, {X: 0, Y: 3}}},
+			{ID: "b", Health: 90, Head: Point{X: 1, Y: 0}, Body: []Point{{X: 1, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 2}, {X: 1, Y: 3}, {X: 1, Y: 4}, {X: 2, Y: 4}, {X: 3, Y: 4}, {X: 4, Y: 4}}},
+		},
+	}
+}
+
+func TestReachableAreas(t *testing.T) {
+	ec := newEvaluationContext(pocketBoard(), 0)
+	assert.Equal(t, []int{0, 8}, ec.ReachableAreas(), "b has more room than it needs, so it's only counted up to its length")
+	assert.Equal(t, []bool{false, true}, ec.TailsReachable())
+
+	// round a loop the only way on is where the tail is leaving, one cell at a time
+	loop := Board{
+		Height: 2,
+		Width:  3,
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}, {X: 2, Y: 0}}},
+		},
+	}
+	ec = newEvaluationContext(loop, 0)
+	assert.Equal(t, []int{5}, ec.ReachableAreas())
+	assert.Equal(t, []bool{true}, ec.TailsReachable())
+
+	// having just eaten it's a cell longer than the loop, but its tail still gets out of the way in time
+	loop.Snakes[0].Body = append(loop.Snakes[0].Body, Point{X: 2, Y: 0})
+	ec = newEvaluationContext(loop, 0)
+	assert.Equal(t, []int{5}, ec.ReachableAreas())
+	assert.Equal(t, []bool{true}, ec.TailsReachable())
+}
+
+func TestOtherTailsDontCountAsAWayOut(t *testing.T) {
+	// a's only way out of the corner is where b's tail is now, which b keeps if it eats this turn
+	board := Board{
+		Height: 5,
+		Width:  5,
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}, {X: 0, Y: 3}}},
+			{ID: "b", Health: 90, Head: Point{X: 1, Y: 4}, Body: []Point{{X: 1, Y: 4}, {X: 1, Y: 3}, {X: 1, Y: 2}, {X: 1, Y: 1}, {X: 1, Y: 0}}},
+		},
+	}
+	ec := newEvaluationContext(board, 0)
+	assert.Equal(t, []int{0, 5}, ec.ReachableAreas())
+	assert.Equal(t, []bool{false, true}, ec.TailsReachable())
+}
+
+// moduleMutates reports whether running the module changed the board it was handed.
+func moduleMutates(module EvaluationModule, board Board, rootIndex int) bool {
+	before := copyBoard(board)
+	module.EvalFunc(newEvaluationContext(board, rootIndex))
+	return !assert.ObjectsAreEqual(before, board)
+}
+
+// The context and its board are shared between modules that may run at the same time,
+// so a module writing to either would be a race as well as a wrong answer.
+func TestModulesDoNotModifyBoard(t *testing.T) {
+	mutating := EvaluationModule{
+		Name: "mutating",
+		EvalFunc: func(ec *EvaluationContext) float64 {
+			ec.Board.Snakes[ec.RootIndex].Health--
+			return 0
+		},
+	}
+	assert.True(t, moduleMutates(mutating, evalTestBoard(), 0), "the contract check should catch a module writing to the board")
+
+	for _, module := range append(append([]EvaluationModule{}, modules...), cheapModules...) {
+		board := evalTestBoard()
+		for index := range board.Snakes {
+			assert.False(t, moduleMutates(module, board, index), "%s modified the board evaluating snake %d", module.Name, index)
+		}
+	}
+}
+
+func benchmarkEvaluateBoard(b *testing.B, parallel bool) {
+	previous := parallelEvaluation
+	parallelEvaluation = parallel
+	defer func() { parallelEvaluation = previous }()
+
+	board := evalTestBoard()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		evaluateBoard(board, 0, modules)
+	}
+}
+
+func BenchmarkEvaluateBoardSerial(b *testing.B)   { benchmarkEvaluateBoard(b, false) }
+func BenchmarkEvaluateBoardParallel(b *testing.B) { benchmarkEvaluateBoard(b, true) }
+
+// benchmarkSearchRate measures end to end iterations per second with the search using every core.
+func benchmarkSearchRate(b *testing.B, parallel bool) {
+	previous := parallelEvaluation
+	parallelEvaluation = parallel
+	defer func() { parallelEvaluation = previous }()
+
+	var visits int64
+	var elapsed time.Duration
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		root := MCTS(ctx, "bench", evalTestBoard(), 1<<40, runtime.NumCPU(), make(map[string]*Node))
+		elapsed += time.Since(start)
+		cancel()
+		visits += root.Visits
+	}
+	b.ReportMetric(float64(visits)/elapsed.Seconds(), "iterations/s")
+}
+
+func BenchmarkSearchRateSerialEval(b *testing.B)   { benchmarkSearchRate(b, false) }
+func BenchmarkSearchRateParallelEval(b *testing.B) { benchmarkSearchRate(b, true) }
@@ -0,0 +1,65 @@
+package main
+
+import "math/rand"
+
+// spawnFood puts food down at the end of a turn the way the standard ruleset does: enough to bring the
+// board back up to its minimum, or failing that a single piece with the ruleset's spawn chance. Where it
+// lands is sampled, so every call is one of the ways the food could turn up rather than a board that
+// slowly starves. Boards without the ruleset's settings never get any.
+func spawnFood(board *Board) {
+	spawnFoodWith(board, rand.Intn)
+}
+
+// spawnFoodWith is spawnFood rolling its dice with intn, so games played from a seed come out the same.
+func spawnFoodWith(board *Board, intn func(n int) int) {
+	spawn := 0
+	if len(board.Food) < board.MinimumFood {
+		spawn = board.MinimumFood - len(board.Food)
+	} else if board.FoodSpawnChance > 0 && intn(100) < board.FoodSpawnChance {
+		spawn = 1
+	}
+	if spawn == 0 {
+		return
+	}
+
+	empty := foodSpawnPoints(board)
+	for ; spawn > 0 && len(empty) > 0; spawn-- {
+		i := intn(len(empty))
+		board.Food = append(board.Food, empty[i])
+		empty[i] = empty[len(empty)-1]
+		empty = empty[:len(empty)-1]
+	}
+}
+
+// foodSpawnPoints are the cells the rules will put food on: not under a snake, food or hazard, and not
+// one a snake's head could move onto next turn.
+func foodSpawnPoints(board *Board) []Point {
+	taken := make(map[Point]bool)
+	for _, point := range board.Food {
+		taken[point] = true
+	}
+	for _, point := range board.Hazards {
+		taken[point] = true
+	}
+	for _, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		for _, part := range snake.Body {
+			taken[part] = true
+		}
+		for _, direction := range AllDirections {
+			taken[moveOnBoard(board, snake.Head, direction)] = true
+		}
+	}
+
+	var empty []Point
+	for y := 0; y < board.Height; y++ {
+		for x := 0; x < board.Width; x++ {
+			if point := (Point{X: x, Y: y}); !taken[point] {
+				empty = append(empty, point)
+			}
+		}
+	}
+	return empty
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpawnFood(t *testing.T) {
+	board := evalTestBoard()
+	board.Food = nil
+	board.Hazards = []Point{{X: 0, Y: 0}}
+
+	spawnFood(&board)
+	assert.Empty(t, board.Food, "boards without the ruleset's settings never get food")
+
+	board.MinimumFood = 3
+	spawnFood(&board)
+	require.Len(t, board.Food, 3, "topped back up to the minimum")
+	allowed := foodSpawnPoints(&Board{Width: board.Width, Height: board.Height, Hazards: board.Hazards, Snakes: board.Snakes})
+	for _, food := range board.Food {
+		assert.Contains(t, allowed, food)
+	}
+	assert.NotContains(t, allowed, Point{X: 0, Y: 0}, "not on a hazard")
+	for _, snake := range board.Snakes {
+		assert.NotContains(t, allowed, snake.Head)
+		assert.NotContains(t, allowed, moveOnBoard(&board, snake.Head, Up), "not where a head can move next")
+	}
+
+	board.FoodSpawnChance = 100
+	spawnFood(&board)
+	assert.Len(t, board.Food, 4, "a certain chance always adds one more")
+	board.FoodSpawnChance = 0
+	spawnFood(&board)
+	assert.Len(t, board.Food, 4)
+}
+
+func TestSearchSpawnsFoodAtTheEndOfATurn(t *testing.T) {
+	board := evalTestBoard()
+	board.Food = nil
+	board.MinimumFood = 1
+
+	root := NewNode(board, -1, nil)
+	node := root
+	for i := range board.Snakes {
+		child := selectNode(context.Background(), node, nil, defaultSearchConfig)
+		require.NotNil(t, child)
+		assert.Empty(t, child.Board.Food, "snake %d moving doesn't put food down", i)
+		assert.Equal(t, i == len(board.Snakes)-1, child.chance, "the last snake to move finishes the turn")
+		node = child
+	}
+	outcome := selectNode(context.Background(), node, nil, defaultSearchConfig)
+	require.NotNil(t, outcome)
+	assert.Same(t, node, outcome.Parent)
+	assert.Len(t, outcome.Board.Food, 1, "the outcome under it has the food")
+
+	rolled := Rollout{Turns: 1}.play(board, -1)
+	assert.Len(t, rolled.Food, 1, "rollouts get food too")
+
+	moves := make([]Direction, len(board.Snakes))
+	for i := range moves {
+		moves[i] = generateSafeMoves(board, i)[0]
+	}
+	applyMoves(&board, moves)
+	assert.Len(t, board.Food, 1, "and so does the simultaneous simulation")
+}
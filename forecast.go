@@ -0,0 +1,84 @@
+package main
+
+// In royale the hazards close in by a row or column every ShrinkEvery turns, from a side the engine
+// picks at random. When isn't a secret, so neither is which cells are at risk: the next line in from
+// each side.
+
+// turnsUntilShrink is how many turns until the royale ring next closes in, or 0 outside royale.
+func turnsUntilShrink(board Board) int {
+	if board.ShrinkEvery <= 0 {
+		return 0
+	}
+	return board.ShrinkEvery - board.Turn%board.ShrinkEvery
+}
+
+// hazardForecast is how likely each cell is to be hazard after the next shrink. Every side is as likely
+// as the others to be the one that closes in, so a cell on the next line in from one side has a quarter
+// chance, and a corner two sides share has half. Cells that are hazard already aren't in it.
+func hazardForecast(board *Board) map[Point]float64 {
+	forecast := make(map[Point]float64)
+	if board.ShrinkEvery <= 0 {
+		return forecast
+	}
+	for _, side := range AllDirections {
+		for _, point := range nextHazardLine(board, side) {
+			if !containsPoint(board.Hazards, point) {
+				forecast[point] += 1 / float64(len(AllDirections))
+			}
+		}
+	}
+	return forecast
+}
+
+// forecastEvaluation keeps the root snake's territory out of the way of the shrinking ring: it's the
+// share of the opponents' Voronoi cells about to go under, less the root's share, weighed by how soon
+// the shrink comes. It only has something to say in royale.
+func forecastEvaluation(ec *EvaluationContext) float64 {
+	board := &ec.Board
+	turns := turnsUntilShrink(*board)
+	if turns == 0 {
+		return 0
+	}
+	forecast := hazardForecast(board)
+	if len(forecast) == 0 {
+		return 0
+	}
+
+	voronoi := ec.Voronoi()
+	cells := make([]float64, len(board.Snakes))
+	atRisk := make([]float64, len(board.Snakes))
+	for y := 0; y < board.Height; y++ {
+		for x := 0; x < board.Width; x++ {
+			if owner := voronoi[y][x]; owner >= 0 {
+				cells[owner]++
+				atRisk[owner] += forecast[Point{X: x, Y: y}]
+			}
+		}
+	}
+	exposure := func(i int) float64 {
+		if cells[i] == 0 {
+			return 0
+		}
+		return atRisk[i] / cells[i]
+	}
+
+	opponents, opponentExposure := 0, 0.0
+	for i := range board.Snakes {
+		if i != ec.RootIndex && ec.Alive[i] {
+			opponents++
+			opponentExposure += exposure(i)
+		}
+	}
+	if opponents > 0 {
+		opponentExposure /= float64(opponents)
+	}
+
+	// a shrink next turn matters most, one a full cycle off hardly at all
+	urgency := 1 - float64(turns-1)/float64(board.ShrinkEvery)
+	return urgency * (opponentExposure - exposure(ec.RootIndex))
+}
+
+// inRoyale says whether the board is one where the ring closes in.
+func inRoyale(ec *EvaluationContext) bool {
+	return ec.Board.ShrinkEvery > 0
+}
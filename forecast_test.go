@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTurnsUntilShrink(t *testing.T) {
+	testCases := []struct {
+		ShrinkEvery, Turn, Want int
+	}{
+		{0, 10, 0},
+		{25, 0, 25},
+		{25, 24, 1},
+		{25, 25, 25},
+		{25, 26, 24},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.Want, turnsUntilShrink(Board{ShrinkEvery: tc.ShrinkEvery, Turn: tc.Turn}), "%+v", tc)
+	}
+}
+
+func TestHazardForecast(t *testing.T) {
+	board := &Board{Width: 5, Height: 5}
+	assert.Empty(t, hazardForecast(board), "only in royale")
+
+	// the left column has already gone, so from the left it's the next one in
+	board.ShrinkEvery = 10
+	board.Hazards = edgeLine(board, Left, 0)
+	forecast := hazardForecast(board)
+	assert.Equal(t, 0.25, forecast[Point{X: 1, Y: 2}], "only the left side closes over it")
+	assert.Equal(t, 0.5, forecast[Point{X: 4, Y: 4}], "top and right both close over the corner")
+	assert.Equal(t, 0.5, forecast[Point{X: 1, Y: 0}], "left and bottom meet")
+	assert.NotContains(t, forecast, Point{X: 0, Y: 0}, "already hazard")
+	assert.NotContains(t, forecast, Point{X: 2, Y: 2})
+}
+
+func TestForecastEvaluation(t *testing.T) {
+	// we're hugging the bottom edge while they sit in the middle
+	board := Board{
+		Height:      7,
+		Width:       7,
+		ShrinkEvery: 20,
+		Turn:        19,
+		Snakes: []Snake{
+			{ID: "us", Health: 90, Head: Point{X: 1, Y: 0}, Body: []Point{{X: 1, Y: 0}, {X: 0, Y: 0}, {X: 0, Y: 1}}},
+			{ID: "them", Health: 90, Head: Point{X: 4, Y: 4}, Body: []Point{{X: 4, Y: 4}, {X: 4, Y: 5}, {X: 5, Y: 5}}},
+		},
+	}
+	soon := forecastEvaluation(newEvaluationContext(board, 0))
+	assert.Negative(t, soon, "our ground goes under first")
+	assert.InDelta(t, -soon, forecastEvaluation(newEvaluationContext(board, 1)), 1e-9, "and theirs is safer")
+
+	board.Turn = 0
+	later := forecastEvaluation(newEvaluationContext(board, 0))
+	assert.Negative(t, later)
+	assert.Greater(t, later, soon, "a shrink a whole cycle away matters less")
+
+	board.ShrinkEvery = 0
+	assert.Zero(t, forecastEvaluation(newEvaluationContext(board, 0)), "nothing outside royale")
+}
+
+func TestInactiveModulesDontDilute(t *testing.T) {
+	board := evalTestBoard()
+	withoutForecast := make([]EvaluationModule, 0, len(modules))
+	for _, module := range modules {
+		if module.Name != "forecast" {
+			withoutForecast = append(withoutForecast, module)
+		}
+	}
+	require.Len(t, withoutForecast, len(modules)-1)
+	assert.Equal(t, evaluateBoard(board, 0, withoutForecast), evaluateBoard(board, 0, modules), "outside royale it's as if it wasn't there")
+
+	board.ShrinkEvery = 10
+	ec := newEvaluationContext(board, 0)
+	assert.Len(t, activeModules(ec, modules), len(modules))
+}
+
+func TestSimulationShrinksOnSchedule(t *testing.T) {
+	board := Board{Width: 7, Height: 7, ShrinkEvery: 3, Turn: 1}
+	rollChance(&board)
+	assert.Empty(t, board.Hazards, "turn 2 isn't a shrink")
+	rollChance(&board)
+	assert.Len(t, board.Hazards, 7, "turn 3 closes in a line")
+	assert.Equal(t, 3, board.Turn)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/storage"
+)
+
+// gameLogsKept is how many games' logs are kept in memory. Anything older is read back from the bucket.
+const gameLogsKept = 100
+
+// GameLogEntry is one move we made and what it was made from, enough to go over a game afterwards
+// without digging through cloud logging.
+type GameLogEntry struct {
+	YouID    string             `json:"you_id"`
+	Turn     int                `json:"turn"`
+	Board    Board              `json:"board"` // as it was searched, us first
+	Ruleset  Ruleset            `json:"ruleset"`
+	Map      string             `json:"map,omitempty"`
+	Move     string             `json:"move"`
+	Visits   map[string]int64   `json:"visits,omitempty"` // root visits by move, left out when the move wasn't searched
+	Eval     map[string]float64 `json:"eval"`             // each module's unweighted score for us on the board
+	Decision DecisionRecord     `json:"decision"`
+}
+
+// SearchBoard is the board with the game's rules and turn back on it, which don't survive being stored,
+// ready to search again.
+func (e GameLogEntry) SearchBoard() Board {
+	return copyBoard(e.Board).withRules(Game{Ruleset: e.Ruleset, Map: e.Map}).atTurn(e.Turn)
+}
+
+// GameLog is every move we made in a game, in turn order.
+type GameLog struct {
+	GameID  string         `json:"game_id"`
+	Entries []GameLogEntry `json:"entries"`
+}
+
+// Add records the entry, replacing one for the same snake and turn the engine retried.
+func (l *GameLog) Add(entry GameLogEntry) {
+	for i := range l.Entries {
+		if l.Entries[i].YouID == entry.YouID && l.Entries[i].Turn == entry.Turn {
+			l.Entries[i] = entry
+			return
+		}
+	}
+	l.Entries = append(l.Entries, entry)
+	sort.SliceStable(l.Entries, func(i, j int) bool { return l.Entries[i].Turn < l.Entries[j].Turn })
+}
+
+// GameLogStore keeps the logs of the latest games in memory, dropping the oldest once it's full.
+type GameLogStore struct {
+	limit int
+
+	mu    sync.Mutex
+	logs  map[string]*GameLog
+	order []string // game ids, oldest first
+}
+
+func newGameLogStore(limit int) *GameLogStore {
+	return &GameLogStore{limit: limit, logs: make(map[string]*GameLog)}
+}
+
+// gameLogs is every game this instance has played lately.
+var gameLogs = newGameLogStore(gameLogsKept)
+
+// Add records the entry in its game's log.
+func (s *GameLogStore) Add(gameID string, entry GameLogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log, ok := s.logs[gameID]
+	if !ok {
+		log = &GameLog{GameID: gameID}
+		s.logs[gameID] = log
+		s.order = append(s.order, gameID)
+		if len(s.order) > s.limit {
+			delete(s.logs, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	log.Add(entry)
+}
+
+// Get returns a copy of the game's log, if it's still in memory.
+func (s *GameLogStore) Get(gameID string) (GameLog, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log, ok := s.logs[gameID]
+	if !ok {
+		return GameLog{}, false
+	}
+	return GameLog{GameID: log.GameID, Entries: append([]GameLogEntry(nil), log.Entries...)}, true
+}
+
+// logMove adds the move to the game's log and the live dashboard, with the board scored by the modules the search used. The root
+// is nil for moves answered without a search.
+func logMove(game Game, board Board, root *Node, decision DecisionRecord, modules []EvaluationModule) {
+	entry := GameLogEntry{
+		YouID:    board.Snakes[0].ID,
+		Turn:     decision.Turn,
+		Board:    copyBoard(board),
+		Ruleset:  game.Ruleset,
+		Map:      game.Map,
+		Move:     decision.Move,
+		Eval:     evaluationBreakdown(board, 0, modules),
+		Decision: decision,
+	}
+	if root != nil {
+		entry.Visits = make(map[string]int64, len(root.Children()))
+		for _, child := range root.Children() {
+			entry.Visits[moveForChild(root, child)] += atomic.LoadInt64(&child.Visits)
+		}
+	}
+	gameLogs.Add(decision.GameID, entry)
+	liveGames.Publish(liveUpdate(decision.GameID, entry))
+}
+
+func gameLogObject(gameID string) string {
+	return fmt.Sprintf("gamelogs/%s.json", gameID)
+}
+
+// gameLogStage keeps the game's log in the bucket for once it's dropped out of memory.
+func gameLogStage(ctx context.Context, job *EndOfGameJob) error {
+	log, ok := gameLogs.Get(job.Session.ID)
+	if !ok || len(log.Entries) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("failed to encode game log: %w", err)
+	}
+	return uploadToBucket(ctx, gameLogObject(log.GameID), "application/json", data)
+}
+
+// loadGameLog finds the game's log in memory, or failing that in the bucket.
+func loadGameLog(ctx context.Context, gameID string) (GameLog, error) {
+	if log, ok := gameLogs.Get(gameID); ok {
+		return log, nil
+	}
+	var log GameLog
+	data, err := downloadFromBucket(ctx, gameLogObject(gameID))
+	if err != nil {
+		return log, err
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return log, fmt.Errorf("failed to decode game log: %w", err)
+	}
+	return log, nil
+}
+
+// handleGameLog serves /games/{id}/log, every move we made in the game with the board, the visits and
+// the eval behind it.
+func handleGameLog(w http.ResponseWriter, r *http.Request) {
+	gameID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/games/"), "/log")
+	if !ok || gameID == "" || strings.Contains(gameID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	log, err := loadGameLog(r.Context(), gameID)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		http.Error(w, "no log for that game", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, log)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameLogStore(t *testing.T) {
+	store := newGameLogStore(2)
+	store.Add("a", GameLogEntry{YouID: "us", Turn: 2, Move: "up"})
+	store.Add("a", GameLogEntry{YouID: "us", Turn: 1, Move: "left"})
+	// the engine retried turn 2
+	store.Add("a", GameLogEntry{YouID: "us", Turn: 2, Move: "down"})
+	// and a hosted snake of ours is in the same game
+	store.Add("a", GameLogEntry{YouID: "also-us", Turn: 1, Move: "right"})
+
+	log, ok := store.Get("a")
+	require.True(t, ok)
+	var moves []string
+	for _, entry := range log.Entries {
+		moves = append(moves, fmt.Sprintf("%s %d %s", entry.YouID, entry.Turn, entry.Move))
+	}
+	assert.Equal(t, []string{"us 1 left", "also-us 1 right", "us 2 down"}, moves)
+
+	store.Add("b", GameLogEntry{Turn: 1})
+	store.Add("c", GameLogEntry{Turn: 1})
+	_, ok = store.Get("a")
+	assert.False(t, ok, "the oldest game is dropped once the store's full")
+	_, ok = store.Get("c")
+	assert.True(t, ok)
+}
+
+func TestGameLogEntrySearchBoard(t *testing.T) {
+	game := Game{Ruleset: Ruleset{Name: "royale", Settings: Settings{HazardDamagePerTurn: 14, Royale: RoyaleSettings{ShrinkEveryNTurns: 25}}}, Map: "hz_islands_bridges"}
+	board := Board{Width: 11, Height: 11, Snakes: []Snake{{ID: "us", Health: 90, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}}}}}
+	data, err := json.Marshal(GameLogEntry{Turn: 30, Board: board, Ruleset: game.Ruleset, Map: game.Map})
+	require.NoError(t, err)
+	var entry GameLogEntry
+	require.NoError(t, json.Unmarshal(data, &entry))
+
+	assert.Equal(t, board.withRules(game).atTurn(30), entry.SearchBoard(), "the rules and the turn are back on the board")
+}
+
+func TestGameLogAfterMove(t *testing.T) {
+	router := newRouter("secret")
+	game := sessionTestGame("gamelog")
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, rec.Code)
+	productionSnake.endSession(game)
+
+	get := func(path, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, get("/games/gamelog/log", "").Code)
+	assert.Equal(t, http.StatusNotFound, get("/games/gamelog", "secret").Code)
+
+	rec = get("/games/gamelog/log", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var log GameLog
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &log))
+	assert.Equal(t, "gamelog", log.GameID)
+	require.Len(t, log.Entries, 1)
+	entry := log.Entries[0]
+	assert.Equal(t, "a", entry.YouID)
+	assert.Equal(t, entry.Decision.Move, entry.Move)
+	assert.Equal(t, game.Board.Snakes[0].Body, entry.Board.Snakes[0].Body)
+	assert.Contains(t, entry.Eval, "voronoi")
+	var visits int64
+	for _, v := range entry.Visits {
+		visits += v
+	}
+	assert.Positive(t, visits)
+	assert.Positive(t, entry.Visits[entry.Move], "the move we made was searched")
+}
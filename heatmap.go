@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+)
+
+const (
+	// heatmapLabelHeight is the strip above each panel with the layer's name in it
+	heatmapLabelHeight = 16
+	// heatmapGap separates the panels
+	heatmapGap = 8
+	// heatmapAlpha is how strongly a layer covers the board where it's strongest
+	heatmapAlpha = 160
+)
+
+// heatmapsEnabled draws what the evaluation sees on every turn we played and keeps it in the bucket.
+// It's an image a turn, so it's off unless HEATMAPS is true.
+var heatmapsEnabled = os.Getenv("HEATMAPS") == "true"
+
+// HeatmapLayer is one way of looking at the board, a colour for each cell drawn over it. A cell left
+// transparent shows the board through.
+type HeatmapLayer struct {
+	Name  string
+	Cells func(board Board, palette *SnakePalette) [][]color.NRGBA // by [y][x]
+}
+
+// heatmapLayers are drawn side by side, us first on the board.
+var heatmapLayers = []HeatmapLayer{
+	{Name: "control", Cells: controlHeatmap},
+	{Name: "danger", Cells: dangerHeatmap},
+	{Name: "food", Cells: foodHeatmap},
+	{Name: "hazard", Cells: hazardHeatmap},
+}
+
+func heatmapGrid(board Board) [][]color.NRGBA {
+	grid := make([][]color.NRGBA, board.Height)
+	for y := range grid {
+		grid[y] = make([]color.NRGBA, board.Width)
+	}
+	return grid
+}
+
+// tint is the colour at the strength, from 0 for nothing to 1 for heatmapAlpha.
+func tint(c color.RGBA, strength float64) color.NRGBA {
+	strength = math.Max(0, math.Min(1, strength))
+	return color.NRGBA{R: c.R, G: c.G, B: c.B, A: uint8(strength * heatmapAlpha)}
+}
+
+// controlHeatmap colours each cell by the snake that gets there first, the same as the voronoi the
+// evaluation uses.
+func controlHeatmap(board Board, palette *SnakePalette) [][]color.NRGBA {
+	grid := heatmapGrid(board)
+	for y, row := range GenerateVoronoi(board) {
+		for x, owner := range row {
+			if owner >= 0 && owner < len(board.Snakes) {
+				grid[y][x] = tint(palette.Body(board.Snakes[owner].ID), 0.6)
+			}
+		}
+	}
+	return grid
+}
+
+// dangerHeatmap is where the opponents' heads can go next turn, strongest where one of them would win
+// the head to head.
+func dangerHeatmap(board Board, palette *SnakePalette) [][]color.NRGBA {
+	grid := heatmapGrid(board)
+	if len(board.Snakes) == 0 {
+		return grid
+	}
+	red := color.RGBA{255, 0, 0, 255}
+	ours := len(board.Snakes[0].Body)
+	for y, row := range markDangerZones(&board, 0) {
+		for x, length := range row {
+			switch {
+			case length >= ours:
+				grid[y][x] = tint(red, 1)
+			case length > 0:
+				grid[y][x] = tint(red, 0.4)
+			}
+		}
+	}
+	return grid
+}
+
+// foodHeatmap is how far each cell is from the nearest food around the bodies, brightest on the food.
+func foodHeatmap(board Board, palette *SnakePalette) [][]color.NRGBA {
+	grid := heatmapGrid(board)
+	distances := foodDistances(board)
+	furthest := 0
+	for _, row := range distances {
+		for _, distance := range row {
+			if distance > furthest {
+				furthest = distance
+			}
+		}
+	}
+	green := color.RGBA{0, 255, 0, 255}
+	for y, row := range distances {
+		for x, distance := range row {
+			if distance >= 0 {
+				grid[y][x] = tint(green, 1-float64(distance)/float64(furthest+1))
+			}
+		}
+	}
+	return grid
+}
+
+// foodDistances is every cell's distance to the nearest food going round the bodies, -1 if it can't get
+// to any.
+func foodDistances(board Board) [][]int {
+	distances := make([][]int, board.Height)
+	for y := range distances {
+		distances[y] = make([]int, board.Width)
+		for x := range distances[y] {
+			distances[y][x] = -1
+		}
+	}
+	blocked := make(map[Point]bool)
+	for _, snake := range board.Snakes {
+		for _, part := range snake.Body {
+			blocked[part] = true
+		}
+	}
+	var queue []Point
+	for _, food := range board.Food {
+		if isPointInsideBoard(&board, food) && distances[food.Y][food.X] < 0 {
+			distances[food.Y][food.X] = 0
+			queue = append(queue, food)
+		}
+	}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, direction := range AllDirections {
+			next := moveOnBoard(&board, cell, direction)
+			if !isPointInsideBoard(&board, next) || distances[next.Y][next.X] >= 0 {
+				continue
+			}
+			// a head can still reach food from a body cell, it just can't go through one
+			distances[next.Y][next.X] = distances[cell.Y][cell.X] + 1
+			if !blocked[next] {
+				queue = append(queue, next)
+			}
+		}
+	}
+	return distances
+}
+
+// hazardHeatmap is the hazard there already and how likely each cell is to be hazard after the next
+// shrink in royale.
+func hazardHeatmap(board Board, palette *SnakePalette) [][]color.NRGBA {
+	grid := heatmapGrid(board)
+	purple := color.RGBA{160, 0, 255, 255}
+	orange := color.RGBA{255, 140, 0, 255}
+	for point, chance := range hazardForecast(&board) {
+		if isPointInsideBoard(&board, point) {
+			grid[point.Y][point.X] = tint(orange, chance*2)
+		}
+	}
+	for _, hazard := range board.Hazards {
+		if isPointInsideBoard(&board, hazard) {
+			grid[hazard.Y][hazard.X] = tint(purple, 0.8)
+		}
+	}
+	return grid
+}
+
+// renderHeatmaps draws every layer over its own copy of the board, side by side with the layer's name
+// above it.
+func renderHeatmaps(board Board, palette *SnakePalette) *image.RGBA {
+	panelWidth, panelHeight := board.Width*hiResCellSize, board.Height*hiResCellSize
+	width := len(heatmapLayers)*(panelWidth+heatmapGap) - heatmapGap
+	img := image.NewRGBA(image.Rect(0, 0, width, heatmapLabelHeight+panelHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0, 0, 0, 255}}, image.Point{}, draw.Src)
+
+	base := renderBoardHighRes(&board, palette)
+	for i, layer := range heatmapLayers {
+		origin := image.Pt(i*(panelWidth+heatmapGap), heatmapLabelHeight)
+		panel := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(panelWidth, panelHeight))}
+		draw.Draw(img, panel, base, image.Point{}, draw.Src)
+		for y, row := range layer.Cells(board, palette) {
+			for x, c := range row {
+				if c.A == 0 {
+					continue
+				}
+				cell := image.Rect(x*hiResCellSize, (board.Height-1-y)*hiResCellSize, (x+1)*hiResCellSize, (board.Height-y)*hiResCellSize) // Flip along Y axis
+				draw.Draw(img, cell.Add(origin), &image.Uniform{c}, image.Point{}, draw.Over)
+			}
+		}
+		addScaledLabel(img, origin.X, heatmapLabelHeight-4, layer.Name, color.RGBA{200, 200, 200, 255})
+	}
+	return img
+}
+
+func encodeHeatmaps(board Board, palette *SnakePalette) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, renderHeatmaps(board, palette)); err != nil {
+		return nil, fmt.Errorf("failed to encode heatmaps: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// heatmapObject sits next to the game's gif in the bucket.
+func heatmapObject(gameID string, turn int) string {
+	return fmt.Sprintf("%s/heatmaps/%d.png", gameID, turn)
+}
+
+func bucketObjectURL(object string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucketName, object)
+}
+
+// heatmapStage draws the heatmaps for every turn we played, keeps them in the bucket and posts the last
+// one to discord, which is usually the one worth looking at.
+func heatmapStage(ctx context.Context, job *EndOfGameJob) error {
+	if !heatmapsEnabled {
+		return nil
+	}
+	session := job.Session
+	log, ok := gameLogs.Get(session.ID)
+	if !ok {
+		return nil
+	}
+	var entries []GameLogEntry
+	for _, entry := range log.Entries {
+		if entry.YouID == session.YouID {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	palette := newSnakePalette(&entries[0].Board)
+	for _, entry := range entries {
+		data, err := encodeHeatmaps(entry.Board, palette)
+		if err != nil {
+			return err
+		}
+		if err := uploadToBucket(ctx, heatmapObject(session.ID, entry.Turn), "image/png", data); err != nil {
+			return err
+		}
+	}
+
+	last := entries[len(entries)-1]
+	url := bucketObjectURL(heatmapObject(session.ID, last.Turn))
+	discordQueue.Send(webhookURL.Get(), fmt.Sprintf("🌡️ heatmaps for %d turns of [game](<https://play.battlesnake.com/game/%s>), the rest are next to [the last](<%s>)", len(entries), session.ID, url), []Embed{
+		{Title: fmt.Sprintf("turn %d", last.Turn), URL: url, Image: &Image{URL: url}},
+	})
+	return nil
+}
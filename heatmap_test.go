@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoodDistances(t *testing.T) {
+	board := Board{
+		Width:  4,
+		Height: 3,
+		Food:   []Point{{X: 0, Y: 0}},
+		Snakes: []Snake{
+			// a wall down the middle with a gap at the top
+			{ID: "wall", Body: []Point{{X: 1, Y: 0}, {X: 1, Y: 1}}},
+		},
+	}
+	distances := foodDistances(board)
+	assert.Equal(t, 0, distances[0][0])
+	assert.Equal(t, 1, distances[1][0])
+	assert.Equal(t, 1, distances[0][1], "food's one move away from a body cell next to it")
+	assert.Equal(t, 3, distances[2][1])
+	assert.Equal(t, 7, distances[0][3], "the rest goes round through the gap")
+}
+
+func TestDangerHeatmap(t *testing.T) {
+	board := blunderTestBoard()
+	grid := dangerHeatmap(board, newSnakePalette(&board))
+	// them is shorter, so the cells round its head are only a little dangerous
+	assert.Positive(t, grid[5][4].A)
+	assert.Less(t, grid[5][4].A, uint8(heatmapAlpha))
+	assert.Zero(t, grid[0][0].A)
+
+	board.Snakes[1].Body = append(board.Snakes[1].Body, Point{X: 6, Y: 5}, Point{X: 6, Y: 4}, Point{X: 6, Y: 3})
+	grid = dangerHeatmap(board, newSnakePalette(&board))
+	assert.Equal(t, uint8(heatmapAlpha), grid[5][4].A, "a longer snake wins the head to head")
+}
+
+func TestRenderHeatmaps(t *testing.T) {
+	board := blunderTestBoard()
+	board.Food = []Point{{X: 3, Y: 3}}
+	data, err := encodeHeatmaps(board, newSnakePalette(&board))
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	panelWidth := board.Width * hiResCellSize
+	assert.Equal(t, image.Rect(0, 0, len(heatmapLayers)*(panelWidth+heatmapGap)-heatmapGap, heatmapLabelHeight+board.Height*hiResCellSize), img.Bounds())
+
+	// the food panel is brightest on the food and darker further away
+	food := board.Food[0]
+	centre := func(p Point, panel int) (uint32, uint32, uint32) {
+		x := panel*(panelWidth+heatmapGap) + p.X*hiResCellSize + 2
+		y := heatmapLabelHeight + (board.Height-1-p.Y)*hiResCellSize + 2
+		r, g, b, _ := img.At(x, y).RGBA()
+		return r, g, b
+	}
+	_, nearGreen, _ := centre(food, 2)
+	_, controlGreen, _ := centre(food, 0)
+	assert.Greater(t, nearGreen, controlGreen)
+}
+
+func TestHeatmapObjectNextToGIF(t *testing.T) {
+	assert.Equal(t, "game/heatmaps/12.png", heatmapObject("game", 12))
+	assert.Equal(t, "https://storage.googleapis.com/gregorywebp/game/heatmaps/12.png", bucketObjectURL(heatmapObject("game", 12)))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	hiResCellSize  = 16 // pixels per board cell for images that get looked at on a real screen
+	hiResFoodInset = 4
+)
+
+// shade darkens the colour by the factor, 1 keeps it as is.
+func shade(c color.RGBA, factor float64) color.RGBA {
+	return color.RGBA{
+		R: uint8(float64(c.R) * factor),
+		G: uint8(float64(c.G) * factor),
+		B: uint8(float64(c.B) * factor),
+		A: c.A,
+	}
+}
+
+// renderBoardHighRes draws the board big enough for discord, fading each snake from head to tail so
+// you can tell which way it's going. The fade isn't in the palette, which is what the dithering is for.
+// Hazards are shaded, dead snakes leave an X where they died and the turn's in the top left corner.
+func renderBoardHighRes(board *Board, snakePalette *SnakePalette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, board.Width*hiResCellSize, board.Height*hiResCellSize))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0, 0, 0, 255}}, image.Point{}, draw.Src)
+
+	cell := func(p Point, inset int) image.Rectangle {
+		x := p.X * hiResCellSize
+		y := (board.Height - 1 - p.Y) * hiResCellSize // Flip along Y axis
+		return image.Rect(x+inset, y+inset, x+hiResCellSize-inset, y+hiResCellSize-inset)
+	}
+
+	// a dot in the middle of every cell so the grid is readable
+	grey := color.RGBA{100, 100, 100, 255}
+	for y := 0; y < board.Height; y++ {
+		for x := 0; x < board.Width; x++ {
+			draw.Draw(img, cell(Point{X: x, Y: y}, hiResCellSize/2-1), &image.Uniform{grey}, image.Point{}, draw.Src)
+		}
+	}
+
+	for _, hazard := range board.Hazards {
+		draw.Draw(img, cell(hazard, 0), &image.Uniform{hazardColor}, image.Point{}, draw.Src)
+	}
+
+	green := color.RGBA{0, 255, 0, 255}
+	for _, food := range board.Food {
+		draw.Draw(img, cell(food, hiResFoodInset), &image.Uniform{green}, image.Point{}, draw.Src)
+	}
+
+	for _, snake := range board.Snakes {
+		body := snakePalette.Body(snake.ID)
+		for i := len(snake.Body) - 1; i >= 0; i-- {
+			c := shade(body, 1-0.5*float64(i)/float64(len(snake.Body)))
+			if i == 0 {
+				c = snakePalette.Head(snake.ID)
+			}
+			draw.Draw(img, cell(snake.Body[i], 1), &image.Uniform{c}, image.Point{}, draw.Src)
+		}
+	}
+
+	for _, death := range board.Deaths {
+		r := cell(death, 2)
+		for i := 0; i < r.Dx(); i++ {
+			for thickness := 0; thickness < 2; thickness++ {
+				img.Set(r.Min.X+i, r.Min.Y+i+thickness, deathColor)
+				img.Set(r.Max.X-1-i, r.Min.Y+i+thickness, deathColor)
+			}
+		}
+	}
+
+	addScaledLabel(img, 2, 11, fmt.Sprintf("%d", board.Turn), color.RGBA{255, 255, 255, 255})
+
+	return img
+}
+
+// encodeGameHighResGIF renders the frames at high resolution and finishes on the outcome screen.
+func encodeGameHighResGIF(frames []*Board, outcome GameOutcome) ([]byte, error) {
+	snakePalette := newSnakePalette(frames[0])
+	palette := snakePalette.Colors()
+	images := make([]*image.Paletted, 0, len(frames)+1)
+	for _, board := range frames {
+		images = append(images, palettize(renderBoardHighRes(board, snakePalette), palette, true))
+	}
+	return encodeFramesWithOutcome(images, gameDelays(len(frames)), outcome)
+}
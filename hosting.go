@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// SnakeProfile is one snake the server plays as: how it looks to the engine and how it searches. Only
+// what's set overrides the production config, so an experiment says just what it's trying.
+type SnakeProfile struct {
+	Name  string `json:"name"` // the snake plays at /snakes/<name>/
+	Color string `json:"color,omitempty"`
+	Head  string `json:"head,omitempty"`
+	Tail  string `json:"tail,omitempty"`
+
+	Engine          string   `json:"engine,omitempty"`   // as ENGINE
+	Pruning         string   `json:"pruning,omitempty"`  // as MAXN_PRUNING
+	Parallel        string   `json:"parallel,omitempty"` // as SEARCH_PARALLEL
+	Policy          string   `json:"policy,omitempty"`   // as POLICY_PRIORS
+	VirtualLoss     *float64 `json:"virtual_loss,omitempty"`
+	CheapEvalVisits int64    `json:"cheap_eval_visits,omitempty"`
+}
+
+// apply overrides the config with whatever the profile sets.
+func (p SnakeProfile) apply(config SearchConfig) SearchConfig {
+	if p.Engine != "" {
+		config.Engine = parseSearchEngine(p.Engine)
+		// several trees is what sets the multi-tree engine apart
+		if config.Engine == EngineMultiMCTS {
+			config.Parallel = ParallelRoot
+		}
+	}
+	if p.Pruning != "" {
+		config.Pruning = parseMaxNPruning(p.Pruning)
+	}
+	if p.Parallel != "" {
+		config.Parallel = parseParallelMode(p.Parallel)
+	}
+	if p.Policy != "" {
+		config.Policy = parsePolicy(p.Policy)
+	}
+	if p.VirtualLoss != nil && *p.VirtualLoss >= 0 {
+		config.VirtualLoss = *p.VirtualLoss
+	}
+	if p.CheapEvalVisits > 0 {
+		config.FullEvalVisits = p.CheapEvalVisits
+	}
+	return config
+}
+
+// customization is what the snake tells the engine about itself at its index.
+func (p SnakeProfile) customization() map[string]string {
+	response := map[string]string{
+		"apiversion": "1",
+		"author":     "brensch",
+		"color":      "#00ff00",
+		"head":       "replit-mark",
+		"tail":       "replit-notmark",
+		"version":    engineBuild.String(),
+	}
+	if p.Color != "" {
+		response["color"] = p.Color
+	}
+	if p.Head != "" {
+		response["head"] = p.Head
+	}
+	if p.Tail != "" {
+		response["tail"] = p.Tail
+	}
+	return response
+}
+
+// HostedSnake is a snake being played on this server with the games it's in. Two of them can be in the
+// same game, so each keeps its own sessions.
+type HostedSnake struct {
+	Profile  SnakeProfile
+	sessions *SessionRegistry
+}
+
+func newHostedSnake(profile SnakeProfile) *HostedSnake {
+	return &HostedSnake{Profile: profile, sessions: newSessionRegistry(sessionTTL)}
+}
+
+// productionSnake is the snake at the root of the server, playing with the config from the environment.
+var productionSnake = &HostedSnake{sessions: sessions}
+
+// hostedSnakes are the snakes played under /snakes/ beside the production one, from SNAKES.
+var hostedSnakes []*HostedSnake
+
+// allSnakes is the production snake and every hosted one.
+func allSnakes() []*HostedSnake {
+	return append([]*HostedSnake{productionSnake}, hostedSnakes...)
+}
+
+var snakeNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// hostedSnakesFromEnv reads SNAKES, a json list of snake profiles. A profile without a usable name, or
+// with one that's already taken, is skipped.
+func hostedSnakesFromEnv() []*HostedSnake {
+	value := os.Getenv("SNAKES")
+	if value == "" {
+		return nil
+	}
+	var profiles []SnakeProfile
+	if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+		slog.Error("failed to parse SNAKES", "error", err.Error())
+		return nil
+	}
+	var snakes []*HostedSnake
+	taken := make(map[string]bool)
+	for _, profile := range profiles {
+		if !snakeNamePattern.MatchString(profile.Name) || taken[profile.Name] {
+			slog.Error("skipping hosted snake", "name", profile.Name)
+			continue
+		}
+		taken[profile.Name] = true
+		snakes = append(snakes, newHostedSnake(profile))
+	}
+	return snakes
+}
+
+// checkpointKey is what the game's checkpoint is saved under. The production snake's are just the game,
+// so they still line up with checkpoints saved before there were other snakes.
+func (s *HostedSnake) checkpointKey(gameID string) string {
+	if s.Profile.Name == "" {
+		return gameID
+	}
+	return s.Profile.Name + "/" + gameID
+}
+
+type snakeKey struct{}
+
+// withSnake is middleware that has the route play as the snake.
+func withSnake(snake *HostedSnake) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), snakeKey{}, snake)))
+		})
+	}
+}
+
+// snakeFrom returns the snake the request is for, the production one unless the route says otherwise.
+func snakeFrom(ctx context.Context) *HostedSnake {
+	if snake, ok := ctx.Value(snakeKey{}).(*HostedSnake); ok {
+		return snake
+	}
+	return productionSnake
+}
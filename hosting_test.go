@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnakeProfileOverridesConfig(t *testing.T) {
+	base := SearchConfig{Engine: EngineAuto, Parallel: ParallelShared, VirtualLoss: 1, FullEvalVisits: 50}
+	assert.Equal(t, base, SnakeProfile{Name: "same"}.apply(base), "nothing set, nothing changes")
+
+	noLoss := 0.0
+	config := SnakeProfile{Engine: "multimcts", Policy: "heuristic", VirtualLoss: &noLoss}.apply(base)
+	assert.Equal(t, EngineMultiMCTS, config.Engine)
+	assert.Equal(t, ParallelRoot, config.Parallel, "the multi-tree engine needs several trees")
+	assert.Equal(t, heuristicPolicy{}, config.Policy)
+	assert.Zero(t, config.VirtualLoss)
+	assert.Equal(t, int64(50), config.FullEvalVisits)
+}
+
+func TestHostedSnakesFromEnv(t *testing.T) {
+	t.Setenv("SNAKES", `[{"name":"maxn","engine":"maxn"},{"name":"Bad Name"},{"name":"maxn"},{"name":"duct","color":"#ff00ff"}]`)
+	snakes := hostedSnakesFromEnv()
+	require.Len(t, snakes, 2, "the bad name and the second maxn are skipped")
+	assert.Equal(t, "maxn", snakes[0].Profile.Name)
+	assert.Equal(t, "maxn", snakes[0].Profile.Engine)
+	assert.Equal(t, "#ff00ff", snakes[1].Profile.Color)
+
+	t.Setenv("SNAKES", `not json`)
+	assert.Empty(t, hostedSnakesFromEnv())
+}
+
+func TestHostedSnakePlaysItsOwnGames(t *testing.T) {
+	store := newMemorySessionStore()
+	savedStore, savedSnakes, savedPipeline := sessionStore, hostedSnakes, endOfGame
+	t.Cleanup(func() { sessionStore, hostedSnakes, endOfGame = savedStore, savedSnakes, savedPipeline })
+	sessionStore, endOfGame = store, newPipeline(nil)
+	experiment := newHostedSnake(SnakeProfile{Name: "experiment", Color: "#ff00ff", Engine: "maxn"})
+	hostedSnakes = []*HostedSnake{experiment}
+
+	router := newRouter("")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snakes/experiment/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var customization map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &customization))
+	assert.Equal(t, "#ff00ff", customization["color"])
+	assert.Equal(t, "replit-mark", customization["head"], "anything the profile leaves out is production's")
+
+	// both snakes in the same game
+	game := sessionTestGame("hosted-snake")
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	for _, path := range []string{"/start", "/move", "/snakes/experiment/start", "/snakes/experiment/move"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+	}
+	ours, ok := experiment.sessions.Get(game.Game.ID)
+	require.True(t, ok)
+	production, ok := sessions.Get(game.Game.ID)
+	require.True(t, ok)
+	assert.NotSame(t, production, ours)
+	assert.Equal(t, EngineMaxN, ours.Config.Engine)
+	assert.Equal(t, defaultSearchConfig.Engine, production.Config.Engine)
+
+	// their checkpoints don't collide either
+	assert.Equal(t, 2, checkpointSessions(context.Background(), store))
+	_, ok, err = store.Get(context.Background(), "experiment/hosted-snake")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	for _, path := range []string{"/end", "/snakes/experiment/end"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+	}
+	assert.Zero(t, experiment.sessions.Len())
+	_, ok = sessions.Get(game.Game.ID)
+	assert.False(t, ok)
+}
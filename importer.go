@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// GameFrames is a whole game as the engine streams it to the board viewer.
+type GameFrames struct {
+	Width  int
+	Height int
+	Game   Game         // the rules it was played by
+	Frames []FrameEvent // in turn order
+}
+
+// engineGameRules reads the rules off the game_end event, where the engine has every setting as a string.
+func engineGameRules(event FrameEvent) Game {
+	setting := func(name string) int {
+		value, _ := strconv.Atoi(event.Data.Ruleset[name])
+		return value
+	}
+	return Game{
+		ID:  event.Data.ID,
+		Map: event.Data.Map,
+		Ruleset: Ruleset{
+			Name: event.Data.Ruleset["name"],
+			Settings: Settings{
+				FoodSpawnChance:     setting("foodSpawnChance"),
+				MinimumFood:         setting("minimumFood"),
+				HazardDamagePerTurn: setting("hazardDamagePerTurn"),
+				Royale:              RoyaleSettings{ShrinkEveryNTurns: setting("shrinkEveryNTurns")},
+			},
+		},
+	}
+}
+
+// downloadGameFrames reads every frame of the game from the engine's websocket, the same one the renderer
+// uses, up to the game_end event that says how big the board was and the rules it was played by.
+func downloadGameFrames(ctx context.Context, wsURL string) (GameFrames, error) {
+	var game GameFrames
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return game, fmt.Errorf("failed to connect to websocket: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			break
+		}
+		if err != nil {
+			return game, fmt.Errorf("failed to read frame: %w", err)
+		}
+		var event FrameEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			return game, fmt.Errorf("failed to decode frame: %w", err)
+		}
+		if event.Type == "game_end" {
+			game.Width, game.Height = event.Data.Width, event.Data.Height
+			game.Game = engineGameRules(event)
+			break
+		}
+		if event.Type == "frame" {
+			game.Frames = append(game.Frames, event)
+		}
+	}
+	if game.Width <= 0 || game.Height <= 0 {
+		return game, errors.New("game never ended, or didn't say how big the board was")
+	}
+	return game, nil
+}
+
+// frameBoard is the board at the frame with the snakes still alive on it, the snake first, under the game's
+// rules. False if the snake is dead by then or was never in the game.
+func (g GameFrames) frameBoard(frame FrameEvent, snakeID string) (Board, bool) {
+	board := Board{Width: g.Width, Height: g.Height, Food: frame.Data.Food, Hazards: frame.Data.Hazards}.withRules(g.Game).atTurn(frame.Data.Turn)
+	found := false
+	for _, snake := range frame.Data.Snakes {
+		if snake.Death != nil || len(snake.Body) == 0 {
+			continue
+		}
+		if snake.ID == snakeID {
+			found = true
+		}
+		board.Snakes = append(board.Snakes, convertFrameSnakeToGameSnake(snake))
+	}
+	if !found {
+		return Board{}, false
+	}
+	return reorderSnakes(board, snakeID), true
+}
+
+// ImportedTurn is a turn of an imported game with the move the snake made and the one we'd have made.
+type ImportedTurn struct {
+	SnakeID string
+	Turn    int
+	Played  string
+	Ours    string
+	Visits  int64
+}
+
+// Agreed says whether we'd have made the same move.
+func (t ImportedTurn) Agreed() bool {
+	return t.Played == t.Ours
+}
+
+// replayImportedGame searches every turn the snake moved on and compares what it played with what we'd
+// have played. The last frame has no move after it, so it isn't searched.
+func replayImportedGame(ctx context.Context, game GameFrames, snakeID string, search time.Duration, config SearchConfig) []ImportedTurn {
+	var turns []ImportedTurn
+	for i := 0; i+1 < len(game.Frames) && ctx.Err() == nil; i++ {
+		board, ok := game.frameBoard(game.Frames[i], snakeID)
+		if !ok {
+			continue
+		}
+		var next Point
+		moved := false
+		for _, snake := range game.Frames[i+1].Data.Snakes {
+			if snake.ID == snakeID && len(snake.Body) > 0 {
+				next, moved = snake.Body[0], true
+			}
+		}
+		if !moved {
+			continue
+		}
+
+		searchCtx, cancel := context.WithTimeout(ctx, search)
+		root := MCTS(searchCtx, "import", board, math.MaxInt, runtime.NumCPU(), nil, WithSearchConfig(config))
+		cancel()
+		turns = append(turns, ImportedTurn{
+			SnakeID: snakeID,
+			Turn:    board.Turn,
+			Played:  determineMoveDirection(board.Snakes[0].Head, next),
+			Ours:    determineBestMove(root),
+			Visits:  root.Visits,
+		})
+	}
+	return turns
+}
+
+// gameSnakeIDs is every snake that started the game, or just the one the id or name picks out.
+func gameSnakeIDs(game GameFrames, pick string) ([]string, error) {
+	if len(game.Frames) == 0 {
+		return nil, errors.New("game has no frames")
+	}
+	var ids []string
+	for _, snake := range game.Frames[0].Data.Snakes {
+		if pick == "" || snake.ID == pick || snake.Name == pick {
+			ids = append(ids, snake.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no snake %q in the game", pick)
+	}
+	return ids, nil
+}
+
+// runImport downloads any public game from the engine and replays our search over every turn, printing
+// where we'd have gone a different way.
+func runImport(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(out)
+	snake := flags.String("snake", "", "id or name of the snake to replay as, every snake if left out")
+	search := flags.Duration("search", 500*time.Millisecond, "time searching each turn")
+	engine := flags.String("engine", "wss://engine.battlesnake.com", "engine to download the game from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: import [flags] <game id>")
+	}
+	gameID := flags.Arg(0)
+
+	// the search logs every tree it looks up, which would drown out the comparison
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	game, err := downloadGameFrames(ctx, fmt.Sprintf("%s/games/%s/events", *engine, gameID))
+	cancel()
+	if err != nil {
+		return err
+	}
+	ids, err := gameSnakeIDs(game, *snake)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		turns := replayImportedGame(context.Background(), game, id, *search, defaultSearchConfig)
+		agreed := 0
+		for _, turn := range turns {
+			if turn.Agreed() {
+				agreed++
+				continue
+			}
+			fmt.Fprintf(out, "%s turn %d: played %s, we'd go %s (%d visits)\n", id, turn.Turn, turn.Played, turn.Ours, turn.Visits)
+		}
+		fmt.Fprintf(out, "%s: agreed on %d of %d turns\n", id, agreed, len(turns))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importTestFrame is a frame with each snake's body, and a death for any that are out.
+func importTestFrame(turn int, snakes ...FrameSnake) FrameEvent {
+	var event FrameEvent
+	event.Type = "frame"
+	event.Data.Turn = turn
+	event.Data.Snakes = snakes
+	return event
+}
+
+// serveGameFrames is a fake engine streaming the frames then the game_end for a 7x7 standard game.
+func serveGameFrames(t *testing.T, frames []FrameEvent) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/games/imported/events", r.URL.Path)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		for _, frame := range frames {
+			require.NoError(t, conn.WriteJSON(frame))
+		}
+		var end FrameEvent
+		end.Type = "game_end"
+		end.Data.Width, end.Data.Height = 7, 7
+		end.Data.Ruleset = map[string]string{"name": "standard", "foodSpawnChance": "15", "minimumFood": "1"}
+		end.Data.Map = "standard"
+		require.NoError(t, conn.WriteJSON(end))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// importTestFrames is blunderTestBoard played out: we walk into the closed off corner and die.
+func importTestFrames() []FrameEvent {
+	them := func(body ...Point) FrameSnake { return FrameSnake{ID: "them", Name: "them", Health: 90, Body: body} }
+	return []FrameEvent{
+		importTestFrame(0,
+			FrameSnake{ID: "us", Name: "Gregory", Health: 90, Body: []Point{{1, 0}, {1, 1}, {0, 1}, {0, 2}, {0, 3}}},
+			them(Point{5, 5}, Point{5, 6}, Point{6, 6})),
+		importTestFrame(1,
+			FrameSnake{ID: "us", Name: "Gregory", Health: 89, Body: []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 2}}},
+			them(Point{4, 5}, Point{5, 5}, Point{5, 6})),
+		importTestFrame(2,
+			FrameSnake{ID: "us", Name: "Gregory", Health: 88, Body: []Point{{0, 1}, {0, 0}, {1, 0}, {1, 1}, {0, 1}}, Death: &Death{Cause: "snake-self-collision", Turn: 2}},
+			them(Point{3, 5}, Point{4, 5}, Point{5, 5})),
+	}
+}
+
+func TestDownloadAndReplayGame(t *testing.T) {
+	server := serveGameFrames(t, importTestFrames())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	game, err := downloadGameFrames(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/games/imported/events")
+	require.NoError(t, err)
+	assert.Equal(t, 7, game.Width)
+	assert.Equal(t, "standard", game.Game.Ruleset.Name)
+	require.Len(t, game.Frames, 3)
+
+	board, ok := game.frameBoard(game.Frames[0], "them")
+	require.True(t, ok)
+	assert.Equal(t, "them", board.Snakes[0].ID, "the snake being replayed goes first")
+	assert.Equal(t, 15, board.FoodSpawnChance, "searched by the game's rules")
+	assert.Equal(t, 1, board.MinimumFood)
+	_, ok = game.frameBoard(game.Frames[2], "us")
+	assert.False(t, ok, "we're dead by the last frame")
+
+	turns := replayImportedGame(context.Background(), game, "us", 100*time.Millisecond, defaultSearchConfig)
+	require.Len(t, turns, 2)
+	assert.Equal(t, "left", turns[0].Played)
+	assert.False(t, turns[0].Agreed(), "we wouldn't have walked into the corner")
+	assert.Equal(t, 1, turns[1].Turn)
+	assert.Equal(t, "up", turns[1].Played)
+
+	ids, err := gameSnakeIDs(game, "Gregory")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us"}, ids)
+	ids, err = gameSnakeIDs(game, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us", "them"}, ids)
+	_, err = gameSnakeIDs(game, "nobody")
+	assert.Error(t, err)
+}
+
+func TestEngineGameRules(t *testing.T) {
+	var end FrameEvent
+	end.Type = "game_end"
+	end.Data.ID = "imported"
+	end.Data.Ruleset = map[string]string{"name": "wrapped", "foodSpawnChance": "15", "minimumFood": "1", "hazardDamagePerTurn": "100", "shrinkEveryNTurns": "25"}
+	end.Data.Map = "hz_islands_bridges"
+	game := engineGameRules(end)
+	assert.Equal(t, Game{
+		ID:  "imported",
+		Map: "hz_islands_bridges",
+		Ruleset: Ruleset{Name: "wrapped", Settings: Settings{
+			FoodSpawnChance:     15,
+			MinimumFood:         1,
+			HazardDamagePerTurn: 100,
+			Royale:              RoyaleSettings{ShrinkEveryNTurns: 25},
+		}},
+	}, game)
+
+	frames := GameFrames{Width: 11, Height: 11, Game: game}
+	board, ok := frames.frameBoard(importTestFrame(7, FrameSnake{ID: "us", Health: 90, Body: []Point{{0, 0}, {0, 1}}}), "us")
+	require.True(t, ok)
+	assert.True(t, board.Wrapped)
+	assert.Equal(t, 100, board.HazardDamage)
+	assert.Zero(t, board.ShrinkEvery, "only royale shrinks")
+	assert.Equal(t, 7, board.Turn)
+}
+
+func TestRunImport(t *testing.T) {
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	server := serveGameFrames(t, importTestFrames())
+	var out strings.Builder
+	err := runImport([]string{"-snake", "us", "-search", "50ms", "-engine", "ws" + strings.TrimPrefix(server.URL, "http"), "imported"}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "us turn 0: played left, we'd go ")
+	assert.Contains(t, out.String(), "of 2 turns")
+	assert.Error(t, runImport(nil, &out), "needs a game id")
+}
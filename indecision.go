@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+const (
+	// normalised entropy above this is considered the search failing to separate moves
+	indecisionHighEntropy = 0.9
+	// how many turns in a row entropy has to stay high before we call it persistent
+	indecisionPersistTurns = 3
+	// jump in entropy compared to the recent average that counts as a spike
+	indecisionSpikeDelta = 0.35
+	// number of previous turns used for the rolling average
+	indecisionWindow = 5
+)
+
+var (
+	// alerting on indecision is noisy so only do it when asked
+	indecisionAlertsEnabled = os.Getenv("INDECISION_ALERTS") == "true"
+)
+
+// IndecisionEvent records a turn where the search couldn't pick a clear move.
+type IndecisionEvent struct {
+	Turn    int     `json:"turn"`
+	Entropy float64 `json:"entropy"`
+	Reason  string  `json:"reason"`
+}
+
+// IndecisionTracker keeps the entropy history for a single game.
+type IndecisionTracker struct {
+	mu        sync.Mutex
+	entropies []float64
+	highRun   int
+	events    []IndecisionEvent
+}
+
+// rootVisitEntropy returns the shannon entropy of the root's child visit distribution,
+// normalised to [0,1] by the number of children. 0 means every visit went to one move,
+// 1 means visits were spread evenly across all moves.
+func rootVisitEntropy(node *Node) float64 {
+	if node == nil || len(node.Children()) < 2 {
+		return 0
+	}
+
+	total := 0.0
+	for _, child := range node.Children() {
+		total += float64(child.Visits)
+	}
+	if total == 0 {
+		return 0
+	}
+
+	entropy := 0.0
+	for _, child := range node.Children() {
+		if child.Visits == 0 {
+			continue
+		}
+		p := float64(child.Visits) / total
+		entropy -= p * math.Log(p)
+	}
+
+	return entropy / math.Log(float64(len(node.Children())))
+}
+
+// Observe adds the entropy for a turn and returns an event if the turn looks indecisive.
+func (t *IndecisionTracker) Observe(turn int, entropy float64) *IndecisionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var event *IndecisionEvent
+
+	// compare against the average of the last few turns before recording this one
+	if len(t.entropies) >= indecisionWindow {
+		recent := t.entropies[len(t.entropies)-indecisionWindow:]
+		mean := 0.0
+		for _, e := range recent {
+			mean += e
+		}
+		mean /= float64(len(recent))
+		if entropy-mean > indecisionSpikeDelta {
+			event = &IndecisionEvent{
+				Turn:    turn,
+				Entropy: entropy,
+				Reason:  fmt.Sprintf("spike from %.2f", mean),
+			}
+		}
+	}
+
+	if entropy > indecisionHighEntropy {
+		t.highRun++
+	} else {
+		t.highRun = 0
+	}
+	if event == nil && t.highRun >= indecisionPersistTurns {
+		event = &IndecisionEvent{
+			Turn:    turn,
+			Entropy: entropy,
+			Reason:  fmt.Sprintf("high for %d turns", t.highRun),
+		}
+	}
+
+	t.entropies = append(t.entropies, entropy)
+	if event != nil {
+		t.events = append(t.events, *event)
+	}
+
+	return event
+}
+
+// Events returns a copy of every indecision event seen this game.
+func (t *IndecisionTracker) Events() []IndecisionEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]IndecisionEvent(nil), t.events...)
+}
+
+// Summary gives a short description of the game's indecision for postmortems.
+func (t *IndecisionTracker) Summary() string {
+	events := t.Events()
+	if len(events) == 0 {
+		return ""
+	}
+
+	worst := events[0]
+	for _, event := range events[1:] {
+		if event.Entropy > worst.Entropy {
+			worst = event
+		}
+	}
+	return fmt.Sprintf("indecisive on %d turns (worst turn %d, %.2f)", len(events), worst.Turn, worst.Entropy)
+}
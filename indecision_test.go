@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootVisitEntropy(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Visits      []int64
+		Expected    float64
+	}{
+		{
+			Description: "no children",
+			Visits:      nil,
+			Expected:    0,
+		},
+		{
+			Description: "single child is never indecisive",
+			Visits:      []int64{100},
+			Expected:    0,
+		},
+		{
+			Description: "all visits on one move",
+			Visits:      []int64{100, 0, 0},
+			Expected:    0,
+		},
+		{
+			Description: "even split is maximum entropy",
+			Visits:      []int64{50, 50, 50, 50},
+			Expected:    1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			root := &Node{}
+			for _, visits := range tc.Visits {
+				root.setChildren(append(root.Children(), &Node{Visits: visits, Parent: root}))
+			}
+			assert.InDelta(t, tc.Expected, rootVisitEntropy(root), 1e-9)
+		})
+	}
+}
+
+func TestIndecisionTracker(t *testing.T) {
+	t.Run("persistent high entropy", func(t *testing.T) {
+		tracker := &IndecisionTracker{}
+		assert.Nil(t, tracker.Observe(1, 0.95))
+		assert.Nil(t, tracker.Observe(2, 0.95))
+		event := tracker.Observe(3, 0.95)
+		if assert.NotNil(t, event) {
+			assert.Equal(t, 3, event.Turn)
+		}
+		assert.Len(t, tracker.Events(), 1)
+	})
+
+	t.Run("spike against rolling average", func(t *testing.T) {
+		tracker := &IndecisionTracker{}
+		for turn := 0; turn < indecisionWindow; turn++ {
+			assert.Nil(t, tracker.Observe(turn, 0.2))
+		}
+		event := tracker.Observe(indecisionWindow, 0.7)
+		if assert.NotNil(t, event) {
+			assert.Contains(t, event.Reason, "spike")
+		}
+		assert.Contains(t, tracker.Summary(), "indecisive on 1 turns")
+	})
+
+	t.Run("decisive game has no summary", func(t *testing.T) {
+		tracker := &IndecisionTracker{}
+		for turn := 0; turn < 10; turn++ {
+			assert.Nil(t, tracker.Observe(turn, 0.1))
+		}
+		assert.Empty(t, tracker.Summary())
+	})
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// leaseTTL is how long an instance owns a game without renewing. Long enough that renewing now
+	// and then is cheap, short enough that a dead owner only costs a couple of moves.
+	leaseTTL = 10 * time.Second
+	// forwardedHeader marks a game request another instance passed on to us because we own the game.
+	forwardedHeader = "X-Snake-Forwarded"
+	// forwardBudgetHeader tells the owner how many milliseconds it has to answer a forwarded request in
+	forwardBudgetHeader = "X-Snake-Forward-Budget"
+	// forwardShare is the most of the move's timeout a forward gets. If the owner's gone that's all it
+	// costs, and the rest is left to search the move here.
+	forwardShare = 0.2
+)
+
+// errLeaseConflict means someone else wrote the lease between us reading and writing it.
+var errLeaseConflict = errors.New("lease changed since it was read")
+
+// Lease is one instance's claim on a game. The instance that owns a game keeps its session,
+// so the search tree and everything else we track carries from move to move.
+type Lease struct {
+	GameID  string    `json:"game_id"`
+	Owner   string    `json:"owner"`
+	URL     string    `json:"url"` // where the owner can be reached for forwarding
+	Expires time.Time `json:"expires"`
+
+	generation int64 // the store's version of the lease, for writing it back conditionally
+}
+
+// Expired says whether the owner has stopped renewing.
+func (l Lease) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+// LeaseStore keeps the leases where every instance can see them. Writes only succeed against the
+// generation that was read, so two instances can't both think they took the same game.
+type LeaseStore interface {
+	// Get returns the game's lease and whether there is one.
+	Get(ctx context.Context, gameID string) (Lease, bool, error)
+	// Put writes the lease if the stored one is still at the generation, 0 meaning there mustn't be one yet.
+	// It returns the lease with its new generation, or errLeaseConflict.
+	Put(ctx context.Context, lease Lease, generation int64) (Lease, error)
+	// Delete removes the lease if it's still at the generation.
+	Delete(ctx context.Context, gameID string, generation int64) error
+}
+
+// bucketLeaseStore keeps leases as objects in our bucket, using generation preconditions to write them
+// atomically.
+type bucketLeaseStore struct {
+	once   sync.Once
+	client *storage.Client
+	err    error
+}
+
+// object opens the lease's object, keeping the client around since leases are checked on every move.
+func (s *bucketLeaseStore) object(ctx context.Context, gameID string) (*storage.ObjectHandle, error) {
+	s.once.Do(func() {
+		s.client, s.err = storage.NewClient(context.Background())
+	})
+	if s.err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", s.err)
+	}
+	return s.client.Bucket(bucketName).Object(fmt.Sprintf("leases/%s.json", gameID)), nil
+}
+
+func (s *bucketLeaseStore) Get(ctx context.Context, gameID string) (Lease, bool, error) {
+	object, err := s.object(ctx, gameID)
+	if err != nil {
+		return Lease{}, false, err
+	}
+	reader, err := object.NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return Lease{}, false, nil
+	}
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("failed to read lease: %w", err)
+	}
+	defer reader.Close()
+
+	var lease Lease
+	if err := json.NewDecoder(reader).Decode(&lease); err != nil {
+		return Lease{}, false, fmt.Errorf("failed to decode lease: %w", err)
+	}
+	lease.generation = reader.Attrs.Generation
+	return lease, true, nil
+}
+
+func (s *bucketLeaseStore) Put(ctx context.Context, lease Lease, generation int64) (Lease, error) {
+	object, err := s.object(ctx, lease.GameID)
+	if err != nil {
+		return Lease{}, err
+	}
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return Lease{}, fmt.Errorf("failed to encode lease: %w", err)
+	}
+	conditions := storage.Conditions{DoesNotExist: true}
+	if generation != 0 {
+		conditions = storage.Conditions{GenerationMatch: generation}
+	}
+
+	writer := object.If(conditions).NewWriter(ctx)
+	writer.ContentType = "application/json"
+	writer.Metadata = engineBuild.Metadata()
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return Lease{}, fmt.Errorf("failed to write lease: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		if isPreconditionFailed(err) {
+			return Lease{}, errLeaseConflict
+		}
+		return Lease{}, fmt.Errorf("failed to close lease writer: %w", err)
+	}
+	lease.generation = writer.Attrs().Generation
+	return lease, nil
+}
+
+func (s *bucketLeaseStore) Delete(ctx context.Context, gameID string, generation int64) error {
+	object, err := s.object(ctx, gameID)
+	if err != nil {
+		return err
+	}
+	err = object.If(storage.Conditions{GenerationMatch: generation}).Delete(ctx)
+	if isPreconditionFailed(err) {
+		return errLeaseConflict
+	}
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete lease: %w", err)
+	}
+	return nil
+}
+
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
+
+// Coordinator decides which of several instances serving the same snake owns each game. Whichever
+// instance the router hands a request to claims the game if nobody holds it, passes the request on to the
+// owner if someone does, and takes the game over if the owner doesn't answer.
+type Coordinator struct {
+	store  LeaseStore
+	self   string // this instance's owner id
+	url    string // where other instances can reach us
+	ttl    time.Duration
+	client *http.Client
+
+	mu        sync.Mutex
+	leases    map[string]Lease // what we last saw of each game's lease
+	renewing  map[string]bool
+	forwards  int64
+	takeovers int64
+}
+
+func newCoordinator(store LeaseStore, url string) *Coordinator {
+	return &Coordinator{
+		store:    store,
+		self:     fmt.Sprintf("%s-%s", os.Getenv("K_REVISION"), uuid.NewString()[:8]),
+		url:      url,
+		ttl:      leaseTTL,
+		client:   &http.Client{},
+		leases:   make(map[string]Lease),
+		renewing: make(map[string]bool),
+	}
+}
+
+// gameOwnership is set when several instances serve the same snake, with REDUNDANT=true and SELF_URL
+// set to this service's own url. Left nil, every game is ours.
+var gameOwnership *Coordinator
+
+func coordinatorFromEnv() *Coordinator {
+	if os.Getenv("REDUNDANT") != "true" {
+		return nil
+	}
+	url := os.Getenv("SELF_URL")
+	if url == "" {
+		slog.Error("REDUNDANT is set without SELF_URL, serving every game ourselves")
+		return nil
+	}
+	return newCoordinator(&bucketLeaseStore{}, url)
+}
+
+// Claim returns the game's lease and whether we own it, taking it if nobody does or, with steal,
+// even if someone does.
+func (c *Coordinator) Claim(ctx context.Context, gameID string, steal bool) (Lease, bool, error) {
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.leases[gameID]
+	c.mu.Unlock()
+	if ok && !cached.Expired(now) {
+		if cached.Owner == c.self {
+			// renew in the background well before it runs out so moves don't wait on the store
+			if cached.Expires.Sub(now) < c.ttl/2 {
+				c.renewLater(gameID)
+			}
+			return cached, true, nil
+		}
+		if !steal {
+			return cached, false, nil
+		}
+	}
+
+	lease, exists, err := c.store.Get(ctx, gameID)
+	if err != nil {
+		return Lease{}, false, err
+	}
+	if exists && lease.Owner != c.self && !lease.Expired(now) && !steal {
+		c.remember(lease)
+		return lease, false, nil
+	}
+
+	var generation int64
+	if exists {
+		generation = lease.generation
+	}
+	claimed, err := c.store.Put(ctx, Lease{GameID: gameID, Owner: c.self, URL: c.url, Expires: now.Add(c.ttl)}, generation)
+	if errors.Is(err, errLeaseConflict) {
+		// someone beat us to it, so they own it
+		lease, _, err := c.store.Get(ctx, gameID)
+		if err != nil {
+			return Lease{}, false, err
+		}
+		c.remember(lease)
+		return lease, lease.Owner == c.self, nil
+	}
+	if err != nil {
+		return Lease{}, false, err
+	}
+	c.remember(claimed)
+	return claimed, true, nil
+}
+
+func (c *Coordinator) remember(lease Lease) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leases[lease.GameID] = lease
+}
+
+// renewLater extends our lease without holding up the move that noticed it needed it.
+func (c *Coordinator) renewLater(gameID string) {
+	c.mu.Lock()
+	if c.renewing[gameID] {
+		c.mu.Unlock()
+		return
+	}
+	c.renewing[gameID] = true
+	lease := c.leases[gameID]
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.renewing, gameID)
+			c.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), c.ttl/2)
+		defer cancel()
+		renewed := lease
+		renewed.Expires = time.Now().Add(c.ttl)
+		renewed, err := c.store.Put(ctx, renewed, lease.generation)
+		if err != nil {
+			slog.Warn("failed to renew lease", "game_id", gameID, "error", err)
+			// if someone took it off us the next claim finds out from the store
+			c.mu.Lock()
+			delete(c.leases, gameID)
+			c.mu.Unlock()
+			return
+		}
+		c.remember(renewed)
+	}()
+}
+
+// Release gives up the game once it's over.
+func (c *Coordinator) Release(ctx context.Context, gameID string) error {
+	c.mu.Lock()
+	lease, ok := c.leases[gameID]
+	delete(c.leases, gameID)
+	c.mu.Unlock()
+	if !ok || lease.Owner != c.self {
+		return nil
+	}
+	// if it's changed since, someone took it off us and it's theirs to give up
+	if err := c.store.Delete(ctx, gameID, lease.generation); err != nil && !errors.Is(err, errLeaseConflict) {
+		return err
+	}
+	return nil
+}
+
+// forward passes the request on to the game's owner and copies back its answer, telling the owner how
+// long it has. Anything but an answer from the owner's handler counts as the owner being gone.
+func (c *Coordinator) forward(w http.ResponseWriter, r *http.Request, body []byte, lease Lease, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, r.Method, lease.URL+r.URL.Path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(forwardedHeader, c.self)
+	req.Header.Set(forwardBudgetHeader, strconv.FormatInt(timeout.Milliseconds(), 10))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("owner answered %s", resp.Status)
+	}
+	answer, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.forwards++
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(answer)
+	return nil
+}
+
+// forwardTimeout is how long a forward gets out of the move's timeout.
+func forwardTimeout(timeoutMs int) time.Duration {
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	return time.Duration(float64(timeout) * forwardShare)
+}
+
+// forwardedBudget is how long the instance that forwarded the request gave us to answer, less the
+// margin for getting the answer back to it. It's zero if the request wasn't forwarded with a budget.
+func forwardedBudget(r *http.Request) time.Duration {
+	ms, err := strconv.Atoi(r.Header.Get(forwardBudgetHeader))
+	if err != nil || ms <= 0 {
+		return 0
+	}
+	if budget := time.Duration(ms)*time.Millisecond - minMoveMargin; budget > minSearchTime {
+		return budget
+	}
+	return minSearchTime
+}
+
+type requestStartKey struct{}
+
+// requestStart is when the request got to us, before anything like a failed forward ate into its time.
+func requestStart(r *http.Request) time.Time {
+	if start, ok := r.Context().Value(requestStartKey{}).(time.Time); ok {
+		return start
+	}
+	return time.Now()
+}
+
+// withGameOwnership sends each game's requests to the instance that owns it, taking the game over if
+// the owner has died. Without a coordinator every game is ours.
+func withGameOwnership(c *Coordinator) middleware {
+	return func(next http.Handler) http.Handler {
+		if c == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the move's clock started before we went looking for the owner
+			r = r.WithContext(context.WithValue(r.Context(), requestStartKey{}, time.Now()))
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			var game BattleSnakeGame
+			if err := json.Unmarshal(body, &game); err != nil || game.Game.ID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gameID := game.Game.ID
+
+			// the other instance already decided we own it, so don't bounce it back
+			if r.Header.Get(forwardedHeader) == "" {
+				lease, owned, err := c.Claim(r.Context(), gameID, false)
+				if err != nil {
+					// better to play the move without the session than not at all
+					slog.Warn("failed to check game ownership", "game_id", gameID, "error", err)
+				}
+				if err == nil && !owned {
+					err := c.forward(w, r, body, lease, forwardTimeout(game.Game.Timeout))
+					if err == nil {
+						return
+					}
+					slog.Warn("owner didn't answer, taking the game over", "game_id", gameID, "owner", lease.Owner, "error", err)
+					if _, _, err := c.Claim(r.Context(), gameID, true); err != nil {
+						slog.Warn("failed to take game over", "game_id", gameID, "error", err)
+					}
+					c.mu.Lock()
+					c.takeovers++
+					c.mu.Unlock()
+				}
+			}
+
+			next.ServeHTTP(w, r)
+
+			if r.URL.Path == "/end" {
+				if err := c.Release(context.WithoutCancel(r.Context()), gameID); err != nil {
+					slog.Warn("failed to release lease", "game_id", gameID, "error", err)
+				}
+			}
+		})
+	}
+}
+
+// LeaseStats is what this instance knows about game ownership, for the admin api.
+type LeaseStats struct {
+	Owner     string  `json:"owner"`
+	URL       string  `json:"url"`
+	Leases    []Lease `json:"leases"`
+	Forwards  int64   `json:"forwards"`  // requests passed on to the owner
+	Takeovers int64   `json:"takeovers"` // games taken off an owner that stopped answering
+}
+
+// Stats reports the leases we've seen and how often we've forwarded or taken over.
+func (c *Coordinator) Stats() LeaseStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := LeaseStats{Owner: c.self, URL: c.url, Leases: make([]Lease, 0, len(c.leases)), Forwards: c.forwards, Takeovers: c.takeovers}
+	for _, lease := range c.leases {
+		stats.Leases = append(stats.Leases, lease)
+	}
+	sort.Slice(stats.Leases, func(i, j int) bool {
+		return stats.Leases[i].GameID < stats.Leases[j].GameID
+	})
+	return stats
+}
+
+// handleLeases shows which games this instance owns or is forwarding.
+func handleLeases(w http.ResponseWriter, r *http.Request) {
+	if gameOwnership == nil {
+		http.Error(w, "not running redundantly", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, gameOwnership.Stats())
+}
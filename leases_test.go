@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLeaseStore is a LeaseStore shared by coordinators in the same test.
+type memoryLeaseStore struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+	next   int64
+}
+
+func newMemoryLeaseStore() *memoryLeaseStore {
+	return &memoryLeaseStore{leases: make(map[string]Lease)}
+}
+
+func (s *memoryLeaseStore) Get(ctx context.Context, gameID string) (Lease, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lease, ok := s.leases[gameID]
+	return lease, ok, nil
+}
+
+func (s *memoryLeaseStore) Put(ctx context.Context, lease Lease, generation int64) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[lease.GameID].generation != generation {
+		return Lease{}, errLeaseConflict
+	}
+	s.next++
+	lease.generation = s.next
+	s.leases[lease.GameID] = lease
+	return lease, nil
+}
+
+func (s *memoryLeaseStore) Delete(ctx context.Context, gameID string, generation int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[gameID].generation != generation {
+		return errLeaseConflict
+	}
+	delete(s.leases, gameID)
+	return nil
+}
+
+func TestCoordinatorClaim(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLeaseStore()
+	first := newCoordinator(store, "http://first")
+	second := newCoordinator(store, "http://second")
+
+	lease, owned, err := first.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	assert.True(t, owned, "nobody had it")
+	assert.Equal(t, "http://first", lease.URL)
+
+	lease, owned, err = second.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	assert.False(t, owned, "the first instance has it")
+	assert.Equal(t, first.self, lease.Owner)
+
+	_, owned, err = first.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	assert.True(t, owned, "still ours")
+
+	_, owned, err = second.Claim(ctx, "game", true)
+	require.NoError(t, err)
+	assert.True(t, owned, "taken over")
+	stored, _, _ := store.Get(ctx, "game")
+	assert.Equal(t, second.self, stored.Owner)
+
+	require.NoError(t, second.Release(ctx, "game"))
+	_, exists, _ := store.Get(ctx, "game")
+	assert.False(t, exists, "released once the game is over")
+	require.NoError(t, first.Release(ctx, "game"), "releasing someone else's game does nothing")
+}
+
+func TestCoordinatorClaimsExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLeaseStore()
+	dead := newCoordinator(store, "http://dead")
+	dead.ttl = time.Millisecond
+	alive := newCoordinator(store, "http://alive")
+
+	_, owned, err := dead.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	require.True(t, owned)
+	time.Sleep(5 * time.Millisecond)
+
+	lease, owned, err := alive.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	assert.True(t, owned, "the owner stopped renewing")
+	assert.Equal(t, alive.self, lease.Owner)
+}
+
+func TestCoordinatorRenewsInBackground(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLeaseStore()
+	c := newCoordinator(store, "http://self")
+	c.ttl = 100 * time.Millisecond
+
+	first, _, err := c.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	_, owned, err := c.Claim(ctx, "game", false)
+	require.NoError(t, err)
+	assert.True(t, owned)
+
+	assert.Eventually(t, func() bool {
+		stored, _, _ := store.Get(ctx, "game")
+		return stored.Expires.After(first.Expires)
+	}, time.Second, 5*time.Millisecond)
+}
+
+// ownershipServer serves /move for the coordinator, answering with the name so tests can see who played.
+func ownershipServer(c *Coordinator, name string) *httptest.Server {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"move": "up", "shout": name})
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/move", withGameOwnership(c)(http.HandlerFunc(handler)))
+	mux.Handle("/end", withGameOwnership(c)(http.HandlerFunc(handler)))
+	return httptest.NewServer(mux)
+}
+
+func postGame(t *testing.T, url, path string) string {
+	t.Helper()
+	body := `{"game":{"id":"game","timeout":500},"turn":3}`
+	resp, err := http.Post(url+path, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var answer map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&answer))
+	return answer["shout"]
+}
+
+func TestGameOwnershipForwardsAndFailsOver(t *testing.T) {
+	store := newMemoryLeaseStore()
+	owner := newCoordinator(store, "")
+	ownerServer := ownershipServer(owner, "owner")
+	owner.url = ownerServer.URL
+	other := newCoordinator(store, "")
+	otherServer := ownershipServer(other, "other")
+	defer otherServer.Close()
+	other.url = otherServer.URL
+
+	assert.Equal(t, "owner", postGame(t, ownerServer.URL, "/move"), "first to see the game owns it")
+	assert.Equal(t, "owner", postGame(t, otherServer.URL, "/move"), "passed on to the owner")
+	assert.Equal(t, int64(1), other.Stats().Forwards)
+
+	// the owner dies partway through the game
+	ownerServer.Close()
+	assert.Equal(t, "other", postGame(t, otherServer.URL, "/move"), "taken over")
+	assert.Equal(t, int64(1), other.Stats().Takeovers)
+	assert.Equal(t, "other", postGame(t, otherServer.URL, "/move"), "ours from now on")
+
+	assert.Equal(t, "other", postGame(t, otherServer.URL, "/end"))
+	_, exists, _ := store.Get(context.Background(), "game")
+	assert.False(t, exists, "lease given up when the game ends")
+}
+
+func TestGameOwnershipForwardGetsASliceOfTheMove(t *testing.T) {
+	store := newMemoryLeaseStore()
+	// an owner that's still up but never answers
+	hung := make(chan struct{})
+	budgets := make(chan string, 1)
+	ownerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budgets <- r.Header.Get(forwardBudgetHeader)
+		<-hung
+	}))
+	defer ownerServer.Close()
+	defer close(hung)
+	_, err := store.Put(context.Background(), Lease{GameID: "game", Owner: "owner", URL: ownerServer.URL, Expires: time.Now().Add(leaseTTL)}, 0)
+	require.NoError(t, err)
+
+	other := newCoordinator(store, "")
+	var waited time.Duration
+	handler := withGameOwnership(other)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		waited = time.Since(requestStart(r))
+		writeJSON(w, map[string]string{"move": "up", "shout": "other"})
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// a 500ms move gives the forward 100ms
+	assert.Equal(t, "other", postGame(t, server.URL, "/move"))
+	assert.Equal(t, "100", <-budgets, "the owner's told to answer in time")
+	assert.GreaterOrEqual(t, waited, 100*time.Millisecond, "the wait comes off our own search")
+	assert.Less(t, waited, 400*time.Millisecond, "not the whole move")
+}
+
+func TestForwardedBudget(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Header      string
+		Expected    time.Duration
+	}{
+		{Description: "not forwarded", Header: "", Expected: 0},
+		{Description: "garbage", Header: "soon", Expected: 0},
+		{Description: "keeps a margin for the way back", Header: "400", Expected: 400*time.Millisecond - minMoveMargin},
+		{Description: "never less than the least search", Header: "60", Expected: minSearchTime},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/move", nil)
+			if tc.Header != "" {
+				r.Header.Set(forwardBudgetHeader, tc.Header)
+			}
+			assert.Equal(t, tc.Expected, forwardedBudget(r))
+		})
+	}
+}
+
+func TestGameOwnershipOffByDefault(t *testing.T) {
+	called := false
+	handler := withGameOwnership(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(`{"game":{"id":"game"}}`)))
+	assert.True(t, called)
+}
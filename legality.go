@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MoveSource is how safe the move we sent was, as a canary for regressions in move generation or the search.
+type MoveSource string
+
+const (
+	// MoveSafe is one of generateSafeMoves.
+	MoveSafe MoveSource = "safe"
+	// MoveBackup is the search's pick with nothing safe to choose from.
+	MoveBackup MoveSource = "backup"
+	// MoveRandom is the last resort guess, when there was no search to pick from and nothing safe.
+	MoveRandom MoveSource = "random"
+)
+
+const (
+	// legalityMinTurns is how many turns a game needs before its rates mean anything
+	legalityMinTurns = 20
+	// share of turns on backup moves that's worth hearing about. a game can end on one,
+	// but more than that means we're getting ourselves stuck or the search isn't finding the safe moves.
+	legalityBackupAlertRate = 0.05
+	// share of turns on random moves that's worth hearing about. any at all is suspicious.
+	legalityRandomAlertRate = 0.01
+)
+
+// searchSource is where a move the searches picked came from. They only look past the safe moves when
+// there aren't any, and then it's the least bad of the rest.
+func searchSource(board Board) MoveSource {
+	if len(board.Snakes) > 0 && len(generateSafeMoves(board, 0)) > 0 {
+		return MoveSafe
+	}
+	return MoveBackup
+}
+
+// LegalityCounts is how many of a game's moves came from each set.
+type LegalityCounts struct {
+	Safe   int `json:"safe"`
+	Backup int `json:"backup"`
+	Random int `json:"random"`
+}
+
+// Total is every move counted.
+func (c LegalityCounts) Total() int {
+	return c.Safe + c.Backup + c.Random
+}
+
+// LegalityAlert is a game's backup or random rate going over its threshold.
+type LegalityAlert struct {
+	Turn   int        `json:"turn"`
+	Source MoveSource `json:"source"`
+	Rate   float64    `json:"rate"`
+}
+
+// LegalityTracker counts where a single game's moves came from and raises an alert, once per game,
+// when too many weren't safe.
+type LegalityTracker struct {
+	mu      sync.Mutex
+	counts  LegalityCounts
+	alerted bool
+}
+
+// Record counts the turn's move, returning an alert the first time a rate crosses its threshold.
+func (t *LegalityTracker) Record(turn int, source MoveSource) *LegalityAlert {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch source {
+	case MoveSafe:
+		t.counts.Safe++
+	case MoveBackup:
+		t.counts.Backup++
+	default:
+		t.counts.Random++
+	}
+
+	total := t.counts.Total()
+	if t.alerted || total < legalityMinTurns {
+		return nil
+	}
+	if rate := float64(t.counts.Random) / float64(total); rate > legalityRandomAlertRate {
+		t.alerted = true
+		return &LegalityAlert{Turn: turn, Source: MoveRandom, Rate: rate}
+	}
+	if rate := float64(t.counts.Backup) / float64(total); rate > legalityBackupAlertRate {
+		t.alerted = true
+		return &LegalityAlert{Turn: turn, Source: MoveBackup, Rate: rate}
+	}
+	return nil
+}
+
+// Counts returns the game's counts so far.
+func (t *LegalityTracker) Counts() LegalityCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts
+}
+
+// Summary describes the game's unsafe moves for postmortems, or nothing if every move was safe.
+func (t *LegalityTracker) Summary() string {
+	counts := t.Counts()
+	if counts.Backup == 0 && counts.Random == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d backup, %d random of %d moves", counts.Backup, counts.Random, counts.Total())
+}
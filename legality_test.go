@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trappedBoard has us in the bottom left corner with the other snake's body blocking the only way out.
+func trappedBoard() Board {
+	return Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 0, Y: 0}, Health: 100, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}}},
+			{ID: "them", Head: Point{X: 3, Y: 0}, Health: 100, Body: []Point{{X: 3, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}},
+		},
+	}
+}
+
+func TestMoveAndSourceForChild(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Board       Board
+		Searched    bool
+		Source      MoveSource
+	}{
+		{Description: "searched", Board: cornerBoard(), Searched: true, Source: MoveSafe},
+		{Description: "searched with nothing safe", Board: trappedBoard(), Searched: true, Source: MoveBackup},
+		{Description: "guessed", Board: cornerBoard(), Source: MoveSafe},
+		{Description: "guessed with nothing safe", Board: trappedBoard(), Source: MoveRandom},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			root := NewNode(tc.Board, -1, nil)
+			var child *Node
+			if tc.Searched {
+				root = MCTS(context.Background(), "legality", tc.Board, 50, 1, make(map[string]*Node))
+				child = chooseRootChild(root).Node
+				require.NotNil(t, child)
+			}
+			move, source := moveAndSourceForChild(root, child)
+			assert.Equal(t, tc.Source, source)
+			if tc.Source == MoveSafe {
+				assert.Contains(t, generateSafeMoves(tc.Board, 0), directionFromString(move))
+			}
+		})
+	}
+}
+
+func TestLegalityTracker(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Safe        int
+		Backup      int
+		Random      int
+		Alert       MoveSource
+	}{
+		{Description: "all safe", Safe: 40},
+		{Description: "ending on a backup move", Safe: 39, Backup: 1},
+		{Description: "too many backups", Safe: 30, Backup: 3, Alert: MoveBackup},
+		{Description: "any random", Safe: 30, Random: 1, Alert: MoveRandom},
+		{Description: "too early to tell", Safe: 5, Backup: 5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			tracker := &LegalityTracker{}
+			var alerts []*LegalityAlert
+			record := func(n int, source MoveSource) {
+				for i := 0; i < n; i++ {
+					if alert := tracker.Record(tracker.Counts().Total(), source); alert != nil {
+						alerts = append(alerts, alert)
+					}
+				}
+			}
+			record(tc.Safe, MoveSafe)
+			record(tc.Backup, MoveBackup)
+			record(tc.Random, MoveRandom)
+
+			assert.Equal(t, LegalityCounts{Safe: tc.Safe, Backup: tc.Backup, Random: tc.Random}, tracker.Counts())
+			if tc.Alert == "" {
+				assert.Empty(t, alerts)
+				return
+			}
+			if assert.Len(t, alerts, 1, "only alerts once a game") {
+				assert.Equal(t, tc.Alert, alerts[0].Source)
+			}
+		})
+	}
+}
+
+func TestLegalitySummary(t *testing.T) {
+	tracker := &LegalityTracker{}
+	tracker.Record(0, MoveSafe)
+	assert.Empty(t, tracker.Summary())
+	tracker.Record(1, MoveBackup)
+	assert.Equal(t, "1 backup, 0 random of 2 moves", tracker.Summary())
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// liveGamesKept is how many games in progress the dashboard follows. A game we never hear the end of
+	// is dropped once this many newer ones have started.
+	liveGamesKept = 20
+	// liveSubscriberBuffer is how many updates a dashboard can fall behind before it misses some.
+	liveSubscriberBuffer = 64
+	// liveHeartbeat is how often an idle stream gets a comment so proxies don't close it.
+	liveHeartbeat = 15 * time.Second
+)
+
+// LiveUpdate is a move we just made, for the dashboard to show while the game is still going.
+type LiveUpdate struct {
+	GameID     string             `json:"game_id"`
+	YouID      string             `json:"you_id"`
+	Turn       int                `json:"turn"`
+	Move       string             `json:"move"`
+	Board      string             `json:"board"`            // rendered with the move on it
+	Visits     map[string]int64   `json:"visits,omitempty"` // root visits by move, left out when the move wasn't searched
+	Eval       map[string]float64 `json:"eval"`
+	Value      float64            `json:"value"`
+	DurationMs int64              `json:"duration_ms"`
+	Ended      bool               `json:"ended,omitempty"` // the game's over and nothing else is coming for it
+}
+
+func liveUpdate(gameID string, entry GameLogEntry) LiveUpdate {
+	return LiveUpdate{
+		GameID:     gameID,
+		YouID:      entry.YouID,
+		Turn:       entry.Turn,
+		Move:       entry.Move,
+		Board:      visualizeBoard(entry.Board, WithMove(directionFromString(entry.Move), 0)),
+		Visits:     entry.Visits,
+		Eval:       entry.Eval,
+		Value:      entry.Decision.Value,
+		DurationMs: entry.Decision.DurationMs,
+	}
+}
+
+// LiveHub passes moves on to every dashboard watching, and keeps the moves of games in progress so a
+// dashboard opened mid game can draw the trends so far.
+type LiveHub struct {
+	limit int
+
+	mu          sync.Mutex
+	games       map[string][]LiveUpdate
+	order       []string // game ids, oldest first
+	subscribers map[chan LiveUpdate]struct{}
+	closed      bool
+}
+
+func newLiveHub(limit int) *LiveHub {
+	return &LiveHub{
+		limit:       limit,
+		games:       make(map[string][]LiveUpdate),
+		subscribers: make(map[chan LiveUpdate]struct{}),
+	}
+}
+
+// liveGames is what the dashboard streams.
+var liveGames = newLiveHub(liveGamesKept)
+
+// Publish sends the update to every dashboard. One that's too far behind misses it rather than holding
+// up the move.
+func (h *LiveHub) Publish(update LiveUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.games[update.GameID]; !ok {
+		h.order = append(h.order, update.GameID)
+		if len(h.order) > h.limit {
+			delete(h.games, h.order[0])
+			h.order = h.order[1:]
+		}
+	}
+	h.games[update.GameID] = append(h.games[update.GameID], update)
+	h.broadcast(update)
+}
+
+// End forgets the game and tells the dashboards it's over.
+func (h *LiveHub) End(gameID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.games[gameID]; !ok {
+		return
+	}
+	delete(h.games, gameID)
+	for i, id := range h.order {
+		if id == gameID {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+	h.broadcast(LiveUpdate{GameID: gameID, Ended: true})
+}
+
+func (h *LiveHub) broadcast(update LiveUpdate) {
+	for subscriber := range h.subscribers {
+		select {
+		case subscriber <- update:
+		default:
+		}
+	}
+}
+
+// Subscribe returns every move of the games in progress, oldest game first, and a channel of the moves
+// after them. Unsubscribe once done watching.
+func (h *LiveHub) Subscribe() ([]LiveUpdate, <-chan LiveUpdate, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var history []LiveUpdate
+	for _, id := range h.order {
+		history = append(history, h.games[id]...)
+	}
+	updates := make(chan LiveUpdate, liveSubscriberBuffer)
+	if h.closed {
+		close(updates)
+	} else {
+		h.subscribers[updates] = struct{}{}
+	}
+	return history, updates, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers, updates)
+	}
+}
+
+// Close ends every stream so shutting down doesn't wait on dashboards that would stay open forever.
+func (h *LiveHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for subscriber := range h.subscribers {
+		close(subscriber)
+		delete(h.subscribers, subscriber)
+	}
+}
+
+func writeLiveEvent(w io.Writer, update LiveUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// handleLiveEvents streams every move we make as server-sent events, starting with the moves so far of
+// the games still going.
+func handleLiveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	history, updates, unsubscribe := liveGames.Subscribe()
+	defer unsubscribe()
+	for _, update := range history {
+		if err := writeLiveEvent(w, update); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := writeLiveEvent(w, update); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// handleLive serves the dashboard itself. The page has nothing in it until it's given the admin secret
+// to stream /live/events with, so it doesn't need the secret to load.
+func handleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, livePage)
+}
+
+// the stream is read with fetch rather than EventSource since EventSource can't send the secret
+const livePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>live</title>
+<style>
+body{font-family:monospace;background:#111;color:#ddd}
+.games{display:flex;flex-wrap:wrap;gap:12px}
+.game{border:1px solid #444;padding:8px;min-width:260px}
+.game.ended{opacity:.4}
+pre{margin:4px 0;line-height:1.1}
+td{padding:0 6px}
+svg{background:#222;display:block;margin:4px 0}
+</style>
+</head>
+<body>
+<h1>live <span id="status">connecting</span></h1>
+<div class="games" id="games"></div>
+<script>
+const games = new Map();
+
+function spark(values, colour) {
+  if (values.length < 2) return '';
+  const min = Math.min(...values), max = Math.max(...values), span = max - min || 1;
+  const points = values.map((v, i) => (i * 240 / (values.length - 1)).toFixed(1) + ',' + (40 - (v - min) * 40 / span).toFixed(1)).join(' ');
+  return '<svg width="240" height="40"><polyline fill="none" stroke="' + colour + '" points="' + points + '"/></svg>';
+}
+
+function escape(text) {
+  const div = document.createElement('div');
+  div.textContent = text;
+  return div.innerHTML;
+}
+
+// a card per snake of ours in the game, since the hosted snakes can end up in the same one
+function render(key) {
+  const game = games.get(key);
+  let card = document.getElementById(key);
+  if (!card) {
+    card = document.createElement('div');
+    card.id = key;
+    card.className = 'game';
+    document.getElementById('games').prepend(card);
+  }
+  card.classList.toggle('ended', game.ended);
+  const last = game.moves[game.moves.length - 1];
+  const visits = Object.entries(last.visits || {}).sort((a, b) => b[1] - a[1])
+    .map(([move, n]) => '<tr><td>' + escape(move) + '</td><td>' + n + '</td></tr>').join('');
+  const evals = Object.entries(last.eval || {})
+    .map(([module, score]) => '<tr><td>' + escape(module) + '</td><td>' + score.toFixed(2) + '</td></tr>').join('');
+  card.innerHTML = '<a href="https://play.battlesnake.com/game/' + encodeURIComponent(last.game_id) + '">' + escape(last.game_id) + '</a>' +
+    '<div>' + escape(last.you_id) + ' turn ' + last.turn + ' ' + escape(last.move) + ' in ' + last.duration_ms + 'ms' + (game.ended ? ' (over)' : '') + '</div>' +
+    '<pre>' + escape(last.board) + '</pre>' +
+    '<table>' + visits + '</table>' +
+    '<div>value</div>' + spark(game.moves.map(m => m.value), '#6c6') +
+    '<div>ms per move</div>' + spark(game.moves.map(m => m.duration_ms), '#c96') +
+    '<table>' + evals + '</table>';
+}
+
+function handle(update) {
+  if (update.ended) {
+    for (const [key, game] of games) {
+      if (game.moves[0].game_id === update.game_id) {
+        game.ended = true;
+        render(key);
+      }
+    }
+    return;
+  }
+  const key = 'game-' + update.game_id + '-' + update.you_id;
+  let game = games.get(key);
+  if (!game) {
+    game = {moves: [], ended: false};
+    games.set(key, game);
+  }
+  // a reconnect sends the game so far again
+  game.moves = game.moves.filter(m => m.turn !== update.turn);
+  game.moves.push(update);
+  game.moves.sort((a, b) => a.turn - b.turn);
+  render(key);
+}
+
+async function stream() {
+  let secret = localStorage.getItem('secret');
+  if (secret === null) {
+    secret = prompt('admin secret') || '';
+    localStorage.setItem('secret', secret);
+  }
+  const status = document.getElementById('status');
+  const response = await fetch('/live/events', {headers: {Authorization: 'Bearer ' + secret}});
+  if (response.status === 401) {
+    localStorage.removeItem('secret');
+    status.textContent = 'wrong secret, reload to try again';
+    return;
+  }
+  status.textContent = 'connected';
+  const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buffer = '';
+  for (;;) {
+    const {value, done} = await reader.read();
+    if (done) break;
+    buffer += value;
+    let end;
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const event = buffer.slice(0, end);
+      buffer = buffer.slice(end + 2);
+      if (event.startsWith('data: ')) handle(JSON.parse(event.slice(6)));
+    }
+  }
+  status.textContent = 'disconnected, reconnecting';
+  setTimeout(stream, 2000);
+}
+
+stream().catch(err => {
+  document.getElementById('status').textContent = 'disconnected, reconnecting';
+  setTimeout(() => location.reload(), 5000);
+});
+</script>
+</body>
+</html>
+`
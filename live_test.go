@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveHub(t *testing.T) {
+	hub := newLiveHub(2)
+	hub.Publish(LiveUpdate{GameID: "a", Turn: 1})
+	hub.Publish(LiveUpdate{GameID: "b", Turn: 1})
+	hub.Publish(LiveUpdate{GameID: "a", Turn: 2})
+
+	history, updates, unsubscribe := hub.Subscribe()
+	var seen []string
+	for _, update := range history {
+		seen = append(seen, update.GameID)
+	}
+	assert.Equal(t, []string{"a", "a", "b"}, seen, "a dashboard opened mid game gets the games so far, oldest first")
+
+	hub.Publish(LiveUpdate{GameID: "c", Turn: 1})
+	assert.Equal(t, "c", (<-updates).GameID)
+	hub.End("b")
+	assert.Equal(t, LiveUpdate{GameID: "b", Ended: true}, <-updates)
+	hub.End("b")
+	assert.Empty(t, updates, "a game only ends once")
+
+	hub.Publish(LiveUpdate{GameID: "d", Turn: 1})
+	<-updates
+	history, _, _ = hub.Subscribe()
+	seen = nil
+	for _, update := range history {
+		seen = append(seen, update.GameID)
+	}
+	assert.Equal(t, []string{"c", "d"}, seen, "the oldest game is dropped once too many are going")
+
+	// a dashboard that isn't reading doesn't hold anything up
+	for i := 0; i < liveSubscriberBuffer*2; i++ {
+		hub.Publish(LiveUpdate{GameID: "d", Turn: i})
+	}
+	assert.Len(t, updates, liveSubscriberBuffer)
+
+	unsubscribe()
+	hub.Close()
+	_, closed, _ := hub.Subscribe()
+	_, ok := <-closed
+	assert.False(t, ok, "nothing streams after the hub's closed")
+}
+
+func TestLiveEventsAfterMove(t *testing.T) {
+	server := httptest.NewServer(newRouter("secret"))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/live/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	game := sessionTestGame("live")
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	move, err := http.Post(server.URL+"/move", "application/json", strings.NewReader(string(body)))
+	require.NoError(t, err)
+	move.Body.Close()
+	require.Equal(t, http.StatusOK, move.StatusCode)
+	end, err := http.Post(server.URL+"/end", "application/json", strings.NewReader(string(body)))
+	require.NoError(t, err)
+	end.Body.Close()
+
+	events := make(chan LiveUpdate)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var update LiveUpdate
+			if json.Unmarshal([]byte(data), &update) == nil && update.GameID == "live" {
+				events <- update
+			}
+		}
+		close(events)
+	}()
+	next := func() LiveUpdate {
+		select {
+		case update := <-events:
+			return update
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event streamed")
+			return LiveUpdate{}
+		}
+	}
+
+	update := next()
+	assert.Equal(t, "a", update.YouID)
+	assert.NotEmpty(t, update.Move)
+	assert.NotEmpty(t, update.Board)
+	assert.Contains(t, update.Eval, "voronoi")
+	assert.Positive(t, update.Visits[update.Move])
+	assert.True(t, next().Ended)
+}
+
+func TestLivePage(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter("secret").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/live", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "the page loads without the secret, it asks for it")
+	assert.Contains(t, rec.Body.String(), "/live/events")
+}
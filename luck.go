@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// luckLosingStanding is the standing at or below which we count as clearly losing
+	luckLosingStanding = -0.25
+	// luckWinningStanding is the standing at or above which we count as clearly winning
+	luckWinningStanding = 0.25
+)
+
+// LuckScale is what a head to head trade is worth to us, where we die taking a snake our length with us.
+// Scored like any other death the search won't go near a coin flip even when it's the only way out of a
+// lost game, so the value slides from Losing to Winning with how the game stands at the root.
+type LuckScale struct {
+	Losing  float64 // what a trade is worth when we're clearly losing
+	Winning float64 // what a trade is worth when we're clearly winning
+}
+
+// defaultLuckScale takes a draw over a loss when we're losing and treats a trade like any death when we're winning.
+var defaultLuckScale = LuckScale{Losing: 0, Winning: -2}
+
+// TradeValue is what a trade is worth at the standing, from -1 lost to 1 won.
+func (s LuckScale) TradeValue(standing float64) float64 {
+	if standing <= luckLosingStanding {
+		return s.Losing
+	}
+	if standing >= luckWinningStanding {
+		return s.Winning
+	}
+	share := (standing - luckLosingStanding) / (luckWinningStanding - luckLosingStanding)
+	return s.Losing + share*(s.Winning-s.Losing)
+}
+
+// parseLuckScale reads "losing,winning", e.g. "0,-2".
+func parseLuckScale(value string) (LuckScale, bool) {
+	fields := strings.Split(value, ",")
+	if len(fields) != 2 {
+		return LuckScale{}, false
+	}
+	losing, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	if err != nil {
+		return LuckScale{}, false
+	}
+	winning, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return LuckScale{}, false
+	}
+	return LuckScale{Losing: losing, Winning: winning}, true
+}
+
+// luckScaleFromEnv reads LUCK_SCALE as "losing,winning". "off" scores trades like any other death and
+// anything unreadable gets the default.
+func luckScaleFromEnv() *LuckScale {
+	value := os.Getenv("LUCK_SCALE")
+	if value == "off" {
+		return nil
+	}
+	scale, ok := parseLuckScale(value)
+	if !ok {
+		scale = defaultLuckScale
+	}
+	return &scale
+}
+
+// tradePartner is the snake ours died head to head with, taking it down too, or -1 if it didn't.
+func tradePartner(board Board, index int) int {
+	snake := board.Snakes[index]
+	if !isSnakeDead(snake) || !isPointInsideBoard(&board, snake.Head) {
+		return -1
+	}
+	for i, other := range board.Snakes {
+		if i != index && isSnakeDead(other) && other.Head == snake.Head {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyLuck rescores our death if it was a trade. If that was the last of a duel played zero sum the other
+// snake gets the negative, so a trade we'd take as a draw is a draw for them too.
+func (c SearchConfig) applyLuck(board Board, scores []float64) []float64 {
+	if c.Luck == nil || len(board.Snakes) < 2 {
+		return scores
+	}
+	partner := tradePartner(board, 0)
+	if partner < 0 {
+		return scores
+	}
+	scores[0] = c.Luck.TradeValue(c.standing)
+	if c.Scoring != ScoringMultiPlayer && len(board.Snakes) == 2 {
+		scores[partner] = -scores[0]
+	}
+	return scores
+}
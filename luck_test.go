@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTradeValue(t *testing.T) {
+	scale := LuckScale{Losing: 0, Winning: -2}
+	testCases := []struct {
+		Standing float64
+		Expected float64
+	}{
+		{-1, 0},
+		{luckLosingStanding, 0},
+		{0, -1},
+		{luckWinningStanding, -2},
+		{1, -2},
+	}
+	for _, tc := range testCases {
+		assert.InDelta(t, tc.Expected, scale.TradeValue(tc.Standing), 1e-9, "standing %v", tc.Standing)
+	}
+}
+
+func TestParseLuckScale(t *testing.T) {
+	testCases := []struct {
+		Value    string
+		Expected LuckScale
+		Ok       bool
+	}{
+		{"0,-2", LuckScale{Losing: 0, Winning: -2}, true},
+		{" -0.5 , -1.5", LuckScale{Losing: -0.5, Winning: -1.5}, true},
+		{"", LuckScale{}, false},
+		{"0", LuckScale{}, false},
+		{"0,-2,1", LuckScale{}, false},
+		{"zero,-2", LuckScale{}, false},
+	}
+	for _, tc := range testCases {
+		scale, ok := parseLuckScale(tc.Value)
+		assert.Equal(t, tc.Ok, ok, tc.Value)
+		assert.Equal(t, tc.Expected, scale, tc.Value)
+	}
+}
+
+func TestLuckScaleFromEnv(t *testing.T) {
+	t.Setenv("LUCK_SCALE", "")
+	assert.Equal(t, &defaultLuckScale, luckScaleFromEnv())
+	t.Setenv("LUCK_SCALE", "-1,-2")
+	assert.Equal(t, &LuckScale{Losing: -1, Winning: -2}, luckScaleFromEnv())
+	t.Setenv("LUCK_SCALE", "off")
+	assert.Nil(t, luckScaleFromEnv())
+}
+
+// tradeBoard has two snakes of the same length a cell either side of (3,3).
+func tradeBoard() Board {
+	return Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 2, Y: 3}, Health: 90, Body: []Point{{X: 2, Y: 3}, {X: 1, Y: 3}, {X: 0, Y: 3}}},
+			{ID: "them", Head: Point{X: 4, Y: 3}, Health: 90, Body: []Point{{X: 4, Y: 3}, {X: 5, Y: 3}, {X: 6, Y: 3}}},
+		},
+	}
+}
+
+func TestApplyLuck(t *testing.T) {
+	traded := tradeBoard()
+	applyMove(&traded, 0, Right)
+	applyMove(&traded, 1, Left)
+	require.True(t, isSnakeDead(traded.Snakes[0]) && isSnakeDead(traded.Snakes[1]))
+	assert.Equal(t, 1, tradePartner(traded, 0))
+
+	// running into them when they're longer isn't a trade, they live
+	lost := tradeBoard()
+	lost.Snakes[1].Body = append(lost.Snakes[1].Body, Point{X: 6, Y: 4})
+	applyMove(&lost, 0, Right)
+	applyMove(&lost, 1, Left)
+	assert.Equal(t, -1, tradePartner(lost, 0))
+
+	walled := tradeBoard()
+	applyMove(&walled, 0, Left)
+	applyMove(&walled, 0, Left)
+	applyMove(&walled, 0, Left)
+	assert.Equal(t, -1, tradePartner(walled, 0))
+
+	losing := SearchConfig{Luck: &LuckScale{Losing: 0, Winning: -2}, standing: -1}
+	assert.Equal(t, []float64{0, 0}, losing.scores(traded, modules), "a draw both ways in a duel")
+	assert.Equal(t, []float64{-2, -2}, SearchConfig{}.scores(traded, modules), "without a scale it's a death like any other")
+
+	multiPlayer := losing
+	multiPlayer.Scoring = ScoringMultiPlayer
+	assert.Equal(t, []float64{0, -2}, multiPlayer.scores(traded, modules), "only ours changes when they score their own games")
+
+	winning := losing
+	winning.standing = 1
+	assert.Equal(t, []float64{-2, 2}, winning.scores(traded, modules))
+}
+
+// luckPuzzleMove searches the board and returns the move the root choice lands on.
+func luckPuzzleMove(t *testing.T, board Board, luck *LuckScale) Direction {
+	t.Helper()
+	root := MCTS(context.Background(), "test", board, 3000, 1, make(map[string]*Node), WithSearchConfig(SearchConfig{Luck: luck}))
+	choice := chooseRootChild(root)
+	require.NotNil(t, choice.Node)
+	return directionTo(&board, board.Snakes[0].Head, choice.Node.Board.Snakes[0].Head)
+}
+
+func TestLuckPuzzleLosing(t *testing.T) {
+	// we're boxed into the bottom right corner with the same length as them. going down is a slow death
+	// in the corner, going left next to their head is a coin flip but the only way out.
+	//
+	// y6 . . . . . . .
+	// y5 . . . . . U U
+	// y4 . . T T T U U
+	// y3 . . T T T U .
+	// y2 . . T . T U U
+	// y1 . . . . t . u
+	// y0 . . . . . . .
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 6, Y: 1}, Health: 90, Body: []Point{{X: 6, Y: 1}, {X: 6, Y: 2}, {X: 5, Y: 2}, {X: 5, Y: 3}, {X: 5, Y: 4}, {X: 6, Y: 4}, {X: 6, Y: 5}, {X: 5, Y: 5}}},
+			{ID: "them", Head: Point{X: 4, Y: 1}, Health: 90, Body: []Point{{X: 4, Y: 1}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 4, Y: 4}, {X: 3, Y: 4}, {X: 3, Y: 3}, {X: 2, Y: 3}, {X: 2, Y: 2}}},
+		},
+	}
+	require.LessOrEqual(t, evaluateBoard(board, 0, modules), luckLosingStanding, "the puzzle needs us clearly losing")
+
+	assert.Equal(t, Left, luckPuzzleMove(t, board, &defaultLuckScale), "a coin flip beats a certain loss")
+	assert.Equal(t, Down, luckPuzzleMove(t, board, nil), "scoring the trade as a death hides in the corner")
+}
+
+func TestLuckPuzzleWinning(t *testing.T) {
+	// they're hemmed in by our body and their only way out is (2,2), which we could move into to force
+	// a trade. we've got the rest of the board so we shouldn't, though if they get out they eat on the
+	// way and outgrow us.
+	//
+	// y4 . . . . . . .
+	// y3 U U u . . . .
+	// y2 U t . f . . .
+	// y1 U T T T T . .
+	// y0 . . . . . . .
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Food:   []Point{{X: 3, Y: 2}},
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 2, Y: 3}, Health: 90, Body: []Point{{X: 2, Y: 3}, {X: 1, Y: 3}, {X: 0, Y: 3}, {X: 0, Y: 2}, {X: 0, Y: 1}}},
+			{ID: "them", Head: Point{X: 1, Y: 2}, Health: 90, Body: []Point{{X: 1, Y: 2}, {X: 1, Y: 1}, {X: 2, Y: 1}, {X: 3, Y: 1}, {X: 4, Y: 1}}},
+		},
+	}
+	require.GreaterOrEqual(t, evaluateBoard(board, 0, modules), luckWinningStanding, "the puzzle needs us clearly winning")
+
+	assert.NotEqual(t, Down, luckPuzzleMove(t, board, &defaultLuckScale), "no trading away a won game")
+	assert.Equal(t, Down, luckPuzzleMove(t, board, &LuckScale{Losing: 0, Winning: 0}), "taking every trade as a draw goes for it")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -10,36 +11,49 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
-	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 )
 
-// things i want to track from the start to the end that don't get provided by the server
-type GameMeta struct {
-	otherSnakes []string
-	start       time.Time
-}
+var (
+	loc *time.Location
+
+	// put search depth in the shout so it shows up in the game viewer
+	shoutSearchStats = os.Getenv("SHOUT_STATS") == "true"
+)
 
 var (
-	gameMetaRegistry = make(map[string]GameMeta)         // this is needed since final game states don't necessarily have all snakes
-	gameStates       = make(map[string]map[string]*Node) // Global map to store known game states
-	// TODO: make this non global
-	webhookURL   string = ""
-	tidbytSecret string = ""
-	loc          *time.Location
+	secretClientMu sync.Mutex
+	secretClient   *secretmanager.Client
 )
 
-func getSecret(secretName string) (string, error) {
-	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+// secretManagerClient makes the secret manager client the first time it's needed and shares it after,
+// since every client opens its own connection.
+func secretManagerClient() (*secretmanager.Client, error) {
+	secretClientMu.Lock()
+	defer secretClientMu.Unlock()
+	if secretClient != nil {
+		return secretClient, nil
+	}
+	client, err := secretmanager.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+	}
+	secretClient = client
+	return client, nil
+}
+
+func getSecret(ctx context.Context, secretName string) (string, error) {
+	client, err := secretManagerClient()
 	if err != nil {
-		return "", fmt.Errorf("failed to create secret manager client: %w", err)
+		return "", err
 	}
-	defer client.Close()
 
 	// Build the request.
 	req := &secretmanagerpb.AccessSecretVersionRequest{
@@ -58,6 +72,60 @@ func getSecret(secretName string) (string, error) {
 }
 
 func main() {
+	// in the browser we only expose the engine to javascript
+	if runtime.GOOS == "js" {
+		runPlayground()
+		return
+	}
+
+	// weights from a tuning run replace the ones the modules are built with
+	if err := applyWeightsFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	// and a learned evaluation can join them or take over
+	if err := applyValueNetFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	// play against the engine in the terminal instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "dojo" {
+		if err := runDojo(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// play round robins against other snakes with the battlesnake cli
+	if len(os.Args) > 1 && os.Args[1] == "arena" {
+		if err := runArena(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// tune the evaluation weights against themselves
+	if len(os.Args) > 1 && os.Args[1] == "tune" {
+		if err := runTune(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// evolve a population of evaluation weights, or promote the best of one
+	if len(os.Args) > 1 && os.Args[1] == "train" {
+		if err := runTrain(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// replay our search over a game downloaded from the engine
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// Set up the custom handler for Google Cloud
 	handler := NewGoogleCloudHandler(os.Stdout, slog.LevelDebug)
@@ -81,49 +149,93 @@ func main() {
 		loc = time.UTC
 	}
 
-	// Retrieve Discord webhook URL from Google Secret Manager
-	secretName := "projects/680796481131/secrets/discord_webhook/versions/latest"
-	webhookURL, err = getSecret(secretName)
-	if err != nil {
-		slog.Error("Failed to retrieve Discord webhook secret", "error", err.Error())
+	// secrets are fetched and refreshed in the background, requests only ever read what's been fetched
+	useSecretManager(getSecret)
+	go refreshSecrets(context.Background(), allSecrets)
+
+	// with two services behind one snake url, decide between them who plays each game
+	gameOwnership = coordinatorFromEnv()
+
+	// games in progress are saved on the way down for whoever gets their next move
+	sessionStore = sessionStoreFromEnv()
+	// experiments play under /snakes/ beside the production snake
+	hostedSnakes = hostedSnakesFromEnv()
+	// and games whose /end never came are let go of
+	for _, snake := range allSnakes() {
+		go snake.sessions.EvictEvery(context.Background(), time.Minute)
 	}
 
-	tidBytSecretName := "projects/680796481131/secrets/tidbyt/versions/latest"
-	tidbytSecret, err = getSecret(tidBytSecretName)
-	if err != nil {
-		slog.Error("Failed to retrieve tidbyt webhook secret", "error", err.Error())
+	server := &http.Server{Addr: ":" + port, Handler: newRouter(os.Getenv("ADMIN_SECRET"))}
+	// dashboards stay connected for as long as they're open, which shutting down would otherwise wait on
+	server.RegisterOnShutdown(liveGames.Close)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	slog.Debug("Starting BattleSnake on port", "port", port)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	received := <-signals
+	slog.Info("shutting down", "signal", received.String())
+	shutdown(server, sessionStore)
+}
+
+// newRouter registers every route with its middleware. Game routes are open to the engine,
+// admin routes need the shared secret if one is set.
+func newRouter(adminSecret string) *http.ServeMux {
+	mux := http.NewServeMux()
+	route := func(path string, handler http.HandlerFunc, middlewares ...middleware) {
+		middlewares = append([]middleware{withLogging(path), withMetrics(path)}, middlewares...)
+		mux.Handle(path, chain(handler, middlewares...))
 	}
 
-	http.HandleFunc("/", handleIndex)
-	http.HandleFunc("/start", handleStart)
-	http.HandleFunc("/move", handleMove)
-	http.HandleFunc("/end", handleEnd)
+	route("/", handleIndex, withRecovery(internalErrorFallback))
+	route("/start", handleStart, withRecovery(internalErrorFallback), withGameOwnership(gameOwnership))
+	route("/move", handleMove, withRecovery(safeMoveFallback), withGameOwnership(gameOwnership))
+	route("/end", handleEnd, withRecovery(internalErrorFallback), withGameOwnership(gameOwnership))
+	for _, snake := range hostedSnakes {
+		prefix := "/snakes/" + snake.Profile.Name
+		route(prefix+"/", handleIndex, withSnake(snake), withRecovery(internalErrorFallback))
+		route(prefix+"/start", handleStart, withSnake(snake), withRecovery(internalErrorFallback), withGameOwnership(gameOwnership))
+		route(prefix+"/move", handleMove, withSnake(snake), withRecovery(safeMoveFallback), withGameOwnership(gameOwnership))
+		route(prefix+"/end", handleEnd, withSnake(snake), withRecovery(internalErrorFallback), withGameOwnership(gameOwnership))
+	}
+	route("/readyz", handleReadyz, withRecovery(internalErrorFallback))
+	route("/matchups", handleMatchups, withRecovery(internalErrorFallback))
+	route("/cron/rank", handleCronRank, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/admin/pipelines", handlePipelines, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/admin/scheduler", handleScheduler, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/admin/leases", handleLeases, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/admin/export", handleExport, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/games/", handleGameLog, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/live", handleLive, withRecovery(internalErrorFallback))
+	// discord signs its interactions, handleDiscordInteractions checks them
+	route("/discord/interactions", handleDiscordInteractions, withRecovery(internalErrorFallback))
+	route("/live/events", handleLiveEvents, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/analyze", handleAnalyze, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/trees/", handleTree, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/metrics", handleMetrics, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	route("/debug/stats", handleDebugStats, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	for path, handler := range pprofRoutes {
+		route(path, handler, withSharedSecret(adminSecret), withRecovery(internalErrorFallback))
+	}
 
-	slog.Debug("Starting BattleSnake on port", "port", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	return mux
 }
 
 func handleIndex(w http.ResponseWriter, r *http.Request) {
-	response := map[string]string{
-		"apiversion": "1",
-		"author":     "brensch",
-		"color":      "#00ff00",
-		"head":       "replit-mark",
-		"tail":       "replit-notmark",
-		"version":    "0.1.0",
-	}
-	writeJSON(w, response)
+	writeJSON(w, snakeFrom(r.Context()).Profile.customization())
 }
 
 func handleStart(w http.ResponseWriter, r *http.Request) {
-	var game BattleSnakeGame
-	if err := json.NewDecoder(r.Body).Decode(&game); err != nil {
+	game, err := decodeGame(w, r, BattleSnakeGame.Validate)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// add a map for this game
-	gameStates[game.Game.ID] = make(map[string]*Node)
 	var otherSnakes []string
 	foundPaul := false
 	for _, snake := range game.Board.Snakes {
@@ -136,62 +248,249 @@ func handleStart(w http.ResponseWriter, r *http.Request) {
 		otherSnakes = append(otherSnakes, snake.Name)
 	}
 	if foundPaul {
-		sendDiscordWebhook(webhookURL, fmt.Sprintf("Paul Alert: https://play.battlesnake.com/game/%s", game.Game.ID), []Embed{})
-	}
-	gameMetaRegistry[game.Game.ID] = GameMeta{
-		otherSnakes: otherSnakes,
-		start:       time.Now(),
+		discordQueue.Send(webhookURL.Get(), fmt.Sprintf("Paul Alert: https://play.battlesnake.com/game/%s", game.Game.ID), []Embed{})
 	}
-	slog.Info("Game started", "game_id", game.Game.ID, "you", game.You, "other_snakes", otherSnakes)
+	snake := snakeFrom(r.Context())
+	session := snake.newSession(game, otherSnakes)
+	snake.startSession(session)
+	session.Logger.Info("Game started", "you", game.You, "other_snakes", otherSnakes, "engine", engineBuild)
 
 	writeJSON(w, map[string]string{})
 }
 
 func handleMove(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+	// counting from when the request arrived, so time spent trying to forward it comes off the search
+	start := requestStart(r)
+	meter := startMoveMeter()
 
-	var game BattleSnakeGame
-	if err := json.NewDecoder(r.Body).Decode(&game); err != nil {
+	game, err := decodeGame(w, r, BattleSnakeGame.ValidateMove)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// get the nodemap for this game
-	gameState, ok := gameStates[game.Game.ID]
-	if !ok {
-		slog.Error("failed to find gamestate. probably reset during a game.")
-		gameState = make(map[string]*Node)
+	session := snakeFrom(r.Context()).lookupSession(game)
+	// stop searching ahead so the tree is ours again
+	session.prefetch.Stop()
+	gameState := session.States()
+
+	reorderedBoard := reorderSnakes(game.Board.withRules(game.Game).atTurn(game.Turn), game.You.ID)
+	// keep back however much of the turn the network has been eating
+	session.latency.Observe(game.Turn, game.You.Latency)
+	session.opponents.Observe(reorderedBoard)
+
+	// while we're winning the starvation race there's nothing to search for either, we just keep going round
+	move, chasing, changed := session.chase.Update(game.Turn, reorderedBoard)
+	if changed {
+		since, turns := session.chase.Since()
+		session.Logger.Info("Tail chase", "chasing", chasing, "since", since, "turns", turns)
+	}
+	// with only one safe move there's nothing to search for, so answer now and bank the time for a harder turn
+	forced := false
+	if !chasing {
+		move, forced = forcedMove(reorderedBoard)
+	}
+	if chasing || forced {
+		answerWithoutSearch(w, session, game, reorderedBoard, start, meter, move, DecisionRecord{Forced: forced, TailChase: chasing, Source: MoveSafe})
+		return
+	}
+
+	// a small duel the solver can prove a win in doesn't need searching. if it can't, the search gets what's left
+	engine := selectEngine(reorderedBoard, game.Game.Ruleset.Name, session.Config.Engine)
+	if engine == EngineEndgame {
+		solveCtx, stop := context.WithTimeout(r.Context(), endgameSolveTime(game.Game.Timeout))
+		result := solveEndgame(solveCtx, reorderedBoard, endgameMaxDepth, endgameMaxNodes)
+		stop()
+		session.Logger.Debug("endgame solve", "value", result.Value.String(), "depth", result.Depth, "nodes", result.Nodes)
+		if result.Proven() {
+			answerWithoutSearch(w, session, game, reorderedBoard, start, meter, result.Move, DecisionRecord{Solved: true, Algorithm: engine.String(), Source: MoveSafe})
+			return
+		}
+		engine = selectSearchEngine(reorderedBoard, game.Game.Ruleset.Name, session.Config.Engine)
 	}
 
-	reorderedBoard := reorderSnakes(game.Board, game.You.ID)
-	// timeout to signify end of move
-	ctx, cancel := context.WithDeadline(context.Background(), start.Add(time.Duration(game.Game.Timeout-170)*time.Millisecond))
+	// complex turns can spend time banked on forced ones, quiet opening turns don't need all of theirs
+	budget, borrowed := session.timing.Budget(game.Game.Timeout, game.Turn, complexTurn(reorderedBoard))
+	// a move passed on from the other instance has to be back there within what it gave us
+	if limit := forwardedBudget(r); limit > 0 && budget > limit {
+		budget = limit
+	}
+	// timeout to signify end of move. hanging off the request means a dropped connection stops
+	// the search instead of starving the engine's retry of cpu, and the game ending stops it too.
+	deadline := start.Add(budget)
+	ctx, cancel := session.moveContext(r.Context(), deadline)
 	defer cancel()
 
-	workers := runtime.NumCPU()
-	mctsResult := MCTS(ctx, game.Game.ID, reorderedBoard, math.MaxInt, workers, gameState)
-	bestMove := determineBestMove(mctsResult)
+	// search each opponent the way its reply times say it plays
+	profile := session.opponents.Profile(reorderedBoard, game.Game.Timeout)
+	config := session.Config
+	config.Opponents = profile.Models
+	config.Engine = engine
+
+	// maxn, paranoid and brs have no tree to carry over or share, they just look as deep as they can in the time
+	if result, ok := depthFirstSearch(ctx, reorderedBoard, config); ok {
+		answerWithoutSearch(w, session, game, reorderedBoard, start, meter, result.Move, DecisionRecord{
+			Algorithm:  config.Engine.String(),
+			Visits:     int64(result.Nodes),
+			MaxDepth:   result.Depth,
+			BudgetMs:   budget.Milliseconds(),
+			BorrowedMs: borrowed.Milliseconds(),
+			Source:     searchSource(reorderedBoard),
+		})
+		return
+	}
+	// neither does duct, its nodes are joint moves the sequential tree can't pick up from
+	if config.Engine == EngineDUCT {
+		move, visits := ductSearch(ctx, reorderedBoard, config)
+		answerWithoutSearch(w, session, game, reorderedBoard, start, meter, move, DecisionRecord{
+			Algorithm:  config.Engine.String(),
+			Visits:     visits,
+			BudgetMs:   budget.Milliseconds(),
+			BorrowedMs: borrowed.Milliseconds(),
+			Source:     searchSource(reorderedBoard),
+		})
+		return
+	}
 
+	// lean towards last turn's plan if the opponents replied the way we expected
+	searchOpts := []func(*searchOptions){WithSearchConfig(config)}
+	followingPlan := false
+	if move, ok := session.plans.Load().NextMove(reorderedBoard, game.Turn); ok {
+		searchOpts = append(searchOpts, WithPreferredMove(move))
+		followingPlan = true
+	}
+
+	// note whether last turn's snapshots predicted this board before the search adds to them
+	lookup := session.cache.Lookup(game.Turn, gameState, reorderedBoard)
+	observeCacheLookup(lookup)
+	warmVisits := lookup.ReusedVisits
+	prefetchVisits := session.prefetch.Added(gameState[boardHash(reorderedBoard)])
+
+	// back off if cloud run throttles us partway through the search
+	guard := session.throttle.Guard()
+	searchOpts = append(searchOpts, WithThrottleGuard(guard))
+
+	// share the cpus with any other games searching at the same time
+	ticket := searchScheduler.Register(game.Game.ID, deadline)
+	defer searchScheduler.Release(ticket)
+	searchOpts = append(searchOpts, WithSearchTicket(ticket))
+
+	// stop as soon as the best move can't be caught and bank what's left
+	cutoff := &EarlyCutoff{}
+	searchOpts = append(searchOpts, WithEarlyCutoff(cutoff))
+
+	workers := runtime.NumCPU()
+	searchStart := time.Now()
+	mctsResult := MCTS(ctx, game.Game.ID, reorderedBoard, math.MaxInt, workers, gameState, searchOpts...)
+	// hand the cpus back now rather than after the response, the deferred release is for a panic in the search
+	searchScheduler.Release(ticket)
+	session.throttle.Record(guard, mctsResult.Visits-warmVisits, time.Since(searchStart))
+	// the most visited move, unless it relies on the opponents not finding a kill and there's a safer one about as good
+	choice := chooseRootChildWithin(mctsResult, profile.DangerMargin)
+	bestMove, source := moveAndSourceForChild(mctsResult, choice.Node)
+	entropy := rootVisitEntropy(mctsResult)
+	pvDepth := len(principalVariation(mctsResult))
+
+	shout := "This is a nice move."
+	if shoutSearchStats {
+		shout = fmt.Sprintf("pv %d, %d visits", pvDepth, mctsResult.Visits)
+	}
 	response := map[string]string{
 		"move":  bestMove,
-		"shout": "This is a nice move.",
+		"shout": shout,
 	}
 	writeJSON(w, response)
+	duration := time.Since(start)
+	session.latency.Record(game.Turn, duration)
+	session.timing.Deposit(game.Game.Timeout, duration)
+	observeMove(game, duration, config.Engine.String())
+	mctsIterations.Observe(float64(mctsResult.Visits - warmVisits))
+
+	decision := DecisionRecord{
+		GameID:     game.Game.ID,
+		Turn:       game.Turn,
+		Move:       bestMove,
+		Visits:     mctsResult.Visits,
+		Entropy:    entropy,
+		MaxDepth:   maxTreeDepth(mctsResult),
+		PVDepth:    pvDepth,
+		DurationMs: duration.Milliseconds(),
+		BudgetMs:   budget.Milliseconds(),
+		Plan:       followingPlan,
+		WarmVisits: warmVisits,
+		Throttled:  guard.Degraded(),
+		Danger:     choice.Danger,
+		SaferMove:  choice.Safer,
+		BorrowedMs: borrowed.Milliseconds(),
+		Workers:    ticket.FewestWorkers(),
+		Prefetched: prefetchVisits,
+		Engine:     engineBuild.String(),
+		Source:     source,
+		Opponents:  profile.Styles(),
+		Parallel:   config.Parallel.String(),
+		EarlyStop:  cutoff.Stopped(),
+		Algorithm:  config.Engine.String(),
+	}
+	if choice.Node != nil && choice.Node.Visits > 0 {
+		decision.Value = choice.Node.Score / float64(choice.Node.Visits)
+	}
+	// the answer's gone already, so walking the tree for its size doesn't cost the move any time
+	stats := meter.Stats(mctsResult.Visits-warmVisits, countNodes(mctsResult), session.cache.Report(game.Game.ID).HitRate)
+	decision.Stats = &stats
+	recordMoveStats(game, stats)
+
+	if treeExportEnabled {
+		decision.TreeURL = exportTree(moveTreeID(game.Game.ID, game.You.ID, game.Turn), mctsResult, config.fullModules(), true, session.Logger)
+	}
 
-	slog.Info("Move processed",
-		"game_id", game.Game.ID,
+	session.Logger.Info("Move processed",
 		"snake_id", game.You.ID,
 		"move", bestMove,
-		"duration_ms", time.Since(start).Milliseconds(),
+		"duration_ms", decision.DurationMs,
 		"depth", mctsResult.Visits,
+		"entropy", entropy,
+		"decision", decision,
 		"board", reorderedBoard,
 	)
 
+	session.plans.Store(buildPlan(mctsResult, game.Turn))
+
+	if diff, ok := session.history.Advance(game.Turn, reorderedBoard); ok {
+		session.Logger.Debug("turn diff", "turn", game.Turn, "diff", diff)
+	}
+
+	session.decisions.Add(decision)
+	observeLegality(session, decision)
+	logMove(game.Game, reorderedBoard, mctsResult, decision, config.fullModules())
+	if trainingDataEnabled {
+		session.training.Add(game.Turn, reorderedBoard, mctsResult, decision.Value)
+	}
+
+	if event := session.indecision.Observe(game.Turn, entropy); event != nil {
+		session.Logger.Warn("indecision event", "turn", event.Turn, "entropy", event.Entropy, "reason", event.Reason)
+		if indecisionAlertsEnabled && session.Source == "tournament" {
+			discordQueue.Send(webhookURL.Get(), fmt.Sprintf("🤔 indecisive on turn %d (%s) [game](<https://play.battlesnake.com/game/%s>)", event.Turn, event.Reason, session.ID), []Embed{})
+		}
+	}
+
+	if commentaryURL := commentaryWebhookURL.Get(); commentaryURL != "" {
+		if line := session.commentary.Comment(decision, reorderedBoard); line != "" {
+			discordQueue.Send(commentaryURL, fmt.Sprintf("%s [game](<https://play.battlesnake.com/game/%s>)", line, session.ID), []Embed{})
+		}
+	}
+
 	// reset this gamestate and load in new nodes
 	gameSaveStart := time.Now()
-	gameStates[game.Game.ID] = make(map[string]*Node)
-	saveNodesAtDepth2(mctsResult, gameStates[game.Game.ID])
-	slog.Debug("finished saving game state", "duration", time.Since(gameSaveStart).Milliseconds())
+	nextGameState, reuse := reuseTree(choice.Node, treeReuseNodes)
+	session.SetStates(nextGameState)
+	saveDuration := time.Since(gameSaveStart)
+	session.cache.Saved(game.Turn, nextGameState, reuse, saveDuration)
+	session.Logger.Debug("finished saving game state", "duration", saveDuration.Milliseconds())
+
+	// keep searching the boards the opponents are likely to leave us until they do
+	if prefetchEnabled {
+		session.prefetch.Start(session.ctx, nextGameState, config, searchScheduler, 2*time.Duration(game.Game.Timeout)*time.Millisecond)
+	}
 
 	// slog.Info("Visualized board", "board", visualizeBoard(game.Board))
 	// fmt.Println(visualizeBoard(reorderedBoard))
@@ -208,15 +507,6 @@ func handleMove(w http.ResponseWriter, r *http.Request) {
 	// }
 }
 
-func saveNodesAtDepth2(rootNode *Node, gameStates map[string]*Node) {
-	for _, child := range rootNode.Children {
-		for _, grandchild := range child.Children {
-			boardKey := boardHash(grandchild.Board)
-			gameStates[boardKey] = grandchild
-		}
-	}
-}
-
 func reorderSnakes(board Board, youID string) Board {
 	var youIndex int
 	for index, snake := range board.Snakes {
@@ -230,33 +520,94 @@ func reorderSnakes(board Board, youID string) Board {
 }
 
 func determineBestMove(node *Node) string {
-	var bestChild *Node
-	maxVisits := int64(-1)
+	return moveForChild(node, chooseRootChild(node).Node)
+}
 
-	for _, child := range node.Children {
-		if child.Visits > maxVisits {
-			bestChild = child
-			maxVisits = child.Visits
-		}
-	}
+// moveForChild is the direction we move to get from the node to the child, or a safe guess if there's no child.
+func moveForChild(node *Node, bestChild *Node) string {
+	move, _ := moveAndSourceForChild(node, bestChild)
+	return move
+}
 
+// moveAndSourceForChild is moveForChild along with which set of moves it came from.
+func moveAndSourceForChild(node *Node, bestChild *Node) (string, MoveSource) {
 	if bestChild != nil {
 		bestMove := determineMoveDirection(node.Board.Snakes[0].Head, bestChild.Board.Snakes[0].Head)
-		return bestMove
+		return bestMove, searchSource(node.Board)
+	}
+
+	// nothing searched (usually no time left), so at least don't walk off the board or into a body
+	if len(node.Board.Snakes) > 0 {
+		if safeMoves := generateSafeMoves(node.Board, 0); len(safeMoves) > 0 {
+			head := node.Board.Snakes[0].Head
+			return determineMoveDirection(head, moveInDirection(head, safeMoves[rand.Intn(len(safeMoves))])), MoveSafe
+		}
 	}
 
 	moves := []string{"up", "down", "left", "right"}
-	return moves[rand.Intn(len(moves))]
+	return moves[rand.Intn(len(moves))], MoveRandom
+}
+
+// answerWithoutSearch responds with a move that didn't come from the tree search and banks the time we didn't use.
+// The decision has to say which set of moves it came from.
+func answerWithoutSearch(w http.ResponseWriter, session *GameSession, game BattleSnakeGame, board Board, start time.Time, meter moveMeter, move Direction, decision DecisionRecord) {
+	head := board.Snakes[0].Head
+	bestMove := determineMoveDirection(head, moveInDirection(head, move))
+	writeJSON(w, map[string]string{
+		"move":  bestMove,
+		"shout": "This is a nice move.",
+	})
+	duration := time.Since(start)
+	session.latency.Record(game.Turn, duration)
+	session.timing.Deposit(game.Game.Timeout, duration)
+	observeMove(game, duration, decision.Algorithm)
+	// last turn's tree and plan don't reach past a turn we didn't search
+	session.SetStates(make(map[string]*Node))
+	session.plans.Store(nil)
+	session.history.Advance(game.Turn, board)
+
+	decision.GameID = game.Game.ID
+	decision.Turn = game.Turn
+	decision.Move = bestMove
+	decision.DurationMs = duration.Milliseconds()
+	decision.Engine = engineBuild.String()
+	stats := meter.Stats(decision.Visits, 0, session.cache.Report(game.Game.ID).HitRate)
+	decision.Stats = &stats
+	recordMoveStats(game, stats)
+	session.Logger.Info("Move processed",
+		"snake_id", game.You.ID,
+		"move", bestMove,
+		"duration_ms", decision.DurationMs,
+		"decision", decision,
+		"banked_ms", session.timing.Banked().Milliseconds(),
+		"board", board,
+	)
+	session.decisions.Add(decision)
+	observeLegality(session, decision)
+	logMove(game.Game, board, nil, decision, session.Config.fullModules())
+}
+
+// observeLegality counts where the move came from and speaks up if the game has had too many unsafe ones.
+func observeLegality(session *GameSession, decision DecisionRecord) {
+	alert := session.legality.Record(decision.Turn, decision.Source)
+	if alert == nil {
+		return
+	}
+	session.Logger.Warn("legality alert", "turn", alert.Turn, "source", alert.Source, "rate", alert.Rate, "counts", session.legality.Counts())
+	discordQueue.Send(webhookURL.Get(), fmt.Sprintf("⚠️ %.0f%% %s moves by turn %d (%s) [game](<https://play.battlesnake.com/game/%s>)", 100*alert.Rate, alert.Source, alert.Turn, engineBuild, session.ID), []Embed{})
 }
 
+// determineMoveDirection is the move that takes the head to the next head. On a wrapped board a step
+// across the edge shows up as a jump the width (or height) of the board the other way.
 func determineMoveDirection(head, nextHead Point) string {
-	if nextHead.X < head.X {
+	dx, dy := nextHead.X-head.X, nextHead.Y-head.Y
+	if dx == -1 || dx > 1 {
 		return "left"
 	}
-	if nextHead.X > head.X {
+	if dx == 1 || dx < -1 {
 		return "right"
 	}
-	if nextHead.Y < head.Y {
+	if dy == -1 || dy > 1 {
 		return "down"
 	}
 	return "up"
@@ -264,105 +615,23 @@ func determineMoveDirection(head, nextHead Point) string {
 
 func handleEnd(w http.ResponseWriter, r *http.Request) {
 	end := time.Now()
-	var game BattleSnakeGame
-	if err := json.NewDecoder(r.Body).Decode(&game); err != nil {
+	game, err := decodeGame(w, r, BattleSnakeGame.Validate)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// tidy the cache
-	delete(gameStates, game.Game.ID)
-
-	gameMeta, ok := gameMetaRegistry[game.Game.ID]
-	if !ok {
-		gameMeta = GameMeta{
-			otherSnakes: []string{"server reset during game"},
-			start:       time.Now(),
-		}
-	}
-	delete(gameMetaRegistry, game.Game.ID)
-
-	outcome, description := describeGameOutcome(game)
-	var outcomeEmoji string
-
-	switch outcome {
-	case Win:
-		outcomeEmoji = "✅"
-	case Loss:
-		outcomeEmoji = "❌"
-	case Draw:
-		outcomeEmoji = "🦍"
-	}
-
-	// TODO: only works for duels
-	rank, score, err := GetDuelsRankAndScore()
-	if err != nil {
-		rank = -1
-		score = -1
-	}
-
-	gameDuration := end.Sub(gameMeta.start)
-
-	slog.Info("Game ended", "game", game, "rank", rank, "score", score, "duration_ms", gameDuration.Milliseconds())
+	// tidy the cache and stop anything still searching for this game
+	session := snakeFrom(r.Context()).endSession(game)
+	liveGames.End(game.Game.ID)
 
-	err = sendDiscordWebhook(webhookURL, fmt.Sprintf("%s [%s](<https://play.battlesnake.com/game/%s>) | %s", outcomeEmoji, strings.Join(gameMeta.otherSnakes, ", "), game.Game.ID, description), []Embed{})
-	if err != nil {
-		slog.Error("failed to send discord webhook", "error", err.Error())
-	}
-	err = downloadAndUploadFile(context.Background(), game.Game.ID)
-	if err != nil {
-		slog.Error("failed to download and upload", "error", err.Error())
-	}
-	// if err != nil {
-	// } else {
-	// 	sendDiscordWebhook(
-	// 		webhookURL,
-	// 		"",
-	// 		[]Embed{
-	// 			{
-	// 				Title:       strings.Join(gameMeta.otherSnakes, ", "),
-	// 				Description: description,
-	// 				Image: &Image{
-	// 					URL: fmt.Sprintf("https://storage.googleapis.com/gregorywebp/%s.gif", game.Game.ID),
-	// 				},
-	// 				Color: getColorForOutcome(outcome),
-	// 				URL:   fmt.Sprintf("https://play.battlesnake.com/game/%s", game.Game.ID),
-	// 				Fields: []EmbedField{
-	// 					{
-	// 						Name:   "turns",
-	// 						Value:  fmt.Sprint(game.Turn),
-	// 						Inline: true,
-	// 					},
-	// 					{
-	// 						Name:   "latency",
-	// 						Value:  game.You.Latency,
-	// 						Inline: true,
-	// 					},
-	// 					{
-	// 						Name:   "rank",
-	// 						Value:  fmt.Sprint(rank),
-	// 						Inline: true,
-	// 					},
-	// 					{
-	// 						Name:   "score",
-	// 						Value:  fmt.Sprint(score),
-	// 						Inline: true,
-	// 					},
-	// 					{
-	// 						Name:   "game duration",
-	// 						Value:  fmt.Sprint(gameDuration.String()),
-	// 						Inline: true,
-	// 					},
-	// 				},
-	// 				Footer: &Footer{
-	// 					Text: time.Now().In(loc).Format(time.RFC3339),
-	// 				},
-	// 			},
-	// 		},
-	// 	)
-	// }
+	report := session.cache.Report(session.ID)
+	session.Logger.Info("cache report", "summary", report.String(), "report", report)
+	outcome, _ := describeGameOutcome(game)
+	gamesEnded.Inc(outcome.String())
 
-	RetrieveGameRenderAndSendToTidbyt(game.Game.ID)
+	// archiving, reporting and the tidbyt are slow and flaky, so they happen after we've answered
+	endOfGame.Start(&EndOfGameJob{Session: session, Game: game, End: end})
 
 	writeJSON(w, map[string]string{})
 }
@@ -0,0 +1,87 @@
+package main
+
+import "math"
+
+const (
+	// sinkholeEvery is how many turns apart the sinkhole grows a ring
+	sinkholeEvery = 20
+	// sinkholeRings is how far out the sinkhole grows before it stops
+	sinkholeRings = 5
+	// mazeWallDamage is hazard damage nothing survives, which is what the maze's walls are
+	mazeWallDamage = 100
+)
+
+// MapRules are the mechanics a map adds to its ruleset, so the simulation plays along with them instead
+// of treating every map as an empty standard board. Any of them can be left out.
+type MapRules struct {
+	// Setup adjusts the board the game sent before it's searched.
+	Setup func(board *Board)
+	// Vacate is called when a snake's tail moves off a cell, with the snake's length.
+	Vacate func(board *Board, tail Point, length int)
+	// EndTurn is called once every snake has moved and the turn has been counted.
+	EndTurn func(board *Board)
+}
+
+// mapRegistry is the rules for the maps that do more than the ruleset, by the game's map name.
+var mapRegistry = map[string]MapRules{
+	"arcade_maze": {Setup: mazeWalls},
+	"snail_mode":  {Vacate: snailTrail, EndTurn: snailDecay},
+	"sinkholes":   {EndTurn: sinkholeGrowth},
+}
+
+// rulesForMap is the map's rules, or none for a map that plays like the ruleset.
+func rulesForMap(name string) MapRules {
+	return mapRegistry[name]
+}
+
+// vacate tells the board's map that a tail moved off a cell.
+func (b *Board) vacate(tail Point, length int) {
+	if rules := rulesForMap(b.Map); rules.Vacate != nil {
+		rules.Vacate(b, tail, length)
+	}
+}
+
+// mazeWalls makes the maze's hazards the walls they are, whatever damage the ruleset says they do.
+func mazeWalls(board *Board) {
+	board.HazardDamage = max(board.HazardDamage, mazeWallDamage)
+}
+
+// snailTrail leaves a stack of hazard as deep as the snake is long where its tail was. It's one deeper
+// than the map puts down, since snailDecay takes a layer off everything at the end of the same turn.
+func snailTrail(board *Board, tail Point, length int) {
+	for i := 0; i <= length; i++ {
+		board.Hazards = append(board.Hazards, tail)
+	}
+}
+
+// snailDecay takes a layer off every stack of hazard, so the trails fade out behind the snakes.
+func snailDecay(board *Board) {
+	seen := make(map[Point]bool, len(board.Hazards))
+	hazards := board.Hazards[:0]
+	for _, point := range board.Hazards {
+		if !seen[point] {
+			seen[point] = true
+			continue
+		}
+		hazards = append(hazards, point)
+	}
+	board.Hazards = hazards
+}
+
+// sinkholeGrowth lays another ring of hazard over the sinkhole in the middle of the board every
+// sinkholeEvery turns, until it's sinkholeRings across. Each ring covers everything inside it as well,
+// so the middle gets deeper as it grows.
+func sinkholeGrowth(board *Board) {
+	if board.Turn <= 0 || board.Turn%sinkholeEvery != 0 || board.Turn/sinkholeEvery > sinkholeRings {
+		return
+	}
+	radius := float64(board.Turn / sinkholeEvery)
+	centre := Point{X: board.Width / 2, Y: board.Height / 2}
+	for y := 0; y < board.Height; y++ {
+		for x := 0; x < board.Width; x++ {
+			if math.Hypot(float64(x-centre.X), float64(y-centre.Y)) < radius {
+				board.Hazards = append(board.Hazards, Point{X: x, Y: y})
+			}
+		}
+	}
+}
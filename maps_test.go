@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMazeWallsKill(t *testing.T) {
+	game := Game{Ruleset: Ruleset{Name: "standard", Settings: Settings{HazardDamagePerTurn: 15}}, Map: "arcade_maze"}
+	board := Board{Width: 19, Height: 21}.withRules(game)
+	assert.Equal(t, "arcade_maze", board.Map)
+	assert.Equal(t, mazeWallDamage, board.HazardDamage)
+
+	game.Map = "standard"
+	assert.Equal(t, 15, Board{}.withRules(game).HazardDamage, "other maps keep the ruleset's damage")
+}
+
+func TestSnailTrail(t *testing.T) {
+	board := Board{
+		Width:        7,
+		Height:       7,
+		Map:          "snail_mode",
+		HazardDamage: 14,
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+			{ID: "b", Health: 90, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}, {X: 5, Y: 6}}},
+		},
+	}
+	applyMove(&board, 0, Up)
+	applyMove(&board, 1, Down)
+	rollChance(&board)
+
+	trail := 0
+	for _, hazard := range board.Hazards {
+		require.Equal(t, Point{X: 0, Y: 0}, hazard, "b's tail was stacked so it didn't leave anything")
+		trail++
+	}
+	assert.Equal(t, 3, trail, "a stack as deep as a is long")
+
+	for turn := 0; turn < 3; turn++ {
+		rollChance(&board)
+	}
+	assert.Empty(t, board.Hazards, "a layer fades every turn")
+
+	// the simultaneous simulation leaves the same trail
+	board = Board{Width: 7, Height: 7, Map: "snail_mode", Snakes: []Snake{
+		{ID: "a", Health: 90, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+		{ID: "b", Health: 90, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+	}}
+	applyMoves(&board, []Direction{Up, Down})
+	assert.Len(t, board.Hazards, 5, "a's three and b's two")
+	assert.Equal(t, 1, board.Turn)
+}
+
+func TestSinkholeGrowth(t *testing.T) {
+	board := Board{Width: 11, Height: 11, Map: "sinkholes", Turn: sinkholeEvery - 2}
+	rollChance(&board)
+	assert.Empty(t, board.Hazards, "nothing until it's due")
+	rollChance(&board)
+	assert.Equal(t, []Point{{X: 5, Y: 5}}, board.Hazards, "the first ring is just the middle")
+
+	board.Turn = 2*sinkholeEvery - 1
+	rollChance(&board)
+	assert.Len(t, board.Hazards, 1+9, "the second covers the middle again and everything next to it")
+
+	board.Turn = (sinkholeRings+1)*sinkholeEvery - 1
+	before := len(board.Hazards)
+	rollChance(&board)
+	assert.Len(t, board.Hazards, before, "it stops growing")
+}
+
+func TestMapsWithoutRules(t *testing.T) {
+	assert.Equal(t, MapRules{}, rulesForMap("standard"))
+	assert.Equal(t, MapRules{}, rulesForMap(""))
+	board := evalTestBoard()
+	board.Map = "hz_something_new"
+	before := copyBoard(board)
+	rollChance(&board)
+	assert.Equal(t, before.Hazards, board.Hazards)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// gameStatsObject is where every game we've finished is kept in the bucket.
+const gameStatsObject = "stats/games.json"
+
+// GameRecord is how one game went, kept so we can look back over who we do well and badly against.
+type GameRecord struct {
+	GameID    string    `json:"game_id"`
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"`
+	Opponents []string  `json:"opponents"`
+	Outcome   string    `json:"outcome"`
+	Cause     string    `json:"cause"`
+	Turns     int       `json:"turns"`
+	Engine    string    `json:"engine"` // build that played the game
+}
+
+// GameStats is every game we've recorded, oldest first.
+type GameStats struct {
+	Games []GameRecord `json:"games"`
+}
+
+// gameStatsAttempts is how many times recording a game rereads the stats after losing a race to another write
+const gameStatsAttempts = 5
+
+var errGameStatsConflict = errors.New("game stats changed since they were read")
+
+// GameStatsStore keeps the stats object along with its generation, so a write can be made to only land if
+// nothing else wrote in between. Instances finishing games together would otherwise drop each other's.
+type GameStatsStore interface {
+	// Get returns the stats and their generation, or no data and generation 0 if there aren't any yet.
+	Get(ctx context.Context) ([]byte, int64, error)
+	// Put writes the stats if they're still at the generation, or returns errGameStatsConflict.
+	Put(ctx context.Context, data []byte, generation int64) error
+}
+
+// bucketGameStatsStore keeps the stats in our bucket, using generation preconditions like the leases do.
+type bucketGameStatsStore struct{}
+
+func (bucketGameStatsStore) Get(ctx context.Context) ([]byte, int64, error) {
+	var data []byte
+	var generation int64
+	err := guardBucket(ctx, func(ctx context.Context) error {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create storage client: %w", err)
+		}
+		defer client.Close()
+
+		reader, err := client.Bucket(bucketName).Object(gameStatsObject).NewReader(ctx)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		if data, err = io.ReadAll(reader); err != nil {
+			return fmt.Errorf("failed to read game stats: %w", err)
+		}
+		generation = reader.Attrs.Generation
+		return nil
+	})
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, 0, nil
+	}
+	return data, generation, err
+}
+
+func (bucketGameStatsStore) Put(ctx context.Context, data []byte, generation int64) error {
+	return guardBucket(ctx, func(ctx context.Context) error {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create storage client: %w", err)
+		}
+		defer client.Close()
+
+		conditions := storage.Conditions{DoesNotExist: true}
+		if generation != 0 {
+			conditions = storage.Conditions{GenerationMatch: generation}
+		}
+		writer := client.Bucket(bucketName).Object(gameStatsObject).If(conditions).NewWriter(ctx)
+		writer.ContentType = "application/json"
+		writer.Metadata = engineBuild.Metadata()
+		if _, err := writer.Write(data); err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to write game stats: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			if isPreconditionFailed(err) {
+				return errGameStatsConflict
+			}
+			return fmt.Errorf("failed to close game stats writer: %w", err)
+		}
+		return nil
+	})
+}
+
+// gameStats is where the stats are kept, swapped out in tests.
+var gameStats GameStatsStore = bucketGameStatsStore{}
+
+// newGameRecord describes the finished game.
+func newGameRecord(session *GameSession, game BattleSnakeGame, end time.Time) GameRecord {
+	outcome, cause, _ := judgeGame(game)
+	return GameRecord{
+		GameID:    session.ID,
+		Time:      end,
+		Source:    session.Source,
+		Opponents: session.otherSnakes,
+		Outcome:   outcome.String(),
+		Cause:     cause,
+		Turns:     game.Turn,
+		Engine:    engineBuild.String(),
+	}
+}
+
+func loadGameStats(ctx context.Context) (*GameStats, error) {
+	stats, _, err := loadGameStatsGeneration(ctx)
+	return stats, err
+}
+
+// loadGameStatsGeneration is loadGameStats along with the generation they were read at.
+func loadGameStatsGeneration(ctx context.Context) (*GameStats, int64, error) {
+	stats := &GameStats{}
+	data, generation, err := gameStats.Get(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if data == nil {
+		return stats, 0, nil
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse game stats: %w", err)
+	}
+	return stats, generation, nil
+}
+
+// recordGame adds the game to the stats in the bucket. A game that's already there isn't added twice,
+// so the pipeline can retry after a failed save. If another write lands between reading the stats and
+// writing them back it starts again from the new stats rather than writing over them.
+func recordGame(ctx context.Context, record GameRecord) error {
+	for attempt := 1; ; attempt++ {
+		stats, generation, err := loadGameStatsGeneration(ctx)
+		if err != nil {
+			return err
+		}
+		for _, game := range stats.Games {
+			if game.GameID == record.GameID {
+				return nil
+			}
+		}
+		stats.Games = append(stats.Games, record)
+
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+		err = gameStats.Put(ctx, data, generation)
+		if !errors.Is(err, errGameStatsConflict) || attempt >= gameStatsAttempts {
+			return err
+		}
+		slog.Debug("game stats changed underneath us, rereading", "game_id", record.GameID, "attempt", attempt)
+	}
+}
+
+// recordStage keeps the game in the stats store for /matchups.
+func recordStage(ctx context.Context, job *EndOfGameJob) error {
+	return recordGame(ctx, newGameRecord(job.Session, job.Game, job.End))
+}
+
+// Matchup is our record against one opponent.
+type Matchup struct {
+	Opponent     string         `json:"opponent"`
+	Games        int            `json:"games"`
+	Wins         int            `json:"wins"`
+	Losses       int            `json:"losses"`
+	Draws        int            `json:"draws"`
+	AverageTurns float64        `json:"average_turns"`
+	TypicalLoss  string         `json:"typical_loss,omitempty"`
+	LossCauses   map[string]int `json:"loss_causes,omitempty"`
+}
+
+// WinRate is the share of games against the opponent that we won.
+func (m Matchup) WinRate() float64 {
+	if m.Games == 0 {
+		return 0
+	}
+	return float64(m.Wins) / float64(m.Games)
+}
+
+// summarizeMatchups totals the games up per opponent, the ones we lose to most first.
+// A game with several opponents counts towards each of them.
+func summarizeMatchups(games []GameRecord) []Matchup {
+	byOpponent := make(map[string]*Matchup)
+	turns := make(map[string]int)
+	for _, game := range games {
+		for _, opponent := range game.Opponents {
+			m, ok := byOpponent[opponent]
+			if !ok {
+				m = &Matchup{Opponent: opponent, LossCauses: make(map[string]int)}
+				byOpponent[opponent] = m
+			}
+			m.Games++
+			turns[opponent] += game.Turns
+			switch game.Outcome {
+			case Win.String():
+				m.Wins++
+			case Draw.String():
+				m.Draws++
+			case Loss.String():
+				m.Losses++
+				m.LossCauses[game.Cause]++
+			}
+		}
+	}
+
+	matchups := make([]Matchup, 0, len(byOpponent))
+	for opponent, m := range byOpponent {
+		m.AverageTurns = float64(turns[opponent]) / float64(m.Games)
+		m.TypicalLoss = typicalCause(m.LossCauses)
+		if len(m.LossCauses) == 0 {
+			m.LossCauses = nil
+		}
+		matchups = append(matchups, *m)
+	}
+	sort.Slice(matchups, func(i, j int) bool {
+		if matchups[i].Losses != matchups[j].Losses {
+			return matchups[i].Losses > matchups[j].Losses
+		}
+		if matchups[i].Games != matchups[j].Games {
+			return matchups[i].Games > matchups[j].Games
+		}
+		return matchups[i].Opponent < matchups[j].Opponent
+	})
+	return matchups
+}
+
+// typicalCause is the most common cause, ties going alphabetically so it doesn't flicker between loads.
+func typicalCause(causes map[string]int) string {
+	typical, most := "", 0
+	for cause, count := range causes {
+		if count > most || (count == most && cause < typical) {
+			typical, most = cause, count
+		}
+	}
+	return typical
+}
+
+// winRateWindow is how many games each point of the win rate sparkline is over, and winRatePoints how
+// many points it has.
+const (
+	winRateWindow = 10
+	winRatePoints = 20
+)
+
+// rollingWinRates is the share of games won in the window ending at each of the last few games, oldest
+// first. The earliest games have fewer before them to go on.
+func rollingWinRates(games []GameRecord, window, points int) []float64 {
+	first := len(games) - points
+	if first < 0 {
+		first = 0
+	}
+	rates := make([]float64, 0, len(games)-first)
+	for end := first; end < len(games); end++ {
+		start := end - window + 1
+		if start < 0 {
+			start = 0
+		}
+		wins := 0
+		for _, game := range games[start : end+1] {
+			if game.Outcome == Win.String() {
+				wins++
+			}
+		}
+		rates = append(rates, float64(wins)/float64(end-start+1))
+	}
+	return rates
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws shares between 0 and 1 as a line of block characters.
+func sparkline(shares []float64) string {
+	line := make([]rune, len(shares))
+	for i, share := range shares {
+		level := int(share*float64(len(sparks)-1) + 0.5)
+		if level < 0 {
+			level = 0
+		}
+		if level >= len(sparks) {
+			level = len(sparks) - 1
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+var matchupsPage = template.Must(template.New("matchups").Funcs(template.FuncMap{
+	"percent": func(f float64) float64 { return f * 100 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>matchups</title>
+<style>body{font-family:monospace}td,th{padding:2px 10px;text-align:left}tr:nth-child(even){background:#eee}</style>
+</head>
+<body>
+<h1>matchups</h1>
+<table>
+<tr><th>opponent</th><th>games</th><th>won</th><th>lost</th><th>drawn</th><th>win rate</th><th>avg turns</th><th>typical loss</th></tr>
+{{range .}}<tr><td>{{.Opponent}}</td><td>{{.Games}}</td><td>{{.Wins}}</td><td>{{.Losses}}</td><td>{{.Draws}}</td><td>{{printf "%.0f%%" (percent .WinRate)}}</td><td>{{printf "%.1f" .AverageTurns}}</td><td>{{.TypicalLoss}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// wantsHTML says whether the request is from a browser or asked for html, rather than wanting json.
+func wantsHTML(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "html"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// writeMatchups writes the matchups as html or json depending on what was asked for.
+func writeMatchups(w http.ResponseWriter, r *http.Request, matchups []Matchup) {
+	if !wantsHTML(r) {
+		writeJSON(w, matchups)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := matchupsPage.Execute(w, matchups); err != nil {
+		slog.Error("failed to render matchups", "error", err.Error())
+	}
+}
+
+// handleMatchups shows our record against each opponent so prep can go into the snakes we lose to.
+func handleMatchups(w http.ResponseWriter, r *http.Request) {
+	stats, err := loadGameStats(r.Context())
+	if err != nil {
+		slog.Error("failed to load game stats", "error", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeMatchups(w, r, summarizeMatchups(stats.Games))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryGameStatsStore keeps the stats in memory, bumping the generation on every write. beforePut runs
+// just before each write is checked, to slip another instance's write in underneath.
+type memoryGameStatsStore struct {
+	mu         sync.Mutex
+	data       []byte
+	generation int64
+	puts       int
+	beforePut  func(s *memoryGameStatsStore)
+}
+
+func (s *memoryGameStatsStore) Get(ctx context.Context) ([]byte, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data, s.generation, nil
+}
+
+func (s *memoryGameStatsStore) Put(ctx context.Context, data []byte, generation int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.puts++
+	if s.beforePut != nil {
+		s.beforePut(s)
+	}
+	if generation != s.generation {
+		return errGameStatsConflict
+	}
+	s.data = data
+	s.generation++
+	return nil
+}
+
+func useMemoryGameStats(t *testing.T) *memoryGameStatsStore {
+	store := &memoryGameStatsStore{}
+	saved := gameStats
+	t.Cleanup(func() { gameStats = saved })
+	gameStats = store
+	return store
+}
+
+func TestRecordGameSkipsDuplicates(t *testing.T) {
+	store := useMemoryGameStats(t)
+	require.NoError(t, recordGame(context.Background(), GameRecord{GameID: "a"}))
+	require.NoError(t, recordGame(context.Background(), GameRecord{GameID: "a"}))
+
+	stats, err := loadGameStats(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, stats.Games, 1)
+	assert.Equal(t, 1, store.puts)
+}
+
+func TestRecordGameRereadsAfterAConflict(t *testing.T) {
+	store := useMemoryGameStats(t)
+	// another instance records its game between our read and our write
+	store.beforePut = func(s *memoryGameStatsStore) {
+		s.beforePut = nil
+		data, err := json.Marshal(GameStats{Games: []GameRecord{{GameID: "theirs"}}})
+		require.NoError(t, err)
+		s.data = data
+		s.generation++
+	}
+	require.NoError(t, recordGame(context.Background(), GameRecord{GameID: "ours"}))
+
+	stats, err := loadGameStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []GameRecord{{GameID: "theirs"}, {GameID: "ours"}}, stats.Games, "neither game is lost")
+	assert.Equal(t, 2, store.puts)
+}
+
+func TestRecordGameGivesUpOnEndlessConflicts(t *testing.T) {
+	store := useMemoryGameStats(t)
+	store.beforePut = func(s *memoryGameStatsStore) { s.generation++ }
+	err := recordGame(context.Background(), GameRecord{GameID: "ours"})
+	assert.ErrorIs(t, err, errGameStatsConflict)
+	assert.Equal(t, gameStatsAttempts, store.puts)
+}
+
+func TestRecordGameConcurrently(t *testing.T) {
+	useMemoryGameStats(t)
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			assert.NoError(t, recordGame(context.Background(), GameRecord{GameID: id}))
+		}(id)
+	}
+	wg.Wait()
+
+	stats, err := loadGameStats(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, stats.Games, 3)
+}
+
+func TestSummarizeMatchups(t *testing.T) {
+	games := []GameRecord{
+		{Opponents: []string{"hungry"}, Outcome: "win", Turns: 100},
+		{Opponents: []string{"hungry"}, Outcome: "loss", Cause: CauseHeadToHead, Turns: 50},
+		{Opponents: []string{"bully"}, Outcome: "loss", Cause: CauseBody, Turns: 30},
+		{Opponents: []string{"bully"}, Outcome: "loss", Cause: CauseHeadToHead, Turns: 40},
+		{Opponents: []string{"bully", "hungry"}, Outcome: "loss", Cause: CauseHeadToHead, Turns: 20},
+		{Opponents: []string{"timid"}, Outcome: "draw", Cause: CauseAllDied, Turns: 200},
+	}
+
+	matchups := summarizeMatchups(games)
+
+	require.Len(t, matchups, 3)
+	assert.Equal(t, Matchup{
+		Opponent:     "bully",
+		Games:        3,
+		Losses:       3,
+		AverageTurns: 30,
+		TypicalLoss:  CauseHeadToHead,
+		LossCauses:   map[string]int{CauseBody: 1, CauseHeadToHead: 2},
+	}, matchups[0])
+	assert.Equal(t, Matchup{
+		Opponent:     "hungry",
+		Games:        3,
+		Wins:         1,
+		Losses:       2,
+		AverageTurns: 170.0 / 3,
+		TypicalLoss:  CauseHeadToHead,
+		LossCauses:   map[string]int{CauseHeadToHead: 2},
+	}, matchups[1])
+	assert.Equal(t, Matchup{Opponent: "timid", Games: 1, Draws: 1, AverageTurns: 200}, matchups[2])
+	assert.InDelta(t, 1.0/3, matchups[1].WinRate(), 1e-9)
+}
+
+func TestTypicalCauseBreaksTiesAlphabetically(t *testing.T) {
+	assert.Equal(t, "", typicalCause(nil))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, CauseBody, typicalCause(map[string]int{CauseStarved: 2, CauseBody: 2, CauseWall: 1}))
+	}
+}
+
+func TestRollingWinRates(t *testing.T) {
+	var games []GameRecord
+	for _, outcome := range []string{"win", "loss", "win", "win", "draw", "loss"} {
+		games = append(games, GameRecord{Outcome: outcome})
+	}
+	assert.Empty(t, rollingWinRates(nil, 3, 4))
+	assert.Equal(t, []float64{1, 0.5, 2.0 / 3, 2.0 / 3}, rollingWinRates(games[:4], 3, 4), "fewer games to go on at the start")
+	assert.Equal(t, []float64{2.0 / 3, 1.0 / 3}, rollingWinRates(games, 3, 2))
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "", sparkline(nil))
+	assert.Equal(t, "▁▃▅█", sparkline([]float64{0, 0.3, 0.6, 1}))
+}
+
+func TestNewGameRecord(t *testing.T) {
+	testCases := []struct {
+		Description string
+		You         Snake
+		Others      []Snake
+		Outcome     string
+		Cause       string
+	}{
+		{
+			Description: "head to head",
+			You:         Snake{ID: "me", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 1, Y: 2}, {X: 0, Y: 2}}},
+			Others:      []Snake{{ID: "them", Name: "them", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 3, Y: 2}, {X: 4, Y: 2}}}},
+			Outcome:     "loss",
+			Cause:       CauseHeadToHead,
+		},
+		{
+			Description: "into a body",
+			You:         Snake{ID: "me", Health: 50, Head: Point{X: 3, Y: 2}, Body: []Point{{X: 3, Y: 2}, {X: 3, Y: 1}, {X: 3, Y: 0}}},
+			Others:      []Snake{{ID: "them", Name: "them", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 3, Y: 2}, {X: 4, Y: 2}}}},
+			Outcome:     "loss",
+			Cause:       CauseBody,
+		},
+		{
+			Description: "wall",
+			You:         Snake{ID: "me", Health: 50, Head: Point{X: -1, Y: 2}, Body: []Point{{X: -1, Y: 2}, {X: 0, Y: 2}, {X: 1, Y: 2}}},
+			Outcome:     "loss",
+			Cause:       CauseWall,
+		},
+		{
+			Description: "won",
+			You:         Snake{ID: "me", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 1, Y: 2}, {X: 0, Y: 2}}},
+			Outcome:     "win",
+			Cause:       CauseLastAlive,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			game := BattleSnakeGame{Turn: 42, You: tc.You}
+			game.Game.ID = "game"
+			game.Board.Width, game.Board.Height = 11, 11
+			game.Board.Snakes = append([]Snake{tc.You}, tc.Others...)
+			session := newGameSession(game, []string{"them"})
+			end := time.Now()
+
+			record := newGameRecord(session, game, end)
+
+			assert.Equal(t, GameRecord{
+				GameID:    "game",
+				Time:      end,
+				Opponents: []string{"them"},
+				Outcome:   tc.Outcome,
+				Cause:     tc.Cause,
+				Turns:     42,
+				Engine:    engineBuild.String(),
+			}, record)
+		})
+	}
+}
+
+func TestWriteMatchups(t *testing.T) {
+	matchups := []Matchup{{Opponent: "<bully>", Games: 4, Wins: 1, Losses: 3, AverageTurns: 30, TypicalLoss: CauseHeadToHead}}
+	testCases := []struct {
+		Description string
+		Target      string
+		Accept      string
+		ContentType string
+	}{
+		{Description: "json by default", Target: "/matchups", ContentType: "application/json"},
+		{Description: "html for browsers", Target: "/matchups", Accept: "text/html,application/xhtml+xml", ContentType: "text/html; charset=utf-8"},
+		{Description: "html when asked", Target: "/matchups?format=html", ContentType: "text/html; charset=utf-8"},
+		{Description: "json when asked", Target: "/matchups?format=json", Accept: "text/html", ContentType: "application/json"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.Target, nil)
+			req.Header.Set("Accept", tc.Accept)
+			rec := httptest.NewRecorder()
+
+			writeMatchups(rec, req, matchups)
+
+			assert.Equal(t, tc.ContentType, rec.Header().Get("Content-Type"))
+			if tc.ContentType == "application/json" {
+				var got []Matchup
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+				assert.Equal(t, matchups, got)
+				return
+			}
+			body := rec.Body.String()
+			assert.Contains(t, body, "&lt;bully&gt;")
+			assert.Contains(t, body, "25%")
+			assert.Contains(t, body, CauseHeadToHead)
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+)
+
+// SearchEngine is the algorithm /move picks its move with.
+type SearchEngine int
+
+const (
+	// EngineMCTS is the tree search every worker shares, the default.
+	EngineMCTS SearchEngine = iota
+	// EngineMultiMCTS is the tree search with each worker growing its own tree from the root.
+	EngineMultiMCTS
+	// EngineMaxN is a depth limited MaxN search, deepened a turn at a time until the move's time is up.
+	EngineMaxN
+	// EngineParanoid is the MaxN search with every opponent playing against us, whatever MAXN_PRUNING says.
+	EngineParanoid
+	// EngineBRS is best-reply search, only the opponent with the worst answer for us getting to reply.
+	EngineBRS
+	// EngineDUCT is decoupled UCT, every snake picking its move at once the way the game has them do.
+	EngineDUCT
+	// EngineAuto picks an engine every turn going by the board and ruleset, see selectEngine.
+	EngineAuto
+	// EngineEndgame is the endgame solver. It's never configured, auto or not it gets first go at the small
+	// duels it can prove.
+	EngineEndgame
+)
+
+func (e SearchEngine) String() string {
+	switch e {
+	case EngineMultiMCTS:
+		return "multimcts"
+	case EngineMaxN:
+		return "maxn"
+	case EngineParanoid:
+		return "paranoid"
+	case EngineBRS:
+		return "brs"
+	case EngineDUCT:
+		return "duct"
+	case EngineAuto:
+		return "auto"
+	case EngineEndgame:
+		return "endgame"
+	}
+	return "mcts"
+}
+
+// searchEngineFromEnv reads ENGINE, which pins one of mcts, multimcts, maxn, paranoid, brs or duct. Anything
+// else is auto.
+func searchEngineFromEnv() SearchEngine {
+	return parseSearchEngine(os.Getenv("ENGINE"))
+}
+
+// parseSearchEngine is the engine the name pins, or auto.
+func parseSearchEngine(name string) SearchEngine {
+	switch name {
+	case "mcts":
+		return EngineMCTS
+	case "maxn":
+		return EngineMaxN
+	case "paranoid":
+		return EngineParanoid
+	case "brs":
+		return EngineBRS
+	case "multimcts":
+		return EngineMultiMCTS
+	case "duct":
+		return EngineDUCT
+	}
+	return EngineAuto
+}
+
+// MaxNPruning is how the MaxN search cuts lines it doesn't need.
+type MaxNPruning int
+
+const (
+	// MaxNShallow is Korf's shallow pruning: a snake stops looking once it has found a reply so good for it
+	// that the snake before can't want this line. It returns exactly what plain MaxN would.
+	MaxNShallow MaxNPruning = iota
+	// MaxNNone searches every line.
+	MaxNNone
+	// MaxNParanoid assumes every opponent plays against us and searches with alpha-beta. It prunes far more
+	// but plays as if the opponents were in it together.
+	MaxNParanoid
+)
+
+func (p MaxNPruning) String() string {
+	switch p {
+	case MaxNNone:
+		return "none"
+	case MaxNParanoid:
+		return "paranoid"
+	}
+	return "shallow"
+}
+
+// maxnPruningFromEnv reads MAXN_PRUNING, which is shallow, none or paranoid. Anything else is shallow.
+func maxnPruningFromEnv() MaxNPruning {
+	return parseMaxNPruning(os.Getenv("MAXN_PRUNING"))
+}
+
+// parseMaxNPruning is the pruning the name picks, or shallow.
+func parseMaxNPruning(name string) MaxNPruning {
+	switch name {
+	case "none":
+		return MaxNNone
+	case "paranoid":
+		return MaxNParanoid
+	}
+	return MaxNShallow
+}
+
+const (
+	// maxnMaxDepth is the most turns the MaxN search deepens to
+	maxnMaxDepth = 32
+	// maxnScoreShift moves scores from the evaluation's -2 to 2 up to 0 to 4, so shallow pruning has the
+	// non-negative scores it needs
+	maxnScoreShift = 2
+)
+
+// MaxNResult is the move the MaxN search settled on and how far it got.
+type MaxNResult struct {
+	Move   Direction
+	Scores []float64 // every snake's score along the line the move leads to by snake index, just ours when paranoid
+	Depth  int       // turns deep the last finished pass went
+	Nodes  int
+}
+
+// maxnSearcher holds a MaxN search's settings while it runs.
+type maxnSearcher struct {
+	ctx     context.Context
+	config  SearchConfig
+	modules []EvaluationModule
+	pruning MaxNPruning
+	// maxSum is the most the shifted scores can add up to. A duel scored zero sum always adds up to the
+	// same, which is what lets shallow pruning cut anything.
+	maxSum  float64
+	nodes   int
+	aborted bool
+}
+
+// MaxNSearch searches the board with every snake picking the move best for itself, a ply per snake in the
+// same order as the tree search, scoring leaves with the config's modules. It deepens a turn at a time and
+// returns the last pass that finished before the context ended. Chance is left out: no food spawns and no
+// hazards close in while it looks ahead.
+func MaxNSearch(ctx context.Context, board Board, config SearchConfig, pruning MaxNPruning) MaxNResult {
+	return maxnSearchTo(ctx, board, config, pruning, maxnMaxDepth)
+}
+
+// depthFirstSearch runs the config's engine if it's one of the searches that deepen a turn at a time
+// instead of growing a tree, and says whether it was.
+func depthFirstSearch(ctx context.Context, board Board, config SearchConfig) (MaxNResult, bool) {
+	switch config.Engine {
+	case EngineMaxN:
+		return MaxNSearch(ctx, board, config, config.Pruning), true
+	case EngineParanoid:
+		return MaxNSearch(ctx, board, config, MaxNParanoid), true
+	case EngineBRS:
+		return BestReplySearch(ctx, board, config), true
+	}
+	return MaxNResult{}, false
+}
+
+// maxnSearchTo is MaxNSearch going no deeper than maxDepth turns.
+func maxnSearchTo(ctx context.Context, board Board, config SearchConfig, pruning MaxNPruning, maxDepth int) MaxNResult {
+	s := &maxnSearcher{
+		ctx:     ctx,
+		config:  config,
+		modules: config.fullModules(),
+		pruning: pruning,
+		maxSum:  2 * maxnScoreShift * float64(len(board.Snakes)),
+	}
+
+	result := MaxNResult{Move: Unset}
+	if len(board.Snakes) == 0 || isSnakeDead(board.Snakes[0]) {
+		return result
+	}
+	alive := 0
+	for _, snake := range board.Snakes {
+		if !isSnakeDead(snake) {
+			alive++
+		}
+	}
+	// with more than two left a forced zero sum gives the same score to everyone else, which doesn't add up
+	// to anything fixed
+	if config.Scoring.zeroSum(board) && alive == 2 {
+		s.maxSum = 2 * maxnScoreShift
+	}
+	// a move to play even if not one pass finishes
+	moves := maxnMoves(board, 0)
+	result.Move = moves[0]
+	for depth := 1; depth <= maxDepth && ctx.Err() == nil; depth++ {
+		// the last pass's best move goes first, which is where the pruning gets most of its cuts
+		moves = append([]Direction{result.Move}, removeDirection(moves, result.Move)...)
+		move, scores := s.root(board, moves, depth*alive)
+		if s.aborted {
+			break
+		}
+		result.Move, result.Scores, result.Depth = move, scores, depth
+		// nothing more to find once every line ends before the depth does
+		if isTerminal(board) {
+			break
+		}
+	}
+	result.Nodes = s.nodes
+	return result
+}
+
+// root picks our move at the ply depth.
+func (s *maxnSearcher) root(board Board, moves []Direction, plies int) (Direction, []float64) {
+	best, bestScores := Unset, []float64(nil)
+	alpha := math.Inf(-1)
+	for _, move := range moves {
+		child, next := s.play(board, 0, move)
+		var scores []float64
+		if s.pruning == MaxNParanoid {
+			value := s.paranoid(child, next, plies-1, alpha, math.Inf(1))
+			scores = []float64{value}
+		} else {
+			bound := math.Inf(-1)
+			if bestScores != nil {
+				bound = bestScores[0]
+			}
+			scores = s.maxn(child, next, plies-1, 0, bound)
+		}
+		if s.aborted {
+			return Unset, nil
+		}
+		if bestScores == nil || scores[0] > bestScores[0] {
+			best, bestScores = move, scores
+			alpha = scores[0]
+		}
+	}
+	return best, bestScores
+}
+
+// maxn is every snake's score with the mover to pick. parent is the snake that moved into the board and
+// bound its best score so far from its other moves, which is what shallow pruning cuts against.
+func (s *maxnSearcher) maxn(board Board, mover, plies, parent int, bound float64) []float64 {
+	if scores, ok := s.leaf(board, mover, plies); ok {
+		return scores
+	}
+	var best []float64
+	for _, move := range maxnMoves(board, mover) {
+		childBound := math.Inf(-1)
+		if best != nil {
+			childBound = best[mover]
+		}
+		child, next := s.play(board, mover, move)
+		scores := s.maxn(child, next, plies-1, mover, childBound)
+		if s.aborted {
+			return scores
+		}
+		if best == nil || scores[mover] > best[mover] {
+			best = scores
+		}
+		// whatever's left for the parent here is no better than what it already has
+		if s.pruning == MaxNShallow && parent != mover && best[mover] >= s.maxSum-bound {
+			break
+		}
+	}
+	return best
+}
+
+// paranoid is our score with the mover to pick, every other snake picking whatever's worst for us.
+func (s *maxnSearcher) paranoid(board Board, mover, plies int, alpha, beta float64) float64 {
+	if scores, ok := s.leaf(board, mover, plies); ok {
+		return scores[0]
+	}
+	ours := mover == 0
+	value := math.Inf(1)
+	if ours {
+		value = math.Inf(-1)
+	}
+	for _, move := range maxnMoves(board, mover) {
+		child, next := s.play(board, mover, move)
+		score := s.paranoid(child, next, plies-1, alpha, beta)
+		if s.aborted {
+			return score
+		}
+		if ours {
+			value = math.Max(value, score)
+			alpha = math.Max(alpha, value)
+		} else {
+			value = math.Min(value, score)
+			beta = math.Min(beta, value)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return value
+}
+
+// leaf scores the board if the search stops here, shifted so none of them are negative.
+func (s *maxnSearcher) leaf(board Board, mover, plies int) ([]float64, bool) {
+	s.nodes++
+	if s.nodes%256 == 0 && s.ctx.Err() != nil {
+		s.aborted = true
+	}
+	if plies > 0 && !isTerminal(board) && !s.aborted {
+		return nil, false
+	}
+	scores := s.config.Scoring.scores(board, s.modules)
+	for i := range scores {
+		scores[i] = math.Max(0, math.Min(2*maxnScoreShift, scores[i]+maxnScoreShift))
+	}
+	return scores, true
+}
+
+// play is the board after the mover's move and the snake to move next. The last living snake's move
+// ends the turn, and the next starts again from the first.
+func (s *maxnSearcher) play(board Board, mover int, move Direction) (Board, int) {
+	child := copyBoard(board)
+	applyMove(&child, mover, move)
+	for next := mover + 1; next < len(child.Snakes); next++ {
+		if !isSnakeDead(child.Snakes[next]) {
+			return child, next
+		}
+	}
+	child.Turn++
+	for next := range child.Snakes {
+		if !isSnakeDead(child.Snakes[next]) {
+			return child, next
+		}
+	}
+	return child, 0
+}
+
+// maxnMoves are the snake's safe moves, or one move to die with if it has none.
+func maxnMoves(board Board, index int) []Direction {
+	if moves := generateSafeMoves(board, index); len(moves) > 0 {
+		return moves
+	}
+	return []Direction{Up}
+}
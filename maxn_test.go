@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEngineFromEnv(t *testing.T) {
+	for value, want := range map[string]SearchEngine{"": EngineAuto, "mcts": EngineMCTS, "maxn": EngineMaxN, "paranoid": EngineParanoid, "brs": EngineBRS, "duct": EngineDUCT, "multimcts": EngineMultiMCTS, "alphazero": EngineAuto} {
+		t.Setenv("ENGINE", value)
+		assert.Equal(t, want, searchEngineFromEnv(), value)
+	}
+	for value, want := range map[string]MaxNPruning{"": MaxNShallow, "none": MaxNNone, "paranoid": MaxNParanoid, "deep": MaxNShallow} {
+		t.Setenv("MAXN_PRUNING", value)
+		assert.Equal(t, want, maxnPruningFromEnv(), value)
+	}
+
+	t.Setenv("ENGINE", "multimcts")
+	t.Setenv("SEARCH_PARALLEL", "")
+	assert.Equal(t, ParallelRoot, loadSearchConfig().Parallel, "the multi-tree engine grows a tree per worker")
+}
+
+func TestMaxNShallowPruningMatchesPlain(t *testing.T) {
+	duel := evalTestBoard()
+	duel.Snakes = duel.Snakes[:2]
+	for name, board := range map[string]Board{"three snakes": evalTestBoard(), "duel": duel} {
+		for _, config := range []SearchConfig{{}, {Scoring: ScoringMultiPlayer}} {
+			plain := maxnSearchTo(context.Background(), board, config, MaxNNone, 2)
+			shallow := maxnSearchTo(context.Background(), board, config, MaxNShallow, 2)
+			assert.Equal(t, plain.Move, shallow.Move, name)
+			assert.Equal(t, plain.Scores, shallow.Scores, name)
+			assert.Equal(t, 2, shallow.Depth, name)
+			assert.LessOrEqual(t, shallow.Nodes, plain.Nodes, name)
+		}
+	}
+
+	// zero sum duels are where it really cuts
+	plain := maxnSearchTo(context.Background(), duel, SearchConfig{}, MaxNNone, 2)
+	shallow := maxnSearchTo(context.Background(), duel, SearchConfig{}, MaxNShallow, 2)
+	assert.Less(t, shallow.Nodes, plain.Nodes)
+}
+
+func TestMaxNParanoidPrunes(t *testing.T) {
+	board := evalTestBoard()
+	plain := maxnSearchTo(context.Background(), board, SearchConfig{}, MaxNNone, 2)
+	paranoid := maxnSearchTo(context.Background(), board, SearchConfig{}, MaxNParanoid, 2)
+	assert.Less(t, paranoid.Nodes, plain.Nodes)
+	require.Len(t, paranoid.Scores, 1, "paranoid only keeps our score")
+	assert.LessOrEqual(t, paranoid.Scores[0], plain.Scores[0], "expecting the worst never scores better")
+}
+
+func TestMaxNTakesTheWin(t *testing.T) {
+	for _, pruning := range []MaxNPruning{MaxNNone, MaxNShallow, MaxNParanoid} {
+		result := maxnSearchTo(context.Background(), cornerEndgame(), SearchConfig{}, pruning, 3)
+		assert.Contains(t, []Direction{Down, Right}, result.Move, pruning.String())
+		assert.Equal(t, 2.0*maxnScoreShift, result.Scores[0], "%s sees them die", pruning)
+	}
+}
+
+func TestMaxNStopsWithTheContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := MaxNSearch(ctx, evalTestBoard(), SearchConfig{}, MaxNShallow)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Contains(t, generateSafeMoves(evalTestBoard(), 0), result.Move)
+	assert.Less(t, result.Depth, maxnMaxDepth)
+
+	cancel()
+	result = MaxNSearch(ctx, evalTestBoard(), SearchConfig{}, MaxNShallow)
+	assert.Zero(t, result.Depth)
+	assert.Contains(t, generateSafeMoves(evalTestBoard(), 0), result.Move, "still a safe move with no time at all")
+}
+
+func TestMaxNEngineAnswersMoves(t *testing.T) {
+	router := newRouter("")
+	game := sessionTestGame("session-maxn")
+	session := newGameSession(game, []string{"b"})
+	session.Config.Engine = EngineMaxN
+	productionSnake.startSession(session)
+	defer productionSnake.endSession(game)
+
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Contains(t, []string{"up", "right"}, response["move"])
+	records := session.decisions.Records()
+	require.Len(t, records, 1)
+	assert.Equal(t, "maxn", records[0].Algorithm)
+	assert.Positive(t, records[0].MaxDepth)
+}
@@ -11,29 +11,45 @@ import (
 
 // Node represents a node in the MCTS tree.
 type Node struct {
-	Board           Board
-	SnakeIndex      int // The index of the snake whose turn it is at this node.
-	Parent          *Node
-	Children        []*Node
-	Visits          int64
-	Score           float64 // Cumulative score from simulations.
-	MyScore         float64 // The initial evaluation score of this node.
-	UnexpandedMoves []Direction
-
-	mutex sync.Mutex
+	Board      Board
+	SnakeIndex int // The index of the snake whose turn it is at this node.
+	Parent     *Node
+	Visits     int64
+	Score      float64   // Cumulative score from simulations for the snake that moved into this node, Scores[SnakeIndex].
+	Scores     []float64 // Cumulative score from simulations for every snake, by snake index.
+	MyScore    float64   // The evaluation score of this node for SnakeIndex, replaced if it gets re-evaluated.
+	MyScores   []float64 // The evaluation score of this node for every snake.
+	Prior      float64   // The parent's policy prior on the move into this node, 0 without a policy.
+
+	// children are swapped for a longer copy as they're added, never changed in place, so a worker can
+	// range over what it loaded while others expand the node.
+	children atomic.Pointer[[]*Node]
+	// moves are the next snake's moves in the order they get expanded. expanded counts the ones workers
+	// have claimed, so each move is expanded exactly once without taking the lock.
+	moves    []Direction
+	expanded int32
+	// priors are the policy's priors on the next snake's moves, by direction. nil without a policy.
+	priors []float64
+	// chance is set on a node whose board is waiting on the end of the turn. Its children are the outcomes
+	// rolled so far rather than moves.
+	chance bool
+
+	mutex         sync.Mutex
+	leafVisits    int64 // simulations that stopped here and used MyScore
+	reevaluations int32 // re-evaluation thresholds already passed
+	inFlight      int32 // workers on their way down through this node, each counted as a virtual loss
 }
 
 // NewNode initializes a new Node and generates possible moves.
 func NewNode(board Board, snakeIndex int, parent *Node) *Node {
 	node := &Node{
-		Board:           board,
-		SnakeIndex:      snakeIndex,
-		Parent:          parent,
-		Children:        make([]*Node, 0),
-		Visits:          0,
-		Score:           0,
-		MyScore:         0,
-		UnexpandedMoves: nil,
+		Board:      board,
+		SnakeIndex: snakeIndex,
+		Parent:     parent,
+		Visits:     0,
+		Score:      0,
+		Scores:     make([]float64, len(board.Snakes)),
+		MyScore:    0,
 	}
 
 	// If the node is terminal, there are no moves to expand.
@@ -51,10 +67,78 @@ func NewNode(board Board, snakeIndex int, parent *Node) *Node {
 		moves = []Direction{Up, Down, Left, Right}
 	}
 
-	node.UnexpandedMoves = moves
+	node.moves = moves
 	return node
 }
 
+// Children are the nodes expanded from this one so far. The slice is never changed once it's handed out.
+func (n *Node) Children() []*Node {
+	if children := n.children.Load(); children != nil {
+		return *children
+	}
+	return nil
+}
+
+// setChildren replaces the node's children. It's for rearranging a tree nothing is searching.
+func (n *Node) setChildren(children []*Node) {
+	n.children.Store(&children)
+}
+
+// addChild publishes a newly expanded child alongside the others.
+func (n *Node) addChild(child *Node) {
+	for {
+		old := n.children.Load()
+		var children []*Node
+		if old != nil {
+			children = make([]*Node, len(*old), len(*old)+1)
+			copy(children, *old)
+		}
+		children = append(children, child)
+		if n.children.CompareAndSwap(old, &children) {
+			return
+		}
+	}
+}
+
+// UnexpandedMoves are the moves no worker has claimed to expand yet.
+func (n *Node) UnexpandedMoves() []Direction {
+	claimed := int(atomic.LoadInt32(&n.expanded))
+	if claimed >= len(n.moves) {
+		return nil
+	}
+	return n.moves[claimed:]
+}
+
+// setUnexpandedMoves replaces the moves still to expand. It's for rearranging a tree nothing is searching.
+func (n *Node) setUnexpandedMoves(moves []Direction) {
+	n.moves = moves
+	atomic.StoreInt32(&n.expanded, 0)
+}
+
+// claimMove takes the next move to expand, if there's one left. Whoever wins the swap owns the move.
+func (n *Node) claimMove() (Direction, bool) {
+	for {
+		claimed := atomic.LoadInt32(&n.expanded)
+		if int(claimed) >= len(n.moves) {
+			return Unset, false
+		}
+		if atomic.CompareAndSwapInt32(&n.expanded, claimed, claimed+1) {
+			return n.moves[claimed], true
+		}
+	}
+}
+
+// preferMove puts the move first among those still to expand, so it's expanded next.
+func (n *Node) preferMove(move Direction) {
+	moves := n.UnexpandedMoves()
+	for i := range moves {
+		if moves[i] == move {
+			moves[0], moves[i] = moves[i], moves[0]
+			return
+		}
+	}
+}
+
 // isTerminal checks if the game has reached a terminal state.
 func isTerminal(board Board) bool {
 	aliveSnakesCount := 0
@@ -72,14 +156,29 @@ func isSnakeDead(snake Snake) bool {
 }
 
 // UCT calculates the Upper Confidence Bound for Trees (UCT) value.
+// It's from the point of view of the snake choosing between the parent's children, which is the one that moved into them.
 func (n *Node) UCT(explorationParam float64) float64 {
+	return n.uctWithLoss(explorationParam, 0)
+}
+
+// uctWithLoss is UCT with every worker still on its way down through the node counted as a visit that
+// lost by virtualLoss, so the next worker along tries something else instead of piling onto the same leaf.
+func (n *Node) uctWithLoss(explorationParam float64, virtualLoss float64) float64 {
 	visits := atomic.LoadInt64(&n.Visits)
+	score := loadFloat64(&n.Score)
+	var parentInFlight int64
+	if virtualLoss > 0 {
+		inFlight := int64(atomic.LoadInt32(&n.inFlight))
+		visits += inFlight
+		score -= float64(inFlight) * virtualLoss
+		parentInFlight = int64(atomic.LoadInt32(&n.Parent.inFlight))
+	}
 	if visits == 0 {
 		return math.MaxFloat64
 	}
 
-	parentVisits := atomic.LoadInt64(&n.Parent.Visits)
-	exploitation := n.Score / float64(visits)
+	parentVisits := atomic.LoadInt64(&n.Parent.Visits) + parentInFlight
+	exploitation := score / float64(visits)
 	exploration := explorationParam * math.Sqrt(math.Log(float64(parentVisits))/float64(visits))
 
 	return exploitation + exploration
@@ -87,19 +186,47 @@ func (n *Node) UCT(explorationParam float64) float64 {
 
 // bestChild selects the best child node based on the UCT value.
 func bestChild(node *Node, explorationParam float64) *Node {
-	if len(node.Children) == 0 {
+	return bestChildWithBonus(node, explorationParam, nil)
+}
+
+// bestChildWithBonus selects the best child node based on the UCT value plus an optional per child bonus.
+func bestChildWithBonus(node *Node, explorationParam float64, bonus func(child *Node) float64) *Node {
+	return bestChildWithLoss(node, explorationParam, bonus, 0)
+}
+
+// bestChildWithLoss is bestChildWithBonus with workers already heading through a child counted against it.
+func bestChildWithLoss(node *Node, explorationParam float64, bonus func(child *Node) float64, virtualLoss float64) *Node {
+	return bestChildBy(node, bonus, func(child *Node) float64 {
+		return child.uctWithLoss(explorationParam, virtualLoss)
+	})
+}
+
+// bestPUCTChild is bestChildWithLoss picking by PUCT, for searches with a policy.
+func bestPUCTChild(node *Node, explorationParam float64, bonus func(child *Node) float64, virtualLoss float64) *Node {
+	return bestChildBy(node, bonus, func(child *Node) float64 {
+		return child.puctWithLoss(explorationParam, virtualLoss)
+	})
+}
+
+// bestChildBy selects the child with the highest value plus its bonus, if any.
+func bestChildBy(node *Node, bonus func(child *Node) float64, valueOf func(child *Node) float64) *Node {
+	children := node.Children()
+	if len(children) == 0 {
 		return nil // No children available.
 	}
 
 	bestValue := -math.MaxFloat64
 	var bestNodes []*Node
 
-	for _, child := range node.Children {
+	for _, child := range children {
 		if child == nil {
 			continue // Skip nil children.
 		}
 
-		value := child.UCT(explorationParam)
+		value := valueOf(child)
+		if bonus != nil && value != math.MaxFloat64 {
+			value += bonus(child)
+		}
 
 		if value > bestValue {
 			bestValue = value
@@ -116,8 +243,56 @@ func bestChild(node *Node, explorationParam float64) *Node {
 	return nil
 }
 
+// searchOptions holds the optional parameters of a search.
+type searchOptions struct {
+	preferredMove Direction // Move at the root that the search should lean towards.
+	config        SearchConfig
+	throttle      *ThrottleGuard
+	cutoff        *EarlyCutoff
+	ticket        *SearchTicket // share of the workers from the scheduler, if any
+	finished      chan struct{} // closed once the root has all the visits it needs
+}
+
+// evaluate scores a leaf, falling back to the cheap modules if the search has been degraded.
+func (o *searchOptions) evaluate(node *Node) []float64 {
+	if o.throttle.Degraded() {
+		return o.config.scores(node.Board, cheapModules)
+	}
+	return o.config.evaluate(node)
+}
+
+// WithPreferredMove biases the root of the search towards the given move for snake 0.
+func WithPreferredMove(move Direction) func(*searchOptions) {
+	return func(o *searchOptions) {
+		o.preferredMove = move
+	}
+}
+
+// rootBonus returns the bonus function for the root's children given the options.
+func (o *searchOptions) rootBonus(rootNode *Node) func(child *Node) float64 {
+	if o.preferredMove == Unset {
+		return nil
+	}
+	preferredHead := moveOnBoard(&rootNode.Board, rootNode.Board.Snakes[0].Head, o.preferredMove)
+	return func(child *Node) float64 {
+		if child.Board.Snakes[0].Head == preferredHead {
+			return planBias
+		}
+		return 0
+	}
+}
+
 // MCTS performs the Monte Carlo Tree Search with concurrency.
-func MCTS(ctx context.Context, gameID string, rootBoard Board, iterations int, numWorkers int, gameStates map[string]*Node) *Node {
+// It returns once the context is done or the root has been visited the given number of times.
+func MCTS(ctx context.Context, gameID string, rootBoard Board, iterations int, numWorkers int, gameStates map[string]*Node, options ...func(*searchOptions)) *Node {
+	opts := &searchOptions{
+		preferredMove: Unset,
+		config:        defaultSearchConfig,
+	}
+	for _, opt := range options {
+		opt(opts)
+	}
+
 	// Generate the hash for the current board state.
 	boardKey := boardHash(rootBoard)
 	var rootNode *Node
@@ -125,23 +300,86 @@ func MCTS(ctx context.Context, gameID string, rootBoard Board, iterations int, n
 	if existingNode, ok := gameStates[boardKey]; ok {
 		slog.Info("board cache lookup", "hit", true, "cache_size", len(gameStates), "visits", existingNode.Visits)
 		rootNode = existingNode
+		// cut it loose from last turn's tree so visits stop flowing up into it and the rest can be collected
+		rootNode.Parent = nil
 	} else {
 		slog.Info("board cache lookup", "hit", false, "cache_size", len(gameStates))
 		// Initialize rootNode with the current snake's index (e.g., -1 for the initial state).
 		rootNode = NewNode(rootBoard, -1, nil)
 	}
 
-	for i := 0; i < numWorkers; i++ {
-		go worker(ctx, rootNode)
+	return searchFrom(ctx, gameID, rootNode, iterations, numWorkers, opts)
+}
+
+// searchFrom runs the search from the root node until the context ends or the root has enough visits.
+func searchFrom(ctx context.Context, gameID string, rootNode *Node, iterations int, numWorkers int, opts *searchOptions) *Node {
+	// a root that hasn't expanded anything yet takes the policy's order, a reused one keeps what it has
+	if rootNode.priors == nil && len(rootNode.Children()) == 0 {
+		opts.config.applyPolicy(rootNode)
+	}
+	trees := workerTrees(rootNode, numWorkers, opts.config.Parallel)
+	// Expand the preferred move first so it gets visits straight away.
+	if opts.preferredMove != Unset {
+		for _, tree := range trees {
+			tree.preferMove(opts.preferredMove)
+		}
+	}
+
+	if opts.config.Luck != nil && len(rootNode.Board.Snakes) > 0 {
+		opts.config.standing = evaluateBoard(rootNode.Board, 0, opts.config.fullModules())
 	}
 
-	<-ctx.Done()
+	if opts.throttle != nil {
+		var stop context.CancelFunc
+		ctx, stop = context.WithCancel(ctx)
+		defer stop()
+		go opts.throttle.watch(ctx, stop, rootNode, numWorkers)
+	}
+	if opts.cutoff != nil && opts.config.Parallel != ParallelRoot {
+		var stop context.CancelFunc
+		ctx, stop = context.WithCancel(ctx)
+		defer stop()
+		go opts.cutoff.watch(ctx, stop, rootNode)
+	}
+
+	// Workers stop on their own once the deadline passes or the root has enough visits. The first to see
+	// enough visits says so, so workers waiting on the scheduler for a share don't wait for the deadline.
+	opts.finished = make(chan struct{})
+	var finish sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			worker(ctx, gameID, rootNode, trees[index], int64(iterations), index, opts)
+			if atomic.LoadInt64(&rootNode.Visits) >= int64(iterations) {
+				finish.Do(func() { close(opts.finished) })
+			}
+		}(i)
+	}
+	wg.Wait()
+	mergeTrees(rootNode, trees)
 
 	return rootNode
 }
 
-// worker performs MCTS iterations, managing synchronization appropriately.
-func worker(ctx context.Context, rootNode *Node) {
+// worker performs MCTS iterations on its tree, managing synchronization appropriately. The tree is the
+// root itself unless the search is root parallel, either way the iterations are counted at the root.
+// A panic inside the worker is reported and only stops this worker, the rest of the search carries on.
+// The index lets a throttle guard stand some of the workers down.
+func worker(ctx context.Context, gameID string, rootNode *Node, tree *Node, iterations int64, index int, opts *searchOptions) {
+	var node *Node
+	// the node whose path down still carries this worker's virtual loss, so a panic doesn't leave it on a
+	// tree that gets reused next turn
+	var inFlight *Node
+	rootBonus := opts.rootBonus(tree)
+	defer func() {
+		if r := recover(); r != nil {
+			opts.config.releaseVirtualLoss(inFlight, tree)
+			reportPanic(gameID, node, r)
+		}
+	}()
+
 	for {
 		// Check if the context is done.
 		select {
@@ -151,104 +389,174 @@ func worker(ctx context.Context, rootNode *Node) {
 			// Continue execution.
 		}
 
-		node := selectNode(ctx, rootNode)
+		if atomic.LoadInt64(&rootNode.Visits) >= iterations || !opts.throttle.allows(index) {
+			return
+		}
+
+		// wait while other games need this worker more
+		if !opts.ticket.wait(ctx, opts.finished, index) {
+			return
+		}
+
+		// if selection itself panics, report the root we started from
+		node = tree
+		node = selectNode(ctx, tree, rootBonus, opts.config)
+		inFlight = node
 
 		// If context was cancelled during selection.
 		if node == nil || ctx.Err() != nil {
+			opts.config.releaseVirtualLoss(inFlight, tree)
 			return
 		}
 
 		// Simulation.
-		var score float64
+		var scores []float64
+		var visits int64
 		if atomic.LoadInt64(&node.Visits) == 0 {
-			// Evaluate from the perspective of the root snake.
-			score = evaluateBoard(node.Board, node.SnakeIndex, modules)
+			// Evaluate from the perspective of every snake.
+			scores = opts.evaluate(node)
 
-			// Update node's own score and visits atomically.
-			atomic.AddInt64(&node.Visits, 1)
-			atomicAddFloat64(&node.Score, score)
-			node.MyScore = score // Save the initial evaluation score.
+			// Save the initial evaluation scores.
+			node.setMyScores(scores)
 		} else {
-			// Node has been visited before; use existing MyScore.
-			score = node.MyScore
-
-			// Update visits and score atomically.
-			atomicAddFloat64(&node.Score, score)
-			atomic.AddInt64(&node.Visits, 1)
+			// Node has been visited before; use existing MyScores.
+			node.mutex.Lock()
+			scores = node.MyScores
+			node.mutex.Unlock()
 		}
-
-		// Backpropagation.
+		// the real visit replaces the virtual one on the way back up
+		opts.config.releaseVirtualLoss(inFlight, tree)
+		inFlight = nil
+		node.addScores(scores)
+		visits = atomic.AddInt64(&node.Visits, 1)
+		atomic.AddInt64(&node.leafVisits, 1)
+		opts.config.maybeReevaluate(node, visits)
+
+		// Backpropagation. Every node keeps the whole vector so each snake is judged by its own score,
+		// rather than flipping one score back and forth which only works with two snakes. Once it's down to two
+		// the scoring mode makes the vector exactly zero sum, which is what flipping was after.
 		n := node.Parent
 		for n != nil {
 			if ctx.Err() != nil {
 				return
 			}
-			// Flip the score to represent the opponent's perspective.
-			score = -score
 
-			// Update score and visits atomically.
-			atomic.AddInt64(&n.Visits, 1)
-			atomicAddFloat64(&n.Score, score)
+			// Update scores and visits atomically.
+			n.addScores(scores)
+			visits = atomic.AddInt64(&n.Visits, 1)
+			opts.config.maybeReevaluate(n, visits)
 			n = n.Parent
 		}
 	}
 }
 
+// setMyScores stores the node's own evaluation.
+func (n *Node) setMyScores(scores []float64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.storeMyScores(scores)
+}
+
+// storeMyScores is setMyScores for callers already holding the node's mutex.
+func (n *Node) storeMyScores(scores []float64) {
+	n.MyScores = scores
+	if n.SnakeIndex >= 0 && n.SnakeIndex < len(scores) {
+		n.MyScore = scores[n.SnakeIndex]
+	}
+}
+
+// addScores adds a simulation's per snake scores to the node's sums.
+func (n *Node) addScores(scores []float64) {
+	for i := range n.Scores {
+		if i < len(scores) {
+			atomicAddFloat64(&n.Scores[i], scores[i])
+		}
+	}
+	if n.SnakeIndex >= 0 && n.SnakeIndex < len(scores) {
+		atomicAddFloat64(&n.Score, scores[n.SnakeIndex])
+	}
+}
+
 // selectNode traverses the tree, expanding nodes as needed.
-func selectNode(ctx context.Context, rootNode *Node) *Node {
+// The bonus, if any, is only applied when choosing among the root's children. Below the root the config
+// says how each snake is expected to choose. With a virtual loss every node on the way down is marked as
+// having a worker in flight, which the caller releases once it has the node's score.
+func selectNode(ctx context.Context, rootNode *Node, rootBonus func(child *Node) float64, config SearchConfig) *Node {
 	node := rootNode
 
 	for {
 		// Check for context cancellation.
 		select {
 		case <-ctx.Done():
+			config.releaseVirtualLoss(node, rootNode)
 			return nil
 		default:
 			// Continue execution.
 		}
 
-		node.mutex.Lock()
-		// If there are unexpanded moves, expand one.
-		if len(node.UnexpandedMoves) > 0 {
-			// Pop a move from UnexpandedMoves.
-			move := node.UnexpandedMoves[0]
-			node.UnexpandedMoves = node.UnexpandedMoves[1:]
-			node.mutex.Unlock()
+		// A chance node rolls for its outcome, and a new outcome is a leaf like a new move.
+		if node.chance {
+			outcome, added := node.outcome()
+			config.addVirtualLoss(outcome)
+			if added {
+				return outcome
+			}
+			node = outcome
+			continue
+		}
 
+		// If there are unexpanded moves, claim one and expand it.
+		if move, ok := node.claimMove(); ok {
 			// Create child node.
 			newBoard := copyBoard(node.Board)
 			nextSnakeIndex := (node.SnakeIndex + 1) % len(node.Board.Snakes)
 			applyMove(&newBoard, nextSnakeIndex, move)
 
-			child := NewNode(newBoard, nextSnakeIndex, node)
-
-			// Append the child to node.Children.
-			node.mutex.Lock()
-			node.Children = append(node.Children, child)
-			node.mutex.Unlock()
+			// the last snake to move finishes the turn, and whatever the end of it brings is left to a chance node
+			// unless there's only one way it can go
+			var child *Node
+			if nextSnakeIndex == len(newBoard.Snakes)-1 && hasChance(newBoard) && !isTerminal(newBoard) {
+				child = newChanceNode(newBoard, nextSnakeIndex, node)
+			} else {
+				if nextSnakeIndex == len(newBoard.Snakes)-1 && !hasChance(newBoard) {
+					rollChance(&newBoard)
+				}
+				child = NewNode(newBoard, nextSnakeIndex, node)
+			}
+			child.Prior = node.priorOf(move)
+			config.applyPolicy(child)
+			config.addVirtualLoss(child)
+			node.addChild(child)
 
 			return child
 		}
-		// No unexpanded moves.
-		node.mutex.Unlock()
 
-		// If the node is a leaf node (no children), return it.
-		node.mutex.Lock()
-		if len(node.Children) == 0 {
-			node.mutex.Unlock()
+		// If the node is a leaf node (no children), return it. A move claimed by another worker that
+		// hasn't been added yet leaves this one looking like a leaf for a moment, which just scores it again.
+		if len(node.Children()) == 0 {
 			return node
 		}
-		node.mutex.Unlock()
 
 		// Node is expanded and has children.
 		// Select the best child.
-		bestChildNode := bestChild(node, 1.41)
+		pick := bestChildWithLoss
+		if config.Policy != nil {
+			pick = bestPUCTChild
+		}
+		var bestChildNode *Node
+		if node == rootNode {
+			bestChildNode = pick(node, 1.41, rootBonus, config.VirtualLoss)
+		} else {
+			exploration, bonus := config.selection(node)
+			bestChildNode = pick(node, exploration, bonus, config.VirtualLoss)
+		}
 		if bestChildNode == nil {
 			// No valid child found.
 			return node
 		}
 
 		// Move to the best child.
+		config.addVirtualLoss(bestChildNode)
 		node = bestChildNode
 	}
 }
@@ -266,24 +574,47 @@ func atomicAddFloat64(addr *float64, delta float64) {
 }
 
 // EvaluationFunc defines the function signature for evaluation modules.
-type EvaluationFunc func(board Board, rootSnakeIndex int) float64
+// Modules may run concurrently on the same context, so they must not modify it.
+type EvaluationFunc func(ec *EvaluationContext) float64
+
+// BatchEvaluationFunc scores several contexts in one go, in the same order. Modules that cost less per
+// board when they see many at once, like a network, can offer one as well as their EvaluationFunc.
+type BatchEvaluationFunc func(ecs []*EvaluationContext) []float64
 
 // EvaluationModule defines a struct that holds an evaluation function and its corresponding weight.
+// A module with Active set only takes part on boards it says yes to, so on the rest it doesn't water
+// the others down.
 type EvaluationModule struct {
-	EvalFunc EvaluationFunc
-	Weight   float64
+	Name      string
+	EvalFunc  EvaluationFunc
+	BatchFunc BatchEvaluationFunc
+	Weight    float64
+	Active    func(ec *EvaluationContext) bool
 }
 
 var (
 	modules = []EvaluationModule{
 		{
+			Name:     "voronoi",
 			EvalFunc: voronoiEvaluation,
 			Weight:   6,
 		},
 		{
+			Name:     "length",
 			EvalFunc: lengthEvaluation,
 			Weight:   6,
 		},
+		{
+			Name:     "trapped",
+			EvalFunc: trappedEvaluation,
+			Weight:   8,
+		},
+		{
+			Name:     "forecast",
+			EvalFunc: forecastEvaluation,
+			Weight:   4,
+			Active:   inRoyale,
+		},
 	}
 )
 
@@ -293,27 +624,106 @@ func evaluateBoard(board Board, rootSnakeIndex int, modules []EvaluationModule)
 		// Invalid snake index.
 		return 0
 	}
+	return evaluateContext(newEvaluationContext(board, rootSnakeIndex), modules)
+}
+
+// evaluateScores evaluates the board from every snake's perspective, by snake index.
+// The snakes share one context so anything expensive is only worked out once.
+func evaluateScores(board Board, modules []EvaluationModule) []float64 {
+	scores := make([]float64, len(board.Snakes))
+	if len(board.Snakes) == 0 {
+		return scores
+	}
+	ec := newEvaluationContext(board, 0)
+	views := make([]*EvaluationContext, len(scores))
+	for i := range views {
+		views[i] = ec.forSnake(i)
+	}
+	if hasBatchModule(modules) {
+		return evaluateContexts(views, modules)
+	}
+	for i, view := range views {
+		scores[i] = evaluateContext(view, modules)
+	}
+	return scores
+}
 
-	rootSnake := board.Snakes[rootSnakeIndex]
+func hasBatchModule(modules []EvaluationModule) bool {
+	for _, module := range modules {
+		if module.BatchFunc != nil {
+			return true
+		}
+	}
+	return false
+}
 
-	// If the root snake is dead, return an extreme negative score.
-	if isSnakeDead(rootSnake) {
-		return -2
+// evaluateContexts is evaluateContext for several contexts at once. Modules with a BatchFunc see every
+// context they're active on in one call, the rest are run a context at a time.
+func evaluateContexts(ecs []*EvaluationContext, modules []EvaluationModule) []float64 {
+	scores := make([]float64, len(ecs))
+	totalWeights := make([]float64, len(ecs))
+	live := make([]bool, len(ecs))
+	for i, ec := range ecs {
+		switch {
+		case !ec.Alive[ec.RootIndex]:
+			scores[i] = -2
+		case ec.AliveOpponents() == 0:
+			scores[i] = 2
+		default:
+			live[i] = true
+		}
+	}
+
+	for _, module := range modules {
+		var batch []*EvaluationContext
+		var indexes []int
+		for i, ec := range ecs {
+			if live[i] && (module.Active == nil || module.Active(ec)) {
+				batch = append(batch, ec)
+				indexes = append(indexes, i)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		var moduleScores []float64
+		if module.BatchFunc != nil {
+			moduleScores = module.BatchFunc(batch)
+		} else {
+			moduleScores = make([]float64, len(batch))
+			for j, ec := range batch {
+				moduleScores[j] = module.EvalFunc(ec)
+			}
+		}
+		for j, i := range indexes {
+			scores[i] += module.Weight * moduleScores[j]
+			totalWeights[i] += module.Weight
+		}
 	}
 
-	// Check if all opponents are dead.
-	aliveOpponents := 0
-	for i, snake := range board.Snakes {
-		if i != rootSnakeIndex && !isSnakeDead(snake) {
-			aliveOpponents++
+	for i := range scores {
+		if !live[i] || totalWeights[i] == 0 {
+			continue
 		}
+		scores[i] = math.Max(-1, math.Min(1, scores[i]/totalWeights[i]))
+	}
+	return scores
+}
+
+// evaluateContext scores the context from its root snake's perspective.
+func evaluateContext(ec *EvaluationContext, modules []EvaluationModule) float64 {
+	// If the root snake is dead, return an extreme negative score.
+	if !ec.Alive[ec.RootIndex] {
+		return -2
 	}
 
 	// If all opponents are dead, return an extreme positive score.
-	if aliveOpponents == 0 {
+	if ec.AliveOpponents() == 0 {
 		return 2
 	}
 
+	modules = activeModules(ec, modules)
+
 	// Calculate the sum of all weights for normalization.
 	totalWeight := 0.0
 	for _, module := range modules {
@@ -322,9 +732,8 @@ func evaluateBoard(board Board, rootSnakeIndex int, modules []EvaluationModule)
 
 	// Accumulate weighted evaluations from each module.
 	totalScore := 0.0
-	for _, module := range modules {
-		moduleScore := module.EvalFunc(board, rootSnakeIndex)
-		weightedScore := (module.Weight / totalWeight) * moduleScore
+	for i, moduleScore := range runModules(ec, modules) {
+		weightedScore := (modules[i].Weight / totalWeight) * moduleScore
 		totalScore += weightedScore
 	}
 
@@ -338,9 +747,27 @@ func evaluateBoard(board Board, rootSnakeIndex int, modules []EvaluationModule)
 	return totalScore
 }
 
+// activeModules leaves out the modules that don't take part on the context's board.
+func activeModules(ec *EvaluationContext, modules []EvaluationModule) []EvaluationModule {
+	for i, module := range modules {
+		if module.Active == nil || module.Active(ec) {
+			continue
+		}
+		active := append([]EvaluationModule(nil), modules[:i]...)
+		for _, module := range modules[i+1:] {
+			if module.Active == nil || module.Active(ec) {
+				active = append(active, module)
+			}
+		}
+		return active
+	}
+	return modules
+}
+
 // voronoiEvaluation evaluates the board based on Voronoi control.
-func voronoiEvaluation(board Board, rootSnakeIndex int) float64 {
-	voronoi := GenerateVoronoi(board)
+func voronoiEvaluation(ec *EvaluationContext) float64 {
+	board, rootSnakeIndex := ec.Board, ec.RootIndex
+	voronoi := ec.Voronoi()
 	totalCells := float64(board.Width * board.Height)
 	rootControlledCells := 0.0
 	opponentsControlledCells := 0.0
@@ -360,17 +787,39 @@ func voronoiEvaluation(board Board, rootSnakeIndex int) float64 {
 	return (rootControlledCells - opponentsControlledCells) / totalCells
 }
 
+// trappedEvaluation penalises the root snake for being shut in with less room than its own length, which
+// it can't get out of however the opponents move. Voronoi still counts a dead end corridor as ours, so
+// without this the search happily walks into one. An opponent shut in counts the same the other way.
+// A snake that can get round to its own tail isn't shut in, since it has a loop to go round for as long as
+// it needs and following it leads back out of the pocket.
+func trappedEvaluation(ec *EvaluationContext) float64 {
+	areas, tails := ec.ReachableAreas(), ec.TailsReachable()
+	shortfall := func(i int) float64 {
+		if tails[i] {
+			return 0
+		}
+		return float64(ec.Lengths[i]-areas[i]) / float64(ec.Lengths[i])
+	}
+
+	score := -shortfall(ec.RootIndex)
+	worstOpponent := 0.0
+	for i, alive := range ec.Alive {
+		if alive && i != ec.RootIndex && shortfall(i) > worstOpponent {
+			worstOpponent = shortfall(i)
+		}
+	}
+	return score + worstOpponent
+}
+
 // lengthEvaluation evaluates the board based on the length of the root snake compared to opponents.
 // The bonus/penalty is constrained between -1 and 1, with specific scaling logic.
-func lengthEvaluation(board Board, rootSnakeIndex int) float64 {
-	rootSnake := board.Snakes[rootSnakeIndex]
-	rootLength := len(rootSnake.Body)
+func lengthEvaluation(ec *EvaluationContext) float64 {
+	rootLength := ec.Lengths[ec.RootIndex]
 	lengthBonus := 0.0
 
 	// Calculate length bonus/penalty.
-	for i, opponent := range board.Snakes {
-		if i != rootSnakeIndex && !isSnakeDead(opponent) {
-			opponentLength := len(opponent.Body)
+	for i, opponentLength := range ec.Lengths {
+		if i != ec.RootIndex && ec.Alive[i] {
 			lengthDifference := rootLength - opponentLength
 
 			if lengthDifference > 0 {
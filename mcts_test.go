@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"math"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -25,7 +27,7 @@ func TestSelectChild(t *testing.T) {
 			Parent: func() *Node {
 				parent := &Node{Visits: 10}
 				child := &Node{Visits: 1, Score: 1.0, Parent: parent}
-				parent.Children = append(parent.Children, child)
+				parent.setChildren(append(parent.Children(), child))
 				return parent
 			}(),
 			ExpectedChild: func() *Node {
@@ -38,7 +40,7 @@ func TestSelectChild(t *testing.T) {
 				parent := &Node{Visits: 20}
 				child1 := &Node{Visits: 5, Score: 3.0, Parent: parent}
 				child2 := &Node{Visits: 10, Score: 6.0, Parent: parent}
-				parent.Children = append(parent.Children, child1, child2)
+				parent.setChildren(append(parent.Children(), child1, child2))
 				return parent
 			}(),
 			ExpectedChild: func() *Node {
@@ -51,7 +53,7 @@ func TestSelectChild(t *testing.T) {
 				parent := &Node{Visits: 30}
 				child1 := &Node{Visits: 10, Score: 5.0, Parent: parent}
 				child2 := &Node{Visits: 10, Score: 5.0, Parent: parent}
-				parent.Children = append(parent.Children, child1, child2)
+				parent.setChildren(append(parent.Children(), child1, child2))
 				return parent
 			}(),
 			ExpectedChild: func() *Node {
@@ -64,7 +66,7 @@ func TestSelectChild(t *testing.T) {
 				parent := &Node{Visits: 0}
 				child1 := &Node{Visits: 5, Score: 3.0, Parent: parent}
 				child2 := &Node{Visits: 10, Score: 6.0, Parent: parent}
-				parent.Children = append(parent.Children, child1, child2)
+				parent.setChildren(append(parent.Children(), child1, child2))
 				return parent
 			}(),
 			ExpectedChild: func() *Node {
@@ -78,7 +80,7 @@ func TestSelectChild(t *testing.T) {
 				parent := &Node{Visits: 50}
 				child1 := &Node{Visits: 25, Score: 12.0, Parent: parent}
 				child2 := &Node{Visits: 0, Score: 0.0, Parent: parent}
-				parent.Children = append(parent.Children, child1, child2)
+				parent.setChildren(append(parent.Children(), child1, child2))
 				return parent
 			}(),
 			ExpectedChild: func() *Node {
@@ -89,7 +91,7 @@ func TestSelectChild(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.Description, func(t *testing.T) {
-			selectedChild := tc.Parent.Children[0]
+			selectedChild := tc.Parent.Children()[0]
 
 			if !assert.NotNil(t, selectedChild, "selected child was nil") {
 				return
@@ -363,21 +365,6 @@ func TestMCTSVisualizationJSON(t *testing.T) {
 		// 	Iterations:   math.MaxInt,
 		// },
 		// {
-		// 	Description:  "other snake's tail shouldn't trick us into trap",
-		// 	InitialBoard: `{"height":11,"width":11,"food":[{"x":1,"y":8},{"x":0,"y":8},{"x":10,"y":7},{"x":7,"y":10},{"x":8,"y":1}],"hazards":[],"snakes":[{"id":"gs_xY7RRtB98qft6dGwHtRmxtHc","name":"Gregory","health":51,"body":[{"x":2,"y":4},{"x":2,"y":5},{"x":3,"y":5},{"x":4,"y":5},{"x":5,"y":5}],"latency":"459","head":{"x":2,"y":4},"shout":"This is a nice move.","customizations":{"color":"#888888","head":"default","tail":"default"}},{"id":"gs_TMvV6VVr6TcKYDkq4f8PtKH9","name":"snakos","health":81,"body":[{"x":4,"y":2},{"x":4,"y":3},{"x":4,"y":4},{"x":5,"y":4},{"x":6,"y":4},{"x":7,"y":4},{"x":8,"y":4},{"x":9,"y":4},{"x":10,"y":4},{"x":10,"y":3},{"x":10,"y":2},{"x":10,"y":1},{"x":10,"y":0},{"x":9,"y":0},{"x":8,"y":0},{"x":7,"y":0},{"x":6,"y":0},{"x":5,"y":0},{"x":4,"y":0},{"x":3,"y":0},{"x":3,"y":1},{"x":3,"y":2}],"latency":"78","head":{"x":4,"y":2},"shout":"chasing tail","customizations":{"color":"#ff8645","head":"replit-mark","tail":"replit-notmark"}}]}`,
-		// 	Iterations:   math.MaxInt,
-		// },
-		// {
-		// 	Description:  "other snake's tail shouldn't trick us into trap",
-		// 	InitialBoard: `{"height":11,"width":11,"food":[{"x":1,"y":8},{"x":0,"y":8},{"x":10,"y":7},{"x":7,"y":10},{"x":8,"y":1}],"hazards":[],"snakes":[{"id":"gs_xY7RRtB98qft6dGwHtRmxtHc","name":"Gregory","health":51,"body":[{"x":2,"y":4},{"x":2,"y":5},{"x":3,"y":5},{"x":4,"y":5},{"x":5,"y":5}],"latency":"459","head":{"x":2,"y":4},"shout":"This is a nice move.","customizations":{"color":"#888888","head":"default","tail":"default"}},{"id":"gs_TMvV6VVr6TcKYDkq4f8PtKH9","name":"snakos","health":81,"body":[{"x":4,"y":2},{"x":4,"y":3},{"x":4,"y":4},{"x":5,"y":4},{"x":6,"y":4},{"x":7,"y":4},{"x":8,"y":4},{"x":9,"y":4},{"x":10,"y":4},{"x":10,"y":3},{"x":10,"y":2},{"x":10,"y":1},{"x":10,"y":0},{"x":9,"y":0},{"x":8,"y":0},{"x":7,"y":0},{"x":6,"y":0},{"x":5,"y":0},{"x":4,"y":0},{"x":3,"y":0},{"x":3,"y":1},{"x":3,"y":2}],"latency":"78","head":{"x":4,"y":2},"shout":"chasing tail","customizations":{"color":"#ff8645","head":"replit-mark","tail":"replit-notmark"}}]}`,
-		// 	Iterations:   math.MaxInt,
-		// },
-		// {
-		// 	Description:  "can chase tail to freedom",
-		// 	InitialBoard: `{"height":11,"width":11,"food":[{"X":10,"Y":9},{"X":9,"Y":10},{"X":0,"Y":0},{"X":10,"Y":4},{"X":0,"Y":10},{"X":0,"Y":5},{"X":0,"Y":7},{"X":1,"Y":0},{"X":6,"Y":6},{"X":3,"Y":3}],"hazards":[],"snakes":[{"id":"gs_vRg7TtfdrGy79wG4GjfwPhx6","name":"Gregory","health":96,"body":[{"X":1,"Y":4},{"X":1,"Y":3},{"X":1,"Y":2},{"X":2,"Y":2},{"X":3,"Y":2},{"X":3,"Y":1},{"X":2,"Y":1},{"X":2,"Y":0},{"X":3,"Y":0},{"X":4,"Y":0},{"X":5,"Y":0},{"X":6,"Y":0},{"X":6,"Y":1},{"X":7,"Y":1},{"X":8,"Y":1},{"X":8,"Y":2},{"X":7,"Y":2},{"X":6,"Y":2},{"X":5,"Y":2},{"X":4,"Y":2},{"X":4,"Y":3},{"X":4,"Y":4},{"X":3,"Y":4}],"latency":"457","head":{"X":1,"Y":4},"shout":"This is a nice move."},{"id":"gs_HRKWrTyp847KtHDVtTDbmy8Q","name":"soba","health":88,"body":[{"X":1,"Y":6},{"X":2,"Y":6},{"X":3,"Y":6},{"X":3,"Y":5},{"X":4,"Y":5},{"X":5,"Y":5},{"X":5,"Y":4},{"X":5,"Y":3},{"X":6,"Y":3},{"X":7,"Y":3},{"X":7,"Y":4},{"X":6,"Y":4},{"X":6,"Y":5},{"X":7,"Y":5},{"X":8,"Y":5},{"X":9,"Y":5},{"X":10,"Y":5},{"X":10,"Y":6},{"X":9,"Y":6},{"X":8,"Y":6},{"X":7,"Y":6},{"X":7,"Y":7},{"X":8,"Y":7},{"X":8,"Y":8},{"X":7,"Y":8},{"X":6,"Y":8}],"latency":"411","head":{"X":1,"Y":6},"shout":"swag"}]}`,
-		// 	Iterations:   math.MaxInt,
-		// },
-		// {
 		// 	Description:  "up leads to kill from opponent - was due to food not being cached (need a test for this eventually)",
 		// 	InitialBoard: `{"height":11,"width":11,"food":[{"X":4,"Y":10},{"X":0,"Y":1},{"X":9,"Y":2},{"X":0,"Y":0},{"X":0,"Y":3},{"X":9,"Y":10},{"X":9,"Y":5},{"X":6,"Y":0},{"X":2,"Y":0},{"X":1,"Y":10},{"X":9,"Y":4},{"X":7,"Y":10},{"X":8,"Y":1},{"X":6,"Y":6}],"hazards":[],"snakes":[{"id":"gs_P6tqpPjgJRCxPQm8yKTkd43S","name":"Gregory","health":21,"body":[{"X":6,"Y":5},{"X":5,"Y":5},{"X":5,"Y":6},{"X":4,"Y":6}],"latency":"458","head":{"X":6,"Y":5},"shout":"This is a nice move."},{"id":"gs_crwYTW6B7RkCh7YvQDmRJqhS","name":"soba","health":88,"body":[{"X":8,"Y":7},{"X":7,"Y":7},{"X":6,"Y":7},{"X":6,"Y":8},{"X":6,"Y":9},{"X":5,"Y":9},{"X":4,"Y":9},{"X":3,"Y":9}],"latency":"409","head":{"X":8,"Y":7},"shout":"swag"}]}`,
 		// 	Iterations:   math.MaxInt,
@@ -469,3 +456,170 @@ func TestMCTSVisualizationJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestMCTSStopsAtIterations(t *testing.T) {
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "snake1", Head: Point{X: 1, Y: 1}, Health: 100, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "snake2", Head: Point{X: 5, Y: 5}, Health: 100, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	node := MCTS(ctx, "testid", board, 500, 4, make(map[string]*Node))
+
+	assert.Less(t, time.Since(start), 5*time.Second, "search should stop on iterations, not the deadline")
+	// workers can each be mid iteration when the limit is hit
+	assert.GreaterOrEqual(t, node.Visits, int64(500))
+	assert.LessOrEqual(t, node.Visits, int64(500+4))
+}
+
+// TestSelectNodeConcurrentExpansion hammers one tree from many goroutines. Run with -race, every move
+// should still be expanded exactly once and every child published.
+func TestSelectNodeConcurrentExpansion(t *testing.T) {
+	const goroutines, iterations = 32, 200
+	root := NewNode(evalTestBoard(), -1, nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				node := selectNode(ctx, root, nil, defaultSearchConfig)
+				scores := make([]float64, len(node.Board.Snakes))
+				for n := node; n != nil; n = n.Parent {
+					n.addScores(scores)
+					atomic.AddInt64(&n.Visits, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(goroutines*iterations), root.Visits)
+
+	var check func(node *Node)
+	check = func(node *Node) {
+		seen := make(map[Direction]int)
+		for _, child := range node.Children() {
+			require.Same(t, node, child.Parent)
+			seen[childMove(node, child)]++
+			check(child)
+		}
+		for _, move := range node.UnexpandedMoves() {
+			seen[move]++
+		}
+		for move, count := range seen {
+			assert.Equal(t, 1, count, "%v expanded %d times", move, count)
+		}
+		assert.Len(t, seen, len(node.moves), "every move is a child or still to expand")
+	}
+	check(root)
+}
+
+func TestDetermineBestMoveFallsBackToSafeMove(t *testing.T) {
+	// cornered with only one way out and no search done
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "snake1", Head: Point{X: 0, Y: 0}, Health: 100, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}}},
+			{ID: "snake2", Head: Point{X: 5, Y: 5}, Health: 100, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+		},
+	}
+	root := NewNode(board, -1, nil)
+
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, "right", determineBestMove(root))
+	}
+}
+
+func TestEvaluateScoresMatchesEachSnake(t *testing.T) {
+	board := evalTestBoard()
+	scores := evaluateScores(board, modules)
+	for i := range board.Snakes {
+		assert.Equal(t, evaluateBoard(board, i, modules), scores[i])
+	}
+}
+
+func TestScoreVectorsInThreePlayerSearch(t *testing.T) {
+	root := MCTS(context.Background(), "test", evalTestBoard(), 2000, 1, make(map[string]*Node))
+
+	// every node should judge itself by its own snake's score, with no sign flipping between levels
+	var check func(n *Node)
+	check = func(n *Node) {
+		if n.SnakeIndex >= 0 {
+			assert.InDelta(t, n.Scores[n.SnakeIndex], n.Score, 1e-6)
+		}
+		for _, child := range n.Children() {
+			check(child)
+		}
+	}
+	check(root)
+
+	// snake 2 choosing between its moves wants what's best for snake 2, even when that's
+	// also good for snake 1 who moved before it
+	parent := &Node{SnakeIndex: 1, Visits: 2, Scores: make([]float64, 3)}
+	goodForBoth := &Node{SnakeIndex: 2, Parent: parent, Visits: 1, Scores: make([]float64, 3)}
+	badForBoth := &Node{SnakeIndex: 2, Parent: parent, Visits: 1, Scores: make([]float64, 3)}
+	goodForBoth.addScores([]float64{-1, 0.5, 0.5})
+	badForBoth.addScores([]float64{1, -0.5, -0.5})
+	parent.setChildren([]*Node{badForBoth, goodForBoth})
+	assert.Same(t, goodForBoth, bestChild(parent, 0))
+}
+
+func TestTrappedEvaluation(t *testing.T) {
+	board := pocketBoard()
+	assert.Equal(t, -1.0, trappedEvaluation(newEvaluationContext(board, 0)), "shut in with nowhere to go")
+	assert.Equal(t, 1.0, trappedEvaluation(newEvaluationContext(board, 1)), "the other way round for b")
+	assert.Zero(t, trappedEvaluation(newEvaluationContext(evalTestBoard(), 0)), "nobody is short of room")
+}
+
+func TestTrappedFollowingTail(t *testing.T) {
+	// a snake longer than the loop it's in, but following its tail it never runs out of room
+	board := Board{
+		Height: 2,
+		Width:  3,
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}, {X: 2, Y: 0}, {X: 2, Y: 0}}},
+			{ID: "b", Health: 0, Head: Point{X: 1, Y: 0}},
+		},
+	}
+	ec := newEvaluationContext(board, 0)
+	require.Less(t, ec.ReachableAreas()[0], ec.Lengths[0])
+	assert.Zero(t, trappedEvaluation(ec))
+}
+
+func TestTailPuzzles(t *testing.T) {
+	testCases := []struct {
+		Description     string
+		InitialBoard    string
+		AcceptableMoves []string
+	}{
+		{
+			// right is a dead end with one way out, and chasing their tail puts their head next to it first
+			Description:     "other snake's tail shouldn't trick us into trap",
+			InitialBoard:    `{"height":11,"width":11,"food":[{"x":1,"y":8},{"x":0,"y":8},{"x":10,"y":7},{"x":7,"y":10},{"x":8,"y":1}],"hazards":[],"snakes":[{"id":"gs_xY7RRtB98qft6dGwHtRmxtHc","name":"Gregory","health":51,"body":[{"x":2,"y":4},{"x":2,"y":5},{"x":3,"y":5},{"x":4,"y":5},{"x":5,"y":5}],"latency":"459","head":{"x":2,"y":4},"shout":"This is a nice move.","customizations":{"color":"#888888","head":"default","tail":"default"}},{"id":"gs_TMvV6VVr6TcKYDkq4f8PtKH9","name":"snakos","health":81,"body":[{"x":4,"y":2},{"x":4,"y":3},{"x":4,"y":4},{"x":5,"y":4},{"x":6,"y":4},{"x":7,"y":4},{"x":8,"y":4},{"x":9,"y":4},{"x":10,"y":4},{"x":10,"y":3},{"x":10,"y":2},{"x":10,"y":1},{"x":10,"y":0},{"x":9,"y":0},{"x":8,"y":0},{"x":7,"y":0},{"x":6,"y":0},{"x":5,"y":0},{"x":4,"y":0},{"x":3,"y":0},{"x":3,"y":1},{"x":3,"y":2}],"latency":"78","head":{"x":4,"y":2},"shout":"chasing tail","customizations":{"color":"#ff8645","head":"replit-mark","tail":"replit-notmark"}}]}`,
+			AcceptableMoves: []string{"down", "left"},
+		},
+		{
+			// up and left are towards their head, which can shut us in. following our tail right, the room keeps opening up behind us
+			Description:     "can chase tail to freedom",
+			InitialBoard:    `{"height":11,"width":11,"food":[{"X":10,"Y":9},{"X":9,"Y":10},{"X":0,"Y":0},{"X":10,"Y":4},{"X":0,"Y":10},{"X":0,"Y":5},{"X":0,"Y":7},{"X":1,"Y":0},{"X":6,"Y":6},{"X":3,"Y":3}],"hazards":[],"snakes":[{"id":"gs_vRg7TtfdrGy79wG4GjfwPhx6","name":"Gregory","health":96,"body":[{"X":1,"Y":4},{"X":1,"Y":3},{"X":1,"Y":2},{"X":2,"Y":2},{"X":3,"Y":2},{"X":3,"Y":1},{"X":2,"Y":1},{"X":2,"Y":0},{"X":3,"Y":0},{"X":4,"Y":0},{"X":5,"Y":0},{"X":6,"Y":0},{"X":6,"Y":1},{"X":7,"Y":1},{"X":8,"Y":1},{"X":8,"Y":2},{"X":7,"Y":2},{"X":6,"Y":2},{"X":5,"Y":2},{"X":4,"Y":2},{"X":4,"Y":3},{"X":4,"Y":4},{"X":3,"Y":4}],"latency":"457","head":{"X":1,"Y":4},"shout":"This is a nice move."},{"id":"gs_HRKWrTyp847KtHDVtTDbmy8Q","name":"soba","health":88,"body":[{"X":1,"Y":6},{"X":2,"Y":6},{"X":3,"Y":6},{"X":3,"Y":5},{"X":4,"Y":5},{"X":5,"Y":5},{"X":5,"Y":4},{"X":5,"Y":3},{"X":6,"Y":3},{"X":7,"Y":3},{"X":7,"Y":4},{"X":6,"Y":4},{"X":6,"Y":5},{"X":7,"Y":5},{"X":8,"Y":5},{"X":9,"Y":5},{"X":10,"Y":5},{"X":10,"Y":6},{"X":9,"Y":6},{"X":8,"Y":6},{"X":7,"Y":6},{"X":7,"Y":7},{"X":8,"Y":7},{"X":8,"Y":8},{"X":7,"Y":8},{"X":6,"Y":8}],"latency":"411","head":{"X":1,"Y":6},"shout":"swag"}]}`,
+			AcceptableMoves: []string{"right"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			var board Board
+			require.NoError(t, json.Unmarshal([]byte(tc.InitialBoard), &board))
+			root := MCTS(context.Background(), "testid", board, 5000, 1, make(map[string]*Node))
+			assert.Contains(t, tc.AcceptableMoves, determineBestMove(root))
+		})
+	}
+}
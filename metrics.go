@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The metrics are written out in prometheus's text format by hand, there being few enough of them that
+// the client library isn't worth pulling in. Cloud monitoring's managed collection scrapes the same format.
+
+// CounterVec is a prometheus counter split by one label.
+type CounterVec struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help, label string) *CounterVec {
+	return &CounterVec{name: name, help: help, label: label, values: make(map[string]float64)}
+}
+
+// Inc adds one to the count for the label's value.
+func (c *CounterVec) Inc(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[value]++
+}
+
+// Value is the count for the label's value.
+func (c *CounterVec) Value(value string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[value]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	values := make([]string, 0, len(c.values))
+	for value := range c.values {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %v\n", c.name, c.label, escapeLabel(value), c.values[value])
+	}
+}
+
+// Histogram is a prometheus histogram.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64 // upper bounds, ascending, without +Inf
+
+	mu     sync.Mutex
+	counts []uint64 // by bucket, observations no bigger than its bound and bigger than the one before
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	return &Histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe counts the value.
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += value
+	h.count++
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.counts[i]++
+	}
+}
+
+// Count is how many values have been observed.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	// prometheus buckets count everything up to their bound, not just what fell since the last one
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%v\"} %d\n", h.name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %v\n%s_count %d\n", h.name, h.count, h.name, h.sum, h.name, h.count)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+var (
+	moveDuration = newHistogram("aisnake_move_duration_seconds", "Time from a move request arriving to answering it.",
+		[]float64{0.025, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.75, 1})
+	mctsIterations = newHistogram("aisnake_mcts_iterations", "Visits the tree search added on a move.",
+		[]float64{1e3, 3e3, 1e4, 3e4, 1e5, 3e5, 1e6, 3e6})
+	moveTimeouts = newCounterVec("aisnake_move_timeouts_total", "Moves answered after the game's timeout.", "engine")
+	cacheLookups = newCounterVec("aisnake_cache_lookups_total", "Searched moves by whether last turn's tree had the board.", "result")
+	gamesEnded   = newCounterVec("aisnake_games_total", "Games ended, by how they went for us.", "outcome")
+	// discord's messages retry in its queue, everything else that goes out at the end of a game, the tidbyt
+	// push being display, retries in the pipeline. either way it's only counted once it's given up on.
+	discordFailures = newCounterVec("aisnake_discord_failures_total", "Discord messages dead-lettered, by why.", "reason")
+	stageFailures   = newCounterVec("aisnake_pipeline_failures_total", "End of game stages given up on, display being the tidbyt push.", "stage")
+	// outbound calls to discord, the tidbyt, battlesnake's sites and the bucket
+	outboundRetries  = newCounterVec("aisnake_outbound_retries_total", "Outbound calls retried, by integration.", "integration")
+	outboundFailures = newCounterVec("aisnake_outbound_failures_total", "Outbound calls that failed for good or weren't made with the circuit open, by integration.", "integration")
+	circuitOpened    = newCounterVec("aisnake_circuit_opened_total", "Times an integration's circuit breaker opened.", "integration")
+)
+
+// observeMove counts a move's latency, and whether it blew the game's timeout, by the engine that
+// picked it. Moves made without an engine are counted under none.
+func observeMove(game BattleSnakeGame, duration time.Duration, algorithm string) {
+	moveDuration.Observe(duration.Seconds())
+	if game.Game.Timeout > 0 && duration >= time.Duration(game.Game.Timeout)*time.Millisecond {
+		if algorithm == "" {
+			algorithm = "none"
+		}
+		moveTimeouts.Inc(algorithm)
+	}
+}
+
+// observeCacheLookup counts whether the searched move's board was in last turn's tree.
+func observeCacheLookup(record CacheTurn) {
+	if record.Hit {
+		cacheLookups.Inc("hit")
+		return
+	}
+	cacheLookups.Inc("miss")
+}
+
+// writeRouteMetrics writes the route counters withMetrics keeps.
+func writeRouteMetrics(w io.Writer) {
+	snapshot := routeMetricsSnapshot()
+	routes := make([]string, 0, len(snapshot))
+	for route := range snapshot {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	write := func(name, help string, value func(RouteMetrics) int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, route := range routes {
+			fmt.Fprintf(w, "%s{route=\"%s\"} %d\n", name, escapeLabel(route), value(snapshot[route]))
+		}
+	}
+	write("aisnake_http_requests_total", "Requests by route.", func(m RouteMetrics) int64 { return m.Requests })
+	write("aisnake_http_errors_total", "5xx responses by route.", func(m RouteMetrics) int64 { return m.Errors })
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	moveDuration.write(w)
+	mctsIterations.write(w)
+	moveTimeouts.write(w)
+	cacheLookups.write(w)
+	gamesEnded.write(w)
+	discordFailures.write(w)
+	stageFailures.write(w)
+	outboundRetries.write(w)
+	outboundFailures.write(w)
+	circuitOpened.write(w)
+	writeRouteMetrics(w)
+}
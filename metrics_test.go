@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramWritesCumulativeBuckets(t *testing.T) {
+	h := newHistogram("test_seconds", "A test.", []float64{0.1, 0.5, 1})
+	for _, v := range []float64{0.05, 0.1, 0.3, 2} {
+		h.Observe(v)
+	}
+	var out strings.Builder
+	h.write(&out)
+	assert.Equal(t, `# HELP test_seconds A test.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 2
+test_seconds_bucket{le="0.5"} 3
+test_seconds_bucket{le="1"} 3
+test_seconds_bucket{le="+Inf"} 4
+test_seconds_sum 2.45
+test_seconds_count 4
+`, out.String())
+}
+
+func TestCounterVecEscapesLabels(t *testing.T) {
+	c := newCounterVec("test_total", "A test.", "reason")
+	c.Inc(`said "no"`)
+	c.Inc("full")
+	c.Inc("full")
+	var out strings.Builder
+	c.write(&out)
+	assert.Equal(t, `# HELP test_total A test.
+# TYPE test_total counter
+test_total{reason="full"} 2
+test_total{reason="said \"no\""} 1
+`, out.String())
+}
+
+func TestObserveMoveCountsTimeouts(t *testing.T) {
+	game := BattleSnakeGame{Game: Game{Timeout: 500}}
+	before := moveTimeouts.Value("none")
+	observeMove(game, 100*time.Millisecond, "")
+	assert.Equal(t, before, moveTimeouts.Value("none"))
+	observeMove(game, 600*time.Millisecond, "")
+	assert.Equal(t, before+1, moveTimeouts.Value("none"))
+}
+
+func TestMetricsAfterGame(t *testing.T) {
+	saved := endOfGame
+	t.Cleanup(func() { endOfGame = saved })
+	endOfGame = newPipeline(nil)
+
+	router := newRouter("secret")
+	game := sessionTestGame("metrics")
+	post := func(path string) {
+		body, err := json.Marshal(game)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	moves, lookups, wins := mctsIterations.Count(), cacheLookups.Value("miss"), gamesEnded.Value("win")
+
+	post("/start")
+	post("/move")
+	// the only snake left standing
+	game.Board.Snakes = game.Board.Snakes[:1]
+	post("/end")
+
+	assert.Equal(t, moves+1, mctsIterations.Count())
+	assert.Equal(t, lookups+1, cacheLookups.Value("miss"), "the first turn has nothing cached")
+	assert.Equal(t, wins+1, gamesEnded.Value("win"))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE aisnake_move_duration_seconds histogram")
+	assert.Contains(t, body, `aisnake_games_total{outcome="win"}`)
+	assert.Contains(t, body, `aisnake_http_requests_total{route="/move"}`)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// middleware wraps a handler with some shared behaviour.
+type middleware func(http.Handler) http.Handler
+
+// chain applies the middlewares so the first one listed is the outermost.
+func chain(h http.Handler, middlewares ...middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// statusRecorder remembers what the handler wrote so middlewares can see it afterwards.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.written {
+		r.status = status
+		r.written = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if !r.written {
+		r.status = http.StatusOK
+		r.written = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func recordStatus(w http.ResponseWriter) *statusRecorder {
+	if recorder, ok := w.(*statusRecorder); ok {
+		return recorder
+	}
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// withLogging logs every request on the route with its status and how long it took.
+func withLogging(route string) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := recordStatus(w)
+			next.ServeHTTP(recorder, r)
+
+			level := slog.LevelDebug
+			if recorder.status >= http.StatusInternalServerError {
+				level = slog.LevelWarn
+			}
+			slog.Log(r.Context(), level, "request",
+				"route", route,
+				"method", r.Method,
+				"status", recorder.status,
+				"duration_ms", time.Since(start).Milliseconds(),
+			)
+		})
+	}
+}
+
+// RouteMetrics counts what's happened on a route since the server started.
+type RouteMetrics struct {
+	Requests   int64 `json:"requests"`
+	Errors     int64 `json:"errors"` // 5xx responses
+	DurationMs int64 `json:"duration_ms"`
+}
+
+var (
+	routeMetricsMu sync.Mutex
+	routeMetrics   = make(map[string]*RouteMetrics)
+)
+
+// metricsFor returns the counters for the route, creating them the first time.
+func metricsFor(route string) *RouteMetrics {
+	routeMetricsMu.Lock()
+	defer routeMetricsMu.Unlock()
+	metrics, ok := routeMetrics[route]
+	if !ok {
+		metrics = &RouteMetrics{}
+		routeMetrics[route] = metrics
+	}
+	return metrics
+}
+
+// routeMetricsSnapshot copies the counters for every route.
+func routeMetricsSnapshot() map[string]RouteMetrics {
+	routeMetricsMu.Lock()
+	defer routeMetricsMu.Unlock()
+	snapshot := make(map[string]RouteMetrics, len(routeMetrics))
+	for route, metrics := range routeMetrics {
+		snapshot[route] = RouteMetrics{
+			Requests:   atomic.LoadInt64(&metrics.Requests),
+			Errors:     atomic.LoadInt64(&metrics.Errors),
+			DurationMs: atomic.LoadInt64(&metrics.DurationMs),
+		}
+	}
+	return snapshot
+}
+
+// withMetrics counts requests, server errors and time spent on the route.
+func withMetrics(route string) middleware {
+	metrics := metricsFor(route)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := recordStatus(w)
+			next.ServeHTTP(recorder, r)
+
+			atomic.AddInt64(&metrics.Requests, 1)
+			atomic.AddInt64(&metrics.DurationMs, time.Since(start).Milliseconds())
+			if recorder.status >= http.StatusInternalServerError {
+				atomic.AddInt64(&metrics.Errors, 1)
+			}
+		})
+	}
+}
+
+// panicFallback answers a request whose handler panicked, given the body the client sent.
+// It's responsible for reporting the panic.
+type panicFallback func(w http.ResponseWriter, body []byte, recovered interface{})
+
+// internalErrorFallback reports the panic and returns a 500.
+func internalErrorFallback(w http.ResponseWriter, body []byte, recovered interface{}) {
+	reportPanic("", nil, recovered)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// safeMoveFallback reports the panic with the board that caused it and still answers with a move that
+// doesn't kill us outright, since a 500 on /move means the server picks one for us.
+func safeMoveFallback(w http.ResponseWriter, body []byte, recovered interface{}) {
+	var game BattleSnakeGame
+	if err := json.Unmarshal(body, &game); err != nil || len(game.Board.Snakes) == 0 {
+		reportPanic(game.Game.ID, nil, recovered)
+		writeJSON(w, map[string]string{"move": "up"})
+		return
+	}
+
+	root := NewNode(reorderSnakes(game.Board.withRules(game.Game).atTurn(game.Turn), game.You.ID), -1, nil)
+	reportPanic(game.Game.ID, root, recovered)
+	writeJSON(w, map[string]string{
+		"move":  determineBestMove(root),
+		"shout": "recovered",
+	})
+}
+
+// withRecovery stops a panicking handler from taking the server down and answers with the fallback.
+// The body is kept so the fallback can read it even if the handler already had.
+func withRecovery(fallback panicFallback) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			recorder := recordStatus(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recorder.written {
+					// too late to answer differently, just make sure we hear about it
+					reportPanic("", nil, recovered)
+					return
+				}
+				fallback(recorder, body, recovered)
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// withSharedSecret only lets requests through that carry the secret as a bearer token.
+// An empty secret leaves the route open, so local runs don't need one.
+func withSharedSecret(secret string) middleware {
+	return func(next http.Handler) http.Handler {
+		if secret == "" {
+			return next
+		}
+		expected := []byte("Bearer " + secret)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	tag := func(name string) middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}), tag("outer"), tag("inner"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, []string{"outer", "inner", "handler"}, calls)
+}
+
+func TestMoveRecoveryAnswersSafely(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// read the body like the real handler does before blowing up
+		var game BattleSnakeGame
+		json.NewDecoder(r.Body).Decode(&game)
+		panic("boom")
+	})
+	handler := chain(panicking, withMetrics("/test-move"), withRecovery(safeMoveFallback))
+
+	// cornered, only right is safe
+	body := `{"game":{"id":"g"},"turn":3,"board":{"height":7,"width":7,"snakes":[
+		{"id":"us","health":90,"head":{"x":0,"y":0},"body":[{"x":0,"y":0},{"x":0,"y":1},{"x":0,"y":2}]},
+		{"id":"them","health":90,"head":{"x":5,"y":5},"body":[{"x":5,"y":5},{"x":5,"y":6}]}]},
+		"you":{"id":"us"}}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	assert.Equal(t, "right", response["move"])
+	assert.Equal(t, int64(1), routeMetricsSnapshot()["/test-move"].Requests)
+	assert.Equal(t, int64(0), routeMetricsSnapshot()["/test-move"].Errors)
+}
+
+func TestRecoveryReturnsInternalError(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := chain(panicking, withMetrics("/test-error"), withRecovery(internalErrorFallback))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, int64(1), routeMetricsSnapshot()["/test-error"].Errors)
+}
+
+func TestSharedSecret(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	testCases := []struct {
+		Description string
+		Secret      string
+		Header      string
+		Expected    int
+	}{
+		{"no secret configured", "", "", http.StatusNoContent},
+		{"missing header", "hunter2", "", http.StatusUnauthorized},
+		{"wrong secret", "hunter2", "Bearer hunter3", http.StatusUnauthorized},
+		{"right secret", "hunter2", "Bearer hunter2", http.StatusNoContent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/cron/rank", nil)
+			if tc.Header != "" {
+				req.Header.Set("Authorization", tc.Header)
+			}
+			rec := httptest.NewRecorder()
+			withSharedSecret(tc.Secret)(ok).ServeHTTP(rec, req)
+			assert.Equal(t, tc.Expected, rec.Code)
+		})
+	}
+}
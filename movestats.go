@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MoveStats is what a move cost, so a slower search shows up in real games and not just in benchmarks. The
+// allocation and gc numbers are the whole process's while the move ran, other games included.
+type MoveStats struct {
+	GameID       string  `json:"game_id"`
+	Turn         int     `json:"turn"`
+	Nodes        int64   `json:"nodes"` // visits or nodes the search added this turn
+	NodesPerSec  float64 `json:"nodes_per_sec"`
+	Allocs       uint64  `json:"allocs"`
+	AllocBytes   uint64  `json:"alloc_bytes"`
+	GCs          uint32  `json:"gcs"`
+	GCPauseMs    float64 `json:"gc_pause_ms"`
+	TreeSize     int     `json:"tree_size"`      // nodes in the tree the move came from, 0 for engines without one
+	CacheHitRate float64 `json:"cache_hit_rate"` // the game's warm start hit rate so far
+}
+
+// moveMeter is where the process was when a move started, to measure the move against.
+type moveMeter struct {
+	start time.Time
+	mem   runtime.MemStats
+}
+
+// startMoveMeter reads the process's counters. It stops the world for a moment, so it's once a move at most.
+func startMoveMeter() moveMeter {
+	meter := moveMeter{start: time.Now()}
+	runtime.ReadMemStats(&meter.mem)
+	return meter
+}
+
+// Stats measures the move so far.
+func (m moveMeter) Stats(nodes int64, treeSize int, cacheHitRate float64) MoveStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := MoveStats{
+		Nodes:        nodes,
+		Allocs:       mem.Mallocs - m.mem.Mallocs,
+		AllocBytes:   mem.TotalAlloc - m.mem.TotalAlloc,
+		GCs:          mem.NumGC - m.mem.NumGC,
+		GCPauseMs:    float64(mem.PauseTotalNs-m.mem.PauseTotalNs) / float64(time.Millisecond),
+		TreeSize:     treeSize,
+		CacheHitRate: cacheHitRate,
+	}
+	if elapsed := time.Since(m.start); elapsed > 0 {
+		stats.NodesPerSec = float64(nodes) / elapsed.Seconds()
+	}
+	return stats
+}
+
+// moveStatsKept is how many of the latest moves /debug/stats shows.
+const moveStatsKept = 200
+
+// MoveStatsLog keeps the latest moves' stats across every game.
+type MoveStatsLog struct {
+	mu    sync.Mutex
+	moves []MoveStats
+	limit int
+}
+
+func newMoveStatsLog(limit int) *MoveStatsLog {
+	return &MoveStatsLog{limit: limit}
+}
+
+var recentMoveStats = newMoveStatsLog(moveStatsKept)
+
+// Add records the move, forgetting the oldest once there's more than the limit.
+func (l *MoveStatsLog) Add(stats MoveStats) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.moves = append(l.moves, stats)
+	if len(l.moves) > l.limit {
+		l.moves = append(l.moves[:0], l.moves[len(l.moves)-l.limit:]...)
+	}
+}
+
+// recordMoveStats keeps the move's stats for /debug/stats.
+func recordMoveStats(game BattleSnakeGame, stats MoveStats) {
+	stats.GameID, stats.Turn = game.Game.ID, game.Turn
+	recentMoveStats.Add(stats)
+}
+
+// MoveStatsReport is the latest moves and their averages.
+type MoveStatsReport struct {
+	Moves          int         `json:"moves"`
+	AvgNodesPerSec float64     `json:"avg_nodes_per_sec"`
+	AvgAllocs      float64     `json:"avg_allocs"`
+	AvgAllocBytes  float64     `json:"avg_alloc_bytes"`
+	AvgGCPauseMs   float64     `json:"avg_gc_pause_ms"`
+	AvgTreeSize    float64     `json:"avg_tree_size"`
+	Recent         []MoveStats `json:"recent"` // oldest first
+}
+
+// Report averages the moves kept. Moves that didn't search, like forced ones, would drag the rate down
+// without saying anything about the search, so the node rate only counts moves with nodes.
+func (l *MoveStatsLog) Report() MoveStatsReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report := MoveStatsReport{Moves: len(l.moves), Recent: append([]MoveStats(nil), l.moves...)}
+	if len(l.moves) == 0 {
+		return report
+	}
+	searched := 0
+	for _, move := range l.moves {
+		if move.Nodes > 0 {
+			searched++
+			report.AvgNodesPerSec += move.NodesPerSec
+		}
+		report.AvgAllocs += float64(move.Allocs)
+		report.AvgAllocBytes += float64(move.AllocBytes)
+		report.AvgGCPauseMs += move.GCPauseMs
+		report.AvgTreeSize += float64(move.TreeSize)
+	}
+	if searched > 0 {
+		report.AvgNodesPerSec /= float64(searched)
+	}
+	n := float64(len(l.moves))
+	report.AvgAllocs /= n
+	report.AvgAllocBytes /= n
+	report.AvgGCPauseMs /= n
+	report.AvgTreeSize /= n
+	return report
+}
+
+func handleDebugStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, recentMoveStats.Report())
+}
+
+// pprofRoutes are the standard pprof handlers by path. The index serves the named profiles, heap,
+// goroutine, allocs and the rest, from under its path.
+var pprofRoutes = map[string]http.HandlerFunc{
+	"/debug/pprof/":        pprof.Index,
+	"/debug/pprof/cmdline": pprof.Cmdline,
+	"/debug/pprof/profile": pprof.Profile,
+	"/debug/pprof/symbol":  pprof.Symbol,
+	"/debug/pprof/trace":   pprof.Trace,
+}
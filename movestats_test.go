@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveStatsLogKeepsLatest(t *testing.T) {
+	log := newMoveStatsLog(3)
+	assert.Equal(t, MoveStatsReport{}, log.Report())
+
+	for turn := 0; turn < 5; turn++ {
+		log.Add(MoveStats{Turn: turn, Nodes: int64(turn), NodesPerSec: float64(1000 * turn), Allocs: 10, TreeSize: 4 * turn})
+	}
+	report := log.Report()
+	require.Len(t, report.Recent, 3)
+	assert.Equal(t, []int{2, 3, 4}, []int{report.Recent[0].Turn, report.Recent[1].Turn, report.Recent[2].Turn})
+	assert.Equal(t, 3, report.Moves)
+	assert.InDelta(t, 3000, report.AvgNodesPerSec, 1e-9)
+	assert.InDelta(t, 10, report.AvgAllocs, 1e-9)
+	assert.InDelta(t, 12, report.AvgTreeSize, 1e-9)
+
+	// a forced move has nothing to say about how fast the search is
+	log.Add(MoveStats{Turn: 5})
+	assert.InDelta(t, 3500, log.Report().AvgNodesPerSec, 1e-9)
+}
+
+func TestMoveStatsMeasuresAllocations(t *testing.T) {
+	meter := startMoveMeter()
+	var kept [][]byte
+	for i := 0; i < 100; i++ {
+		kept = append(kept, make([]byte, 1024))
+	}
+	stats := meter.Stats(500, 7, 0.5)
+	assert.Len(t, kept, 100)
+	assert.GreaterOrEqual(t, stats.Allocs, uint64(100))
+	assert.GreaterOrEqual(t, stats.AllocBytes, uint64(100*1024))
+	assert.Positive(t, stats.NodesPerSec)
+	assert.Equal(t, 7, stats.TreeSize)
+	assert.Equal(t, 0.5, stats.CacheHitRate)
+}
+
+func TestDebugStatsAfterMove(t *testing.T) {
+	saved := recentMoveStats
+	recentMoveStats = newMoveStatsLog(moveStatsKept)
+	t.Cleanup(func() { recentMoveStats = saved })
+
+	router := newRouter("secret")
+	game := sessionTestGame("movestats")
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, rec.Code)
+	productionSnake.endSession(game)
+
+	get := func(path string, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, get("/debug/stats", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/debug/pprof/", "").Code)
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/", "secret").Code)
+
+	rec = get("/debug/stats", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report MoveStatsReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Len(t, report.Recent, 1)
+	move := report.Recent[0]
+	assert.Equal(t, "movestats", move.GameID)
+	assert.Positive(t, move.Nodes)
+	assert.Positive(t, move.NodesPerSec)
+	assert.Positive(t, move.TreeSize)
+	assert.Positive(t, move.Allocs)
+}
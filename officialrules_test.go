@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/brensch/aisnake/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+// officialRulesCases is how many random turns each differential test plays.
+const officialRulesCases = 5000
+
+var officialMoves = map[Direction]string{
+	Up:    rules.MoveUp,
+	Down:  rules.MoveDown,
+	Left:  rules.MoveLeft,
+	Right: rules.MoveRight,
+}
+
+// randomRulesBoard makes a board with up to the given number of snakes on it. The snakes are random walks
+// that stay clear of each other, and are stacked at the tail if they get boxed in, the way they are at
+// the start of a game. Health runs low often enough to starve, and there's food and stacked hazard about.
+func randomRulesBoard(rng *rand.Rand, maxSnakes int) Board {
+	board := Board{
+		Width:   3 + rng.Intn(9),
+		Height:  3 + rng.Intn(9),
+		Wrapped: rng.Intn(4) == 0,
+	}
+	if rng.Intn(2) == 0 {
+		board.HazardDamage = 1 + rng.Intn(20)
+	}
+
+	occupied := make(map[Point]bool)
+	free := func() (Point, bool) {
+		for tries := 0; tries < 50; tries++ {
+			p := Point{X: rng.Intn(board.Width), Y: rng.Intn(board.Height)}
+			if !occupied[p] {
+				return p, true
+			}
+		}
+		return Point{}, false
+	}
+
+	snakes := 1 + rng.Intn(maxSnakes)
+	for i := 0; i < snakes; i++ {
+		head, ok := free()
+		if !ok {
+			break
+		}
+		occupied[head] = true
+		body := []Point{head}
+		for length := 2 + rng.Intn(8); len(body) < length; {
+			tail := body[len(body)-1]
+			var next []Point
+			for _, direction := range AllDirections {
+				p := moveOnBoard(&board, tail, direction)
+				if isPointInsideBoard(&board, p) && !occupied[p] {
+					next = append(next, p)
+				}
+			}
+			if len(next) == 0 {
+				body = append(body, tail)
+				continue
+			}
+			p := next[rng.Intn(len(next))]
+			occupied[p] = true
+			body = append(body, p)
+		}
+		health := 1 + rng.Intn(100)
+		if rng.Intn(3) == 0 {
+			health = 1 + rng.Intn(3)
+		}
+		board.Snakes = append(board.Snakes, Snake{ID: fmt.Sprintf("snake%d", i), Health: health, Head: head, Body: body})
+	}
+
+	for n := rng.Intn(6); n > 0; n-- {
+		if p, ok := free(); ok {
+			occupied[p] = true
+			board.Food = append(board.Food, p)
+		}
+	}
+	for n := rng.Intn(12); n > 0; n-- {
+		board.Hazards = append(board.Hazards, Point{X: rng.Intn(board.Width), Y: rng.Intn(board.Height)})
+	}
+	return board
+}
+
+func toRulesBoard(board Board) (rules.BoardState, rules.Settings) {
+	points := func(points []Point) []rules.Point {
+		converted := make([]rules.Point, len(points))
+		for i, p := range points {
+			converted[i] = rules.Point{X: p.X, Y: p.Y}
+		}
+		return converted
+	}
+	state := rules.BoardState{
+		Width:   board.Width,
+		Height:  board.Height,
+		Food:    points(board.Food),
+		Hazards: points(board.Hazards),
+	}
+	for _, snake := range board.Snakes {
+		state.Snakes = append(state.Snakes, rules.Snake{ID: snake.ID, Body: points(snake.Body), Health: snake.Health})
+	}
+	return state, rules.Settings{HazardDamagePerTurn: board.HazardDamage, Wrapped: board.Wrapped}
+}
+
+// assertMatchesOfficialRules checks our board came out of the turn the way the official one did: the same
+// snakes alive, with the same bodies and health, and the same food left.
+func assertMatchesOfficialRules(t *testing.T, official rules.BoardState, board Board, description string) {
+	t.Helper()
+	for i, snake := range official.Snakes {
+		alive := snake.EliminatedCause == rules.NotEliminated
+		if !assert.Equal(t, alive, !isSnakeDead(board.Snakes[i]), "%s: %s alive (official cause %q)", description, snake.ID, snake.EliminatedCause) || !alive {
+			continue
+		}
+		body := make([]rules.Point, len(board.Snakes[i].Body))
+		for j, p := range board.Snakes[i].Body {
+			body[j] = rules.Point{X: p.X, Y: p.Y}
+		}
+		assert.Equal(t, snake.Body, body, "%s: %s body", description, snake.ID)
+		assert.Equal(t, snake.Health, board.Snakes[i].Health, "%s: %s health", description, snake.ID)
+		assert.Equal(t, body[0], rules.Point{X: board.Snakes[i].Head.X, Y: board.Snakes[i].Head.Y}, "%s: %s head", description, snake.ID)
+	}
+	food := make([]rules.Point, len(board.Food))
+	for i, p := range board.Food {
+		food[i] = rules.Point{X: p.X, Y: p.Y}
+	}
+	assert.ElementsMatch(t, official.Food, food, "%s: food", description)
+}
+
+func TestApplyMovesMatchesOfficialRules(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for c := 0; c < officialRulesCases; c++ {
+		board := randomRulesBoard(rng, 4)
+		state, settings := toRulesBoard(board)
+		moves := make([]Direction, len(board.Snakes))
+		byID := make(map[string]string, len(board.Snakes))
+		for i, snake := range board.Snakes {
+			moves[i] = AllDirections[rng.Intn(len(AllDirections))]
+			byID[snake.ID] = officialMoves[moves[i]]
+		}
+		description := fmt.Sprintf("case %d: %s moving %v", c, visualizeBoard(board), moves)
+
+		official := rules.Step(state, settings, byID)
+		applyMoves(&board, moves)
+		assertMatchesOfficialRules(t, official, board, description)
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+// applyMove plays one snake at a time, so it only has to agree with the official rules when there's
+// nobody else on the board. With more snakes the later ones see the earlier ones' moves, on purpose.
+func TestApplyMoveMatchesOfficialRulesAlone(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for c := 0; c < officialRulesCases; c++ {
+		board := randomRulesBoard(rng, 1)
+		state, settings := toRulesBoard(board)
+		move := AllDirections[rng.Intn(len(AllDirections))]
+		description := fmt.Sprintf("case %d: %s moving %v", c, visualizeBoard(board), move)
+
+		official := rules.Step(state, settings, map[string]string{board.Snakes[0].ID: officialMoves[move]})
+		applyMove(&board, 0, move)
+		assertMatchesOfficialRules(t, official, board, description)
+		if t.Failed() {
+			return
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// OpponentStyle is what an opponent's reply times say about how it picks moves.
+type OpponentStyle string
+
+const (
+	// StyleUnknown hasn't shown enough turns, or sits in between, so it's searched like us.
+	StyleUnknown OpponentStyle = "unknown"
+	// StyleDeep uses most of the timeout, so it's probably searching and will find its best reply.
+	StyleDeep OpponentStyle = "deep"
+	// StyleFast answers straight away, so it's probably a heuristic bot that goes for whatever looks good now.
+	StyleFast OpponentStyle = "fast"
+)
+
+const (
+	// opponentMinTurns is how many replies an opponent needs before we judge its style
+	opponentMinTurns = 5
+	// opponentWindow is how many of its latest replies the style is judged on
+	opponentWindow = 20
+	// opponentDeepShare is the share of the timeout a typical reply has to use to count as searching
+	opponentDeepShare = 0.6
+	// opponentFastShare is the share of the timeout under which a typical reply counts as not searching
+	opponentFastShare = 0.15
+
+	// opponentDeepExploration is how much the search explores a deep opponent's replies. less than our own
+	// so the search settles on their best reply sooner, which is what they'll play.
+	opponentDeepExploration = 0.7
+	// opponentGreed is the selection bonus for a fast opponent's reply that heads for food
+	opponentGreed = 0.3
+)
+
+// OpponentModel is how the search expects a snake to pick its moves. The zero value is played like us.
+type OpponentModel struct {
+	Style       OpponentStyle
+	Exploration float64 // 0 uses the usual exploration
+	Greed       float64 // bonus for replies that close on food
+}
+
+// OpponentProfile is how this turn's search should treat the opponents.
+type OpponentProfile struct {
+	Models       []OpponentModel // by snake index, us included so the indexes line up
+	DangerMargin float64         // value we'll give up for a line with fewer dangerous replies
+}
+
+// Styles lists each snake's style by index.
+func (p OpponentProfile) Styles() []OpponentStyle {
+	styles := make([]OpponentStyle, len(p.Models))
+	for i, model := range p.Models {
+		styles[i] = model.Style
+	}
+	return styles
+}
+
+// OpponentTracker keeps each opponent's reply times for a game.
+type OpponentTracker struct {
+	mu        sync.Mutex
+	latencies map[string][]int // ms by snake id, latest last
+}
+
+// Observe records the latency the engine reported for every opponent's last reply.
+func (t *OpponentTracker) Observe(board Board) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.latencies == nil {
+		t.latencies = make(map[string][]int)
+	}
+	for i, snake := range board.Snakes {
+		if i == 0 || isSnakeDead(snake) {
+			continue
+		}
+		// nothing's reported before the first move
+		ms, err := strconv.Atoi(snake.Latency)
+		if err != nil || ms <= 0 {
+			continue
+		}
+		latencies := append(t.latencies[snake.ID], ms)
+		if len(latencies) > opponentWindow {
+			latencies = latencies[len(latencies)-opponentWindow:]
+		}
+		t.latencies[snake.ID] = latencies
+	}
+}
+
+// Style judges the opponent on its typical reply time against the game's timeout.
+func (t *OpponentTracker) Style(id string, timeoutMs int) OpponentStyle {
+	t.mu.Lock()
+	latencies := append([]int(nil), t.latencies[id]...)
+	t.mu.Unlock()
+	if len(latencies) < opponentMinTurns || timeoutMs <= 0 {
+		return StyleUnknown
+	}
+
+	// the median so the odd slow network round trip doesn't make a bot look like it's thinking
+	sort.Ints(latencies)
+	share := float64(latencies[len(latencies)/2]) / float64(timeoutMs)
+	switch {
+	case share >= opponentDeepShare:
+		return StyleDeep
+	case share <= opponentFastShare:
+		return StyleFast
+	}
+	return StyleUnknown
+}
+
+// Profile works out how to search against the opponents on the board. Deep opponents are assumed to find
+// their best replies and we give up more value to stay out of their reach; fast ones are expected to go for
+// food and we don't pass up value avoiding kills they won't look for.
+func (t *OpponentTracker) Profile(board Board, timeoutMs int) OpponentProfile {
+	profile := OpponentProfile{Models: make([]OpponentModel, len(board.Snakes))}
+	deep, fast, alive := 0, 0, 0
+	for i, snake := range board.Snakes {
+		profile.Models[i].Style = StyleUnknown
+		if i == 0 || isSnakeDead(snake) {
+			continue
+		}
+		alive++
+		style := t.Style(snake.ID, timeoutMs)
+		profile.Models[i].Style = style
+		switch style {
+		case StyleDeep:
+			deep++
+			profile.Models[i].Exploration = opponentDeepExploration
+		case StyleFast:
+			fast++
+			profile.Models[i].Greed = opponentGreed
+		}
+	}
+
+	profile.DangerMargin = dangerValueMargin
+	switch {
+	case alive > 0 && fast == alive:
+		profile.DangerMargin = 0
+	case deep > 0:
+		profile.DangerMargin = 2 * dangerValueMargin
+	}
+	return profile
+}
+
+// nearestFoodDistance is how far the point is from the closest food as the crow flies, or -1 with no food.
+func nearestFoodDistance(board *Board, p Point) int {
+	nearest := -1
+	for _, food := range board.Food {
+		if d := manhattanDistance(p, food); nearest < 0 || d < nearest {
+			nearest = d
+		}
+	}
+	return nearest
+}
+
+// selection is how the search picks between the node's children: the exploration to use and a bonus for
+// children the snake moving is expected to favour.
+func (c SearchConfig) selection(node *Node) (float64, func(child *Node) float64) {
+	const exploration = 1.41
+	if len(c.Opponents) == 0 || len(node.Board.Snakes) == 0 {
+		return exploration, nil
+	}
+	mover := (node.SnakeIndex + 1) % len(node.Board.Snakes)
+	if mover >= len(c.Opponents) {
+		return exploration, nil
+	}
+	model := c.Opponents[mover]
+
+	explore := exploration
+	if model.Exploration > 0 {
+		explore = model.Exploration
+	}
+	if model.Greed == 0 {
+		return explore, nil
+	}
+	before := nearestFoodDistance(&node.Board, node.Board.Snakes[mover].Head)
+	if before < 0 {
+		return explore, nil
+	}
+	return explore, func(child *Node) float64 {
+		after := nearestFoodDistance(&child.Board, child.Board.Snakes[mover].Head)
+		// eating it takes it off the board, so the nearest food is suddenly further away
+		if after < before || len(child.Board.Food) < len(node.Board.Food) {
+			return model.Greed
+		}
+		return 0
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latencyBoard has every opponent's last reply taking the given ms.
+func latencyBoard(ms string) Board {
+	board := evalTestBoard()
+	for i := 1; i < len(board.Snakes); i++ {
+		board.Snakes[i].Latency = ms
+	}
+	return board
+}
+
+func TestOpponentStyle(t *testing.T) {
+	repeat := func(ms string, n int) []string {
+		var out []string
+		for i := 0; i < n; i++ {
+			out = append(out, ms)
+		}
+		return out
+	}
+
+	testCases := []struct {
+		Description string
+		Latencies   []string
+		Want        OpponentStyle
+	}{
+		{Description: "searches to the timeout", Latencies: repeat("420", 10), Want: StyleDeep},
+		{Description: "answers straight away", Latencies: repeat("12", 10), Want: StyleFast},
+		{Description: "in between", Latencies: repeat("200", 10), Want: StyleUnknown},
+		{Description: "not enough turns", Latencies: repeat("12", opponentMinTurns-1), Want: StyleUnknown},
+		{Description: "missing latencies don't count", Latencies: append(repeat("0", 10), repeat("", 10)...), Want: StyleUnknown},
+		{Description: "the odd slow round trip", Latencies: append(repeat("12", 8), "450", "480"), Want: StyleFast},
+		{Description: "judged on recent turns", Latencies: append(repeat("12", 30), repeat("420", opponentWindow)...), Want: StyleDeep},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			tracker := &OpponentTracker{}
+			for _, ms := range tc.Latencies {
+				tracker.Observe(latencyBoard(ms))
+			}
+			assert.Equal(t, tc.Want, tracker.Style(evalTestBoard().Snakes[1].ID, 500))
+		})
+	}
+}
+
+func TestOpponentProfile(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Ms          string
+		WantStyle   OpponentStyle
+		WantMargin  float64
+	}{
+		{Description: "deep", Ms: "420", WantStyle: StyleDeep, WantMargin: 2 * dangerValueMargin},
+		{Description: "fast", Ms: "12", WantStyle: StyleFast, WantMargin: 0},
+		{Description: "unknown", Ms: "200", WantStyle: StyleUnknown, WantMargin: dangerValueMargin},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			tracker := &OpponentTracker{}
+			for i := 0; i < opponentMinTurns; i++ {
+				tracker.Observe(latencyBoard(tc.Ms))
+			}
+			profile := tracker.Profile(evalTestBoard(), 500)
+			require.Len(t, profile.Models, 3)
+			assert.Equal(t, []OpponentStyle{StyleUnknown, tc.WantStyle, tc.WantStyle}, profile.Styles(), "we're never judged")
+			assert.Equal(t, tc.WantMargin, profile.DangerMargin)
+		})
+	}
+
+	// only worth being bold when every opponent is a fast bot
+	tracker := &OpponentTracker{}
+	for i := 0; i < opponentMinTurns; i++ {
+		board := latencyBoard("12")
+		board.Snakes[2].Latency = "200"
+		tracker.Observe(board)
+	}
+	assert.Equal(t, dangerValueMargin, tracker.Profile(evalTestBoard(), 500).DangerMargin)
+}
+
+func TestSelectionFollowsOpponentModel(t *testing.T) {
+	// after our move it's the opponent's turn at this node
+	board := evalTestBoard()
+	board.Food = []Point{{X: board.Snakes[1].Head.X + 2, Y: board.Snakes[1].Head.Y}}
+	node := NewNode(board, 0, nil)
+
+	exploration, bonus := SearchConfig{}.selection(node)
+	assert.Equal(t, 1.41, exploration)
+	assert.Nil(t, bonus, "searched like us without a model")
+
+	deep := SearchConfig{Opponents: []OpponentModel{{}, {Style: StyleDeep, Exploration: opponentDeepExploration}}}
+	exploration, bonus = deep.selection(node)
+	assert.Equal(t, opponentDeepExploration, exploration)
+	assert.Nil(t, bonus)
+
+	fast := SearchConfig{Opponents: []OpponentModel{{}, {Style: StyleFast, Greed: opponentGreed}}}
+	exploration, bonus = fast.selection(node)
+	assert.Equal(t, 1.41, exploration)
+	require.NotNil(t, bonus)
+	for _, move := range node.UnexpandedMoves() {
+		child := copyBoard(board)
+		applyMove(&child, 1, move)
+		want := 0.0
+		if move == Right {
+			want = opponentGreed
+		}
+		assert.Equal(t, want, bonus(&Node{Board: child}), "%v", move)
+	}
+
+	// our own moves are never modelled
+	_, bonus = fast.selection(NewNode(board, -1, nil))
+	assert.Nil(t, bonus)
+}
+
+func TestGreedyOpponentRepliesSearchedMore(t *testing.T) {
+	board := evalTestBoard()
+	board.Food = []Point{{X: board.Snakes[1].Head.X + 2, Y: board.Snakes[1].Head.Y}}
+
+	foodShare := func(config SearchConfig) float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		root := MCTS(ctx, "greedy", board, 3000, 1, make(map[string]*Node), WithSearchConfig(config))
+		var toFood, total int64
+		for _, ours := range root.Children() {
+			for _, theirs := range ours.Children() {
+				total += theirs.Visits
+				if theirs.Board.Snakes[1].Head.X > board.Snakes[1].Head.X {
+					toFood += theirs.Visits
+				}
+			}
+		}
+		require.Positive(t, total)
+		return float64(toFood) / float64(total)
+	}
+
+	plain := foodShare(SearchConfig{})
+	greedy := foodShare(SearchConfig{Opponents: []OpponentModel{{}, {Style: StyleFast, Greed: opponentGreed}}})
+	assert.Greater(t, greedy, plain, "expected a fast bot to go for the food: "+strconv.FormatFloat(plain, 'f', 2, 64))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// outboundBackoff is the wait before an outbound call's first retry, doubling after each failure up to
+	// outboundMaxBackoff. The actual wait is jittered between half and all of it so retries don't line up.
+	outboundBackoff    = 500 * time.Millisecond
+	outboundMaxBackoff = 10 * time.Second
+	// breakerThreshold is how many calls in a row can fail before an integration's circuit opens, and
+	// breakerCooldown how long it stays open before a call's let through to try it again
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+// Circuit states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// errCircuitOpen means the integration's been failing, so the call wasn't made at all.
+var errCircuitOpen = errors.New("circuit open")
+
+// CircuitBreaker stops calling an integration that keeps failing, so a dead service costs a quick error
+// rather than every caller waiting out its timeouts and retries. Once the cooldown's up a single call is let
+// through; if it works the circuit closes again.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int // in a row
+	openedAt time.Time
+	trying   bool // a call is testing the integration while half open
+}
+
+func newCircuitBreaker(name string) *CircuitBreaker {
+	return &CircuitBreaker{name: name, threshold: breakerThreshold, cooldown: breakerCooldown, now: time.Now}
+}
+
+// State is closed, open or half open, the last once the cooldown's up.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state()
+}
+
+func (b *CircuitBreaker) state() string {
+	switch {
+	case b.failures < b.threshold:
+		return CircuitClosed
+	case b.now().Sub(b.openedAt) < b.cooldown:
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+// Allow says whether a call can go ahead. Every call it allows has to be followed by Record.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state() {
+	case CircuitOpen:
+		return fmt.Errorf("%s: %w", b.name, errCircuitOpen)
+	case CircuitHalfOpen:
+		if b.trying {
+			return fmt.Errorf("%s: %w", b.name, errCircuitOpen)
+		}
+		b.trying = true
+	}
+	return nil
+}
+
+// Record counts how the call went.
+func (b *CircuitBreaker) Record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trying = false
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			circuitOpened.Inc(b.name)
+			slog.Warn("circuit opened", "integration", b.name, "cooldown", b.cooldown.String())
+		}
+		b.openedAt = b.now()
+	}
+}
+
+// OutboundClient is how we call anything outside, discord, the tidbyt and battlesnake's sites. Each
+// attempt gets a timeout, failures are retried with jittered backoff as long as the budget allows, and
+// an integration that keeps failing is cut off by its circuit breaker.
+type OutboundClient struct {
+	name       string
+	client     *http.Client
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	budget     time.Duration // the most a call gets, retries and all
+	breaker    *CircuitBreaker
+}
+
+// newOutboundClient makes a client for the integration that gives each attempt the timeout and tries up to
+// attempts times.
+func newOutboundClient(name string, timeout time.Duration, attempts int) *OutboundClient {
+	return &OutboundClient{
+		name:       name,
+		client:     &http.Client{Timeout: timeout},
+		attempts:   attempts,
+		backoff:    outboundBackoff,
+		maxBackoff: outboundMaxBackoff,
+		budget:     time.Duration(attempts)*timeout + outboundMaxBackoff,
+		breaker:    newCircuitBreaker(name),
+	}
+}
+
+var (
+	// discord's queue does its own retries and knows about its rate limits, so its client only tries once
+	discordClient  = newOutboundClient("discord", 10*time.Second, 1)
+	tidbytClient   = newOutboundClient("tidbyt", 15*time.Second, 3)
+	exporterClient = newOutboundClient("exporter", 30*time.Second, 3)
+	profileClient  = newOutboundClient("profile", 10*time.Second, 3)
+	// gcsBreaker guards the bucket, whose client does its own retries
+	gcsBreaker = newCircuitBreaker("gcs")
+)
+
+// retryable is whether the attempt's worth trying again: it didn't get an answer, the server broke or
+// we're being rate limited.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// healthy is whether the attempt says the integration's up, for the circuit breaker. A 4xx is our problem, not theirs.
+func healthy(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode < 500
+}
+
+// Do sends the request, retrying it when that might help. A response is returned once one isn't worth
+// retrying or the attempts run out, so callers check the status as usual. Requests with a body have to be
+// replayable, which they are when made by http.NewRequest from a bytes.Buffer or Reader.
+func (c *OutboundClient) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.Allow(); err != nil {
+			outboundFailures.Inc(c.name)
+			return nil, err
+		}
+		resp, err = c.client.Do(attemptRequest(req, attempt))
+		// the caller giving up isn't the integration's fault
+		c.breaker.Record(healthy(resp, err) || req.Context().Err() != nil)
+		if !retryable(resp, err) || req.Context().Err() != nil {
+			break
+		}
+
+		wait := c.wait(attempt, resp)
+		if attempt >= c.attempts || time.Since(start)+wait > c.budget {
+			break
+		}
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		outboundRetries.Inc(c.name)
+		slog.Warn("outbound call failed, retrying", "integration", c.name, "reason", reason, "attempt", attempt, "wait_ms", wait.Milliseconds())
+
+		select {
+		case <-req.Context().Done():
+			outboundFailures.Inc(c.name)
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+
+	if !healthy(resp, err) {
+		outboundFailures.Inc(c.name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return resp, nil
+}
+
+// attemptRequest is the request for the attempt, with a fresh body after the first.
+func attemptRequest(req *http.Request, attempt int) *http.Request {
+	if attempt == 1 || req.Body == nil || req.GetBody == nil {
+		return req
+	}
+	retry := req.Clone(req.Context())
+	body, err := req.GetBody()
+	if err == nil {
+		retry.Body = body
+	}
+	return retry
+}
+
+// wait is how long to wait before the next attempt: what the server asked for if it did, otherwise the
+// backoff with jitter.
+func (c *OutboundClient) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil && seconds >= 0 {
+			if wait := time.Duration(seconds * float64(time.Second)); wait < c.maxBackoff {
+				return wait
+			}
+			return c.maxBackoff
+		}
+	}
+	backoff := c.backoff << (attempt - 1)
+	if backoff > c.maxBackoff || backoff <= 0 {
+		backoff = c.maxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// guardBucket runs the call to the bucket through its circuit breaker. Missing objects are a fine answer,
+// and so is a write losing a race to another.
+func guardBucket(ctx context.Context, call func(ctx context.Context) error) error {
+	if err := gcsBreaker.Allow(); err != nil {
+		outboundFailures.Inc("gcs")
+		return err
+	}
+	err := call(ctx)
+	ok := err == nil || errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, errGameStatsConflict)
+	gcsBreaker.Record(ok)
+	if !ok {
+		outboundFailures.Inc("gcs")
+	}
+	return err
+}
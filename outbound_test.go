@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer answers with the statuses in turn, then 200 forever, and keeps the bodies it was sent.
+func flakyServer(t *testing.T, statuses ...int) (*httptest.Server, *int32, *[]string) {
+	t.Helper()
+	calls := new(int32)
+	bodies := new([]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(atomic.AddInt32(calls, 1))
+		body, _ := io.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+		if call <= len(statuses) {
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls, bodies
+}
+
+func testOutboundClient(name string, attempts int) *OutboundClient {
+	client := newOutboundClient(name, time.Second, attempts)
+	client.backoff = time.Millisecond
+	return client
+}
+
+func TestOutboundClientRetries(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Statuses    []int
+		Attempts    int
+		Calls       int32
+		Status      int
+	}{
+		{"succeeds first time", nil, 3, 1, http.StatusOK},
+		{"retries server errors", []int{http.StatusBadGateway, http.StatusServiceUnavailable}, 3, 3, http.StatusOK},
+		{"retries rate limits", []int{http.StatusTooManyRequests}, 3, 2, http.StatusOK},
+		{"gives up with the last response", []int{500, 500, 500, 500}, 3, 3, http.StatusInternalServerError},
+		{"doesn't retry our mistakes", []int{http.StatusBadRequest}, 3, 1, http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			server, calls, bodies := flakyServer(t, tc.Statuses...)
+			client := testOutboundClient(tc.Description, tc.Attempts)
+
+			req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewBufferString("payload"))
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tc.Status, resp.StatusCode)
+			assert.Equal(t, tc.Calls, atomic.LoadInt32(calls))
+			for _, body := range *bodies {
+				assert.Equal(t, "payload", body, "every attempt gets the whole body")
+			}
+		})
+	}
+}
+
+func TestOutboundClientRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	client := testOutboundClient("retry after", 2)
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	start := time.Now()
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "it waited as long as it was asked")
+}
+
+func TestOutboundClientBudget(t *testing.T) {
+	server, calls, _ := flakyServer(t, 500, 500, 500, 500, 500)
+	client := testOutboundClient("budget", 5)
+	client.backoff = time.Second
+	client.budget = 100 * time.Millisecond
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, 1, atomic.LoadInt32(calls), "no time left to wait for a retry")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestOutboundClientCallerCancels(t *testing.T) {
+	server, _, _ := flakyServer(t, 500, 500, 500)
+	client := testOutboundClient("cancelled", 3)
+	client.backoff = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := client.Do(req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker("test")
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < breakerThreshold-1; i++ {
+		require.NoError(t, breaker.Allow())
+		breaker.Record(false)
+	}
+	assert.Equal(t, CircuitClosed, breaker.State())
+	require.NoError(t, breaker.Allow())
+	breaker.Record(false)
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen)
+
+	// once the cooldown's up one call gets to try
+	now = now.Add(breakerCooldown)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	require.NoError(t, breaker.Allow())
+	assert.ErrorIs(t, breaker.Allow(), errCircuitOpen, "only the one")
+	breaker.Record(false)
+	assert.Equal(t, CircuitOpen, breaker.State(), "it failed, so back to waiting")
+
+	now = now.Add(breakerCooldown)
+	require.NoError(t, breaker.Allow())
+	breaker.Record(true)
+	assert.Equal(t, CircuitClosed, breaker.State())
+	assert.NoError(t, breaker.Allow())
+}
+
+func TestOutboundClientCircuitOpens(t *testing.T) {
+	statuses := make([]int, breakerThreshold)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	server, calls, _ := flakyServer(t, statuses...)
+	client := testOutboundClient("breaker", 1)
+	failures := outboundFailures.Value("breaker")
+
+	for i := 0; i < breakerThreshold; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+	assert.ErrorIs(t, err, errCircuitOpen)
+	assert.EqualValues(t, breakerThreshold, atomic.LoadInt32(calls), "the open circuit didn't call out")
+	assert.Equal(t, failures+breakerThreshold+1, outboundFailures.Value("breaker"))
+	assert.Equal(t, 1.0, circuitOpened.Value("breaker"))
+}
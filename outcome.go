@@ -10,26 +10,60 @@ const (
 	Loss
 )
 
+func (o GameOutcome) String() string {
+	switch o {
+	case Win:
+		return "win"
+	case Draw:
+		return "draw"
+	case Loss:
+		return "loss"
+	}
+	return "unknown"
+}
+
+// Causes of a game ending the way it did, short enough to count up across games.
+const (
+	CauseWall       = "wall"
+	CauseHeadToHead = "head-to-head"
+	CauseBody       = "ran into a snake"
+	CauseSelf       = "ran into ourselves"
+	CauseStarved    = "starved"
+	CauseAllDied    = "all died"
+	CauseLastAlive  = "last alive"
+	CauseOutlasted  = "outlasted"
+)
+
 // describeGameOutcome returns both the enum (GameOutcome) and a descriptive string.
 func describeGameOutcome(game BattleSnakeGame) (GameOutcome, string) {
+	outcome, _, description := judgeGame(game)
+	return outcome, description
+}
+
+// judgeGame works out how the game went for us, why, and a sentence saying so.
+func judgeGame(game BattleSnakeGame) (GameOutcome, string, string) {
 	// Check if you lost by colliding with a wall
 	if game.You.Head.X < 0 || game.You.Head.X >= game.Board.Width || game.You.Head.Y < 0 || game.You.Head.Y >= game.Board.Height {
-		return Loss, "You crashed into a wall"
+		return Loss, CauseWall, "You crashed into a wall"
 	}
 
 	// Check if you lost by colliding with another snake
 	for _, snake := range game.Board.Snakes {
 		if snake.ID != game.You.ID {
-			for _, segment := range snake.Body {
+			for i, segment := range snake.Body {
 				if game.You.Head == segment {
-					return Loss, fmt.Sprintf("You lost by colliding with %s.", snake.Name)
+					cause := CauseBody
+					if i == 0 {
+						cause = CauseHeadToHead
+					}
+					return Loss, cause, fmt.Sprintf("You lost by colliding with %s.", snake.Name)
 				}
 			}
 		} else {
 			// check for collisions with ourself
 			for _, segment := range snake.Body[1 : len(snake.Body)-1] {
 				if game.You.Head == segment {
-					return Loss, "You ran into yourself"
+					return Loss, CauseSelf, "You ran into yourself"
 
 				}
 			}
@@ -38,7 +72,7 @@ func describeGameOutcome(game BattleSnakeGame) (GameOutcome, string) {
 
 	// Check if you lost by starving
 	if game.You.Health <= 0 {
-		return Loss, "You lost by starving to death."
+		return Loss, CauseStarved, "You lost by starving to death."
 	}
 
 	// Check if all snakes died (a draw)
@@ -49,17 +83,17 @@ func describeGameOutcome(game BattleSnakeGame) (GameOutcome, string) {
 		}
 	}
 	if livingSnakes == 0 {
-		return Draw, "All snakes died"
+		return Draw, CauseAllDied, "All snakes died"
 	}
 
 	// Check if you won because all other snakes starved or collided
 	if len(game.Board.Snakes) == 1 && game.Board.Snakes[0].ID == game.You.ID {
 		// If only your snake remains, it means you won
-		return Win, "You won."
+		return Win, CauseLastAlive, "You won."
 	}
 
 	// if we didn't win or draw
-	return Loss, "You Lost."
+	return Loss, CauseOutlasted, "You Lost."
 }
 
 func getColorForOutcome(outcome GameOutcome) int {
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"image/color"
+)
+
+// baseColors are the non-snake colours every frame can use. They always come first in the palette.
+var baseColors = []color.Color{
+	color.RGBA{0, 0, 0, 255},       // Black
+	color.RGBA{255, 255, 255, 255}, // White
+	color.RGBA{255, 0, 0, 255},     // Red
+	color.RGBA{255, 255, 0, 255},   // yellow
+	color.RGBA{0, 255, 0, 255},     // Green
+	color.RGBA{0, 0, 255, 255},     // Blue
+	color.RGBA{100, 100, 100, 255}, // Grey
+	hazardColor,
+	deathColor,
+}
+
+var (
+	// hazardColor fills hazard cells, dark enough that snakes and food still stand out on it
+	hazardColor = color.RGBA{70, 20, 90, 255}
+	// deathColor marks where a snake died
+	deathColor = color.RGBA{255, 60, 60, 255}
+)
+
+// SnakePalette gives every snake in a game the same colours and label row on every frame,
+// even once snakes start dying and dropping out of the frames.
+type SnakePalette struct {
+	order  []string // snake ids in the order they appeared in the first frame
+	bodies map[string]color.RGBA
+}
+
+// newSnakePalette works out each snake's colour from the first frame of the game.
+// Snakes without a usable customisation colour get one derived from their id.
+func newSnakePalette(first *Board) *SnakePalette {
+	palette := &SnakePalette{bodies: make(map[string]color.RGBA)}
+	if first == nil {
+		return palette
+	}
+	for _, snake := range first.Snakes {
+		if _, ok := palette.bodies[snake.ID]; ok {
+			continue
+		}
+		body, err := hexToRGBA(snake.Customizations.Color)
+		if err != nil {
+			body = generateColor(snake.ID)
+		}
+		palette.order = append(palette.order, snake.ID)
+		palette.bodies[snake.ID] = body
+	}
+	return palette
+}
+
+// Body is the snake's body colour.
+func (p *SnakePalette) Body(id string) color.RGBA {
+	if body, ok := p.bodies[id]; ok {
+		return body
+	}
+	return generateColor(id)
+}
+
+// Head is the snake's head colour, a little lighter than its body.
+func (p *SnakePalette) Head(id string) color.RGBA {
+	return lighten(p.Body(id))
+}
+
+// Slot is the snake's position in the first frame, or -1 if it wasn't there.
+func (p *SnakePalette) Slot(id string) int {
+	for i, known := range p.order {
+		if known == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Colors is the full palette for the game: the base colours then body and head for each snake.
+func (p *SnakePalette) Colors() color.Palette {
+	colors := append(color.Palette{}, baseColors...)
+	for _, id := range p.order {
+		colors = append(colors, p.Body(id), p.Head(id))
+	}
+	return colors
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnakePaletteStableAcrossFrames(t *testing.T) {
+	first := &Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "a", Body: []Point{{X: 1, Y: 1}}, Customizations: Customizations{Color: "#ff0000"}},
+			{ID: "b", Body: []Point{{X: 5, Y: 5}}},
+			{ID: "c", Body: []Point{{X: 9, Y: 9}}, Customizations: Customizations{Color: "not a colour"}},
+		},
+	}
+	palette := newSnakePalette(first)
+
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, palette.Body("a"))
+	assert.Equal(t, generateColor("b"), palette.Body("b"))
+	assert.Equal(t, generateColor("c"), palette.Body("c"))
+	assert.Equal(t, lighten(palette.Body("b")), palette.Head("b"))
+
+	// b dies and a later frame loses its colour, nothing else moves
+	later := &Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "c", Body: []Point{{X: 9, Y: 8}}},
+			{ID: "a", Body: []Point{{X: 1, Y: 2}}},
+		},
+	}
+	_, firstColors := renderBoardToImage(first, palette)
+	_, laterColors := renderBoardToImage(later, palette)
+	assert.Equal(t, firstColors, laterColors)
+	assert.Equal(t, 0, palette.Slot("a"))
+	assert.Equal(t, 2, palette.Slot("c"))
+	assert.Equal(t, -1, palette.Slot("unknown"))
+	assert.Len(t, palette.Colors(), len(baseColors)+6)
+}
+
+func TestGenerateColorDeterministic(t *testing.T) {
+	assert.Equal(t, generateColor("gs_abc"), generateColor("gs_abc"))
+	assert.NotEqual(t, generateColor("gs_abc"), generateColor("gs_abd"))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// ParallelMode decides how the workers share a search.
+type ParallelMode int
+
+const (
+	// ParallelShared has every worker grow the one tree, taking turns on each node's lock.
+	ParallelShared ParallelMode = iota
+	// ParallelRoot gives each worker a tree of its own grown from the root. Nothing is shared below the
+	// root so there's no waiting on locks, and the root's children are merged when it's time to decide.
+	ParallelRoot
+)
+
+func (m ParallelMode) String() string {
+	if m == ParallelRoot {
+		return "root"
+	}
+	return "shared"
+}
+
+// parallelModeFromEnv reads SEARCH_PARALLEL, "root" for root parallelization and the shared tree otherwise.
+func parallelModeFromEnv() ParallelMode {
+	return parseParallelMode(os.Getenv("SEARCH_PARALLEL"))
+}
+
+// parseParallelMode is root parallelization for "root" and the shared tree otherwise.
+func parseParallelMode(name string) ParallelMode {
+	if name == "root" {
+		return ParallelRoot
+	}
+	return ParallelShared
+}
+
+// workerTrees is the tree each worker grows. With a shared tree that's the root for all of them. With
+// root parallelization the first worker keeps the root, so whatever was reused from last turn still gets
+// searched, and the rest start their own from the same board. Their roots hang off the real one so
+// visits still add up at the top while they search, but it doesn't know about them until mergeTrees.
+func workerTrees(rootNode *Node, numWorkers int, mode ParallelMode) []*Node {
+	trees := make([]*Node, numWorkers)
+	for i := range trees {
+		if i == 0 || mode != ParallelRoot {
+			trees[i] = rootNode
+			continue
+		}
+		trees[i] = NewNode(copyBoard(rootNode.Board), rootNode.SnakeIndex, rootNode)
+	}
+	return trees
+}
+
+// mergeTrees folds the workers' own trees into the root once they've finished. A move more than one tree
+// searched keeps the subtree with the most visits and takes the others' visits and scores on top, so
+// the root's children add up to everything that was searched. The root's own totals are already right
+// since every tree backed its visits up into it.
+func mergeTrees(rootNode *Node, trees []*Node) {
+	for _, tree := range trees {
+		if tree == rootNode {
+			continue
+		}
+		for _, child := range tree.Children() {
+			move := childMove(tree, child)
+			children := append([]*Node(nil), rootNode.Children()...)
+			existing := -1
+			for i, c := range children {
+				if childMove(rootNode, c) == move {
+					existing = i
+					break
+				}
+			}
+			if existing < 0 {
+				child.Parent = rootNode
+				rootNode.setChildren(append(children, child))
+				rootNode.setUnexpandedMoves(removeDirection(rootNode.UnexpandedMoves(), move))
+				continue
+			}
+			kept, folded := children[existing], child
+			if atomic.LoadInt64(&folded.Visits) > atomic.LoadInt64(&kept.Visits) {
+				kept, folded = folded, kept
+				kept.Parent = rootNode
+				children[existing] = kept
+				rootNode.setChildren(children)
+			}
+			kept.absorb(folded)
+		}
+	}
+}
+
+// absorb adds another node's visits and scores for the same board to this one's.
+func (n *Node) absorb(other *Node) {
+	atomic.AddInt64(&n.Visits, atomic.LoadInt64(&other.Visits))
+	atomicAddFloat64(&n.Score, loadFloat64(&other.Score))
+	for i := range n.Scores {
+		if i < len(other.Scores) {
+			atomicAddFloat64(&n.Scores[i], loadFloat64(&other.Scores[i]))
+		}
+	}
+}
+
+// childMove is the move that takes the node to its child.
+func childMove(node, child *Node) Direction {
+	return directionTo(&node.Board, node.Board.Snakes[child.SnakeIndex].Head, child.Board.Snakes[child.SnakeIndex].Head)
+}
+
+func removeDirection(moves []Direction, move Direction) []Direction {
+	kept := moves[:0]
+	for _, m := range moves {
+		if m != move {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
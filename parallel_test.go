@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelModeFromEnv(t *testing.T) {
+	t.Setenv("SEARCH_PARALLEL", "")
+	assert.Equal(t, ParallelShared, parallelModeFromEnv())
+	t.Setenv("SEARCH_PARALLEL", "root")
+	assert.Equal(t, ParallelRoot, parallelModeFromEnv())
+	assert.Equal(t, "root", ParallelRoot.String())
+	assert.Equal(t, "shared", ParallelShared.String())
+}
+
+func TestRootParallelMergesTrees(t *testing.T) {
+	board := evalTestBoard()
+	root := MCTS(context.Background(), "test", board, 4000, 4, make(map[string]*Node), WithSearchConfig(SearchConfig{Parallel: ParallelRoot}))
+	require.GreaterOrEqual(t, root.Visits, int64(4000))
+
+	// each move once, all of them hanging off the root, and between them every visit the root had
+	moves := make(map[Direction]bool)
+	var visits int64
+	for _, child := range root.Children() {
+		move := childMove(root, child)
+		assert.False(t, moves[move], "%v merged twice", move)
+		moves[move] = true
+		assert.Equal(t, root, child.Parent)
+		visits += child.Visits
+	}
+	assert.Equal(t, root.Visits, visits)
+	for _, move := range root.UnexpandedMoves() {
+		assert.False(t, moves[move], "%v is both a child and unexpanded", move)
+	}
+	assert.NotEmpty(t, principalVariation(root))
+}
+
+func TestMergeTrees(t *testing.T) {
+	board := evalTestBoard()
+	root := NewNode(copyBoard(board), -1, nil)
+	trees := workerTrees(root, 3, ParallelRoot)
+	require.Len(t, trees, 3)
+	assert.Same(t, root, trees[0], "the first worker keeps the root and whatever it already had")
+	for _, tree := range trees[1:] {
+		assert.NotSame(t, root, tree)
+		assert.Same(t, root, tree.Parent)
+	}
+
+	// the root has searched up, one tree has searched up more deeply and one down
+	expand := func(node *Node, move Direction, visits int64, score float64) *Node {
+		next := copyBoard(node.Board)
+		applyMove(&next, 0, move)
+		child := NewNode(next, 0, node)
+		child.Visits = visits
+		child.Score = score
+		child.Scores = []float64{score, -score, -score}
+		node.setChildren(append(node.Children(), child))
+		node.setUnexpandedMoves(removeDirection(node.UnexpandedMoves(), move))
+		return child
+	}
+	shallow := expand(root, Up, 10, 5)
+	deep := expand(trees[1], Up, 30, 3)
+	down := expand(trees[2], Down, 20, -4)
+
+	mergeTrees(root, trees)
+	require.Len(t, root.Children(), 2)
+	assert.Same(t, deep, root.Children()[0], "the move keeps its most searched subtree")
+	assert.Equal(t, int64(40), deep.Visits)
+	assert.Equal(t, 8.0, deep.Score)
+	assert.Equal(t, []float64{8, -8, -8}, deep.Scores)
+	assert.Same(t, root, deep.Parent)
+	assert.Same(t, down, root.Children()[1])
+	assert.Same(t, root, down.Parent)
+	assert.NotContains(t, root.UnexpandedMoves(), Down)
+	assert.Equal(t, int64(10), shallow.Visits, "the folded subtree is left as it was")
+
+	shared := workerTrees(root, 3, ParallelShared)
+	for _, tree := range shared {
+		assert.Same(t, root, tree)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pipelineAttempts is how many times a stage is tried before it's marked failed
+	pipelineAttempts = 3
+	// pipelineBackoff is the wait before a stage's first retry, doubling after each failure
+	pipelineBackoff = 2 * time.Second
+	// pipelineStageTimeout is the most a single attempt at a stage gets
+	pipelineStageTimeout = 2 * time.Minute
+	// pipelineHistory is how many finished games' statuses are kept for the admin api
+	pipelineHistory = 50
+	// pipelineWorkers is how many games' end of game work runs at once. Rendering and uploading are heavy,
+	// and a burst of games ending together shouldn't take the cpu from the games still going.
+	pipelineWorkers = 2
+	// pipelineQueueSize is how many games can wait for a worker before new ones are turned away
+	pipelineQueueSize = 100
+)
+
+// Stage states.
+const (
+	StagePending = "pending"
+	StageRunning = "running"
+	StageDone    = "done"
+	StageFailed  = "failed"
+	StageSkipped = "skipped"
+)
+
+// errQueueFull means too many games were waiting for their end of game work, so the game was dropped.
+var errQueueFull = errors.New("end of game queue is full")
+
+// errNothingToDisplay means the render came out empty, so there's nothing for the tidbyt.
+var errNothingToDisplay = errors.New("no frames rendered")
+
+// EndOfGameJob is the work left over once a game ends, passed from stage to stage.
+type EndOfGameJob struct {
+	Session *GameSession
+	Game    BattleSnakeGame
+	End     time.Time
+
+	tidbyt []TidbytPush // filled in by the render stage for the display stage
+
+	// filled in by the render stage for the replay stage
+	frames  []*Board
+	outcome GameOutcome
+}
+
+// PipelineStage is one independent piece of the end of game work.
+type PipelineStage struct {
+	Name  string
+	After string // stage that has to succeed first, if any
+	Run   func(ctx context.Context, job *EndOfGameJob) error
+}
+
+// StageStatus is how a stage is getting on.
+type StageStatus struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PipelineStatus is how a game's end of game work is getting on.
+type PipelineStatus struct {
+	GameID   string        `json:"game_id"`
+	Started  time.Time     `json:"started"`            // when the game was queued
+	Dequeued time.Time     `json:"dequeued,omitempty"` // when a worker picked it up
+	Stages   []StageStatus `json:"stages"`
+}
+
+// queuedJob is a game waiting for a worker, with the status it reports to.
+type queuedJob struct {
+	job    *EndOfGameJob
+	status *PipelineStatus
+}
+
+// Pipeline runs the end of game stages in the background so /end can answer straight away. Games queue
+// up for a few workers rather than all running at once.
+// Each stage is retried on its own, so a failing tidbyt push doesn't stop the game being archived or reported.
+type Pipeline struct {
+	stages   []PipelineStage
+	attempts int
+	backoff  time.Duration
+	workers  int
+
+	queue        chan queuedJob
+	startWorkers sync.Once
+
+	mu       sync.Mutex
+	statuses []*PipelineStatus // oldest first
+	running  sync.WaitGroup
+}
+
+func newPipeline(stages []PipelineStage) *Pipeline {
+	return &Pipeline{
+		stages:   stages,
+		attempts: pipelineAttempts,
+		backoff:  pipelineBackoff,
+		workers:  pipelineWorkers,
+		queue:    make(chan queuedJob, pipelineQueueSize),
+	}
+}
+
+// endOfGame reports, records, archives, renders, replays and displays every game we play, and keeps our
+// decisions so the game can be exported with them drawn on, our searches so they can be trained on and
+// heatmaps of what the evaluation saw.
+var endOfGame = newPipeline([]PipelineStage{
+	{Name: "report", Run: reportStage},
+	{Name: "record", Run: recordStage},
+	{Name: "archive", Run: archiveStage},
+	{Name: "decisions", Run: decisionsStage},
+	{Name: "gamelog", Run: gameLogStage},
+	{Name: "blunders", Run: blunderStage},
+	{Name: "regressions", Run: regressionStage},
+	{Name: "heatmaps", Run: heatmapStage},
+	{Name: "training", Run: trainingStage},
+	{Name: "render", Run: renderStage},
+	{Name: "replay", After: "render", Run: replayStage},
+	{Name: "display", After: "render", Run: displayStage},
+})
+
+// Start queues the job for the stages and returns straight away. If the queue's full the job's stages are
+// all marked failed rather than holding up /end.
+func (p *Pipeline) Start(job *EndOfGameJob) {
+	p.startWorkers.Do(func() {
+		for i := 0; i < p.workers; i++ {
+			go p.work()
+		}
+	})
+
+	status := &PipelineStatus{GameID: job.Session.ID, Started: time.Now()}
+	for _, stage := range p.stages {
+		status.Stages = append(status.Stages, StageStatus{Name: stage.Name, State: StagePending, UpdatedAt: status.Started})
+	}
+
+	p.mu.Lock()
+	p.statuses = append(p.statuses, status)
+	if len(p.statuses) > pipelineHistory {
+		p.statuses = p.statuses[len(p.statuses)-pipelineHistory:]
+	}
+	p.mu.Unlock()
+
+	p.running.Add(1)
+	select {
+	case p.queue <- queuedJob{job: job, status: status}:
+		job.Session.Logger.Info("end of game work queued", "queued", len(p.queue))
+	default:
+		for i := range p.stages {
+			p.update(status, i, StageFailed, 0, errQueueFull)
+		}
+		stageFailures.Inc("queue")
+		job.Session.Logger.Error("end of game work dropped", "error", errQueueFull.Error())
+		p.running.Done()
+	}
+}
+
+// work runs queued jobs one after another, for as long as the process lives.
+func (p *Pipeline) work() {
+	for queued := range p.queue {
+		p.run(queued.job, queued.status)
+		p.running.Done()
+	}
+}
+
+// Wait blocks until every started job has finished or the context is done.
+func (p *Pipeline) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run works through the stages in order. A stage whose prerequisite didn't succeed is skipped.
+func (p *Pipeline) run(job *EndOfGameJob, status *PipelineStatus) {
+	p.mu.Lock()
+	status.Dequeued = time.Now()
+	waited := status.Dequeued.Sub(status.Started)
+	p.mu.Unlock()
+	job.Session.Logger.Info("end of game work started", "waited", waited.String())
+
+	failed := 0
+	defer func() {
+		job.Session.Logger.Info("end of game work finished", "took", time.Since(status.Dequeued).String(), "failed", failed)
+	}()
+
+	succeeded := make(map[string]bool)
+	for i, stage := range p.stages {
+		if stage.After != "" && !succeeded[stage.After] {
+			p.update(status, i, StageSkipped, 0, fmt.Errorf("%s didn't succeed", stage.After))
+			continue
+		}
+
+		var err error
+		attempt := 1
+		for ; attempt <= p.attempts; attempt++ {
+			p.update(status, i, StageRunning, attempt, err)
+			ctx, cancel := context.WithTimeout(context.Background(), pipelineStageTimeout)
+			err = stage.Run(ctx, job)
+			cancel()
+			if err == nil {
+				break
+			}
+			job.Session.Logger.Warn("end of game stage failed", "stage", stage.Name, "attempt", attempt, "error", err.Error())
+			if attempt < p.attempts {
+				time.Sleep(p.backoff << (attempt - 1))
+			}
+		}
+
+		if err != nil {
+			p.update(status, i, StageFailed, p.attempts, err)
+			stageFailures.Inc(stage.Name)
+			failed++
+			job.Session.Logger.Error("end of game stage gave up", "stage", stage.Name, "error", err.Error())
+			continue
+		}
+		succeeded[stage.Name] = true
+		p.update(status, i, StageDone, attempt, nil)
+	}
+}
+
+func (p *Pipeline) update(status *PipelineStatus, i int, state string, attempts int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stage := &status.Stages[i]
+	stage.State = state
+	stage.Attempts = attempts
+	stage.Error = ""
+	if err != nil {
+		stage.Error = err.Error()
+	}
+	stage.UpdatedAt = time.Now()
+}
+
+// Statuses copies the status of the most recent jobs, newest first.
+func (p *Pipeline) Statuses() []PipelineStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]PipelineStatus, 0, len(p.statuses))
+	for i := len(p.statuses) - 1; i >= 0; i-- {
+		status := *p.statuses[i]
+		status.Stages = append([]StageStatus(nil), status.Stages...)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// handlePipelines shows how the end of game work is going, optionally for one game with ?game_id=.
+func handlePipelines(w http.ResponseWriter, r *http.Request) {
+	statuses := endOfGame.Statuses()
+	if gameID := r.URL.Query().Get("game_id"); gameID != "" {
+		for _, status := range statuses {
+			if status.GameID == gameID {
+				writeJSON(w, status)
+				return
+			}
+		}
+		http.Error(w, "no pipeline for that game", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, statuses)
+}
+
+// archiveStage keeps a copy of the engine's gif of the game in our bucket.
+func archiveStage(ctx context.Context, job *EndOfGameJob) error {
+	return downloadAndUploadFile(ctx, job.Session.ID)
+}
+
+// reportStage posts the result to discord, with how we've been doing lately from the stats store.
+func reportStage(ctx context.Context, job *EndOfGameJob) error {
+	session := job.Session
+	record := newGameRecord(session, job.Game, job.End)
+
+	// the record stage hasn't kept this game yet, unless this is a retry
+	var games []GameRecord
+	stats, err := loadGameStats(ctx)
+	if err != nil {
+		session.Logger.Warn("failed to load game stats for the report", "error", err.Error())
+	} else {
+		games = stats.Games
+		if len(games) == 0 || games[len(games)-1].GameID != record.GameID {
+			games = append(games, record)
+		}
+	}
+
+	// TODO: only works for duels
+	rank, score, err := GetDuelsRankAndScore()
+	if err != nil {
+		rank = -1
+		score = -1
+	}
+
+	gameDuration := job.End.Sub(session.start)
+
+	session.Logger.Info("Game ended", "game", job.Game, "rank", rank, "score", score, "duration_ms", gameDuration.Milliseconds())
+
+	discordQueue.Send(webhookURL.Get(), "", []Embed{gameReportEmbed(session, record, gameDuration, games, rank, score)})
+	return nil
+}
+
+// gameReportEmbed is the end of game report. games is everything in the stats store including this
+// game, and the win rate's left off if it couldn't be loaded. A rank below zero means we don't know it.
+func gameReportEmbed(session *GameSession, record GameRecord, duration time.Duration, games []GameRecord, rank, score int) Embed {
+	var outcome GameOutcome
+	var outcomeEmoji string
+	switch record.Outcome {
+	case Win.String():
+		outcome, outcomeEmoji = Win, "✅"
+	case Loss.String():
+		outcome, outcomeEmoji = Loss, "❌"
+	default:
+		outcome, outcomeEmoji = Draw, "🦍"
+	}
+
+	opponents := "nobody"
+	if len(record.Opponents) > 0 {
+		opponents = strings.Join(record.Opponents, ", ")
+	}
+	description := []string{record.Cause}
+	for _, summary := range []string{session.indecision.Summary(), session.legality.Summary(), session.decisions.EngineSummary()} {
+		if summary != "" {
+			description = append(description, summary)
+		}
+	}
+
+	latency := "-"
+	if average := session.latency.Average(); average > 0 {
+		latency = fmt.Sprintf("%dms", average.Milliseconds())
+	}
+	fields := []EmbedField{
+		{Name: "turns", Value: fmt.Sprint(record.Turns), Inline: true},
+		{Name: "duration", Value: duration.Round(time.Second).String(), Inline: true},
+		{Name: "avg latency", Value: latency, Inline: true},
+		{Name: "iterations/move", Value: fmt.Sprint(session.decisions.VisitsPerMove()), Inline: true},
+	}
+	if rank >= 0 {
+		fields = append(fields,
+			EmbedField{Name: "rank", Value: fmt.Sprint(rank), Inline: true},
+			EmbedField{Name: "score", Value: fmt.Sprint(score), Inline: true},
+		)
+	}
+	if rates := rollingWinRates(games, winRateWindow, winRatePoints); len(rates) > 0 {
+		fields = append(fields, EmbedField{
+			Name:  fmt.Sprintf("win rate, last %d", winRateWindow),
+			Value: fmt.Sprintf("%.0f%% `%s`", 100*rates[len(rates)-1], sparkline(rates)),
+		})
+	}
+
+	return Embed{
+		Title:       fmt.Sprintf("%s %s against %s", outcomeEmoji, record.Outcome, opponents),
+		Description: strings.Join(description, " | "),
+		URL:         fmt.Sprintf("https://play.battlesnake.com/game/%s", record.GameID),
+		Color:       getColorForOutcome(outcome),
+		Fields:      fields,
+		Footer:      &Footer{Text: record.Engine},
+		Timestamp:   record.Time.Format(time.RFC3339),
+	}
+}
+
+// renderStage replays the game from the engine and draws it for the tidbyt.
+func renderStage(ctx context.Context, job *EndOfGameJob) error {
+	session := job.Session
+
+	// WebSocket URL for the game
+	wsURL := fmt.Sprintf("wss://engine.battlesnake.com/games/%s/events", session.ID)
+
+	// Collect game frames
+	frames, outcome, err := collectGameFrames(wsURL, session.YouID)
+	if err != nil {
+		return fmt.Errorf("failed to collect game frames: %w", err)
+	}
+	session.Logger.Info("got frames from websocket", "turns", len(frames), "outcome", outcome)
+	if len(frames) == 0 {
+		return errNothingToDisplay
+	}
+
+	job.frames, job.outcome = frames, outcome
+	job.tidbyt, err = encodeGameWebPs(frames, outcome)
+	if err != nil {
+		return fmt.Errorf("failed to render game to webp: %w", err)
+	}
+	session.Logger.Info("rendered game for the tidbyt", "pushes", len(job.tidbyt))
+	return nil
+}
+
+// replayObject is where the high resolution replay of the game is kept in the bucket.
+func replayObject(gameID string) string {
+	return fmt.Sprintf("replays/%s.gif", gameID)
+}
+
+// replayStage keeps a high resolution gif of the game in the bucket, for looking back over it bigger than
+// the tidbyt can show.
+func replayStage(ctx context.Context, job *EndOfGameJob) error {
+	data, err := encodeGameHighResGIF(job.frames, job.outcome)
+	if err != nil {
+		return fmt.Errorf("failed to render the replay: %w", err)
+	}
+	job.Session.Logger.Info("rendered high res replay", "bytes", len(data))
+	return uploadToBucket(ctx, replayObject(job.Session.ID), "image/gif", data)
+}
+
+// displayStage pushes the render to the tidbyt, one part at a time, letting each play out before the next
+// replaces it.
+func displayStage(ctx context.Context, job *EndOfGameJob) error {
+	for i, push := range job.tidbyt {
+		if err := PushToTidbyt(ctx, deviceID, push.WebP); err != nil {
+			return fmt.Errorf("failed to push part %d of %d to Tidbyt: %w", i+1, len(job.tidbyt), err)
+		}
+		if i == len(job.tidbyt)-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(push.Duration):
+		}
+	}
+	return nil
+}
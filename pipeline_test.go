@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStage fails the first failures attempts then succeeds.
+func failingStage(name string, failures int32) (PipelineStage, *int32) {
+	calls := new(int32)
+	return PipelineStage{
+		Name: name,
+		Run: func(ctx context.Context, job *EndOfGameJob) error {
+			if atomic.AddInt32(calls, 1) <= failures {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	}, calls
+}
+
+func runPipeline(t *testing.T, p *Pipeline, gameID string) PipelineStatus {
+	t.Helper()
+	p.backoff = time.Millisecond
+	game := BattleSnakeGame{}
+	game.Game.ID = gameID
+	p.Start(&EndOfGameJob{Session: newGameSession(game, nil), Game: game, End: time.Now()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, p.Wait(ctx))
+	statuses := p.Statuses()
+	require.NotEmpty(t, statuses)
+	return statuses[0]
+}
+
+func TestPipelineRetriesStagesIndependently(t *testing.T) {
+	flaky, flakyCalls := failingStage("flaky", 1)
+	broken, brokenCalls := failingStage("broken", 100)
+	fine, fineCalls := failingStage("fine", 0)
+	p := newPipeline([]PipelineStage{flaky, broken, fine})
+
+	status := runPipeline(t, p, "retries")
+
+	assert.Equal(t, "retries", status.GameID)
+	assert.EqualValues(t, 2, atomic.LoadInt32(flakyCalls))
+	assert.EqualValues(t, pipelineAttempts, atomic.LoadInt32(brokenCalls))
+	assert.EqualValues(t, 1, atomic.LoadInt32(fineCalls), "a failed stage shouldn't stop the ones after it")
+
+	require.Len(t, status.Stages, 3)
+	assert.Equal(t, StageStatus{Name: "flaky", State: StageDone, Attempts: 2}, withoutTime(status.Stages[0]))
+	assert.Equal(t, StageStatus{Name: "broken", State: StageFailed, Attempts: pipelineAttempts, Error: "boom"}, withoutTime(status.Stages[1]))
+	assert.Equal(t, StageStatus{Name: "fine", State: StageDone, Attempts: 1}, withoutTime(status.Stages[2]))
+}
+
+func TestPipelineSkipsStagesWhosePrerequisiteFailed(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Failures    int32
+		Want        string
+	}{
+		{Description: "prerequisite succeeded", Failures: 0, Want: StageDone},
+		{Description: "prerequisite failed", Failures: 100, Want: StageSkipped},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			render, _ := failingStage("render", tc.Failures)
+			display, displayCalls := failingStage("display", 0)
+			display.After = "render"
+			p := newPipeline([]PipelineStage{render, display})
+
+			status := runPipeline(t, p, tc.Description)
+
+			assert.Equal(t, tc.Want, status.Stages[1].State)
+			if tc.Want == StageSkipped {
+				assert.Zero(t, atomic.LoadInt32(displayCalls))
+				assert.Contains(t, status.Stages[1].Error, "render")
+			}
+		})
+	}
+}
+
+func TestPipelineStartReturnsBeforeStagesFinish(t *testing.T) {
+	release := make(chan struct{})
+	p := newPipeline([]PipelineStage{{
+		Name: "slow",
+		Run: func(ctx context.Context, job *EndOfGameJob) error {
+			<-release
+			return nil
+		},
+	}})
+	game := BattleSnakeGame{}
+	game.Game.ID = "slow"
+
+	p.Start(&EndOfGameJob{Session: newGameSession(game, nil), Game: game})
+	assert.Eventually(t, func() bool {
+		return p.Statuses()[0].Stages[0].State == StageRunning
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	require.NoError(t, p.Wait(context.Background()))
+	assert.Equal(t, StageDone, p.Statuses()[0].Stages[0].State)
+}
+
+func TestPipelineRunsAFewGamesAtOnce(t *testing.T) {
+	release := make(chan struct{})
+	var running, most int32
+	p := newPipeline([]PipelineStage{{
+		Name: "slow",
+		Run: func(ctx context.Context, job *EndOfGameJob) error {
+			now := atomic.AddInt32(&running, 1)
+			for {
+				seen := atomic.LoadInt32(&most)
+				if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+			return nil
+		},
+	}})
+	for i := 0; i < pipelineWorkers+3; i++ {
+		game := BattleSnakeGame{}
+		game.Game.ID = string(rune('a' + i))
+		p.Start(&EndOfGameJob{Session: newGameSession(game, nil), Game: game})
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&running) == pipelineWorkers }, time.Second, time.Millisecond)
+
+	queued := 0
+	for _, status := range p.Statuses() {
+		if status.Dequeued.IsZero() {
+			queued++
+			assert.Equal(t, StagePending, status.Stages[0].State)
+		}
+	}
+	assert.Equal(t, 3, queued, "the rest wait for a worker")
+
+	close(release)
+	require.NoError(t, p.Wait(context.Background()))
+	assert.EqualValues(t, pipelineWorkers, atomic.LoadInt32(&most))
+	for _, status := range p.Statuses() {
+		assert.Equal(t, StageDone, status.Stages[0].State)
+	}
+}
+
+func TestPipelineDropsGamesWhenTheQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	p := newPipeline([]PipelineStage{{
+		Name: "slow",
+		Run: func(ctx context.Context, job *EndOfGameJob) error {
+			<-release
+			return nil
+		},
+	}})
+	p.workers = 1
+	p.queue = make(chan queuedJob, 1)
+	start := func(id string) {
+		game := BattleSnakeGame{}
+		game.Game.ID = id
+		p.Start(&EndOfGameJob{Session: newGameSession(game, nil), Game: game})
+	}
+	start("running")
+	assert.Eventually(t, func() bool { return len(p.queue) == 0 }, time.Second, time.Millisecond)
+	start("queued")
+	start("dropped")
+
+	dropped := p.Statuses()[0]
+	assert.Equal(t, "dropped", dropped.GameID)
+	assert.Equal(t, StageFailed, dropped.Stages[0].State)
+	assert.Equal(t, errQueueFull.Error(), dropped.Stages[0].Error)
+
+	close(release)
+	require.NoError(t, p.Wait(context.Background()))
+	assert.Equal(t, StageDone, p.Statuses()[1].Stages[0].State, "the queued game still runs")
+}
+
+func TestPipelineKeepsRecentHistory(t *testing.T) {
+	p := newPipeline(nil)
+	for i := 0; i < pipelineHistory+5; i++ {
+		runPipeline(t, p, string(rune('a'+i%26)))
+	}
+	assert.Len(t, p.Statuses(), pipelineHistory)
+}
+
+func TestHandlePipelines(t *testing.T) {
+	saved := endOfGame
+	t.Cleanup(func() { endOfGame = saved })
+	stage, _ := failingStage("report", 0)
+	endOfGame = newPipeline([]PipelineStage{stage})
+	runPipeline(t, endOfGame, "game-1")
+	runPipeline(t, endOfGame, "game-2")
+
+	router := newRouter("secret")
+	get := func(path string, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("/admin/pipelines", "").Code)
+
+	rec := get("/admin/pipelines", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var all []PipelineStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &all))
+	require.Len(t, all, 2)
+	assert.Equal(t, "game-2", all[0].GameID, "newest first")
+
+	rec = get("/admin/pipelines?game_id=game-1", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var one PipelineStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &one))
+	assert.Equal(t, "game-1", one.GameID)
+	assert.Equal(t, StageDone, one.Stages[0].State)
+
+	assert.Equal(t, http.StatusNotFound, get("/admin/pipelines?game_id=nope", "secret").Code)
+}
+
+func withoutTime(status StageStatus) StageStatus {
+	status.UpdatedAt = time.Time{}
+	return status
+}
+
+func TestGameReportEmbed(t *testing.T) {
+	game := sessionTestGame("report")
+	session := newGameSession(game, []string{"b"})
+	session.latency.Observe(1, "100")
+	session.latency.Observe(2, "200")
+	session.decisions.Add(DecisionRecord{Visits: 5000, Algorithm: "mcts"})
+	session.decisions.Add(DecisionRecord{Forced: true})
+	record := GameRecord{GameID: "report", Time: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), Opponents: []string{"b"}, Outcome: Loss.String(), Cause: CauseHeadToHead, Turns: 42, Engine: "abc123"}
+	games := []GameRecord{{Outcome: Win.String()}, {Outcome: Win.String()}, record}
+
+	embed := gameReportEmbed(session, record, 95*time.Second, games, 12, 1500)
+	assert.Equal(t, "❌ loss against b", embed.Title)
+	assert.Equal(t, "head-to-head | mcts 1", embed.Description)
+	assert.Equal(t, "https://play.battlesnake.com/game/report", embed.URL)
+	assert.Equal(t, getColorForOutcome(Loss), embed.Color)
+	assert.Equal(t, "abc123", embed.Footer.Text)
+	assert.Equal(t, "2024-03-10T12:00:00Z", embed.Timestamp)
+
+	fields := make(map[string]string)
+	for _, field := range embed.Fields {
+		fields[field.Name] = field.Value
+	}
+	assert.Equal(t, map[string]string{
+		"turns":             "42",
+		"duration":          "1m35s",
+		"avg latency":       "150ms",
+		"iterations/move":   "5000",
+		"rank":              "12",
+		"score":             "1500",
+		"win rate, last 10": "67% `██▆`",
+	}, fields)
+
+	// no rank, no stats, no latency reported
+	embed = gameReportEmbed(newGameSession(game, nil), record, time.Second, nil, -1, -1)
+	assert.Len(t, embed.Fields, 4)
+	assert.Equal(t, "-", embed.Fields[2].Value)
+}
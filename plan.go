@@ -0,0 +1,126 @@
+package main
+
+import (
+	"sync"
+)
+
+const (
+	// how many of our own moves to keep from the principal variation
+	maxPlanMoves = 4
+	// opponent replies with less than this share of their parent's visits aren't considered expected
+	planMinReplyShare = 0.1
+	// added to the average score of the planned child at the root, enough to break near ties
+	planBias = 0.05
+)
+
+// Plan is the line the search intended to play at the end of a turn.
+type Plan struct {
+	Turn int `json:"turn"`
+	// our intended moves starting with the one we just played
+	Moves []Direction `json:"moves"`
+	// squares our head passes through while following the plan
+	Region []Point `json:"region"`
+	// heads each opponent could reasonably have after replying to our move, keyed by snake id
+	ExpectedHeads map[string][]Point `json:"expected_heads"`
+}
+
+// PlanCache holds the latest plan for a game.
+type PlanCache struct {
+	mu   sync.Mutex
+	plan *Plan
+}
+
+// Store replaces the cached plan.
+func (c *PlanCache) Store(plan *Plan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.plan = plan
+}
+
+// Load returns the cached plan, or nil if there isn't one.
+func (c *PlanCache) Load() *Plan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.plan
+}
+
+// buildPlan extracts our intended moves and the expected opponent replies from a finished search.
+// It assumes our snake is at index 0 of the root board.
+func buildPlan(root *Node, turn int) *Plan {
+	pv := principalVariation(root)
+	if len(pv) == 0 {
+		return nil
+	}
+
+	plan := &Plan{
+		Turn:          turn,
+		ExpectedHeads: make(map[string][]Point),
+	}
+
+	previous := root
+	for _, node := range pv {
+		if node.SnakeIndex == 0 && !isSnakeDead(node.Board.Snakes[0]) {
+			move := directionTo(&previous.Board, previous.Board.Snakes[0].Head, node.Board.Snakes[0].Head)
+			plan.Moves = append(plan.Moves, move)
+			plan.Region = append(plan.Region, node.Board.Snakes[0].Head)
+			if len(plan.Moves) == maxPlanMoves {
+				break
+			}
+		}
+		previous = node
+	}
+
+	// walk every reasonably visited reply of each opponent in turn below our chosen move
+	frontier := []*Node{pv[0]}
+	for snakeIndex := 1; snakeIndex < len(root.Board.Snakes); snakeIndex++ {
+		var next []*Node
+		for _, parent := range frontier {
+			for _, child := range parent.Children() {
+				if float64(child.Visits) < planMinReplyShare*float64(parent.Visits) {
+					continue
+				}
+				next = append(next, child)
+				snake := child.Board.Snakes[snakeIndex]
+				if !isSnakeDead(snake) && !containsPoint(plan.ExpectedHeads[snake.ID], snake.Head) {
+					plan.ExpectedHeads[snake.ID] = append(plan.ExpectedHeads[snake.ID], snake.Head)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return plan
+}
+
+// NextMove returns the planned move for this turn if the board played out the way the plan expected.
+// The board must have our snake at index 0.
+func (p *Plan) NextMove(board Board, turn int) (Direction, bool) {
+	if p == nil || turn != p.Turn+1 || len(p.Moves) < 2 || len(board.Snakes) == 0 {
+		return Unset, false
+	}
+
+	if board.Snakes[0].Head != p.Region[0] {
+		return Unset, false
+	}
+
+	for _, snake := range board.Snakes[1:] {
+		if isSnakeDead(snake) {
+			continue
+		}
+		expected, ok := p.ExpectedHeads[snake.ID]
+		if !ok || !containsPoint(expected, snake.Head) {
+			return Unset, false
+		}
+	}
+
+	return p.Moves[1], true
+}
+
+func containsPoint(points []Point, point Point) bool {
+	for _, p := range points {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func planTestBoard(usHead, themHead Point) Board {
+	return Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "us", Health: 100, Head: usHead, Body: []Point{usHead, {X: 0, Y: 0}}},
+			{ID: "them", Health: 100, Head: themHead, Body: []Point{themHead, {X: 10, Y: 10}}},
+		},
+	}
+}
+
+func TestBuildPlanAndNextMove(t *testing.T) {
+	// we go up twice, they mostly go left, sometimes down, almost never up
+	root := &Node{Board: planTestBoard(Point{X: 5, Y: 5}, Point{X: 8, Y: 8}), SnakeIndex: -1, Visits: 100}
+	up := &Node{Board: planTestBoard(Point{X: 5, Y: 6}, Point{X: 8, Y: 8}), SnakeIndex: 0, Visits: 90, Parent: root}
+	right := &Node{Board: planTestBoard(Point{X: 6, Y: 5}, Point{X: 8, Y: 8}), SnakeIndex: 0, Visits: 10, Parent: root}
+	root.setChildren([]*Node{up, right})
+
+	left := &Node{Board: planTestBoard(Point{X: 5, Y: 6}, Point{X: 7, Y: 8}), SnakeIndex: 1, Visits: 60, Parent: up}
+	down := &Node{Board: planTestBoard(Point{X: 5, Y: 6}, Point{X: 8, Y: 7}), SnakeIndex: 1, Visits: 28, Parent: up}
+	rare := &Node{Board: planTestBoard(Point{X: 5, Y: 6}, Point{X: 8, Y: 9}), SnakeIndex: 1, Visits: 2, Parent: up}
+	up.setChildren([]*Node{left, down, rare})
+
+	upAgain := &Node{Board: planTestBoard(Point{X: 5, Y: 7}, Point{X: 7, Y: 8}), SnakeIndex: 0, Visits: 50, Parent: left}
+	left.setChildren([]*Node{upAgain})
+
+	plan := buildPlan(root, 10)
+	if !assert.NotNil(t, plan) {
+		return
+	}
+	assert.Equal(t, []Direction{Up, Up}, plan.Moves)
+	assert.Equal(t, []Point{{X: 5, Y: 6}, {X: 5, Y: 7}}, plan.Region)
+	assert.ElementsMatch(t, []Point{{X: 7, Y: 8}, {X: 8, Y: 7}}, plan.ExpectedHeads["them"])
+
+	testCases := []struct {
+		Description string
+		Board       Board
+		Turn        int
+		Expected    Direction
+		OK          bool
+	}{
+		{"opponent played the main line", planTestBoard(Point{X: 5, Y: 6}, Point{X: 7, Y: 8}), 11, Up, true},
+		{"opponent played another expected reply", planTestBoard(Point{X: 5, Y: 6}, Point{X: 8, Y: 7}), 11, Up, true},
+		{"opponent surprised us", planTestBoard(Point{X: 5, Y: 6}, Point{X: 8, Y: 9}), 11, Unset, false},
+		{"plan is stale", planTestBoard(Point{X: 5, Y: 6}, Point{X: 7, Y: 8}), 12, Unset, false},
+		{"we didn't play the plan", planTestBoard(Point{X: 6, Y: 5}, Point{X: 7, Y: 8}), 11, Unset, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			move, ok := plan.NextMove(tc.Board, tc.Turn)
+			assert.Equal(t, tc.OK, ok)
+			assert.Equal(t, tc.Expected, move)
+		})
+	}
+}
+
+func TestBuildPlanAcrossWrappedEdge(t *testing.T) {
+	// we go left off the edge and come back on the right, then keep going left
+	wrapped := func(usHead Point) Board {
+		board := planTestBoard(usHead, Point{X: 8, Y: 8})
+		board.Wrapped = true
+		return board
+	}
+	root := &Node{Board: wrapped(Point{X: 0, Y: 5}), SnakeIndex: -1, Visits: 100}
+	left := &Node{Board: wrapped(Point{X: 10, Y: 5}), SnakeIndex: 0, Visits: 90, Parent: root}
+	root.setChildren([]*Node{left})
+	reply := &Node{Board: wrapped(Point{X: 10, Y: 5}), SnakeIndex: 1, Visits: 90, Parent: left}
+	left.setChildren([]*Node{reply})
+	leftAgain := &Node{Board: wrapped(Point{X: 9, Y: 5}), SnakeIndex: 0, Visits: 80, Parent: reply}
+	reply.setChildren([]*Node{leftAgain})
+
+	plan := buildPlan(root, 10)
+	if !assert.NotNil(t, plan) {
+		return
+	}
+	assert.Equal(t, []Direction{Left, Left}, plan.Moves)
+}
+
+func TestNilPlanHasNoMove(t *testing.T) {
+	var plan *Plan
+	_, ok := plan.NextMove(planTestBoard(Point{X: 1, Y: 1}, Point{X: 2, Y: 2}), 1)
+	assert.False(t, ok)
+}
+
+func TestBestChildWithBonusBreaksTies(t *testing.T) {
+	parent := &Node{Visits: 20}
+	child1 := &Node{Visits: 10, Score: 5.0, Parent: parent}
+	child2 := &Node{Visits: 10, Score: 5.0, Parent: parent}
+	parent.setChildren([]*Node{child1, child2})
+
+	assert.Equal(t, child1, bestChild(parent, 1.41))
+	bonus := func(child *Node) float64 {
+		if child == child2 {
+			return planBias
+		}
+		return 0
+	}
+	assert.Equal(t, child2, bestChildWithBonus(parent, 1.41, bonus))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PlaygroundMove is the search result for one of the root's moves.
+type PlaygroundMove struct {
+	Move     string  `json:"move"`
+	Visits   int64   `json:"visits"`
+	AvgScore float64 `json:"avg_score"`
+}
+
+// PlaygroundResult is what a what-if search returns to the browser.
+type PlaygroundResult struct {
+	Move    string           `json:"move"`
+	Visits  int64            `json:"visits"`
+	Moves   []PlaygroundMove `json:"moves"`
+	PV      []string         `json:"pv"`
+	Board   string           `json:"board"`
+	Voronoi string           `json:"voronoi"`
+}
+
+// analyseBoardJSON runs a bounded search on a pasted board, with our snake at index 0.
+// It's shared by the wasm playground and anything else that wants a one-off analysis.
+func analyseBoardJSON(boardJSON string, iterations int, timeout time.Duration) (PlaygroundResult, error) {
+	var board Board
+	if err := json.Unmarshal([]byte(boardJSON), &board); err != nil {
+		return PlaygroundResult{}, fmt.Errorf("failed to parse board: %w", err)
+	}
+	if len(board.Snakes) == 0 {
+		return PlaygroundResult{}, fmt.Errorf("board has no snakes")
+	}
+	for i := range board.Snakes {
+		if len(board.Snakes[i].Body) > 0 {
+			board.Snakes[i].Head = board.Snakes[i].Body[0]
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// the browser only has one thread so more workers don't help
+	root := MCTS(ctx, "playground", copyBoard(board), iterations, 1, make(map[string]*Node))
+
+	result := PlaygroundResult{
+		Move:    determineBestMove(root),
+		Visits:  root.Visits,
+		Board:   visualizeBoard(board),
+		Voronoi: VisualizeVoronoi(GenerateVoronoi(board), board.Snakes),
+	}
+	for _, child := range root.Children() {
+		move := PlaygroundMove{
+			Move:   determineMoveDirection(board.Snakes[0].Head, child.Board.Snakes[0].Head),
+			Visits: child.Visits,
+		}
+		if child.Visits > 0 {
+			move.AvgScore = child.Score / float64(child.Visits)
+		}
+		result.Moves = append(result.Moves, move)
+	}
+
+	previous := root
+	for _, node := range principalVariation(root) {
+		snake := node.Board.Snakes[node.SnakeIndex]
+		if !isSnakeDead(snake) {
+			head := previous.Board.Snakes[node.SnakeIndex].Head
+			result.PV = append(result.PV, fmt.Sprintf("%c %s", 'a'+node.SnakeIndex, determineMoveDirection(head, snake.Head)))
+		}
+		previous = node
+	}
+
+	return result, nil
+}
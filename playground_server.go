@@ -0,0 +1,8 @@
+//go:build !(js && wasm)
+
+package main
+
+// runPlayground is only available in the wasm build.
+func runPlayground() {
+	panic("the playground is only available when built with GOOS=js GOARCH=wasm")
+}
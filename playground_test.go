@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyseBoardJSON(t *testing.T) {
+	boardJSON := `{"height":7,"width":7,"food":[{"x":3,"y":3}],"hazards":[],"snakes":[{"id":"a","health":100,"body":[{"x":0,"y":1},{"x":0,"y":0}]},{"id":"b","health":100,"body":[{"x":5,"y":5},{"x":5,"y":6}]}]}`
+
+	result, err := analyseBoardJSON(boardJSON, 300, 5*time.Second)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(300), result.Visits)
+	assert.Contains(t, []string{"up", "right"}, result.Move, "left is a wall and down is our neck")
+	total := int64(0)
+	for _, move := range result.Moves {
+		total += move.Visits
+	}
+	assert.Equal(t, result.Visits, total, "every root visit comes through one of its moves")
+	assert.NotEmpty(t, result.PV)
+	assert.NotEmpty(t, result.Board)
+}
+
+func TestAnalyseBoardJSONRejectsBadInput(t *testing.T) {
+	_, err := analyseBoardJSON(`{"height":`, 10, time.Second)
+	assert.Error(t, err)
+
+	_, err = analyseBoardJSON(`{"height":7,"width":7,"snakes":[]}`, 10, time.Second)
+	assert.Error(t, err)
+}
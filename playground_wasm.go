@@ -0,0 +1,44 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"time"
+)
+
+// runPlayground exposes the engine to javascript instead of starting the server.
+// aisnakeAnalyse(boardJSON, iterations, timeoutMs) calls back with the result as a JSON string,
+// since a blocking call would freeze the page while the search runs.
+func runPlayground() {
+	js.Global().Set("aisnakeAnalyse", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 4 {
+			return "usage: aisnakeAnalyse(boardJSON, iterations, timeoutMs, callback)"
+		}
+		boardJSON := args[0].String()
+		iterations := args[1].Int()
+		timeout := time.Duration(args[2].Int()) * time.Millisecond
+		callback := args[3]
+
+		go func() {
+			result, err := analyseBoardJSON(boardJSON, iterations, timeout)
+			if err != nil {
+				callback.Invoke(err.Error(), js.Null())
+				return
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				callback.Invoke(err.Error(), js.Null())
+				return
+			}
+			callback.Invoke(js.Null(), string(data))
+		}()
+		return nil
+	}))
+
+	js.Global().Get("console").Call("log", "aisnake engine loaded")
+
+	// keep the module alive so the callback can be used
+	select {}
+}
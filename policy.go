@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// Policy gives the moves a snake could make from a board a prior probability each, in the same order as
+// the moves and adding up to 1. The search expands the likeliest moves first and PUCT spends its
+// exploration on them in proportion, so a good policy keeps the tree off moves nobody would play.
+type Policy interface {
+	Priors(board Board, snakeIndex int, moves []Direction) []float64
+}
+
+// policyFromEnv is the policy POLICY_PRIORS names, or nil to keep plain UCT. "heuristic" is the only one
+// so far; a learned policy would slot in beside it.
+func policyFromEnv() Policy {
+	return parsePolicy(os.Getenv("POLICY_PRIORS"))
+}
+
+// parsePolicy is the policy the name picks, or nil for plain UCT.
+func parsePolicy(name string) Policy {
+	switch name {
+	case "heuristic":
+		return heuristicPolicy{}
+	}
+	return nil
+}
+
+// How much each thing the heuristic policy notices shifts a move's logit.
+const (
+	policyEatBonus      = 2.0
+	policyFoodBonus     = 1.0
+	policyWallPenalty   = 0.5
+	policyHazardPenalty = 1.0
+)
+
+// heuristicPolicy is a cheap stand in for a learned policy: moves towards food, and onto it most of
+// all, are likelier, and moves along a wall or into a hazard less so.
+type heuristicPolicy struct{}
+
+func (heuristicPolicy) Priors(board Board, snakeIndex int, moves []Direction) []float64 {
+	logits := make([]float64, len(moves))
+	if snakeIndex < 0 || snakeIndex >= len(board.Snakes) || isSnakeDead(board.Snakes[snakeIndex]) {
+		return softmax(logits)
+	}
+	head := board.Snakes[snakeIndex].Head
+	before := nearestFoodDistance(&board, head)
+	hazards := make(map[Point]bool, len(board.Hazards))
+	for _, hazard := range board.Hazards {
+		hazards[hazard] = true
+	}
+
+	for i, move := range moves {
+		next := moveOnBoard(&board, head, move)
+		after := nearestFoodDistance(&board, next)
+		switch {
+		case after == 0:
+			logits[i] += policyEatBonus
+		case before >= 0 && after < before:
+			logits[i] += policyFoodBonus
+		}
+		if !board.Wrapped && (next.X == 0 || next.Y == 0 || next.X == board.Width-1 || next.Y == board.Height-1) {
+			logits[i] -= policyWallPenalty
+		}
+		if hazards[next] {
+			logits[i] -= policyHazardPenalty
+		}
+	}
+	return softmax(logits)
+}
+
+// softmax turns logits into probabilities.
+func softmax(logits []float64) []float64 {
+	probabilities := make([]float64, len(logits))
+	if len(logits) == 0 {
+		return probabilities
+	}
+	highest := logits[0]
+	for _, logit := range logits {
+		highest = math.Max(highest, logit)
+	}
+	total := 0.0
+	for i, logit := range logits {
+		probabilities[i] = math.Exp(logit - highest)
+		total += probabilities[i]
+	}
+	for i := range probabilities {
+		probabilities[i] /= total
+	}
+	return probabilities
+}
+
+// applyPolicy gives the node's moves their priors and sorts them likeliest first. It has to happen before
+// the node is published or searched, since it reorders the moves still to expand.
+func (c SearchConfig) applyPolicy(node *Node) {
+	if c.Policy == nil || len(node.moves) == 0 || node.chance {
+		return
+	}
+	next := (node.SnakeIndex + 1) % len(node.Board.Snakes)
+	priors := c.Policy.Priors(node.Board, next, node.moves)
+	if len(priors) != len(node.moves) {
+		return
+	}
+	node.priors = make([]float64, Right+1)
+	for i, move := range node.moves {
+		node.priors[move] = priors[i]
+	}
+	sort.SliceStable(node.moves, func(i, j int) bool {
+		return node.priors[node.moves[i]] > node.priors[node.moves[j]]
+	})
+}
+
+// priorOf is the prior the node's policy gave the move, or 0 if it hasn't got one.
+func (n *Node) priorOf(move Direction) float64 {
+	if int(move) >= len(n.priors) {
+		return 0
+	}
+	return n.priors[move]
+}
+
+// puctWithLoss is the AlphaZero style PUCT value, Q + c * P * sqrt(N) / (1 + n), with the same virtual
+// loss as uctWithLoss. A node without a prior, from before the policy was on or restored without one,
+// shares the parent's prior evenly with its siblings.
+func (n *Node) puctWithLoss(explorationParam float64, virtualLoss float64) float64 {
+	visits := atomic.LoadInt64(&n.Visits)
+	score := loadFloat64(&n.Score)
+	var parentInFlight int64
+	if virtualLoss > 0 {
+		inFlight := int64(atomic.LoadInt32(&n.inFlight))
+		visits += inFlight
+		score -= float64(inFlight) * virtualLoss
+		parentInFlight = int64(atomic.LoadInt32(&n.Parent.inFlight))
+	}
+	if visits == 0 {
+		return math.MaxFloat64
+	}
+
+	prior := n.Prior
+	if prior <= 0 {
+		prior = 1 / float64(max(1, len(n.Parent.Children())))
+	}
+	parentVisits := atomic.LoadInt64(&n.Parent.Visits) + parentInFlight
+	exploitation := score / float64(visits)
+	exploration := explorationParam * prior * math.Sqrt(float64(parentVisits)) / float64(1+visits)
+
+	return exploitation + exploration
+}
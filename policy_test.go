@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("POLICY_PRIORS", "")
+	assert.Nil(t, policyFromEnv())
+	t.Setenv("POLICY_PRIORS", "heuristic")
+	assert.Equal(t, heuristicPolicy{}, policyFromEnv())
+	t.Setenv("POLICY_PRIORS", "learned")
+	assert.Nil(t, policyFromEnv(), "an unknown policy keeps plain UCT")
+}
+
+func TestHeuristicPolicyPriors(t *testing.T) {
+	board := Board{
+		Width: 7, Height: 7,
+		Food:    []Point{{X: 3, Y: 5}},
+		Hazards: []Point{{X: 4, Y: 3}},
+		Snakes: []Snake{
+			{ID: "us", Health: 80, Head: Point{X: 3, Y: 3}, Body: []Point{{X: 3, Y: 3}, {X: 3, Y: 2}, {X: 3, Y: 1}}},
+			{ID: "them", Health: 80, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+		},
+	}
+	moves := []Direction{Up, Left, Right}
+	priors := heuristicPolicy{}.Priors(board, 0, moves)
+	require.Len(t, priors, 3)
+	assert.InDelta(t, 1, priors[0]+priors[1]+priors[2], 1e-9)
+	assert.Greater(t, priors[0], priors[1], "towards the food")
+	assert.Greater(t, priors[1], priors[2], "the hazard is worse than nothing")
+
+	board.Food = []Point{{X: 3, Y: 4}}
+	eating := heuristicPolicy{}.Priors(board, 0, moves)
+	assert.Greater(t, eating[0], priors[0], "onto the food beats towards it")
+
+	// them at the corner, with one way along the wall and one way off it
+	board.Food = nil
+	board.Snakes[1] = Snake{ID: "them", Health: 80, Head: Point{X: 1, Y: 0}, Body: []Point{{X: 1, Y: 0}, {X: 0, Y: 0}}}
+	walls := heuristicPolicy{}.Priors(board, 1, []Direction{Up, Right})
+	assert.Greater(t, walls[0], walls[1], "off the wall")
+}
+
+func TestApplyPolicyOrdersMoves(t *testing.T) {
+	board := evalTestBoard()
+	board.Snakes = board.Snakes[:2]
+	node := NewNode(board, -1, nil)
+	moves := append([]Direction(nil), node.UnexpandedMoves()...)
+	require.Greater(t, len(moves), 1)
+
+	SearchConfig{}.applyPolicy(node)
+	assert.Nil(t, node.priors, "no policy, no priors")
+	assert.Equal(t, moves, node.UnexpandedMoves())
+
+	SearchConfig{Policy: heuristicPolicy{}}.applyPolicy(node)
+	ordered := node.UnexpandedMoves()
+	assert.ElementsMatch(t, moves, ordered)
+	total := 0.0
+	for i, move := range ordered {
+		total += node.priorOf(move)
+		if i > 0 {
+			assert.GreaterOrEqual(t, node.priorOf(ordered[i-1]), node.priorOf(move), "likeliest first")
+		}
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+	assert.Zero(t, node.priorOf(Unset))
+}
+
+func TestPUCTWithVirtualLoss(t *testing.T) {
+	parent := &Node{Visits: 16}
+	likely := &Node{Parent: parent, Visits: 3, Score: 1.5, Prior: 0.6}
+	unlikely := &Node{Parent: parent, Visits: 3, Score: 1.5, Prior: 0.1}
+	parent.setChildren([]*Node{likely, unlikely})
+
+	assert.InDelta(t, 0.5+1.41*0.6*4/4, likely.puctWithLoss(1.41, 0), 1e-9)
+	assert.Greater(t, likely.puctWithLoss(1.41, 0), unlikely.puctWithLoss(1.41, 0))
+
+	// without a prior the children share it evenly
+	unlikely.Prior = 0
+	assert.InDelta(t, 0.5+1.41*0.5*4/4, unlikely.puctWithLoss(1.41, 0), 1e-9)
+
+	likely.inFlight = 1
+	parent.inFlight = 1
+	want := (1.5-1)/4 + 1.41*0.6*math.Sqrt(17)/5
+	assert.InDelta(t, want, likely.puctWithLoss(1.41, 1), 1e-9)
+	assert.Equal(t, math.MaxFloat64, (&Node{Parent: parent}).puctWithLoss(1.41, 1))
+}
+
+func TestPolicySearchFollowsPriors(t *testing.T) {
+	board := evalTestBoard()
+	board.Snakes = board.Snakes[:2]
+	config := SearchConfig{Policy: heuristicPolicy{}}
+	root := MCTS(context.Background(), "policy", board, 300, 1, make(map[string]*Node), WithSearchConfig(config))
+
+	require.NotEmpty(t, root.Children())
+	total := 0.0
+	for _, child := range root.Children() {
+		assert.Greater(t, child.Prior, 0.0)
+		total += child.Prior
+		for _, grandchild := range child.Children() {
+			assert.Greater(t, grandchild.Prior, 0.0, "priors all the way down")
+		}
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// populationEliteShare is the share of each generation, one in this many, that carries on unchanged
+	populationEliteShare = 4
+	// populationCheckpointPrefix is where training runs keep their generations in the bucket
+	populationCheckpointPrefix = "training"
+)
+
+// PopulationMember is one set of weights in a training run, with how it did in its generation's round robin.
+type PopulationMember struct {
+	ID      string        `json:"id"`
+	Weights ModuleWeights `json:"weights"`
+	Parents []string      `json:"parents,omitempty"`
+	// Score is the sum of its match scores, each from -1 to 1
+	Score   float64 `json:"score"`
+	Matches int     `json:"matches"`
+}
+
+// average is the member's mean match score, 0 before it's played.
+func (m PopulationMember) average() float64 {
+	if m.Matches == 0 {
+		return 0
+	}
+	return m.Score / float64(m.Matches)
+}
+
+// Generation is a population once its round robin has been played, the unit training checkpoints in.
+type Generation struct {
+	Run      string             `json:"run"`
+	Number   int                `json:"number"`
+	Time     time.Time          `json:"time"`
+	Members  []PopulationMember `json:"members"`
+	Champion string             `json:"champion"`
+}
+
+// champion is the member with the best average score.
+func (g *Generation) champion() (PopulationMember, bool) {
+	for _, member := range g.Members {
+		if member.ID == g.Champion {
+			return member, true
+		}
+	}
+	return PopulationMember{}, false
+}
+
+// CheckpointStore keeps every generation of a training run so a run can pick up where it stopped.
+type CheckpointStore interface {
+	// Latest returns the run's most recent generation and whether it has one.
+	Latest(ctx context.Context, run string) (*Generation, bool, error)
+	// Save stores the generation and makes it the run's latest.
+	Save(ctx context.Context, generation *Generation) error
+}
+
+// bucketCheckpointStore keeps generations as objects in our bucket, one per generation plus a copy of
+// the latest so resuming doesn't need to list the run.
+type bucketCheckpointStore struct{}
+
+func checkpointObject(run string, number int) string {
+	return fmt.Sprintf("%s/%s/generation-%04d.json", populationCheckpointPrefix, run, number)
+}
+
+func latestCheckpointObject(run string) string {
+	return fmt.Sprintf("%s/%s/latest.json", populationCheckpointPrefix, run)
+}
+
+func (bucketCheckpointStore) Latest(ctx context.Context, run string) (*Generation, bool, error) {
+	data, err := downloadFromBucket(ctx, latestCheckpointObject(run))
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	generation := &Generation{}
+	if err := json.Unmarshal(data, generation); err != nil {
+		return nil, false, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	return generation, true, nil
+}
+
+func (bucketCheckpointStore) Save(ctx context.Context, generation *Generation) error {
+	data, err := json.MarshalIndent(generation, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := uploadToBucket(ctx, checkpointObject(generation.Run, generation.Number), "application/json", data); err != nil {
+		return err
+	}
+	return uploadToBucket(ctx, latestCheckpointObject(generation.Run), "application/json", data)
+}
+
+// trainConfig holds the settings for a population training run.
+type trainConfig struct {
+	run         string
+	population  int
+	generations int
+	mutation    float64
+	seed        int64
+	// how each pair's match is played
+	match tuneConfig
+}
+
+// memberMatch plays two members' weights against each other and returns the first's score from -1 to 1.
+type memberMatch func(first, second ModuleWeights, seed int64) (float64, error)
+
+// runTrain evolves a population of weights, checkpointing every generation to the bucket, or promotes
+// a run's champion to a weights file for EVAL_WEIGHTS.
+// Usage: main train -run name [-population 8] [-generations 10] [-games 2] [-visits 300]
+//
+//	main train promote -run name [-out weights.json]
+func runTrain(args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "promote" {
+		return runPromote(context.Background(), args[1:], out, bucketCheckpointStore{})
+	}
+
+	flags := flag.NewFlagSet("train", flag.ContinueOnError)
+	flags.SetOutput(out)
+	cfg := trainConfig{}
+	flags.StringVar(&cfg.run, "run", "", "name of the training run, to resume it or start it")
+	flags.IntVar(&cfg.population, "population", 8, "weight sets in each generation")
+	flags.IntVar(&cfg.generations, "generations", 10, "generations to play this time, on top of any already checkpointed")
+	flags.Float64Var(&cfg.mutation, "mutation", 0.2, "standard deviation of a mutation, in log weight")
+	flags.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "seed for breeding and starting positions")
+	flags.IntVar(&cfg.match.games, "games", 2, "games each pair plays, swapping seats")
+	flags.IntVar(&cfg.match.visits, "visits", 300, "visits each side searches per move")
+	flags.IntVar(&cfg.match.size, "size", 11, "board width and height")
+	flags.IntVar(&cfg.match.turns, "turns", 200, "turns before a game is called a draw")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cfg.run == "" {
+		return errors.New("a training run needs a name")
+	}
+	if cfg.population < 2 || cfg.match.games <= 0 {
+		return errors.New("need at least two members and one game")
+	}
+
+	// the search logs every tree it looks up, which would drown out the progress
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	start, err := tunableModules(modules)
+	if err != nil {
+		return err
+	}
+	match := func(first, second ModuleWeights, seed int64) (float64, error) {
+		a, errA := withWeights(start, first)
+		b, errB := withWeights(start, second)
+		if err := errors.Join(errA, errB); err != nil {
+			// members are only ever bred from the same modules, so this is a checkpoint from another build
+			return 0, err
+		}
+		return playTuneMatch(cfg.match, a, b, seed), nil
+	}
+	_, err = trainPopulation(context.Background(), cfg, weightsOf(start), bucketCheckpointStore{}, match, out)
+	return err
+}
+
+// trainPopulation plays the configured number of generations, carrying on from the run's latest
+// checkpoint if it has one and starting from mutations of the seed weights if not. It returns the last
+// generation played.
+func trainPopulation(ctx context.Context, cfg trainConfig, seed ModuleWeights, store CheckpointStore, match memberMatch, out io.Writer) (*Generation, error) {
+	latest, ok, err := store.Latest(ctx, cfg.run)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []PopulationMember
+	number := 0
+	if ok {
+		number = latest.Number + 1
+		members = breed(latest, cfg, rand.New(rand.NewSource(cfg.seed+int64(number))))
+		fmt.Fprintf(out, "resuming %s at generation %d, champion so far %s\n", cfg.run, number, latest.Champion)
+	} else {
+		members = seedPopulation(seed, cfg, rand.New(rand.NewSource(cfg.seed)))
+	}
+
+	for played := 0; played < cfg.generations; played++ {
+		generation := &Generation{Run: cfg.run, Number: number, Members: members}
+		if err := playRoundRobin(generation, match, cfg.seed+int64(number)*1000); err != nil {
+			return nil, fmt.Errorf("failed to play generation %d: %w", number, err)
+		}
+		generation.Time = time.Now()
+		if err := store.Save(ctx, generation); err != nil {
+			return nil, fmt.Errorf("failed to checkpoint generation %d: %w", number, err)
+		}
+		describeGeneration(out, generation)
+
+		latest = generation
+		number++
+		members = breed(generation, cfg, rand.New(rand.NewSource(cfg.seed+int64(number))))
+	}
+	return latest, nil
+}
+
+// seedPopulation is the first generation: the seed weights themselves, so training can't end up worse
+// than where it started without noticing, and mutations of them.
+func seedPopulation(seed ModuleWeights, cfg trainConfig, rng *rand.Rand) []PopulationMember {
+	members := []PopulationMember{{ID: "g0-0", Weights: seed}}
+	for i := 1; i < cfg.population; i++ {
+		members = append(members, PopulationMember{ID: fmt.Sprintf("g0-%d", i), Weights: mutate(seed, cfg.mutation, rng)})
+	}
+	return members
+}
+
+// playRoundRobin has every pair of members play a match and adds the scores up on both sides.
+func playRoundRobin(generation *Generation, match memberMatch, seed int64) error {
+	members := generation.Members
+	for i := range members {
+		members[i].Score, members[i].Matches = 0, 0
+	}
+	for i := 0; i < len(members); i++ {
+		for j := i + 1; j < len(members); j++ {
+			score, err := match(members[i].Weights, members[j].Weights, seed)
+			if err != nil {
+				return err
+			}
+			seed++
+			members[i].Score += score
+			members[j].Score -= score
+			members[i].Matches++
+			members[j].Matches++
+		}
+	}
+
+	best := 0
+	for i := range members {
+		if members[i].average() > members[best].average() {
+			best = i
+		}
+	}
+	generation.Champion = members[best].ID
+	return nil
+}
+
+// breed makes the next generation from a played one. The best quarter carry on as they are and the rest
+// are children of two of them: each weight taken from one parent or the other, then mutated.
+func breed(generation *Generation, cfg trainConfig, rng *rand.Rand) []PopulationMember {
+	ranked := append([]PopulationMember(nil), generation.Members...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].average() > ranked[j].average() })
+	elite := max(1, len(ranked)/populationEliteShare)
+
+	number := generation.Number + 1
+	var members []PopulationMember
+	for _, parent := range ranked[:elite] {
+		members = append(members, PopulationMember{ID: parent.ID, Weights: parent.Weights, Parents: parent.Parents})
+	}
+	for len(members) < cfg.population {
+		a, b := ranked[rng.Intn(elite)], ranked[rng.Intn(elite)]
+		child := make(ModuleWeights, len(a.Weights))
+		for name, weight := range a.Weights {
+			if other, ok := b.Weights[name]; ok && rng.Intn(2) == 0 {
+				weight = other
+			}
+			child[name] = weight
+		}
+		members = append(members, PopulationMember{
+			ID:      fmt.Sprintf("g%d-%d", number, len(members)),
+			Weights: mutate(child, cfg.mutation, rng),
+			Parents: []string{a.ID, b.ID},
+		})
+	}
+	return members
+}
+
+// mutate scales every weight by a random factor, log normally distributed, and then scales them all back
+// to the total they had, since only their ratios matter.
+func mutate(weights ModuleWeights, sigma float64, rng *rand.Rand) ModuleWeights {
+	mutated := make(ModuleWeights, len(weights))
+	total, sum := 0.0, 0.0
+	for name, weight := range weights {
+		total += weight
+		mutated[name] = weight * math.Exp(sigma*rng.NormFloat64())
+		sum += mutated[name]
+	}
+	if sum > 0 {
+		for name := range mutated {
+			mutated[name] *= total / sum
+		}
+	}
+	return mutated
+}
+
+func describeGeneration(out io.Writer, generation *Generation) {
+	ranked := append([]PopulationMember(nil), generation.Members...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].average() > ranked[j].average() })
+	fmt.Fprintf(out, "generation %d of %s, champion %s\n", generation.Number, generation.Run, generation.Champion)
+	for _, member := range ranked {
+		fmt.Fprintf(out, "  %-10s %+.2f over %d matches  %s\n", member.ID, member.average(), member.Matches, describeModuleWeights(member.Weights))
+	}
+}
+
+func describeModuleWeights(weights ModuleWeights) string {
+	modules := make([]EvaluationModule, 0, len(weights))
+	for name, weight := range weights {
+		modules = append(modules, EvaluationModule{Name: name, Weight: weight})
+	}
+	return describeWeights(modules)
+}
+
+// runPromote writes the champion of a run's latest generation to a weights file, ready for EVAL_WEIGHTS.
+func runPromote(ctx context.Context, args []string, out io.Writer, store CheckpointStore) error {
+	flags := flag.NewFlagSet("promote", flag.ContinueOnError)
+	flags.SetOutput(out)
+	run := flags.String("run", "", "name of the training run to promote the champion of")
+	path := flags.String("out", "weights.json", "weights file to write the champion to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	generation, ok, err := store.Latest(ctx, *run)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("training run %q has no checkpoints", *run)
+	}
+	champion, ok := generation.champion()
+	if !ok {
+		return fmt.Errorf("generation %d of %q has no champion", generation.Number, *run)
+	}
+	if _, err := withWeights(modules, champion.Weights); err != nil {
+		return fmt.Errorf("champion doesn't fit this build: %w", err)
+	}
+	if err := champion.Weights.Save(*path); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "promoted %s from generation %d of %s to %s: %s\n", champion.ID, generation.Number, *run, *path, describeModuleWeights(champion.Weights))
+	return nil
+}
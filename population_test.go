@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCheckpointStore keeps every generation it's given, by run.
+type memoryCheckpointStore struct {
+	runs map[string][]*Generation
+}
+
+func newMemoryCheckpointStore() *memoryCheckpointStore {
+	return &memoryCheckpointStore{runs: make(map[string][]*Generation)}
+}
+
+func (s *memoryCheckpointStore) Latest(ctx context.Context, run string) (*Generation, bool, error) {
+	generations := s.runs[run]
+	if len(generations) == 0 {
+		return nil, false, nil
+	}
+	return generations[len(generations)-1], true, nil
+}
+
+func (s *memoryCheckpointStore) Save(ctx context.Context, generation *Generation) error {
+	s.runs[generation.Run] = append(s.runs[generation.Run], generation)
+	return nil
+}
+
+// voronoiLover wins every match for whichever side leans harder on voronoi.
+func voronoiLover(first, second ModuleWeights, seed int64) (float64, error) {
+	share := func(weights ModuleWeights) float64 {
+		total := 0.0
+		for _, weight := range weights {
+			total += weight
+		}
+		return weights["voronoi"] / total
+	}
+	switch {
+	case share(first) > share(second):
+		return 1, nil
+	case share(first) < share(second):
+		return -1, nil
+	}
+	return 0, nil
+}
+
+func TestTrainPopulationCheckpointsAndResumes(t *testing.T) {
+	store := newMemoryCheckpointStore()
+	seed := ModuleWeights{"voronoi": 6, "length": 6, "trapped": 8}
+	cfg := trainConfig{run: "test", population: 8, generations: 3, mutation: 0.3, seed: 1}
+
+	var out bytes.Buffer
+	last, err := trainPopulation(context.Background(), cfg, seed, store, voronoiLover, &out)
+	require.NoError(t, err)
+	require.Len(t, store.runs["test"], 3)
+	for i, generation := range store.runs["test"] {
+		assert.Equal(t, i, generation.Number)
+		assert.Len(t, generation.Members, 8)
+		_, ok := generation.champion()
+		assert.True(t, ok, "generation %d has a champion", i)
+	}
+	assert.Equal(t, seed, store.runs["test"][0].Members[0].Weights, "the seed weights are in the first generation")
+
+	cfg.generations = 2
+	resumed, err := trainPopulation(context.Background(), cfg, seed, store, voronoiLover, &out)
+	require.NoError(t, err)
+	assert.Equal(t, last.Number+2, resumed.Number, "resuming carries on from the checkpoint")
+	assert.Contains(t, out.String(), "resuming test at generation 3")
+
+	champion, _ := resumed.champion()
+	assert.Greater(t, champion.Weights["voronoi"], seed["voronoi"], "the population drifts towards voronoi")
+}
+
+func TestBreedKeepsTheEliteAndFillsThePopulation(t *testing.T) {
+	generation := &Generation{Number: 4}
+	for i := 0; i < 8; i++ {
+		generation.Members = append(generation.Members, PopulationMember{
+			ID:      string(rune('a' + i)),
+			Weights: ModuleWeights{"voronoi": float64(i + 1), "length": 1},
+			Score:   float64(i),
+			Matches: 7,
+		})
+	}
+
+	members := breed(generation, trainConfig{population: 8, mutation: 0.1}, rand.New(rand.NewSource(1)))
+	require.Len(t, members, 8)
+	assert.Equal(t, "h", members[0].ID, "the best carries on")
+	assert.Equal(t, "g", members[1].ID)
+	assert.Zero(t, members[0].Matches, "scores start again each generation")
+	for _, child := range members[2:] {
+		assert.Contains(t, child.ID, "g5-")
+		require.Len(t, child.Parents, 2)
+		for _, parent := range child.Parents {
+			assert.Contains(t, []string{"g", "h"}, parent, "children come from the elite")
+		}
+	}
+}
+
+func TestMutateKeepsTheTotal(t *testing.T) {
+	weights := ModuleWeights{"voronoi": 6, "length": 6, "trapped": 8}
+	mutated := mutate(weights, 0.5, rand.New(rand.NewSource(3)))
+	total := 0.0
+	for _, weight := range mutated {
+		assert.Greater(t, weight, 0.0)
+		total += weight
+	}
+	assert.InDelta(t, 20, total, 1e-9)
+	assert.NotEqual(t, weights, mutated)
+	assert.Equal(t, 6.0, weights["voronoi"], "the original is left alone")
+}
+
+func TestPromoteWritesTheChampion(t *testing.T) {
+	store := newMemoryCheckpointStore()
+	champion := ModuleWeights{"voronoi": 9, "length": 4, "trapped": 7}
+	require.NoError(t, store.Save(context.Background(), &Generation{
+		Run:      "test",
+		Number:   2,
+		Champion: "g2-1",
+		Members: []PopulationMember{
+			{ID: "g2-0", Weights: ModuleWeights{"voronoi": 1, "length": 1, "trapped": 1}},
+			{ID: "g2-1", Weights: champion},
+		},
+	}))
+
+	path := filepath.Join(t.TempDir(), "weights.json")
+	var out bytes.Buffer
+	require.NoError(t, runPromote(context.Background(), []string{"-run", "test", "-out", path}, &out, store))
+	written, err := loadModuleWeights(path)
+	require.NoError(t, err)
+	assert.Equal(t, champion, written)
+
+	assert.Error(t, runPromote(context.Background(), []string{"-run", "missing", "-out", path}, &out, store))
+}
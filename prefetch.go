@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// prefetchCandidates is how many of the likely next boards get searched while we wait
+	prefetchCandidates = 3
+	// prefetchMinShare is the least share of the saved visits a board needs to be worth searching ahead
+	prefetchMinShare = 0.1
+	// prefetchSlice is how long each stretch of searching ahead runs before checking whether a real search needs the cpu
+	prefetchSlice = 20 * time.Millisecond
+	// prefetchIdleWait is how long searching ahead backs off while another game is searching
+	prefetchIdleWait = 5 * time.Millisecond
+)
+
+// prefetchEnabled turns on searching ahead between moves. Cloud run only gives us cpu while a request is in
+// flight unless it's set to always allocate, so it's only worth it on instances that do.
+var prefetchEnabled = os.Getenv("PREFETCH") == "true"
+
+// likelyReplies picks the saved boards the opponents are most likely to leave us, going by the visits
+// the search gave them. Boards with too small a share aren't worth the cpu.
+func likelyReplies(states map[string]*Node, n int) []*Node {
+	var total int64
+	candidates := make([]*Node, 0, len(states))
+	for _, node := range states {
+		total += atomic.LoadInt64(&node.Visits)
+		candidates = append(candidates, node)
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return atomic.LoadInt64(&candidates[i].Visits) > atomic.LoadInt64(&candidates[j].Visits)
+	})
+
+	var likely []*Node
+	for _, node := range candidates {
+		if len(likely) == n || float64(atomic.LoadInt64(&node.Visits)) < prefetchMinShare*float64(total) {
+			break
+		}
+		likely = append(likely, node)
+	}
+	return likely
+}
+
+// Prefetcher searches the likely next boards between our response and the next request, so whichever one
+// the opponents pick already has a deeper tree under it when the search picks it up as the root.
+type Prefetcher struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	added  map[*Node]int64 // visits each board got from searching ahead
+}
+
+// Start searches ahead on the likely boards until the limit, the context ending or Stop, whichever comes first.
+// Searching ahead steps aside whenever another game's search is running.
+func (p *Prefetcher) Start(parent context.Context, states map[string]*Node, config SearchConfig, scheduler *SearchScheduler, limit time.Duration) {
+	p.Stop()
+	likely := likelyReplies(states, prefetchCandidates)
+	if len(likely) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, limit)
+	done := make(chan struct{})
+	added := make(map[*Node]int64, len(likely))
+	p.mu.Lock()
+	p.cancel, p.done, p.added = cancel, done, added
+	p.mu.Unlock()
+
+	// the boards are cut loose from this turn's tree like they would be as next turn's root,
+	// so searching ahead doesn't pile visits onto a tree nobody will look at again
+	for _, node := range likely {
+		node.Parent = nil
+	}
+	opts := &searchOptions{preferredMove: Unset, config: config}
+
+	go func() {
+		defer close(done)
+		prior := make([]int64, len(likely))
+		for i, node := range likely {
+			prior[i] = atomic.LoadInt64(&node.Visits)
+		}
+		for ctx.Err() == nil {
+			if scheduler.Busy() {
+				select {
+				case <-ctx.Done():
+				case <-time.After(prefetchIdleWait):
+				}
+				continue
+			}
+
+			// share the time out by how likely each board is
+			next, best := 0, -1.0
+			for i, node := range likely {
+				p.mu.Lock()
+				priority := float64(prior[i]) / float64(1+added[node])
+				p.mu.Unlock()
+				if priority > best {
+					next, best = i, priority
+				}
+			}
+
+			node := likely[next]
+			before := atomic.LoadInt64(&node.Visits)
+			slice, cancelSlice := context.WithTimeout(ctx, prefetchSlice)
+			searchFrom(slice, "prefetch", node, math.MaxInt, 1, opts)
+			cancelSlice()
+
+			p.mu.Lock()
+			added[node] += atomic.LoadInt64(&node.Visits) - before
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// Stop ends searching ahead and waits for it to finish with the tree.
+func (p *Prefetcher) Stop() {
+	p.mu.Lock()
+	cancel, done := p.cancel, p.done
+	p.cancel, p.done = nil, nil
+	p.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Added is how many visits searching ahead gave the node.
+func (p *Prefetcher) Added(node *Node) int64 {
+	if node == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.added[node]
+}
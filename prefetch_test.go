@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLikelyReplies(t *testing.T) {
+	a := &Node{Visits: 60}
+	b := &Node{Visits: 25}
+	c := &Node{Visits: 10}
+	d := &Node{Visits: 5}
+	states := map[string]*Node{"a": a, "b": b, "c": c, "d": d}
+
+	testCases := []struct {
+		Description string
+		States      map[string]*Node
+		N           int
+		Want        []*Node
+	}{
+		{Description: "most visited first, unlikely ones left out", States: states, N: 3, Want: []*Node{a, b, c}},
+		{Description: "only as many as asked for", States: states, N: 1, Want: []*Node{a}},
+		{Description: "nothing saved", States: map[string]*Node{}, N: 3},
+		{Description: "nothing searched", States: map[string]*Node{"x": {}}, N: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, tc.Want, likelyReplies(tc.States, tc.N))
+		})
+	}
+}
+
+// prefetchStates searches the board and saves the replies to our move like handleMove does.
+func prefetchStates(t *testing.T) map[string]*Node {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	board := sessionTestGame("prefetch").Board
+	root := MCTS(ctx, "prefetch", board, 2000, 1, make(map[string]*Node))
+	states, _ := reuseTree(chooseRootChild(root).Node, treeReuseNodes)
+	require.NotEmpty(t, states)
+	return states
+}
+
+func TestPrefetcherSearchesLikelyBoards(t *testing.T) {
+	states := prefetchStates(t)
+	likely := likelyReplies(states, prefetchCandidates)
+	require.NotEmpty(t, likely)
+	before := make([]int64, len(likely))
+	for i, node := range likely {
+		before[i] = node.Visits
+	}
+
+	prefetcher := &Prefetcher{}
+	prefetcher.Start(context.Background(), states, defaultSearchConfig, newSearchScheduler(1), time.Second)
+	time.Sleep(100 * time.Millisecond)
+	prefetcher.Stop()
+
+	for i, node := range likely {
+		assert.Nil(t, node.Parent, "cut loose from last turn's tree")
+		assert.Equal(t, before[i]+prefetcher.Added(node), node.Visits)
+	}
+	assert.Positive(t, prefetcher.Added(likely[0]), "the most likely board gets searched")
+	assert.Zero(t, prefetcher.Added(nil))
+
+	// stopping again or without starting is harmless
+	prefetcher.Stop()
+	(&Prefetcher{}).Stop()
+}
+
+func TestPrefetcherStepsAsideForRealSearches(t *testing.T) {
+	states := prefetchStates(t)
+	likely := likelyReplies(states, prefetchCandidates)
+	require.NotEmpty(t, likely)
+	before := likely[0].Visits
+
+	scheduler := newSearchScheduler(1)
+	ticket := scheduler.Register("real", time.Now().Add(time.Second))
+	prefetcher := &Prefetcher{}
+	prefetcher.Start(context.Background(), states, defaultSearchConfig, scheduler, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, before, likely[0].Visits, "nothing searched ahead while another game searches")
+
+	scheduler.Release(ticket)
+	assert.Eventually(t, func() bool {
+		prefetcher.mu.Lock()
+		defer prefetcher.mu.Unlock()
+		return prefetcher.added[likely[0]] > 0
+	}, time.Second, 5*time.Millisecond)
+	prefetcher.Stop()
+}
+
+func TestPrefetcherStopsAtItsLimit(t *testing.T) {
+	prefetcher := &Prefetcher{}
+	prefetcher.Start(context.Background(), prefetchStates(t), defaultSearchConfig, newSearchScheduler(1), 30*time.Millisecond)
+
+	prefetcher.mu.Lock()
+	done := prefetcher.done
+	prefetcher.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("searching ahead outlived its limit")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// rank change between snapshots that's worth a message
+	rankAlertMove = 5
+	// entering or leaving the top this many is always worth a message
+	rankAlertTopN = 10
+	// the only competition we can scrape at the moment
+	duelsCompetition = "duels"
+)
+
+// RankSnapshot is our standing in a competition at a point in time.
+type RankSnapshot struct {
+	Time        time.Time `json:"time"`
+	Competition string    `json:"competition"`
+	Rank        int       `json:"rank"`
+	Score       int       `json:"score"`
+}
+
+// RankHistory is every snapshot taken for a competition, oldest first.
+type RankHistory struct {
+	Snapshots []RankSnapshot `json:"snapshots"`
+}
+
+func rankHistoryObject(competition string) string {
+	return fmt.Sprintf("rank-history/%s.json", competition)
+}
+
+// Latest returns the most recent snapshot.
+func (h *RankHistory) Latest() (RankSnapshot, bool) {
+	if len(h.Snapshots) == 0 {
+		return RankSnapshot{}, false
+	}
+	return h.Snapshots[len(h.Snapshots)-1], true
+}
+
+// rankAlert describes the move from prev to next, or returns an empty string if it isn't worth mentioning.
+func rankAlert(prev, next RankSnapshot) string {
+	wasTop := prev.Rank <= rankAlertTopN
+	isTop := next.Rank <= rankAlertTopN
+	switch {
+	case isTop && !wasTop:
+		return fmt.Sprintf("🏆 into the %s top %d: rank %d (was %d), score %d", next.Competition, rankAlertTopN, next.Rank, prev.Rank, next.Score)
+	case wasTop && !isTop:
+		return fmt.Sprintf("📉 out of the %s top %d: rank %d (was %d), score %d", next.Competition, rankAlertTopN, next.Rank, prev.Rank, next.Score)
+	case prev.Rank-next.Rank >= rankAlertMove:
+		return fmt.Sprintf("📈 up %d in %s to rank %d, score %d", prev.Rank-next.Rank, next.Competition, next.Rank, next.Score)
+	case next.Rank-prev.Rank >= rankAlertMove:
+		return fmt.Sprintf("📉 down %d in %s to rank %d, score %d", next.Rank-prev.Rank, next.Competition, next.Rank, next.Score)
+	}
+	return ""
+}
+
+func loadRankHistory(ctx context.Context, competition string) (*RankHistory, error) {
+	history := &RankHistory{}
+	data, err := downloadFromBucket(ctx, rankHistoryObject(competition))
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse rank history: %w", err)
+	}
+	return history, nil
+}
+
+func saveRankHistory(ctx context.Context, competition string, history *RankHistory) error {
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return uploadToBucket(ctx, rankHistoryObject(competition), "application/json", data)
+}
+
+// handleCronRank is hit by cloud scheduler. It records where we are in the duels and shouts about big moves.
+func handleCronRank(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rank, score, err := GetDuelsRankAndScore()
+	if err != nil {
+		slog.Error("failed to get rank", "error", err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	snapshot := RankSnapshot{
+		Time:        time.Now(),
+		Competition: duelsCompetition,
+		Rank:        rank,
+		Score:       score,
+	}
+
+	history, err := loadRankHistory(ctx, duelsCompetition)
+	if err != nil {
+		slog.Error("failed to load rank history", "error", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	prev, hasPrev := history.Latest()
+	history.Snapshots = append(history.Snapshots, snapshot)
+	if err := saveRankHistory(ctx, duelsCompetition, history); err != nil {
+		slog.Error("failed to save rank history", "error", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if hasPrev {
+		if alert := rankAlert(prev, snapshot); alert != "" {
+			discordQueue.Send(webhookURL.Get(), alert, []Embed{})
+		}
+	}
+
+	slog.Info("rank snapshot", "competition", duelsCompetition, "rank", rank, "score", score, "snapshots", len(history.Snapshots))
+	writeJSON(w, snapshot)
+}
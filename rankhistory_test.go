@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankAlert(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Prev        int
+		Next        int
+		Contains    string
+	}{
+		{"small move", 30, 28, ""},
+		{"big climb", 30, 22, "up 8"},
+		{"big drop", 22, 30, "down 8"},
+		{"into the top", 11, 10, "into the duels top 10"},
+		{"out of the top", 10, 11, "out of the duels top 10"},
+		{"moving around inside the top", 9, 2, "up 7"},
+		{"staying put", 3, 3, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			alert := rankAlert(
+				RankSnapshot{Competition: duelsCompetition, Rank: tc.Prev},
+				RankSnapshot{Competition: duelsCompetition, Rank: tc.Next},
+			)
+			if tc.Contains == "" {
+				assert.Empty(t, alert)
+				return
+			}
+			assert.Contains(t, alert, tc.Contains)
+		})
+	}
+}
+
+func TestRankHistoryLatest(t *testing.T) {
+	history := &RankHistory{}
+	_, ok := history.Latest()
+	assert.False(t, ok)
+
+	history.Snapshots = append(history.Snapshots, RankSnapshot{Rank: 5}, RankSnapshot{Rank: 3})
+	latest, ok := history.Latest()
+	assert.True(t, ok)
+	assert.Equal(t, 3, latest.Rank)
+}
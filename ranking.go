@@ -15,7 +15,11 @@ const (
 // GetDuelsRankAndScore fetches the profile page and extracts the Duels rank and score
 func GetDuelsRankAndScore() (rank, score int, err error) {
 	// Perform HTTP GET request
-	resp, err := http.Get(brenschProfile)
+	req, err := http.NewRequest(http.MethodGet, brenschProfile, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := profileClient.Do(req)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to retrieve URL: %w", err)
 	}
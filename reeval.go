@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// parseVisitThresholds reads a comma separated list of increasing visit counts, skipping anything that isn't one.
+func parseVisitThresholds(value string) []int64 {
+	var thresholds []int64
+	for _, field := range strings.Split(value, ",") {
+		visits, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil || visits <= 0 {
+			continue
+		}
+		if len(thresholds) > 0 && visits <= thresholds[len(thresholds)-1] {
+			continue
+		}
+		thresholds = append(thresholds, visits)
+	}
+	return thresholds
+}
+
+func reevalThresholdsFromEnv() []int64 {
+	return parseVisitThresholds(os.Getenv("REEVAL_VISITS"))
+}
+
+// maybeReevaluate looks at the node again once its visits pass the next re-evaluation threshold.
+// Only one worker gets to do each threshold.
+func (c SearchConfig) maybeReevaluate(node *Node, visits int64) {
+	if node.Parent == nil || node.SnakeIndex < 0 {
+		// nobody chooses between roots, so their own score doesn't matter
+		return
+	}
+	done := atomic.LoadInt32(&node.reevaluations)
+	if int(done) >= len(c.ReevalVisits) || visits < c.ReevalVisits[done] {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&node.reevaluations, done, done+1) {
+		return
+	}
+	reevaluate(node, c.deepEvaluate(node.Board, node.SnakeIndex))
+}
+
+// reevaluate replaces the node's own scores and fixes up every sum they went into. The old scores were added
+// to the node and each of its ancestors once per simulation that stopped at the node.
+func reevaluate(node *Node, scores []float64) {
+	node.mutex.Lock()
+	uses := float64(atomic.LoadInt64(&node.leafVisits))
+	delta := make([]float64, len(scores))
+	changed := false
+	for i := range scores {
+		old := 0.0
+		if i < len(node.MyScores) {
+			old = node.MyScores[i]
+		}
+		delta[i] = (scores[i] - old) * uses
+		changed = changed || delta[i] != 0
+	}
+	node.storeMyScores(scores)
+	node.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	for n := node; n != nil; n = n.Parent {
+		n.addScores(delta)
+	}
+}
+
+// deepEvaluate scores the board for every snake with the full modules after looking one move ahead,
+// assuming the next snake to move after the given one picks the reply that's best for itself.
+func (c SearchConfig) deepEvaluate(board Board, snakeIndex int) []float64 {
+	if isTerminal(board) {
+		return c.scores(board, modules)
+	}
+
+	next := (snakeIndex + 1) % len(board.Snakes)
+	moves := generateSafeMoves(board, next)
+	if len(moves) == 0 {
+		moves = AllDirections
+	}
+
+	var best []float64
+	for _, move := range moves {
+		nextBoard := copyBoard(board)
+		applyMove(&nextBoard, next, move)
+		scores := c.scores(nextBoard, modules)
+		if best == nil || scores[next] > best[next] {
+			best = scores
+		}
+	}
+	return best
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVisitThresholds(t *testing.T) {
+	testCases := []struct {
+		Value    string
+		Expected []int64
+	}{
+		{"", nil},
+		{"200", []int64{200}},
+		{"200, 2000,20000", []int64{200, 2000, 20000}},
+		{"200,nope,-5,2000", []int64{200, 2000}},
+		{"2000,200,3000", []int64{2000, 3000}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.Expected, parseVisitThresholds(tc.Value), tc.Value)
+	}
+}
+
+func TestReevaluateCorrectsSums(t *testing.T) {
+	root := &Node{SnakeIndex: -1, Visits: 10, Scores: []float64{1, -1}}
+	child := &Node{SnakeIndex: 0, Parent: root, Visits: 6, Score: -2, Scores: []float64{-2, 2}}
+	leaf := &Node{SnakeIndex: 1, Parent: child, Visits: 3, Score: 0.9, Scores: []float64{-0.9, 0.9}, MyScore: 0.3, MyScores: []float64{-0.3, 0.3}, leafVisits: 3}
+
+	reevaluate(leaf, []float64{0.1, -0.1})
+
+	assert.Equal(t, []float64{0.1, -0.1}, leaf.MyScores)
+	assert.Equal(t, -0.1, leaf.MyScore)
+	assert.InDelta(t, 0.9-1.2, leaf.Score, 1e-9)
+	assert.InDeltaSlice(t, []float64{-0.9 + 1.2, 0.9 - 1.2}, leaf.Scores, 1e-9)
+	assert.InDelta(t, -2+1.2, child.Score, 1e-9, "the parent reads snake 0's part")
+	assert.InDeltaSlice(t, []float64{-2 + 1.2, 2 - 1.2}, child.Scores, 1e-9)
+	assert.InDeltaSlice(t, []float64{1 + 1.2, -1 - 1.2}, root.Scores, 1e-9)
+	assert.Equal(t, int64(10), root.Visits, "visits don't change")
+}
+
+func TestMaybeReevaluateOncePerThreshold(t *testing.T) {
+	board := evalTestBoard()
+	root := NewNode(board, -1, nil)
+	child := NewNode(board, 0, root)
+	// nothing real scores this high, so any re-evaluation shows
+	fake := []float64{5, 5, 5}
+	child.setMyScores(fake)
+	child.leafVisits = 1
+
+	config := SearchConfig{ReevalVisits: []int64{10, 100}}
+	config.maybeReevaluate(child, 9)
+	assert.Equal(t, fake, child.MyScores, "not at the threshold yet")
+
+	config.maybeReevaluate(child, 10)
+	assert.Equal(t, config.deepEvaluate(board, 0), child.MyScores)
+
+	child.setMyScores(fake)
+	config.maybeReevaluate(child, 11)
+	assert.Equal(t, fake, child.MyScores, "first threshold already done")
+
+	config.maybeReevaluate(child, 150)
+	assert.Equal(t, config.deepEvaluate(board, 0), child.MyScores)
+	assert.Equal(t, int32(2), child.reevaluations)
+
+	root.Visits = 1000
+	config.maybeReevaluate(root, 1000)
+	assert.Equal(t, int32(0), root.reevaluations, "roots are left alone")
+}
+
+func TestDeepEvaluateAssumesBestReply(t *testing.T) {
+	board := evalTestBoard()
+	config := SearchConfig{}
+	var best []float64
+	for _, move := range generateSafeMoves(board, 1) {
+		next := copyBoard(board)
+		applyMove(&next, 1, move)
+		scores := evaluateScores(next, modules)
+		if best == nil || scores[1] > best[1] {
+			best = scores
+		}
+	}
+	assert.Equal(t, best, config.deepEvaluate(board, 0))
+}
+
+func TestSearchWithReevaluation(t *testing.T) {
+	root := MCTS(context.Background(), "test", evalTestBoard(), 3000, 2, make(map[string]*Node), WithSearchConfig(SearchConfig{ReevalVisits: []int64{20, 200}}))
+
+	// every node's sums should still be its own evaluations plus its children's
+	var check func(n *Node)
+	check = func(n *Node) {
+		for i := range n.Scores {
+			expected := 0.0
+			if n.MyScores != nil {
+				expected = n.MyScores[i] * float64(n.leafVisits)
+			}
+			for _, child := range n.Children() {
+				expected += child.Scores[i]
+			}
+			assert.InDelta(t, expected, n.Scores[i], 1e-6)
+		}
+		for _, child := range n.Children() {
+			check(child)
+		}
+	}
+	check(root)
+	assert.Positive(t, root.Children()[0].reevaluations)
+}
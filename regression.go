@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// regressionTurns is how many of the turns we searched before dying are kept from a lost game.
+const regressionTurns = 3
+
+// RegressionCase is a board from a game we lost with the move we made on it, for the test suite to make
+// sure we don't make it again. The board is the same json TestMCTSVisualizationJSON's cases are written in.
+type RegressionCase struct {
+	Description      string   `json:"description"`
+	GameID           string   `json:"game_id"`
+	Turn             int      `json:"turn"`
+	TurnsBeforeDeath int      `json:"turns_before_death"`
+	Engine           string   `json:"engine"`  // build that made the move
+	Board            Board    `json:"board"`   // us first
+	Ruleset          Ruleset  `json:"ruleset"` // the game's rules, which the board's json leaves out
+	Map              string   `json:"map,omitempty"`
+	AvoidMoves       []string `json:"avoid_moves"`
+	// AcceptableMoves narrows it down further once someone has worked out what we should have done
+	AcceptableMoves []string `json:"acceptable_moves,omitempty"`
+	// SearchMs is how long the case gets to search, the usual move budget if it's left out
+	SearchMs int `json:"search_ms,omitempty"`
+}
+
+// regressionCases turns the last turns we searched before dying into cases avoiding the moves we made.
+// Forced moves are left out since there was nothing else to do by then.
+func regressionCases(log GameLog, youID string, cause string, lastTurn int) []RegressionCase {
+	var cases []RegressionCase
+	for i := len(log.Entries) - 1; i >= 0 && len(cases) < regressionTurns; i-- {
+		entry := log.Entries[i]
+		if entry.YouID != youID || entry.Decision.Forced || entry.Decision.TailChase {
+			continue
+		}
+		cases = append(cases, RegressionCase{
+			Description:      fmt.Sprintf("went %s %d turns before losing (%s)", entry.Move, lastTurn-entry.Turn, cause),
+			GameID:           log.GameID,
+			Turn:             entry.Turn,
+			TurnsBeforeDeath: lastTurn - entry.Turn,
+			Engine:           entry.Decision.Engine,
+			Board:            entry.Board,
+			Ruleset:          entry.Ruleset,
+			Map:              entry.Map,
+			AvoidMoves:       []string{entry.Move},
+		})
+	}
+	return cases
+}
+
+// SearchBoard is the case's board with the game's rules and turn back on it, ready to search.
+func (c RegressionCase) SearchBoard() Board {
+	return copyBoard(c.Board).withRules(Game{Ruleset: c.Ruleset, Map: c.Map}).atTurn(c.Turn)
+}
+
+func regressionObject(gameID string, turn int, end time.Time) string {
+	return fmt.Sprintf("regressions/%s/%s_%d.json", end.UTC().Format("2006-01-02"), gameID, turn)
+}
+
+// regressionStage keeps the last turns of a lost game in the bucket as test cases.
+func regressionStage(ctx context.Context, job *EndOfGameJob) error {
+	outcome, cause, _ := judgeGame(job.Game)
+	if outcome != Loss {
+		return nil
+	}
+	log, ok := gameLogs.Get(job.Session.ID)
+	if !ok {
+		return nil
+	}
+	for _, regression := range regressionCases(log, job.Session.YouID, cause, job.Game.Turn) {
+		data, err := json.MarshalIndent(regression, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode regression case: %w", err)
+		}
+		if err := uploadToBucket(ctx, regressionObject(regression.GameID, regression.Turn, job.End), "application/json", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
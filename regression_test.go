@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegressionCasesFromLostGame(t *testing.T) {
+	board := blunderTestBoard()
+	log := GameLog{GameID: "lost", Entries: []GameLogEntry{
+		{YouID: "us", Turn: 7, Board: board, Move: "up"},
+		{YouID: "us", Turn: 8, Board: board, Move: "up"},
+		{YouID: "also-us", Turn: 8, Board: board, Move: "down"},
+		{YouID: "us", Turn: 9, Board: board, Ruleset: Ruleset{Name: "wrapped"}, Map: "standard", Move: "left"},
+		{YouID: "us", Turn: 10, Board: board, Move: "right", Decision: DecisionRecord{Forced: true}},
+	}}
+
+	cases := regressionCases(log, "us", CauseSelf, 11)
+	require.Len(t, cases, regressionTurns)
+	var turns []int
+	for _, c := range cases {
+		turns = append(turns, c.Turn)
+	}
+	assert.Equal(t, []int{9, 8, 7}, turns, "the forced move and the other snake's turn are left out")
+	assert.Equal(t, []string{"left"}, cases[0].AvoidMoves)
+	assert.Equal(t, 2, cases[0].TurnsBeforeDeath)
+	assert.Equal(t, "went left 2 turns before losing (ran into ourselves)", cases[0].Description)
+	assert.True(t, cases[0].SearchBoard().Wrapped, "the game's rules come with the case")
+	assert.Equal(t, "standard", cases[0].SearchBoard().Map)
+	assert.Equal(t, 9, cases[0].SearchBoard().Turn)
+}
+
+// regressionFixtures is where TestRegressionFixtures finds its cases. Point REGRESSION_FIXTURES at a copy
+// of the bucket's regressions/ to run everything lost games have turned up.
+func regressionFixtures() string {
+	if dir := os.Getenv("REGRESSION_FIXTURES"); dir != "" {
+		return dir
+	}
+	return filepath.Join("testdata", "regressions")
+}
+
+func TestRegressionFixtures(t *testing.T) {
+	err := filepath.WalkDir(regressionFixtures(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var regression RegressionCase
+		if err := json.Unmarshal(data, &regression); err != nil {
+			return err
+		}
+
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Log(regression.Description)
+			searchTime := 500 * time.Millisecond
+			if regression.SearchMs > 0 {
+				searchTime = time.Duration(regression.SearchMs) * time.Millisecond
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), searchTime)
+			defer cancel()
+			node := MCTS(ctx, regression.GameID, regression.SearchBoard(), math.MaxInt, runtime.NumCPU(), nil)
+			move := determineBestMove(node)
+			assert.NotContains(t, regression.AvoidMoves, move)
+			if len(regression.AcceptableMoves) > 0 {
+				assert.Contains(t, regression.AcceptableMoves, move)
+			}
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha1"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -26,7 +25,9 @@ import (
 const (
 	canvasWidth  = 64 // Canvas dimensions
 	canvasHeight = 32
-	cellSize     = 3 // Each cell is 3x3 pixels
+	cellSize     = 3 // Each cell is 3x3 pixels on the standard boards
+	// tidbytBoardWidth is what's left for the board once the lengths down the left have their room
+	tidbytBoardWidth = 33
 )
 
 // FrameSnake defines the structure of a snake in a game frame
@@ -57,39 +58,23 @@ type Death struct {
 type FrameEvent struct {
 	Type string `json:"Type"`
 	Data struct {
-		ID     string       `json:"ID"`
-		Turn   int          `json:"Turn"`
-		Snakes []FrameSnake `json:"Snakes"` // FrameSnake for event snakes
-		Food   []Point      `json:"Food"`
-		Width  int          `json:"Width"`  // Board width
-		Height int          `json:"Height"` // Board height
+		ID      string       `json:"ID"`
+		Turn    int          `json:"Turn"`
+		Snakes  []FrameSnake `json:"Snakes"` // FrameSnake for event snakes
+		Food    []Point      `json:"Food"`
+		Hazards []Point      `json:"Hazards"`
+		Width   int          `json:"Width"`  // Board width
+		Height  int          `json:"Height"` // Board height
+		// the game's settings, on the game_end event, as strings by name
+		Ruleset map[string]string `json:"Ruleset"`
+		Map     string            `json:"Map"`
 	} `json:"Data"`
 }
 
-func RetrieveGameRenderAndSendToTidbyt(gameID string) {
-
-	// WebSocket URL for the game
-	wsURL := fmt.Sprintf("wss://engine.battlesnake.com/games/%s/events", gameID)
-
-	// Collect game frames
-	frames, outcome, err := collectGameFrames(wsURL)
-	if err != nil {
-		slog.Error("Failed to collect game frames", "error", err.Error())
-	}
-	slog.Info("got frames from websocket", "turns", len(frames), "outcome", outcome)
-
-	// Render frames to WebP and push to Tidbyt
-	err = renderGameToGIF(frames, deviceID, outcome)
-	if err != nil {
-		slog.Error("Failed to render game to gif", "error", err.Error())
-	}
-
-}
-
-// Generate color from a hash of the snake name
-func generateColor(name string) color.RGBA {
+// Generate color from a hash of the snake id
+func generateColor(id string) color.RGBA {
 	h := sha1.New()
-	h.Write([]byte(name))
+	h.Write([]byte(id))
 	hash := h.Sum(nil)
 	return color.RGBA{hash[0], hash[1], hash[2], 255}
 }
@@ -112,7 +97,7 @@ func min(a, b int) int {
 }
 
 // Collect game frames from WebSocket and save board dimensions from the `game_end` event
-func collectGameFrames(wsURL string) ([]*Board, GameOutcome, error) {
+func collectGameFrames(wsURL, youID string) ([]*Board, GameOutcome, error) {
 	var boards []*Board
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
@@ -143,20 +128,22 @@ func collectGameFrames(wsURL string) ([]*Board, GameOutcome, error) {
 		if event.Type == "game_end" {
 			boardWidth = event.Data.Width
 			boardHeight = event.Data.Height
-			// Check if Gregory won (i.e., no Death value)
 			break
 		}
 		lastFrameEvent = event
 
 		board := &Board{
-			Snakes: convertFrameEventToGame(event),
-			Food:   event.Data.Food,
+			Snakes:  convertFrameEventToGame(event),
+			Food:    event.Data.Food,
+			Hazards: event.Data.Hazards,
+			Deaths:  frameDeaths(event),
+			Turn:    event.Data.Turn,
 		}
 		boards = append(boards, board)
 
 	}
 
-	outcome, err := GetOutcomeForGregory(lastFrameEvent)
+	outcome, err := GetOutcomeForSnake(lastFrameEvent, youID)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -170,41 +157,49 @@ func collectGameFrames(wsURL string) ([]*Board, GameOutcome, error) {
 	return boards, outcome, nil
 }
 
-// GetOutcomeForGregory determines if Gregory won, lost, or the game was a draw.
-// Note this is for the retrieved object types
-func GetOutcomeForGregory(data FrameEvent) (GameOutcome, error) {
-
-	var gregory, opponent *FrameSnake
-
-	// Find Gregory and the other snake
+// GetOutcomeForSnake determines if the snake with the id won, lost, or the game was a draw,
+// going by who has a Death in the final frame. Note this is for the retrieved object types
+func GetOutcomeForSnake(data FrameEvent, youID string) (GameOutcome, error) {
+	var you *FrameSnake
 	for i := range data.Data.Snakes {
-		if data.Data.Snakes[i].Name == "Gregory" {
-			gregory = &data.Data.Snakes[i]
-		} else {
-			opponent = &data.Data.Snakes[i]
+		if data.Data.Snakes[i].ID == youID {
+			you = &data.Data.Snakes[i]
 		}
 	}
+	if you == nil {
+		return Draw, fmt.Errorf("could not find snake %s", youID)
+	}
 
-	if gregory == nil || opponent == nil {
-		return Draw, fmt.Errorf("could not find Gregory or opponent")
+	othersAlive := 0
+	lastDeath := -1
+	for _, snake := range data.Data.Snakes {
+		if snake.ID == youID {
+			continue
+		}
+		if snake.Death == nil {
+			othersAlive++
+		} else if snake.Death.Turn > lastDeath {
+			lastDeath = snake.Death.Turn
+		}
 	}
 
-	// Check if both died on the same turn (ensure Death is not nil)
-	if gregory.Death != nil && opponent.Death != nil {
+	if you.Death == nil {
+		// we're the last one standing
+		if othersAlive == 0 {
+			return Win, nil
+		}
 		return Draw, nil
 	}
 
-	// If Gregory is alive (Death is nil or Turn is 0) and the opponent is dead
-	if gregory.Death == nil && opponent.Death != nil {
-		return Win, nil
+	// If we're dead and someone else isn't
+	if othersAlive > 0 {
+		return Loss, nil
 	}
 
-	// If Gregory is dead and the opponent is alive (Death is nil or Turn is 0)
-	if gregory.Death != nil && (opponent.Death == nil) {
+	// everyone is dead, it's only a draw if we went out with the last of them
+	if you.Death.Turn < lastDeath {
 		return Loss, nil
 	}
-
-	// If only one snake is alive, Gregory wins if it's him
 	return Draw, nil
 }
 
@@ -233,28 +228,34 @@ func convertFrameSnakeToGameSnake(fs FrameSnake) Snake {
 	}
 }
 
-// Example usage within collectGameFrames or anywhere else
+// Example usage within collectGameFrames or anywhere else.
+// Dead snakes stay in the engine's frames where they died, they're left out here and marked by frameDeaths instead.
 func convertFrameEventToGame(frameEvent FrameEvent) []Snake {
 	var gameSnakes []Snake
 	for _, frameSnake := range frameEvent.Data.Snakes {
+		if frameSnake.Death != nil {
+			continue
+		}
 		gameSnake := convertFrameSnakeToGameSnake(frameSnake)
 		gameSnakes = append(gameSnakes, gameSnake)
 	}
 	return gameSnakes
 }
 
-// Render a single board to an image with 3x3 pixel cells, border, y-axis flip, and snake names
-func renderBoardToImage(board *Board) (*image.RGBA, []color.Color) {
-	palette := []color.Color{
-		color.RGBA{0, 0, 0, 255},       // Black
-		color.RGBA{255, 255, 255, 255}, // White
-		color.RGBA{255, 0, 0, 255},     // Red
-		color.RGBA{255, 255, 0, 255},   // yellow
-		color.RGBA{0, 255, 0, 255},     // Green
-		color.RGBA{0, 0, 255, 255},     // Blue
-		color.RGBA{100, 100, 100, 255}, // Grey
+// frameDeaths is where each dead snake's head was when it died.
+func frameDeaths(frameEvent FrameEvent) []Point {
+	var deaths []Point
+	for _, frameSnake := range frameEvent.Data.Snakes {
+		if frameSnake.Death != nil && len(frameSnake.Body) > 0 {
+			deaths = append(deaths, frameSnake.Body[0])
+		}
 	}
+	return deaths
+}
 
+// Render a single board to an image with 3x3 pixel cells, border, y-axis flip, snake lengths and the turn.
+// Colours and label rows come from the game's palette so they don't move around between frames.
+func renderBoardToImage(board *Board, snakePalette *SnakePalette) (*image.RGBA, []color.Color) {
 	img := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
 
 	// Fill the background with black
@@ -262,49 +263,66 @@ func renderBoardToImage(board *Board) (*image.RGBA, []color.Color) {
 	draw.Draw(img, img.Bounds(), &image.Uniform{black}, image.Point{}, draw.Src)
 
 	// Calculate the offset to move the board to the far right
-	offsetX := canvasWidth - board.Width*3 // The far-right position, considering 3x3 cells
+	size := tidbytCellSize(board)
+	offsetX := canvasWidth - board.Width*size // The far-right position
 	offsetY := 0
 	dividerColor := color.RGBA{100, 100, 100, 255}
-	dividerRect := image.Rect(canvasWidth-3*board.Width-1, 0, canvasWidth-3*board.Width, canvasHeight)
+	dividerRect := image.Rect(offsetX-1, 0, offsetX, canvasHeight)
 	draw.Draw(img, dividerRect, &image.Uniform{dividerColor}, image.Point{}, draw.Src)
+	cell := func(p Point) (int, int) {
+		flippedY := board.Height - 1 - p.Y // Flip along Y axis
+		return offsetX + p.X*size, offsetY + flippedY*size
+	}
+
+	// Hazards go underneath everything else
+	for _, hazard := range board.Hazards {
+		x, y := cell(hazard)
+		drawCell(img, x, y, size, hazardColor)
+	}
 
 	// Draw the snakes
-	// Render snake names on the left side
-	yOffset := 10
-	for _, snake := range board.Snakes {
-		bodyColor, err := hexToRGBA(snake.Customizations.Color)
-		if err != nil {
-			bodyColor = generateColor(snake.Name)
-		}
-		headColor := lighten(bodyColor)
-		palette = append(palette, bodyColor)
-		palette = append(palette, headColor)
+	// Render snake lengths on the left side
+	for index, snake := range board.Snakes {
+		bodyColor := snakePalette.Body(snake.ID)
+		headColor := snakePalette.Head(snake.ID)
 
 		// Draw snake's body
 		for i, segment := range snake.Body {
-			flippedY := board.Height - 1 - segment.Y // Flip along Y axis
-
+			x, y := cell(segment)
 			if i == 0 {
 				// Head of the snake (slightly lighter)
-				drawCell(img, offsetX+segment.X*3, offsetY+flippedY*3, headColor)
+				drawCell(img, x, y, size, headColor)
 			} else {
 				// Body of the snake
-				drawCell(img, offsetX+segment.X*3, offsetY+flippedY*3, bodyColor)
+				drawCell(img, x, y, size, bodyColor)
 			}
 		}
 
-		addScaledLabel(img, 10, yOffset, fmt.Sprintf("%3d", len(snake.Body)), bodyColor) // Render each snake name starting from (10, yOffset)
-		yOffset += 20
+		// keep each snake on its own row for the whole game
+		row := snakePalette.Slot(snake.ID)
+		if row < 0 {
+			row = index
+		}
+		addScaledLabel(img, 10, 10+row*20, fmt.Sprintf("%3d", len(snake.Body)), bodyColor)
 	}
 
 	// Draw food (in green)
 	green := color.RGBA{0, 255, 0, 255}
 	for _, food := range board.Food {
-		flippedY := board.Height - 1 - food.Y // Flip along Y axis
-		drawCell(img, offsetX+food.X*3, offsetY+flippedY*3, green)
+		x, y := cell(food)
+		drawCell(img, x, y, size, green)
+	}
+
+	// an X where each snake died
+	for _, death := range board.Deaths {
+		x, y := cell(death)
+		drawCross(img, x, y, size, deathColor)
 	}
 
-	return img, palette
+	// the turn sits in the gap between the first two length rows, the font's too big for anywhere else
+	drawTinyNumber(img, 1, 14, board.Turn, dividerColor)
+
+	return img, snakePalette.Colors()
 }
 
 // Helper function to add text (snake names) using the basic font
@@ -350,11 +368,27 @@ func hexToRGBA(hex string) (color.RGBA, error) {
 	return color.RGBA{uint8(r), uint8(g), uint8(b), 255}, nil
 }
 
-// Draw a 3x3 cell at the specified board position, accounting for centering
-func drawCell(img *image.RGBA, x, y int, c color.RGBA) {
-	// Each "cell" is now 3x3 pixels, so expand each cell to fill that space
-	for i := 0; i < 3; i++ {
-		for j := 0; j < 3; j++ {
+// tidbytCellSize is how many pixels a side each cell gets on the tidbyt. Standard boards get 3, letting
+// the bottom pixel of an 11 high board go off the screen. Anything bigger, like the 19x19 and 25x25
+// maps, gets the biggest cells that still fit next to the lengths.
+func tidbytCellSize(board *Board) int {
+	if board.Width*cellSize <= tidbytBoardWidth && board.Height*cellSize <= canvasHeight+1 {
+		return cellSize
+	}
+	if board.Width == 0 || board.Height == 0 {
+		return cellSize
+	}
+	size := min(tidbytBoardWidth/board.Width, canvasHeight/board.Height)
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+// Draw a size x size cell at the specified board position
+func drawCell(img *image.RGBA, x, y, size int, c color.RGBA) {
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
 			if y+j < canvasHeight { // Ensure we don't draw outside the canvas height
 				img.Set(x+i, y+j, c)
 			}
@@ -362,78 +396,116 @@ func drawCell(img *image.RGBA, x, y int, c color.RGBA) {
 	}
 }
 
-// Stitch together frames and encode as GIF animation with dynamic delay to fit within 15 seconds
-func renderGameToGIF(frames []*Board, deviceID string, outcome GameOutcome) error {
+// Draw an X over a 3x3 cell, the corners and the middle. Smaller cells are too small for it and get filled in.
+func drawCross(img *image.RGBA, x, y, size int, c color.RGBA) {
+	if size < 3 {
+		drawCell(img, x, y, size, c)
+		return
+	}
+	for _, offset := range []image.Point{{0, 0}, {2, 0}, {1, 1}, {0, 2}, {2, 2}} {
+		if y+offset.Y < canvasHeight {
+			img.Set(x+offset.X, y+offset.Y, c)
+		}
+	}
+}
+
+// tinyDigits is a 3x5 pixel font for the digits, one row of three bits per line from the top.
+var tinyDigits = [10][5]uint8{
+	{7, 5, 5, 5, 7}, // 0
+	{2, 6, 2, 2, 7}, // 1
+	{7, 1, 7, 4, 7}, // 2
+	{7, 1, 3, 1, 7}, // 3
+	{5, 5, 7, 1, 1}, // 4
+	{7, 4, 7, 1, 7}, // 5
+	{7, 4, 7, 5, 7}, // 6
+	{7, 1, 1, 1, 1}, // 7
+	{7, 5, 7, 5, 7}, // 8
+	{7, 5, 7, 1, 7}, // 9
+}
 
-	if len(frames) == 0 {
-		slog.Warn("no frames to be rendered")
-		return nil
+// Draw a number with its top left at x, y using the tiny font, 4 pixels a digit
+func drawTinyNumber(img *image.RGBA, x, y, n int, c color.RGBA) {
+	for i, digit := range strconv.Itoa(n) {
+		if digit < '0' || digit > '9' {
+			continue
+		}
+		for row, bits := range tinyDigits[digit-'0'] {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) != 0 {
+					img.Set(x+i*4+col, y+row, c)
+				}
+			}
+		}
 	}
+}
 
-	slog.Info("rendering game")
-	totalDuration := 13000                               // 15 seconds in milliseconds
-	maxDelayPerFrame := 20                               // Maximum delay of 200ms (200ms = 20 * 10ms)
-	framesPerChunk := len(frames)                        // Total number of frames in the game
-	delayPerFrame := totalDuration / framesPerChunk / 10 // Calculate the delay dynamically
+// palettize converts the image to the palette. The tidbyt canvas only ever uses colours that are already
+// in the palette, so it maps each pixel to the nearest colour; dithering there just adds speckle to 3x3 cells.
+// Dithering is for the high resolution renderer, where shading needs colours the palette doesn't have.
+func palettize(img image.Image, palette color.Palette, dither bool) *image.Paletted {
+	paletted := image.NewPaletted(img.Bounds(), palette)
+	if dither {
+		draw.FloydSteinberg.Draw(paletted, img.Bounds(), img, image.Point{})
+	} else {
+		draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)
+	}
+	return paletted
+}
+
+// gameDelays spreads the frames over about 13 seconds, capped at 200ms a frame, and holds the last frame.
+func gameDelays(frames int) []int {
+	totalDuration := 13000                       // 15 seconds in milliseconds
+	maxDelayPerFrame := 20                       // Maximum delay of 200ms (200ms = 20 * 10ms)
+	delayPerFrame := totalDuration / frames / 10 // Calculate the delay dynamically
 
 	// Cap the delay to ensure it's not longer than 200ms per frame
 	if delayPerFrame > maxDelayPerFrame {
 		delayPerFrame = maxDelayPerFrame
 	}
 
-	// Arrays to store the full set of images and delays for the entire GIF
-	var images []*image.Paletted
-	var delays []int
-
-	// Loop through each board (frame) and render it
-	for i, board := range frames {
-		img, palette := renderBoardToImage(board)
-
-		// Convert the image to a paletted image (required for GIFs)
-		palettedImage := image.NewPaletted(img.Bounds(), palette)
-		draw.FloydSteinberg.Draw(palettedImage, img.Bounds(), img, image.Point{})
-
-		// Append the paletted image and the dynamic delay (in 100ths of a second)
-		images = append(images, palettedImage)
-		if i == len(frames)-1 {
-			delays = append(delays, 200) // longer delay on last frame
-		} else {
-			delays = append(delays, delayPerFrame) // Dynamic delay per frame
-		}
+	delays := make([]int, frames)
+	for i := range delays {
+		delays[i] = delayPerFrame // Dynamic delay per frame
 	}
+	delays[frames-1] = 200 // longer delay on last frame
+	return delays
+}
 
-	// If Gregory won, append a green screen at the end, otherwise append a red screen
+// outcomeScreen is a solid frame in the outcome's colour to finish the gif on.
+func outcomeScreen(bounds image.Rectangle, outcome GameOutcome) *image.Paletted {
+	// green if we won, yellow for a draw, red if we lost
 	colourHex := fmt.Sprintf("#%06x", getColorForOutcome(outcome))
 	colour, err := hexToRGBA(colourHex)
 	if err != nil {
 		colour = color.RGBA{255, 255, 255, 255}
 	}
-	winScreenPalette := color.Palette{colour}
+	return image.NewPaletted(bounds, color.Palette{colour})
+}
 
-	// Create the win/lose screen as a paletted image
-	finalScreen := image.NewPaletted(image.Rect(0, 0, canvasWidth, canvasHeight), winScreenPalette)
+// encodeGameGIF renders the frames for the tidbyt and finishes on the outcome screen.
+func encodeGameGIF(frames []*Board, outcome GameOutcome) ([]byte, error) {
+	snakePalette := newSnakePalette(frames[0])
+	images := make([]*image.Paletted, 0, len(frames)+1)
+	for _, board := range frames {
+		img, palette := renderBoardToImage(board, snakePalette)
+		images = append(images, palettize(img, palette, false))
+	}
+	return encodeFramesWithOutcome(images, gameDelays(len(frames)), outcome)
+}
 
+func encodeFramesWithOutcome(images []*image.Paletted, delays []int, outcome GameOutcome) ([]byte, error) {
 	// Append the final screen image with a delay of 1 second (100 * 10ms = 1000ms)
-	images = append(images, finalScreen)
-	delays = append(delays, 100) // 1 second delay for the final screen
-
-	// Create a buffer to store the full GIF data
-	var buf bytes.Buffer
+	images = append(images, outcomeScreen(images[0].Bounds(), outcome))
+	delays = append(delays, 100)
 
 	// Encode the images (including the final screen) into a single GIF
-	err = gif.EncodeAll(&buf, &gif.GIF{
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
 		Image: images,
 		Delay: delays,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to encode GIF: %v", err)
-	}
-
-	// Encode the GIF as base64 and send it to Tidbyt (only one push)
-	webpBase64 := base64.StdEncoding.EncodeToString(buf.Bytes())
-	if err := PushToTidbyt(deviceID, webpBase64); err != nil {
-		return fmt.Errorf("failed to push to Tidbyt: %v", err)
+		return nil, fmt.Errorf("failed to encode GIF: %v", err)
 	}
-
-	return nil
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// assertGolden compares the data with the checked in file, or rewrites the file with -update.
+func assertGolden(t *testing.T, name string, data []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test -run %s -update to create it", t.Name())
+	if !bytes.Equal(expected, data) {
+		failed := path + ".failed"
+		os.WriteFile(failed, data, 0o644)
+		t.Fatalf("%s doesn't match, wrote the new render to %s", path, failed)
+	}
+}
+
+func rendererTestFrames() []*Board {
+	snakes := func(a, b []Point) []Snake {
+		return []Snake{
+			{ID: "gs_us", Name: "Gregory", Health: 100, Body: a, Head: a[0], Customizations: Customizations{Color: "#00ff00"}},
+			{ID: "gs_them", Name: "them", Health: 100, Body: b, Head: b[0]},
+		}
+	}
+	return []*Board{
+		{Height: 11, Width: 11, Food: []Point{{X: 5, Y: 5}}, Snakes: snakes(
+			[]Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}},
+			[]Point{{X: 9, Y: 9}, {X: 9, Y: 10}, {X: 10, Y: 10}},
+		)},
+		{Height: 11, Width: 11, Food: []Point{{X: 5, Y: 5}}, Snakes: snakes(
+			[]Point{{X: 1, Y: 2}, {X: 1, Y: 1}, {X: 1, Y: 0}},
+			[]Point{{X: 8, Y: 9}, {X: 9, Y: 9}, {X: 9, Y: 10}},
+		)},
+	}
+}
+
+func TestTidbytRenderHasNoDithering(t *testing.T) {
+	frames := rendererTestFrames()
+	snakePalette := newSnakePalette(frames[0])
+	img, palette := renderBoardToImage(frames[0], snakePalette)
+	paletted := palettize(img, palette, false)
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			want := color.RGBAModel.Convert(img.At(x, y))
+			got := color.RGBAModel.Convert(paletted.At(x, y))
+			if !assert.Equal(t, want, got, "pixel %d,%d", x, y) {
+				return
+			}
+		}
+	}
+}
+
+func TestTidbytGIFGolden(t *testing.T) {
+	data, err := encodeGameGIF(rendererTestFrames(), Win)
+	require.NoError(t, err)
+	assertGolden(t, "tidbyt_1v1.gif", data)
+}
+
+func TestHighResGIFGolden(t *testing.T) {
+	data, err := encodeGameHighResGIF(rendererTestFrames(), Loss)
+	require.NoError(t, err)
+	assertGolden(t, "hires_1v1.gif", data)
+}
+
+// goldenStep moves every snake with a move one cell, growing it if it lands on food.
+// Snakes without a move have died and are left out of the frame with their head marked, like collectGameFrames does.
+func goldenStep(board *Board, moves map[string]Direction) *Board {
+	next := &Board{Height: board.Height, Width: board.Width, Hazards: board.Hazards, Turn: board.Turn + 1}
+	next.Deaths = append(next.Deaths, board.Deaths...)
+	eaten := map[Point]bool{}
+	for _, snake := range board.Snakes {
+		move, ok := moves[snake.ID]
+		if !ok {
+			next.Deaths = append(next.Deaths, snake.Head)
+			continue
+		}
+		head := moveHead(snake.Head, move)
+		body := append([]Point{head}, snake.Body[:len(snake.Body)-1]...)
+		if containsPoint(board.Food, head) {
+			body = append(body, body[len(body)-1])
+			eaten[head] = true
+		}
+		snake.Head = head
+		snake.Body = body
+		next.Snakes = append(next.Snakes, snake)
+	}
+	for _, food := range board.Food {
+		if !eaten[food] {
+			next.Food = append(next.Food, food)
+		}
+	}
+	return next
+}
+
+func goldenSequence(start *Board, turns []map[string]Direction) []*Board {
+	frames := []*Board{start}
+	for _, moves := range turns {
+		frames = append(frames, goldenStep(frames[len(frames)-1], moves))
+	}
+	return frames
+}
+
+func goldenSnake(id, colour string, body ...Point) Snake {
+	return Snake{ID: id, Name: id, Health: 100, Head: body[0], Body: body, Customizations: Customizations{Color: colour}}
+}
+
+func TestRendererGoldenSequences(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Frames  []*Board
+		Outcome GameOutcome
+	}{
+		{
+			Name: "2p_food",
+			Frames: goldenSequence(&Board{
+				Height: 11,
+				Width:  11,
+				Food:   []Point{{X: 1, Y: 4}, {X: 8, Y: 2}},
+				Snakes: []Snake{
+					goldenSnake("gs_us", "#00ff00", Point{X: 1, Y: 1}, Point{X: 1, Y: 0}, Point{X: 0, Y: 0}),
+					goldenSnake("gs_them", "#3e338f", Point{X: 9, Y: 9}, Point{X: 9, Y: 10}, Point{X: 10, Y: 10}),
+				},
+			}, []map[string]Direction{
+				{"gs_us": Up, "gs_them": Down},
+				{"gs_us": Up, "gs_them": Down},
+				{"gs_us": Up, "gs_them": Left},
+				{"gs_us": Right, "gs_them": Down},
+				{"gs_us": Right, "gs_them": Down},
+			}),
+			Outcome: Win,
+		},
+		{
+			Name: "4p_deaths",
+			Frames: goldenSequence(&Board{
+				Height:  11,
+				Width:   11,
+				Food:    []Point{{X: 5, Y: 5}, {X: 2, Y: 8}},
+				Hazards: []Point{{X: 0, Y: 4}, {X: 0, Y: 5}, {X: 0, Y: 6}, {X: 10, Y: 4}, {X: 10, Y: 5}, {X: 10, Y: 6}},
+				Snakes: []Snake{
+					goldenSnake("gs_us", "#00ff00", Point{X: 1, Y: 1}, Point{X: 1, Y: 0}, Point{X: 0, Y: 0}),
+					goldenSnake("gs_b", "", Point{X: 9, Y: 9}, Point{X: 9, Y: 10}, Point{X: 10, Y: 10}),
+					goldenSnake("gs_c", "#ff8800", Point{X: 1, Y: 9}, Point{X: 0, Y: 9}, Point{X: 0, Y: 10}),
+					goldenSnake("gs_d", "#ff00ff", Point{X: 9, Y: 1}, Point{X: 10, Y: 1}, Point{X: 10, Y: 0}),
+				},
+			}, []map[string]Direction{
+				{"gs_us": Up, "gs_b": Down, "gs_c": Right, "gs_d": Left},
+				{"gs_us": Up, "gs_b": Down, "gs_c": Down, "gs_d": Left},
+				// d runs out of health, c eats
+				{"gs_us": Right, "gs_b": Left, "gs_c": Up},
+				{"gs_us": Right, "gs_b": Left, "gs_c": Up},
+				// b is eliminated
+				{"gs_us": Up, "gs_c": Right},
+			}),
+			Outcome: Loss,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			tidbyt, err := encodeGameGIF(tc.Frames, tc.Outcome)
+			require.NoError(t, err)
+			assertGolden(t, "tidbyt_"+tc.Name+".gif", tidbyt)
+
+			hires, err := encodeGameHighResGIF(tc.Frames, tc.Outcome)
+			require.NoError(t, err)
+			assertGolden(t, "hires_"+tc.Name+".gif", hires)
+		})
+	}
+}
+
+func TestFrameDeaths(t *testing.T) {
+	var event FrameEvent
+	event.Data.Snakes = []FrameSnake{
+		{ID: "alive", Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}}},
+		{ID: "dead", Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}}, Death: &Death{Cause: "wall-collision", Turn: 3}},
+	}
+	snakes := convertFrameEventToGame(event)
+	require.Len(t, snakes, 1)
+	assert.Equal(t, "alive", snakes[0].ID)
+	assert.Equal(t, []Point{{X: 5, Y: 5}}, frameDeaths(event))
+}
+
+func TestRenderHazardsAndDeaths(t *testing.T) {
+	board := Board{
+		Width:   coordWidth,
+		Height:  coordHeight,
+		Hazards: []Point{{X: 2, Y: 2}},
+		Deaths:  []Point{{X: 4, Y: 4}},
+		Turn:    12,
+	}
+	palette := newSnakePalette(&board)
+
+	img, colors := renderBoardToImage(&board, palette)
+	x, y := tidbytPixel(board, Point{X: 2, Y: 2})
+	assert.Equal(t, hazardColor, img.RGBAAt(x, y))
+	x, y = tidbytPixel(board, Point{X: 4, Y: 4})
+	assert.Equal(t, deathColor, img.RGBAAt(x, y), "the middle of the X")
+	assert.Equal(t, deathColor, img.RGBAAt(x-1, y-1), "and its corners")
+	assert.NotEqual(t, deathColor, img.RGBAAt(x, y-1))
+	assert.Contains(t, colors, color.Color(hazardColor))
+	assert.Contains(t, colors, color.Color(deathColor))
+
+	hires := renderBoardHighRes(&board, palette)
+	x, y = hiResPixel(board, Point{X: 2, Y: 2})
+	assert.Equal(t, hazardColor, hires.RGBAAt(x, y))
+	x, y = hiResPixel(board, Point{X: 4, Y: 4})
+	assert.Equal(t, deathColor, hires.RGBAAt(x, y))
+}
+
+func TestTidbytTurnLabel(t *testing.T) {
+	board := Board{Width: 11, Height: 11}
+	palette := newSnakePalette(&board)
+	lit := func(turn int) int {
+		board.Turn = turn
+		img, _ := renderBoardToImage(&board, palette)
+		count := 0
+		for y := 14; y < 19; y++ {
+			for x := 0; x < canvasWidth-3*board.Width-1; x++ {
+				if img.RGBAAt(x, y) != (color.RGBA{0, 0, 0, 255}) {
+					count++
+				}
+			}
+		}
+		return count
+	}
+	assert.Equal(t, 13, lit(8), "an 8 lights the whole 3x5 box but the two holes")
+	assert.Equal(t, 2*lit(1), lit(11))
+}
+
+func TestGetOutcomeForSnake(t *testing.T) {
+	alive := func(id, name string) FrameSnake { return FrameSnake{ID: id, Name: name} }
+	dead := func(id, name string, turn int) FrameSnake {
+		return FrameSnake{ID: id, Name: name, Death: &Death{Cause: "snake-collision", Turn: turn}}
+	}
+
+	testCases := []struct {
+		Description string
+		Snakes      []FrameSnake
+		Expected    GameOutcome
+		Err         bool
+	}{
+		{"renamed snake wins", []FrameSnake{alive("us", "Gregory II"), dead("them", "them", 40)}, Win, false},
+		{"we lose", []FrameSnake{dead("us", "Gregory", 40), alive("them", "them")}, Loss, false},
+		{"head to head draw", []FrameSnake{dead("us", "Gregory", 40), dead("them", "them", 40)}, Draw, false},
+		{"another deployment named Gregory is ignored", []FrameSnake{dead("other", "Gregory", 10), alive("us", "Gregory"), dead("them", "them", 30)}, Win, false},
+		{"4 player loss while two still fight", []FrameSnake{dead("us", "Gregory", 20), alive("b", "b"), alive("c", "c"), dead("d", "d", 5)}, Loss, false},
+		{"died before the others drew", []FrameSnake{dead("us", "Gregory", 20), dead("b", "b", 50), dead("c", "c", 50)}, Loss, false},
+		{"not in the game", []FrameSnake{alive("them", "them")}, Draw, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			var event FrameEvent
+			event.Data.Snakes = tc.Snakes
+			outcome, err := GetOutcomeForSnake(event, "us")
+			if tc.Err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.Expected, outcome)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayNetwork is the round trip the fake engine clock charges each move on top of the time we took.
+const replayNetwork = 60 * time.Millisecond
+
+// replayRequest is one request from a recorded game, as written by the tester's -record flag.
+type replayRequest struct {
+	Path    string          `json:"path"`
+	Request BattleSnakeGame `json:"request"`
+}
+
+func loadReplay(t *testing.T, name string) []replayRequest {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "replay", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	var requests []replayRequest
+	decoder := json.NewDecoder(f)
+	for {
+		var request replayRequest
+		err := decoder.Decode(&request)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		requests = append(requests, request)
+	}
+	require.NotEmpty(t, requests)
+	return requests
+}
+
+// replayClock is the engine's side of the clock. Nothing is timed: each move is charged the budget the handler
+// gave its search plus the network, and that's reported back as our latency on the next request like the
+// engine does. A move answered without a search is charged just the network.
+type replayClock struct {
+	network time.Duration
+	last    time.Duration
+}
+
+// stamp puts last turn's latency on the request.
+func (c *replayClock) stamp(game *BattleSnakeGame) {
+	latency := ""
+	if c.last > 0 {
+		latency = strconv.FormatInt(c.last.Milliseconds(), 10)
+	}
+	game.You.Latency = latency
+	for i := range game.Board.Snakes {
+		if game.Board.Snakes[i].ID == game.You.ID {
+			game.Board.Snakes[i].Latency = latency
+		}
+	}
+}
+
+// charge is how long the engine waited for the move.
+func (c *replayClock) charge(budget time.Duration) time.Duration {
+	c.last = budget + c.network
+	return c.last
+}
+
+// TestReplayArchivedGame plays a whole recorded game through the handlers, start to end, so a refactor
+// that breaks the request lifecycle anywhere shows up. duel.jsonl is a game the tester played against
+// itself with -record, not a real one from the engine.
+func TestReplayArchivedGame(t *testing.T) {
+	if testing.Short() {
+		t.Skip("replays a whole game at full time per move")
+	}
+	requests := loadReplay(t, "duel.jsonl")
+
+	// the real stages talk to discord, the bucket and the engine, so each is swapped for one that notes it ran
+	var mu sync.Mutex
+	ran := map[string]*EndOfGameJob{}
+	original := endOfGame
+	var stages []PipelineStage
+	for _, stage := range original.stages {
+		name := stage.Name
+		stages = append(stages, PipelineStage{Name: name, After: stage.After, Run: func(ctx context.Context, job *EndOfGameJob) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran[name] = job
+			return nil
+		}})
+	}
+	endOfGame = newPipeline(stages)
+	defer func() { endOfGame = original }()
+
+	router := newRouter("")
+	clock := &replayClock{network: replayNetwork}
+	var session *GameSession
+	moves := 0
+	for _, request := range requests {
+		game := request.Request
+		if request.Path == "/move" {
+			clock.stamp(&game)
+		}
+		body, err := json.Marshal(game)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, request.Path, strings.NewReader(string(body))))
+		require.Equal(t, http.StatusOK, rec.Code, "%s on turn %d", request.Path, game.Turn)
+
+		current, _ := sessions.Get(game.Game.ID)
+
+		switch request.Path {
+		case "/start":
+			require.NotNil(t, current)
+			session = current
+		case "/move":
+			moves++
+			records := session.decisions.Records()
+			require.Len(t, records, moves)
+			decision := records[moves-1]
+			require.Equal(t, game.Turn, decision.Turn)
+			timeout := time.Duration(game.Game.Timeout) * time.Millisecond
+			assert.LessOrEqual(t, clock.charge(time.Duration(decision.BudgetMs)*time.Millisecond), timeout, "turn %d went over the timeout", game.Turn)
+
+			var response map[string]string
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Contains(t, []string{"up", "down", "left", "right"}, response["move"], "turn %d", game.Turn)
+			require.Same(t, session, current, "turn %d was answered from a different session", game.Turn)
+		case "/end":
+			assert.Nil(t, current, "the session should be gone once the game's over")
+		}
+	}
+	require.NotNil(t, session)
+	assert.Equal(t, []string{"Opponent Snake"}, session.otherSnakes, "the session from /start should last the whole game")
+
+	report := session.cache.Report(session.ID)
+	t.Log(report)
+	assert.Positive(t, report.Hits, "last turn's tree should be reused at least once")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, endOfGame.Wait(ctx))
+	statuses := endOfGame.Statuses()
+	require.Len(t, statuses, 1)
+	for _, stage := range statuses[0].Stages {
+		assert.Equal(t, StageDone, stage.State, stage.Name)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, ran, len(original.stages))
+	for name, job := range ran {
+		assert.Same(t, session, job.Session, name)
+	}
+	assert.Len(t, session.decisions.Records(), moves, "every move should be kept for the archive")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RolloutPolicy is how the snakes pick their moves while a leaf is played out.
+type RolloutPolicy int
+
+const (
+	// RolloutSafe picks at random from the moves that don't walk straight into something, and only takes an
+	// unsafe one when there's nothing else.
+	RolloutSafe RolloutPolicy = iota
+	// RolloutRandom picks any of the four moves at random, like a classic rollout.
+	RolloutRandom
+)
+
+func (p RolloutPolicy) String() string {
+	if p == RolloutRandom {
+		return "random"
+	}
+	return "safe"
+}
+
+// Rollout is how far a leaf is played out before it's evaluated. With no turns the leaf is evaluated as it
+// is, which is what the search has always done.
+type Rollout struct {
+	Turns  int
+	Policy RolloutPolicy
+}
+
+// play plays the board out for the rollout's turns, every snake moving once a turn starting with the one
+// after the snake that moved last. Food and hazards turn up at the end of each turn as the ruleset says. It stops
+// early once the game is over.
+func (r Rollout) play(board Board, lastMoved int) Board {
+	if r.Turns <= 0 || len(board.Snakes) == 0 || isTerminal(board) {
+		return board
+	}
+	board = copyBoard(board)
+	index := lastMoved
+	for moves := 0; moves < r.Turns*len(board.Snakes) && !isTerminal(board); moves++ {
+		index = (index + 1) % len(board.Snakes)
+		if !isSnakeDead(board.Snakes[index]) {
+			applyMove(&board, index, r.pick(board, index))
+		}
+		if index == len(board.Snakes)-1 {
+			rollChance(&board)
+		}
+	}
+	return board
+}
+
+// pick is the snake's move going by the policy.
+func (r Rollout) pick(board Board, index int) Direction {
+	if r.Policy == RolloutSafe {
+		if safe := generateSafeMoves(board, index); len(safe) > 0 {
+			return safe[rand.Intn(len(safe))]
+		}
+	}
+	return AllDirections[rand.Intn(len(AllDirections))]
+}
+
+// RolloutSettings is the rollout each ruleset gets. The empty name is for rulesets without one of their own.
+type RolloutSettings map[string]Rollout
+
+// For is the rollout for the ruleset.
+func (s RolloutSettings) For(ruleset string) Rollout {
+	if rollout, ok := s[ruleset]; ok {
+		return rollout
+	}
+	return s[""]
+}
+
+// rolloutSettings are read once from ROLLOUT_TURNS and ROLLOUT_POLICY.
+var rolloutSettings = rolloutSettingsFromEnv()
+
+// rolloutSettingsFromEnv reads ROLLOUT_TURNS as turns for every ruleset, e.g. "4", or per ruleset, e.g.
+// "standard:4,constrictor:0,8" where the bare number covers the rest. ROLLOUT_POLICY=random plays the moves
+// out at random instead of sticking to safe ones. Entries that don't read as a number of turns are skipped.
+func rolloutSettingsFromEnv() RolloutSettings {
+	policy := RolloutSafe
+	if os.Getenv("ROLLOUT_POLICY") == "random" {
+		policy = RolloutRandom
+	}
+	settings := make(RolloutSettings)
+	for _, entry := range strings.Split(os.Getenv("ROLLOUT_TURNS"), ",") {
+		ruleset, value := "", strings.TrimSpace(entry)
+		if name, turns, ok := strings.Cut(value, ":"); ok {
+			ruleset, value = strings.TrimSpace(name), strings.TrimSpace(turns)
+		}
+		turns, err := strconv.Atoi(value)
+		if err != nil || turns < 0 {
+			continue
+		}
+		settings[ruleset] = Rollout{Turns: turns, Policy: policy}
+	}
+	return settings
+}
+
+// searchConfigFor is the search config for a game, the default with the rollout for its ruleset.
+func searchConfigFor(game Game) SearchConfig {
+	config := defaultSearchConfig
+	config.Rollout = rolloutSettings.For(game.Ruleset.Name)
+	return config
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutSettingsFromEnv(t *testing.T) {
+	t.Setenv("ROLLOUT_TURNS", "")
+	t.Setenv("ROLLOUT_POLICY", "")
+	assert.Equal(t, Rollout{}, rolloutSettingsFromEnv().For("standard"), "off unless asked for")
+
+	t.Setenv("ROLLOUT_TURNS", "4")
+	assert.Equal(t, Rollout{Turns: 4}, rolloutSettingsFromEnv().For("royale"))
+
+	t.Setenv("ROLLOUT_TURNS", "standard:6, constrictor:0,2,wrapped:lots")
+	t.Setenv("ROLLOUT_POLICY", "random")
+	settings := rolloutSettingsFromEnv()
+	assert.Equal(t, Rollout{Turns: 6, Policy: RolloutRandom}, settings.For("standard"))
+	assert.Equal(t, Rollout{Policy: RolloutRandom}, settings.For("constrictor"))
+	assert.Equal(t, Rollout{Turns: 2, Policy: RolloutRandom}, settings.For("wrapped"), "unreadable entries fall back to the rest")
+	assert.Equal(t, "random", RolloutRandom.String())
+	assert.Equal(t, "safe", RolloutSafe.String())
+}
+
+func TestRolloutPlay(t *testing.T) {
+	board := evalTestBoard()
+	board.Food = nil
+	assert.Equal(t, board, Rollout{}.play(board, -1), "no turns leaves the board alone")
+
+	played := Rollout{Turns: 3}.play(board, -1)
+	for i, snake := range played.Snakes {
+		require.False(t, isSnakeDead(snake), "snake %d had room to move safely", i)
+		assert.Equal(t, board.Snakes[i].Health-3, snake.Health, "every snake moves once a turn")
+		assert.Len(t, snake.Body, len(board.Snakes[i].Body))
+	}
+	assert.Equal(t, 90, board.Snakes[0].Health, "the leaf's board isn't touched")
+
+	// picking up partway through a round still moves every snake once a turn
+	partial := Rollout{Turns: 1}.play(board, 1)
+	for _, snake := range partial.Snakes {
+		assert.Equal(t, 89, snake.Health)
+	}
+
+	// once only one snake is left there's nothing to play
+	over := copyBoard(board)
+	markDeadSnake(&over, 1)
+	markDeadSnake(&over, 2)
+	assert.Equal(t, over, Rollout{Turns: 5}.play(over, -1))
+}
+
+func TestSearchConfigForRuleset(t *testing.T) {
+	saved := rolloutSettings
+	defer func() { rolloutSettings = saved }()
+	rolloutSettings = RolloutSettings{"standard": {Turns: 4}, "": {Turns: 1, Policy: RolloutRandom}}
+
+	assert.Equal(t, Rollout{Turns: 4}, searchConfigFor(Game{Ruleset: Ruleset{Name: "standard"}}).Rollout)
+	assert.Equal(t, Rollout{Turns: 1, Policy: RolloutRandom}, searchConfigFor(Game{Ruleset: Ruleset{Name: "royale"}}).Rollout)
+}
+
+func TestStaticEvalAgainstRollouts(t *testing.T) {
+	// against the left wall, with the only way out of the corner upwards. down is a one cell pocket, well
+	// away from the tail
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "me", Health: 100, Head: Point{X: 0, Y: 1}, Body: []Point{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}, {X: 3, Y: 1}, {X: 3, Y: 2}}},
+			{ID: "them", Health: 100, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+		},
+	}
+
+	for _, rollout := range []Rollout{{}, {Turns: 4}, {Turns: 4, Policy: RolloutRandom}} {
+		config := SearchConfig{Rollout: rollout}
+		root := MCTS(context.Background(), "test", copyBoard(board), 3000, 2, make(map[string]*Node), WithSearchConfig(config))
+		choice := chooseRootChild(root)
+		require.NotNil(t, choice.Node, "rollout %+v", rollout)
+		assert.Equal(t, "up", moveForChild(root, choice.Node), "rollout %+v", rollout)
+	}
+}
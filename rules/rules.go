@@ -0,0 +1,245 @@
+// Package rules plays a turn of Battlesnake exactly the way the official rules engine does. It's kept
+// apart from the engine's own simulation, which trades exactness for speed, so the simulation has
+// something to be checked against and the tester has a board it can trust.
+package rules
+
+// SnakeMaxHealth is the health a snake starts with and gets back when it eats.
+const SnakeMaxHealth = 100
+
+// The moves a snake can make.
+const (
+	MoveUp    = "up"
+	MoveDown  = "down"
+	MoveLeft  = "left"
+	MoveRight = "right"
+)
+
+// Why a snake was eliminated, the way the official engine reports it.
+const (
+	NotEliminated             = ""
+	EliminatedByOutOfHealth   = "out-of-health"
+	EliminatedByOutOfBounds   = "wall-collision"
+	EliminatedBySelfCollision = "snake-self-collision"
+	EliminatedByCollision     = "snake-collision"
+	EliminatedByHeadToHead    = "head-collision"
+)
+
+type Point struct {
+	X int
+	Y int
+}
+
+// Snake is a snake on the board. An eliminated snake keeps its body and says why it went, but takes no
+// further part in the game.
+type Snake struct {
+	ID              string
+	Body            []Point
+	Health          int
+	EliminatedCause string
+}
+
+// BoardState is the board at the start or end of a turn.
+type BoardState struct {
+	Width   int
+	Height  int
+	Food    []Point
+	Hazards []Point
+	Snakes  []Snake
+}
+
+// Settings are the parts of the ruleset a turn depends on.
+type Settings struct {
+	HazardDamagePerTurn int
+	Wrapped             bool
+}
+
+// Step plays one turn with the snakes' moves, by snake ID, and returns the board after it. The board
+// passed in isn't changed. It runs the standard ruleset's stages in order: every snake moves, loses a
+// point of health and takes any hazard damage, food is eaten, and then snakes are eliminated. Food isn't
+// spawned, since that's random and the caller decides how it wants to do it.
+func Step(state BoardState, settings Settings, moves map[string]string) BoardState {
+	next := state.clone()
+	moveSnakes(&next, settings, moves)
+	reduceHealth(&next)
+	damageHazards(&next, settings)
+	feedSnakes(&next)
+	eliminateSnakes(&next)
+	return next
+}
+
+// IsGameOver says whether one snake or none is left.
+func IsGameOver(state BoardState) bool {
+	alive := 0
+	for _, snake := range state.Snakes {
+		if snake.EliminatedCause == NotEliminated {
+			alive++
+		}
+	}
+	return alive <= 1
+}
+
+func (b BoardState) clone() BoardState {
+	next := BoardState{
+		Width:   b.Width,
+		Height:  b.Height,
+		Food:    append([]Point(nil), b.Food...),
+		Hazards: append([]Point(nil), b.Hazards...),
+		Snakes:  make([]Snake, len(b.Snakes)),
+	}
+	for i, snake := range b.Snakes {
+		snake.Body = append([]Point(nil), snake.Body...)
+		next.Snakes[i] = snake
+	}
+	return next
+}
+
+// moveSnakes moves every snake still in the game. A snake without a move carries on the way it was going,
+// or up if it hasn't gone anywhere yet, which is what the engine does when a snake times out.
+func moveSnakes(b *BoardState, settings Settings, moves map[string]string) {
+	for i := range b.Snakes {
+		snake := &b.Snakes[i]
+		if snake.EliminatedCause != NotEliminated || len(snake.Body) == 0 {
+			continue
+		}
+		move, ok := moves[snake.ID]
+		if !ok {
+			move = lastMove(*snake)
+		}
+		head := moved(snake.Body[0], move)
+		if settings.Wrapped {
+			head.X = (head.X%b.Width + b.Width) % b.Width
+			head.Y = (head.Y%b.Height + b.Height) % b.Height
+		}
+		snake.Body = append([]Point{head}, snake.Body[:len(snake.Body)-1]...)
+	}
+}
+
+func moved(head Point, move string) Point {
+	switch move {
+	case MoveDown:
+		return Point{X: head.X, Y: head.Y - 1}
+	case MoveLeft:
+		return Point{X: head.X - 1, Y: head.Y}
+	case MoveRight:
+		return Point{X: head.X + 1, Y: head.Y}
+	default:
+		return Point{X: head.X, Y: head.Y + 1}
+	}
+}
+
+// lastMove is the way the snake went last turn.
+func lastMove(snake Snake) string {
+	if len(snake.Body) < 2 {
+		return MoveUp
+	}
+	for _, move := range []string{MoveUp, MoveDown, MoveLeft, MoveRight} {
+		if moved(snake.Body[1], move) == snake.Body[0] {
+			return move
+		}
+	}
+	return MoveUp
+}
+
+func reduceHealth(b *BoardState) {
+	for i := range b.Snakes {
+		if b.Snakes[i].EliminatedCause == NotEliminated {
+			b.Snakes[i].Health--
+		}
+	}
+}
+
+// damageHazards takes the ruleset's damage off for every hazard stacked under a snake's head, unless
+// there's food there too.
+func damageHazards(b *BoardState, settings Settings) {
+	if settings.HazardDamagePerTurn <= 0 {
+		return
+	}
+	for i := range b.Snakes {
+		snake := &b.Snakes[i]
+		if snake.EliminatedCause != NotEliminated || contains(b.Food, snake.Body[0]) {
+			continue
+		}
+		for _, hazard := range b.Hazards {
+			if hazard == snake.Body[0] {
+				snake.Health = max(0, snake.Health-settings.HazardDamagePerTurn)
+			}
+		}
+	}
+}
+
+// feedSnakes lets every snake whose head is on food eat it. Two snakes on the same food both eat.
+func feedSnakes(b *BoardState) {
+	var food []Point
+	for _, f := range b.Food {
+		eaten := false
+		for i := range b.Snakes {
+			snake := &b.Snakes[i]
+			if snake.EliminatedCause != NotEliminated || snake.Body[0] != f {
+				continue
+			}
+			snake.Health = SnakeMaxHealth
+			snake.Body = append(snake.Body, snake.Body[len(snake.Body)-1])
+			eaten = true
+		}
+		if !eaten {
+			food = append(food, f)
+		}
+	}
+	b.Food = food
+}
+
+// eliminateSnakes takes out the snakes out of health or off the board first, and then the ones that ran
+// into a body or lost a head to head. Collisions are only with snakes that survived the first part, and
+// they all happen at once.
+func eliminateSnakes(b *BoardState) {
+	for i := range b.Snakes {
+		snake := &b.Snakes[i]
+		if snake.EliminatedCause != NotEliminated {
+			continue
+		}
+		head := snake.Body[0]
+		switch {
+		case snake.Health <= 0:
+			snake.EliminatedCause = EliminatedByOutOfHealth
+		case head.X < 0 || head.X >= b.Width || head.Y < 0 || head.Y >= b.Height:
+			snake.EliminatedCause = EliminatedByOutOfBounds
+		}
+	}
+
+	causes := make(map[int]string)
+	for i, snake := range b.Snakes {
+		if snake.EliminatedCause != NotEliminated {
+			continue
+		}
+		head := snake.Body[0]
+		if contains(snake.Body[1:], head) {
+			causes[i] = EliminatedBySelfCollision
+			continue
+		}
+		for j, other := range b.Snakes {
+			if i == j || other.EliminatedCause != NotEliminated {
+				continue
+			}
+			if contains(other.Body[1:], head) {
+				causes[i] = EliminatedByCollision
+				break
+			}
+			if other.Body[0] == head && len(snake.Body) <= len(other.Body) {
+				causes[i] = EliminatedByHeadToHead
+				break
+			}
+		}
+	}
+	for i, cause := range causes {
+		b.Snakes[i].EliminatedCause = cause
+	}
+}
+
+func contains(points []Point, p Point) bool {
+	for _, point := range points {
+		if point == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep(t *testing.T) {
+	testCases := []struct {
+		Description string
+		State       BoardState
+		Settings    Settings
+		Moves       map[string]string
+		Expected    BoardState
+	}{
+		{
+			Description: "Snake eating on its last point of health survives",
+			State: BoardState{
+				Width: 5, Height: 5,
+				Food:   []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{{ID: "a", Health: 1, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}}},
+			},
+			Moves: map[string]string{"a": MoveUp},
+			Expected: BoardState{
+				Width: 5, Height: 5,
+				Snakes: []Snake{{ID: "a", Health: 100, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 2, Y: 2}}}},
+			},
+		},
+		{
+			Description: "Equal snakes both lose a head to head",
+			State: BoardState{
+				Width: 5, Height: 5,
+				Snakes: []Snake{
+					{ID: "a", Health: 50, Body: []Point{{X: 1, Y: 2}, {X: 0, Y: 2}}},
+					{ID: "b", Health: 50, Body: []Point{{X: 3, Y: 2}, {X: 4, Y: 2}}},
+				},
+			},
+			Moves: map[string]string{"a": MoveRight, "b": MoveLeft},
+			Expected: BoardState{
+				Width: 5, Height: 5,
+				Snakes: []Snake{
+					{ID: "a", Health: 49, Body: []Point{{X: 2, Y: 2}, {X: 1, Y: 2}}, EliminatedCause: EliminatedByHeadToHead},
+					{ID: "b", Health: 49, Body: []Point{{X: 2, Y: 2}, {X: 3, Y: 2}}, EliminatedCause: EliminatedByHeadToHead},
+				},
+			},
+		},
+		{
+			Description: "Snake without a move carries on into the wall",
+			State: BoardState{
+				Width: 3, Height: 3,
+				Snakes: []Snake{{ID: "a", Health: 50, Body: []Point{{X: 2, Y: 1}, {X: 1, Y: 1}}}},
+			},
+			Moves: map[string]string{},
+			Expected: BoardState{
+				Width: 3, Height: 3,
+				Snakes: []Snake{{ID: "a", Health: 49, Body: []Point{{X: 3, Y: 1}, {X: 2, Y: 1}}, EliminatedCause: EliminatedByOutOfBounds}},
+			},
+		},
+		{
+			Description: "Hazard damage stacks and starves the snake",
+			State: BoardState{
+				Width: 5, Height: 5,
+				Hazards: []Point{{X: 2, Y: 3}, {X: 2, Y: 3}},
+				Snakes:  []Snake{{ID: "a", Health: 20, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}}},
+			},
+			Settings: Settings{HazardDamagePerTurn: 14},
+			Moves:    map[string]string{"a": MoveUp},
+			Expected: BoardState{
+				Width: 5, Height: 5,
+				Hazards: []Point{{X: 2, Y: 3}, {X: 2, Y: 3}},
+				Snakes:  []Snake{{ID: "a", Health: 0, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}, EliminatedCause: EliminatedByOutOfHealth}},
+			},
+		},
+		{
+			Description: "Moving onto a tail that's moving on is safe, but not onto one that just grew",
+			State: BoardState{
+				Width: 7, Height: 7,
+				Snakes: []Snake{
+					{ID: "a", Health: 50, Body: []Point{{X: 0, Y: 1}, {X: 0, Y: 2}}},
+					{ID: "b", Health: 50, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+					{ID: "c", Health: 50, Body: []Point{{X: 3, Y: 5}, {X: 3, Y: 6}}},
+					{ID: "d", Health: 50, Body: []Point{{X: 5, Y: 4}, {X: 5, Y: 5}, {X: 4, Y: 5}, {X: 4, Y: 5}}},
+				},
+			},
+			Moves: map[string]string{"a": MoveDown, "b": MoveUp, "c": MoveRight, "d": MoveDown},
+			Expected: BoardState{
+				Width: 7, Height: 7,
+				Snakes: []Snake{
+					{ID: "a", Health: 49, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}}},
+					{ID: "b", Health: 49, Body: []Point{{X: 1, Y: 2}, {X: 1, Y: 1}, {X: 1, Y: 0}}},
+					{ID: "c", Health: 49, Body: []Point{{X: 4, Y: 5}, {X: 3, Y: 5}}, EliminatedCause: EliminatedByCollision},
+					{ID: "d", Health: 49, Body: []Point{{X: 5, Y: 3}, {X: 5, Y: 4}, {X: 5, Y: 5}, {X: 4, Y: 5}}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			before := tc.State.clone()
+			assert.Equal(t, tc.Expected, Step(tc.State, tc.Settings, tc.Moves))
+			assert.Equal(t, before, tc.State, "the board passed in shouldn't change")
+		})
+	}
+}
+
+func TestIsGameOver(t *testing.T) {
+	state := BoardState{Snakes: []Snake{{ID: "a"}, {ID: "b", EliminatedCause: EliminatedByCollision}}}
+	assert.True(t, IsGameOver(state))
+	state.Snakes[1].EliminatedCause = NotEliminated
+	assert.False(t, IsGameOver(state))
+}
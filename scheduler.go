@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SearchTicket is one search's share of the workers. Workers beyond the share wait until it grows,
+// rather than stopping, since another game finishing hands its workers back.
+type SearchTicket struct {
+	GameID   string
+	Deadline time.Time
+
+	limit      int32 // workers this search may run right now
+	fewest     int32 // the smallest share it's had
+	mu         sync.Mutex
+	changed    chan struct{} // closed whenever the share changes
+	registered time.Time
+}
+
+// Workers is how many workers the search may run right now.
+func (t *SearchTicket) Workers() int {
+	return int(atomic.LoadInt32(&t.limit))
+}
+
+// FewestWorkers is the smallest share the search has had, which is what it had to make do with.
+func (t *SearchTicket) FewestWorkers() int {
+	return int(atomic.LoadInt32(&t.fewest))
+}
+
+// allows says whether the worker with this index is inside the search's share.
+func (t *SearchTicket) allows(worker int) bool {
+	return t == nil || int32(worker) < atomic.LoadInt32(&t.limit)
+}
+
+// wait blocks the worker until it's inside the search's share, returning false if the context ends
+// or the search finishes first.
+func (t *SearchTicket) wait(ctx context.Context, finished <-chan struct{}, worker int) bool {
+	for {
+		if t.allows(worker) {
+			return true
+		}
+		t.mu.Lock()
+		changed := t.changed
+		t.mu.Unlock()
+		// the share might have grown between checking and picking up the channel
+		if t.allows(worker) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-finished:
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// setLimit changes the share and wakes any workers waiting on it.
+func (t *SearchTicket) setLimit(limit int) {
+	atomic.StoreInt32(&t.limit, int32(limit))
+	for {
+		fewest := atomic.LoadInt32(&t.fewest)
+		if fewest != 0 && fewest <= int32(limit) {
+			break
+		}
+		if atomic.CompareAndSwapInt32(&t.fewest, fewest, int32(limit)) {
+			break
+		}
+	}
+	t.mu.Lock()
+	close(t.changed)
+	t.changed = make(chan struct{})
+	t.mu.Unlock()
+}
+
+// SearchScheduler shares the workers between the searches running on this instance. Without it every game
+// spawns a worker per cpu and they all fight over the same cpus, so a game about to run out of time gets no more
+// than one that's just started. Workers are handed out in proportion to how long each search has left.
+type SearchScheduler struct {
+	workers int
+
+	mu          sync.Mutex
+	active      map[*SearchTicket]struct{}
+	rebalances  int64
+	contentions int64 // searches registered while another was running
+}
+
+func newSearchScheduler(workers int) *SearchScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &SearchScheduler{
+		workers: workers,
+		active:  make(map[*SearchTicket]struct{}),
+	}
+}
+
+// searchScheduler is shared by every game's search.
+var searchScheduler = newSearchScheduler(runtime.NumCPU())
+
+// Register adds a search that has until the deadline and reshares the workers.
+func (s *SearchScheduler) Register(gameID string, deadline time.Time) *SearchTicket {
+	ticket := &SearchTicket{
+		GameID:     gameID,
+		Deadline:   deadline,
+		changed:    make(chan struct{}),
+		registered: time.Now(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.active) > 0 {
+		s.contentions++
+	}
+	s.active[ticket] = struct{}{}
+	s.rebalance(time.Now())
+	return ticket
+}
+
+// Release hands the search's workers back to the others.
+func (s *SearchScheduler) Release(ticket *SearchTicket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.active[ticket]; !ok {
+		return
+	}
+	delete(s.active, ticket)
+	s.rebalance(time.Now())
+}
+
+// yieldInterval is how often a search yielding to the games checks whether any are searching.
+const yieldInterval = 10 * time.Millisecond
+
+// Yield is a ticket for a search that isn't a game's, like /analyze: it gets no share of its own, just one
+// worker while any game is searching and all of them otherwise, checked until the context ends.
+func (s *SearchScheduler) Yield(ctx context.Context, workers int) *SearchTicket {
+	ticket := &SearchTicket{Deadline: time.Now(), changed: make(chan struct{}), registered: time.Now()}
+	share := func() int {
+		if s.Busy() {
+			return 1
+		}
+		return workers
+	}
+	ticket.setLimit(share())
+	go func() {
+		tick := time.NewTicker(yieldInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if limit := share(); limit != ticket.Workers() {
+					ticket.setLimit(limit)
+				}
+			}
+		}
+	}()
+	return ticket
+}
+
+// Busy says whether any search is running.
+func (s *SearchScheduler) Busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.active) > 0
+}
+
+// rebalance shares the workers out in proportion to each search's remaining time, with at least one each.
+// Callers hold the lock.
+func (s *SearchScheduler) rebalance(now time.Time) {
+	s.rebalances++
+	tickets := make([]*SearchTicket, 0, len(s.active))
+	for ticket := range s.active {
+		tickets = append(tickets, ticket)
+	}
+	if len(tickets) == 0 {
+		return
+	}
+	remaining := func(ticket *SearchTicket) time.Duration {
+		if left := ticket.Deadline.Sub(now); left > time.Millisecond {
+			return left
+		}
+		return time.Millisecond
+	}
+	sort.Slice(tickets, func(i, j int) bool {
+		return remaining(tickets[i]) > remaining(tickets[j])
+	})
+
+	// more searches than workers, so they'll have to share
+	spare := s.workers - len(tickets)
+	if spare <= 0 {
+		for _, ticket := range tickets {
+			ticket.setLimit(1)
+		}
+		return
+	}
+
+	var total time.Duration
+	for _, ticket := range tickets {
+		total += remaining(ticket)
+	}
+	shares := make([]int, len(tickets))
+	fractions := make([]float64, len(tickets))
+	handed := 0
+	for i, ticket := range tickets {
+		exact := float64(spare) * float64(remaining(ticket)) / float64(total)
+		shares[i] = int(exact)
+		fractions[i] = exact - float64(shares[i])
+		handed += shares[i]
+	}
+	// whatever rounding left over goes to the searches it was shaved off most
+	order := make([]int, len(tickets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return fractions[order[a]] > fractions[order[b]]
+	})
+	for _, i := range order[:spare-handed] {
+		shares[i]++
+	}
+	for i, ticket := range tickets {
+		ticket.setLimit(1 + shares[i])
+	}
+}
+
+// SchedulerAllocation is one running search's share of the workers.
+type SchedulerAllocation struct {
+	GameID      string `json:"game_id"`
+	Workers     int    `json:"workers"`
+	RemainingMs int64  `json:"remaining_ms"`
+	RunningMs   int64  `json:"running_ms"`
+}
+
+// SchedulerStats is how the workers are shared out right now, for the admin api.
+type SchedulerStats struct {
+	Workers     int                   `json:"workers"`
+	Active      []SchedulerAllocation `json:"active"`
+	Rebalances  int64                 `json:"rebalances"`
+	Contentions int64                 `json:"contentions"`
+}
+
+// Stats reports every running search's share, the one with the most time left first.
+func (s *SearchScheduler) Stats() SchedulerStats {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := SchedulerStats{
+		Workers:     s.workers,
+		Active:      make([]SchedulerAllocation, 0, len(s.active)),
+		Rebalances:  s.rebalances,
+		Contentions: s.contentions,
+	}
+	for ticket := range s.active {
+		stats.Active = append(stats.Active, SchedulerAllocation{
+			GameID:      ticket.GameID,
+			Workers:     ticket.Workers(),
+			RemainingMs: ticket.Deadline.Sub(now).Milliseconds(),
+			RunningMs:   now.Sub(ticket.registered).Milliseconds(),
+		})
+	}
+	sort.Slice(stats.Active, func(i, j int) bool {
+		return stats.Active[i].RemainingMs > stats.Active[j].RemainingMs
+	})
+	return stats
+}
+
+// handleScheduler shows how the workers are shared between the games searching right now.
+func handleScheduler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, searchScheduler.Stats())
+}
+
+// WithSearchTicket keeps the search to the workers the scheduler gave it.
+func WithSearchTicket(ticket *SearchTicket) func(*searchOptions) {
+	return func(o *searchOptions) {
+		o.ticket = ticket
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerSharesByRemainingTime(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		Description string
+		Workers     int
+		Remaining   []time.Duration
+		Want        []int
+	}{
+		{Description: "alone", Workers: 8, Remaining: []time.Duration{400 * time.Millisecond}, Want: []int{8}},
+		{Description: "even", Workers: 8, Remaining: []time.Duration{400 * time.Millisecond, 400 * time.Millisecond}, Want: []int{4, 4}},
+		{Description: "nearly done gets less", Workers: 8, Remaining: []time.Duration{450 * time.Millisecond, 150 * time.Millisecond}, Want: []int{6, 2}},
+		{Description: "past the deadline still gets one", Workers: 4, Remaining: []time.Duration{400 * time.Millisecond, -time.Second}, Want: []int{3, 1}},
+		{Description: "more searches than workers", Workers: 2, Remaining: []time.Duration{400 * time.Millisecond, 300 * time.Millisecond, 200 * time.Millisecond}, Want: []int{1, 1, 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			scheduler := newSearchScheduler(tc.Workers)
+			var tickets []*SearchTicket
+			for _, remaining := range tc.Remaining {
+				tickets = append(tickets, scheduler.Register("game", now.Add(remaining)))
+			}
+
+			got := make([]int, len(tickets))
+			total := 0
+			for i, ticket := range tickets {
+				got[i] = ticket.Workers()
+				total += got[i]
+			}
+			assert.Equal(t, tc.Want, got)
+			if len(tickets) <= tc.Workers {
+				assert.Equal(t, tc.Workers, total, "every worker is handed out")
+			}
+		})
+	}
+}
+
+func TestSchedulerReleaseHandsWorkersBack(t *testing.T) {
+	scheduler := newSearchScheduler(4)
+	first := scheduler.Register("first", time.Now().Add(400*time.Millisecond))
+	assert.Equal(t, 4, first.Workers())
+
+	second := scheduler.Register("second", time.Now().Add(400*time.Millisecond))
+	assert.Equal(t, 2, first.Workers())
+	assert.Equal(t, 2, second.Workers())
+
+	scheduler.Release(second)
+	scheduler.Release(second) // releasing twice is harmless
+	assert.Equal(t, 4, first.Workers())
+	assert.Equal(t, 2, first.FewestWorkers(), "remembers what it had to make do with")
+
+	stats := scheduler.Stats()
+	assert.Equal(t, 4, stats.Workers)
+	assert.Equal(t, int64(1), stats.Contentions)
+	require.Len(t, stats.Active, 1)
+	assert.Equal(t, "first", stats.Active[0].GameID)
+	assert.Equal(t, 4, stats.Active[0].Workers)
+}
+
+func TestSearchTicketWaitsForItsShare(t *testing.T) {
+	scheduler := newSearchScheduler(2)
+	ticket := scheduler.Register("waiting", time.Now().Add(time.Second))
+	other := scheduler.Register("other", time.Now().Add(time.Second))
+	require.Equal(t, 1, ticket.Workers())
+
+	assert.True(t, ticket.wait(context.Background(), nil, 0), "inside the share")
+
+	woke := make(chan bool)
+	go func() { woke <- ticket.wait(context.Background(), nil, 1) }()
+	select {
+	case <-woke:
+		t.Fatal("worker outside the share should wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	scheduler.Release(other)
+	select {
+	case ok := <-woke:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("worker wasn't woken when the share grew")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, ticket.wait(ctx, nil, 5), "gives up when the search is cancelled")
+	finished := make(chan struct{})
+	close(finished)
+	assert.False(t, ticket.wait(context.Background(), finished, 5), "gives up when the search has its visits")
+
+	var none *SearchTicket
+	assert.True(t, none.wait(ctx, nil, 5), "searches without a ticket use every worker")
+}
+
+func TestSearchWithTicketStillFinishes(t *testing.T) {
+	scheduler := newSearchScheduler(4)
+	scheduler.Register("busy", time.Now().Add(time.Second))
+	ticket := scheduler.Register("search", time.Now().Add(time.Second))
+	require.Equal(t, 2, ticket.Workers())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	root := MCTS(ctx, "scheduled", evalTestBoard(), 300, 4, make(map[string]*Node), WithSearchTicket(ticket))
+	assert.GreaterOrEqual(t, root.Visits, int64(300), "the workers inside the share finish the search")
+	assert.Less(t, time.Since(start), 2*time.Second, "the waiting workers shouldn't hold the search open until the deadline")
+}
+
+func TestYieldGivesWayToGames(t *testing.T) {
+	scheduler := newSearchScheduler(8)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ticket := scheduler.Yield(ctx, 8)
+	assert.Equal(t, 8, ticket.Workers(), "nothing else is searching")
+
+	game := scheduler.Register("game", time.Now().Add(400*time.Millisecond))
+	assert.Equal(t, 8, game.Workers(), "the game's share doesn't give anything up for it")
+	assert.Eventually(t, func() bool { return ticket.Workers() == 1 }, time.Second, yieldInterval)
+
+	scheduler.Release(game)
+	assert.Eventually(t, func() bool { return ticket.Workers() == 8 }, time.Second, yieldInterval)
+}
+
+func TestHandleScheduler(t *testing.T) {
+	saved := searchScheduler
+	t.Cleanup(func() { searchScheduler = saved })
+	searchScheduler = newSearchScheduler(4)
+	searchScheduler.Register("game-1", time.Now().Add(time.Second))
+
+	rec := httptest.NewRecorder()
+	newRouter("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/scheduler", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats SchedulerStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Len(t, stats.Active, 1)
+	assert.Equal(t, "game-1", stats.Active[0].GameID)
+	assert.Equal(t, 4, stats.Active[0].Workers)
+}
@@ -0,0 +1,77 @@
+package main
+
+// ScoringMode decides how a leaf's evaluation is shared out between the snakes.
+type ScoringMode int
+
+const (
+	// ScoringAuto plays zero sum once it's down to two snakes and multi-player before that.
+	ScoringAuto ScoringMode = iota
+	// ScoringMultiPlayer evaluates the board separately from every snake's point of view.
+	ScoringMultiPlayer
+	// ScoringZeroSum evaluates the board once and gives the other snakes exactly the negative,
+	// so whatever is good for us is bad for them by the same amount, however many are left.
+	ScoringZeroSum
+)
+
+func (m ScoringMode) String() string {
+	switch m {
+	case ScoringMultiPlayer:
+		return "multi-player"
+	case ScoringZeroSum:
+		return "zero-sum"
+	}
+	return "auto"
+}
+
+// zeroSum says whether the board should be scored zero sum. Auto only does once it's down to two snakes,
+// forcing it plays every other snake as if it were out to get us.
+func (m ScoringMode) zeroSum(board Board) bool {
+	alive := 0
+	for _, snake := range board.Snakes {
+		if !isSnakeDead(snake) {
+			alive++
+		}
+	}
+	switch m {
+	case ScoringMultiPlayer:
+		return false
+	case ScoringZeroSum:
+		return alive >= 2
+	}
+	return alive == 2
+}
+
+// scores evaluates the board for every snake the way the mode says to.
+func (m ScoringMode) scores(board Board, modules []EvaluationModule) []float64 {
+	if m.zeroSum(board) {
+		return evaluateZeroSum(board, modules)
+	}
+	return evaluateScores(board, modules)
+}
+
+// evaluateZeroSum scores the board from the first living snake's point of view and gives every other living
+// snake the exact negative. Dead snakes get the usual dead score.
+func evaluateZeroSum(board Board, modules []EvaluationModule) []float64 {
+	scores := make([]float64, len(board.Snakes))
+	first := -1
+	var others []int
+	for i, snake := range board.Snakes {
+		switch {
+		case isSnakeDead(snake):
+			scores[i] = -2
+		case first == -1:
+			first = i
+		default:
+			others = append(others, i)
+		}
+	}
+	if first == -1 || len(others) == 0 {
+		return evaluateScores(board, modules)
+	}
+
+	scores[first] = evaluateBoard(board, first, modules)
+	for _, i := range others {
+		scores[i] = -scores[first]
+	}
+	return scores
+}
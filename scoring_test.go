@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoringModeZeroSum(t *testing.T) {
+	duel := evalTestBoard()
+	duel.Snakes = duel.Snakes[:2]
+	threeWay := evalTestBoard()
+	deadThird := evalTestBoard()
+	deadThird.Snakes[1].Health = 0
+	alone := evalTestBoard()
+	alone.Snakes = alone.Snakes[:1]
+
+	testCases := []struct {
+		Description string
+		Mode        ScoringMode
+		Board       Board
+		Expected    bool
+	}{
+		{Description: "auto duel", Mode: ScoringAuto, Board: duel, Expected: true},
+		{Description: "auto three way", Mode: ScoringAuto, Board: threeWay, Expected: false},
+		{Description: "auto down to two", Mode: ScoringAuto, Board: deadThird, Expected: true},
+		{Description: "multi-player duel", Mode: ScoringMultiPlayer, Board: duel, Expected: false},
+		{Description: "zero sum duel", Mode: ScoringZeroSum, Board: duel, Expected: true},
+		{Description: "zero sum three way", Mode: ScoringZeroSum, Board: threeWay, Expected: true},
+		{Description: "zero sum alone", Mode: ScoringZeroSum, Board: alone, Expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Mode.zeroSum(tc.Board))
+		})
+	}
+}
+
+func TestEvaluateZeroSum(t *testing.T) {
+	board := evalTestBoard()
+	board.Snakes[1].Health = 0
+	scores := evaluateZeroSum(board, modules)
+
+	assert.Equal(t, evaluateBoard(board, 0, modules), scores[0])
+	assert.Equal(t, -2.0, scores[1])
+	assert.Equal(t, -scores[0], scores[2], "the opponent gets exactly the negative")
+
+	threeWay := evalTestBoard()
+	scores = evaluateZeroSum(threeWay, modules)
+	assert.Equal(t, evaluateBoard(threeWay, 0, modules), scores[0])
+	assert.Equal(t, -scores[0], scores[1], "everyone else gets the negative")
+	assert.Equal(t, -scores[0], scores[2], "everyone else gets the negative")
+}
+
+// cornerPinch has us in the bottom left corner with them alongside, and food up the board. Going up for it
+// lets them step across in front of us and shut us in against the wall, going down follows our own tail
+// round where they can't get at us.
+func cornerPinch() Board {
+	return Board{
+		Width: 7, Height: 7,
+		Food: []Point{{X: 3, Y: 3}},
+		Snakes: []Snake{
+			{ID: "us", Health: 90, Head: Point{X: 0, Y: 1}, Body: []Point{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "them", Health: 90, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}, {X: 2, Y: 0}}},
+		},
+	}
+}
+
+func TestCornerPinchIsLostGoingUp(t *testing.T) {
+	board := cornerPinch()
+	up := &endgameSolver{ctx: context.Background(), maxNodes: endgameMaxNodes, proven: make(map[string]endgameValue)}
+	assert.Equal(t, endgameLoss, up.reply(board, Up, 8))
+	down := &endgameSolver{ctx: context.Background(), maxNodes: endgameMaxNodes, proven: make(map[string]endgameValue)}
+	assert.NotEqual(t, endgameLoss, down.reply(board, Down, 8))
+}
+
+func TestZeroSumSeesThePinch(t *testing.T) {
+	duel := cornerPinch()
+	// far enough away to have nothing to do with it, but it makes three
+	threeWay := cornerPinch()
+	threeWay.Snakes = append(threeWay.Snakes, Snake{ID: "other", Health: 90, Head: Point{X: 6, Y: 6}, Body: []Point{{X: 6, Y: 6}, {X: 5, Y: 6}, {X: 4, Y: 6}}})
+
+	testCases := []struct {
+		Description string
+		Mode        ScoringMode
+		Board       Board
+		Expected    Direction
+	}{
+		{Description: "zero sum duel", Mode: ScoringZeroSum, Board: duel, Expected: Down},
+		{Description: "auto duel", Mode: ScoringAuto, Board: duel, Expected: Down},
+		{Description: "multi-player duel expects them to go for the food", Mode: ScoringMultiPlayer, Board: duel, Expected: Up},
+		{Description: "zero sum three way", Mode: ScoringZeroSum, Board: threeWay, Expected: Down},
+		{Description: "auto three way plays multi-player", Mode: ScoringAuto, Board: threeWay, Expected: Up},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			// two turns is deep enough to see them cut across and shallow enough that only the scoring decides it
+			result := maxnSearchTo(context.Background(), tc.Board, SearchConfig{Scoring: tc.Mode}, MaxNNone, 2)
+			assert.Equal(t, tc.Expected, result.Move)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// SearchConfig tunes how the search spends its time.
+type SearchConfig struct {
+	// leaves whose parent has fewer visits than this are scored with the cheap modules, so quiet lines
+	// cost less and the search gets deeper. 0 scores every leaf with the full modules.
+	FullEvalVisits int64
+	// visit counts, increasing, at which a node's own score is worked out again with a move of lookahead,
+	// so an unlucky first evaluation doesn't stick for the rest of the search.
+	ReevalVisits []int64
+	// how each leaf's evaluation is shared between the snakes
+	Scoring ScoringMode
+	// how each snake is expected to pick its moves, by snake index. snakes without one are searched like us.
+	Opponents []OpponentModel
+	// what a head to head trade is worth to us depending on how the game stands. nil scores it like any death.
+	Luck *LuckScale
+	// whether the workers share one tree or grow their own from the root
+	Parallel ParallelMode
+	// what each worker still on its way down through a node counts against it, so workers spread out
+	// instead of all descending to the same leaf. 0 turns it off.
+	VirtualLoss float64
+	// how far each leaf is played out before it's evaluated. none evaluates the leaf as it is.
+	Rollout Rollout
+	// the full modules leaves are scored with. nil uses the engine's own.
+	Modules []EvaluationModule
+	// the move priors for PUCT selection. nil keeps plain UCT.
+	Policy Policy
+	// which algorithm picks the move, and how MaxN prunes when it's the one
+	Engine  SearchEngine
+	Pruning MaxNPruning
+
+	// how the game stands for us at the root, -1 lost to 1 won. the search works it out when it starts.
+	standing float64
+}
+
+// defaultSearchConfig is what searches use unless told otherwise. CHEAP_EVAL_VISITS turns on the cheap tier
+// and REEVAL_VISITS (e.g. "200,2000") turns on re-evaluation. SEARCH_PARALLEL=root gives each worker its own tree
+// and VIRTUAL_LOSS sets how hard workers are pushed apart. POLICY_PRIORS=heuristic expands the likeliest moves
+// first and picks children by PUCT. ENGINE pins the algorithm, which otherwise is picked every turn, with
+// MAXN_PRUNING for MaxN. Rollouts depend on the ruleset, see searchConfigFor.
+var defaultSearchConfig = loadSearchConfig()
+
+func loadSearchConfig() SearchConfig {
+	config := SearchConfig{
+		ReevalVisits: reevalThresholdsFromEnv(),
+		Luck:         luckScaleFromEnv(),
+		Parallel:     parallelModeFromEnv(),
+		VirtualLoss:  virtualLossFromEnv(),
+		Policy:       policyFromEnv(),
+		Engine:       searchEngineFromEnv(),
+		Pruning:      maxnPruningFromEnv(),
+	}
+	// several trees is what sets the multi-tree engine apart
+	if config.Engine == EngineMultiMCTS {
+		config.Parallel = ParallelRoot
+	}
+	if visits, err := strconv.ParseInt(os.Getenv("CHEAP_EVAL_VISITS"), 10, 64); err == nil && visits > 0 {
+		config.FullEvalVisits = visits
+	}
+	return config
+}
+
+// WithSearchConfig replaces the default search config for this search.
+func WithSearchConfig(config SearchConfig) func(*searchOptions) {
+	return func(o *searchOptions) {
+		o.config = config
+	}
+}
+
+// wantsFullEval says whether the node is on a line the search cares about enough to pay for the full modules.
+func (c SearchConfig) wantsFullEval(node *Node) bool {
+	if c.FullEvalVisits <= 0 || node.Parent == nil {
+		return true
+	}
+	return atomic.LoadInt64(&node.Parent.Visits) >= c.FullEvalVisits
+}
+
+// evaluate scores a leaf for every snake with whichever tier of modules the config picks for it, after
+// playing it out if the config has a rollout.
+func (c SearchConfig) evaluate(node *Node) []float64 {
+	board := c.Rollout.play(node.Board, node.SnakeIndex)
+	if c.wantsFullEval(node) {
+		return c.scores(board, c.fullModules())
+	}
+	return c.scores(board, cheapModules)
+}
+
+// fullModules is the modules the config scores leaves with when it isn't cutting corners.
+func (c SearchConfig) fullModules() []EvaluationModule {
+	if c.Modules != nil {
+		return c.Modules
+	}
+	return modules
+}
+
+// scores evaluates the board for every snake the way the scoring mode says to, then values a trade for us
+// by how the game stands.
+func (c SearchConfig) scores(board Board, modules []EvaluationModule) []float64 {
+	return c.applyLuck(board, c.Scoring.scores(board, modules))
+}
+
+var (
+	// cheapModules only look at what the context has already worked out, nothing needs a flood fill.
+	cheapModules = []EvaluationModule{
+		{
+			Name:     "space",
+			EvalFunc: spaceEvaluation,
+			Weight:   6,
+		},
+		{
+			Name:     "length",
+			EvalFunc: lengthEvaluation,
+			Weight:   6,
+		},
+		{
+			Name:     "health",
+			EvalFunc: healthEvaluation,
+			Weight:   1,
+		},
+	}
+)
+
+// spaceRadius is how far from its head a snake's free space is counted in the cheap tier.
+const spaceRadius = 3
+
+// spaceEvaluation compares the free cells near our head with the free cells near the opponents' heads.
+// It's a rough stand in for voronoi that doesn't need a flood fill.
+func spaceEvaluation(ec *EvaluationContext) float64 {
+	rootSpace := float64(freeCellsNear(ec, ec.Board.Snakes[ec.RootIndex].Head))
+	opponentSpace := 0.0
+	for i, snake := range ec.Board.Snakes {
+		if i != ec.RootIndex && ec.Alive[i] {
+			opponentSpace += float64(freeCellsNear(ec, snake.Head))
+		}
+	}
+	opponents := float64(ec.AliveOpponents())
+	if opponents == 0 {
+		return 1
+	}
+	opponentSpace /= opponents
+
+	if rootSpace+opponentSpace == 0 {
+		return 0
+	}
+	return (rootSpace - opponentSpace) / (rootSpace + opponentSpace)
+}
+
+// freeCellsNear counts the unoccupied cells within spaceRadius moves of the point, ignoring anything in the way.
+func freeCellsNear(ec *EvaluationContext, p Point) int {
+	free := 0
+	for y := p.Y - spaceRadius; y <= p.Y+spaceRadius; y++ {
+		for x := p.X - spaceRadius; x <= p.X+spaceRadius; x++ {
+			cell := Point{X: x, Y: y}
+			if manhattanDistance(p, cell) > spaceRadius || !isPointInsideBoard(&ec.Board, cell) {
+				continue
+			}
+			if ec.Occupancy[y][x] == -1 {
+				free++
+			}
+		}
+	}
+	return free
+}
+
+func manhattanDistance(a, b Point) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
+
+// healthEvaluation is our health against the average opponent's, scaled to -1 to 1.
+func healthEvaluation(ec *EvaluationContext) float64 {
+	opponents := ec.AliveOpponents()
+	if opponents == 0 {
+		return 1
+	}
+	opponentHealth := 0
+	for i, snake := range ec.Board.Snakes {
+		if i != ec.RootIndex && ec.Alive[i] {
+			opponentHealth += snake.Health
+		}
+	}
+	average := float64(opponentHealth) / float64(opponents)
+	return (float64(ec.Board.Snakes[ec.RootIndex].Health) - average) / 100
+}
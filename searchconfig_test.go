@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheapModulesSkipVoronoi(t *testing.T) {
+	ec := newEvaluationContext(evalTestBoard(), 0)
+	runModules(ec, cheapModules)
+	assert.Nil(t, ec.lazy.voronoi, "the cheap tier shouldn't pay for a flood fill")
+}
+
+func TestSearchConfigPicksTier(t *testing.T) {
+	parent := NewNode(evalTestBoard(), -1, nil)
+	child := NewNode(evalTestBoard(), 0, parent)
+
+	testCases := []struct {
+		Description   string
+		Config        SearchConfig
+		ParentVisits  int64
+		ExpectedFull  bool
+		ExpectedScore []float64
+	}{
+		{"off", SearchConfig{}, 0, true, evaluateScores(child.Board, modules)},
+		{"quiet line", SearchConfig{FullEvalVisits: 10}, 9, false, evaluateScores(child.Board, cheapModules)},
+		{"busy line", SearchConfig{FullEvalVisits: 10}, 10, true, evaluateScores(child.Board, modules)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			parent.Visits = tc.ParentVisits
+			assert.Equal(t, tc.ExpectedFull, tc.Config.wantsFullEval(child))
+			assert.Equal(t, tc.ExpectedScore, tc.Config.evaluate(child))
+		})
+	}
+
+	assert.True(t, SearchConfig{FullEvalVisits: 10}.wantsFullEval(parent), "the root has nothing to compare against")
+}
+
+func TestSpaceEvaluation(t *testing.T) {
+	// a is tucked in the corner behind its own body, b is in the open
+	board := Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "a", Health: 90, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}}},
+			{ID: "b", Health: 90, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 4}, {X: 5, Y: 3}}},
+		},
+	}
+	assert.Less(t, spaceEvaluation(newEvaluationContext(board, 0)), 0.0)
+	assert.Greater(t, spaceEvaluation(newEvaluationContext(board, 1)), 0.0)
+}
+
+func TestHealthEvaluation(t *testing.T) {
+	board := evalTestBoard()
+	board.Snakes[0].Health = 100
+	board.Snakes[1].Health = 50
+	board.Snakes[2].Health = 30
+	assert.InDelta(t, 0.6, healthEvaluation(newEvaluationContext(board, 0)), 1e-9)
+	assert.InDelta(t, -0.15, healthEvaluation(newEvaluationContext(board, 1)), 1e-9)
+}
+
+func TestCheapEvalSparesTheFullModules(t *testing.T) {
+	// the same number of visits either way, so it's the work per visit that's compared rather than how
+	// many visits a busy machine gets through
+	const iterations = 2000
+	search := func(config SearchConfig) (int64, int64) {
+		var full int64
+		config.Modules = []EvaluationModule{{
+			Name: "counted",
+			EvalFunc: func(ec *EvaluationContext) float64 {
+				atomic.AddInt64(&full, 1)
+				return voronoiEvaluation(ec)
+			},
+			Weight: 1,
+		}}
+		root := MCTS(context.Background(), "test", evalTestBoard(), iterations, 1, make(map[string]*Node), WithSearchConfig(config))
+		return root.Visits, atomic.LoadInt64(&full)
+	}
+	fullVisits, fullEvals := search(SearchConfig{})
+	cheapVisits, cheapEvals := search(SearchConfig{FullEvalVisits: 1 << 62})
+	assert.Equal(t, fullVisits, cheapVisits)
+	assert.Positive(t, fullEvals)
+	assert.Zero(t, cheapEvals, "every leaf was quiet enough for the cheap tier")
+
+	// with a threshold only the leaves under busy parents pay for the full modules
+	_, someEvals := search(SearchConfig{FullEvalVisits: 3})
+	assert.Less(t, someEvals, fullEvals)
+	assert.Positive(t, someEvals)
+}
+
+func BenchmarkEvaluateBoardCheap(b *testing.B) {
+	board := evalTestBoard()
+	for i := 0; i < b.N; i++ {
+		evaluateBoard(board, 0, cheapModules)
+	}
+}
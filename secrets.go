@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// secretRefreshInterval is how long a fetched secret is trusted before it's fetched again, so rotations get picked up
+	secretRefreshInterval = 30 * time.Minute
+	// secretRetryInterval is the least time between attempts at a secret that failed to fetch
+	secretRetryInterval = 30 * time.Second
+	// secretCheckInterval is how often the background refresh looks for secrets that need fetching
+	secretCheckInterval = time.Minute
+	// secretFetchTimeout is the longest one fetch gets before it counts as failed
+	secretFetchTimeout = 10 * time.Second
+)
+
+var errSecretsNotConfigured = errors.New("secret manager not configured")
+
+// CachedSecret holds a secret fetched in the background, fetching again every so often so rotations get
+// picked up. Reading it never waits on secret manager. A failed fetch is retried later instead of leaving
+// the secret empty for good, and a failed refresh keeps the value we already had.
+type CachedSecret struct {
+	name  string // full secret manager resource name
+	fetch func(ctx context.Context, name string) (string, error)
+
+	mu          sync.Mutex
+	value       string
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+	fetching    bool
+}
+
+func newCachedSecret(name string) *CachedSecret {
+	return &CachedSecret{name: name}
+}
+
+// Label is the short name of the secret.
+func (s *CachedSecret) Label() string {
+	parts := strings.Split(s.name, "/")
+	for i, part := range parts {
+		if part == "secrets" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return s.name
+}
+
+// Get returns the secret as last fetched. It's empty if the secret has never been fetched successfully.
+func (s *CachedSecret) Get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value
+}
+
+// Refresh fetches the secret if it's missing or stale, unless the last attempt was too recent or another
+// fetch is already going. The lock isn't held while fetching so reads carry on with the old value.
+func (s *CachedSecret) Refresh(ctx context.Context) {
+	now := time.Now()
+	s.mu.Lock()
+	stale := s.fetchedAt.IsZero() || now.Sub(s.fetchedAt) >= secretRefreshInterval
+	if !stale || s.fetching || (s.lastErr != nil && now.Sub(s.lastAttempt) < secretRetryInterval) {
+		s.mu.Unlock()
+		return
+	}
+	s.lastAttempt = now
+	fetch := s.fetch
+	if fetch == nil {
+		s.lastErr = errSecretsNotConfigured
+		s.mu.Unlock()
+		return
+	}
+	s.fetching = true
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, secretFetchTimeout)
+	defer cancel()
+	value, err := fetch(ctx, s.name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = false
+	if err != nil {
+		s.lastErr = err
+		slog.Error("failed to fetch secret", "secret", s.Label(), "error", err.Error(), "have_previous", s.value != "")
+		return
+	}
+	s.value = value
+	s.fetchedAt = now
+	s.lastErr = nil
+}
+
+// SecretHealth is how a secret is doing, for /readyz.
+type SecretHealth struct {
+	Name      string    `json:"name"`
+	Available bool      `json:"available"`
+	FetchedAt time.Time `json:"fetched_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Health reports whether we have the secret and what went wrong last time if anything did.
+func (s *CachedSecret) Health() SecretHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	health := SecretHealth{
+		Name:      s.Label(),
+		Available: s.value != "",
+		FetchedAt: s.fetchedAt,
+	}
+	if s.lastErr != nil {
+		health.Error = s.lastErr.Error()
+	}
+	return health
+}
+
+var (
+	webhookURL           = newCachedSecret("projects/680796481131/secrets/discord_webhook/versions/latest")
+	commentaryWebhookURL = newCachedSecret("projects/680796481131/secrets/discord_commentary_webhook/versions/latest") // commentary gets its own channel so it doesn't drown out results
+	tidbytSecret         = newCachedSecret("projects/680796481131/secrets/tidbyt/versions/latest")
+
+	allSecrets = []*CachedSecret{webhookURL, commentaryWebhookURL, tidbytSecret}
+)
+
+// useSecretManager has every secret fetch from secret manager. Until it's called, which only main does,
+// secrets are empty so tests and local runs never go looking for credentials.
+func useSecretManager(fetch func(ctx context.Context, name string) (string, error)) {
+	for _, secret := range allSecrets {
+		secret.mu.Lock()
+		secret.fetch = fetch
+		secret.mu.Unlock()
+	}
+}
+
+// refreshSecrets keeps the secrets fresh in the background until the context is done,
+// starting straight away so they're there for the first requests.
+func refreshSecrets(ctx context.Context, secrets []*CachedSecret) {
+	ticker := time.NewTicker(secretCheckInterval)
+	defer ticker.Stop()
+	for {
+		for _, secret := range secrets {
+			secret.Refresh(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleReadyz reports the health of each secret. Missing secrets only cost us alerts and the tidbyt,
+// not moves, so the server is still ready, just degraded.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	healths := make([]SecretHealth, 0, len(allSecrets))
+	for _, secret := range allSecrets {
+		health := secret.Health()
+		if !health.Available {
+			status = "degraded"
+		}
+		healths = append(healths, health)
+	}
+	writeJSON(w, map[string]interface{}{
+		"status":  status,
+		"secrets": healths,
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretManager hands out whatever value is set, or the error.
+type fakeSecretManager struct {
+	value string
+	err   error
+	calls int
+}
+
+func (f *fakeSecretManager) fetch(ctx context.Context, name string) (string, error) {
+	f.calls++
+	return f.value, f.err
+}
+
+func TestCachedSecretLabel(t *testing.T) {
+	assert.Equal(t, "discord_webhook", webhookURL.Label())
+	assert.Equal(t, "plain", newCachedSecret("plain").Label())
+}
+
+func TestCachedSecretOnlyFetchesOnRefresh(t *testing.T) {
+	manager := &fakeSecretManager{value: "hook"}
+	secret := newCachedSecret("projects/1/secrets/hook/versions/latest")
+	secret.fetch = manager.fetch
+	assert.Equal(t, "", secret.Get(), "reading never fetches")
+	assert.Equal(t, 0, manager.calls)
+
+	secret.Refresh(context.Background())
+	secret.Refresh(context.Background())
+	assert.Equal(t, "hook", secret.Get())
+	assert.Equal(t, 1, manager.calls, "still fresh")
+}
+
+func TestCachedSecretRetriesFailures(t *testing.T) {
+	manager := &fakeSecretManager{err: errors.New("boom")}
+	secret := newCachedSecret("projects/1/secrets/hook/versions/latest")
+	secret.fetch = manager.fetch
+
+	secret.Refresh(context.Background())
+	secret.Refresh(context.Background())
+	assert.Equal(t, "", secret.Get())
+	assert.Equal(t, 1, manager.calls, "doesn't hammer secret manager straight after a failure")
+	assert.Equal(t, "boom", secret.Health().Error)
+
+	// once the retry interval has passed it tries again
+	manager.err = nil
+	manager.value = "hook"
+	secret.lastAttempt = time.Now().Add(-secretRetryInterval)
+	secret.Refresh(context.Background())
+	assert.Equal(t, "hook", secret.Get())
+	assert.Equal(t, SecretHealth{Name: "hook", Available: true, FetchedAt: secret.fetchedAt}, secret.Health())
+}
+
+func TestCachedSecretRefreshesForRotation(t *testing.T) {
+	manager := &fakeSecretManager{value: "old"}
+	secret := newCachedSecret("projects/1/secrets/hook/versions/latest")
+	secret.fetch = manager.fetch
+	secret.Refresh(context.Background())
+	assert.Equal(t, "old", secret.Get())
+
+	manager.value = "new"
+	secret.Refresh(context.Background())
+	assert.Equal(t, "old", secret.Get(), "still fresh")
+
+	secret.fetchedAt = time.Now().Add(-secretRefreshInterval)
+	secret.Refresh(context.Background())
+	assert.Equal(t, "new", secret.Get())
+
+	// a failed refresh keeps what we had
+	manager.err = errors.New("boom")
+	secret.fetchedAt = time.Now().Add(-secretRefreshInterval)
+	secret.Refresh(context.Background())
+	assert.Equal(t, "new", secret.Get())
+	assert.True(t, secret.Health().Available)
+	assert.Equal(t, "boom", secret.Health().Error)
+}
+
+func TestCachedSecretReadsWhileFetching(t *testing.T) {
+	secret := newCachedSecret("projects/1/secrets/hook/versions/latest")
+	secret.value = "old"
+	started, release := make(chan struct{}), make(chan struct{})
+	calls := 0
+	secret.fetch = func(ctx context.Context, name string) (string, error) {
+		calls++
+		close(started)
+		<-release
+		return "new", nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		secret.Refresh(context.Background())
+	}()
+	<-started
+	assert.Equal(t, "old", secret.Get(), "a slow fetch doesn't hold up reads")
+	secret.Refresh(context.Background())
+	close(release)
+	<-done
+	assert.Equal(t, "new", secret.Get())
+	assert.Equal(t, 1, calls, "one fetch at a time")
+}
+
+func TestCachedSecretFetchTimesOut(t *testing.T) {
+	secret := newCachedSecret("projects/1/secrets/hook/versions/latest")
+	var deadline time.Time
+	secret.fetch = func(ctx context.Context, name string) (string, error) {
+		deadline, _ = ctx.Deadline()
+		return "hook", nil
+	}
+	secret.Refresh(context.Background())
+	assert.WithinDuration(t, time.Now().Add(secretFetchTimeout), deadline, time.Second)
+}
+
+func TestCachedSecretNotConfigured(t *testing.T) {
+	secret := newCachedSecret("projects/1/secrets/hook/versions/latest")
+	secret.Refresh(context.Background())
+	assert.Equal(t, "", secret.Get())
+	assert.Equal(t, errSecretsNotConfigured.Error(), secret.Health().Error)
+}
+
+func TestReadyzReportsSecrets(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Status  string         `json:"status"`
+		Secrets []SecretHealth `json:"secrets"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Secrets, len(allSecrets))
+	for _, health := range body.Secrets {
+		if !health.Available {
+			assert.Equal(t, "degraded", body.Status)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// GameSession is everything we keep for one game from /start to /end. It rides along on request contexts
+// so anything working for the game can find it, and its own context is cancelled when the game ends so
+// nothing keeps searching for a game that's over.
+type GameSession struct {
+	ID      string
+	YouID   string
+	Source  string
+	Timeout int          // ms the engine gives us per move
+	Config  SearchConfig // search profile for this game
+	Logger  *slog.Logger // with game_id already attached
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// things i want to track from the start to the end that don't get provided by the server
+	otherSnakes []string
+	start       time.Time
+	indecision  *IndecisionTracker
+	plans       *PlanCache
+	history     *TurnHistory
+	latency     *LatencyTracker
+	timing      *TimeManager
+	cache       *CacheStats
+	commentary  *Commentator
+	throttle    *ThrottleTracker
+	prefetch    *Prefetcher
+	chase       *TailChase
+	legality    *LegalityTracker
+	opponents   *OpponentTracker
+	decisions   *DecisionLog
+	training    *TrainingLog
+
+	statesMu sync.Mutex
+	states   map[string]*Node // nodes saved from last turn's search, keyed by board
+
+	restored bool // whether the session came from a checkpoint, which needs cleaning up after the game
+}
+
+// newGameSession sets up the session for a game we're seeing for the first time.
+func newGameSession(game BattleSnakeGame, otherSnakes []string) *GameSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	latency := &LatencyTracker{}
+	return &GameSession{
+		ID:          game.Game.ID,
+		YouID:       game.You.ID,
+		Source:      game.Game.Source,
+		Timeout:     game.Game.Timeout,
+		Config:      searchConfigFor(game.Game),
+		Logger:      slog.Default().With("game_id", game.Game.ID),
+		ctx:         ctx,
+		cancel:      cancel,
+		otherSnakes: otherSnakes,
+		start:       time.Now(),
+		indecision:  &IndecisionTracker{},
+		plans:       &PlanCache{},
+		history:     &TurnHistory{},
+		latency:     latency,
+		timing:      newTimeManager(latency),
+		cache:       &CacheStats{},
+		commentary:  &Commentator{},
+		throttle:    &ThrottleTracker{},
+		prefetch:    &Prefetcher{},
+		chase:       &TailChase{},
+		legality:    &LegalityTracker{},
+		opponents:   &OpponentTracker{},
+		decisions:   &DecisionLog{},
+		training:    &TrainingLog{},
+		states:      make(map[string]*Node),
+	}
+}
+
+// Done is closed once the game has ended.
+func (s *GameSession) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// States returns the nodes saved from last turn.
+func (s *GameSession) States() map[string]*Node {
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+	return s.states
+}
+
+// SetStates swaps in the nodes to pick up from next turn.
+func (s *GameSession) SetStates(states map[string]*Node) {
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+	s.states = states
+}
+
+// moveContext is the context for searching one move: it ends at the deadline, when the request goes away,
+// or when the game ends, whichever comes first.
+func (s *GameSession) moveContext(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithDeadline(withSession(parent, s), deadline)
+	stop := context.AfterFunc(s.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Close ends the session, stopping anything still working on the game.
+func (s *GameSession) Close() {
+	s.cancel()
+}
+
+type sessionKey struct{}
+
+// withSession attaches the session to the context.
+func withSession(ctx context.Context, session *GameSession) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// sessionFrom returns the session attached to the context, or nil if there isn't one.
+func sessionFrom(ctx context.Context) *GameSession {
+	session, _ := ctx.Value(sessionKey{}).(*GameSession)
+	return session
+}
+
+// sessionTTL is how long a game can go without a request before its session is let go. The engine never
+// leaves this long between moves, so a game that's gone this quiet is one whose /end isn't coming.
+const sessionTTL = 10 * time.Minute
+
+// SessionRegistry holds the session of every game in progress. The engine can retry or duplicate a
+// request, so the same game can be in several handlers at once.
+type SessionRegistry struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*GameSession
+	seen     map[string]time.Time // when each game last had a request
+}
+
+func newSessionRegistry(ttl time.Duration) *SessionRegistry {
+	return &SessionRegistry{
+		ttl:      ttl,
+		sessions: make(map[string]*GameSession),
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// sessions is the production snake's, needed since final game states don't necessarily have all snakes
+var sessions = newSessionRegistry(sessionTTL)
+
+// Get returns the game's session, if there is one, and counts it as seen.
+func (r *SessionRegistry) Get(gameID string) (*GameSession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[gameID]
+	if ok {
+		r.seen[gameID] = time.Now()
+	}
+	return session, ok
+}
+
+// Put registers the session and returns the one it replaced, if any.
+func (r *SessionRegistry) Put(session *GameSession) *GameSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := r.sessions[session.ID]
+	r.sessions[session.ID] = session
+	r.seen[session.ID] = time.Now()
+	return previous
+}
+
+// PutIfAbsent registers the session unless the game already has one, and returns whichever is registered.
+func (r *SessionRegistry) PutIfAbsent(session *GameSession) (*GameSession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen[session.ID] = time.Now()
+	if existing, ok := r.sessions[session.ID]; ok {
+		return existing, false
+	}
+	r.sessions[session.ID] = session
+	return session, true
+}
+
+// Remove takes the game's session out of the registry and returns it.
+func (r *SessionRegistry) Remove(gameID string) (*GameSession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[gameID]
+	delete(r.sessions, gameID)
+	delete(r.seen, gameID)
+	return session, ok
+}
+
+// All returns every session registered.
+func (r *SessionRegistry) All() []*GameSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]*GameSession, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		all = append(all, session)
+	}
+	return all
+}
+
+// Len is how many games have a session.
+func (r *SessionRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessions)
+}
+
+// Evict closes and lets go of the sessions of games that haven't had a request for longer than the ttl,
+// and returns them.
+func (r *SessionRegistry) Evict(now time.Time) []*GameSession {
+	r.mu.Lock()
+	var evicted []*GameSession
+	for id, seen := range r.seen {
+		if now.Sub(seen) <= r.ttl {
+			continue
+		}
+		evicted = append(evicted, r.sessions[id])
+		delete(r.sessions, id)
+		delete(r.seen, id)
+	}
+	r.mu.Unlock()
+
+	for _, session := range evicted {
+		session.Logger.Warn("evicting session that stopped getting requests", "ttl", r.ttl.String())
+		session.Close()
+	}
+	return evicted
+}
+
+// EvictEvery evicts stale sessions on the interval until the context ends.
+func (r *SessionRegistry) EvictEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.Evict(now)
+		}
+	}
+}
+
+// newSession sets up the session for a game the snake is seeing for the first time, searching the way
+// its profile says.
+func (s *HostedSnake) newSession(game BattleSnakeGame, otherSnakes []string) *GameSession {
+	session := newGameSession(game, otherSnakes)
+	if s.Profile.Name != "" {
+		session.Config = s.Profile.apply(session.Config)
+		session.Logger = session.Logger.With("snake", s.Profile.Name)
+	}
+	return session
+}
+
+// startSession registers the game's session, replacing and closing any earlier one for the same game.
+func (s *HostedSnake) startSession(session *GameSession) {
+	if previous := s.sessions.Put(session); previous != nil && previous != session {
+		previous.Close()
+	}
+}
+
+// lookupSession finds the game's session. If we've never heard of the game, usually because the server
+// was reset partway through, it picks up from the game's checkpoint if there is one, or failing that starts
+// a new one so the rest of the game still gets tracked.
+func (s *HostedSnake) lookupSession(game BattleSnakeGame) *GameSession {
+	if session, ok := s.sessions.Get(game.Game.ID); ok {
+		return session
+	}
+
+	// the store's too slow to hold every other game up for
+	session, ok := s.loadCheckpoint(sessionStore, game)
+	if !ok {
+		session = s.newSession(game, []string{"server reset during game"})
+	}
+
+	registered, added := s.sessions.PutIfAbsent(session)
+	if !added {
+		// a retry of the same move got there first
+		session.Close()
+		return registered
+	}
+	if !session.restored {
+		session.Logger.Error("failed to find session. probably reset during a game.")
+	}
+	return session
+}
+
+// endSession removes the game's session and closes it. The session is returned for the post-game work,
+// or one from the game's checkpoint or a fresh one if we never heard of the game.
+func (s *HostedSnake) endSession(game BattleSnakeGame) *GameSession {
+	session, ok := s.sessions.Remove(game.Game.ID)
+	if !ok {
+		session, ok = s.loadCheckpoint(sessionStore, game)
+	}
+	if !ok {
+		session = s.newSession(game, []string{"server reset during game"})
+	}
+	if session.restored {
+		forgetCheckpoint(sessionStore, s.checkpointKey(game.Game.ID))
+	}
+	session.Close()
+	return session
+}
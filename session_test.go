@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionTestGame(id string) BattleSnakeGame {
+	board := evalTestBoard()
+	board.Snakes = board.Snakes[:2]
+	board.Snakes[0].Name = "a"
+	board.Snakes[1].Name = "b"
+	return BattleSnakeGame{
+		Game:  Game{ID: id, Source: "custom", Timeout: 250},
+		Board: board,
+		You:   board.Snakes[0],
+	}
+}
+
+func TestSessionMoveContextEndsWithGame(t *testing.T) {
+	session := newGameSession(sessionTestGame("session-ctx"), nil)
+	ctx, cancel := session.moveContext(context.Background(), time.Now().Add(time.Minute))
+	defer cancel()
+
+	assert.Same(t, session, sessionFrom(ctx))
+	assert.NoError(t, ctx.Err())
+
+	session.Close()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("move context outlived the game")
+	}
+	assert.Nil(t, sessionFrom(context.Background()))
+}
+
+func TestSessionRegistry(t *testing.T) {
+	game := sessionTestGame("session-registry")
+
+	first := newGameSession(game, []string{"b"})
+	productionSnake.startSession(first)
+	assert.Same(t, first, productionSnake.lookupSession(game))
+
+	// a second start for the same game replaces the first and stops anything it was doing
+	second := newGameSession(game, []string{"b"})
+	productionSnake.startSession(second)
+	assert.Same(t, second, productionSnake.lookupSession(game))
+	assert.Error(t, first.ctx.Err())
+
+	ended := productionSnake.endSession(game)
+	assert.Same(t, second, ended)
+	assert.Error(t, second.ctx.Err())
+
+	// unknown games get a fresh session rather than nothing
+	restarted := productionSnake.lookupSession(game)
+	assert.NotSame(t, second, restarted)
+	assert.Equal(t, []string{"server reset during game"}, restarted.otherSnakes)
+	productionSnake.endSession(game)
+}
+
+func TestSessionRegistryEvictsQuietGames(t *testing.T) {
+	registry := newSessionRegistry(time.Minute)
+	quiet := newGameSession(sessionTestGame("session-quiet"), nil)
+	busy := newGameSession(sessionTestGame("session-busy"), nil)
+	registry.Put(quiet)
+	registry.Put(busy)
+
+	assert.Empty(t, registry.Evict(time.Now()), "nothing's gone quiet yet")
+	registry.seen[quiet.ID] = time.Now().Add(-2 * time.Minute)
+	assert.Equal(t, []*GameSession{quiet}, registry.Evict(time.Now()))
+	assert.Error(t, quiet.ctx.Err(), "an evicted game stops searching")
+	assert.NoError(t, busy.ctx.Err())
+
+	_, ok := registry.Get(quiet.ID)
+	assert.False(t, ok)
+	assert.Equal(t, 1, registry.Len())
+	busy.Close()
+}
+
+// run with -race to check the registry holds up with the engine's requests coming in all at once
+func TestSessionRegistryConcurrentGames(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		game := sessionTestGame(fmt.Sprintf("session-concurrent-%d", g))
+		for worker := 0; worker < 4; worker++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					switch (worker + i) % 4 {
+					case 0:
+						productionSnake.startSession(newGameSession(game, []string{"b"}))
+					case 1, 2:
+						session := productionSnake.lookupSession(game)
+						session.SetStates(session.States())
+					case 3:
+						productionSnake.endSession(game)
+					}
+					sessions.Evict(time.Now())
+				}
+			}(worker)
+		}
+	}
+	wg.Wait()
+
+	for g := 0; g < 8; g++ {
+		productionSnake.endSession(sessionTestGame(fmt.Sprintf("session-concurrent-%d", g)))
+	}
+	for _, session := range sessions.All() {
+		assert.NotContains(t, session.ID, "session-concurrent")
+	}
+}
+
+func TestHandlersShareSession(t *testing.T) {
+	router := newRouter("")
+	game := sessionTestGame("session-handlers")
+	post := func(path string, game BattleSnakeGame) *httptest.ResponseRecorder {
+		body, err := json.Marshal(game)
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, post("/start", game).Code)
+	session, _ := sessions.Get(game.Game.ID)
+	require.NotNil(t, session)
+	assert.Equal(t, []string{"b"}, session.otherSnakes)
+
+	rec := post("/move", game)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, session.States(), "the search should have left nodes for next turn")
+	assert.NotNil(t, session.plans.Load())
+
+	session.Close()
+	sessions.Remove(game.Game.ID)
+}
+
+func TestForcedMoveAnswersWithoutSearching(t *testing.T) {
+	router := newRouter("")
+	game := sessionTestGame("session-forced")
+	game.Board = Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "a", Name: "a", Health: 100, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: 2}}},
+			{ID: "b", Name: "b", Health: 100, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}, {X: 5, Y: 7}}},
+		},
+	}
+	game.You = game.Board.Snakes[0]
+	session := newGameSession(game, []string{"b"})
+	session.SetStates(map[string]*Node{"stale": {}})
+	productionSnake.startSession(session)
+	defer productionSnake.endSession(game)
+
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "right", response["move"])
+	assert.Positive(t, session.timing.Banked(), "the unused time goes in the bank")
+	assert.Empty(t, session.States(), "last turn's tree doesn't carry past a turn we didn't search")
+	assert.Nil(t, session.plans.Load())
+}
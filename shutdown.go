@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// shutdownGrace is how long we take to stop once asked. Cloud run kills us 10s after SIGTERM.
+const shutdownGrace = 9 * time.Second
+
+// shutdown stops taking requests and lets the moves in flight finish, then saves the games still going for
+// whoever gets their next move and waits on what's still going out for games that have ended.
+func shutdown(server *http.Server, store SessionStore) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("failed to finish requests in flight", "error", err.Error())
+	}
+
+	saveCtx, cancelSave := context.WithTimeout(ctx, checkpointSaveTime)
+	saved := checkpointSessions(saveCtx, store)
+	cancelSave()
+	slog.Info("checkpointed games in progress", "games", saved)
+
+	if err := endOfGame.Wait(ctx); err != nil {
+		slog.Warn("stopped before end of game work finished", "error", err.Error())
+	}
+	if err := discordQueue.Drain(ctx); err != nil {
+		slog.Warn("stopped before discord messages went out", "error", err.Error())
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+// applyMoves plays a whole turn the way the official rules do, with every snake moving at once rather
+// than one at a time like applyMove. Every head moves and loses a point of health, hazards do their
+// damage to snakes that didn't land on food, and food is eaten. Only then are snakes taken off the board:
+// first the ones out of health or off the edge, then any whose head hit a body or lost a head to head
+// against the snakes still standing, all at the same time. Last of all new food and hazards are put down.
+//
+// moves holds a move for each snake by index. Dead snakes don't move.
+func applyMoves(board *Board, moves []Direction) {
+	resolveMoves(board, moves)
+	rollChance(board)
+}
+
+// resolveMoves is the part of the turn the snakes decide, applyMoves without the chance events at the end:
+// the turn isn't counted and no food or hazards are put down, so the same moves always give the same board.
+func resolveMoves(board *Board, moves []Direction) {
+	moved := make([]bool, len(board.Snakes))
+	for i := range board.Snakes {
+		snake := &board.Snakes[i]
+		if isSnakeDead(*snake) || i >= len(moves) {
+			continue
+		}
+		head := moveOnBoard(board, snake.Head, moves[i])
+		tail := snake.Body[len(snake.Body)-1]
+		snake.Body = append([]Point{head}, snake.Body[:len(snake.Body)-1]...)
+		if len(snake.Body) > 0 && snake.Body[len(snake.Body)-1] != tail {
+			board.vacate(tail, len(snake.Body))
+		}
+		snake.Head = head
+		snake.Health--
+		moved[i] = true
+	}
+
+	// standing in sauce costs the ruleset's damage for every hazard stacked on the cell, unless there's food there
+	if board.HazardDamage > 0 {
+		for i := range board.Snakes {
+			snake := &board.Snakes[i]
+			if !moved[i] || containsPoint(board.Food, snake.Head) {
+				continue
+			}
+			for _, hazard := range board.Hazards {
+				if hazard == snake.Head {
+					snake.Health -= board.HazardDamage
+				}
+			}
+		}
+	}
+
+	// every snake that reaches a food eats it, even if another got there too
+	eaten := make(map[Point]bool)
+	for i := range board.Snakes {
+		snake := &board.Snakes[i]
+		if !moved[i] || !containsPoint(board.Food, snake.Head) {
+			continue
+		}
+		snake.Health = 100
+		snake.Body = append(snake.Body, snake.Body[len(snake.Body)-1])
+		eaten[snake.Head] = true
+	}
+	if len(eaten) > 0 {
+		food := board.Food[:0]
+		for _, point := range board.Food {
+			if !eaten[point] {
+				food = append(food, point)
+			}
+		}
+		board.Food = food
+	}
+
+	deadSnakes := make(map[int]bool)
+	for i, snake := range board.Snakes {
+		if moved[i] && (snake.Health <= 0 || !isPointInsideBoard(board, snake.Head)) {
+			deadSnakes[i] = true
+		}
+	}
+
+	// collisions are only with the snakes that are still standing, and everyone who collides dies together
+	collided := make(map[int]bool)
+	for i, snake := range board.Snakes {
+		if !moved[i] || deadSnakes[i] {
+			continue
+		}
+		for j, other := range board.Snakes {
+			if !moved[j] || deadSnakes[j] {
+				continue
+			}
+			if containsPoint(other.Body[1:], snake.Head) {
+				collided[i] = true
+				break
+			}
+			if j != i && other.Head == snake.Head && len(snake.Body) <= len(other.Body) {
+				collided[i] = true
+				break
+			}
+		}
+	}
+	for i := range collided {
+		deadSnakes[i] = true
+	}
+	markDeadSnakes(board, deadSnakes)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyMoves(t *testing.T) {
+	testCases := []struct {
+		Description   string
+		InitialBoard  Board
+		Moves         []Direction
+		ExpectedBoard Board
+	}{
+		{
+			Description: "Single snake moves up and loses health",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Single snake eats food, grows, and restores health",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Food: []Point{{X: 2, Y: 3}, {X: 0, Y: 0}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 98, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Food: []Point{{X: 0, Y: 0}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Snake runs into wall and dies",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 4, Y: 4}, Body: []Point{{X: 4, Y: 4}, {X: 3, Y: 4}}},
+				},
+			},
+			Moves: []Direction{Right},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 5, Y: 4}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "Two snakes collide head-to-head, longer one survives",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 1, Y: 2}, {X: 0, Y: 2}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 4, Y: 2}, Body: []Point{{X: 4, Y: 2}, {X: 4, Y: 3}}},
+				},
+			},
+			Moves: []Direction{Right, Left},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 3, Y: 2}, Body: []Point{{X: 3, Y: 2}, {X: 2, Y: 2}, {X: 1, Y: 2}}},
+					{ID: "snake2", Health: 0, Head: Point{X: 3, Y: 2}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "Two snakes collide heads at 90 degrees, longer one survives",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}, {X: 2, Y: 0}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 3, Y: 3}, Body: []Point{{X: 3, Y: 3}, {X: 3, Y: 4}}},
+				},
+			},
+			Moves: []Direction{Right, Down},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 3, Y: 2}, Body: []Point{{X: 3, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 1}}},
+					{ID: "snake2", Health: 0, Head: Point{X: 3, Y: 2}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "Snakes of the same length both die head-to-head",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 3, Y: 3}, Body: []Point{{X: 3, Y: 3}, {X: 3, Y: 4}}},
+				},
+			},
+			Moves: []Direction{Right, Down},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 3, Y: 2}, Body: []Point{}},
+					{ID: "snake2", Health: 0, Head: Point{X: 3, Y: 2}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "Snakes passing through each other both hit a body",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 1, Y: 2}, {X: 0, Y: 2}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 3, Y: 2}, Body: []Point{{X: 3, Y: 2}, {X: 4, Y: 2}}},
+				},
+			},
+			Moves: []Direction{Right, Left},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 3, Y: 2}, Body: []Point{}},
+					{ID: "snake2", Health: 0, Head: Point{X: 2, Y: 2}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "A snake can follow another's tail since it moves at the same time",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 0, Y: 1}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 1, Y: 2}}},
+				},
+			},
+			Moves: []Direction{Up, Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 1, Y: 2}, Body: []Point{{X: 1, Y: 2}, {X: 1, Y: 1}}},
+					{ID: "snake2", Health: 99, Head: Point{X: 2, Y: 4}, Body: []Point{{X: 2, Y: 4}, {X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "A snake that just ate keeps its tail where it was",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 0, Y: 1}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 1, Y: 2}, {X: 1, Y: 2}}},
+				},
+			},
+			Moves: []Direction{Up, Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 1, Y: 2}, Body: []Point{}},
+					{ID: "snake2", Health: 99, Head: Point{X: 2, Y: 4}, Body: []Point{{X: 2, Y: 4}, {X: 2, Y: 3}, {X: 2, Y: 2}, {X: 1, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Both snakes eat a food they reach together before the longer wins the head-to-head",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Food: []Point{{X: 2, Y: 2}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 50, Head: Point{X: 1, Y: 2}, Body: []Point{{X: 1, Y: 2}, {X: 0, Y: 2}, {X: 0, Y: 1}}},
+					{ID: "snake2", Health: 50, Head: Point{X: 3, Y: 2}, Body: []Point{{X: 3, Y: 2}, {X: 4, Y: 2}}},
+				},
+			},
+			Moves: []Direction{Right, Left},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Food: []Point{},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 1, Y: 2}, {X: 0, Y: 2}, {X: 0, Y: 2}}},
+					{ID: "snake2", Health: 0, Head: Point{X: 2, Y: 2}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "A snake that went off the edge doesn't block anyone",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 0, Y: 3}, Body: []Point{{X: 0, Y: 3}, {X: 0, Y: 4}, {X: 1, Y: 4}}},
+					{ID: "snake2", Health: 100, Head: Point{X: 1, Y: 2}, Body: []Point{{X: 1, Y: 2}, {X: 2, Y: 2}}},
+				},
+			},
+			Moves: []Direction{Left, Left},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: -1, Y: 3}, Body: []Point{}},
+					{ID: "snake2", Health: 99, Head: Point{X: 0, Y: 2}, Body: []Point{{X: 0, Y: 2}, {X: 1, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Snake leaving the edge of a wrapped board comes back on the other side",
+			InitialBoard: Board{
+				Height: 5, Width: 5, Wrapped: true,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 4, Y: 2}, Body: []Point{{X: 4, Y: 2}, {X: 3, Y: 2}}},
+				},
+			},
+			Moves: []Direction{Right},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1, Wrapped: true,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 0, Y: 2}, Body: []Point{{X: 0, Y: 2}, {X: 4, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Snake moving into hazard sauce takes the ruleset's damage",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 35, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Snake dies inside hazard sauce when the damage takes the last of its health",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 2}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 15, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 2}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 2, Y: 3}, Body: []Point{}},
+				},
+			},
+		},
+		{
+			Description: "Stacked hazards do their damage once per layer",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 50, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1, HazardDamage: 14,
+				Hazards: []Point{{X: 2, Y: 3}, {X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 21, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Eating food inside hazard sauce saves the snake from the damage",
+			InitialBoard: Board{
+				Height: 5, Width: 5, HazardDamage: 14,
+				Food:    []Point{{X: 2, Y: 3}},
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 5, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1, HazardDamage: 14,
+				Food:    []Point{},
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 3}, Body: []Point{{X: 2, Y: 3}, {X: 2, Y: 2}, {X: 2, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Hazards do nothing without hazard damage, but running out of health still kills",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 1, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+					{ID: "snake2", Health: 2, Head: Point{X: 4, Y: 2}, Body: []Point{{X: 4, Y: 2}, {X: 4, Y: 1}}},
+				},
+			},
+			Moves: []Direction{Up, Up},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Hazards: []Point{{X: 2, Y: 3}},
+				Snakes: []Snake{
+					{ID: "snake1", Health: 0, Head: Point{X: 2, Y: 3}, Body: []Point{}},
+					{ID: "snake2", Health: 1, Head: Point{X: 4, Y: 3}, Body: []Point{{X: 4, Y: 3}, {X: 4, Y: 2}}},
+				},
+			},
+		},
+		{
+			Description: "Dead snakes stay where they are",
+			InitialBoard: Board{
+				Height: 5, Width: 5,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+					{ID: "snake2", Health: 0, Head: Point{X: 4, Y: 4}, Body: []Point{}},
+				},
+			},
+			Moves: []Direction{Left, Unset},
+			ExpectedBoard: Board{
+				Height: 5, Width: 5, Turn: 1,
+				Snakes: []Snake{
+					{ID: "snake1", Health: 99, Head: Point{X: 1, Y: 2}, Body: []Point{{X: 1, Y: 2}, {X: 2, Y: 2}}},
+					{ID: "snake2", Health: 0, Head: Point{X: 4, Y: 4}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			board := copyBoard(tc.InitialBoard)
+			applyMoves(&board, tc.Moves)
+			assert.Equal(t, tc.ExpectedBoard, board)
+		})
+	}
+}
+
+func TestResolveMovesLeavesChanceAlone(t *testing.T) {
+	board := Board{
+		Height: 5, Width: 5, FoodSpawnChance: 100, MinimumFood: 3, ShrinkEvery: 1,
+		Snakes: []Snake{
+			{ID: "snake1", Health: 100, Head: Point{X: 2, Y: 2}, Body: []Point{{X: 2, Y: 2}, {X: 2, Y: 1}}},
+		},
+	}
+	resolved := copyBoard(board)
+	resolveMoves(&resolved, []Direction{Up})
+	assert.Equal(t, Point{X: 2, Y: 3}, resolved.Snakes[0].Head)
+	assert.Zero(t, resolved.Turn)
+	assert.Empty(t, resolved.Food)
+	assert.Empty(t, resolved.Hazards)
+
+	applied := copyBoard(board)
+	applyMoves(&applied, []Direction{Up})
+	assert.Equal(t, 1, applied.Turn)
+	assert.Len(t, applied.Food, 3, "applyMoves goes on to roll them")
+}
@@ -0,0 +1,147 @@
+package main
+
+import "sync"
+
+// starvationRace says whether we've already won by chasing our own tail: our head is next to our tail so
+// we can go round the same loop forever, and every opponent runs out of health before us without being
+// able to reach any food. It returns the move that keeps the loop going.
+//
+// It's deliberately pessimistic. Our loop is the only thing in the opponents' way, since their own bodies
+// clear out as they move, and food that spawns later isn't known about, so the answer only holds for this turn.
+func starvationRace(board Board) (Direction, bool) {
+	if len(board.Snakes) < 2 || isSnakeDead(board.Snakes[0]) {
+		return Unset, false
+	}
+	us := board.Snakes[0]
+	// a loop needs at least a two by two square
+	if len(us.Body) < 4 {
+		return Unset, false
+	}
+	tail := us.Body[len(us.Body)-1]
+	// just after eating the tail doesn't move, so following it is suicide
+	if us.Body[len(us.Body)-2] == tail {
+		return Unset, false
+	}
+	move := directionTo(&board, us.Head, tail)
+	if move == Unset || !containsDirection(generateSafeMoves(board, 0), move) {
+		return Unset, false
+	}
+
+	loop := make(map[Point]bool, len(us.Body))
+	for _, p := range us.Body {
+		loop[p] = true
+	}
+	for _, p := range board.Hazards {
+		// going round through hazards burns health we're counting on
+		if loop[p] {
+			return Unset, false
+		}
+	}
+
+	opponents := 0
+	for _, snake := range board.Snakes[1:] {
+		if isSnakeDead(snake) {
+			continue
+		}
+		opponents++
+		if snake.Health >= us.Health {
+			return Unset, false
+		}
+		// they could meet us head on where our tail just was
+		if (snake.Head == tail || directionTo(&board, snake.Head, tail) != Unset) && len(snake.Body) >= len(us.Body) {
+			return Unset, false
+		}
+		if distance := distanceAvoiding(board, snake.Head, board.Food, loop); distance >= 0 && distance <= snake.Health {
+			return Unset, false
+		}
+	}
+	if opponents == 0 {
+		return Unset, false
+	}
+	return move, true
+}
+
+// distanceAvoiding is how many moves it takes to get from start to the nearest target without going
+// through the blocked cells, or -1 if none can be reached.
+func distanceAvoiding(board Board, start Point, targets []Point, blocked map[Point]bool) int {
+	if len(targets) == 0 {
+		return -1
+	}
+	wanted := make(map[Point]bool, len(targets))
+	for _, t := range targets {
+		wanted[t] = true
+	}
+	visited := map[Point]bool{start: true}
+	frontier := []Point{start}
+	for distance := 0; len(frontier) > 0; distance++ {
+		var next []Point
+		for _, p := range frontier {
+			if wanted[p] {
+				return distance
+			}
+			for _, direction := range AllDirections {
+				n := moveOnBoard(&board, p, direction)
+				if !isPointInsideBoard(&board, n) || visited[n] || blocked[n] {
+					continue
+				}
+				visited[n] = true
+				next = append(next, n)
+			}
+		}
+		frontier = next
+	}
+	return -1
+}
+
+// directionTo is the direction of a neighbouring point.
+func directionTo(board *Board, from, to Point) Direction {
+	for _, direction := range AllDirections {
+		if moveOnBoard(board, from, direction) == to {
+			return direction
+		}
+	}
+	return Unset
+}
+
+func containsDirection(moves []Direction, move Direction) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
+
+// TailChase keeps us going round our own loop once the starvation race is won. Left to itself the search
+// sometimes wanders off the loop towards food or space it doesn't need, since every line looks like a win.
+// The race is checked again every turn because food spawning within an opponent's reach undoes it.
+type TailChase struct {
+	mu     sync.Mutex
+	active bool
+	since  int // turn the chase started
+	turns  int // turns spent chasing this time round
+}
+
+// Update rechecks the race for this turn's board. It returns the move that keeps the loop going if we're
+// chasing, and whether we just started or stopped.
+func (c *TailChase) Update(turn int, board Board) (move Direction, chasing, changed bool) {
+	move, won := starvationRace(board)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed = won != c.active
+	if won && !c.active {
+		c.since, c.turns = turn, 0
+	}
+	c.active = won
+	if won {
+		c.turns++
+	}
+	return move, won, changed
+}
+
+// Since is the turn the current chase started and how many turns it's lasted.
+func (c *TailChase) Since() (turn, turns int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.since, c.turns
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loopBoard has us going round a two by two square in the bottom left with our head next to our tail,
+// and a hungrier opponent off in the far corner.
+func loopBoard(opponentHealth int, food ...Point) Board {
+	return Board{
+		Height: 11,
+		Width:  11,
+		Food:   food,
+		Snakes: []Snake{
+			{ID: "us", Head: Point{X: 1, Y: 1}, Health: 90, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 1}}},
+			{ID: "them", Head: Point{X: 8, Y: 8}, Health: opponentHealth, Body: []Point{{X: 8, Y: 8}, {X: 8, Y: 9}, {X: 8, Y: 10}}},
+		},
+	}
+}
+
+func TestStarvationRace(t *testing.T) {
+	ring := loopBoard(20, Point{X: 2, Y: 2})
+	ring.Snakes[0].Body = []Point{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 1, Y: 3}, {X: 2, Y: 3}, {X: 3, Y: 3}, {X: 3, Y: 2}, {X: 3, Y: 1}, {X: 2, Y: 1}}
+
+	justAte := loopBoard(5)
+	justAte.Snakes[0].Body = append(justAte.Snakes[0].Body, Point{X: 2, Y: 1})
+
+	straight := loopBoard(5)
+	straight.Snakes[0].Body = []Point{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 1, Y: 3}, {X: 1, Y: 4}}
+
+	waiting := loopBoard(5)
+	waiting.Snakes[1].Head = Point{X: 3, Y: 1}
+	waiting.Snakes[1].Body = []Point{{X: 3, Y: 1}, {X: 4, Y: 1}, {X: 5, Y: 1}, {X: 6, Y: 1}, {X: 7, Y: 1}}
+
+	shorterWaiting := loopBoard(5)
+	shorterWaiting.Snakes[1].Head = Point{X: 3, Y: 1}
+	shorterWaiting.Snakes[1].Body = []Point{{X: 3, Y: 1}, {X: 4, Y: 1}, {X: 5, Y: 1}}
+
+	hazardous := loopBoard(5)
+	hazardous.Hazards = []Point{{X: 2, Y: 2}}
+
+	testCases := []struct {
+		Description string
+		Board       Board
+		Want        bool
+	}{
+		{Description: "they starve first", Board: loopBoard(5, Point{X: 9, Y: 0}), Want: true},
+		{Description: "food in their reach", Board: loopBoard(20, Point{X: 9, Y: 0})},
+		{Description: "they outlast us", Board: loopBoard(95)},
+		{Description: "food walled in by our loop", Board: ring, Want: true},
+		{Description: "tail stacked after eating", Board: justAte},
+		{Description: "head not next to tail", Board: straight},
+		{Description: "longer snake waiting by our tail", Board: waiting},
+		{Description: "shorter snake waiting by our tail", Board: shorterWaiting, Want: true},
+		{Description: "loop through hazards", Board: hazardous},
+		{Description: "nobody left", Board: Board{Height: 11, Width: 11, Snakes: loopBoard(5).Snakes[:1]}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			move, won := starvationRace(tc.Board)
+			assert.Equal(t, tc.Want, won)
+			if tc.Want {
+				assert.Equal(t, Right, move, "follow the tail")
+			}
+		})
+	}
+}
+
+func TestStarvationRaceAcrossTheWrap(t *testing.T) {
+	// our tail is on the left edge and the longer snake is on the right, one step away round the wrap
+	board := loopBoard(5)
+	board.Snakes[0].Head = Point{X: 1, Y: 1}
+	board.Snakes[0].Body = []Point{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 0, Y: 2}, {X: 0, Y: 1}}
+	board.Snakes[1].Head = Point{X: 10, Y: 1}
+	board.Snakes[1].Body = []Point{{X: 10, Y: 1}, {X: 10, Y: 2}, {X: 10, Y: 3}, {X: 10, Y: 4}, {X: 10, Y: 5}}
+
+	move, won := starvationRace(board)
+	assert.True(t, won, "the edge keeps them away")
+	assert.Equal(t, Left, move)
+
+	board.Wrapped = true
+	_, won = starvationRace(board)
+	assert.False(t, won, "they can meet us where our tail was")
+}
+
+func TestTailChaseRechecksEveryTurn(t *testing.T) {
+	chase := &TailChase{}
+
+	_, chasing, changed := chase.Update(10, loopBoard(20, Point{X: 9, Y: 0}))
+	assert.False(t, chasing)
+	assert.False(t, changed)
+
+	move, chasing, changed := chase.Update(11, loopBoard(5, Point{X: 9, Y: 0}))
+	assert.True(t, chasing)
+	assert.True(t, changed)
+	assert.Equal(t, Right, move)
+
+	_, chasing, changed = chase.Update(12, loopBoard(4))
+	assert.True(t, chasing)
+	assert.False(t, changed)
+	since, turns := chase.Since()
+	assert.Equal(t, 11, since)
+	assert.Equal(t, 2, turns)
+
+	// food spawning next to them undoes it
+	_, chasing, changed = chase.Update(13, loopBoard(3, Point{X: 8, Y: 7}))
+	assert.False(t, chasing)
+	assert.True(t, changed)
+}
+
+func TestTailChaseAnswersWithoutSearching(t *testing.T) {
+	router := newRouter("")
+	game := sessionTestGame("session-tail-chase")
+	game.Board = loopBoard(5)
+	game.You = game.Board.Snakes[0]
+	session := newGameSession(game, []string{"them"})
+	productionSnake.startSession(session)
+	defer productionSnake.endSession(game)
+
+	body, err := json.Marshal(game)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "right", response["move"])
+	assert.Positive(t, session.timing.Banked(), "the unused time goes in the bank")
+	assert.Empty(t, session.States())
+}
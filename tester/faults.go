@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// faultConfig controls the network problems injected into move requests.
+type faultConfig struct {
+	maxDelay      time.Duration // each leg of the round trip gets up to this much extra latency
+	dropRate      float64       // the connection is cut on the way there and the request retried
+	duplicateRate float64       // the same request arrives twice at once
+}
+
+// faultyClient sends requests to the server the way a flaky network and an eager engine might.
+type faultyClient struct {
+	url    string
+	cfg    faultConfig
+	client *http.Client
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newFaultyClient(url string, cfg faultConfig, rng *rand.Rand) *faultyClient {
+	return &faultyClient{
+		url:    url,
+		cfg:    cfg,
+		client: &http.Client{},
+		rng:    rng,
+	}
+}
+
+func (c *faultyClient) roll(chance float64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64() < chance
+}
+
+func (c *faultyClient) duration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.rng.Int63n(int64(max)))
+}
+
+// post sends a request without any faults, for /start and /end.
+func (c *faultyClient) post(path string, game BattleSnakeGame) error {
+	data, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post(c.url+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type moveAttempt struct {
+	move    string
+	err     error
+	elapsed time.Duration
+}
+
+// move sends a /move request with faults injected and times it from the engine's point of view,
+// so injected latency and retries all count against the game timeout.
+func (c *faultyClient) move(game BattleSnakeGame) turnResult {
+	start := time.Now()
+	timeout := time.Duration(game.Game.Timeout) * time.Millisecond
+	// give up well after the timeout so late answers are measured rather than abandoned
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(2*timeout))
+	defer cancel()
+
+	var result turnResult
+	data, err := json.Marshal(game)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	outbound := c.duration(c.cfg.maxDelay)
+	inbound := c.duration(c.cfg.maxDelay)
+	if outbound+inbound > 0 {
+		result.faults = append(result.faults, fmt.Sprintf("delay=%s", (outbound+inbound).Round(time.Millisecond)))
+	}
+
+	if c.roll(c.cfg.dropRate) {
+		// the connection goes before the request reaches the server, so the retry is racing the same clock
+		cutAfter := c.duration(outbound)
+		result.faults = append(result.faults, fmt.Sprintf("drop=%s", cutAfter.Round(time.Millisecond)))
+		dropCtx, dropCancel := context.WithTimeout(ctx, cutAfter)
+		c.sendMove(dropCtx, data)
+		dropCancel()
+	}
+
+	copies := 1
+	if c.roll(c.cfg.duplicateRate) {
+		copies = 2
+		result.faults = append(result.faults, "duplicate")
+	}
+
+	attempts := make(chan moveAttempt, copies)
+	for i := 0; i < copies; i++ {
+		go func() {
+			time.Sleep(outbound)
+			move, err := c.sendMove(ctx, data)
+			if err == nil {
+				time.Sleep(inbound)
+			}
+			attempts <- moveAttempt{move: move, err: err, elapsed: time.Since(start)}
+		}()
+	}
+
+	// every copy has to come back in time with a real move, the engine could act on either
+	for i := 0; i < copies; i++ {
+		attempt := <-attempts
+		if attempt.err != nil && result.err == nil {
+			result.err = attempt.err
+		}
+		if result.move == "" {
+			result.move = attempt.move
+		}
+		if attempt.elapsed > result.elapsed {
+			result.elapsed = attempt.elapsed
+		}
+	}
+
+	return result
+}
+
+func (c *faultyClient) sendMove(ctx context.Context, data []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/move", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Move string `json:"move"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	switch response.Move {
+	case "up", "down", "left", "right":
+		return response.Move, nil
+	}
+	return "", fmt.Errorf("invalid move %q", response.Move)
+}
@@ -3,13 +3,55 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"runtime"
 	"time"
 )
 
 func main() {
+	simulate := flag.Bool("simulate", false, "play whole games against the server while injecting network faults")
+	cfg := simConfig{}
+	flag.StringVar(&cfg.url, "url", "http://localhost:8080", "server to test")
+	flag.IntVar(&cfg.games, "games", 3, "games to simulate")
+	flag.IntVar(&cfg.turns, "turns", 100, "maximum turns per game")
+	flag.IntVar(&cfg.timeout, "timeout", 500, "game timeout in ms")
+	flag.DurationVar(&cfg.faults.maxDelay, "delay", 50*time.Millisecond, "maximum one way network delay added to each request")
+	flag.Float64Var(&cfg.faults.dropRate, "drop", 0.1, "chance a request's connection is dropped and retried")
+	flag.Float64Var(&cfg.faults.duplicateRate, "dup", 0.1, "chance a request is sent twice at once")
+	flag.StringVar(&cfg.record, "record", "", "file to record every request to, one json object per line")
+	flag.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "seed for the games and the faults")
+	selfPlay := flag.Bool("selfplay", false, "play engine configurations against each other and rate them")
+	engines := flag.String("engines", "", "engines for -selfplay as name=url, separated by commas")
+	snakes := flag.Int("snakes", 2, "snakes in each -selfplay game, 2 to 4")
+	flag.Parse()
+
+	if *selfPlay {
+		parsed, err := parseEngines(*engines)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		if *snakes < 2 || *snakes > len(selfPlayCorners) {
+			fmt.Printf("can't seat %d snakes\n", *snakes)
+			os.Exit(2)
+		}
+		// -games is per pair in duels, and the turn limit draws games that won't finish
+		report := runSelfPlay(selfPlayConfig{engines: parsed, games: cfg.games, snakes: *snakes, turns: cfg.turns, timeout: cfg.timeout, seed: cfg.seed})
+		fmt.Print(report)
+		return
+	}
+
+	if *simulate {
+		report := runSimulation(cfg)
+		fmt.Print(report)
+		if !report.ok() {
+			os.Exit(1)
+		}
+		return
+	}
 
 	numCPUs := runtime.NumCPU()
 	fmt.Printf("Number of available CPUs: %d\n", numCPUs)
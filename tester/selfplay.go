@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	selfPlayInitialRating = 1500
+	selfPlayK             = 16
+	// selfPlayZ is the normal quantile for the 95% confidence intervals on the score rates
+	selfPlayZ = 1.96
+)
+
+// selfPlayEngine is one engine configuration taking part, running as a server of its own.
+type selfPlayEngine struct {
+	name string
+	url  string
+}
+
+// selfPlayConfig holds the settings for a self play run.
+type selfPlayConfig struct {
+	engines []selfPlayEngine
+	games   int // games each pair plays in duels, games in all with more snakes
+	snakes  int // snakes in each game, 2 to 4
+	turns   int
+	timeout int
+	seed    int64
+}
+
+// parseEngines reads engines written as name=url, separated by commas.
+func parseEngines(value string) ([]selfPlayEngine, error) {
+	var engines []selfPlayEngine
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		name, url, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || url == "" {
+			return nil, fmt.Errorf("engine %q isn't name=url", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("engine %q listed twice", name)
+		}
+		seen[name] = true
+		engines = append(engines, selfPlayEngine{name: name, url: url})
+	}
+	if len(engines) < 2 {
+		return nil, errors.New("need at least two engines to play against each other")
+	}
+	return engines, nil
+}
+
+// selfPlayRecord is how one engine has done so far.
+type selfPlayRecord struct {
+	name                 string
+	wins, losses, draws  int
+	rating               float64
+	timeouts, moveErrors int
+}
+
+func (r selfPlayRecord) games() int {
+	return r.wins + r.losses + r.draws
+}
+
+// score is the share of the points the engine took, a draw counting half.
+func (r selfPlayRecord) score() float64 {
+	if r.games() == 0 {
+		return 0
+	}
+	return (float64(r.wins) + float64(r.draws)/2) / float64(r.games())
+}
+
+// interval is the Wilson score interval around the engine's score. Draws count as half a win, which makes
+// it a little wider than it needs to be.
+func (r selfPlayRecord) interval() (float64, float64) {
+	n := float64(r.games())
+	if n == 0 {
+		return 0, 1
+	}
+	p := r.score()
+	z2 := selfPlayZ * selfPlayZ
+	centre := (p + z2/(2*n)) / (1 + z2/n)
+	spread := selfPlayZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+	return math.Max(0, centre-spread), math.Min(1, centre+spread)
+}
+
+// selfPlayReport is every engine's record once the games are done.
+type selfPlayReport struct {
+	records map[string]*selfPlayRecord
+	games   int
+}
+
+func newSelfPlayReport(engines []selfPlayEngine) *selfPlayReport {
+	report := &selfPlayReport{records: make(map[string]*selfPlayRecord)}
+	for _, engine := range engines {
+		report.records[engine.name] = &selfPlayRecord{name: engine.name, rating: selfPlayInitialRating}
+	}
+	return report
+}
+
+// record adds a game between a and b to both records and moves their ratings. scoreA is 1 if a won, 0 if
+// b won and 0.5 for a draw.
+func (r *selfPlayReport) record(a, b string, scoreA float64) {
+	ra, rb := r.records[a], r.records[b]
+	switch scoreA {
+	case 1:
+		ra.wins++
+		rb.losses++
+	case 0:
+		ra.losses++
+		rb.wins++
+	default:
+		ra.draws++
+		rb.draws++
+	}
+	expectedA := 1 / (1 + math.Pow(10, (rb.rating-ra.rating)/400))
+	ra.rating += selfPlayK * (scoreA - expectedA)
+	rb.rating -= selfPlayK * (scoreA - expectedA)
+	r.games++
+}
+
+func (r *selfPlayReport) String() string {
+	records := make([]*selfPlayRecord, 0, len(r.records))
+	for _, record := range r.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].rating == records[j].rating {
+			return records[i].name < records[j].name
+		}
+		return records[i].rating > records[j].rating
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "games: %d\n", r.games)
+	fmt.Fprintf(&sb, "%-20s %7s %5s %5s %5s %7s %15s %9s %7s\n", "engine", "elo", "won", "lost", "drew", "score", "95% interval", "timeouts", "errors")
+	for _, record := range records {
+		low, high := record.interval()
+		fmt.Fprintf(&sb, "%-20s %7.1f %5d %5d %5d %6.1f%% %6.1f%%-%5.1f%% %9d %7d\n", record.name, record.rating,
+			record.wins, record.losses, record.draws, 100*record.score(), 100*low, 100*high, record.timeouts, record.moveErrors)
+	}
+	return sb.String()
+}
+
+// runSelfPlay has every pair of engines play their games on the tester's own board, swapping starting
+// corners each game, and rates them as it goes. With more than two snakes a game it plays free for alls
+// instead, see runSelfPlayFreeForAll.
+func runSelfPlay(cfg selfPlayConfig) *selfPlayReport {
+	rng := rand.New(rand.NewSource(cfg.seed))
+	report := newSelfPlayReport(cfg.engines)
+	clients := make(map[string]*faultyClient, len(cfg.engines))
+	for _, engine := range cfg.engines {
+		clients[engine.name] = newFaultyClient(engine.url, faultConfig{}, rand.New(rand.NewSource(rng.Int63())))
+	}
+	if cfg.snakes > 2 {
+		runSelfPlayFreeForAll(cfg, clients, report, rng)
+		return report
+	}
+
+	for g := 0; g < cfg.games; g++ {
+		for i := 0; i < len(cfg.engines); i++ {
+			for j := i + 1; j < len(cfg.engines); j++ {
+				pair := [2]selfPlayEngine{cfg.engines[i], cfg.engines[j]}
+				if g%2 == 1 {
+					pair[0], pair[1] = pair[1], pair[0]
+				}
+				id := fmt.Sprintf("selfplay-%d-%d-%s-%s", cfg.seed, g, pair[0].name, pair[1].name)
+				lasted := playSelfPlayGame(cfg, id, pair[:], clients, report, rng)
+				scoreA := outlasted(lasted[0], lasted[1])
+				report.record(pair[0].name, pair[1].name, scoreA)
+				fmt.Printf("%s: %s\n", id, describeSelfPlayResult(pair, scoreA))
+			}
+		}
+	}
+	return report
+}
+
+// runSelfPlayFreeForAll plays the games with cfg.snakes snakes each, the lineup and corners turning over by
+// an engine every game so each gets every seat. There can be more seats than engines, in which case an
+// engine plays itself too. Each game is rated as a duel between every two different engines in it, won by
+// whichever lasted longer.
+func runSelfPlayFreeForAll(cfg selfPlayConfig, clients map[string]*faultyClient, report *selfPlayReport, rng *rand.Rand) {
+	for g := 0; g < cfg.games; g++ {
+		seats := make([]selfPlayEngine, cfg.snakes)
+		names := make([]string, cfg.snakes)
+		for i := range seats {
+			seats[i] = cfg.engines[(g+i)%len(cfg.engines)]
+			names[i] = seats[i].name
+		}
+		id := fmt.Sprintf("selfplay-%d-%d-%s", cfg.seed, g, strings.Join(names, "-"))
+		lasted := playSelfPlayGame(cfg, id, seats, clients, report, rng)
+		for i := range seats {
+			for j := i + 1; j < len(seats); j++ {
+				if seats[i].name != seats[j].name {
+					report.record(seats[i].name, seats[j].name, outlasted(lasted[i], lasted[j]))
+				}
+			}
+		}
+		fmt.Printf("%s: %s\n", id, describeFreeForAllResult(seats, lasted))
+	}
+}
+
+// outlasted is a's score against b going by how many turns each lasted.
+func outlasted(a, b int) float64 {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return 0
+	}
+	return 0.5
+}
+
+// selfPlayCorners are where the snakes start, in seat order.
+var selfPlayCorners = []Point{{X: 1, Y: 1}, {X: 9, Y: 9}, {X: 1, Y: 9}, {X: 9, Y: 1}}
+
+// playSelfPlayGame plays one game to the end or the turn limit and returns how many turns each seat lasted,
+// one more than the limit for those still going. An engine that's too slow or answers with nonsense carries
+// on the way it was going, like on the real engine.
+func playSelfPlayGame(cfg selfPlayConfig, id string, seats []selfPlayEngine, clients map[string]*faultyClient, report *selfPlayReport, rng *rand.Rand) []int {
+	game := newSimGame(id, cfg.timeout)
+	game.Board.Snakes = nil
+	ids := make([]string, len(seats))
+	for i, engine := range seats {
+		// an engine in more than one seat needs an id for each
+		ids[i] = engine.name
+		for j := 0; j < i; j++ {
+			if seats[j].name == engine.name {
+				ids[i] = fmt.Sprintf("%s-%d", engine.name, i+1)
+			}
+		}
+		head := selfPlayCorners[i]
+		game.Board.Snakes = append(game.Board.Snakes, Snake{ID: ids[i], Name: engine.name, Health: 100, Head: head, Body: []Point{head, head, head}})
+	}
+	perspective := func(game BattleSnakeGame, i int) BattleSnakeGame {
+		for _, snake := range game.Board.Snakes {
+			if snake.ID == ids[i] {
+				game.You = snake
+			}
+		}
+		return game
+	}
+	// the seats still on the board, in the board's order
+	playing := func() []int {
+		var seated []int
+		for _, snake := range game.Board.Snakes {
+			for i := range ids {
+				if snake.ID == ids[i] {
+					seated = append(seated, i)
+				}
+			}
+		}
+		return seated
+	}
+
+	for i, engine := range seats {
+		if err := clients[engine.name].post("/start", perspective(game, i)); err != nil {
+			fmt.Printf("%s: %s didn't start: %v\n", id, engine.name, err)
+		}
+	}
+
+	lasted := make([]int, len(seats))
+	timeout := time.Duration(cfg.timeout) * time.Millisecond
+	for turn := 0; turn < cfg.turns && len(game.Board.Snakes) > 1; turn++ {
+		game.Turn = turn
+		seated := playing()
+		moves := make([]string, len(seated))
+		var wg sync.WaitGroup
+		for m, i := range seated {
+			wg.Add(1)
+			go func(m, i int, engine selfPlayEngine) {
+				defer wg.Done()
+				result := clients[engine.name].move(perspective(game, i))
+				moves[m] = result.move
+				if result.err != nil || result.elapsed > timeout {
+					moves[m] = ""
+					if result.err != nil {
+						report.records[engine.name].moveErrors++
+					} else {
+						report.records[engine.name].timeouts++
+					}
+				}
+			}(m, i, seats[i])
+		}
+		wg.Wait()
+		for m := range moves {
+			if moves[m] == "" {
+				moves[m] = lastMove(game.Board.Snakes[m])
+			}
+		}
+		game.Board = stepBoard(game.Board, moves, rng)
+		for _, i := range seated {
+			lasted[i] = turn
+		}
+	}
+	for _, i := range playing() {
+		lasted[i] = cfg.turns + 1
+	}
+
+	for i, engine := range seats {
+		if err := clients[engine.name].post("/end", perspective(game, i)); err != nil {
+			fmt.Printf("%s: %s didn't end: %v\n", id, engine.name, err)
+		}
+	}
+	return lasted
+}
+
+func describeSelfPlayResult(pair [2]selfPlayEngine, scoreA float64) string {
+	switch scoreA {
+	case 1:
+		return pair[0].name + " won"
+	case 0:
+		return pair[1].name + " won"
+	}
+	return "draw"
+}
+
+func describeFreeForAllResult(seats []selfPlayEngine, lasted []int) string {
+	winner := 0
+	for i := range lasted {
+		if lasted[i] > lasted[winner] {
+			winner = i
+		}
+	}
+	for i := range lasted {
+		if i != winner && lasted[i] == lasted[winner] {
+			return "draw"
+		}
+	}
+	return seats[winner].name + " won"
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfPlayRecordScore(t *testing.T) {
+	testCases := []struct {
+		Description         string
+		Wins, Losses, Draws int
+		Score               float64
+	}{
+		{Description: "no games", Score: 0},
+		{Description: "all won", Wins: 4, Score: 1},
+		{Description: "all lost", Losses: 4, Score: 0},
+		{Description: "draws count half", Wins: 1, Losses: 1, Draws: 2, Score: 0.5},
+		{Description: "mixed", Wins: 6, Losses: 3, Draws: 1, Score: 0.65},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			record := selfPlayRecord{wins: tc.Wins, losses: tc.Losses, draws: tc.Draws}
+			assert.InDelta(t, tc.Score, record.score(), 1e-9)
+		})
+	}
+}
+
+func TestSelfPlayRecordInterval(t *testing.T) {
+	// the Wilson intervals for these are in any table of them
+	testCases := []struct {
+		Description  string
+		Wins, Losses int
+		Low, High    float64
+	}{
+		{Description: "no games", Low: 0, High: 1},
+		{Description: "8 of 10", Wins: 8, Losses: 2, Low: 0.4902, High: 0.9433},
+		{Description: "10 of 10", Wins: 10, Low: 0.7225, High: 1},
+		{Description: "none of 10", Losses: 10, Low: 0, High: 0.2775},
+		{Description: "50 of 100", Wins: 50, Losses: 50, Low: 0.4038, High: 0.5962},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			low, high := selfPlayRecord{wins: tc.Wins, losses: tc.Losses}.interval()
+			assert.InDelta(t, tc.Low, low, 1e-4)
+			assert.InDelta(t, tc.High, high, 1e-4)
+		})
+	}
+}
+
+func TestSelfPlayReportElo(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Scores      []float64 // a's score in each game, in order
+		A, B        float64
+	}{
+		{Description: "a draw between equals changes nothing", Scores: []float64{0.5}, A: 1500, B: 1500},
+		{Description: "a win between equals", Scores: []float64{1}, A: 1508, B: 1492},
+		{Description: "beating a weaker engine gains less", Scores: []float64{1, 1}, A: 1515.6318, B: 1484.3682},
+		{Description: "losing to a weaker engine costs more", Scores: []float64{1, 0}, A: 1499.6318, B: 1500.3682},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			report := newSelfPlayReport([]selfPlayEngine{{name: "a"}, {name: "b"}})
+			for _, score := range tc.Scores {
+				report.record("a", "b", score)
+			}
+			assert.InDelta(t, tc.A, report.records["a"].rating, 1e-4)
+			assert.InDelta(t, tc.B, report.records["b"].rating, 1e-4)
+			assert.InDelta(t, 2*selfPlayInitialRating, report.records["a"].rating+report.records["b"].rating, 1e-9, "what one gains the other loses")
+			assert.Equal(t, len(tc.Scores), report.games)
+		})
+	}
+}
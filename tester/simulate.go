@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brensch/aisnake/rules"
+)
+
+// simConfig holds the settings for a simulated run against the server.
+type simConfig struct {
+	url     string
+	games   int
+	turns   int
+	timeout int
+	seed    int64
+	faults  faultConfig
+	record  string // file to write every request to, for replaying in tests
+}
+
+// recordedRequest is one request as the engine sent it, in the format the replay tests read.
+type recordedRequest struct {
+	Path    string          `json:"path"`
+	Request BattleSnakeGame `json:"request"`
+}
+
+// recorder writes requests to a file one json object per line. The zero value records nothing.
+type recorder struct {
+	encoder *json.Encoder
+}
+
+func (r recorder) record(path string, game BattleSnakeGame) {
+	if r.encoder == nil {
+		return
+	}
+	if err := r.encoder.Encode(recordedRequest{Path: path, Request: game}); err != nil {
+		fmt.Printf("failed to record %s: %v\n", path, err)
+	}
+}
+
+// turnResult is what happened to one /move request, including any duplicates or retries.
+type turnResult struct {
+	gameID  string
+	turn    int
+	move    string
+	elapsed time.Duration
+	faults  []string
+	err     error
+	unsafe  bool
+}
+
+// simReport collects every turn of every game so problems can be listed at the end.
+type simReport struct {
+	timeout time.Duration
+	turns   []turnResult
+}
+
+func (r simReport) missed() []turnResult {
+	var missed []turnResult
+	for _, turn := range r.turns {
+		if turn.err != nil || turn.elapsed > r.timeout {
+			missed = append(missed, turn)
+		}
+	}
+	return missed
+}
+
+func (r simReport) unsafe() []turnResult {
+	var unsafe []turnResult
+	for _, turn := range r.turns {
+		if turn.unsafe {
+			unsafe = append(unsafe, turn)
+		}
+	}
+	return unsafe
+}
+
+func (r simReport) ok() bool {
+	return len(r.missed()) == 0 && len(r.unsafe()) == 0
+}
+
+func (r simReport) String() string {
+	var sb strings.Builder
+	faulted := 0
+	var slowest time.Duration
+	for _, turn := range r.turns {
+		if len(turn.faults) > 0 {
+			faulted++
+		}
+		if turn.elapsed > slowest {
+			slowest = turn.elapsed
+		}
+	}
+
+	fmt.Fprintf(&sb, "turns: %d, with faults: %d, slowest: %s of %s\n", len(r.turns), faulted, slowest, r.timeout)
+	for _, turn := range r.missed() {
+		fmt.Fprintf(&sb, "MISSED %s turn %d after %s (faults: %s): %v\n", turn.gameID, turn.turn, turn.elapsed, strings.Join(turn.faults, ","), turn.err)
+	}
+	for _, turn := range r.unsafe() {
+		fmt.Fprintf(&sb, "UNSAFE %s turn %d moved %s with a safe move available (faults: %s)\n", turn.gameID, turn.turn, turn.move, strings.Join(turn.faults, ","))
+	}
+	if r.ok() {
+		sb.WriteString("PASS\n")
+	} else {
+		sb.WriteString("FAIL\n")
+	}
+	return sb.String()
+}
+
+// runSimulation plays games against the server, with our snake controlled by the server
+// and a random opponent, pushing every move request through the fault injector.
+func runSimulation(cfg simConfig) simReport {
+	rng := rand.New(rand.NewSource(cfg.seed))
+	client := newFaultyClient(cfg.url, cfg.faults, rand.New(rand.NewSource(rng.Int63())))
+	report := simReport{timeout: time.Duration(cfg.timeout) * time.Millisecond}
+
+	var rec recorder
+	if cfg.record != "" {
+		f, err := os.Create(cfg.record)
+		if err != nil {
+			fmt.Printf("failed to create %s: %v\n", cfg.record, err)
+		} else {
+			defer f.Close()
+			rec.encoder = json.NewEncoder(f)
+		}
+	}
+
+	for g := 0; g < cfg.games; g++ {
+		game := newSimGame(fmt.Sprintf("sim-%d-%d", cfg.seed, g), cfg.timeout)
+		rec.record("/start", game)
+		if err := client.post("/start", game); err != nil {
+			report.turns = append(report.turns, turnResult{gameID: game.Game.ID, err: fmt.Errorf("start: %w", err)})
+			continue
+		}
+
+		latency := ""
+		for turn := 0; turn < cfg.turns; turn++ {
+			game.Turn = turn
+			game.You = game.Board.Snakes[0]
+			game.You.Latency = latency
+			game.Board.Snakes[0].Latency = latency
+
+			rec.record("/move", game)
+			result := client.move(game)
+			result.gameID = game.Game.ID
+			result.turn = turn
+			latency = fmt.Sprint(result.elapsed.Milliseconds())
+
+			safe := safeMoves(game.Board, 0)
+			move := result.move
+			if result.err != nil || result.elapsed > report.timeout {
+				// the engine moves us in the same direction as last turn when we're too slow
+				move = lastMove(game.Board.Snakes[0])
+			} else if len(safe) > 0 && !containsMove(safe, move) {
+				result.unsafe = true
+			}
+			report.turns = append(report.turns, result)
+
+			opponentMoves := safeMoves(game.Board, 1)
+			opponentMove := lastMove(game.Board.Snakes[1])
+			if len(opponentMoves) > 0 {
+				opponentMove = opponentMoves[rng.Intn(len(opponentMoves))]
+			}
+
+			game.Board = stepBoard(game.Board, []string{move, opponentMove}, rng)
+			if len(game.Board.Snakes) < 2 || game.Board.Snakes[0].ID != "us" {
+				break
+			}
+		}
+
+		rec.record("/end", game)
+		if err := client.post("/end", game); err != nil {
+			fmt.Printf("failed to end %s: %v\n", game.Game.ID, err)
+		}
+	}
+
+	return report
+}
+
+func newSimGame(id string, timeout int) BattleSnakeGame {
+	snake := func(id, name string, head Point) Snake {
+		return Snake{ID: id, Name: name, Health: 100, Head: head, Body: []Point{head, head, head}}
+	}
+
+	game := BattleSnakeGame{
+		Game: Game{
+			ID:      id,
+			Ruleset: Ruleset{Name: "standard", Version: "1.0.0", Settings: Settings{FoodSpawnChance: 15, MinimumFood: 1}},
+			Map:     "standard",
+			Source:  "custom",
+			Timeout: timeout,
+		},
+		Board: Board{
+			Height:  11,
+			Width:   11,
+			Food:    []Point{{X: 5, Y: 5}},
+			Hazards: []Point{},
+			Snakes: []Snake{
+				snake("us", "My Snake", Point{X: 1, Y: 1}),
+				snake("them", "Opponent Snake", Point{X: 9, Y: 9}),
+			},
+		},
+	}
+	game.You = game.Board.Snakes[0]
+	return game
+}
+
+func moveHead(head Point, move string) Point {
+	switch move {
+	case "up":
+		return Point{X: head.X, Y: head.Y + 1}
+	case "down":
+		return Point{X: head.X, Y: head.Y - 1}
+	case "left":
+		return Point{X: head.X - 1, Y: head.Y}
+	default:
+		return Point{X: head.X + 1, Y: head.Y}
+	}
+}
+
+// lastMove is the direction the snake moved last, which is what the engine plays on a timeout.
+func lastMove(snake Snake) string {
+	if len(snake.Body) < 2 || snake.Body[0] == snake.Body[1] {
+		return "up"
+	}
+	head, neck := snake.Body[0], snake.Body[1]
+	for _, move := range []string{"up", "down", "left", "right"} {
+		if moveHead(neck, move) == head {
+			return move
+		}
+	}
+	return "up"
+}
+
+func inBounds(board Board, p Point) bool {
+	return p.X >= 0 && p.X < board.Width && p.Y >= 0 && p.Y < board.Height
+}
+
+// safeMoves are the moves that don't leave the board or run into a body. Tails are free since they move this turn.
+func safeMoves(board Board, index int) []string {
+	head := board.Snakes[index].Head
+	var safe []string
+	for _, move := range []string{"up", "down", "left", "right"} {
+		next := moveHead(head, move)
+		if !inBounds(board, next) {
+			continue
+		}
+		blocked := false
+		for _, snake := range board.Snakes {
+			for _, segment := range snake.Body[:len(snake.Body)-1] {
+				if segment == next {
+					blocked = true
+				}
+			}
+		}
+		if !blocked {
+			safe = append(safe, move)
+		}
+	}
+	return safe
+}
+
+func containsMove(moves []string, move string) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
+
+// stepBoard plays the turn by the official rules and drops dead snakes, like the engine does.
+func stepBoard(board Board, moves []string, rng *rand.Rand) Board {
+	state := rules.BoardState{
+		Width:   board.Width,
+		Height:  board.Height,
+		Food:    toRulesPoints(board.Food),
+		Hazards: toRulesPoints(board.Hazards),
+	}
+	byID := make(map[string]string, len(board.Snakes))
+	for i, snake := range board.Snakes {
+		state.Snakes = append(state.Snakes, rules.Snake{ID: snake.ID, Body: toRulesPoints(snake.Body), Health: snake.Health})
+		byID[snake.ID] = moves[i]
+	}
+	state = rules.Step(state, rules.Settings{}, byID)
+
+	next := board
+	next.Food = fromRulesPoints(state.Food)
+	next.Snakes = nil
+	for i, snake := range state.Snakes {
+		if snake.EliminatedCause != rules.NotEliminated {
+			continue
+		}
+		alive := board.Snakes[i]
+		alive.Body = fromRulesPoints(snake.Body)
+		alive.Head = alive.Body[0]
+		alive.Health = snake.Health
+		next.Snakes = append(next.Snakes, alive)
+	}
+
+	if len(next.Food) == 0 || rng.Intn(100) < 15 {
+		p := Point{X: rng.Intn(next.Width), Y: rng.Intn(next.Height)}
+		occupied := false
+		for _, snake := range next.Snakes {
+			for _, segment := range snake.Body {
+				occupied = occupied || segment == p
+			}
+		}
+		if !occupied {
+			next.Food = append(next.Food, p)
+		}
+	}
+
+	return next
+}
+
+func toRulesPoints(points []Point) []rules.Point {
+	converted := make([]rules.Point, len(points))
+	for i, p := range points {
+		converted[i] = rules.Point{X: p.X, Y: p.Y}
+	}
+	return converted
+}
+
+func fromRulesPoints(points []rules.Point) []Point {
+	var converted []Point
+	for _, p := range points {
+		converted = append(converted, Point{X: p.X, Y: p.Y})
+	}
+	return converted
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// throttleSampleInterval is how often a guarded search checks its visit rate
+	throttleSampleInterval = 20 * time.Millisecond
+	// throttleCollapse is the share of the expected visit rate below which a sample counts as throttled
+	throttleCollapse = 0.25
+	// throttleSlowSamples in a row is what it takes to decide we're throttled rather than unlucky
+	throttleSlowSamples = 2
+	// throttleDeadlineShare is how much of the remaining search time is kept once throttled. everything after
+	// the search, writing the response included, is slow too so it needs a bigger share of the turn.
+	throttleDeadlineShare = 0.5
+	// throttleBaselineWeight is how much each healthy turn moves the expected visit rate
+	throttleBaselineWeight = 0.3
+)
+
+// ThrottleTracker remembers how fast searches run in this game while the cpu isn't being throttled.
+type ThrottleTracker struct {
+	mu       sync.Mutex
+	baseline float64 // visits per second
+}
+
+// Guard makes the guard for this turn's search, expecting the rate of previous turns.
+func (t *ThrottleTracker) Guard() *ThrottleGuard {
+	guard := &ThrottleGuard{}
+	if t == nil {
+		return guard
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	guard.baseline = t.baseline
+	return guard
+}
+
+// Record folds a finished search's visit rate into the expected rate. Throttled searches are left out,
+// otherwise a run of them would teach us that throttled is normal.
+func (t *ThrottleTracker) Record(guard *ThrottleGuard, visits int64, elapsed time.Duration) {
+	if t == nil || guard.Degraded() || visits <= 0 || elapsed <= 0 {
+		return
+	}
+	rate := float64(visits) / elapsed.Seconds()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.baseline == 0 {
+		t.baseline = rate
+		return
+	}
+	t.baseline += throttleBaselineWeight * (rate - t.baseline)
+}
+
+// ThrottleGuard watches one search's visit rate. When it collapses, which on cloud run means the cpu is
+// being throttled, the guard drops to fewer workers and the cheap evaluation, and ends the search early,
+// so we still answer with something sane instead of whatever a handful of noisy visits says.
+type ThrottleGuard struct {
+	baseline    float64
+	degraded    int32
+	workerLimit int32 // 0 until degraded
+}
+
+// Degraded says whether the search has been degraded.
+func (g *ThrottleGuard) Degraded() bool {
+	return g != nil && atomic.LoadInt32(&g.degraded) == 1
+}
+
+// allows says whether the worker with this index should keep going.
+func (g *ThrottleGuard) allows(worker int) bool {
+	if g == nil {
+		return true
+	}
+	limit := atomic.LoadInt32(&g.workerLimit)
+	return limit == 0 || int32(worker) < limit
+}
+
+// degrade cuts the search down. It only does anything the first time.
+func (g *ThrottleGuard) degrade(workers int) bool {
+	if !atomic.CompareAndSwapInt32(&g.degraded, 0, 1) {
+		return false
+	}
+	limit := workers / 2
+	if limit < 1 {
+		limit = 1
+	}
+	atomic.StoreInt32(&g.workerLimit, int32(limit))
+	return true
+}
+
+// throttleSampler turns the root's visit count at each tick into a rate and judges it against previous
+// turns and the best seen this turn.
+type throttleSampler struct {
+	baseline float64
+	peak     float64
+	slow     int // samples in a row that were too slow
+	last     int64
+	lastTime time.Time
+}
+
+// sample takes the visit count at the time and says whether the rate has been collapsed for long enough to
+// call it throttled, along with the rate and what it was judged against.
+func (s *throttleSampler) sample(visits int64, now time.Time) (rate, reference float64, throttled bool) {
+	rate = float64(visits-s.last) / now.Sub(s.lastTime).Seconds()
+	s.last, s.lastTime = visits, now
+
+	reference = s.baseline
+	if s.peak > reference {
+		reference = s.peak
+	}
+	if rate > s.peak {
+		s.peak = rate
+	}
+	if reference == 0 || rate >= throttleCollapse*reference {
+		s.slow = 0
+		return rate, reference, false
+	}
+	s.slow++
+	return rate, reference, s.slow >= throttleSlowSamples
+}
+
+// watch samples the root's visit rate until the context is done, degrading the search and calling stop
+// early if the rate collapses.
+func (g *ThrottleGuard) watch(ctx context.Context, stop context.CancelFunc, root *Node, workers int) {
+	ticker := time.NewTicker(throttleSampleInterval)
+	defer ticker.Stop()
+	g.watchTicks(ctx, stop, root, workers, time.Now(), ticker.C)
+}
+
+// watchTicks is watch sampling on each tick, from the start.
+func (g *ThrottleGuard) watchTicks(ctx context.Context, stop context.CancelFunc, root *Node, workers int, start time.Time, ticks <-chan time.Time) {
+	sampler := &throttleSampler{baseline: g.baseline, last: atomic.LoadInt64(&root.Visits), lastTime: start}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticks:
+			rate, reference, throttled := sampler.sample(atomic.LoadInt64(&root.Visits), now)
+			if !throttled || !g.degrade(workers) {
+				continue
+			}
+
+			slog.Warn("cpu throttled, degrading search",
+				"visits_per_second", int64(rate),
+				"expected_per_second", int64(reference),
+				"workers", atomic.LoadInt32(&g.workerLimit),
+			)
+			if deadline, ok := ctx.Deadline(); ok {
+				remaining := time.Until(deadline)
+				timer := time.NewTimer(time.Duration(float64(remaining) * throttleDeadlineShare))
+				defer timer.Stop()
+				select {
+				case <-ctx.Done():
+				case <-timer.C:
+					stop()
+				}
+			}
+			return
+		}
+	}
+}
+
+// WithThrottleGuard has the guard watch the search and degrade it if the cpu gets throttled.
+func WithThrottleGuard(guard *ThrottleGuard) func(*searchOptions) {
+	return func(o *searchOptions) {
+		o.throttle = guard
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleTrackerBaseline(t *testing.T) {
+	var nilTracker *ThrottleTracker
+	assert.NotNil(t, nilTracker.Guard())
+	nilTracker.Record(nilTracker.Guard(), 100, time.Second)
+
+	tracker := &ThrottleTracker{}
+	tracker.Record(tracker.Guard(), 1000, time.Second)
+	assert.Equal(t, 1000.0, tracker.Guard().baseline)
+
+	tracker.Record(tracker.Guard(), 2000, time.Second)
+	assert.InDelta(t, 1300.0, tracker.Guard().baseline, 1e-9)
+
+	throttled := tracker.Guard()
+	throttled.degrade(4)
+	tracker.Record(throttled, 10, time.Second)
+	assert.InDelta(t, 1300.0, tracker.Guard().baseline, 1e-9, "throttled turns don't count")
+}
+
+func TestThrottleGuardDegrade(t *testing.T) {
+	var nilGuard *ThrottleGuard
+	assert.False(t, nilGuard.Degraded())
+	assert.True(t, nilGuard.allows(7))
+
+	guard := &ThrottleGuard{}
+	assert.True(t, guard.allows(3))
+	assert.True(t, guard.degrade(4))
+	assert.False(t, guard.degrade(4), "only degrades once")
+	assert.True(t, guard.Degraded())
+	assert.True(t, guard.allows(1))
+	assert.False(t, guard.allows(2))
+
+	single := &ThrottleGuard{}
+	single.degrade(1)
+	assert.True(t, single.allows(0), "always keeps one worker")
+}
+
+// sampleAt is the time of the sampler's nth tick after the start.
+func sampleAt(start time.Time, n int) time.Time {
+	return start.Add(time.Duration(n) * throttleSampleInterval)
+}
+
+func TestThrottleSamplerCatchesCollapse(t *testing.T) {
+	start := time.Unix(0, 0)
+	sampler := &throttleSampler{lastTime: start}
+	visits := int64(0)
+	for n := 1; n <= 5; n++ {
+		visits += 200
+		_, _, throttled := sampler.sample(visits, sampleAt(start, n))
+		assert.False(t, throttled)
+	}
+
+	// then nothing, as if the cpu went away
+	_, _, throttled := sampler.sample(visits, sampleAt(start, 6))
+	assert.False(t, throttled, "one slow sample could be bad luck")
+	rate, reference, throttled := sampler.sample(visits, sampleAt(start, 7))
+	assert.True(t, throttled)
+	assert.Zero(t, rate)
+	assert.InDelta(t, 10000.0, reference, 1e-6, "judged against the best rate this turn")
+}
+
+func TestThrottleSamplerLeavesSteadySearchAlone(t *testing.T) {
+	start := time.Unix(0, 0)
+	sampler := &throttleSampler{lastTime: start}
+	visits := int64(0)
+	for n := 1; n <= 10; n++ {
+		visits += 200
+		// every other sample dips, but not by enough to be a collapse
+		if n%2 == 0 {
+			visits -= 140
+		}
+		_, _, throttled := sampler.sample(visits, sampleAt(start, n))
+		assert.False(t, throttled, "sample %d", n)
+	}
+}
+
+func TestThrottleSamplerComparesWithEarlierTurns(t *testing.T) {
+	start := time.Unix(0, 0)
+	// previous turns managed far more than this turn ever does
+	sampler := &throttleSampler{baseline: 1e8, lastTime: start}
+	_, _, throttled := sampler.sample(200, sampleAt(start, 1))
+	assert.False(t, throttled)
+	_, _, throttled = sampler.sample(400, sampleAt(start, 2))
+	assert.True(t, throttled)
+}
+
+func TestThrottleGuardStopsTheSearch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	searchCtx, stop := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer stop()
+
+	start := time.Now()
+	ticks := make(chan time.Time)
+	guard := &ThrottleGuard{baseline: 1e8}
+	done := make(chan struct{})
+	go func() {
+		guard.watchTicks(searchCtx, stop, &Node{}, 4, start, ticks)
+		close(done)
+	}()
+	ticks <- sampleAt(start, 1)
+	ticks <- sampleAt(start, 2)
+	<-done
+
+	assert.True(t, guard.Degraded())
+	assert.False(t, guard.allows(2))
+	assert.ErrorIs(t, searchCtx.Err(), context.Canceled, "stopped with time to spare rather than at the deadline")
+	assert.NoError(t, ctx.Err())
+}
+
+func TestDegradedSearchUsesCheapModules(t *testing.T) {
+	node := NewNode(evalTestBoard(), 0, nil)
+	guard := &ThrottleGuard{}
+	opts := &searchOptions{throttle: guard}
+	assert.Equal(t, evaluateScores(node.Board, modules), opts.evaluate(node))
+
+	guard.degrade(2)
+	assert.Equal(t, evaluateScores(node.Board, cheapModules), opts.evaluate(node))
+}
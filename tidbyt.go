@@ -2,10 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"image"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 const (
@@ -13,17 +17,113 @@ const (
 	deviceID = "jocundly-liberated-allied-panda-3f1"
 )
 
+const (
+	// tidbytPushLength is about how long the tidbyt shows a push before going back to its rotation
+	tidbytPushLength = 15 * time.Second
+	// tidbytMinFrameDelay is as quick as frames go, any faster and the display can't keep up.
+	// tidbytMaxFrameDelay is as slow, so short games don't crawl.
+	tidbytMinFrameDelay = 50 * time.Millisecond
+	tidbytMaxFrameDelay = 200 * time.Millisecond
+	// tidbytFramesPerPush is as many frames as fit in one push at the quickest
+	tidbytFramesPerPush = int(tidbytPushLength / tidbytMinFrameDelay)
+	// tidbytMaxPushes is the most pushes one game gets, longer games skip frames instead
+	tidbytMaxPushes = 4
+	// tidbytLastFrameDelay holds the final position, then the outcome screen is shown for tidbytOutcomeDelay
+	tidbytLastFrameDelay = 2 * time.Second
+	tidbytOutcomeDelay   = time.Second
+)
+
+// TidbytPush is one part of a game for the tidbyt, and how long it takes to play through.
+type TidbytPush struct {
+	WebP     []byte
+	Duration time.Duration
+}
+
+// tidbytParts splits the game's frames into pushes, each short enough to play through at a watchable speed
+// before the tidbyt moves on. Games too long even for tidbytMaxPushes skip frames evenly, always keeping
+// the last one.
+func tidbytParts(frames int) [][]int {
+	if frames == 0 {
+		return nil
+	}
+	step := 1
+	for (frames+step-1)/step > tidbytFramesPerPush*tidbytMaxPushes {
+		step++
+	}
+	var indices []int
+	for i := 0; i < frames; i += step {
+		indices = append(indices, i)
+	}
+	if indices[len(indices)-1] != frames-1 {
+		indices = append(indices, frames-1)
+	}
+
+	pushes := min(tidbytMaxPushes, (len(indices)+tidbytFramesPerPush-1)/tidbytFramesPerPush)
+	size := (len(indices) + pushes - 1) / pushes
+	var parts [][]int
+	for len(indices) > 0 {
+		n := min(size, len(indices))
+		parts = append(parts, indices[:n])
+		indices = indices[n:]
+	}
+	return parts
+}
+
+// encodeGameWebPs renders the frames for the tidbyt as animated webps, one per push, with the last one
+// finishing on the outcome screen.
+func encodeGameWebPs(frames []*Board, outcome GameOutcome) ([]TidbytPush, error) {
+	if len(frames) == 0 {
+		return nil, errNothingToDisplay
+	}
+	snakePalette := newSnakePalette(frames[0])
+	parts := tidbytParts(len(frames))
+	pushes := make([]TidbytPush, 0, len(parts))
+	for i, part := range parts {
+		// webp delays are whole milliseconds
+		delay := (tidbytPushLength / time.Duration(len(part))).Truncate(time.Millisecond)
+		if delay > tidbytMaxFrameDelay {
+			delay = tidbytMaxFrameDelay
+		}
+		images := make([]image.Image, 0, len(part)+1)
+		delays := make([]time.Duration, 0, len(part)+1)
+		for _, index := range part {
+			img, _ := renderBoardToImage(frames[index], snakePalette)
+			images = append(images, img)
+			delays = append(delays, delay)
+		}
+		if i == len(parts)-1 {
+			delays[len(delays)-1] = tidbytLastFrameDelay
+			images = append(images, outcomeScreen(images[0].Bounds(), outcome))
+			delays = append(delays, tidbytOutcomeDelay)
+		}
+
+		var push TidbytPush
+		ms := make([]int, len(delays))
+		for j, d := range delays {
+			ms[j] = int(d.Milliseconds())
+			push.Duration += d
+		}
+		var err error
+		push.WebP, err = encodeWebPAnimation(images, ms)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode part %d of %d: %w", i+1, len(parts), err)
+		}
+		pushes = append(pushes, push)
+	}
+	return pushes, nil
+}
+
 type PushRequest struct {
 	Image          string `json:"image"`
 	InstallationID string `json:"installationID,omitempty"`
 	Background     bool   `json:"background"`
 }
 
-func PushToTidbyt(deviceID, webpBase64 string) error {
+func PushToTidbyt(ctx context.Context, deviceID string, webp []byte) error {
 
 	// Prepare the request body
 	requestBody := PushRequest{
-		Image:      webpBase64,
+		Image:      base64.StdEncoding.EncodeToString(webp),
 		Background: false, // Set to true if you want to push the image in the background
 	}
 
@@ -35,15 +135,14 @@ func PushToTidbyt(deviceID, webpBase64 string) error {
 
 	// Send the POST request to Tidbyt
 	pushURL := fmt.Sprintf(apiURL, deviceID)
-	req, err := http.NewRequest("POST", pushURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", pushURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %v", err)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidbytSecret))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidbytSecret.Get()))
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := tidbytClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request to Tidbyt API: %v", err)
 	}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTidbytParts(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Frames      int
+		Pushes      int
+		Step        int
+	}{
+		{"short game, one push", 40, 1, 1},
+		{"exactly one push", tidbytFramesPerPush, 1, 1},
+		{"just over splits in half", tidbytFramesPerPush + 2, 2, 1},
+		{"as long as fits", tidbytFramesPerPush * tidbytMaxPushes, tidbytMaxPushes, 1},
+		{"twice as long as fits skips two frames in three", tidbytFramesPerPush*tidbytMaxPushes*2 + 50, 3, 3},
+		{"a bit longer skips every other frame", tidbytFramesPerPush*tidbytMaxPushes + 10, 3, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			parts := tidbytParts(tc.Frames)
+			require.Equal(t, tc.Pushes, len(parts))
+			var all []int
+			for _, part := range parts {
+				assert.LessOrEqual(t, len(part), tidbytFramesPerPush+1)
+				all = append(all, part...)
+			}
+			assert.Equal(t, 0, all[0])
+			assert.Equal(t, tc.Frames-1, all[len(all)-1], "the last frame's always there")
+			assert.Equal(t, tc.Step, all[1]-all[0])
+			assert.LessOrEqual(t, len(parts[0])-len(parts[len(parts)-1]), 1, "the parts are even")
+		})
+	}
+	assert.Empty(t, tidbytParts(0))
+}
+
+func TestEncodeGameWebPs(t *testing.T) {
+	frames := rendererTestFrames()
+	for len(frames) < tidbytFramesPerPush+10 {
+		frames = append(frames, frames[len(frames)%2])
+	}
+	pushes, err := encodeGameWebPs(frames, Win)
+	require.NoError(t, err)
+	require.Len(t, pushes, 2)
+
+	names, chunks := webpChunks(t, pushes[0].WebP)
+	assert.Len(t, names, 2+len(frames)/2, "header, animation, then a frame each")
+	assert.Equal(t, []byte{canvasWidth - 1, 0, 0, canvasHeight - 1, 0, 0}, chunks[0][4:], "the tidbyt's 64x32")
+	delay := (tidbytPushLength / time.Duration(len(frames)/2)).Truncate(time.Millisecond)
+	assert.Equal(t, delay*time.Duration(len(frames)/2), pushes[0].Duration)
+
+	names, _ = webpChunks(t, pushes[1].WebP)
+	assert.Len(t, names, 2+len(frames)/2+1, "the last push finishes on the outcome")
+	assert.Greater(t, pushes[1].Duration, pushes[0].Duration)
+
+	_, err = encodeGameWebPs(nil, Win)
+	assert.ErrorIs(t, err, errNothingToDisplay)
+}
+
+func TestTidbytCellSizeFitsTheScreen(t *testing.T) {
+	for _, size := range []int{7, 11, 15, 19, 25} {
+		board := &Board{Width: size, Height: size, Food: []Point{{X: size - 1, Y: 0}, {X: 0, Y: size - 1}}}
+		cell := tidbytCellSize(board)
+		assert.LessOrEqual(t, board.Width*cell, tidbytBoardWidth, "%dx%d", size, size)
+		assert.LessOrEqual(t, board.Height*cell, canvasHeight+1, "%dx%d", size, size)
+
+		// the food in the bottom right and top left corners are both drawn
+		img, _ := renderBoardToImage(board, newSnakePalette(board))
+		green := img.RGBAAt(canvasWidth-1, (size-1)*cell)
+		assert.Equal(t, uint8(255), green.G, "%dx%d bottom right", size, size)
+		green = img.RGBAAt(canvasWidth-size*cell, 0)
+		assert.Equal(t, uint8(255), green.G, "%dx%d top left", size, size)
+	}
+	assert.Equal(t, cellSize, tidbytCellSize(&Board{Width: 11, Height: 11}), "standard boards stay as they were")
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// trainingDataEnabled turns on keeping every searched move as training data. Off by default since it's a
+// shard in the bucket for every game.
+var trainingDataEnabled = os.Getenv("TRAINING_DATA") == "true"
+
+// TrainingSample is one searched move the AlphaZero way: the board, where the search spent its visits,
+// and how the game turned out for us.
+type TrainingSample struct {
+	GameID  string     `json:"game_id"`
+	Turn    int        `json:"turn"`
+	Width   int        `json:"width"`
+	Height  int        `json:"height"`
+	Planes  []float64  `json:"planes"`  // valueNetPlanes grids of width by height, from our point of view
+	Policy  [4]float64 `json:"policy"`  // share of the root's visits by move: up, down, left, right
+	Value   float64    `json:"value"`   // what the search thought of the move it played
+	Outcome float64    `json:"outcome"` // 1 if we won, -1 if we lost, 0 for a draw
+}
+
+// trainingTurn is a searched move waiting on the end of the game. The board is only encoded once the
+// game's over, so the move itself costs no more than a copy.
+type trainingTurn struct {
+	turn   int
+	board  Board
+	policy [4]float64
+	value  float64
+}
+
+// TrainingLog keeps a game's searched moves until the game ends and they can be written out.
+type TrainingLog struct {
+	mu    sync.Mutex
+	turns []trainingTurn
+}
+
+// Add records the board we searched from and where the search's visits went.
+func (l *TrainingLog) Add(turn int, board Board, root *Node, value float64) {
+	policy, ok := visitDistribution(root)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.turns = append(l.turns, trainingTurn{turn: turn, board: copyBoard(board), policy: policy, value: value})
+}
+
+// Samples encodes the moves recorded so far with the game's outcome.
+func (l *TrainingLog) Samples(gameID string, outcome GameOutcome) []TrainingSample {
+	l.mu.Lock()
+	turns := append([]trainingTurn(nil), l.turns...)
+	l.mu.Unlock()
+
+	samples := make([]TrainingSample, 0, len(turns))
+	for _, turn := range turns {
+		samples = append(samples, TrainingSample{
+			GameID:  gameID,
+			Turn:    turn.turn,
+			Width:   turn.board.Width,
+			Height:  turn.board.Height,
+			Planes:  encodeBoardPlanes(newEvaluationContext(turn.board, 0), turn.board.Width, turn.board.Height),
+			Policy:  turn.policy,
+			Value:   turn.value,
+			Outcome: outcomeValue(outcome),
+		})
+	}
+	return samples
+}
+
+// visitDistribution is the share of the root's visits each of our moves got, false if nothing was visited.
+func visitDistribution(root *Node) ([4]float64, bool) {
+	var policy [4]float64
+	if root == nil || len(root.Board.Snakes) == 0 {
+		return policy, false
+	}
+	total := 0.0
+	for _, child := range root.Children() {
+		move := directionFromString(moveForChild(root, child))
+		visits := float64(atomic.LoadInt64(&child.Visits))
+		if move == Unset || visits == 0 {
+			continue
+		}
+		policy[move-Up] += visits
+		total += visits
+	}
+	if total == 0 {
+		return policy, false
+	}
+	for i := range policy {
+		policy[i] /= total
+	}
+	return policy, true
+}
+
+func outcomeValue(outcome GameOutcome) float64 {
+	switch outcome {
+	case Win:
+		return 1
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+// encodeTrainingShard writes the samples as gzipped JSON lines.
+func encodeTrainingShard(samples []TrainingSample) ([]byte, error) {
+	var buf bytes.Buffer
+	compressed := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(compressed)
+	for _, sample := range samples {
+		if err := encoder.Encode(sample); err != nil {
+			return nil, err
+		}
+	}
+	if err := compressed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// trainingShardObject is where a game's shard goes in the bucket, by the day it ended so a training run
+// can pick up a date range.
+func trainingShardObject(gameID string, end time.Time) string {
+	return fmt.Sprintf("training-data/%s/%s.jsonl.gz", end.UTC().Format("2006-01-02"), gameID)
+}
+
+// trainingStage writes the game's searched moves to the bucket with how it turned out.
+func trainingStage(ctx context.Context, job *EndOfGameJob) error {
+	if !trainingDataEnabled {
+		return nil
+	}
+	outcome, _ := describeGameOutcome(job.Game)
+	samples := job.Session.training.Samples(job.Session.ID, outcome)
+	if len(samples) == 0 {
+		return nil
+	}
+	data, err := encodeTrainingShard(samples)
+	if err != nil {
+		return fmt.Errorf("failed to encode training data: %w", err)
+	}
+	return uploadToBucket(ctx, trainingShardObject(job.Session.ID, job.End), "application/gzip", data)
+}
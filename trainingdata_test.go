@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisitDistribution(t *testing.T) {
+	root := NewNode(evalTestBoard(), -1, nil)
+	visits := map[Direction]int64{Up: 30, Right: 10}
+	for move, count := range visits {
+		board := copyBoard(root.Board)
+		applyMove(&board, 0, move)
+		child := NewNode(board, 0, root)
+		child.Visits = count
+		root.addChild(child)
+	}
+
+	policy, ok := visitDistribution(root)
+	require.True(t, ok)
+	assert.Equal(t, [4]float64{0.75, 0, 0, 0.25}, policy)
+
+	_, ok = visitDistribution(NewNode(evalTestBoard(), -1, nil))
+	assert.False(t, ok, "nothing searched, nothing to learn")
+}
+
+func TestTrainingLogSamples(t *testing.T) {
+	board := evalTestBoard()
+	root := MCTS(context.Background(), "training", board, 200, 1, make(map[string]*Node))
+
+	log := &TrainingLog{}
+	log.Add(3, board, root, 0.4)
+	log.Add(4, board, NewNode(board, -1, nil), 0)
+	board.Snakes[0].Health = 1 // the log keeps its own copy
+
+	samples := log.Samples("game", Loss)
+	require.Len(t, samples, 1, "an unsearched turn is left out")
+	sample := samples[0]
+	assert.Equal(t, "game", sample.GameID)
+	assert.Equal(t, 3, sample.Turn)
+	assert.Equal(t, -1.0, sample.Outcome)
+	assert.Equal(t, 0.4, sample.Value)
+	assert.Len(t, sample.Planes, valueNetPlanes*11*11)
+	assert.Equal(t, 0.9, sample.Planes[(planeRootHealth*11+5)*11+5], "encoded from the board as it was")
+	total := 0.0
+	for _, share := range sample.Policy {
+		total += share
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+}
+
+func TestTrainingShardRoundTrip(t *testing.T) {
+	samples := []TrainingSample{
+		{GameID: "game", Turn: 1, Width: 1, Height: 1, Planes: make([]float64, valueNetPlanes), Policy: [4]float64{1, 0, 0, 0}, Outcome: 1},
+		{GameID: "game", Turn: 2, Width: 1, Height: 1, Planes: make([]float64, valueNetPlanes), Policy: [4]float64{0, 0.5, 0.5, 0}, Outcome: 1},
+	}
+	data, err := encodeTrainingShard(samples)
+	require.NoError(t, err)
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	var decoded []TrainingSample
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	for decoder.More() {
+		var sample TrainingSample
+		require.NoError(t, decoder.Decode(&sample))
+		decoded = append(decoded, sample)
+	}
+	assert.Equal(t, samples, decoded)
+	assert.Equal(t, 2, bytes.Count(raw, []byte("\n")), "one sample a line")
+}
+
+func TestTrainingShardObject(t *testing.T) {
+	end := time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("PDT", -7*3600))
+	assert.Equal(t, "training-data/2024-03-10/game.jsonl.gz", trainingShardObject("game", end))
+}
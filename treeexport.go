@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// treeExportDepth, treeExportChildren and treeExportMinVisits bound an exported tree to the part of it
+	// the search spent its time on. Every node carries its board and voronoi, so the whole tree after a
+	// long search is far too big to load.
+	treeExportDepth     = 6
+	treeExportChildren  = 3
+	treeExportMinVisits = 10
+	// treeExportsKept is how many exported trees are served from memory. Older ones come from the bucket.
+	treeExportsKept = 50
+	// treeUploadTime is how long an export gets to reach the bucket.
+	treeUploadTime = 30 * time.Second
+)
+
+// treeExportEnabled exports the tree behind every move for the visualiser. It's a lot of json, so it's off
+// unless TREE_EXPORT is true.
+var treeExportEnabled = os.Getenv("TREE_EXPORT") == "true"
+
+// treeExportOptions are the bounds every export is made with.
+var treeExportOptions = []func(*treeOptions){
+	WithMaxDepth(treeExportDepth),
+	WithTopChildren(treeExportChildren),
+	WithMinVisits(treeExportMinVisits),
+}
+
+// TreeStore keeps the latest exported trees in memory, already encoded, dropping the oldest once it's full.
+type TreeStore struct {
+	limit int
+
+	mu    sync.Mutex
+	trees map[string][]byte
+	order []string // tree ids, oldest first
+}
+
+func newTreeStore(limit int) *TreeStore {
+	return &TreeStore{limit: limit, trees: make(map[string][]byte)}
+}
+
+// treeExports is every tree this instance has exported lately.
+var treeExports = newTreeStore(treeExportsKept)
+
+func (s *TreeStore) Add(id string, tree []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.trees[id]; !ok {
+		s.order = append(s.order, id)
+		if len(s.order) > s.limit {
+			delete(s.trees, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	s.trees[id] = tree
+}
+
+func (s *TreeStore) Get(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tree, ok := s.trees[id]
+	return tree, ok
+}
+
+// moveTreeID names the tree behind a move, unique per snake of ours in the game.
+func moveTreeID(gameID, youID string, turn int) string {
+	return fmt.Sprintf("%s_%s_%d", gameID, youID, turn)
+}
+
+func treeObject(id string) string {
+	return fmt.Sprintf("trees/%s.json", id)
+}
+
+// treeURL is where the exported tree is served.
+func treeURL(id string) string {
+	return "/trees/" + id
+}
+
+// exportTree keeps the bounded tree for /trees/{id}, with each node's eval by the modules the search used,
+// and returns its url. The tree's encoded straight away since the next search starts changing it, but it
+// goes to the bucket in the background when upload is set.
+func exportTree(id string, root *Node, modules []EvaluationModule, upload bool, logger *slog.Logger) string {
+	options := append([]func(*treeOptions){WithEvalBreakdown(modules)}, treeExportOptions...)
+	var tree bytes.Buffer
+	if err := writeTreeData(&tree, root, options...); err != nil {
+		logger.Error("failed to encode tree", "error", err.Error())
+		return ""
+	}
+	treeExports.Add(id, tree.Bytes())
+	if upload {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), treeUploadTime)
+			defer cancel()
+			if err := uploadToBucket(ctx, treeObject(id), "application/json", tree.Bytes()); err != nil {
+				logger.Warn("failed to upload tree", "tree", id, "error", err.Error())
+			}
+		}()
+	}
+	return treeURL(id)
+}
+
+// loadTree finds the exported tree in memory, or failing that in the bucket.
+func loadTree(ctx context.Context, id string) ([]byte, error) {
+	if tree, ok := treeExports.Get(id); ok {
+		return tree, nil
+	}
+	return downloadFromBucket(ctx, treeObject(id))
+}
+
+// handleTree serves /trees/{id}, an exported tree in the format the visualiser reads.
+func handleTree(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/trees/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	tree, err := loadTree(r.Context(), id)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		http.Error(w, "no tree with that id", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(tree)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func treeDepth(tree *TreeNode) int {
+	deepest := 0
+	for _, child := range tree.Children {
+		deepest = max(deepest, treeDepth(child)+1)
+	}
+	return deepest
+}
+
+func searchedTestTree(t *testing.T) *Node {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	root := MCTS(ctx, "tree", blunderTestBoard(), 5000, 1, nil)
+	require.Greater(t, maxTreeDepth(root), 2, "the search has to go deeper than the export for the test to mean anything")
+	return root
+}
+
+func TestGenerateTreeDataMaxDepth(t *testing.T) {
+	root := searchedTestTree(t)
+	assert.Equal(t, 2, treeDepth(generateTreeData(root, WithMaxDepth(2))))
+	assert.Equal(t, 1, treeDepth(generateTreeData(root, WithMaxDepth(1))))
+	assert.Greater(t, treeDepth(generateTreeData(root)), 2, "no limit exports the whole tree")
+}
+
+func assertTreeBounds(t *testing.T, tree *TreeNode, children int, minVisits int64) {
+	t.Helper()
+	assert.LessOrEqual(t, len(tree.Children), children)
+	for i, child := range tree.Children {
+		assert.GreaterOrEqual(t, child.Visits, minVisits)
+		assert.Equal(t, i == 0, child.IsMostVisited)
+		if i > 0 {
+			assert.LessOrEqual(t, child.Visits, tree.Children[i-1].Visits)
+		}
+		assertTreeBounds(t, child, children, minVisits)
+	}
+}
+
+func TestGenerateTreeDataSampled(t *testing.T) {
+	root := searchedTestTree(t)
+	whole := generateTreeData(root)
+	sampled := generateTreeData(root, WithTopChildren(1), WithMinVisits(50))
+	assertTreeBounds(t, sampled, 1, 50)
+	assert.Equal(t, whole.Children[0].ID, sampled.Children[0].ID, "the most visited child is the one kept")
+	assert.NotZero(t, sampled.Children[0].AverageScore)
+}
+
+func TestWriteTreeDataMatchesGenerate(t *testing.T) {
+	root := searchedTestTree(t)
+	options := []func(*treeOptions){WithMaxDepth(3), WithTopChildren(2), WithMinVisits(5), WithEvalBreakdown(modules)}
+	expected, err := json.Marshal(generateTreeData(root, options...))
+	require.NoError(t, err)
+	var streamed bytes.Buffer
+	require.NoError(t, writeTreeData(&streamed, root, options...))
+	assert.JSONEq(t, string(expected), streamed.String())
+}
+
+func TestTreeStore(t *testing.T) {
+	store := newTreeStore(2)
+	store.Add("a", []byte("a"))
+	store.Add("b", []byte("b"))
+	store.Add("a", []byte("a again"))
+	store.Add("c", []byte("c"))
+
+	_, ok := store.Get("a")
+	assert.False(t, ok, "the oldest tree is dropped once the store's full")
+	tree, ok := store.Get("c")
+	require.True(t, ok)
+	assert.Equal(t, "c", string(tree))
+}
+
+func TestExportedTreeServed(t *testing.T) {
+	root := searchedTestTree(t)
+	url := exportTree(moveTreeID("game", "us", 3), root, modules, false, slog.Default())
+	assert.Equal(t, "/trees/game_us_3", url)
+
+	router := newRouter("secret")
+	get := func(path, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusUnauthorized, get(url, "").Code)
+	assert.Equal(t, http.StatusNotFound, get("/trees/", "secret").Code)
+
+	rec := get(url, "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tree TreeNode
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tree))
+	assert.Equal(t, root.Visits, tree.Visits)
+	assert.LessOrEqual(t, treeDepth(&tree), treeExportDepth)
+	assertTreeBounds(t, &tree, treeExportChildren, treeExportMinVisits)
+	require.NotEmpty(t, tree.Children)
+	child := tree.Children[0]
+	assert.Equal(t, evaluationBreakdown(child.Board, 0, modules), child.Eval, "the root's children are our moves")
+}
+
+func TestTreeEvalBreakdown(t *testing.T) {
+	root := searchedTestTree(t)
+	assert.Nil(t, generateTreeData(root).Eval, "the breakdown's only worked out when it's asked for")
+
+	tree := generateTreeData(root, WithMaxDepth(2), WithEvalBreakdown(modules))
+	require.NotEmpty(t, tree.Children)
+	require.NotEmpty(t, tree.Children[0].Children)
+	reply := tree.Children[0].Children[0]
+	assert.Contains(t, reply.Eval, "voronoi")
+	// their reply is scored for them
+	assert.Equal(t, evaluationBreakdown(reply.Board, 1, modules), reply.Eval)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// defaultTreeReuseNodes is how much of a turn's tree we carry into the next one. A node is a board and a
+// few slices, so this keeps it to a couple of hundred megabytes on a long game.
+const defaultTreeReuseNodes = 200000
+
+// treeReuseNodes is the budget from TREE_REUSE_NODES, or the default when it isn't a positive number.
+var treeReuseNodes = treeReuseNodesFromEnv()
+
+func treeReuseNodesFromEnv() int {
+	if nodes, err := strconv.Atoi(os.Getenv("TREE_REUSE_NODES")); err == nil && nodes > 0 {
+		return nodes
+	}
+	return defaultTreeReuseNodes
+}
+
+// TreeReuse is what was carried over from a turn's tree.
+type TreeReuse struct {
+	Replies int // boards the opponents could leave us, each the root of a kept subtree
+	Nodes   int // nodes kept beneath and including the replies
+	Pruned  int // nodes cut to get under the budget
+}
+
+// reuseTree re-roots the tree at the move we played. Every reply the opponents could make is kept, keyed
+// by its board, with everything searched beneath it, so whichever one they make next turn picks up where
+// this one left off. A reply that finished the turn is kept by the outcomes it rolled instead, each under
+// its own board. The moves we didn't play can't come up again and are let go.
+//
+// If the replies hold more than the budget the least visited branches are cut until they fit. The cut
+// moves go back to being unexpanded, so the search grows them again if they turn out to matter.
+func reuseTree(played *Node, budget int) (map[string]*Node, TreeReuse) {
+	states := make(map[string]*Node)
+	var reuse TreeReuse
+	if played == nil {
+		return states, reuse
+	}
+	replies := played.Children()
+	reuse.Replies = len(replies)
+
+	var visits []int64
+	for _, reply := range replies {
+		visits = collectVisits(reply, visits)
+	}
+	reuse.Nodes = len(visits)
+	if budget > 0 && reuse.Nodes > budget {
+		// a parent has at least the visits of any child, so cutting everything under a visit count leaves a
+		// connected tree. the replies themselves are always kept.
+		sort.Slice(visits, func(i, j int) bool { return visits[i] > visits[j] })
+		threshold := visits[budget-1]
+		for _, reply := range replies {
+			reuse.Pruned += pruneBelow(reply, threshold)
+		}
+		reuse.Nodes -= reuse.Pruned
+	}
+
+	// a chance node is never searched from, the next turn starts from whichever outcome really happened
+	for _, reply := range replies {
+		if !reply.chance {
+			states[boardHash(reply.Board)] = reply
+			continue
+		}
+		for _, outcome := range reply.Children() {
+			states[boardHash(outcome.Board)] = outcome
+		}
+	}
+	return states, reuse
+}
+
+// collectVisits appends the visits of the node and everything beneath it.
+func collectVisits(node *Node, visits []int64) []int64 {
+	visits = append(visits, atomic.LoadInt64(&node.Visits))
+	for _, child := range node.Children() {
+		visits = collectVisits(child, visits)
+	}
+	return visits
+}
+
+// pruneBelow cuts the node's children with fewer visits than the threshold, and theirs, putting the moves
+// back as unexpanded. It returns how many nodes went.
+func pruneBelow(node *Node, threshold int64) int {
+	pruned := 0
+	var kept []*Node
+	unexpanded := append([]Direction(nil), node.UnexpandedMoves()...)
+	for _, child := range node.Children() {
+		if atomic.LoadInt64(&child.Visits) >= threshold {
+			kept = append(kept, child)
+			pruned += pruneBelow(child, threshold)
+			continue
+		}
+		pruned += countNodes(child)
+		if move := childMove(node, child); move != Unset {
+			unexpanded = append(unexpanded, move)
+		}
+	}
+	node.setChildren(kept)
+	node.setUnexpandedMoves(unexpanded)
+	return pruned
+}
+
+// countNodes is the size of the subtree under and including the node.
+func countNodes(node *Node) int {
+	count := 1
+	for _, child := range node.Children() {
+		count += countNodes(child)
+	}
+	return count
+}
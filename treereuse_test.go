@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func treeReuseTestRoot(t *testing.T) *Node {
+	t.Helper()
+	board := Board{
+		Height: 7,
+		Width:  7,
+		Snakes: []Snake{
+			{ID: "snake1", Head: Point{X: 1, Y: 1}, Health: 100, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "snake2", Head: Point{X: 5, Y: 5}, Health: 100, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+		},
+	}
+	return MCTS(context.Background(), "testid", board, 3000, 1, make(map[string]*Node))
+}
+
+func TestReuseTree(t *testing.T) {
+	root := treeReuseTestRoot(t)
+	played := chooseRootChild(root).Node
+	require.NotNil(t, played)
+
+	saved, reuse := reuseTree(played, 0)
+	assert.Len(t, saved, len(played.Children()), "only the replies to the move we play are kept")
+	assert.Equal(t, len(saved), reuse.Replies)
+	assert.Equal(t, countNodes(played)-1, reuse.Nodes, "everything under the move we played comes along")
+	assert.Zero(t, reuse.Pruned)
+	for key, node := range saved {
+		assert.Equal(t, played, node.Parent)
+		assert.Equal(t, boardHash(node.Board), key)
+	}
+
+	// picking one of them up as next turn's root carries on from its subtree and detaches it
+	var next *Node
+	for _, node := range saved {
+		if next == nil || node.Visits > next.Visits {
+			next = node
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	warm := next.Visits
+	playedVisits := played.Visits
+	reused := MCTS(ctx, "testid", copyBoard(next.Board), int(warm)+500, 4, saved)
+	assert.Equal(t, next, reused)
+	assert.Nil(t, reused.Parent)
+	assert.GreaterOrEqual(t, reused.Visits, warm+500)
+	assert.Equal(t, playedVisits, played.Visits, "visits no longer flow into last turn's tree")
+
+	empty, reuse := reuseTree(nil, treeReuseNodes)
+	assert.Empty(t, empty)
+	assert.Zero(t, reuse)
+}
+
+func TestReuseTreeBudget(t *testing.T) {
+	root := treeReuseTestRoot(t)
+	played := chooseRootChild(root).Node
+	require.NotNil(t, played)
+	before := countNodes(played) - 1
+	budget := before / 4
+
+	saved, reuse := reuseTree(played, budget)
+	require.Positive(t, reuse.Pruned)
+	assert.Len(t, saved, len(played.Children()), "every reply is kept however small the budget")
+	assert.Equal(t, before, reuse.Nodes+reuse.Pruned)
+	assert.Equal(t, countNodes(played)-1, reuse.Nodes)
+	// ties on the threshold can keep a few over
+	assert.InDelta(t, budget, reuse.Nodes, float64(budget)/10)
+
+	// what's left still adds up and the cut moves can be searched again
+	var check func(node *Node)
+	check = func(node *Node) {
+		expanded := make(map[Direction]bool)
+		for _, child := range node.Children() {
+			assert.Equal(t, node, child.Parent)
+			move := directionTo(&node.Board, node.Board.Snakes[child.SnakeIndex].Head, child.Board.Snakes[child.SnakeIndex].Head)
+			expanded[move] = true
+			check(child)
+		}
+		for _, move := range node.UnexpandedMoves() {
+			assert.False(t, expanded[move], "%v is both expanded and waiting to be", move)
+		}
+	}
+	for _, reply := range saved {
+		check(reply)
+	}
+
+	for _, reply := range saved {
+		reused := MCTS(context.Background(), "testid", copyBoard(reply.Board), int(reply.Visits)+200, 1, saved)
+		assert.Equal(t, reply, reused, "a pruned reply still picks up as the root")
+		break
+	}
+}
+
+func TestReuseTreeKeepsOutcomes(t *testing.T) {
+	board := Board{
+		Height:          7,
+		Width:           7,
+		FoodSpawnChance: 50,
+		Snakes: []Snake{
+			{ID: "snake1", Head: Point{X: 1, Y: 1}, Health: 100, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}}},
+			{ID: "snake2", Head: Point{X: 5, Y: 5}, Health: 100, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}}},
+		},
+	}
+	root := MCTS(context.Background(), "testid", board, 3000, 1, make(map[string]*Node))
+	played := chooseRootChild(root).Node
+	require.NotNil(t, played)
+
+	saved, reuse := reuseTree(played, 0)
+	assert.Equal(t, len(played.Children()), reuse.Replies)
+	outcomes := 0
+	for _, reply := range played.Children() {
+		require.True(t, reply.chance, "the reply finishes the turn")
+		outcomes += len(reply.Children())
+	}
+	assert.Len(t, saved, outcomes, "next turn starts from whichever outcome really happened")
+	for key, node := range saved {
+		assert.False(t, node.chance)
+		assert.Equal(t, boardHash(node.Board), key)
+	}
+}
+
+func TestTreeReuseNodesFromEnv(t *testing.T) {
+	t.Setenv("TREE_REUSE_NODES", "")
+	assert.Equal(t, defaultTreeReuseNodes, treeReuseNodesFromEnv())
+	t.Setenv("TREE_REUSE_NODES", "5000")
+	assert.Equal(t, 5000, treeReuseNodesFromEnv())
+	t.Setenv("TREE_REUSE_NODES", "-1")
+	assert.Equal(t, defaultTreeReuseNodes, treeReuseNodesFromEnv())
+}
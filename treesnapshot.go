@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+// treeSnapshotVersion changes whenever the snapshot layout does, so old snapshots are refused
+// rather than read wrong.
+const treeSnapshotVersion = 3
+
+// TreeSnapshot is a search tree written out compactly, for keeping trees between instances or turns and
+// for looking at production searches offline. Only the root's board is kept: every other board is the
+// parent's with one move applied, so it's replayed on the way back in instead of stored. The outcomes of
+// a chance node keep the food and hazards they rolled, since there's no move to replay.
+type TreeSnapshot struct {
+	Version        int
+	Engine         string // build that searched the tree
+	Root           Board
+	RootSnakeIndex int
+	Nodes          []NodeRecord // depth first, each node followed by its children's subtrees
+}
+
+// NodeRecord is one node of a snapshot without its board.
+type NodeRecord struct {
+	Move          Direction // what the node's snake played to get here from the parent, unset for the root
+	Chance        bool      // whether the node's children are outcomes rather than moves
+	Food          []Point   // an outcome's food
+	Hazards       []Point   // an outcome's hazards
+	Children      int
+	Visits        int64
+	Score         float64
+	Scores        []float64
+	MyScore       float64
+	MyScores      []float64
+	Unexpanded    []Direction
+	LeafVisits    int64
+	Reevaluations int32
+	Prior         float64
+	Priors        []float64 // by direction, nil if the search had no policy
+}
+
+// loadFloat64 reads a float the search adds to atomically.
+func loadFloat64(addr *float64) float64 {
+	return math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(addr))))
+}
+
+// snapshotTree flattens the tree under the root. It's safe to call while the tree is being searched,
+// though the counts will be from slightly different moments.
+func snapshotTree(root *Node) (*TreeSnapshot, error) {
+	snapshot := &TreeSnapshot{
+		Version:        treeSnapshotVersion,
+		Engine:         engineBuild.String(),
+		Root:           copyBoard(root.Board),
+		RootSnakeIndex: root.SnakeIndex,
+	}
+
+	var walk func(node *Node, move Direction, outcome bool) error
+	walk = func(node *Node, move Direction, outcome bool) error {
+		children := node.Children()
+		record := NodeRecord{
+			Move:          move,
+			Chance:        node.chance,
+			Children:      len(children),
+			Visits:        atomic.LoadInt64(&node.Visits),
+			Score:         loadFloat64(&node.Score),
+			Unexpanded:    append([]Direction(nil), node.UnexpandedMoves()...),
+			LeafVisits:    atomic.LoadInt64(&node.leafVisits),
+			Reevaluations: atomic.LoadInt32(&node.reevaluations),
+			Prior:         node.Prior,
+			Priors:        append([]float64(nil), node.priors...),
+		}
+		record.Scores = make([]float64, len(node.Scores))
+		for i := range node.Scores {
+			record.Scores[i] = loadFloat64(&node.Scores[i])
+		}
+		if outcome {
+			record.Food = append([]Point(nil), node.Board.Food...)
+			record.Hazards = append([]Point(nil), node.Board.Hazards...)
+		}
+		// the evaluation is stored under the node's mutex the first time it's visited
+		node.mutex.Lock()
+		record.MyScore = node.MyScore
+		record.MyScores = append([]float64(nil), node.MyScores...)
+		node.mutex.Unlock()
+		snapshot.Nodes = append(snapshot.Nodes, record)
+
+		for _, child := range children {
+			if node.chance {
+				if err := walk(child, Unset, true); err != nil {
+					return err
+				}
+				continue
+			}
+			from := node.Board.Snakes[child.SnakeIndex].Head
+			childMove := directionTo(&node.Board, from, child.Board.Snakes[child.SnakeIndex].Head)
+			if childMove == Unset {
+				return fmt.Errorf("can't tell the move from %v to %v", from, child.Board.Snakes[child.SnakeIndex].Head)
+			}
+			if err := walk(child, childMove, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, Unset, false); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Tree rebuilds the nodes, replaying each move to get its board back.
+func (s *TreeSnapshot) Tree() (*Node, error) {
+	if s.Version != treeSnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d, want %d", s.Version, treeSnapshotVersion)
+	}
+	if len(s.Nodes) == 0 {
+		return nil, fmt.Errorf("snapshot has no nodes")
+	}
+
+	next := 0
+	var build func(board Board, snakeIndex int, parent *Node) (*Node, error)
+	build = func(board Board, snakeIndex int, parent *Node) (*Node, error) {
+		if next >= len(s.Nodes) {
+			return nil, fmt.Errorf("snapshot ends partway through the tree")
+		}
+		record := s.Nodes[next]
+		next++
+
+		node := NewNode(board, snakeIndex, parent)
+		node.chance = record.Chance
+		node.Visits = record.Visits
+		node.Score = record.Score
+		node.MyScore = record.MyScore
+		node.MyScores = record.MyScores
+		node.setUnexpandedMoves(record.Unexpanded)
+		node.leafVisits = record.LeafVisits
+		node.reevaluations = record.Reevaluations
+		node.Prior = record.Prior
+		node.priors = record.Priors
+		if len(record.Scores) == len(node.Scores) {
+			node.Scores = record.Scores
+		}
+
+		childIndex := (snakeIndex + 1) % len(board.Snakes)
+		for i := 0; i < record.Children; i++ {
+			if next >= len(s.Nodes) {
+				return nil, fmt.Errorf("snapshot ends partway through the tree")
+			}
+			childBoard, index := copyBoard(board), childIndex
+			if record.Chance {
+				childBoard.Food, childBoard.Hazards, index = s.Nodes[next].Food, s.Nodes[next].Hazards, snakeIndex
+				childBoard.Turn++
+			} else {
+				applyMove(&childBoard, childIndex, s.Nodes[next].Move)
+				// a turn with nothing left to chance ends on the move that finishes it, the same as in the search
+				if childIndex == len(board.Snakes)-1 && !s.Nodes[next].Chance && !hasChance(childBoard) {
+					rollChance(&childBoard)
+				}
+			}
+			child, err := build(childBoard, index, node)
+			if err != nil {
+				return nil, err
+			}
+			node.addChild(child)
+		}
+		return node, nil
+	}
+
+	root, err := build(copyBoard(s.Root), s.RootSnakeIndex, nil)
+	if err != nil {
+		return nil, err
+	}
+	if next != len(s.Nodes) {
+		return nil, fmt.Errorf("snapshot has %d nodes left over", len(s.Nodes)-next)
+	}
+	return root, nil
+}
+
+// EncodeTree writes the tree under the root as a gob.
+func EncodeTree(w io.Writer, root *Node) error {
+	snapshot, err := snapshotTree(root)
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(snapshot)
+}
+
+// DecodeTree reads a tree written by EncodeTree.
+func DecodeTree(r io.Reader) (*Node, error) {
+	var snapshot TreeSnapshot
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode tree: %w", err)
+	}
+	return snapshot.Tree()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertSameTree checks the rebuilt tree matches the searched one node for node.
+func assertSameTree(t *testing.T, want, got *Node) {
+	t.Helper()
+	require.Equal(t, want.Board, got.Board)
+	require.Equal(t, want.SnakeIndex, got.SnakeIndex)
+	require.Equal(t, want.chance, got.chance)
+	require.Equal(t, want.Visits, got.Visits)
+	require.InDelta(t, want.Score, got.Score, 1e-9)
+	require.Equal(t, want.Scores, got.Scores)
+	require.Equal(t, want.MyScores, got.MyScores)
+	require.ElementsMatch(t, want.UnexpandedMoves(), got.UnexpandedMoves())
+	require.Equal(t, want.leafVisits, got.leafVisits)
+	require.Equal(t, want.Prior, got.Prior)
+	require.Equal(t, want.priors, got.priors)
+	require.Len(t, got.Children(), len(want.Children()))
+	for i := range want.Children() {
+		require.Same(t, got, got.Children()[i].Parent)
+		assertSameTree(t, want.Children()[i], got.Children()[i])
+	}
+}
+
+func searchedTree(t *testing.T) *Node {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return MCTS(ctx, "snapshot", evalTestBoard(), 1500, 1, make(map[string]*Node))
+}
+
+func TestTreeSnapshotRoundTrip(t *testing.T) {
+	root := searchedTree(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeTree(&buf, root))
+	decoded, err := DecodeTree(&buf)
+	require.NoError(t, err)
+
+	assertSameTree(t, root, decoded)
+	assert.Nil(t, decoded.Parent)
+}
+
+func TestTreeSnapshotKeepsPriors(t *testing.T) {
+	config := SearchConfig{Policy: heuristicPolicy{}}
+	root := MCTS(context.Background(), "snapshot", evalTestBoard(), 500, 1, make(map[string]*Node), WithSearchConfig(config))
+	require.NotNil(t, root.priors)
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeTree(&buf, root))
+	decoded, err := DecodeTree(&buf)
+	require.NoError(t, err)
+	assertSameTree(t, root, decoded)
+}
+
+func TestTreeSnapshotChanceNodes(t *testing.T) {
+	board := evalTestBoard()
+	board.FoodSpawnChance = 50
+	root := MCTS(context.Background(), "snapshot", board, 1500, 1, make(map[string]*Node))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeTree(&buf, root))
+	decoded, err := DecodeTree(&buf)
+	require.NoError(t, err)
+	assertSameTree(t, root, decoded)
+}
+
+func TestTreeSnapshotSkipsBoards(t *testing.T) {
+	root := searchedTree(t)
+	snapshot, err := snapshotTree(root)
+	require.NoError(t, err)
+
+	var withoutBoards, oneBoard bytes.Buffer
+	require.NoError(t, EncodeTree(&withoutBoards, root))
+	require.NoError(t, gob.NewEncoder(&oneBoard).Encode(root.Board))
+	assert.Less(t, withoutBoards.Len(), len(snapshot.Nodes)*oneBoard.Len(), "smaller than storing every node's board")
+	assert.Equal(t, engineBuild.String(), snapshot.Engine)
+}
+
+func TestDecodedTreeKeepsSearching(t *testing.T) {
+	root := searchedTree(t)
+	var buf bytes.Buffer
+	require.NoError(t, EncodeTree(&buf, root))
+	decoded, err := DecodeTree(&buf)
+	require.NoError(t, err)
+
+	before := decoded.Visits
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	searchFrom(ctx, "snapshot", decoded, int(before)+500, 1, &searchOptions{preferredMove: Unset, config: defaultSearchConfig})
+	assert.GreaterOrEqual(t, decoded.Visits, before+500)
+}
+
+func TestTreeSnapshotRejectsBadSnapshots(t *testing.T) {
+	snapshot, err := snapshotTree(searchedTree(t))
+	require.NoError(t, err)
+
+	old := *snapshot
+	old.Version = treeSnapshotVersion + 1
+	_, err = old.Tree()
+	assert.Error(t, err, "different version")
+
+	truncated := *snapshot
+	truncated.Nodes = truncated.Nodes[:len(truncated.Nodes)-1]
+	_, err = truncated.Tree()
+	assert.Error(t, err, "missing nodes")
+
+	extra := *snapshot
+	extra.Nodes = append(append([]NodeRecord(nil), extra.Nodes...), NodeRecord{})
+	_, err = extra.Tree()
+	assert.Error(t, err, "nodes left over")
+
+	_, err = (&TreeSnapshot{Version: treeSnapshotVersion}).Tree()
+	assert.Error(t, err, "empty")
+
+	_, err = DecodeTree(bytes.NewReader([]byte("not a gob")))
+	assert.Error(t, err)
+}
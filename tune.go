@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SPSA's standard gain exponents, from Spall's guidelines for practical use.
+const (
+	spsaAlpha = 0.602
+	spsaGamma = 0.101
+)
+
+// ModuleWeights are evaluation module weights by module name, the way the tuner writes them and the
+// server reads them back with EVAL_WEIGHTS.
+type ModuleWeights map[string]float64
+
+// loadModuleWeights reads weights written by the tuner.
+func loadModuleWeights(path string) (ModuleWeights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var weights ModuleWeights
+	if err := json.Unmarshal(data, &weights); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return weights, nil
+}
+
+// Save writes the weights where loadModuleWeights can read them.
+func (w ModuleWeights) Save(path string) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// withWeights is a copy of the modules with the weights swapped in. A weight for a module that isn't
+// there is an error, so a file from an old set of modules doesn't quietly do nothing.
+func withWeights(modules []EvaluationModule, weights ModuleWeights) ([]EvaluationModule, error) {
+	weighted := append([]EvaluationModule(nil), modules...)
+	for name, weight := range weights {
+		found := false
+		for i := range weighted {
+			if weighted[i].Name == name {
+				weighted[i].Weight = weight
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no evaluation module called %q", name)
+		}
+		if weight < 0 || math.IsNaN(weight) {
+			return nil, fmt.Errorf("module %q has weight %v", name, weight)
+		}
+	}
+	return weighted, nil
+}
+
+// applyWeightsFromEnv swaps in the weights from the file EVAL_WEIGHTS points at, if it's set.
+func applyWeightsFromEnv() error {
+	path := os.Getenv("EVAL_WEIGHTS")
+	if path == "" {
+		return nil
+	}
+	weights, err := loadModuleWeights(path)
+	if err != nil {
+		return err
+	}
+	weighted, err := withWeights(modules, weights)
+	if err != nil {
+		return err
+	}
+	modules = weighted
+	return nil
+}
+
+// tuneConfig holds the settings for a tuning run.
+type tuneConfig struct {
+	out          string
+	iterations   int
+	games        int
+	visits       int
+	size         int
+	turns        int
+	seed         int64
+	step         float64
+	perturbation float64
+}
+
+// runTune tunes the evaluation weights with SPSA against itself and writes them to the out file.
+// Usage: main tune [-out weights.json] [-iterations 100] [-games 8] [-visits 300]
+func runTune(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	flags.SetOutput(out)
+	cfg := tuneConfig{}
+	flags.StringVar(&cfg.out, "out", "weights.json", "file to write the tuned weights to, for EVAL_WEIGHTS")
+	flags.IntVar(&cfg.iterations, "iterations", 100, "SPSA steps to take")
+	flags.IntVar(&cfg.games, "games", 8, "games played between the two perturbed weights each step")
+	flags.IntVar(&cfg.visits, "visits", 300, "visits each side searches per move")
+	flags.IntVar(&cfg.size, "size", 11, "board width and height")
+	flags.IntVar(&cfg.turns, "turns", 200, "turns before a game is called a draw")
+	flags.Int64Var(&cfg.seed, "seed", time.Now().UnixNano(), "seed for perturbations and starting positions")
+	flags.Float64Var(&cfg.step, "step", 0.5, "SPSA step size, in log weight")
+	flags.Float64Var(&cfg.perturbation, "perturbation", 0.2, "SPSA perturbation size, in log weight")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if cfg.iterations <= 0 || cfg.games <= 0 {
+		return errors.New("need at least one iteration and one game")
+	}
+
+	// the search logs every tree it looks up, which would drown out the progress
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	start, err := tunableModules(modules)
+	if err != nil {
+		return err
+	}
+	match := func(plus, minus []EvaluationModule, iteration int) float64 {
+		return playTuneMatch(cfg, plus, minus, cfg.seed+int64(iteration)*int64(cfg.games))
+	}
+	rng := rand.New(rand.NewSource(cfg.seed))
+	tuned := spsaTune(cfg, start, match, rng, func(iteration int, current []EvaluationModule, score float64) {
+		fmt.Fprintf(out, "iteration %d: perturbed pair scored %+.2f, weights %s\n", iteration, score, describeWeights(current))
+		// save as we go so a long run that dies part way still counts
+		if err := weightsOf(current).Save(cfg.out); err != nil {
+			fmt.Fprintf(out, "failed to save %s: %v\n", cfg.out, err)
+		}
+	})
+	if err := weightsOf(tuned).Save(cfg.out); err != nil {
+		return err
+	}
+
+	// one last match so it's clear whether the run got anywhere
+	score := playTuneMatch(cfg, tuned, start, cfg.seed-int64(cfg.games))
+	fmt.Fprintf(out, "tuned %s scored %+.2f against the starting %s\n", describeWeights(tuned), score, describeWeights(start))
+	return nil
+}
+
+// tunableModules is the modules the tuner works on: the ones that always take part. Modules that only
+// turn up on some boards wouldn't show up in the duels the tuner plays.
+func tunableModules(modules []EvaluationModule) ([]EvaluationModule, error) {
+	var tunable []EvaluationModule
+	for _, module := range modules {
+		if module.Active == nil && module.Weight > 0 {
+			tunable = append(tunable, module)
+		}
+	}
+	if len(tunable) < 2 {
+		return nil, errors.New("need at least two weighted modules to tune")
+	}
+	return tunable, nil
+}
+
+// tuneMatch plays the plus weights against the minus weights and returns the plus side's score, from -1
+// when it lost every game to 1 when it won them all.
+type tuneMatch func(plus, minus []EvaluationModule, iteration int) float64
+
+// spsaTune runs SPSA on the logs of the module weights. Each step moves every weight up or down by the
+// same amount at random, plays the two perturbed sets against each other, and steps every weight towards
+// the side that did better. Only the weights' ratios matter to evaluateContext, so after each step they're
+// scaled back to the total they started with. onIteration, if set, sees every step's weights.
+func spsaTune(cfg tuneConfig, start []EvaluationModule, match tuneMatch, rng *rand.Rand, onIteration func(int, []EvaluationModule, float64)) []EvaluationModule {
+	theta := make([]float64, len(start))
+	total := 0.0
+	for i, module := range start {
+		theta[i] = math.Log(module.Weight)
+		total += module.Weight
+	}
+	weighted := func(theta []float64) []EvaluationModule {
+		weights := append([]EvaluationModule(nil), start...)
+		sum := 0.0
+		for i := range weights {
+			weights[i].Weight = math.Exp(theta[i])
+			sum += weights[i].Weight
+		}
+		for i := range weights {
+			weights[i].Weight *= total / sum
+		}
+		return weights
+	}
+
+	// the stability constant is a tenth of the run, as Spall suggests
+	stability := float64(cfg.iterations) / 10
+	for k := 0; k < cfg.iterations; k++ {
+		a := cfg.step / math.Pow(float64(k+1)+stability, spsaAlpha)
+		c := cfg.perturbation / math.Pow(float64(k+1), spsaGamma)
+
+		delta := make([]float64, len(theta))
+		plus := make([]float64, len(theta))
+		minus := make([]float64, len(theta))
+		for i := range theta {
+			delta[i] = float64(2*rng.Intn(2) - 1)
+			plus[i] = theta[i] + c*delta[i]
+			minus[i] = theta[i] - c*delta[i]
+		}
+
+		score := match(weighted(plus), weighted(minus), k)
+		for i := range theta {
+			theta[i] += a * score / (2 * c * delta[i])
+		}
+		// keep the logs centred the same way the weights are scaled
+		current := weighted(theta)
+		for i := range theta {
+			theta[i] = math.Log(current[i].Weight)
+		}
+		if onIteration != nil {
+			onIteration(k, current, score)
+		}
+	}
+	return weighted(theta)
+}
+
+// playTuneMatch plays games between the two sets of weights, swapping seats each game, and returns the
+// first set's score from -1 to 1. Games are played at the same time and each is deterministic for its seed.
+func playTuneMatch(cfg tuneConfig, first, second []EvaluationModule, seed int64) float64 {
+	results := make([]int, cfg.games)
+	var wg sync.WaitGroup
+	for g := 0; g < cfg.games; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			a, b := SearchConfig{Modules: first}, SearchConfig{Modules: second}
+			// the same seed twice in a row so both sides get the same start from each seat
+			gameSeed := seed + int64(g/2)
+			if g%2 == 0 {
+				results[g] = playTuneGame(cfg, a, b, gameSeed)
+			} else {
+				results[g] = -playTuneGame(cfg, b, a, gameSeed)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, result := range results {
+		total += result
+	}
+	return float64(total) / float64(cfg.games)
+}
+
+// playTuneGame plays one game by the official turn order and returns 1 if the first config wins, -1 if
+// the second does and 0 for a draw.
+func playTuneGame(cfg tuneConfig, first, second SearchConfig, seed int64) int {
+	rng := rand.New(rand.NewSource(seed))
+	board := newDuelBoard(cfg.size, rng)
+	for turn := 0; turn < cfg.turns && !isTerminal(board); turn++ {
+		applyMoves(&board, []Direction{
+			duelEngine(board, 0, first, cfg.visits),
+			duelEngine(board, 1, second, cfg.visits),
+		})
+		spawnFoodWith(&board, rng.Intn)
+	}
+
+	firstDead, secondDead := isSnakeDead(board.Snakes[0]), isSnakeDead(board.Snakes[1])
+	switch {
+	case firstDead && !secondDead:
+		return -1
+	case secondDead && !firstDead:
+		return 1
+	}
+	return 0
+}
+
+// duelEngine picks a move for the snake at the index with a fixed number of visits. It plays the most visited
+// move rather than going through chooseRootChild so the only difference between the sides is the config.
+func duelEngine(board Board, index int, config SearchConfig, iterations int) Direction {
+	view := reorderSnakes(copyBoard(board), board.Snakes[index].ID)
+	root := MCTS(context.Background(), "duel", view, iterations, 1, make(map[string]*Node), WithSearchConfig(config))
+	var best *Node
+	for _, child := range root.Children() {
+		if best == nil || child.Visits > best.Visits {
+			best = child
+		}
+	}
+	return directionFromString(moveForChild(root, best))
+}
+
+// newDuelBoard starts two snakes on random cells with a little food about, so games don't all mirror each other.
+func newDuelBoard(size int, rng *rand.Rand) Board {
+	board := newDojoBoard(size, rng)
+	board.Food = nil
+	for i := range board.Snakes {
+		for {
+			p := Point{X: rng.Intn(size), Y: rng.Intn(size)}
+			if i == 0 || manhattanDistance(p, board.Snakes[0].Head) >= size/2 {
+				board.Snakes[i].Head = p
+				board.Snakes[i].Body = []Point{p, p, p}
+				break
+			}
+		}
+	}
+	// a few more than the ruleset's minimum to start with
+	start := board
+	start.MinimumFood = 3
+	spawnFoodWith(&start, rng.Intn)
+	board.Food = start.Food
+	return board
+}
+
+func weightsOf(modules []EvaluationModule) ModuleWeights {
+	weights := make(ModuleWeights, len(modules))
+	for _, module := range modules {
+		weights[module.Name] = module.Weight
+	}
+	return weights
+}
+
+func describeWeights(modules []EvaluationModule) string {
+	sorted := append([]EvaluationModule(nil), modules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	description := ""
+	for i, module := range sorted {
+		if i > 0 {
+			description += " "
+		}
+		description += fmt.Sprintf("%s=%.2f", module.Name, module.Weight)
+	}
+	return description
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleWeightsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weights.json")
+	weights := ModuleWeights{"voronoi": 7.5, "length": 3}
+	require.NoError(t, weights.Save(path))
+
+	loaded, err := loadModuleWeights(path)
+	require.NoError(t, err)
+	assert.Equal(t, weights, loaded)
+
+	weighted, err := withWeights(modules, loaded)
+	require.NoError(t, err)
+	for i, module := range weighted {
+		if weight, ok := weights[module.Name]; ok {
+			assert.Equal(t, weight, module.Weight, module.Name)
+		} else {
+			assert.Equal(t, modules[i].Weight, module.Weight, "%s keeps its weight", module.Name)
+		}
+	}
+	assert.NotEqual(t, 7.5, modules[0].Weight, "the engine's own modules are left alone")
+}
+
+func TestWithWeightsRejectsBadWeights(t *testing.T) {
+	_, err := withWeights(modules, ModuleWeights{"nonsense": 1})
+	assert.Error(t, err, "unknown module")
+	_, err = withWeights(modules, ModuleWeights{"voronoi": -1})
+	assert.Error(t, err, "negative weight")
+}
+
+func TestTunableModulesSkipsSituationalOnes(t *testing.T) {
+	tunable, err := tunableModules(modules)
+	require.NoError(t, err)
+	for _, module := range tunable {
+		assert.Nil(t, module.Active, module.Name)
+	}
+	assert.Less(t, len(tunable), len(modules), "forecast only plays in royale")
+}
+
+// a match that the side leaning harder on the first module always wins should push its weight up,
+// keeping the total where it started.
+func TestSPSAFollowsTheWinner(t *testing.T) {
+	start := []EvaluationModule{{Name: "a", Weight: 5}, {Name: "b", Weight: 5}, {Name: "c", Weight: 5}}
+	match := func(plus, minus []EvaluationModule, iteration int) float64 {
+		share := func(modules []EvaluationModule) float64 {
+			return modules[0].Weight / (modules[0].Weight + modules[1].Weight + modules[2].Weight)
+		}
+		switch {
+		case share(plus) > share(minus):
+			return 1
+		case share(plus) < share(minus):
+			return -1
+		}
+		return 0
+	}
+
+	iterations := 0
+	cfg := tuneConfig{iterations: 50, step: 0.5, perturbation: 0.2}
+	tuned := spsaTune(cfg, start, match, rand.New(rand.NewSource(1)), func(int, []EvaluationModule, float64) { iterations++ })
+
+	assert.Equal(t, 50, iterations)
+	assert.Greater(t, tuned[0].Weight, tuned[1].Weight)
+	assert.Greater(t, tuned[0].Weight, tuned[2].Weight)
+	assert.InDelta(t, 15, tuned[0].Weight+tuned[1].Weight+tuned[2].Weight, 1e-9)
+	assert.Equal(t, 5.0, start[0].Weight, "the starting modules are left alone")
+}
+
+func TestPlayTuneMatchIsDeterministic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("plays whole games")
+	}
+	cfg := tuneConfig{games: 2, visits: 30, size: 7, turns: 30}
+	score := playTuneMatch(cfg, modules, modules, 1)
+	assert.Equal(t, score, playTuneMatch(cfg, modules, modules, 1))
+	assert.GreaterOrEqual(t, score, -1.0)
+	assert.LessOrEqual(t, score, 1.0)
+}
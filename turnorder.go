@@ -0,0 +1,82 @@
+package main
+
+// The search plays snakes one at a time in index order, but the real game resolves every move
+// at once. These helpers are the single place that decides which parts of a snake's body are
+// still in the way of another snake's head, so the simulator, move generation and voronoi all
+// agree on when tails move.
+//
+// From the point of view of the snake at viewerIndex, about to place its head:
+//   - snakes with a lower index have already moved this round, so their body is exactly as stored.
+//   - snakes with a higher index haven't moved yet, but they will move in the same real turn,
+//     so their tail is already free.
+//   - the viewer's own tail moves as it moves, so its tail is free too.
+//
+// A snake that just ate has its last two segments stacked, so dropping the tail still leaves the
+// tail square occupied, which matches the official rules.
+
+// pendingTailMoves returns how many tail segments of the target will have moved by the time the
+// viewer's head lands on its next move.
+func pendingTailMoves(viewerIndex, targetIndex int) int {
+	if targetIndex < viewerIndex {
+		return 0
+	}
+	return 1
+}
+
+// effectiveBody returns the segments of the target snake that block the viewer's next move.
+// The target's head is included; dead snakes block nothing.
+func effectiveBody(board *Board, viewerIndex, targetIndex int) []Point {
+	return effectiveBodyAfterSteps(board, viewerIndex, targetIndex, 0)
+}
+
+// effectiveBodyAfterMove returns the segments of the target snake that block the mover's head
+// after the mover has already been applied to the board. This is the view resolveCollisions needs:
+// the mover's own tail has already been removed, and everyone up to the mover has moved.
+func effectiveBodyAfterMove(board *Board, moverIndex, targetIndex int) []Point {
+	return effectiveBodyAfterSteps(board, moverIndex+1, targetIndex, 0)
+}
+
+// effectiveBodyAfterSteps returns the segments of the target snake that will still be in place
+// when the viewer reaches a square steps moves after its next one, assuming nobody eats.
+func effectiveBodyAfterSteps(board *Board, viewerIndex, targetIndex, steps int) []Point {
+	target := board.Snakes[targetIndex]
+	if isSnakeDead(target) {
+		return nil
+	}
+
+	remove := steps + pendingTailMoves(viewerIndex, targetIndex)
+	if remove >= len(target.Body) {
+		return nil
+	}
+	return target.Body[:len(target.Body)-remove]
+}
+
+// blocksPoint reports whether any snake's effective body covers the point for the viewer's next move.
+func blocksPoint(board *Board, viewerIndex int, point Point) bool {
+	for i := range board.Snakes {
+		for _, segment := range effectiveBody(board, viewerIndex, i) {
+			if segment == point {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hitsBody reports whether moving the viewer's head to the point runs into a body segment.
+// Heads of snakes that have already moved this round are left out, since landing on them is a
+// head-to-head collision that resolveCollisions settles by length rather than a certain death.
+func hitsBody(board *Board, viewerIndex int, point Point) bool {
+	for i := range board.Snakes {
+		body := effectiveBody(board, viewerIndex, i)
+		if i < viewerIndex && len(body) > 0 {
+			body = body[1:]
+		}
+		for _, segment := range body {
+			if segment == point {
+				return true
+			}
+		}
+	}
+	return false
+}
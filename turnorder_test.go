@@ -0,0 +1,162 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// three snakes so every viewer has a snake before and after it in the turn order
+func turnOrderTestBoard() Board {
+	return Board{
+		Height: 11,
+		Width:  11,
+		Snakes: []Snake{
+			{ID: "a", Health: 100, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 1, Y: 3}}},
+			{ID: "b", Health: 100, Head: Point{X: 5, Y: 5}, Body: []Point{{X: 5, Y: 5}, {X: 5, Y: 6}, {X: 5, Y: 7}, {X: 5, Y: 7}}}, // just ate
+			{ID: "c", Health: 100, Head: Point{X: 9, Y: 9}, Body: []Point{{X: 9, Y: 9}, {X: 9, Y: 8}}},
+		},
+	}
+}
+
+func TestPendingTailMoves(t *testing.T) {
+	for viewer := 0; viewer < 3; viewer++ {
+		for target := 0; target < 3; target++ {
+			expected := 1
+			if target < viewer {
+				expected = 0
+			}
+			assert.Equal(t, expected, pendingTailMoves(viewer, target), "viewer %d target %d", viewer, target)
+		}
+	}
+}
+
+func TestEffectiveBody(t *testing.T) {
+	board := turnOrderTestBoard()
+	full := func(i int) []Point { return board.Snakes[i].Body }
+	noTail := func(i int) []Point { return board.Snakes[i].Body[:len(board.Snakes[i].Body)-1] }
+
+	testCases := []struct {
+		Description string
+		Viewer      int
+		Target      int
+		Expected    []Point
+	}{
+		{"own tail is free", 0, 0, noTail(0)},
+		{"later snake tail is free", 0, 1, noTail(1)},
+		{"later snake tail is free even further on", 0, 2, noTail(2)},
+		{"earlier snake has already moved", 1, 0, full(0)},
+		{"own tail free in the middle of the order", 1, 1, noTail(1)},
+		{"later snake from the middle of the order", 1, 2, noTail(2)},
+		{"two earlier snakes from the last snake", 2, 0, full(0)},
+		{"earlier snake that just ate keeps its stacked tail", 2, 1, full(1)},
+		{"last snake's own tail", 2, 2, noTail(2)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, effectiveBody(&board, tc.Viewer, tc.Target))
+		})
+	}
+
+	t.Run("stacked tail square stays blocked", func(t *testing.T) {
+		assert.Contains(t, effectiveBody(&board, 0, 1), Point{X: 5, Y: 7})
+	})
+
+	t.Run("dead snakes block nothing", func(t *testing.T) {
+		dead := turnOrderTestBoard()
+		dead.Snakes[1].Health = 0
+		assert.Empty(t, effectiveBody(&dead, 0, 1))
+		assert.Empty(t, effectiveBody(&dead, 2, 1))
+	})
+
+	t.Run("length one snake that has yet to move blocks nothing", func(t *testing.T) {
+		short := turnOrderTestBoard()
+		short.Snakes[2].Body = short.Snakes[2].Body[:1]
+		assert.Empty(t, effectiveBody(&short, 0, 2))
+		assert.Equal(t, []Point{{X: 9, Y: 9}}, effectiveBody(&short, 2+1, 2))
+	})
+}
+
+func TestEffectiveBodyAfterMove(t *testing.T) {
+	board := turnOrderTestBoard()
+
+	// after snake 1 has moved, snake 0 and 1 are final and snake 2 is still to move
+	assert.Equal(t, board.Snakes[0].Body, effectiveBodyAfterMove(&board, 1, 0))
+	assert.Equal(t, board.Snakes[1].Body, effectiveBodyAfterMove(&board, 1, 1))
+	assert.Equal(t, board.Snakes[2].Body[:1], effectiveBodyAfterMove(&board, 1, 2))
+}
+
+func TestEffectiveBodyAfterSteps(t *testing.T) {
+	board := turnOrderTestBoard()
+
+	assert.Equal(t, board.Snakes[1].Body[:3], effectiveBodyAfterSteps(&board, 0, 1, 0))
+	assert.Equal(t, board.Snakes[1].Body[:2], effectiveBodyAfterSteps(&board, 0, 1, 1))
+	assert.Equal(t, board.Snakes[1].Body[:3], effectiveBodyAfterSteps(&board, 2, 1, 1))
+	assert.Empty(t, effectiveBodyAfterSteps(&board, 0, 1, 3))
+	assert.Empty(t, effectiveBodyAfterSteps(&board, 0, 0, 10))
+}
+
+func TestHitsBody(t *testing.T) {
+	board := turnOrderTestBoard()
+
+	// the head of a snake that already moved is a head-to-head, not a body hit
+	assert.False(t, hitsBody(&board, 1, Point{X: 1, Y: 1}))
+	assert.True(t, blocksPoint(&board, 1, Point{X: 1, Y: 1}))
+	// the head of a snake that hasn't moved yet becomes its neck
+	assert.True(t, hitsBody(&board, 0, Point{X: 5, Y: 5}))
+	// tails that are about to move are free
+	assert.False(t, hitsBody(&board, 0, Point{X: 9, Y: 8}))
+	assert.False(t, hitsBody(&board, 0, Point{X: 1, Y: 3}))
+	// tails of snakes that moved already are not
+	assert.True(t, hitsBody(&board, 2, Point{X: 1, Y: 3}))
+}
+
+func TestConventionsAgree(t *testing.T) {
+	board := turnOrderTestBoard()
+
+	// every cell voronoi considers reachable on the first step must also be one the simulator lets us survive in
+	for viewer := range board.Snakes {
+		for _, direction := range AllDirections {
+			next := moveHead(board.Snakes[viewer].Head, direction)
+			if !isPointInsideBoard(&board, next) {
+				continue
+			}
+			legal := isLegalMove(board, viewer, next, 0)
+			if legal {
+				assert.False(t, hitsBody(&board, viewer, next), "viewer %d direction %d", viewer, direction)
+			}
+		}
+	}
+
+	// moving snake 0 into snake 1's neck kills it
+	moved := turnOrderTestBoard()
+	moved.Snakes[0].Head = Point{X: 4, Y: 6}
+	moved.Snakes[0].Body = []Point{{X: 4, Y: 6}, {X: 4, Y: 5}, {X: 4, Y: 4}}
+	applyMove(&moved, 0, Right)
+	assert.True(t, isSnakeDead(moved.Snakes[0]))
+	assert.Contains(t, effectiveBody(&board, 0, 1), Point{X: 5, Y: 6})
+}
+
+func TestGenerateSafeMovesAvoidsBodies(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Index       int
+		Body        []Point
+		Expected    []Direction
+	}{
+		{"into a body", 2, []Point{{X: 6, Y: 6}, {X: 7, Y: 6}}, []Direction{Up, Down}},
+		{"onto the head of a snake that already moved is a head to head", 2, []Point{{X: 2, Y: 1}, {X: 3, Y: 1}}, []Direction{Up, Down, Left}},
+		{"the tail of a snake yet to move is free", 0, []Point{{X: 8, Y: 8}, {X: 7, Y: 8}, {X: 6, Y: 8}}, []Direction{Up, Down, Right}},
+		{"the tail of a snake that already moved isn't", 2, []Point{{X: 2, Y: 3}, {X: 3, Y: 3}}, []Direction{Up, Down}},
+		{"a snake that just ate keeps its tail", 0, []Point{{X: 4, Y: 7}, {X: 3, Y: 7}, {X: 2, Y: 7}}, []Direction{Up, Down}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			board := turnOrderTestBoard()
+			board.Snakes[tc.Index].Head = tc.Body[0]
+			board.Snakes[tc.Index].Body = tc.Body
+			assert.ElementsMatch(t, tc.Expected, generateSafeMoves(board, tc.Index))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// maxGameBytes is the biggest request body a game handler reads. A full 25x25 board with every cell
+	// taken is well under it.
+	maxGameBytes = 1 << 20
+	// maxBoardSide is the widest or tallest board we'll play on. The engine's biggest maps are 25 a side,
+	// and everything we allocate per cell grows with the area.
+	maxBoardSide = 64
+)
+
+// decodeGame reads the game from a BattleSnake request and checks it with validate, which is one of
+// BattleSnakeGame's Validate methods. Fields the API adds that we don't know about are ignored.
+func decodeGame(w http.ResponseWriter, r *http.Request, validate func(BattleSnakeGame) error) (BattleSnakeGame, error) {
+	var game BattleSnakeGame
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGameBytes)).Decode(&game); err != nil {
+		return BattleSnakeGame{}, fmt.Errorf("failed to decode game: %w", err)
+	}
+	if err := validate(game); err != nil {
+		return BattleSnakeGame{}, err
+	}
+	return game, nil
+}
+
+// Validate checks the game is well formed enough to not trip anything up: a board of a sane size with
+// everything on it, and snakes that each have a body and an id of their own. It doesn't need us to be on
+// the board, since /end comes after we're gone. ValidateMove checks that too.
+func (g BattleSnakeGame) Validate() error {
+	if g.Game.ID == "" {
+		return errors.New("game has no id")
+	}
+	if g.Turn < 0 {
+		return fmt.Errorf("turn %d is negative", g.Turn)
+	}
+	board := g.Board
+	if board.Width <= 0 || board.Height <= 0 || board.Width > maxBoardSide || board.Height > maxBoardSide {
+		return fmt.Errorf("board is %dx%d", board.Width, board.Height)
+	}
+	onBoard := func(p Point) bool {
+		return p.X >= 0 && p.Y >= 0 && p.X < board.Width && p.Y < board.Height
+	}
+	for _, food := range board.Food {
+		if !onBoard(food) {
+			return fmt.Errorf("food at %v is off the board", food)
+		}
+	}
+	for _, hazard := range board.Hazards {
+		if !onBoard(hazard) {
+			return fmt.Errorf("hazard at %v is off the board", hazard)
+		}
+	}
+	ids := make(map[string]bool, len(board.Snakes))
+	for _, snake := range board.Snakes {
+		if snake.ID == "" {
+			return errors.New("snake has no id")
+		}
+		if ids[snake.ID] {
+			return fmt.Errorf("snake %s is on the board twice", snake.ID)
+		}
+		ids[snake.ID] = true
+		if len(snake.Body) == 0 {
+			return fmt.Errorf("snake %s has no body", snake.ID)
+		}
+		// stacked segments at the start of a game still fit, anything longer can't
+		if len(snake.Body) > board.Width*board.Height+2 {
+			return fmt.Errorf("snake %s is longer than the board", snake.ID)
+		}
+		for _, part := range snake.Body {
+			if !onBoard(part) {
+				return fmt.Errorf("snake %s is off the board at %v", snake.ID, part)
+			}
+		}
+		if snake.Head != snake.Body[0] {
+			return fmt.Errorf("snake %s has its head at %v but its body starts at %v", snake.ID, snake.Head, snake.Body[0])
+		}
+	}
+	return nil
+}
+
+// ValidateMove is Validate plus us being on the board, which we have to be to move.
+func (g BattleSnakeGame) ValidateMove() error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	for _, snake := range g.Board.Snakes {
+		if snake.ID == g.You.ID {
+			return nil
+		}
+	}
+	return fmt.Errorf("you (%q) aren't on the board", g.You.ID)
+}
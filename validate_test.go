@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGame(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Break       func(*BattleSnakeGame)
+		Valid       bool
+		ValidMove   bool
+	}{
+		{"well formed", func(g *BattleSnakeGame) {}, true, true},
+		{"we've died", func(g *BattleSnakeGame) { g.Board.Snakes = g.Board.Snakes[1:] }, true, false},
+		{"nobody left", func(g *BattleSnakeGame) { g.Board.Snakes = nil }, true, false},
+		{"no you", func(g *BattleSnakeGame) { g.You = Snake{} }, true, false},
+		{"no game id", func(g *BattleSnakeGame) { g.Game.ID = "" }, false, false},
+		{"negative turn", func(g *BattleSnakeGame) { g.Turn = -1 }, false, false},
+		{"no board", func(g *BattleSnakeGame) { g.Board.Width, g.Board.Height = 0, 0 }, false, false},
+		{"huge board", func(g *BattleSnakeGame) { g.Board.Width = 1 << 20 }, false, false},
+		{"food off the board", func(g *BattleSnakeGame) { g.Board.Food = append(g.Board.Food, Point{X: -1}) }, false, false},
+		{"hazard off the board", func(g *BattleSnakeGame) { g.Board.Hazards = append(g.Board.Hazards, Point{Y: 99}) }, false, false},
+		{"snake without a body", func(g *BattleSnakeGame) { g.Board.Snakes[1].Body = nil }, false, false},
+		{"snake without an id", func(g *BattleSnakeGame) { g.Board.Snakes[1].ID = "" }, false, false},
+		{"snake twice", func(g *BattleSnakeGame) { g.Board.Snakes[1].ID = g.Board.Snakes[0].ID }, false, false},
+		{"head off its body", func(g *BattleSnakeGame) { g.Board.Snakes[1].Head.X++ }, false, false},
+		{"body off the board", func(g *BattleSnakeGame) {
+			g.Board.Snakes[1].Body = append(g.Board.Snakes[1].Body, Point{X: g.Board.Width})
+		}, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			game := sessionTestGame("validate")
+			tc.Break(&game)
+			assert.Equal(t, tc.Valid, game.Validate() == nil, "validate")
+			assert.Equal(t, tc.ValidMove, game.ValidateMove() == nil, "validate move")
+		})
+	}
+}
+
+func TestMalformedMoveIsBadRequest(t *testing.T) {
+	router := newRouter("")
+	for _, body := range []string{
+		`not json`,
+		`{"game":{"id":"g"},"board":{"width":11,"height":11,"snakes":[]},"you":{"id":"us"}}`,
+		`{"game":{"id":"g"},"board":{"width":11,"height":11,"snakes":[{"id":"them","head":{"x":1,"y":1},"body":[{"x":1,"y":1}]}]},"you":{"id":"us"}}`,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestDecodeGameIgnoresUnknownFields(t *testing.T) {
+	body := `{"game":{"id":"g","somethingNew":true},"turn":2,"board":{"width":7,"height":7,"snakes":[
+		{"id":"us","head":{"x":0,"y":0},"body":[{"x":0,"y":0}],"squad":"1"}],"extra":[]},"you":{"id":"us"}}`
+	game, err := decodeGame(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body)), BattleSnakeGame.ValidateMove)
+	require.NoError(t, err)
+	assert.Equal(t, "g", game.Game.ID)
+	assert.Equal(t, 2, game.Turn)
+}
+
+// FuzzDecodeGame checks nothing the engine could send gets past validation and then trips up the parts of
+// a move that run before the search.
+func FuzzDecodeGame(f *testing.F) {
+	game := sessionTestGame("fuzz")
+	seed, err := json.Marshal(game)
+	require.NoError(f, err)
+	f.Add(string(seed))
+	f.Add(`{"game":{"id":"g"},"board":{"width":1,"height":1,"snakes":[{"id":"us","head":{"x":0,"y":0},"body":[{"x":0,"y":0},{"x":0,"y":0}]}]},"you":{"id":"us"}}`)
+	f.Add(`{"game":{"id":"g"},"board":{"width":11,"height":11,"snakes":[]},"you":{}}`)
+	f.Add(`{"board":{"snakes":[{"body":[]}]}}`)
+	f.Add(`[]`)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		game, err := decodeGame(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body)), BattleSnakeGame.ValidateMove)
+		if err != nil {
+			return
+		}
+		board := reorderSnakes(game.Board.withRules(game.Game).atTurn(game.Turn), game.You.ID)
+		if board.Snakes[0].ID != game.You.ID {
+			t.Fatalf("we're not first after reordering: %q", board.Snakes[0].ID)
+		}
+		forcedMove(board)
+		complexTurn(board)
+		determineBestMove(NewNode(board, -1, nil))
+	})
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// valueNetDefaultWeight is the value network's module weight when it's blended in without one set
+const valueNetDefaultWeight = 8
+
+// The planes the board is encoded into for the value network, each a grid the network's size with the
+// board in the bottom left corner. Everything is from the root snake's point of view.
+const (
+	// the root snake, 1 at the head falling to 1/length at the tail, so the network can tell which way it's going
+	planeRootBody = iota
+	// every opponent, the same way
+	planeOpponentBodies
+	// the root snake's health out of 100, on every cell of the board
+	planeRootHealth
+	// each opponent's health out of 100, on its head
+	planeOpponentHealth
+	// 1 on food
+	planeFood
+	// the number of hazards stacked on the cell
+	planeHazards
+	// 1 on the board, 0 in the padding around a smaller board
+	planeOnBoard
+	valueNetPlanes
+)
+
+// valueNetLayer is a fully connected layer. Weights is by output then input.
+type valueNetLayer struct {
+	Weights    [][]float64 `json:"weights"`
+	Bias       []float64   `json:"bias"`
+	Activation string      `json:"activation"` // "relu", "tanh" or "" for none
+}
+
+// ValueNetwork is a learned evaluation: a stack of fully connected layers over the encoded board, whose
+// single output is squashed with tanh into a score from -1 to 1 for the root snake. It's trained offline
+// and saved as JSON, there's no runtime to load anything fancier into.
+type ValueNetwork struct {
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Layers []valueNetLayer `json:"layers"`
+}
+
+// loadValueNetwork reads a network saved as JSON and checks its layers fit together.
+func loadValueNetwork(path string) (*ValueNetwork, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	net := &ValueNetwork{}
+	if err := json.Unmarshal(data, net); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := net.validate(); err != nil {
+		return nil, fmt.Errorf("bad value network %s: %w", path, err)
+	}
+	return net, nil
+}
+
+// inputs is how many numbers the encoded board takes.
+func (n *ValueNetwork) inputs() int {
+	return valueNetPlanes * n.Width * n.Height
+}
+
+func (n *ValueNetwork) validate() error {
+	if n.Width <= 0 || n.Height <= 0 {
+		return errors.New("no board size")
+	}
+	if len(n.Layers) == 0 {
+		return errors.New("no layers")
+	}
+	inputs := n.inputs()
+	for i, layer := range n.Layers {
+		if len(layer.Weights) == 0 || len(layer.Weights) != len(layer.Bias) {
+			return fmt.Errorf("layer %d has %d outputs and %d biases", i, len(layer.Weights), len(layer.Bias))
+		}
+		for _, row := range layer.Weights {
+			if len(row) != inputs {
+				return fmt.Errorf("layer %d takes %d inputs, not %d", i, len(row), inputs)
+			}
+		}
+		switch layer.Activation {
+		case "", "relu", "tanh":
+		default:
+			return fmt.Errorf("layer %d has unknown activation %q", i, layer.Activation)
+		}
+		inputs = len(layer.Weights)
+	}
+	if inputs != 1 {
+		return fmt.Errorf("the last layer has %d outputs, not 1", inputs)
+	}
+	return nil
+}
+
+// fits says whether the board is small enough for the network to see all of it.
+func (n *ValueNetwork) fits(board Board) bool {
+	return board.Width <= n.Width && board.Height <= n.Height
+}
+
+// encode lays the board out in the network's planes from the context's root snake's point of view.
+func (n *ValueNetwork) encode(ec *EvaluationContext) []float64 {
+	return encodeBoardPlanes(ec, n.Width, n.Height)
+}
+
+// encodeBoardPlanes lays the board out in the valueNetPlanes grids of the width and height, from the
+// context's root snake's point of view. Anything off the grids is left out.
+func encodeBoardPlanes(ec *EvaluationContext, width, height int) []float64 {
+	input := make([]float64, valueNetPlanes*width*height)
+	cell := func(plane int, p Point) *float64 {
+		return &input[(plane*height+p.Y)*width+p.X]
+	}
+	board := &ec.Board
+	inside := func(p Point) bool {
+		return isPointInsideBoard(board, p) && p.X < width && p.Y < height
+	}
+
+	root := board.Snakes[ec.RootIndex]
+	for y := 0; y < min(board.Height, height); y++ {
+		for x := 0; x < min(board.Width, width); x++ {
+			*cell(planeOnBoard, Point{X: x, Y: y}) = 1
+			*cell(planeRootHealth, Point{X: x, Y: y}) = float64(root.Health) / 100
+		}
+	}
+	for i, snake := range board.Snakes {
+		if !ec.Alive[i] {
+			continue
+		}
+		plane := planeOpponentBodies
+		if i == ec.RootIndex {
+			plane = planeRootBody
+		}
+		// walk from the tail so a segment stacked on another keeps the value nearest the head
+		for j := len(snake.Body) - 1; j >= 0; j-- {
+			if inside(snake.Body[j]) {
+				*cell(plane, snake.Body[j]) = float64(len(snake.Body)-j) / float64(len(snake.Body))
+			}
+		}
+		if i != ec.RootIndex && inside(snake.Head) {
+			*cell(planeOpponentHealth, snake.Head) = float64(snake.Health) / 100
+		}
+	}
+	for _, food := range board.Food {
+		if inside(food) {
+			*cell(planeFood, food) = 1
+		}
+	}
+	for _, hazard := range board.Hazards {
+		if inside(hazard) {
+			*cell(planeHazards, hazard)++
+		}
+	}
+	return input
+}
+
+// forwardBatch runs every encoded board through the network at once, layer by layer, and returns their
+// scores in the same order.
+func (n *ValueNetwork) forwardBatch(inputs [][]float64) []float64 {
+	activations := inputs
+	for _, layer := range n.Layers {
+		next := make([][]float64, len(activations))
+		for b, input := range activations {
+			output := make([]float64, len(layer.Weights))
+			for o, row := range layer.Weights {
+				sum := layer.Bias[o]
+				for i, weight := range row {
+					sum += weight * input[i]
+				}
+				switch layer.Activation {
+				case "relu":
+					sum = math.Max(0, sum)
+				case "tanh":
+					sum = math.Tanh(sum)
+				}
+				output[o] = sum
+			}
+			next[b] = output
+		}
+		activations = next
+	}
+
+	scores := make([]float64, len(activations))
+	for b, output := range activations {
+		scores[b] = math.Tanh(output[0])
+	}
+	return scores
+}
+
+// valueRequest is a leaf waiting on the network.
+type valueRequest struct {
+	input  []float64
+	result chan float64
+}
+
+// valueBatcher gathers up the leaves the search's workers want scored at the same time and runs them
+// through the network together. It doesn't wait for a batch to fill: whatever has queued up while the
+// last batch ran goes in the next, so a lone worker is never held up and a busy search batches itself.
+type valueBatcher struct {
+	net      *ValueNetwork
+	size     int
+	requests chan valueRequest
+}
+
+func newValueBatcher(net *ValueNetwork, size int) *valueBatcher {
+	b := &valueBatcher{net: net, size: size, requests: make(chan valueRequest, size)}
+	go b.run()
+	return b
+}
+
+func (b *valueBatcher) run() {
+	for first := range b.requests {
+		batch := []valueRequest{first}
+	gather:
+		for len(batch) < b.size {
+			select {
+			case request := <-b.requests:
+				batch = append(batch, request)
+			default:
+				break gather
+			}
+		}
+
+		inputs := make([][]float64, len(batch))
+		for i, request := range batch {
+			inputs[i] = request.input
+		}
+		for i, score := range b.net.forwardBatch(inputs) {
+			batch[i].result <- score
+		}
+	}
+}
+
+// evaluate scores one board, in whichever batch it ends up in.
+func (b *valueBatcher) evaluate(input []float64) float64 {
+	result := make(chan float64, 1)
+	b.requests <- valueRequest{input: input, result: result}
+	return <-result
+}
+
+// valueModule is the network as an evaluation module. Leaves scored one at a time are batched with the
+// other workers' by the batcher, and callers with a batch of their own hand it straight over.
+func valueModule(net *ValueNetwork, weight float64, batchSize int) EvaluationModule {
+	batcher := newValueBatcher(net, batchSize)
+	return EvaluationModule{
+		Name: "value",
+		EvalFunc: func(ec *EvaluationContext) float64 {
+			return batcher.evaluate(net.encode(ec))
+		},
+		BatchFunc: func(ecs []*EvaluationContext) []float64 {
+			inputs := make([][]float64, len(ecs))
+			for i, ec := range ecs {
+				inputs[i] = net.encode(ec)
+			}
+			return net.forwardBatch(inputs)
+		},
+		Weight: weight,
+		Active: func(ec *EvaluationContext) bool {
+			return net.fits(ec.Board)
+		},
+	}
+}
+
+// applyValueNetFromEnv loads the network VALUE_NET points at, if it's set, and adds it to the modules
+// with VALUE_NET_WEIGHT. VALUE_NET_MODE=replace scores with the network alone on the boards it fits,
+// leaving the hand written modules for the boards too big for it.
+func applyValueNetFromEnv() error {
+	path := os.Getenv("VALUE_NET")
+	if path == "" {
+		return nil
+	}
+	net, err := loadValueNetwork(path)
+	if err != nil {
+		return err
+	}
+	weight := float64(valueNetDefaultWeight)
+	if value, err := strconv.ParseFloat(os.Getenv("VALUE_NET_WEIGHT"), 64); err == nil && value > 0 {
+		weight = value
+	}
+
+	replace := os.Getenv("VALUE_NET_MODE") == "replace"
+	combined := make([]EvaluationModule, 0, len(modules)+1)
+	for _, module := range modules {
+		if replace {
+			module.Active = unlessNetworkFits(net, module.Active)
+		}
+		combined = append(combined, module)
+	}
+	modules = append(combined, valueModule(net, weight, runtime.NumCPU()))
+	return nil
+}
+
+// unlessNetworkFits is the module's Active for boards too big for the network, and false for the rest.
+func unlessNetworkFits(net *ValueNetwork, active func(ec *EvaluationContext) bool) func(ec *EvaluationContext) bool {
+	return func(ec *EvaluationContext) bool {
+		return !net.fits(ec.Board) && (active == nil || active(ec))
+	}
+}
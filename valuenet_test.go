@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// foodCountingNetwork is a network whose score is tanh of the food on the board less the root's body
+// planes, so what it sees is easy to work out by hand.
+func foodCountingNetwork(width, height int) *ValueNetwork {
+	net := &ValueNetwork{Width: width, Height: height}
+	weights := make([]float64, net.inputs())
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			weights[(planeFood*height+y)*width+x] = 1
+			weights[(planeRootBody*height+y)*width+x] = -0.1
+		}
+	}
+	net.Layers = []valueNetLayer{
+		{Weights: [][]float64{weights}, Bias: []float64{0}, Activation: "relu"},
+		{Weights: [][]float64{{1}}, Bias: []float64{0}},
+	}
+	return net
+}
+
+func TestValueNetworkEncode(t *testing.T) {
+	net := &ValueNetwork{Width: 7, Height: 7}
+	board := Board{
+		Width: 5, Height: 5,
+		Food:    []Point{{X: 4, Y: 4}},
+		Hazards: []Point{{X: 0, Y: 4}, {X: 0, Y: 4}},
+		Snakes: []Snake{
+			{ID: "us", Health: 50, Head: Point{X: 1, Y: 1}, Body: []Point{{X: 1, Y: 1}, {X: 1, Y: 0}, {X: 0, Y: 0}, {X: 0, Y: 0}}},
+			{ID: "them", Health: 80, Head: Point{X: 3, Y: 3}, Body: []Point{{X: 3, Y: 3}, {X: 3, Y: 2}}},
+		},
+	}
+	input := net.encode(newEvaluationContext(board, 0))
+	at := func(plane, x, y int) float64 {
+		return input[(plane*net.Height+y)*net.Width+x]
+	}
+
+	assert.Equal(t, 1.0, at(planeRootBody, 1, 1), "head")
+	assert.Equal(t, 0.75, at(planeRootBody, 1, 0))
+	assert.Equal(t, 0.5, at(planeRootBody, 0, 0), "the stacked tail keeps the value nearer the head")
+	assert.Equal(t, 1.0, at(planeOpponentBodies, 3, 3))
+	assert.Equal(t, 0.5, at(planeOpponentBodies, 3, 2))
+	assert.Equal(t, 0.8, at(planeOpponentHealth, 3, 3))
+	assert.Equal(t, 0.5, at(planeRootHealth, 4, 4))
+	assert.Equal(t, 0.0, at(planeRootHealth, 5, 5), "nothing in the padding")
+	assert.Equal(t, 1.0, at(planeFood, 4, 4))
+	assert.Equal(t, 2.0, at(planeHazards, 0, 4), "stacked hazards count")
+	assert.Equal(t, 1.0, at(planeOnBoard, 4, 0))
+	assert.Equal(t, 0.0, at(planeOnBoard, 5, 0))
+
+	flipped := net.encode(newEvaluationContext(board, 1))
+	assert.Equal(t, 1.0, flipped[(planeRootBody*net.Height+3)*net.Width+3], "the other snake's point of view")
+}
+
+func TestValueNetworkForwardBatch(t *testing.T) {
+	net := foodCountingNetwork(5, 5)
+	require.NoError(t, net.validate())
+
+	board := evalTestBoard()
+	board.Snakes = board.Snakes[:2]
+	none := board
+	none.Food = nil
+	two := board
+	two.Food = []Point{{X: 0, Y: 4}, {X: 4, Y: 0}}
+
+	inputs := [][]float64{net.encode(newEvaluationContext(none, 0)), net.encode(newEvaluationContext(two, 0))}
+	scores := net.forwardBatch(inputs)
+	require.Len(t, scores, 2)
+	assert.Equal(t, 0.0, scores[0], "relu keeps the body's negative out")
+	// the root's body is 1, 0.75, 0.5 and 0.25 from head to tail
+	assert.InDelta(t, math.Tanh(2-0.1*2.5), scores[1], 1e-9)
+	assert.Equal(t, scores[1], net.forwardBatch(inputs[1:])[0], "batching doesn't change a score")
+}
+
+func TestLoadValueNetworkChecksShapes(t *testing.T) {
+	dir := t.TempDir()
+	save := func(net *ValueNetwork) string {
+		data, err := json.Marshal(net)
+		require.NoError(t, err)
+		path := filepath.Join(dir, "net.json")
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return path
+	}
+
+	loaded, err := loadValueNetwork(save(foodCountingNetwork(5, 5)))
+	require.NoError(t, err)
+	assert.Equal(t, foodCountingNetwork(5, 5), loaded)
+
+	wrongInputs := foodCountingNetwork(5, 5)
+	wrongInputs.Width = 6
+	_, err = loadValueNetwork(save(wrongInputs))
+	assert.Error(t, err)
+
+	twoOutputs := foodCountingNetwork(5, 5)
+	twoOutputs.Layers[1] = valueNetLayer{Weights: [][]float64{{1}, {1}}, Bias: []float64{0, 0}}
+	_, err = loadValueNetwork(save(twoOutputs))
+	assert.Error(t, err)
+
+	badActivation := foodCountingNetwork(5, 5)
+	badActivation.Layers[0].Activation = "sigmoid"
+	_, err = loadValueNetwork(save(badActivation))
+	assert.Error(t, err)
+}
+
+func TestEvaluateContextsMatchesOneAtATime(t *testing.T) {
+	board := evalTestBoard()
+	dead := evalTestBoard()
+	dead.Snakes[0].Health = 0
+	dead.Snakes[0].Body = nil
+
+	blended := append(append([]EvaluationModule(nil), modules...), valueModule(foodCountingNetwork(11, 11), 8, 4))
+	for _, b := range []Board{board, dead} {
+		ec := newEvaluationContext(b, 0)
+		var views []*EvaluationContext
+		for i := range b.Snakes {
+			views = append(views, ec.forSnake(i))
+		}
+		batched := evaluateContexts(views, blended)
+		for i, view := range views {
+			assert.InDelta(t, evaluateContext(view, blended), batched[i], 1e-9, "snake %d", i)
+		}
+		assert.Equal(t, batched, evaluateScores(b, blended))
+	}
+}
+
+func TestValueModuleSkipsBoardsTooBig(t *testing.T) {
+	module := valueModule(foodCountingNetwork(7, 7), 8, 4)
+	small := newEvaluationContext(Board{Width: 7, Height: 7}, 0)
+	big := newEvaluationContext(Board{Width: 11, Height: 11}, 0)
+	assert.True(t, module.Active(small))
+	assert.False(t, module.Active(big))
+
+	replaced := unlessNetworkFits(foodCountingNetwork(7, 7), nil)
+	assert.False(t, replaced(small), "the hand written modules step aside where the network fits")
+	assert.True(t, replaced(big))
+}
+
+func TestValueBatcherUnderLoad(t *testing.T) {
+	net := foodCountingNetwork(11, 11)
+	batcher := newValueBatcher(net, 8)
+	board := evalTestBoard()
+	input := net.encode(newEvaluationContext(board, 0))
+	expected := net.forwardBatch([][]float64{input})[0]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, expected, batcher.evaluate(input))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// set at build time with -ldflags "-X main.version=... -X main.commit=...". the docker build can't see .git,
+// so the Dockerfile takes them as build args.
+var (
+	version = "0.1.0"
+	commit  = ""
+)
+
+// BuildInfo identifies the engine build, so every game and blunder we record can be traced back to
+// the code that played it.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Dirty   bool   `json:"dirty,omitempty"` // built from a tree with uncommitted changes
+}
+
+// engineBuild is the build that's running.
+var engineBuild = readBuildInfo()
+
+// readBuildInfo uses the stamped commit, falling back to what go records about the checkout when
+// we're built straight from the repo.
+func readBuildInfo() BuildInfo {
+	build := BuildInfo{Version: version, Commit: commit}
+	if build.Commit != "" {
+		return build
+	}
+	build.Commit = "unknown"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Commit = setting.Value
+		case "vcs.modified":
+			build.Dirty = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// String is the version with the short commit, like 0.1.0+1a2b3c4.
+func (b BuildInfo) String() string {
+	commit := b.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	s := fmt.Sprintf("%s+%s", b.Version, commit)
+	if b.Dirty {
+		s += "-dirty"
+	}
+	return s
+}
+
+// Metadata is the build as object metadata for the files we keep in the bucket.
+func (b BuildInfo) Metadata() map[string]string {
+	return map[string]string{
+		"engine-version": b.Version,
+		"engine-commit":  b.Commit,
+		"engine-build":   b.String(),
+	}
+}
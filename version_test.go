@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	testCases := []struct {
+		Description string
+		Build       BuildInfo
+		Want        string
+	}{
+		{Description: "full commit shortened", Build: BuildInfo{Version: "0.1.0", Commit: "1a2b3c4d5e6f"}, Want: "0.1.0+1a2b3c4"},
+		{Description: "short commit kept", Build: BuildInfo{Version: "0.2.0", Commit: "abc"}, Want: "0.2.0+abc"},
+		{Description: "uncommitted changes", Build: BuildInfo{Version: "0.1.0", Commit: "1a2b3c4d5e6f", Dirty: true}, Want: "0.1.0+1a2b3c4-dirty"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, tc.Want, tc.Build.String())
+			assert.Equal(t, tc.Want, tc.Build.Metadata()["engine-build"])
+		})
+	}
+}
+
+func TestReadBuildInfoPrefersStampedCommit(t *testing.T) {
+	saved := commit
+	t.Cleanup(func() { commit = saved })
+
+	commit = "deadbeefcafe"
+	assert.Equal(t, BuildInfo{Version: version, Commit: "deadbeefcafe"}, readBuildInfo())
+
+	commit = ""
+	assert.NotEmpty(t, readBuildInfo().Commit, "falls back to the checkout, or says it doesn't know")
+}
+
+func TestIndexReportsBuild(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, engineBuild.String(), response["version"])
+}
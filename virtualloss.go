@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// defaultVirtualLoss is what a worker in flight costs a node. A full loss is -2, so this is enough to
+// send the next worker down a close second without ruling the node out.
+const defaultVirtualLoss = 1.0
+
+// virtualLossFromEnv reads VIRTUAL_LOSS. 0 turns it off and anything unreadable gets the default.
+func virtualLossFromEnv() float64 {
+	value := os.Getenv("VIRTUAL_LOSS")
+	if value == "" {
+		return defaultVirtualLoss
+	}
+	loss, err := strconv.ParseFloat(value, 64)
+	if err != nil || loss < 0 {
+		return defaultVirtualLoss
+	}
+	return loss
+}
+
+// addVirtualLoss marks a worker as on its way through the node.
+func (c SearchConfig) addVirtualLoss(node *Node) {
+	if c.VirtualLoss > 0 {
+		atomic.AddInt32(&node.inFlight, 1)
+	}
+}
+
+// releaseVirtualLoss takes the worker's mark off every node between the one it reached and the root of
+// the tree it was searching, which never gets one.
+func (c SearchConfig) releaseVirtualLoss(node, root *Node) {
+	if c.VirtualLoss <= 0 {
+		return
+	}
+	for n := node; n != nil && n != root; n = n.Parent {
+		atomic.AddInt32(&n.inFlight, -1)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualLossFromEnv(t *testing.T) {
+	t.Setenv("VIRTUAL_LOSS", "")
+	assert.Equal(t, defaultVirtualLoss, virtualLossFromEnv())
+	t.Setenv("VIRTUAL_LOSS", "0.5")
+	assert.Equal(t, 0.5, virtualLossFromEnv())
+	t.Setenv("VIRTUAL_LOSS", "0")
+	assert.Equal(t, 0.0, virtualLossFromEnv())
+	t.Setenv("VIRTUAL_LOSS", "-1")
+	assert.Equal(t, defaultVirtualLoss, virtualLossFromEnv())
+	t.Setenv("VIRTUAL_LOSS", "lots")
+	assert.Equal(t, defaultVirtualLoss, virtualLossFromEnv())
+}
+
+func TestUCTWithVirtualLoss(t *testing.T) {
+	parent := &Node{Visits: 20}
+	child := &Node{Parent: parent, Visits: 10, Score: 5}
+	assert.Equal(t, child.UCT(1.41), child.uctWithLoss(1.41, 1), "nothing in flight changes nothing")
+
+	child.inFlight = 2
+	parent.inFlight = 2
+	want := (5.0-2)/12 + 1.41*math.Sqrt(math.Log(22)/12)
+	assert.InDelta(t, want, child.uctWithLoss(1.41, 1), 1e-9)
+	assert.Equal(t, child.UCT(1.41), child.uctWithLoss(1.41, 0), "switched off it's plain UCT")
+
+	// a child someone else is expanding isn't the sure thing an unvisited one is
+	fresh := &Node{Parent: parent}
+	assert.Equal(t, math.MaxFloat64, fresh.uctWithLoss(1.41, 1))
+	fresh.inFlight = 1
+	assert.Less(t, fresh.uctWithLoss(1.41, 1), math.MaxFloat64)
+}
+
+func TestVirtualLossSpreadsWorkers(t *testing.T) {
+	// an even root, every move searched as much as the others
+	root := NewNode(evalTestBoard(), -1, nil)
+	for move, ok := root.claimMove(); ok; move, ok = root.claimMove() {
+		board := copyBoard(root.Board)
+		applyMove(&board, 0, move)
+		child := NewNode(board, 0, root)
+		child.Visits = 10
+		root.addChild(child)
+	}
+	root.Visits = int64(10 * len(root.Children()))
+	require.Greater(t, len(root.Children()), 1)
+
+	firstMoves := func(config SearchConfig) map[*Node]bool {
+		seen := make(map[*Node]bool)
+		for range root.Children() {
+			node := selectNode(context.Background(), root, nil, config)
+			for node.Parent != root {
+				node = node.Parent
+			}
+			seen[node] = true
+		}
+		return seen
+	}
+
+	// workers that haven't come back yet all go the same way without it
+	assert.Len(t, firstMoves(SearchConfig{}), 1)
+	assert.Len(t, firstMoves(SearchConfig{VirtualLoss: 1}), len(root.Children()), "each worker takes a different move")
+}
+
+func TestVirtualLossReleased(t *testing.T) {
+	// cut off by the deadline partway through, with workers all over the tree
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	root := MCTS(ctx, "test", evalTestBoard(), math.MaxInt, 4, make(map[string]*Node), WithSearchConfig(SearchConfig{VirtualLoss: 1}))
+	require.Positive(t, root.Visits)
+
+	var check func(node *Node)
+	check = func(node *Node) {
+		assert.Zero(t, node.inFlight, "virtual loss left on a node")
+		for _, child := range node.Children() {
+			check(child)
+		}
+	}
+	check(root)
+
+	parallel := MCTS(context.Background(), "test", evalTestBoard(), 2000, 4, make(map[string]*Node), WithSearchConfig(SearchConfig{VirtualLoss: 1, Parallel: ParallelRoot}))
+	check(parallel)
+}
@@ -17,6 +17,7 @@ import ReactFlow, {
   NodeChange,
 } from "reactflow"
 import "reactflow/dist/style.css"
+import Playground from "./Playground"
 
 interface TreeNode {
   id: string
@@ -27,6 +28,7 @@ interface TreeNode {
   isMostVisited: boolean
   children?: TreeNode[]
   board: Board
+  eval?: Record<string, number>
 }
 
 interface TreeFile {
@@ -57,6 +59,23 @@ interface Snake {
   shout: string
 }
 
+// EvalBreakdown shows each evaluation module's score behind the node, when the tree was exported with it
+const EvalBreakdown: React.FC<{ node: TreeNode }> = ({ node }) => {
+  if (!node.eval) return null
+  return (
+    <table style={{ fontFamily: "Courier New", margin: "0 auto" }}>
+      <tbody>
+        {Object.entries(node.eval).map(([module, score]) => (
+          <tr key={module}>
+            <td style={{ textAlign: "left" }}>{module}</td>
+            <td style={{ textAlign: "right" }}>{score.toFixed(3)}</td>
+          </tr>
+        ))}
+      </tbody>
+    </table>
+  )
+}
+
 const boxWidthDefault = 300
 const boxHeightDefault = 800
 
@@ -164,6 +183,7 @@ const TreeViewer: React.FC = () => {
               <pre style={{ fontFamily: "Courier New" }}>
                 {currentNode.body}
               </pre>
+              <EvalBreakdown node={currentNode} />
               <button
                 onClick={(e) => {
                   e.stopPropagation()
@@ -187,6 +207,7 @@ const TreeViewer: React.FC = () => {
               label: (
                 <div>
                   <pre style={{ fontFamily: "Courier New" }}>{child.body}</pre>
+                  <EvalBreakdown node={child} />
                   <button
                     onClick={(e) => {
                       e.stopPropagation()
@@ -256,6 +277,7 @@ const TreeViewer: React.FC = () => {
           label: (
             <div>
               <pre style={{ fontFamily: "Courier New" }}>{child.body}</pre>
+              <EvalBreakdown node={child} />
               <button
                 onClick={(e) => {
                   e.stopPropagation()
@@ -409,6 +431,7 @@ const App: React.FC = () => {
           />
 
           <Route path="/trees/:id" element={<TreeViewer />} />
+          <Route path="/playground" element={<Playground />} />
         </Routes>
       </div>
     </Router>
@@ -588,6 +611,20 @@ const Sidebar: React.FC = () => {
         </button>
       </form>
       <BoardDisplay board={board} />
+      <button
+        onClick={() => navigate("/playground")}
+        style={{
+          width: "100%",
+          padding: "0.5rem",
+          marginBottom: "1rem",
+          backgroundColor: "#007BFF",
+          color: "#fff",
+          border: "none",
+          borderRadius: "4px",
+        }}
+      >
+        Playground
+      </button>
       <h3 style={{ margin: 0, color: "#fff" }}>Available Trees</h3>
       <button
         onClick={fetchTrees}
@@ -0,0 +1,88 @@
+import React, { useState } from "react"
+import { analyseBoard, PlaygroundResult } from "./engine"
+
+// Playground runs what-if searches on a pasted board entirely in the browser.
+const Playground: React.FC = () => {
+  const [boardJSON, setBoardJSON] = useState("")
+  const [iterations, setIterations] = useState(20000)
+  const [timeoutMs, setTimeoutMs] = useState(2000)
+  const [result, setResult] = useState<PlaygroundResult | null>(null)
+  const [error, setError] = useState("")
+  const [running, setRunning] = useState(false)
+
+  const run = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setRunning(true)
+    setError("")
+    try {
+      setResult(await analyseBoard(boardJSON, iterations, timeoutMs))
+    } catch (err) {
+      setError(String(err))
+    } finally {
+      setRunning(false)
+    }
+  }
+
+  return (
+    <div style={{ padding: "1rem", width: "80%", overflowY: "scroll" }}>
+      <form onSubmit={run}>
+        <label>Board JSON (our snake first):</label>
+        <textarea
+          value={boardJSON}
+          onChange={(e) => setBoardJSON(e.target.value)}
+          style={{ width: "100%", height: "8rem", marginBottom: "1rem" }}
+        />
+        <label>Iterations:</label>
+        <input
+          type="number"
+          value={iterations}
+          onChange={(e) => setIterations(Number(e.target.value))}
+          style={{ marginRight: "1rem" }}
+        />
+        <label>Timeout (ms):</label>
+        <input
+          type="number"
+          value={timeoutMs}
+          onChange={(e) => setTimeoutMs(Number(e.target.value))}
+          style={{ marginRight: "1rem" }}
+        />
+        <button type="submit" disabled={running}>
+          {running ? "Searching..." : "Search"}
+        </button>
+      </form>
+      {error && <p style={{ color: "red" }}>{error}</p>}
+      {result && (
+        <div style={{ display: "flex", gap: "2rem", marginTop: "1rem" }}>
+          <div>
+            <h3>
+              Best move: {result.move} ({result.visits} visits)
+            </h3>
+            <table>
+              <thead>
+                <tr>
+                  <th>move</th>
+                  <th>visits</th>
+                  <th>avg score</th>
+                </tr>
+              </thead>
+              <tbody>
+                {result.moves.map((move) => (
+                  <tr key={move.move}>
+                    <td>{move.move}</td>
+                    <td>{move.visits}</td>
+                    <td>{move.avg_score.toFixed(3)}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+            <p>PV: {result.pv.join(", ")}</p>
+          </div>
+          <pre>{result.board}</pre>
+          <pre>{result.voronoi}</pre>
+        </div>
+      )}
+    </div>
+  )
+}
+
+export default Playground
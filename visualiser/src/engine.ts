@@ -0,0 +1,80 @@
+// Loads the Go engine compiled to WebAssembly so searches can run in the browser.
+// Build it with `npm run build-wasm`, which also copies Go's wasm_exec.js into public/.
+
+export interface PlaygroundMove {
+  move: string
+  visits: number
+  avg_score: number
+}
+
+export interface PlaygroundResult {
+  move: string
+  visits: number
+  moves: PlaygroundMove[]
+  pv: string[]
+  board: string
+  voronoi: string
+}
+
+type AnalyseFn = (
+  boardJSON: string,
+  iterations: number,
+  timeoutMs: number,
+  callback: (err: string | null, result: string | null) => void,
+) => void
+
+declare global {
+  interface Window {
+    Go: any
+    aisnakeAnalyse?: AnalyseFn
+  }
+}
+
+let loading: Promise<void> | null = null
+
+const loadScript = (src: string) =>
+  new Promise<void>((resolve, reject) => {
+    const script = document.createElement("script")
+    script.src = src
+    script.onload = () => resolve()
+    script.onerror = () => reject(new Error(`failed to load ${src}`))
+    document.head.appendChild(script)
+  })
+
+export const loadEngine = (): Promise<void> => {
+  if (loading) {
+    return loading
+  }
+  loading = (async () => {
+    await loadScript("/wasm_exec.js")
+    const go = new window.Go()
+    const result = await WebAssembly.instantiateStreaming(
+      fetch("/aisnake.wasm"),
+      go.importObject,
+    )
+    // run never resolves since the engine blocks to keep its callbacks alive
+    go.run(result.instance)
+  })()
+  return loading
+}
+
+export const analyseBoard = async (
+  boardJSON: string,
+  iterations: number,
+  timeoutMs: number,
+): Promise<PlaygroundResult> => {
+  await loadEngine()
+  return new Promise((resolve, reject) => {
+    if (!window.aisnakeAnalyse) {
+      reject(new Error("engine did not register aisnakeAnalyse"))
+      return
+    }
+    window.aisnakeAnalyse(boardJSON, iterations, timeoutMs, (err, result) => {
+      if (err || !result) {
+        reject(new Error(err ?? "no result"))
+        return
+      }
+      resolve(JSON.parse(result))
+    })
+  })
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -51,7 +54,7 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 		default:
 			arrow = ' ' // Handle unexpected cases
 		}
-		sb.WriteRune(snakeChar)
+		sb.WriteString(opts.paint(snakeChar, cellStyle{snake: opts.snakeIndex, head: true}))
 		sb.WriteRune(arrow)
 		sb.WriteString(opts.newlineCharacter)
 	}
@@ -60,13 +63,23 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 	extendedHeight := game.Height + 2
 	extendedWidth := game.Width + 2
 
-	// Create a 2D slice to represent the extended board
+	// Walls are 'x', a wrapped board's edges lead round to the other side so they're '~'
+	edge := 'x'
+	if game.Wrapped {
+		edge = '~'
+	}
+
+	// Create a 2D slice to represent the extended board, with how each cell gets coloured alongside it
 	board := make([][]rune, extendedHeight)
+	styles := make([][]cellStyle, extendedHeight)
 	for i := range board {
 		board[i] = make([]rune, extendedWidth)
+		styles[i] = make([]cellStyle, extendedWidth)
 		for j := range board[i] {
+			styles[i][j].snake = -1
 			if i == 0 || i == extendedHeight-1 || j == 0 || j == extendedWidth-1 {
-				board[i][j] = 'x' // Set the boundary to 'x'
+				board[i][j] = edge
+				styles[i][j].edge = true
 			} else {
 				board[i][j] = '.' // Initialize all positions as empty
 			}
@@ -86,6 +99,7 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 		adjustedY := adjustY(food.Y)
 		if adjustedY != -1 && food.X+1 < extendedWidth {
 			board[adjustedY][food.X+1] = '♥'
+			styles[adjustedY][food.X+1].food = true
 		}
 	}
 
@@ -93,7 +107,13 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 	for _, hazard := range game.Hazards {
 		adjustedY := adjustY(hazard.Y)
 		if adjustedY != -1 && hazard.X+1 < extendedWidth {
-			board[adjustedY][hazard.X+1] = 'H'
+			// in colour the hazard is the cell's background, so whatever's in it still shows
+			styles[adjustedY][hazard.X+1].hazard = true
+			if !opts.ansi {
+				board[adjustedY][hazard.X+1] = 'H'
+			} else if board[adjustedY][hazard.X+1] == '.' {
+				board[adjustedY][hazard.X+1] = '░'
+			}
 		}
 	}
 
@@ -110,21 +130,26 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 		headY := adjustY(snake.Head.Y)
 		if headY != -1 && snake.Head.X+1 < extendedWidth {
 			board[headY][snake.Head.X+1] = unicode.ToUpper(snakeChar)
+			styles[headY][snake.Head.X+1].snake = i
+			styles[headY][snake.Head.X+1].head = true
 		}
 		for _, part := range snake.Body[1:] {
 			partY := adjustY(part.Y)
 			if partY != -1 && part.X+1 < extendedWidth {
 				board[partY][part.X+1] = snakeChar
+				styles[partY][part.X+1].snake = i
+				styles[partY][part.X+1].head = false
 			}
 		}
 	}
 
 	// Overlay the arrow for the current snake's move safely
 	if opts.move != Unset && opts.snakeIndex != -1 && arrow != ' ' {
-		newHead := moveHead(game.Snakes[opts.snakeIndex].Head, opts.move)
+		newHead := moveOnBoard(&game, game.Snakes[opts.snakeIndex].Head, opts.move)
 		adjustedY := adjustY(newHead.Y)
 		if adjustedY != -1 && newHead.X+1 < extendedWidth {
 			board[adjustedY][newHead.X+1] = arrow
+			styles[adjustedY][newHead.X+1].arrow = true
 		}
 	}
 
@@ -136,11 +161,15 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 	// sb.WriteString(opts.newlineCharacter)
 
 	// Build the string representation of the board using manual spacing for alignment
-	for _, row := range board {
+	spacing := "  " // Add extra spacing to simulate a table
+	if opts.compact {
+		spacing = " "
+	}
+	for i, row := range board {
 		sb.WriteString(opts.indent)
-		for _, cell := range row {
-			sb.WriteRune(cell)
-			sb.WriteString("  ") // Add extra spacing to simulate a table
+		for j, cell := range row {
+			sb.WriteString(opts.paint(cell, styles[i][j]))
+			sb.WriteString(spacing)
 		}
 		sb.WriteString(opts.newlineCharacter)
 	}
@@ -148,12 +177,63 @@ func visualizeBoard(game Board, options ...func(*boardOptions)) string {
 	return sb.String()
 }
 
+// cellStyle is what's in a cell of a visualised board, for colouring it in
+type cellStyle struct {
+	snake  int // -1 for none
+	head   bool
+	food   bool
+	hazard bool
+	edge   bool
+	arrow  bool
+}
+
+const (
+	ansiReset            = "\x1b[0m"
+	ansiBold             = "\x1b[1m"
+	ansiDim              = "\x1b[2m"
+	ansiFood             = "\x1b[91m"
+	ansiHazardBackground = "\x1b[48;5;238m"
+)
+
+// ansiSnakeColours are the snakes' colours by index, bright ones first so they stand out from the food
+var ansiSnakeColours = []string{"\x1b[94m", "\x1b[93m", "\x1b[95m", "\x1b[96m", "\x1b[92m", "\x1b[97m", "\x1b[34m", "\x1b[33m"}
+
+// paint is the cell's character, wrapped in the escape codes for its colour when the board's in colour
+func (o *boardOptions) paint(cell rune, style cellStyle) string {
+	if !o.ansi {
+		return string(cell)
+	}
+	var codes string
+	switch {
+	case style.arrow:
+		codes = ansiBold
+	case style.snake >= 0:
+		codes = ansiSnakeColours[style.snake%len(ansiSnakeColours)]
+		if style.head {
+			codes += ansiBold
+		}
+	case style.food:
+		codes = ansiFood
+	case style.edge, cell == '.':
+		codes = ansiDim
+	}
+	if style.hazard {
+		codes += ansiHazardBackground
+	}
+	if codes == "" {
+		return string(cell)
+	}
+	return codes + string(cell) + ansiReset
+}
+
 // Options struct to hold the customizable parameters
 type boardOptions struct {
 	indent           string
 	newlineCharacter string
 	move             Direction // Represents the move of a single snake
 	snakeIndex       int       // The index of the snake whose move is being visualized
+	ansi             bool      // colour the board in for a terminal
+	compact          bool      // one space between cells instead of two
 }
 
 // Option functions to set optional parameters
@@ -169,6 +249,36 @@ func WithNewlineCharacter(newlineCharacter string) func(*boardOptions) {
 	}
 }
 
+// WithANSI colours the board in with terminal escape codes: each snake its own colour, the food red and
+// hazard shaded behind whatever's in it.
+func WithANSI() func(*boardOptions) {
+	return func(o *boardOptions) {
+		o.ansi = true
+	}
+}
+
+// WithCompact halves the spacing between cells so big boards fit in a terminal.
+func WithCompact() func(*boardOptions) {
+	return func(o *boardOptions) {
+		o.compact = true
+	}
+}
+
+// terminalBoardOptions colours boards printed straight to a terminal, or anywhere when BOARD_COLOR is
+// ansi, which is how to get coloured boards out of go test. NO_COLOR turns it off.
+func terminalBoardOptions() []func(*boardOptions) {
+	if os.Getenv("NO_COLOR") != "" {
+		return nil
+	}
+	if os.Getenv("BOARD_COLOR") == "ansi" {
+		return []func(*boardOptions){WithANSI()}
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return []func(*boardOptions){WithANSI()}
+	}
+	return nil
+}
+
 func WithMove(move Direction, snakeIndex int) func(*boardOptions) {
 	return func(o *boardOptions) {
 		o.move = move
@@ -258,11 +368,12 @@ type TreeNode struct {
 	Children      []*TreeNode `json:"children"`
 	Body          string      `json:"body"`
 	Board         Board       `json:"board"`
+	// Eval is each module's unweighted score behind the node's own score, when the export was asked for it
+	Eval map[string]float64 `json:"eval,omitempty"`
 }
 
 func GenerateMostVisitedPathWithAlternativesHtmlTree(node *Node) error {
 
-	treeNode := generateTreeData(node)
 	timestamp := time.Now().Format("20060102_150405.000000")
 	uuid := uuid.New().String()
 	fileName := fmt.Sprintf("%s_%s", timestamp, uuid)
@@ -277,7 +388,7 @@ func GenerateMostVisitedPathWithAlternativesHtmlTree(node *Node) error {
 	defer file.Close()
 
 	// Generate the tree data in JSON format
-	err = json.NewEncoder(file).Encode(treeNode)
+	err = writeTreeData(file, node)
 	if err != nil {
 		return err
 	}
@@ -286,54 +397,169 @@ func GenerateMostVisitedPathWithAlternativesHtmlTree(node *Node) error {
 	return nil
 }
 
-// generateTreeData recursively generates the tree structure in JSON format
-func generateTreeData(node *Node) *TreeNode {
-	if node == nil {
+// treeOptions bounds how much of the tree generateTreeData and writeTreeData export
+type treeOptions struct {
+	maxDepth    int   // 0 for the whole tree
+	topChildren int   // 0 for every child
+	minVisits   int64 // children with fewer visits are left out
+	modules     []EvaluationModule
+}
+
+// WithMaxDepth stops the export that many moves below the root.
+func WithMaxDepth(depth int) func(*treeOptions) {
+	return func(o *treeOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// WithTopChildren only exports each node's k most visited children.
+func WithTopChildren(k int) func(*treeOptions) {
+	return func(o *treeOptions) {
+		o.topChildren = k
+	}
+}
+
+// WithMinVisits leaves out children the search visited fewer times than this.
+func WithMinVisits(visits int64) func(*treeOptions) {
+	return func(o *treeOptions) {
+		o.minVisits = visits
+	}
+}
+
+// WithEvalBreakdown scores every exported node with the modules the search used, so the visualiser can
+// show why it scored what it did. It's worked out again rather than kept on every node of the search.
+func WithEvalBreakdown(modules []EvaluationModule) func(*treeOptions) {
+	return func(o *treeOptions) {
+		o.modules = modules
+	}
+}
+
+func newTreeOptions(options []func(*treeOptions)) *treeOptions {
+	opts := &treeOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+	return opts
+}
+
+// treeChildren is the children of a node depth moves below the root that make it into the export, most
+// visited first.
+func treeChildren(node *Node, depth int, opts *treeOptions) []*Node {
+	if opts.maxDepth > 0 && depth > opts.maxDepth {
 		return nil
 	}
+	var children []*Node
+	for _, child := range node.Children() {
+		if atomic.LoadInt64(&child.Visits) >= opts.minVisits {
+			children = append(children, child)
+		}
+	}
+	// Sort children by visit count, descending
+	sort.SliceStable(children, func(i, j int) bool {
+		return atomic.LoadInt64(&children[i].Visits) > atomic.LoadInt64(&children[j].Visits)
+	})
+	if opts.topChildren > 0 && len(children) > opts.topChildren {
+		children = children[:opts.topChildren]
+	}
+	return children
+}
 
-	rootNode := &TreeNode{
+// treeNodeFor is the node without its children. The root has no UCB.
+func treeNodeFor(node *Node, root bool, mostVisited bool, opts *treeOptions) *TreeNode {
+	visits := atomic.LoadInt64(&node.Visits)
+	treeNode := &TreeNode{
 		ID:            fmt.Sprintf("Node_%p", node),
-		Visits:        node.Visits,
-		UCB:           0.0, // Root has no UCB
-		IsMostVisited: true,
+		Visits:        visits,
+		IsMostVisited: mostVisited,
 		Children:      make([]*TreeNode, 0),
 		Body:          visualizeNode(node),
 		Board:         node.Board,
 	}
+	if visits > 0 {
+		treeNode.AverageScore = loadFloat64(&node.Score) / float64(visits)
+	}
+	if !root {
+		treeNode.UCB = node.UCT(1.41)
+	}
+	// the node's score is for SnakeIndex, so the breakdown is too
+	if opts.modules != nil {
+		treeNode.Eval = evaluationBreakdown(node.Board, node.SnakeIndex, opts.modules)
+	}
+	return treeNode
+}
 
+// generateTreeData recursively generates the tree structure in JSON format
+func generateTreeData(node *Node, options ...func(*treeOptions)) *TreeNode {
+	if node == nil {
+		return nil
+	}
+	opts := newTreeOptions(options)
+	rootNode := treeNodeFor(node, true, true, opts)
 	// Traverse children
-	traverseAndBuildTree(node, rootNode)
+	traverseAndBuildTree(node, rootNode, 1, opts)
 	return rootNode
 }
 
 // traverseAndBuildTree populates the TreeNode structure with children and marks the most visited path
-func traverseAndBuildTree(node *Node, treeNode *TreeNode) {
+func traverseAndBuildTree(node *Node, treeNode *TreeNode, depth int, opts *treeOptions) {
+	for i, child := range treeChildren(node, depth, opts) {
+		// Only mark the most visited path
+		childNode := treeNodeFor(child, false, i == 0, opts)
+		treeNode.Children = append(treeNode.Children, childNode)
+		traverseAndBuildTree(child, childNode, depth+1, opts)
+	}
+}
+
+// writeTreeData writes the same json as generateTreeData a node at a time, so exporting a big tree
+// doesn't need a copy of it in memory first.
+func writeTreeData(w io.Writer, node *Node, options ...func(*treeOptions)) error {
 	if node == nil {
-		return
+		_, err := io.WriteString(w, "null\n")
+		return err
 	}
+	buffered := bufio.NewWriter(w)
+	if err := writeTreeNode(buffered, node, true, true, 1, newTreeOptions(options)); err != nil {
+		return err
+	}
+	if err := buffered.WriteByte('\n'); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
 
-	// Sort children by visit count, descending
-	sort.Slice(node.Children, func(i, j int) bool {
-		return node.Children[i].Visits > node.Children[j].Visits
-	})
+func writeTreeNode(w *bufio.Writer, node *Node, root bool, mostVisited bool, depth int, opts *treeOptions) error {
+	treeNode := treeNodeFor(node, root, mostVisited, opts)
+	// the fields either side of the children are small enough to encode whole, in TreeNode's order
+	before, err := json.Marshal(struct {
+		ID            string  `json:"id"`
+		Visits        int64   `json:"visits"`
+		AverageScore  float64 `json:"avg_score"`
+		UCB           float64 `json:"ucb"`
+		IsMostVisited bool    `json:"isMostVisited"`
+	}{treeNode.ID, treeNode.Visits, treeNode.AverageScore, treeNode.UCB, treeNode.IsMostVisited})
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(struct {
+		Body  string             `json:"body"`
+		Board Board              `json:"board"`
+		Eval  map[string]float64 `json:"eval,omitempty"`
+	}{treeNode.Body, treeNode.Board, treeNode.Eval})
+	if err != nil {
+		return err
+	}
 
-	for i, child := range node.Children {
-		childNode := &TreeNode{
-			ID:            fmt.Sprintf("Node_%p", child),
-			Visits:        child.Visits,
-			UCB:           child.UCT(1.41),
-			IsMostVisited: i == 0, // Only mark the most visited path
-			Children:      make([]*TreeNode, 0),
-			Body:          visualizeNode(child),
-			Board:         child.Board,
+	w.Write(before[:len(before)-1])
+	w.WriteString(`,"children":[`)
+	for i, child := range treeChildren(node, depth, opts) {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		if err := writeTreeNode(w, child, false, i == 0, depth+1, opts); err != nil {
+			return err
 		}
-
-		treeNode.Children = append(treeNode.Children, childNode)
-
-		// Recur only on the most visited child
-		// if i == 0 {
-		traverseAndBuildTree(child, childNode)
-		// }
 	}
+	w.WriteString("],")
+	_, err = w.Write(after[1:])
+	return err
 }
@@ -2,9 +2,13 @@ package main
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestVisualizeBoard(t *testing.T) {
@@ -114,3 +118,36 @@ A A A A .
 	// Compare the output with the expected output using testify's assert.Equal
 	assert.Equal(t, expectedOutput, output, "The Voronoi visualization output does not match the expected output")
 }
+
+var ansiCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestVisualizeBoardANSI(t *testing.T) {
+	board := blunderTestBoard()
+	board.Food = []Point{{X: 3, Y: 3}}
+
+	plain := visualizeBoard(board, WithMove(Right, 0))
+	coloured := visualizeBoard(board, WithANSI(), WithMove(Right, 0))
+	assert.Equal(t, plain, ansiCodes.ReplaceAllString(coloured, ""), "colour doesn't move anything")
+	assert.Contains(t, coloured, ansiSnakeColours[0]+ansiBold+"A"+ansiReset, "heads are bold in the snake's colour")
+	assert.Contains(t, coloured, ansiSnakeColours[1]+"b"+ansiReset)
+	assert.Contains(t, coloured, ansiFood+"♥"+ansiReset)
+
+	// a snake in hazard still shows in colour, where the plain board can only show one or the other
+	board.Hazards = []Point{{X: 5, Y: 6}, {X: 2, Y: 2}}
+	plain = visualizeBoard(board)
+	coloured = visualizeBoard(board, WithANSI())
+	assert.Contains(t, plain, "H")
+	assert.Contains(t, coloured, ansiSnakeColours[1]+ansiHazardBackground+"b"+ansiReset)
+	assert.Contains(t, coloured, ansiHazardBackground+"░"+ansiReset)
+}
+
+func TestVisualizeBoardCompact(t *testing.T) {
+	board := blunderTestBoard()
+	plain := strings.Split(visualizeBoard(board), "\n")
+	compact := strings.Split(visualizeBoard(board, WithCompact()), "\n")
+	require.Equal(t, len(plain), len(compact))
+	// the walls either side make it nine cells a row
+	assert.Equal(t, 9*3, utf8.RuneCountInString(plain[0]))
+	assert.Equal(t, 9*2, utf8.RuneCountInString(compact[0]))
+	assert.Equal(t, strings.ReplaceAll(plain[1], "  ", " "), compact[1])
+}
@@ -1,10 +1,9 @@
 package main
 
-import (
-	"container/heap"
-)
+import "sync"
 
-// isLegalMove checks if a move to a new point is legal for the snake.
+// isLegalMove checks if a move to a new point is legal for the snake. Voronoi asks the same thing of an
+// occupancyGrid, which doesn't walk every body each time.
 func isLegalMove(board Board, snakeIndex int, newHead Point, steps int) bool {
 	snake := board.Snakes[snakeIndex]
 
@@ -15,36 +14,16 @@ func isLegalMove(board Board, snakeIndex int, newHead Point, steps int) bool {
 
 	// Check for collisions with other snakes
 	for i := range board.Snakes {
-		otherSnake := board.Snakes[i]
-
-		// Don't consider dead snakes
-		if len(otherSnake.Body) == 0 || otherSnake.Health == 0 {
-			continue
-		}
-
-		// Determine how much of the tail is to be removed based on steps
-		stepsToRemove := steps
-		if snakeIndex < i {
-			stepsToRemove++ // Since the other snake moves after this one, remove one extra
-		}
-
-		// Ensure we do not remove more segments than the snake has
-		if stepsToRemove < len(otherSnake.Body) {
-			otherSnake.Body = otherSnake.Body[0 : len(otherSnake.Body)-stepsToRemove]
-		} else {
-			// If the steps exceed the length of the snake, treat it as having no body
-			otherSnake.Body = []Point{}
-		}
-
-		// Check for collisions with the snake's body
-		for _, segment := range otherSnake.Body {
+		// Check for collisions with what will be left of the snake's body by then
+		for _, segment := range effectiveBodyAfterSteps(&board, snakeIndex, i, steps) {
 			if newHead == segment {
 				return false
 			}
 		}
 
 		// Check for head-to-head collisions where the other snake is longer or equal
-		if newHead == otherSnake.Head && len(otherSnake.Body) >= len(snake.Body) {
+		otherSnake := board.Snakes[i]
+		if i != snakeIndex && !isSnakeDead(otherSnake) && newHead == otherSnake.Head && len(otherSnake.Body) >= len(snake.Body) {
 			return false
 		}
 	}
@@ -53,109 +32,176 @@ func isLegalMove(board Board, snakeIndex int, newHead Point, steps int) bool {
 	return true
 }
 
-type dijkstraNode struct {
-	point       Point
-	snakeIndex  int
-	distance    int // Number of moves from the snake's head
-	snakeLength int // Length of the snake
-}
+// maxHealth is what eating puts a snake's health back up to.
+const maxHealth = 100
 
-// Priority queue for Dijkstra's algorithm
-type PriorityQueue []dijkstraNode
-
-// Implement heap.Interface for PriorityQueue
-func (pq PriorityQueue) Len() int { return len(pq) }
-
-func (pq PriorityQueue) Less(i, j int) bool {
-	// Priority based on distance first, and snake length for tie-breaking
-	if pq[i].distance == pq[j].distance {
-		return pq[i].snakeLength > pq[j].snakeLength
+// starvationHorizon is the furthest a snake can get from its head, in moves, and still be alive when it
+// arrives: as far as its health lasts, or if it can reach food before then, as far as a full belly lasts
+// from the food. foodDistance is -1 if it can't reach any.
+func starvationHorizon(health, foodDistance int) int {
+	if foodDistance >= 0 && foodDistance <= health {
+		return foodDistance + maxHealth - 1
 	}
-	return pq[i].distance < pq[j].distance
+	return health - 1
+}
+
+// GenerateVoronoi generates a board ownership diagram based on a shortest path algorithm. Snakes can't
+// claim cells beyond their starvation horizon, so a starving snake isn't credited with space it would
+// die on the way to.
+func GenerateVoronoi(board Board) [][]int {
+	return newEvaluationContext(board, 0).Voronoi()
 }
 
-func (pq PriorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
+// voronoiScratch is the working space for one run of generateVoronoi. Every leaf the search scores needs a
+// diagram, so it's pooled rather than allocated again for each.
+type voronoiScratch struct {
+	owners    []int // by cell, the snake index that gets there first, -1 for nobody
+	distances []int // by cell, how many moves it takes the owner
+	queue     []int // cells in the order they were claimed
+	seeds     []int // living snakes' indexes, longest first
+	occupancy occupancyGrid
 }
 
-func (pq *PriorityQueue) Push(x interface{}) {
-	*pq = append(*pq, x.(dijkstraNode))
+var voronoiScratchPool = sync.Pool{New: func() any { return &voronoiScratch{} }}
+
+// reset sizes the scratch for a board of the cells and marks every cell unowned.
+func (s *voronoiScratch) reset(cells int) {
+	if cap(s.owners) < cells {
+		s.owners = make([]int, cells)
+		s.distances = make([]int, cells)
+		s.queue = make([]int, 0, cells)
+		s.occupancy = occupancyGrid{
+			lasts:      make([]int, cells),
+			lastsSnake: make([]int, cells),
+			headLength: make([]int, cells),
+		}
+	}
+	s.owners = s.owners[:cells]
+	s.distances = s.distances[:cells]
+	for i := range s.owners {
+		s.owners[i] = -1
+	}
+	s.occupancy.lasts = s.occupancy.lasts[:cells]
+	s.occupancy.lastsSnake = s.occupancy.lastsSnake[:cells]
+	s.occupancy.headLength = s.occupancy.headLength[:cells]
+	s.queue = s.queue[:0]
+	s.seeds = s.seeds[:0]
 }
 
-func (pq *PriorityQueue) Pop() interface{} {
-	old := *pq
-	n := len(old)
-	item := old[n-1]
-	*pq = old[0 : n-1]
-	return item
+// occupancyGrid is the board's bodies laid out by cell and by how long they'll stay, so whether a cell is
+// free some moves from now is a lookup instead of a walk along every body. It answers what isLegalMove
+// does for cells on the board, going by the same turn order.
+type occupancyGrid struct {
+	// lasts is by cell how many more moves the longest lasting segment on it stays put, counting from
+	// before anyone's moved this turn, 0 for nothing there
+	lasts []int
+	// lastsSnake is by cell the lowest index of the snakes with a segment lasting that long. Their tails
+	// are the ones still in place for the longest, see pendingTailMoves.
+	lastsSnake []int
+	// headLength is by cell the length of the longest living snake with its head there, 0 for no head
+	headLength []int
 }
 
-// GenerateVoronoi generates a board ownership diagram based on a shortest path algorithm
-// note, voronoi will
-func GenerateVoronoi(board Board) [][]int {
-	// Track the best path (shortest distance and longest snake) to each position
-	bestPaths := make([][]dijkstraNode, board.Height)
-	for i := range bestPaths {
-		bestPaths[i] = make([]dijkstraNode, board.Width)
-		for j := range bestPaths[i] {
-			bestPaths[i][j] = dijkstraNode{Point{-1, -1}, -1, -1, -1} // Initialize all positions as unassigned
+// build fills the grid in from the board. The slices have to be sized to the board already.
+func (g *occupancyGrid) build(board *Board) {
+	for i := range g.lasts {
+		g.lasts[i], g.lastsSnake[i], g.headLength[i] = 0, -1, 0
+	}
+	for k, snake := range board.Snakes {
+		if isSnakeDead(snake) {
+			continue
+		}
+		for j, segment := range snake.Body {
+			if !isPointInsideBoard(board, segment) {
+				continue
+			}
+			// the segment's still there until the tail has moved past it, which a move at a time takes
+			// as many moves as it is from the end of the body
+			cell, lasts := cellIndex(board, segment), len(snake.Body)-j
+			if lasts > g.lasts[cell] {
+				g.lasts[cell], g.lastsSnake[cell] = lasts, k
+			}
+		}
+		if isPointInsideBoard(board, snake.Head) {
+			cell := cellIndex(board, snake.Head)
+			g.headLength[cell] = max(g.headLength[cell], len(snake.Body))
 		}
 	}
+}
 
-	// Priority queue (min-heap) to process nodes based on distance
-	pq := &PriorityQueue{}
-	heap.Init(pq)
+// free says whether the viewer's head can be on the cell the given number of moves after its next, the
+// same as isLegalMove, assuming nobody eats. It never asks about the viewer's own head, where the
+// head to head check would have it run into itself.
+func (g *occupancyGrid) free(board *Board, viewerIndex, cell, steps int) bool {
+	if g.lasts[cell] > 0 && steps+pendingTailMoves(viewerIndex, g.lastsSnake[cell]) < g.lasts[cell] {
+		return false
+	}
+	return g.headLength[cell] < len(board.Snakes[viewerIndex].Body)
+}
 
-	// Initialize the priority queue with the heads of all snakes
+// generateVoronoi is GenerateVoronoi with each snake's horizon, by snake index, already worked out.
+//
+// Every move costs the same, so it's a flood fill out from all the heads at once rather than a shortest
+// path search. A cell goes to whoever gets there first, and when two snakes get there on the same move to
+// the longer one, which would win the head to head. Starting from the heads longest first keeps every
+// distance's cells in the queue longest first too, so the longer snake always claims a contested cell
+// before the shorter one looks at it and nothing ever has to be taken back. Snakes the same length that
+// tie go by snake index.
+func generateVoronoi(board Board, horizons []int) [][]int {
+	scratch := voronoiScratchPool.Get().(*voronoiScratch)
+	defer voronoiScratchPool.Put(scratch)
+	scratch.reset(board.Width * board.Height)
+	owners, distances := scratch.owners, scratch.distances
+
+	occupancy := &scratch.occupancy
+	occupancy.build(&board)
+
+	// longest first, each slotted in behind any the same length so ties stay in snake order
 	for k, snake := range board.Snakes {
-		if snake.Health > 0 && len(snake.Body) > 0 { // Skip dead or empty snakes
-			head := snake.Head
-			heap.Push(pq, dijkstraNode{head, k, 0, len(snake.Body)})
-			bestPaths[head.Y][head.X] = dijkstraNode{head, k, 0, len(snake.Body)} // Record snake index, distance, and snake length
+		if snake.Health <= 0 || len(snake.Body) == 0 || !isPointInsideBoard(&board, snake.Head) {
+			continue
 		}
+		scratch.seeds = append(scratch.seeds, k)
+		i := len(scratch.seeds) - 1
+		for ; i > 0 && len(board.Snakes[scratch.seeds[i-1]].Body) < len(snake.Body); i-- {
+			scratch.seeds[i] = scratch.seeds[i-1]
+		}
+		scratch.seeds[i] = k
+	}
+	for _, k := range scratch.seeds {
+		cell := cellIndex(&board, board.Snakes[k].Head)
+		owners[cell], distances[cell] = k, 0
+		scratch.queue = append(scratch.queue, cell)
 	}
 
-	// Process nodes in the priority queue
-	for pq.Len() > 0 {
-		node := heap.Pop(pq).(dijkstraNode)
-		currentPoint := node.point
-
-		// Get legal moves for the current point
+	for next := 0; next < len(scratch.queue); next++ {
+		cell := scratch.queue[next]
+		owner, distance := owners[cell], distances[cell]
+		if distance+1 > horizons[owner] {
+			// it would starve before getting any further
+			continue
+		}
+		current := Point{X: cell % board.Width, Y: cell / board.Width}
 		for _, direction := range AllDirections {
-			newPoint := moveHead(currentPoint, direction)
-
-			// Ensure new point is within bounds
-			if newPoint.X >= 0 && newPoint.X < board.Width && newPoint.Y >= 0 && newPoint.Y < board.Height {
-				// Check if the move is legal for the snake at snakeIndex
-				if isLegalMove(board, node.snakeIndex, newPoint, node.distance) {
-					// Compute the new distance to reach this point
-					newDistance := node.distance + 1
-
-					// Check if this path is better (shorter distance or same distance but longer snake)
-					bestNode := bestPaths[newPoint.Y][newPoint.X]
-					if bestNode.snakeIndex == -1 || newDistance < bestNode.distance ||
-						(newDistance == bestNode.distance && node.snakeLength > bestNode.snakeLength) {
-
-						// Update with the better path
-						bestPaths[newPoint.Y][newPoint.X] = dijkstraNode{newPoint, node.snakeIndex, newDistance, node.snakeLength}
-						heap.Push(pq, dijkstraNode{newPoint, node.snakeIndex, newDistance, node.snakeLength})
-					}
-				}
+			newPoint := moveOnBoard(&board, current, direction)
+			if !isPointInsideBoard(&board, newPoint) {
+				continue
+			}
+			newCell := cellIndex(&board, newPoint)
+			if owners[newCell] != -1 {
+				continue
+			}
+			if occupancy.free(&board, owner, newCell, distance) {
+				owners[newCell], distances[newCell] = owner, distance+1
+				scratch.queue = append(scratch.queue, newCell)
 			}
 		}
 	}
 
-	return dijkstraToResult(bestPaths)
-}
-
-// dijkstraToResult converts the bestPaths grid to a simple snake ownership grid (used for debugging)
-func dijkstraToResult(bestPaths [][]dijkstraNode) [][]int {
-	result := make([][]int, len(bestPaths))
-	for i := range result {
-		result[i] = make([]int, len(bestPaths[i]))
-		for j := range result[i] {
-			result[i][j] = bestPaths[i][j].snakeIndex
-		}
+	result := make([][]int, board.Height)
+	cells := append([]int(nil), owners...)
+	for y := range result {
+		result[y] = cells[y*board.Width : (y+1)*board.Width : (y+1)*board.Width]
 	}
 	return result
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"container/heap"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -235,6 +237,71 @@ func TestVoronoi(t *testing.T) {
 	}
 }
 
+func TestStarvationHorizon(t *testing.T) {
+	testCases := []struct {
+		Health       int
+		FoodDistance int
+		Expected     int
+	}{
+		{Health: 100, FoodDistance: -1, Expected: 99},
+		{Health: 3, FoodDistance: -1, Expected: 2},
+		{Health: 1, FoodDistance: -1, Expected: 0},
+		// eating on the last move it has left still counts
+		{Health: 3, FoodDistance: 3, Expected: 102},
+		{Health: 3, FoodDistance: 4, Expected: 2},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.Expected, starvationHorizon(tc.Health, tc.FoodDistance), "health %d, food %d", tc.Health, tc.FoodDistance)
+	}
+}
+
+func TestVoronoiStopsAtStarvationHorizon(t *testing.T) {
+	// a corridor with the longer snake on the left, so it wins the tie in the middle if it lives that long
+	corridor := func(health int, food []Point) Board {
+		return Board{
+			Height: 1,
+			Width:  9,
+			Food:   food,
+			Snakes: []Snake{
+				{ID: "long", Health: health, Head: Point{X: 2, Y: 0}, Body: []Point{{X: 2, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 0}}},
+				{ID: "short", Health: 100, Head: Point{X: 8, Y: 0}, Body: []Point{{X: 8, Y: 0}}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		Description string
+		Board       Board
+		Expected    []int
+	}{
+		{Description: "fed", Board: corridor(100, nil), Expected: []int{-1, -1, 0, 0, 0, 0, 1, 1, 1}},
+		{Description: "starving", Board: corridor(3, nil), Expected: []int{-1, -1, 0, 0, 0, 1, 1, 1, 1}},
+		{Description: "starving next to food", Board: corridor(3, []Point{{X: 3, Y: 0}}), Expected: []int{-1, -1, 0, 0, 0, 0, 1, 1, 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			assert.Equal(t, [][]int{tc.Expected}, GenerateVoronoi(tc.Board))
+		})
+	}
+}
+
+func TestVoronoiWrapped(t *testing.T) {
+	// on a wrapped corridor the short snake reaches round behind the long one
+	board := Board{
+		Height:  1,
+		Width:   9,
+		Wrapped: true,
+		Snakes: []Snake{
+			{ID: "long", Health: 100, Head: Point{X: 3, Y: 0}, Body: []Point{{X: 3, Y: 0}, {X: 2, Y: 0}, {X: 1, Y: 0}}},
+			{ID: "short", Health: 100, Head: Point{X: 7, Y: 0}, Body: []Point{{X: 7, Y: 0}}},
+		},
+	}
+	assert.Equal(t, [][]int{{1, 1, 1, 0, 0, 0, 1, 1, 1}}, GenerateVoronoi(board))
+
+	board.Wrapped = false
+	assert.Equal(t, [][]int{{-1, -1, -1, 0, 0, 0, 1, 1, 1}}, GenerateVoronoi(board))
+}
+
 func BenchmarkGenerateVoronoi(b *testing.B) {
 	// Set up an 11x11 grid with some snakes
 	board := Board{
@@ -255,6 +322,17 @@ func BenchmarkGenerateVoronoi(b *testing.B) {
 	}
 }
 
+// the snakes above are all dead, which leaves the diagram nothing to do. this is a board in play.
+func BenchmarkGenerateVoronoiInPlay(b *testing.B) {
+	board := evalTestBoard()
+	horizons := newEvaluationContext(board, 0).StarvationHorizons()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = generateVoronoi(board, horizons)
+	}
+}
+
 // func TestIsLegalMove(t *testing.T) {
 // 	testCases := []struct {
 // 		Description  string
@@ -311,3 +389,201 @@ func TestEvaluate(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateVoronoiReusesScratch(t *testing.T) {
+	small := evalTestBoard()
+	horizons := newEvaluationContext(small, 0).StarvationHorizons()
+	want := generateVoronoi(small, horizons)
+
+	// a bigger board through the pool first mustn't leave anything behind for the smaller one
+	big := evalTestBoard()
+	big.Width, big.Height = 19, 19
+	generateVoronoi(big, horizons)
+	assert.Equal(t, want, generateVoronoi(small, horizons))
+	assert.Len(t, generateVoronoi(big, horizons), 19)
+}
+
+func TestOccupancyGridMatchesIsLegalMove(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	for i := 0; i < 500; i++ {
+		board := randomRulesBoard(rng, 4)
+		if i%5 == 0 {
+			// a dead snake's body is left where it was and mustn't be in anyone's way
+			board.Snakes[rng.Intn(len(board.Snakes))].Health = 0
+		}
+		grid := occupancyGrid{
+			lasts:      make([]int, board.Width*board.Height),
+			lastsSnake: make([]int, board.Width*board.Height),
+			headLength: make([]int, board.Width*board.Height),
+		}
+		grid.build(&board)
+		for viewer, snake := range board.Snakes {
+			if isSnakeDead(snake) {
+				continue
+			}
+			for y := 0; y < board.Height; y++ {
+				for x := 0; x < board.Width; x++ {
+					p := Point{X: x, Y: y}
+					if p == snake.Head {
+						continue
+					}
+					// past the longest random body everything's moved off
+					for steps := 0; steps <= 10; steps++ {
+						assert.Equal(t, isLegalMove(board, viewer, p, steps), grid.free(&board, viewer, cellIndex(&board, p), steps),
+							"board %d viewer %d %v after %d: %+v", i, viewer, p, steps, board)
+					}
+				}
+			}
+		}
+	}
+}
+
+// dijkstraVoronoi is the shortest path search generateVoronoi used to be, kept to check the flood fill
+// against. The two only differ where snakes the same length tie for a cell: it gave the cell to whichever
+// its heap happened to pop first, where the flood fill always gives it to the lower snake index.
+func dijkstraVoronoi(board Board, horizons []int) [][]int {
+	// Track the best path (shortest distance and longest snake) to each position
+	bestPaths := make([][]dijkstraNode, board.Height)
+	for i := range bestPaths {
+		bestPaths[i] = make([]dijkstraNode, board.Width)
+		for j := range bestPaths[i] {
+			bestPaths[i][j] = dijkstraNode{Point{-1, -1}, -1, -1, -1} // Initialize all positions as unassigned
+		}
+	}
+
+	// Priority queue (min-heap) to process nodes based on distance
+	pq := &dijkstraQueue{}
+	heap.Init(pq)
+
+	// Initialize the priority queue with the heads of all snakes
+	for k, snake := range board.Snakes {
+		if snake.Health > 0 && len(snake.Body) > 0 { // Skip dead or empty snakes
+			head := snake.Head
+			heap.Push(pq, dijkstraNode{head, k, 0, len(snake.Body)})
+			bestPaths[head.Y][head.X] = dijkstraNode{head, k, 0, len(snake.Body)} // Record snake index, distance, and snake length
+		}
+	}
+
+	// Process nodes in the priority queue
+	for pq.Len() > 0 {
+		node := heap.Pop(pq).(dijkstraNode)
+		currentPoint := node.point
+
+		// Get legal moves for the current point
+		for _, direction := range AllDirections {
+			newPoint := moveOnBoard(&board, currentPoint, direction)
+
+			// Ensure new point is within bounds
+			if newPoint.X >= 0 && newPoint.X < board.Width && newPoint.Y >= 0 && newPoint.Y < board.Height {
+				// Check if the move is legal for the snake at snakeIndex
+				if isLegalMove(board, node.snakeIndex, newPoint, node.distance) {
+					// Compute the new distance to reach this point
+					newDistance := node.distance + 1
+					if newDistance > horizons[node.snakeIndex] {
+						// it would starve before getting there
+						continue
+					}
+
+					// Check if this path is better (shorter distance or same distance but longer snake)
+					bestNode := bestPaths[newPoint.Y][newPoint.X]
+					if bestNode.snakeIndex == -1 || newDistance < bestNode.distance ||
+						(newDistance == bestNode.distance && node.snakeLength > bestNode.snakeLength) {
+
+						// Update with the better path
+						bestPaths[newPoint.Y][newPoint.X] = dijkstraNode{newPoint, node.snakeIndex, newDistance, node.snakeLength}
+						heap.Push(pq, dijkstraNode{newPoint, node.snakeIndex, newDistance, node.snakeLength})
+					}
+				}
+			}
+		}
+	}
+
+	return dijkstraToResult(bestPaths)
+}
+
+// dijkstraToResult converts the bestPaths grid to a snake ownership grid.
+func dijkstraToResult(bestPaths [][]dijkstraNode) [][]int {
+	result := make([][]int, len(bestPaths))
+	for i := range result {
+		result[i] = make([]int, len(bestPaths[i]))
+		for j := range result[i] {
+			result[i][j] = bestPaths[i][j].snakeIndex
+		}
+	}
+	return result
+}
+
+type dijkstraNode struct {
+	point       Point
+	snakeIndex  int
+	distance    int // Number of moves from the snake's head
+	snakeLength int // Length of the snake
+}
+
+// dijkstraQueue is the priority queue for dijkstraVoronoi.
+type dijkstraQueue []dijkstraNode
+
+func (pq dijkstraQueue) Len() int { return len(pq) }
+
+func (pq dijkstraQueue) Less(i, j int) bool {
+	// Priority based on distance first, and snake length for tie-breaking
+	if pq[i].distance == pq[j].distance {
+		return pq[i].snakeLength > pq[j].snakeLength
+	}
+	return pq[i].distance < pq[j].distance
+}
+
+func (pq dijkstraQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+}
+
+func (pq *dijkstraQueue) Push(x interface{}) {
+	*pq = append(*pq, x.(dijkstraNode))
+}
+
+func (pq *dijkstraQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	*pq = old[0 : n-1]
+	return item
+}
+
+func TestVoronoiMatchesDijkstra(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	checked := 0
+	for i := 0; i < 2000; i++ {
+		board := randomRulesBoard(rng, 4)
+		lengths := make(map[int]bool)
+		tied := false
+		for _, snake := range board.Snakes {
+			tied = tied || lengths[len(snake.Body)]
+			lengths[len(snake.Body)] = true
+		}
+		if tied {
+			continue
+		}
+		for j := range board.Snakes {
+			board.Snakes[j].Health = 1 + rng.Intn(100)
+		}
+		horizons := newEvaluationContext(board, 0).StarvationHorizons()
+		assert.Equal(t, dijkstraVoronoi(board, horizons), generateVoronoi(board, horizons), "board %d: %+v", i, board)
+		checked++
+	}
+	assert.Greater(t, checked, 500)
+}
+
+func TestVoronoiTies(t *testing.T) {
+	board := Board{
+		Height: 1,
+		Width:  5,
+		Snakes: []Snake{
+			{ID: "a", Health: 100, Head: Point{X: 0, Y: 0}, Body: []Point{{X: 0, Y: 0}}},
+			{ID: "b", Health: 100, Head: Point{X: 4, Y: 0}, Body: []Point{{X: 4, Y: 0}}},
+		},
+	}
+	assert.Equal(t, [][]int{{0, 0, 0, 1, 1}}, GenerateVoronoi(board), "the same length it goes by snake index")
+
+	board.Snakes[1].Body = append(board.Snakes[1].Body, Point{X: 4, Y: 0})
+	assert.Equal(t, [][]int{{0, 0, 1, 1, 1}}, GenerateVoronoi(board), "the longer one wins the middle")
+}
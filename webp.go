@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// The tidbyt wants animated webp, and nothing we can import writes it, so this is a small lossless
+// (vp8l) encoder. It doesn't bother with transforms or backward references, the frames are tiny and
+// mostly one colour, so the prefix codes alone get them small enough.
+
+const (
+	vp8lSignature = 0x2f
+	// vp8lMaxSize is the biggest width or height vp8l can describe, they're 14 bits
+	vp8lMaxSize = 1 << 14
+	// vp8lMaxCodeLength is the longest prefix code vp8l allows, vp8lMaxCodeLengthCode the longest in the code
+	// that codes the code lengths
+	vp8lMaxCodeLength     = 15
+	vp8lMaxCodeLengthCode = 7
+	// the alphabet sizes of the five prefix codes with no colour cache: green (which also has the 24 length
+	// prefixes), red, blue, alpha and distance
+	vp8lGreenAlphabet    = 256 + 24
+	vp8lColourAlphabet   = 256
+	vp8lDistanceAlphabet = 40
+	// webpMaxDuration is the longest a frame can be shown for, durations are 24 bits of milliseconds
+	webpMaxDuration = 1<<24 - 1
+)
+
+// vp8lCodeLengthOrder is the order the code length code's lengths are written in.
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// bitWriter packs bits least significant first, the way vp8l reads them.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.acc |= uint64(value&(1<<n-1)) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+// huffmanLengths works out code lengths for the counts, none longer than limit. Symbols that never come
+// up get no code. It needs at least two symbols that do.
+func huffmanLengths(counts []int, limit int) []int {
+	counts = append([]int(nil), counts...)
+	for {
+		lengths, longest := huffmanTree(counts)
+		if longest <= limit {
+			return lengths
+		}
+		// flatten the counts and go again until the tree's shallow enough
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+	}
+}
+
+// huffmanTree is the plain huffman code lengths for the counts, and the longest of them.
+func huffmanTree(counts []int) ([]int, int) {
+	type node struct {
+		count       int
+		left, right int // children, -1 for a leaf
+		symbol      int
+	}
+	var nodes []node
+	for symbol, count := range counts {
+		if count > 0 {
+			nodes = append(nodes, node{count: count, left: -1, right: -1, symbol: symbol})
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+
+	// two queues: the sorted leaves at the front of nodes, and the merged nodes appended after them,
+	// which come out in order too
+	leafCount := len(nodes)
+	leaf, merged := 0, leafCount
+	next := func() int {
+		if leaf < leafCount && (merged >= len(nodes) || nodes[leaf].count <= nodes[merged].count) {
+			leaf++
+			return leaf - 1
+		}
+		merged++
+		return merged - 1
+	}
+	for i := 0; i < leafCount-1; i++ {
+		a := next()
+		b := next()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, left: a, right: b})
+	}
+
+	lengths := make([]int, len(counts))
+	longest := 0
+	var walk func(index, depth int)
+	walk = func(index, depth int) {
+		n := nodes[index]
+		if n.left < 0 {
+			lengths[n.symbol] = depth
+			longest = max(longest, depth)
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(len(nodes)-1, 0)
+	return lengths, longest
+}
+
+// canonicalCodes assigns the codes for the lengths the way vp8l (and deflate) does, shortest first then
+// by symbol. They come back bit reversed, since the decoder reads a code from its first bit.
+func canonicalCodes(lengths []int) []uint32 {
+	var perLength [vp8lMaxCodeLength + 1]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			perLength[length]++
+		}
+	}
+	var next [vp8lMaxCodeLength + 2]uint32
+	code := uint32(0)
+	for length := 1; length <= vp8lMaxCodeLength; length++ {
+		code = (code + perLength[length-1]) << 1
+		next[length] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		c := next[length]
+		next[length]++
+		reversed := uint32(0)
+		for i := 0; i < length; i++ {
+			reversed = reversed<<1 | c&1
+			c >>= 1
+		}
+		codes[symbol] = reversed
+	}
+	return codes
+}
+
+// prefixCode is a code ready to write symbols with.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (c prefixCode) write(w *bitWriter, symbol int) {
+	w.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writePrefixCode picks the code for the counts and writes it out. One or two small symbols fit the
+// simple code, anything else has its code lengths written with the code length code.
+func writePrefixCode(w *bitWriter, counts []int) prefixCode {
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	lengths := make([]int, len(counts))
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		w.write(1, 1) // simple code
+		w.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			w.write(uint32(used[1]), 8)
+			lengths[used[0]], lengths[used[1]] = 1, 1
+		}
+		// a single symbol takes no bits at all
+		return prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+	}
+
+	lengths = huffmanLengths(counts, vp8lMaxCodeLength)
+	w.write(0, 1) // normal code
+	writeCodeLengths(w, lengths)
+	return prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+// writeCodeLengths writes the lengths with runs of zeros squashed, coded with a code of their own.
+func writeCodeLengths(w *bitWriter, lengths []int) {
+	type token struct {
+		symbol    int
+		extra     uint32
+		extraBits uint
+	}
+	var tokens []token
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			tokens = append(tokens, token{symbol: lengths[i]})
+			i++
+			continue
+		}
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == 0 && run < 138 {
+			run++
+		}
+		switch {
+		case run < 3:
+			for j := 0; j < run; j++ {
+				tokens = append(tokens, token{symbol: 0})
+			}
+		case run <= 10:
+			tokens = append(tokens, token{symbol: 17, extra: uint32(run - 3), extraBits: 3})
+		default:
+			tokens = append(tokens, token{symbol: 18, extra: uint32(run - 11), extraBits: 7})
+		}
+		i += run
+	}
+
+	counts := make([]int, len(vp8lCodeLengthOrder))
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+	// the code needs two symbols to be a tree, a spare one costs nothing
+	used := 0
+	for _, count := range counts {
+		if count > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		if counts[0] == 0 {
+			counts[0] = 1
+		} else {
+			counts[1] = 1
+		}
+	}
+	codeLengths := huffmanLengths(counts, vp8lMaxCodeLengthCode)
+	code := prefixCode{lengths: codeLengths, codes: canonicalCodes(codeLengths)}
+
+	written := 4
+	for i, symbol := range vp8lCodeLengthOrder {
+		if codeLengths[symbol] > 0 {
+			written = max(written, i+1)
+		}
+	}
+	w.write(uint32(written-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:written] {
+		w.write(uint32(codeLengths[symbol]), 3)
+	}
+	w.write(0, 1) // every symbol's length follows, no max_symbol
+	for _, t := range tokens {
+		code.write(w, t.symbol)
+		if t.extraBits > 0 {
+			w.write(t.extra, t.extraBits)
+		}
+	}
+}
+
+// encodeVP8L is the lossless bitstream for the image, what goes in a VP8L chunk.
+func encodeVP8L(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return nil, fmt.Errorf("can't encode a %dx%d image as webp", width, height)
+	}
+
+	pixels := make([]color.NRGBA, 0, width*height)
+	green := make([]int, vp8lGreenAlphabet)
+	red := make([]int, vp8lColourAlphabet)
+	blue := make([]int, vp8lColourAlphabet)
+	alpha := make([]int, vp8lColourAlphabet)
+	alphaUsed := uint32(0)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, c)
+			green[c.G]++
+			red[c.R]++
+			blue[c.B]++
+			alpha[c.A]++
+			if c.A != 0xff {
+				alphaUsed = 1
+			}
+		}
+	}
+
+	w := &bitWriter{}
+	w.write(vp8lSignature, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(alphaUsed, 1)
+	w.write(0, 3) // version
+	w.write(0, 1) // no transforms
+	w.write(0, 1) // no colour cache
+	w.write(0, 1) // one set of prefix codes for the whole image
+
+	greenCode := writePrefixCode(w, green)
+	redCode := writePrefixCode(w, red)
+	blueCode := writePrefixCode(w, blue)
+	alphaCode := writePrefixCode(w, alpha)
+	writePrefixCode(w, make([]int, vp8lDistanceAlphabet)) // nothing refers back, so no distances
+
+	for _, c := range pixels {
+		greenCode.write(w, int(c.G))
+		redCode.write(w, int(c.R))
+		blueCode.write(w, int(c.B))
+		alphaCode.write(w, int(c.A))
+	}
+	return w.bytes(), nil
+}
+
+// riffChunk is the chunk with its header, padded to an even length.
+func riffChunk(fourCC string, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data)+1)
+	copy(chunk, fourCC)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+func riffWebP(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	return riffChunk("RIFF", body)
+}
+
+// encodeWebPAnimation encodes the frames as an animated webp that loops forever, each frame shown for its
+// delay in milliseconds. The frames all have to be the size of the first.
+func encodeWebPAnimation(images []image.Image, delays []int) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, errors.New("no frames to encode")
+	}
+	if len(images) != len(delays) {
+		return nil, fmt.Errorf("%d frames but %d delays", len(images), len(delays))
+	}
+	size := images[0].Bounds().Size()
+
+	chunks := make([][]byte, 0, len(images)+2)
+	var hasAlpha bool
+	for i, img := range images {
+		if img.Bounds().Size() != size {
+			return nil, fmt.Errorf("frame %d is %v, not %v like the first", i, img.Bounds().Size(), size)
+		}
+		if delays[i] < 0 || delays[i] > webpMaxDuration {
+			return nil, fmt.Errorf("frame %d's delay of %dms is out of range", i, delays[i])
+		}
+		bitstream, err := encodeVP8L(img)
+		if err != nil {
+			return nil, err
+		}
+		// the alpha hint is the bit after the signature and dimensions
+		hasAlpha = hasAlpha || bitstream[4]&0x10 != 0
+
+		frame := make([]byte, 16)
+		// the frame sits at 0,0
+		putUint24(frame[6:], size.X-1)
+		putUint24(frame[9:], size.Y-1)
+		putUint24(frame[12:], delays[i])
+		frame[15] = 0x02 // replace the canvas rather than blending over it, and don't dispose
+		chunks = append(chunks, riffChunk("ANMF", append(frame, riffChunk("VP8L", bitstream)...)))
+	}
+
+	header := make([]byte, 10)
+	header[0] = 0x02 // animated
+	if hasAlpha {
+		header[0] |= 0x10
+	}
+	putUint24(header[4:], size.X-1)
+	putUint24(header[7:], size.Y-1)
+	anim := []byte{0, 0, 0, 0xff, 0, 0} // opaque black background (in BGRA), looping forever
+	chunks = append([][]byte{riffChunk("VP8X", header), riffChunk("ANIM", anim)}, chunks...)
+	return riffWebP(chunks...), nil
+}
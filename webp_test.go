@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+// webpChunks splits the riff body into its chunks by fourcc, in order.
+func webpChunks(t *testing.T, data []byte) ([]string, [][]byte) {
+	t.Helper()
+	require.Equal(t, "RIFF", string(data[:4]))
+	require.Equal(t, "WEBP", string(data[8:12]))
+	require.Equal(t, len(data)-8, int(binary.LittleEndian.Uint32(data[4:])))
+	var names []string
+	var chunks [][]byte
+	for rest := data[12:]; len(rest) > 0; {
+		size := int(binary.LittleEndian.Uint32(rest[4:]))
+		names = append(names, string(rest[:4]))
+		chunks = append(chunks, rest[8:8+size])
+		rest = rest[8+size+size%2:]
+	}
+	return names, chunks
+}
+
+// decodeVP8L decodes a bare vp8l bitstream with the standard decoder, which doesn't do animations.
+func decodeVP8L(t *testing.T, bitstream []byte) image.Image {
+	t.Helper()
+	img, err := webp.Decode(bytes.NewReader(riffWebP(riffChunk("VP8L", bitstream))))
+	require.NoError(t, err)
+	return img
+}
+
+func assertSameImage(t *testing.T, want, got image.Image) {
+	t.Helper()
+	require.Equal(t, want.Bounds().Size(), got.Bounds().Size())
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			w := color.NRGBAModel.Convert(want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y))
+			g := color.NRGBAModel.Convert(got.At(got.Bounds().Min.X+x, got.Bounds().Min.Y+y))
+			if !assert.Equal(t, w, g, "pixel %d,%d", x, y) {
+				return
+			}
+		}
+	}
+}
+
+func solidImage(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, canvasWidth, canvasHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{c}, image.Point{}, draw.Src)
+	return img
+}
+
+func TestEncodeVP8LRoundTrips(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := image.NewNRGBA(image.Rect(0, 0, 37, 23))
+	for i := range noise.Pix {
+		noise.Pix[i] = byte(rng.Intn(256))
+	}
+	// skewed enough that the counts need flattening to fit in 15 bits
+	skewed := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for i := 0; i < len(skewed.Pix); i += 4 {
+		v := byte(0)
+		for v < 40 && rng.Intn(2) == 0 {
+			v++
+		}
+		skewed.Pix[i], skewed.Pix[i+1], skewed.Pix[i+2], skewed.Pix[i+3] = v, v*3, 255-v, 255
+	}
+	frames := rendererTestFrames()
+	tidbyt, _ := renderBoardToImage(frames[0], newSnakePalette(frames[0]))
+
+	testCases := []struct {
+		Name  string
+		Image image.Image
+	}{
+		{"one colour", solidImage(color.RGBA{10, 20, 30, 255})},
+		{"tidbyt frame", tidbyt},
+		{"noise with alpha", noise},
+		{"skewed", skewed},
+		{"single pixel", image.NewRGBA(image.Rect(0, 0, 1, 1))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			bitstream, err := encodeVP8L(tc.Image)
+			require.NoError(t, err)
+			assertSameImage(t, tc.Image, decodeVP8L(t, bitstream))
+		})
+	}
+}
+
+func TestHuffmanLengthsLimited(t *testing.T) {
+	// fibonacci counts make the deepest tree there is
+	counts := make([]int, 30)
+	a, b := 1, 1
+	for i := range counts {
+		counts[i] = a
+		a, b = b, a+b
+	}
+	lengths := huffmanLengths(counts, 7)
+	kraft := 0.0
+	for _, length := range lengths {
+		assert.LessOrEqual(t, length, 7)
+		assert.Positive(t, length)
+		kraft += 1 / float64(int(1)<<length)
+	}
+	assert.Equal(t, 1.0, kraft, "the code's complete")
+}
+
+func TestEncodeWebPAnimation(t *testing.T) {
+	red := solidImage(color.RGBA{255, 0, 0, 255})
+	blue := solidImage(color.RGBA{0, 0, 255, 255})
+	data, err := encodeWebPAnimation([]image.Image{red, blue}, []int{100, 2000})
+	require.NoError(t, err)
+
+	names, chunks := webpChunks(t, data)
+	require.Equal(t, []string{"VP8X", "ANIM", "ANMF", "ANMF"}, names)
+	assert.Equal(t, byte(0x02), chunks[0][0], "animated without alpha")
+	assert.Equal(t, []byte{63, 0, 0, 31, 0, 0}, chunks[0][4:], "a 64x32 canvas")
+
+	for i, want := range []struct {
+		img   image.Image
+		delay int
+	}{{red, 100}, {blue, 2000}} {
+		frame := chunks[2+i]
+		assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 63, 0, 0, 31, 0, 0}, frame[:12], "the whole canvas")
+		assert.Equal(t, want.delay, int(frame[12])|int(frame[13])<<8|int(frame[14])<<16)
+		require.Equal(t, "VP8L", string(frame[16:20]))
+		size := binary.LittleEndian.Uint32(frame[20:])
+		assertSameImage(t, want.img, decodeVP8L(t, frame[24:24+size]))
+	}
+
+	_, err = encodeWebPAnimation([]image.Image{red, image.NewRGBA(image.Rect(0, 0, 10, 10))}, []int{1, 1})
+	assert.Error(t, err, "frames have to match")
+	_, err = encodeWebPAnimation([]image.Image{red}, nil)
+	assert.Error(t, err)
+}